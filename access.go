@@ -0,0 +1,74 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// AccessSweep describes a request which requires credentials, and the
+// invalid credentials with which to replay it. See HttpCall.AccessSweep.
+type AccessSweep struct {
+	// Request returns the Steps which create the request, without
+	// credentials, for example with NewRequest and RequestHeader. It
+	// is called for each replay, so that a request body can be read
+	// afresh.
+	Request func() Steps
+	// Header is the header which carries the credentials. If empty,
+	// Authorization is used, and the tokens below are sent as bearer
+	// tokens; otherwise they are sent as the header's value.
+	Header string
+	// ExpiredToken, if non-empty, is an expired token, with which the
+	// request is expected to be refused with 401 Unauthorized.
+	ExpiredToken string
+	// WrongTenantToken, if non-empty, is a valid token for a tenant
+	// (or user) which may not access the resource, with which the
+	// request is expected to be refused with 403 Forbidden.
+	WrongTenantToken string
+}
+
+func (as AccessSweep) credentials(token string) (string, string) {
+	if as.Header == "" {
+		return "Authorization", "Bearer " + token
+	} else {
+		return as.Header, token
+	}
+}
+
+// AccessSweep is a Step that when executed replays the request of
+// sweep without credentials, and then with each of its invalid
+// tokens, erroring at the first replay whose response does not have
+// the expected status: 401 without credentials or with an expired
+// token, and 403 with a wrong tenant's token. The tokens are not
+// shown in step names or errors. hc is left holding the last
+// response.
+func (hc *HttpCall) AccessSweep(sweep AccessSweep) Step {
+	return hc.step("AccessSweep", func() error {
+		variants := []struct {
+			name   string
+			token  string
+			status int
+		}{
+			{"Without credentials", "", http.StatusUnauthorized},
+			{"Expired token", sweep.ExpiredToken, http.StatusUnauthorized},
+			{"Wrong tenant token", sweep.WrongTenantToken, http.StatusForbidden},
+		}
+		for idx, variant := range variants {
+			if idx > 0 && variant.token == "" {
+				continue
+			}
+			steps := sweep.Request()
+			if variant.token != "" {
+				key, value := sweep.credentials(variant.token)
+				hc.redactor().AddValue(variant.token)
+				steps = append(steps, hc.RequestHeader(key, value))
+			}
+			if err := append(steps, hc.ResponseStatusEquals(variant.status)).Go(); err != nil {
+				if hcErr, ok := err.(*HttpCallError); ok {
+					err = hcErr.Err
+				}
+				return fmt.Errorf("%s: %v", variant.name, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,47 @@
+package argot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessSweep(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch auth := r.Header.Get("Authorization"); {
+		case string(body) != `{"tea": "earl grey"}`:
+			w.WriteHeader(http.StatusBadRequest)
+		case auth == "Bearer valid":
+		case auth == "Bearer other-tenant" && r.URL.Path == "/leaky":
+		case auth == "Bearer other-tenant":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	sweep := func(path string) AccessSweep {
+		return AccessSweep{
+			Request: func() Steps {
+				return Steps{hc.NewRequest("POST", server.URL+path, strings.NewReader(`{"tea": "earl grey"}`))}
+			},
+			ExpiredToken:     "expired",
+			WrongTenantToken: "other-tenant",
+		}
+	}
+	Steps{hc.AccessSweep(sweep("/orders"))}.Test(t)
+
+	if _, err := (Steps{hc.AccessSweep(sweep("/leaky"))}).Test(nil); err == nil {
+		t.Fatal("Expected the wrong tenant to be refused.")
+	} else if msg := err.Error(); !strings.HasPrefix(msg, "Wrong tenant token: Status: Expected 403; found 200.") {
+		t.Fatalf("Unexpected error: %s", msg)
+	} else if strings.Contains(msg, "other-tenant") {
+		t.Fatalf("Expected the token to be redacted: %s", msg)
+	}
+}
@@ -0,0 +1,94 @@
+package argot
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// Actor is one of the identities taking part in a scenario, such as an
+// administrator, the owner of a resource, or another user, bundling
+// the HttpCall with which it makes its requests, its credentials, and
+// the Store in which it keeps what it has captured. Scenarios
+// involving several identities, as access-control scenarios do, are
+// then written as the steps of each actor in turn, with every step
+// named after, and every failure prefixed with, the actor which took
+// it. For example:
+//
+//	admin := NewActor("admin", nil).WithBearerToken(adminToken)
+//	user := NewActor("user", nil).WithBearerToken(userToken)
+//	steps := append(admin.Steps(func(hc *HttpCall, store *Store) Steps {
+//		return Steps{hc.NewRequest("POST", url, body), hc.ResponseStatusEquals(201)}
+//	}), user.Steps(func(hc *HttpCall, store *Store) Steps {
+//		return Steps{hc.NewRequest("DELETE", url, nil), hc.ResponseStatusEquals(403)}
+//	})...)
+type Actor struct {
+	Name  string
+	Call  *HttpCall
+	Store *Store
+}
+
+// NewActor creates a new Actor called name, with no credentials, whose
+// HttpCall uses client (see NewHttpCall), and with an empty Store.
+func NewActor(name string, client *http.Client) *Actor {
+	return &Actor{
+		Name:  name,
+		Call:  NewHttpCall(client),
+		Store: NewStore(),
+	}
+}
+
+// WithHeader makes the actor send the header key, with value, on
+// every request which does not already set it, and returns the actor.
+// The value is redacted (see Redactor) from failure output.
+func (a *Actor) WithHeader(key, value string) *Actor {
+	a.Call.ownRedactor().AddValue(value)
+	a.Call.BeforeSend(func(req *http.Request) error {
+		if req.Header.Get(key) == "" {
+			req.Header.Set(key, value)
+		}
+		return nil
+	})
+	return a
+}
+
+// WithBearerToken makes the actor authenticate every request, which
+// does not already set the Authorization header, with token as a
+// bearer token, and returns the actor.
+func (a *Actor) WithBearerToken(token string) *Actor {
+	a.Call.ownRedactor().AddValue(token)
+	return a.WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithBasicAuth makes the actor authenticate every request, which does
+// not already set the Authorization header, with basic authentication,
+// and returns the actor.
+func (a *Actor) WithBasicAuth(username, password string) *Actor {
+	a.Call.ownRedactor().AddValue(password)
+	return a.WithHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+}
+
+// Step wraps step, which should use the actor's HttpCall or Store, so
+// that its name, and its error should it fail, are prefixed with the
+// actor's name. The wrapped error is available through errors.Unwrap,
+// errors.As and so on.
+func (a *Actor) Step(step Step) Step {
+	return NewNamedStep(fmt.Sprintf("%s: %v", a.Name, step), func() error {
+		if err := step.Go(); err != nil {
+			return &MessageError{Message: a.Name, Err: err}
+		} else {
+			return nil
+		}
+	})
+}
+
+// Steps passes the actor's HttpCall and Store to build, and wraps each
+// of the resulting steps with Step.
+func (a *Actor) Steps(build func(hc *HttpCall, store *Store) Steps) Steps {
+	steps := build(a.Call, a.Store)
+	wrapped := make(Steps, len(steps))
+	for idx, step := range steps {
+		wrapped[idx] = a.Step(step)
+	}
+	return wrapped
+}
@@ -0,0 +1,62 @@
+package argot
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestActor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if r.Header.Get("Authorization") == "Bearer admin-token" {
+			w.Header().Set("Location", "/teas/1")
+			w.WriteHeader(http.StatusCreated)
+		} else if user == "alice" && password == "alice-password" {
+			w.WriteHeader(http.StatusForbidden)
+		} else {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	admin := NewActor("admin", nil).WithBearerToken("admin-token")
+	user := NewActor("user", nil).WithBasicAuth("alice", "alice-password")
+	steps := admin.Steps(func(hc *HttpCall, store *Store) Steps {
+		return Steps{
+			hc.NewRequest(http.MethodPost, server.URL+"/teas", nil),
+			hc.ResponseStatusEquals(http.StatusCreated),
+			hc.CaptureHeader(store, "tea", "Location"),
+		}
+	})
+	steps = append(steps, user.Steps(func(hc *HttpCall, store *Store) Steps {
+		return Steps{
+			NewNamedStep("Delete", func() error {
+				return hc.NewRequest(http.MethodDelete, server.URL+admin.Store.GetString("tea"), nil).Go()
+			}),
+			hc.ResponseStatusEquals(http.StatusForbidden),
+		}
+	})...)
+	if err := steps.Go(); err != nil {
+		t.Fatal(err)
+	} else if name := steps[0].(*NamedStep).String(); !strings.HasPrefix(name, "admin: NewRequest(POST: ") {
+		t.Fatalf("Expected the step to be named after the actor; found %s", name)
+	}
+
+	// A request which sets its own credentials keeps them.
+	err := user.Steps(func(hc *HttpCall, store *Store) Steps {
+		return Steps{
+			hc.NewRequest(http.MethodGet, server.URL+"/teas/1", nil),
+			hc.RequestHeader("Authorization", "Bearer stolen"),
+			hc.ResponseStatusEquals(http.StatusForbidden),
+		}
+	}).Go()
+	msgErr := new(MessageError)
+	if !errors.As(err, &msgErr) || !strings.HasPrefix(err.Error(), "user: ") || !strings.Contains(err.Error(), "Expected 403; found 401.") {
+		t.Fatalf("Expected the failure to be prefixed with the actor; found %v", err)
+	} else if strings.Contains(err.Error(), "alice-password") {
+		t.Fatalf("Expected the password to be redacted; found %v", err)
+	}
+}
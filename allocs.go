@@ -0,0 +1,132 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// AllocResult records the allocations made whilst an AllocTest ran.
+type AllocResult struct {
+	Runs int
+	// AllocsPerRun and BytesPerRun are the mean number of heap
+	// allocations, and bytes allocated, per run of the step.
+	AllocsPerRun float64
+	BytesPerRun  float64
+	// HeapGrowth is the difference in live heap, measured after a
+	// garbage collection, between before the first run and after the
+	// last run. Persistent growth suggests a leak.
+	HeapGrowth int64
+}
+
+func (ar *AllocResult) String() string {
+	return fmt.Sprintf("%d runs, %.1f allocs/run, %.0f bytes/run, heap growth %d bytes",
+		ar.Runs, ar.AllocsPerRun, ar.BytesPerRun, ar.HeapGrowth)
+}
+
+// AllocTest is a Step which runs a step repeatedly and records the
+// heap allocations made, in the style of testing.AllocsPerRun.
+// Assertions over the result are made with further steps such as
+// AllocsPerRunUnder. For example:
+//
+//	hc := NewHandlerCall(handler)
+//	allocs := MeasureAllocs(100, Steps{
+//		hc.NewRequest("GET", "http://example.com/hot", nil),
+//		hc.ResponseStatusEquals(200),
+//		StepFunc(hc.Reset),
+//	})
+//	Steps{allocs, allocs.AllocsPerRunUnder(200), allocs.HeapGrowthUnder(64 * 1024)}.Test(t)
+//
+// The step must therefore be repeatable. The runtime's statistics
+// cover the whole process, so the figures include allocations by
+// anything else running at the time, and by the client side of any
+// HttpCall. Handlers served in-process with NewHandlerCall give the
+// most repeatable figures.
+type AllocTest struct {
+	runs int
+	step Step
+	// Result is set once the AllocTest has run.
+	Result *AllocResult
+}
+
+// MeasureAllocs creates a new AllocTest which runs step runs times,
+// after one further warmup run whose allocations are discarded.
+func MeasureAllocs(runs int, step Step) *AllocTest {
+	return &AllocTest{runs: runs, step: step}
+}
+
+func (at *AllocTest) String() string {
+	return fmt.Sprintf("MeasureAllocs(%d: %v)", at.runs, at.step)
+}
+
+// Go runs the step, setting at.Result. It errors if runs is not
+// positive, or if any run of the step errors.
+func (at *AllocTest) Go() error {
+	if at.runs <= 0 {
+		return errors.New("Allocs: runs must be positive.")
+	} else if err := at.step.Go(); err != nil {
+		return fmt.Errorf("Allocs: warmup run: %v", err)
+	}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for idx := 0; idx < at.runs; idx++ {
+		if err := at.step.Go(); err != nil {
+			return fmt.Errorf("Allocs: run %d: %v", idx+1, err)
+		}
+	}
+	runtime.ReadMemStats(&after)
+	result := &AllocResult{
+		Runs:         at.runs,
+		AllocsPerRun: float64(after.Mallocs-before.Mallocs) / float64(at.runs),
+		BytesPerRun:  float64(after.TotalAlloc-before.TotalAlloc) / float64(at.runs),
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	result.HeapGrowth = int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	at.Result = result
+	return nil
+}
+
+func (at *AllocTest) assertion(name string, check func(result *AllocResult) error) Step {
+	return NewNamedStep(name, func() error {
+		if at.Result == nil {
+			return errors.New("Allocs: The allocation test has not been run.")
+		} else {
+			return check(at.Result)
+		}
+	})
+}
+
+// AllocsPerRunUnder is a Step that when executed errors unless the
+// mean number of allocations per run is under limit.
+func (at *AllocTest) AllocsPerRunUnder(limit float64) Step {
+	return at.assertion(fmt.Sprintf("AllocsPerRunUnder(%v)", limit), func(result *AllocResult) error {
+		if result.AllocsPerRun >= limit {
+			return fmt.Errorf("Allocs: Expected under %v allocs/run; found %.1f. %v", limit, result.AllocsPerRun, result)
+		}
+		return nil
+	})
+}
+
+// BytesPerRunUnder is a Step that when executed errors unless the mean
+// number of bytes allocated per run is under limit.
+func (at *AllocTest) BytesPerRunUnder(limit float64) Step {
+	return at.assertion(fmt.Sprintf("BytesPerRunUnder(%v)", limit), func(result *AllocResult) error {
+		if result.BytesPerRun >= limit {
+			return fmt.Errorf("Allocs: Expected under %v bytes/run; found %.0f. %v", limit, result.BytesPerRun, result)
+		}
+		return nil
+	})
+}
+
+// HeapGrowthUnder is a Step that when executed errors unless the live
+// heap grew by less than limit bytes over all the runs.
+func (at *AllocTest) HeapGrowthUnder(limit int64) Step {
+	return at.assertion(fmt.Sprintf("HeapGrowthUnder(%d)", limit), func(result *AllocResult) error {
+		if result.HeapGrowth >= limit {
+			return fmt.Errorf("Allocs: Heap growth: Expected under %d bytes; found %d. %v", limit, result.HeapGrowth, result)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,39 @@
+package argot
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMeasureAllocs(t *testing.T) {
+	var retained [][]byte
+	hc := NewHandlerCall(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/leak" {
+			retained = append(retained, make([]byte, 64*1024))
+		}
+	}))
+	build := func(path string) Steps {
+		return Steps{
+			hc.NewRequest("GET", "http://example.com"+path, nil),
+			hc.ResponseStatusEquals(200),
+			StepFunc(hc.Reset),
+		}
+	}
+
+	quiet := MeasureAllocs(20, build("/"))
+	leaky := MeasureAllocs(20, build("/leak"))
+	Steps{
+		ExpectError(quiet.AllocsPerRunUnder(1)),
+		quiet,
+		quiet.AllocsPerRunUnder(10000),
+		ExpectError(quiet.AllocsPerRunUnder(1)),
+		quiet.BytesPerRunUnder(1024 * 1024),
+		leaky,
+		leaky.BytesPerRunUnder(1024 * 1024),
+		ExpectError(leaky.HeapGrowthUnder(1024 * 1024)),
+		ExpectError(MeasureAllocs(0, build("/"))),
+	}.Test(t)
+	if len(retained) != 21 {
+		t.Fatalf("Expected 21 leaky requests; found %d", len(retained))
+	}
+}
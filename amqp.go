@@ -0,0 +1,184 @@
+package argot
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// AMQPMessage is a message published to, or received from, an AMQP
+// broker.
+type AMQPMessage struct {
+	Exchange    string
+	RoutingKey  string
+	ContentType string
+	Headers     map[string]interface{}
+	Body        []byte
+}
+
+// AMQPClient is the subset of AMQP 0-9-1 operations used by
+// AMQPCall. argot does not bundle an AMQP implementation: wrap the
+// channel of your AMQP library of choice in a few lines, for example
+// with github.com/rabbitmq/amqp091-go, Get is Channel.Get with
+// autoAck set, converting the Delivery to an AMQPMessage.
+type AMQPClient interface {
+	// DeclareQueue declares a durable queue, which is a no-op if it
+	// already exists.
+	DeclareQueue(name string) error
+	// PurgeQueue removes all messages from the queue, returning how
+	// many were removed.
+	PurgeQueue(name string) (int, error)
+	// Publish publishes msg to msg.Exchange with msg.RoutingKey.
+	Publish(msg *AMQPMessage) error
+	// Get fetches and acknowledges a single message from the queue,
+	// returning nil if the queue is empty.
+	Get(queue string) (*AMQPMessage, error)
+}
+
+// AMQPCall provides steps for seeding and verifying the messages of
+// an AMQP broker (such as RabbitMQ) around HTTP calls.
+type AMQPCall struct {
+	Client AMQPClient
+	// How often to poll queues whilst waiting for a message. If zero,
+	// 20 milliseconds is used.
+	PollInterval time.Duration
+}
+
+// NewAMQPCall creates a new AMQPCall using client.
+func NewAMQPCall(client AMQPClient) *AMQPCall {
+	return &AMQPCall{Client: client}
+}
+
+// DeclareQueue is a Step that when executed declares the queue.
+func (ac *AMQPCall) DeclareQueue(name string) Step {
+	return NewNamedStep(fmt.Sprintf("DeclareQueue(%s)", name), func() error {
+		return ac.Client.DeclareQueue(name)
+	})
+}
+
+// PurgeQueue is a Step that when executed removes all messages from
+// the queue.
+func (ac *AMQPCall) PurgeQueue(name string) Step {
+	return NewNamedStep(fmt.Sprintf("PurgeQueue(%s)", name), func() error {
+		_, err := ac.Client.PurgeQueue(name)
+		return err
+	})
+}
+
+// Publish is a Step that when executed publishes body to exchange
+// with routingKey. To publish to a queue directly, use the default
+// exchange ("") with the queue name as the routing key.
+func (ac *AMQPCall) Publish(exchange, routingKey string, body []byte) Step {
+	return NewNamedStep(fmt.Sprintf("Publish(%s: %s)", exchange, routingKey), func() error {
+		return ac.Client.Publish(&AMQPMessage{Exchange: exchange, RoutingKey: routingKey, Body: body})
+	})
+}
+
+// PublishJSON is a Step that when executed publishes value, encoded
+// as JSON, to exchange with routingKey.
+func (ac *AMQPCall) PublishJSON(exchange, routingKey string, value interface{}) Step {
+	return NewNamedStep(fmt.Sprintf("PublishJSON(%s: %s)", exchange, routingKey), func() error {
+		if body, err := json.Marshal(value); err != nil {
+			return err
+		} else {
+			return ac.Client.Publish(&AMQPMessage{
+				Exchange:    exchange,
+				RoutingKey:  routingKey,
+				ContentType: "application/json",
+				Body:        body,
+			})
+		}
+	})
+}
+
+// AMQPMatcher returns nil iff the message matches.
+type AMQPMatcher func(msg *AMQPMessage) error
+
+// AMQPBodyContains matches messages whose body contains value using
+// strings.Contains.
+func AMQPBodyContains(value string) AMQPMatcher {
+	return func(msg *AMQPMessage) error {
+		if !strings.Contains(string(msg.Body), value) {
+			return fmt.Errorf("Body: Expected '%s'; found '%s'.", value, string(msg.Body))
+		}
+		return nil
+	}
+}
+
+// AMQPBodyJSONMatchesStruct matches messages whose body, parsed as
+// JSON based on the type of expected, is equal to expected as
+// validated by the pretty package.
+func AMQPBodyJSONMatchesStruct(expected interface{}) AMQPMatcher {
+	return func(msg *AMQPMessage) error {
+		parseAs := reflect.New(reflect.TypeOf(expected)).Interface()
+		if err := json.Unmarshal(msg.Body, parseAs); err != nil {
+			return err
+		} else if err := compareError(parseAs, expected); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// AMQPHeaderEquals matches messages whose header key, formatted with
+// fmt.Sprint, equals value.
+func AMQPHeaderEquals(key, value string) AMQPMatcher {
+	return func(msg *AMQPMessage) error {
+		if found, ok := msg.Headers[key]; !ok {
+			return fmt.Errorf("Header '%s' not found.", key)
+		} else if fmt.Sprint(found) != value {
+			return fmt.Errorf("Header '%s': Expected '%s'; found '%v'.", key, value, found)
+		}
+		return nil
+	}
+}
+
+func (ac *AMQPCall) pollInterval() time.Duration {
+	if ac.PollInterval == 0 {
+		return 20 * time.Millisecond
+	} else {
+		return ac.PollInterval
+	}
+}
+
+// ExpectMessage is a Step that when executed waits up to timeout for
+// a message on queue, and errors unless it satisfies every matcher.
+// The message is consumed.
+func (ac *AMQPCall) ExpectMessage(queue string, timeout time.Duration, matchers ...AMQPMatcher) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectMessage(%s)", queue), func() error {
+		deadline := time.Now().Add(timeout)
+		for {
+			if msg, err := ac.Client.Get(queue); err != nil {
+				return err
+			} else if msg != nil {
+				for _, matcher := range matchers {
+					if err := matcher(msg); err != nil {
+						return fmt.Errorf("Queue '%s': %v", queue, err)
+					}
+				}
+				return nil
+			} else if !time.Now().Before(deadline) {
+				return fmt.Errorf("Queue '%s': No message received within %v.", queue, timeout)
+			}
+			time.Sleep(ac.pollInterval())
+		}
+	})
+}
+
+// ExpectNoMessage is a Step that when executed waits for wait and
+// errors if a message is then available on queue. Any such message is
+// consumed.
+func (ac *AMQPCall) ExpectNoMessage(queue string, wait time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectNoMessage(%s)", queue), func() error {
+		time.Sleep(wait)
+		if msg, err := ac.Client.Get(queue); err != nil {
+			return err
+		} else if msg != nil {
+			return fmt.Errorf("Queue '%s': Expected no message; found '%s'.", queue, string(msg.Body))
+		} else {
+			return nil
+		}
+	})
+}
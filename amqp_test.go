@@ -0,0 +1,74 @@
+package argot
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryAMQP is an AMQPClient supporting only the default exchange.
+type memoryAMQP struct {
+	lock   sync.Mutex
+	queues map[string][]*AMQPMessage
+}
+
+func (m *memoryAMQP) DeclareQueue(name string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, found := m.queues[name]; !found {
+		m.queues[name] = nil
+	}
+	return nil
+}
+
+func (m *memoryAMQP) PurgeQueue(name string) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	count := len(m.queues[name])
+	m.queues[name] = nil
+	return count, nil
+}
+
+func (m *memoryAMQP) Publish(msg *AMQPMessage) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.queues[msg.RoutingKey] = append(m.queues[msg.RoutingKey], msg)
+	return nil
+}
+
+func (m *memoryAMQP) Get(queue string) (*AMQPMessage, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if msgs := m.queues[queue]; len(msgs) == 0 {
+		return nil, nil
+	} else {
+		m.queues[queue] = msgs[1:]
+		return msgs[0], nil
+	}
+}
+
+func TestAMQPCall(t *testing.T) {
+	client := &memoryAMQP{queues: make(map[string][]*AMQPMessage)}
+	ac := NewAMQPCall(client)
+	type event struct {
+		Kind string
+		ID   int
+	}
+
+	Steps{
+		ac.DeclareQueue("events"),
+		ac.Publish("", "events", []byte("stale")),
+		ac.PurgeQueue("events"),
+		ac.ExpectNoMessage("events", 0),
+		NewNamedStep("PublishLater", func() error {
+			time.AfterFunc(30*time.Millisecond, func() {
+				client.Publish(&AMQPMessage{RoutingKey: "events", Body: []byte(`{"Kind":"created","ID":7}`)})
+			})
+			return nil
+		}),
+		ac.ExpectMessage("events", time.Second, AMQPBodyContains("created"), AMQPBodyJSONMatchesStruct(event{Kind: "created", ID: 7})),
+		ac.PublishJSON("", "events", event{Kind: "deleted"}),
+		ExpectError(ac.ExpectMessage("events", time.Second, AMQPBodyJSONMatchesStruct(event{Kind: "created"}))),
+		ExpectError(ac.ExpectMessage("events", 10*time.Millisecond)),
+	}.Test(t)
+}
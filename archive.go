@@ -0,0 +1,207 @@
+package argot
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// archiveEntry is a file within an archive.
+type archiveEntry struct {
+	name string
+	size int64
+	data []byte
+}
+
+// responseArchive reads the body as a zip archive, or a tar archive,
+// which may be gzipped, according to its leading bytes, and returns
+// its files in archive order. Directories are omitted.
+func (hc *HttpCall) responseArchive() ([]*archiveEntry, error) {
+	var body []byte
+	if _, err := hc.streamBody(func(r io.Reader) (bool, error) {
+		var err error
+		body, err = ioutil.ReadAll(r)
+		return true, err
+	}); err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(body, []byte("PK\x03\x04")) || bytes.HasPrefix(body, []byte("PK\x05\x06")) {
+		return zipEntries(body)
+	} else if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		if reader, err := gzip.NewReader(bytes.NewReader(body)); err != nil {
+			return nil, fmt.Errorf("Archive: %v", err)
+		} else {
+			return tarEntries(reader)
+		}
+	} else {
+		return tarEntries(bytes.NewReader(body))
+	}
+}
+
+func zipEntries(body []byte) ([]*archiveEntry, error) {
+	reader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, fmt.Errorf("Archive: %v", err)
+	}
+	entries := []*archiveEntry{}
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("Archive: %s: %v", file.Name, err)
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Archive: %s: %v", file.Name, err)
+		}
+		entries = append(entries, &archiveEntry{name: file.Name, size: int64(len(data)), data: data})
+	}
+	return entries, nil
+}
+
+func tarEntries(r io.Reader) ([]*archiveEntry, error) {
+	reader := tar.NewReader(r)
+	entries := []*archiveEntry{}
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("Archive: Expected a zip or tar archive: %v", err)
+		} else if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		} else if data, err := ioutil.ReadAll(reader); err != nil {
+			return nil, fmt.Errorf("Archive: %s: %v", header.Name, err)
+		} else {
+			entries = append(entries, &archiveEntry{name: header.Name, size: header.Size, data: data})
+		}
+	}
+}
+
+// responseArchiveEntry returns the named file of the archive.
+func (hc *HttpCall) responseArchiveEntry(name string) (*archiveEntry, error) {
+	if entries, err := hc.responseArchive(); err != nil {
+		return nil, err
+	} else {
+		for _, entry := range entries {
+			if entry.name == name {
+				return entry, nil
+			}
+		}
+		return nil, fmt.Errorf("Archive: Entry '%s' not found.", name)
+	}
+}
+
+// ResponseBodyArchiveEntries is a Step that when executed ensures the
+// body has been received and, treating it as a zip or tar archive
+// (optionally gzipped, as detected from its leading bytes), errors
+// unless the names of its files, in any order, are exactly names.
+// Directories are ignored.
+func (hc *HttpCall) ResponseBodyArchiveEntries(names ...string) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyArchiveEntries(%s)", strings.Join(names, ", ")), func() error {
+		hc.cover("body:")
+		entries, err := hc.responseArchive()
+		if err != nil {
+			return err
+		}
+		got := make([]string, len(entries))
+		for idx, entry := range entries {
+			got[idx] = entry.name
+		}
+		want := append([]string{}, names...)
+		sort.Strings(got)
+		sort.Strings(want)
+		return compareError(got, want)
+	})
+}
+
+// ResponseBodyArchiveHasEntry is a Step that when executed ensures the
+// body has been received and, treating it as an archive (see
+// ResponseBodyArchiveEntries), errors unless it contains a file called
+// name.
+func (hc *HttpCall) ResponseBodyArchiveHasEntry(name string) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyArchiveHasEntry(%s)", name), func() error {
+		hc.cover("body:")
+		_, err := hc.responseArchiveEntry(name)
+		return err
+	})
+}
+
+// ResponseBodyArchiveEntrySize is a Step that when executed ensures
+// the body has been received and, treating it as an archive (see
+// ResponseBodyArchiveEntries), errors unless its file called name is
+// size bytes long, uncompressed.
+func (hc *HttpCall) ResponseBodyArchiveEntrySize(name string, size int64) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyArchiveEntrySize(%s: %d)", name, size), func() error {
+		hc.cover("body:")
+		if entry, err := hc.responseArchiveEntry(name); err != nil {
+			return err
+		} else if entry.size != size {
+			return fmt.Errorf("Archive: Entry '%s': Expected %d bytes; found %d.", name, size, entry.size)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ResponseBodyArchiveEntry is a Step that when executed ensures the
+// body has been received and, treating it as an archive (see
+// ResponseBodyArchiveEntries), errors if check errors with the content
+// of its file called name, for example by parsing the content as a CSV
+// report.
+func (hc *HttpCall) ResponseBodyArchiveEntry(name string, check func(content []byte) error) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyArchiveEntry(%s)", name), func() error {
+		hc.cover("body:")
+		if entry, err := hc.responseArchiveEntry(name); err != nil {
+			return err
+		} else if err := check(entry.data); err != nil {
+			return fmt.Errorf("Archive: Entry '%s': %v", name, err)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ResponseBodyArchiveEntryContains is a Step that when executed
+// ensures the body has been received and, treating it as an archive
+// (see ResponseBodyArchiveEntries), errors unless the content of its
+// file called name contains value.
+func (hc *HttpCall) ResponseBodyArchiveEntryContains(name, value string) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyArchiveEntryContains(%s: %s)", name, value), func() error {
+		hc.cover("body:")
+		if entry, err := hc.responseArchiveEntry(name); err != nil {
+			return err
+		} else if !bytes.Contains(entry.data, []byte(value)) {
+			return fmt.Errorf("Archive: Entry '%s': Expected to contain '%s'; found '%s'.", name, value, entry.data)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ResponseBodyArchiveEntryMatches is a Step that when executed
+// ensures the body has been received and, treating it as an archive
+// (see ResponseBodyArchiveEntries), errors unless the content of its
+// file called name matches pattern.
+func (hc *HttpCall) ResponseBodyArchiveEntryMatches(name string, pattern *regexp.Regexp) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyArchiveEntryMatches(%s: %v)", name, pattern), func() error {
+		hc.cover("body:")
+		if entry, err := hc.responseArchiveEntry(name); err != nil {
+			return err
+		} else if !pattern.Match(entry.data) {
+			return fmt.Errorf("Archive: Entry '%s': Expected to match the pattern '%v'; found '%s'.", name, pattern, entry.data)
+		} else {
+			return nil
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package argot
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestResponseBodyArchive(t *testing.T) {
+	files := map[string]string{"report.csv": "id,total\n1,42\n", "readme.txt": "Quarterly export"}
+	zipped, tarred, gzipped := new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer)
+	zw := zip.NewWriter(zipped)
+	zw.Create("data/")
+	tw := tar.NewWriter(tarred)
+	gw := gzip.NewWriter(gzipped)
+	gtw := tar.NewWriter(gw)
+	for _, name := range []string{"report.csv", "readme.txt"} {
+		if w, err := zw.Create("data/" + name); err != nil {
+			t.Fatal(err)
+		} else {
+			w.Write([]byte(files[name]))
+		}
+		for _, w := range []*tar.Writer{tw, gtw} {
+			w.WriteHeader(&tar.Header{Name: "data/" + name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg})
+			w.Write([]byte(files[name]))
+		}
+	}
+	zw.Close()
+	tw.Close()
+	gtw.Close()
+	gw.Close()
+	bodies := map[string][]byte{"/zip": zipped.Bytes(), "/tar": tarred.Bytes(), "/tgz": gzipped.Bytes()}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body, found := bodies[r.URL.Path]; found {
+			w.Write(body)
+		} else {
+			w.Write([]byte("not an archive"))
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	for path := range bodies {
+		Steps{
+			hc.NewRequest("GET", server.URL+path, nil),
+			hc.ResponseBodyArchiveEntries("data/readme.txt", "data/report.csv"),
+			ExpectError(hc.ResponseBodyArchiveEntries("data/readme.txt")),
+			hc.ResponseBodyArchiveHasEntry("data/report.csv"),
+			ExpectError(hc.ResponseBodyArchiveHasEntry("data/missing.csv")),
+			hc.ResponseBodyArchiveEntrySize("data/readme.txt", 16),
+			ExpectError(hc.ResponseBodyArchiveEntrySize("data/readme.txt", 17)),
+			hc.ResponseBodyArchiveEntryContains("data/report.csv", "1,42"),
+			ExpectError(hc.ResponseBodyArchiveEntryContains("data/report.csv", "2,42")),
+			hc.ResponseBodyArchiveEntryMatches("data/readme.txt", regexp.MustCompile(`^Quarterly`)),
+			hc.ResponseBodyArchiveEntry("data/report.csv", func(content []byte) error {
+				if lines := strings.Count(string(content), "\n"); lines != 2 {
+					return errors.New("Expected 2 lines.")
+				}
+				return nil
+			}),
+		}.Test(t)
+	}
+
+	Steps{
+		hc.NewRequest("GET", server.URL+"/text", nil),
+		ExpectError(hc.ResponseBodyArchiveHasEntry("data/report.csv")),
+	}.Test(t)
+}
@@ -34,7 +34,12 @@ func formatFatalSteps(results Steps, err error) string {
 	if l > 0 {
 		msg = msg + "Failed Step:\n" + defaultConfig.Sprint(&results[l-1]) + "\n"
 	}
-	return fmt.Sprintf("%vError: %v", msg, err)
+	if multi, ok := err.(*MultiError); ok {
+		msg += "Error: " + multi.format()
+	} else {
+		msg += fmt.Sprintf("Error: %v", err)
+	}
+	return DefaultRedactor.String(msg) + seedMessage()
 }
 
 // Test runs the steps in order and returns either all the steps, or
@@ -46,7 +51,7 @@ func (ss Steps) Test(t *testing.T) (results Steps, err error) {
 	if t != nil {
 		defer func() {
 			if err != nil {
-				t.Fatalf(formatFatalSteps(results, err))
+				t.Fatal(formatFatalSteps(results, err))
 			}
 		}()
 	}
@@ -62,7 +67,7 @@ func (ss Steps) Go() error {
 func (ss Steps) run() (Steps, error) {
 	var err error
 	for idx, step := range ss {
-		err = step.Go()
+		_, err = runStep(step)
 		if err != nil {
 			return ss[:idx+1], err
 		}
@@ -98,6 +103,43 @@ func NewNamedStep(name string, step StepFunc) *NamedStep {
 	}
 }
 
+// WithMessage wraps step so that, should it fail, its error is
+// prefixed with msg. This is useful where the step alone does not
+// identify what failed, for example when the same steps are run for
+// each case of a data-driven test. The wrapped error is available
+// through errors.Unwrap, errors.As and so on.
+func WithMessage(step Step, msg string) Step {
+	return NewNamedStep(fmt.Sprint(step), func() error {
+		if err := step.Go(); err != nil {
+			return &MessageError{Message: msg, Err: err}
+		} else {
+			return nil
+		}
+	})
+}
+
+// WithMessagef is as WithMessage but formats the message using
+// fmt.Sprintf.
+func WithMessagef(step Step, format string, args ...interface{}) Step {
+	return WithMessage(step, fmt.Sprintf(format, args...))
+}
+
+// MessageError is the error returned by a step wrapped with
+// WithMessage.
+type MessageError struct {
+	Message string
+	Err     error
+}
+
+func (e *MessageError) Error() string {
+	return e.Message + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error of the wrapped step.
+func (e *MessageError) Unwrap() error {
+	return e.Err
+}
+
 // AnyError is a utility function that returns the first non-nil error
 // in the slice, or nil if either the slice or all elements of the
 // slice are nil.
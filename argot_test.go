@@ -2,6 +2,9 @@ package argot
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -17,3 +20,81 @@ func TestStepsSatisftyStep(t *testing.T) {
 		t.Errorf("Unexpected error result from Steps.Go(): %s", err)
 	}
 }
+
+func TestNegatedExpectations(t *testing.T) {
+	var nilMap map[string]int
+	for _, step := range []Step{
+		ExpectNil(nil),
+		ExpectNil(nilMap),
+		ExpectNotNil(1),
+		ExpectDeepEqual([]int{1}, []int{1}),
+		ExpectNotDeepEqual([]int{1}, []int{2}),
+		ExpectContains("haystack", "hay"),
+		ExpectNotContains("haystack", "needle"),
+		ExpectError(ExpectNotNil(nilMap)),
+	} {
+		if err := step.Go(); err != nil {
+			t.Errorf("%v: %v", step, err)
+		}
+	}
+	if err := ExpectNotContains("haystack", "st").Go(); err == nil || !strings.Contains(err.Error(), "not to contain") {
+		t.Errorf("Unexpected error from ExpectNotContains: %v", err)
+	}
+}
+
+func TestExpectErrorArtifact(t *testing.T) {
+	inner := NewArtifactStep("inner", func(attach func(name, mimeType string, data []byte)) error {
+		attach("inner.txt", "text/plain", []byte("details"))
+		return errors.New("Expected 200; found 404.")
+	})
+	result := RunScenario("negative", Steps{ExpectError(inner)})
+	if !result.Passed() {
+		t.Fatal(result.Err)
+	}
+	artifacts := result.Steps[0].Artifacts
+	if len(artifacts) != 2 || artifacts[0].Name != "expected-error.txt" || artifacts[1].Name != "inner.txt" {
+		t.Fatalf("Unexpected artifacts: %+v", artifacts)
+	} else if data := string(artifacts[0].Data); data != "Step: inner\nError: Expected 200; found 404.\n" {
+		t.Fatalf("Unexpected expected error: %q", data)
+	}
+}
+
+func TestConcurrently(t *testing.T) {
+	var lock sync.Mutex
+	created := map[string]bool{}
+	create := func(i int) Step {
+		return NewNamedStep(fmt.Sprintf("Create(%d)", i), func() error {
+			lock.Lock()
+			defer lock.Unlock()
+			if created["widget"] {
+				return errors.New("duplicate")
+			}
+			created["widget"] = true
+			return nil
+		})
+	}
+	_, err := Steps{Concurrently(5, create)}.Test(nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "4 of 5 concurrent steps failed") {
+		t.Fatalf("Expected 4 failures; found %v", err)
+	}
+	Steps{Concurrently(3, func(i int) Step { return StepFunc(func() error { return nil }) })}.Test(t)
+}
+
+func TestWithMessage(t *testing.T) {
+	cause := errors.New("Expected 200; found 500.")
+	failing := NewNamedStep("ResponseStatusEquals(200)", func() error { return cause })
+
+	step := WithMessagef(failing, "case %d", 3)
+	if name := fmt.Sprint(step); name != "ResponseStatusEquals(200)" {
+		t.Fatalf("Expected the wrapped step's name; found %s", name)
+	}
+	err := step.Go()
+	if err == nil || err.Error() != "case 3: Expected 200; found 500." {
+		t.Fatalf("Unexpected error: %v", err)
+	} else if !errors.Is(err, cause) {
+		t.Fatal("Expected the error to wrap the step's error.")
+	}
+	if err := WithMessage(Steps{}, "case 4").Go(); err != nil {
+		t.Fatal(err)
+	}
+}
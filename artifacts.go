@@ -0,0 +1,139 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Artifact is a named blob of diagnostic output, such as a response
+// dump, a screenshot or a generated payload, attached to the result of
+// the step which produced it (see StepResult.Artifacts) and surfaced by
+// the reporters.
+type Artifact struct {
+	Name     string `json:"name"`
+	MIMEType string `json:"mimeType"`
+	Data     []byte `json:"data"`
+}
+
+// ArtifactSource is implemented by steps, and by errors, which carry
+// artifacts. After running a step, RunScenario attaches the artifacts
+// of the step and of its error to the step's result.
+type ArtifactSource interface {
+	Artifacts() []Artifact
+}
+
+// ArtifactStep is a NamedStep whose function may attach artifacts.
+type ArtifactStep struct {
+	name      string
+	fn        func(attach func(name, mimeType string, data []byte)) error
+	artifacts []Artifact
+}
+
+// NewArtifactStep creates a new ArtifactStep. When the step is run,
+// fn is called with a function which attaches an artifact to the
+// step's result. Artifacts are attached whether or not fn errors, and
+// are replaced each time the step is run.
+func NewArtifactStep(name string, fn func(attach func(name, mimeType string, data []byte)) error) *ArtifactStep {
+	return &ArtifactStep{name: name, fn: fn}
+}
+
+func (as *ArtifactStep) String() string {
+	return as.name
+}
+
+// Go runs the step.
+func (as *ArtifactStep) Go() error {
+	as.artifacts = nil
+	return as.fn(func(name, mimeType string, data []byte) {
+		as.artifacts = append(as.artifacts, Artifact{Name: name, MIMEType: mimeType, Data: data})
+	})
+}
+
+// Artifacts returns the artifacts attached when the step last ran.
+func (as *ArtifactStep) Artifacts() []Artifact {
+	return as.artifacts
+}
+
+// AttachDump is a Step that when executed attaches a dump of hc's
+// request and response (see Dump) as the artifact "dump.txt".
+func (hc *HttpCall) AttachDump() Step {
+	return NewArtifactStep("AttachDump", func(attach func(name, mimeType string, data []byte)) error {
+		if hc.Request == nil {
+			return errors.New("Cannot dump: no request.")
+		}
+		attach("dump.txt", "text/plain", []byte(hc.Dump()))
+		return nil
+	})
+}
+
+// Artifacts returns the dump of the failed call (see
+// HttpCall.DumpOnFailure), if any, as the artifact "dump.txt", and the
+// screenshot of its response (see HttpCall.Screenshot), if any, as the
+// artifact "screenshot.png", or should taking it have failed, the
+// error as the artifact "screenshot-error.txt".
+func (e *HttpCallError) Artifacts() []Artifact {
+	var artifacts []Artifact
+	if e.Dump != "" {
+		artifacts = append(artifacts, Artifact{Name: "dump.txt", MIMEType: "text/plain", Data: []byte(e.Dump)})
+	}
+	if e.Screenshot != nil {
+		artifacts = append(artifacts, Artifact{Name: "screenshot.png", MIMEType: "image/png", Data: e.Screenshot})
+	} else if e.ScreenshotErr != nil {
+		msg := e.ScreenshotErr.Error()
+		if e.redactor != nil {
+			msg = e.redactor.String(msg)
+		}
+		artifacts = append(artifacts, Artifact{Name: "screenshot-error.txt", MIMEType: "text/plain", Data: []byte(msg + "\n")})
+	}
+	return artifacts
+}
+
+// stepArtifacts returns the artifacts of step and of err.
+func stepArtifacts(step Step, err error) []Artifact {
+	var artifacts []Artifact
+	if source, ok := step.(ArtifactSource); ok {
+		artifacts = append(artifacts, source.Artifacts()...)
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		if source, ok := err.(ArtifactSource); ok {
+			artifacts = append(artifacts, source.Artifacts()...)
+			break
+		}
+	}
+	return artifacts
+}
+
+// artifactFileName returns name with every character which is not
+// safe in a file name replaced.
+func artifactFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// WriteArtifacts writes the artifacts of the results to files beneath
+// dir: dir/SCENARIO/STEP-NAME, where SCENARIO is the scenario's name,
+// STEP the step's number, counting from one, and NAME the artifact's
+// name, with unsafe characters replaced.
+func WriteArtifacts(dir string, results []*ScenarioResult) error {
+	for _, result := range results {
+		for idx, step := range result.Steps {
+			for _, artifact := range step.Artifacts {
+				path := filepath.Join(dir, artifactFileName(result.Name), fmt.Sprintf("%d-%s", idx+1, artifactFileName(artifact.Name)))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					return err
+				} else if err := ioutil.WriteFile(path, artifact.Data, 0644); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
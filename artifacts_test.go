@@ -0,0 +1,145 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArtifacts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	hc.DumpOnFailure = true
+	result := RunScenario("brew tea", Steps{
+		NewArtifactStep("payload", func(attach func(name, mimeType string, data []byte)) error {
+			attach("order.json", "application/json", []byte(`{"tea": "earl grey"}`))
+			return nil
+		}),
+		hc.NewRequest("GET", server.URL, nil),
+		hc.AttachDump(),
+		hc.ResponseStatusEquals(200),
+	})
+	if result.Passed() {
+		t.Fatal("Expected the scenario to fail.")
+	}
+	for idx, expected := range []string{"order.json", "", "dump.txt", "dump.txt"} {
+		artifacts := result.Steps[idx].Artifacts
+		if expected == "" && len(artifacts) != 0 || expected != "" && (len(artifacts) != 1 || artifacts[0].Name != expected) {
+			t.Fatalf("Step %d: Expected artifact %q; found %+v.", idx+1, expected, artifacts)
+		}
+	}
+	if dump := string(result.Steps[3].Artifacts[0].Data); !strings.Contains(dump, "short and stout") {
+		t.Fatalf("Expected the failure's dump to include the response; found %s.", dump)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteJSONReport(buf, []*ScenarioResult{result}); err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Scenarios []struct {
+			Steps []struct {
+				Artifacts []Artifact `json:"artifacts"`
+			} `json:"steps"`
+		} `json:"scenarios"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	} else if artifact := report.Scenarios[0].Steps[0].Artifacts[0]; artifact.MIMEType != "application/json" || string(artifact.Data) != `{"tea": "earl grey"}` {
+		t.Fatalf("Expected the JSON report to include the artifacts; found %+v.", artifact)
+	}
+	buf.Reset()
+	if err := WriteMarkdownReport(buf, "Suite", []*ScenarioResult{result}); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(buf.String(), "Artifacts: `dump.txt`") {
+		t.Fatalf("Expected the Markdown report to list the failed step's artifacts; found %s.", buf)
+	}
+
+	dir, err := ioutil.TempDir("", "argot-artifacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := WriteArtifacts(dir, []*ScenarioResult{result}); err != nil {
+		t.Fatal(err)
+	} else if data, err := ioutil.ReadFile(filepath.Join(dir, "brew_tea", "1-order.json")); err != nil || string(data) != `{"tea": "earl grey"}` {
+		t.Fatalf("Expected the artifact to be written; found %s (%v).", data, err)
+	} else if _, err := os.Stat(filepath.Join(dir, "brew_tea", "4-dump.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Artifacts are found on wrapped errors too.
+	wrapped := WithMessage(hc.ResponseStatusEquals(201), "ordering")
+	if artifacts := stepArtifacts(wrapped, wrapped.Go()); len(artifacts) != 1 {
+		t.Fatalf("Expected the wrapped error's artifacts; found %+v.", artifacts)
+	} else if artifacts := stepArtifacts(wrapped, errors.New("plain")); len(artifacts) != 0 {
+		t.Fatalf("Expected no artifacts; found %+v.", artifacts)
+	}
+}
+
+func TestScreenshot(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("<h1>Oops</h1>"))
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	var rendered string
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	hc.Screenshot = func(urlStr string, html []byte) ([]byte, error) {
+		rendered = urlStr + " " + string(html)
+		return []byte("PNG"), nil
+	}
+	result := RunScenario("page", Steps{
+		hc.NewRequest("GET", server.URL+"/page", nil),
+		hc.ResponseStatusEquals(200),
+	})
+	if artifacts := result.Steps[1].Artifacts; len(artifacts) != 1 || artifacts[0].Name != "screenshot.png" || artifacts[0].MIMEType != "image/png" || string(artifacts[0].Data) != "PNG" {
+		t.Fatalf("Expected the screenshot to be attached; found %+v.", artifacts)
+	} else if rendered != server.URL+"/page <h1>Oops</h1>" {
+		t.Fatalf("Expected the HTML response to be rendered; found %q.", rendered)
+	}
+
+	// Responses which are not HTML are not rendered.
+	rendered = ""
+	result = RunScenario("api", Steps{
+		hc.NewRequest("GET", server.URL+"/api", nil),
+		hc.ResponseStatusEquals(200),
+	})
+	if artifacts := result.Steps[1].Artifacts; len(artifacts) != 0 || rendered != "" {
+		t.Fatalf("Expected no screenshot; found %+v.", artifacts)
+	}
+
+	// A failure to render is attached in place of the screenshot.
+	hc.Screenshot = func(urlStr string, html []byte) ([]byte, error) {
+		return nil, errors.New("browser not found")
+	}
+	result = RunScenario("page", Steps{
+		hc.NewRequest("GET", server.URL+"/page", nil),
+		hc.ResponseStatusEquals(200),
+	})
+	if artifacts := result.Steps[1].Artifacts; len(artifacts) != 1 || artifacts[0].Name != "screenshot-error.txt" || !strings.Contains(string(artifacts[0].Data), "browser not found") {
+		t.Fatalf("Expected the screenshot's error to be attached; found %+v.", artifacts)
+	}
+}
@@ -0,0 +1,136 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AsyncJob describes the submit-then-poll flow of an asynchronous job:
+// a request submits the job, and its status is then polled until it
+// reaches a terminal state.
+type AsyncJob struct {
+	// The JSON path (see JSONPath) of the job's id in the body of the
+	// submission's response. If empty, the job has no id.
+	IDPath string
+	// The URL at which the job's status is polled, in which "{id}" is
+	// replaced by the job's id. If empty, the Location header of the
+	// submission's response, resolved against the submission's URL, is
+	// used.
+	StatusURL string
+	// The JSON path of the job's state in the body of the status
+	// response.
+	StatePath string
+	// The terminal states in which the job has succeeded, and failed.
+	// Any other state is polled again.
+	Succeeded []string
+	Failed    []string
+	// How long to poll for before giving up.
+	Timeout time.Duration
+	// If not nil, the job's id is stored in Store as IDKey, for the
+	// success and failure steps.
+	Store *Store
+	IDKey string
+}
+
+func (job AsyncJob) String() string {
+	return fmt.Sprintf("%s until %s or %s within %v", job.StatePath, strings.Join(job.Succeeded, "|"), strings.Join(job.Failed, "|"), job.Timeout)
+}
+
+// asyncJobState returns the state of the job from the body of the
+// status response held by hc.
+func (hc *HttpCall) asyncJobState(job AsyncJob) (string, error) {
+	value, err := hc.responseJSONPath(job.StatePath)
+	if err != nil {
+		return "", err
+	} else if value == nil {
+		return "", fmt.Errorf("JSON path '%s': Expected a state; found null.", job.StatePath)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// RunAsyncJob is a Step that when executed submits the job with
+// hc.Request (which must have been created with NewRequest), errors
+// unless the response status is 2xx, captures the job's id and status
+// URL, and then polls the status URL with GETs (carrying the
+// submission's headers, other than its Content headers), backing off
+// exponentially from 10ms to 1s, until the job reaches a terminal
+// state. It then runs onSuccess, or onFailure, with hc holding the
+// final status response. If onFailure is nil, a failed job is an
+// error. It errors if the job does not reach a terminal state within
+// job.Timeout, giving the last state found.
+func (hc *HttpCall) RunAsyncJob(job AsyncJob, onSuccess, onFailure Steps) Step {
+	return hc.step(fmt.Sprintf("RunAsyncJob(%v)", job), func() error {
+		if err := hc.EnsureResponse(); err != nil {
+			return err
+		} else if hc.Response.StatusCode < 200 || hc.Response.StatusCode > 299 {
+			hc.ReceiveBody()
+			return fmt.Errorf("Job: Submission status: Expected 2xx; found %d: '%s'.", hc.Response.StatusCode, hc.redactor().String(string(hc.ResponseBody)))
+		}
+
+		id := ""
+		if job.IDPath != "" {
+			if value, err := hc.responseJSONPath(job.IDPath); err != nil {
+				return fmt.Errorf("Job: %v", err)
+			} else {
+				id = fmt.Sprint(value)
+			}
+			if job.Store != nil {
+				job.Store.Set(job.IDKey, id)
+			}
+		}
+		statusURL := strings.Replace(job.StatusURL, "{id}", id, -1)
+		if statusURL == "" {
+			if location, err := hc.Response.Location(); err != nil {
+				return fmt.Errorf("Job: No status URL: %v", err)
+			} else {
+				statusURL = location.String()
+			}
+		}
+		header := http.Header{}
+		for key, values := range hc.Request.Header {
+			if !strings.HasPrefix(key, "Content-") {
+				header[key] = values
+			}
+		}
+
+		state := ""
+		err := waitFor(job.Timeout, func() error {
+			err := hc.NewRequest(http.MethodGet, statusURL, nil).Go()
+			if err == nil {
+				for key, values := range header {
+					hc.Request.Header[key] = values
+				}
+				err = hc.ResponseStatusEquals(http.StatusOK).Go()
+			}
+			if hcErr, ok := err.(*HttpCallError); ok {
+				return hcErr.Err
+			} else if err != nil {
+				return err
+			} else if state, err = hc.asyncJobState(job); err != nil {
+				return err
+			}
+			for _, terminal := range append(append([]string{}, job.Succeeded...), job.Failed...) {
+				if state == terminal {
+					return nil
+				}
+			}
+			return fmt.Errorf("State %s is not terminal.", state)
+		})
+		if err != nil {
+			return fmt.Errorf("Job %s: %v", id, err)
+		}
+
+		for _, succeeded := range job.Succeeded {
+			if state == succeeded {
+				return onSuccess.Go()
+			}
+		}
+		if onFailure == nil {
+			hc.ReceiveBody()
+			return fmt.Errorf("Job %s: Failed in state %s: '%s'.", id, state, hc.redactor().String(string(hc.ResponseBody)))
+		}
+		return onFailure.Go()
+	})
+}
@@ -0,0 +1,89 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunAsyncJob(t *testing.T) {
+	var polls int32
+	final := "done"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+		} else if r.Method == http.MethodPost {
+			w.Header().Set("Location", "/jobs/7/status")
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"job": {"id": 7}}`))
+		} else if atomic.AddInt32(&polls, 1) < 3 {
+			w.Write([]byte(`{"state": "running"}`))
+		} else {
+			fmt.Fprintf(w, `{"state": "%s", "result": "ok"}`, final)
+		}
+	}))
+	defer server.Close()
+
+	store := NewStore()
+	job := AsyncJob{
+		IDPath:    "job.id",
+		StatePath: "state",
+		Succeeded: []string{"done"},
+		Failed:    []string{"error"},
+		Timeout:   5 * time.Second,
+		Store:     store,
+		IDKey:     "jobId",
+	}
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("POST", server.URL+"/jobs", strings.NewReader(`{"export": true}`)),
+		hc.RequestHeader("Authorization", "Bearer token"),
+		hc.RequestHeader("Content-Type", "application/json"),
+		hc.RunAsyncJob(job, Steps{hc.ResponseBodyJSONPathEquals("result", "ok")}, nil),
+	}.Test(t)
+	if id := store.GetString("jobId"); id != "7" {
+		t.Fatalf("Expected the job id to be stored; found %q", id)
+	}
+
+	// A failed job runs the failure branch, or errors without one.
+	final = "error"
+	failed := false
+	atomic.StoreInt32(&polls, 0)
+	job.StatusURL = server.URL + "/jobs/{id}"
+	Steps{
+		hc.NewRequest("POST", server.URL+"/jobs", nil),
+		hc.RequestHeader("Authorization", "Bearer token"),
+		hc.RunAsyncJob(job, nil, Steps{NewNamedStep("Failed", func() error { failed = true; return nil })}),
+	}.Test(t)
+	if !failed {
+		t.Fatal("Expected the failure branch to run.")
+	} else if hc.Request.URL.Path != "/jobs/7" {
+		t.Fatalf("Expected the status URL to be polled; found %s", hc.Request.URL)
+	}
+	atomic.StoreInt32(&polls, 0)
+	err := Steps{
+		hc.NewRequest("POST", server.URL+"/jobs", nil),
+		hc.RequestHeader("Authorization", "Bearer token"),
+		hc.RunAsyncJob(job, nil, nil),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "Job 7: Failed in state error") {
+		t.Fatalf("Expected the job to fail; found %v", err)
+	}
+
+	// A job which never finishes times out.
+	final = "running"
+	job.Timeout = 50 * time.Millisecond
+	err = Steps{
+		hc.NewRequest("POST", server.URL+"/jobs", nil),
+		hc.RequestHeader("Authorization", "Bearer token"),
+		hc.RunAsyncJob(job, nil, nil),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "State running is not terminal.") {
+		t.Fatalf("Expected the job to time out; found %v", err)
+	}
+}
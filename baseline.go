@@ -0,0 +1,214 @@
+package argot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RebaselineEnv is the name of the environment variable which, when
+// set to a non-empty value, causes TimingBaseline.Check to replace the
+// stored baseline with the current timings rather than compare
+// against it.
+const RebaselineEnv = "ARGOT_REBASELINE"
+
+// TimingRegression is a timing which has regressed beyond the
+// threshold of a TimingBaseline.
+type TimingRegression struct {
+	Key      string
+	Baseline time.Duration
+	Current  time.Duration
+}
+
+func (tr TimingRegression) String() string {
+	return fmt.Sprintf("%s: %v, %.0f%% slower than the baseline %v.",
+		tr.Key, tr.Current, 100*(float64(tr.Current)/float64(tr.Baseline)-1), tr.Baseline)
+}
+
+// TimingBaseline compares the timings of steps and HTTP calls with
+// those of earlier runs, so that latency creep is caught without
+// absolute budgets. Timings are observed during a run (see
+// RecordResults and ObserveCalls) and, with Check, compared with the
+// stored baseline: the median of each key's timings must not exceed
+// its baseline by more than Threshold percent. Keys without a baseline
+// are not compared. RebaselineCurrent (or Check, with Rebaseline set)
+// stores the current timings as the new baseline, which is persisted
+// with Save. A TimingBaseline is safe for concurrent use.
+type TimingBaseline struct {
+	// The percentage by which a timing may exceed its baseline. If 0,
+	// 20 is used.
+	Threshold float64
+	// Regressions of less than MinIncrease are ignored, so that very
+	// quick steps do not fail on noise.
+	MinIncrease time.Duration
+	// If true, Check rebaselines rather than compares. LoadTimingBaseline
+	// sets it if RebaselineEnv is set.
+	Rebaseline bool
+
+	lock     sync.Mutex
+	baseline map[string]time.Duration
+	current  map[string][]time.Duration
+}
+
+// NewTimingBaseline creates a new, empty, TimingBaseline.
+func NewTimingBaseline() *TimingBaseline {
+	return &TimingBaseline{
+		Rebaseline: os.Getenv(RebaselineEnv) != "",
+		baseline:   make(map[string]time.Duration),
+		current:    make(map[string][]time.Duration),
+	}
+}
+
+// LoadTimingBaseline loads the TimingBaseline saved to path by Save. If
+// path does not exist, as before the first run, an empty
+// TimingBaseline is returned.
+func LoadTimingBaseline(path string) (*TimingBaseline, error) {
+	tb := NewTimingBaseline()
+	bites, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return tb, nil
+	} else if err != nil {
+		return nil, err
+	}
+	timings := make(map[string]float64)
+	if err := json.Unmarshal(bites, &timings); err != nil {
+		return nil, fmt.Errorf("Timing baseline %s: %v", path, err)
+	}
+	for key, ms := range timings {
+		tb.baseline[key] = time.Duration(ms * float64(time.Millisecond))
+	}
+	return tb, nil
+}
+
+// Save writes the baseline to path, as a JSON object of timings in
+// milliseconds.
+func (tb *TimingBaseline) Save(path string) error {
+	tb.lock.Lock()
+	timings := make(map[string]float64, len(tb.baseline))
+	for key, d := range tb.baseline {
+		timings[key] = float64(d) / float64(time.Millisecond)
+	}
+	tb.lock.Unlock()
+	if bites, err := json.MarshalIndent(timings, "", "  "); err != nil {
+		return err
+	} else if err := ioutil.WriteFile(path, append(bites, '\n'), 0644); err != nil {
+		return fmt.Errorf("Timing baseline %s: %v", path, err)
+	} else {
+		return nil
+	}
+}
+
+// Observe records a timing of key in the current run.
+func (tb *TimingBaseline) Observe(key string, d time.Duration) {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+	tb.current[key] = append(tb.current[key], d)
+}
+
+// RecordResults observes the timing of every step which succeeded in
+// results, keyed by the scenario and step names, as "scenario: step".
+func (tb *TimingBaseline) RecordResults(results ...*ScenarioResult) {
+	for _, result := range results {
+		for _, step := range result.Steps {
+			if step.Err == nil {
+				tb.Observe(result.Name+": "+step.Name, step.Duration)
+			}
+		}
+	}
+}
+
+// ObserveCalls observes the timing of every HTTP call which receives a
+// response from now on, other than during a WarmupStep, keyed by its
+// method and URL (without the query), as "METHOD URL". Calls whose
+// URLs vary between runs, such as those containing generated ids,
+// never have a baseline and so are not compared. It returns a
+// function which stops observing.
+func (tb *TimingBaseline) ObserveCalls() func() {
+	return AddEventListener(func(event Event) {
+		if event.Type == RequestFinished && event.Err == nil && !event.Warmup {
+			urlStr := event.URL
+			if idx := strings.IndexByte(urlStr, '?'); idx >= 0 {
+				urlStr = urlStr[:idx]
+			}
+			tb.Observe(event.Method+" "+urlStr, event.Duration)
+		}
+	})
+}
+
+// medians returns the median of the current timings of each key.
+func (tb *TimingBaseline) medians() map[string]time.Duration {
+	medians := make(map[string]time.Duration, len(tb.current))
+	for key, timings := range tb.current {
+		sorted := append([]time.Duration{}, timings...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		medians[key] = percentile(sorted, 50)
+	}
+	return medians
+}
+
+// Regressions returns the timings of the current run which exceed
+// their baseline by more than the threshold, in key order.
+func (tb *TimingBaseline) Regressions() []TimingRegression {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+	threshold := tb.Threshold
+	if threshold == 0 {
+		threshold = 20
+	}
+	medians := tb.medians()
+	keys := make([]string, 0, len(medians))
+	for key := range medians {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	regressions := []TimingRegression{}
+	for _, key := range keys {
+		baseline, found := tb.baseline[key]
+		current := medians[key]
+		if found && baseline > 0 && float64(current) > float64(baseline)*(1+threshold/100) && current-baseline >= tb.MinIncrease {
+			regressions = append(regressions, TimingRegression{Key: key, Baseline: baseline, Current: current})
+		}
+	}
+	return regressions
+}
+
+// RebaselineCurrent replaces the baseline of every key observed in the
+// current run with its current timing. Keys not observed keep their
+// baseline, so that a partial run does not discard the rest.
+func (tb *TimingBaseline) RebaselineCurrent() {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+	for key, median := range tb.medians() {
+		tb.baseline[key] = median
+	}
+}
+
+// Check errors if any timing of the current run has regressed (see
+// Regressions). If tb.Rebaseline is true, it instead rebaselines (see
+// RebaselineCurrent) and returns nil.
+func (tb *TimingBaseline) Check() error {
+	if tb.Rebaseline {
+		tb.RebaselineCurrent()
+		return nil
+	}
+	regressions := tb.Regressions()
+	if len(regressions) == 0 {
+		return nil
+	}
+	lines := make([]string, len(regressions))
+	for idx, regression := range regressions {
+		lines[idx] = regression.String()
+	}
+	return fmt.Errorf("Timings regressed beyond the baseline (rebaseline with $%s):\n\t%s", RebaselineEnv, strings.Join(lines, "\n\t"))
+}
+
+// ExpectNoRegressions is a Step that when executed errors if any
+// timing observed so far has regressed (see Check).
+func (tb *TimingBaseline) ExpectNoRegressions() Step {
+	return NewNamedStep("ExpectNoTimingRegressions", tb.Check)
+}
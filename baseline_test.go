@@ -0,0 +1,90 @@
+package argot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimingBaseline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "argot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "baseline.json")
+
+	tb, err := LoadTimingBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tb.RecordResults(&ScenarioResult{Name: "orders", Steps: []StepResult{
+		{Name: "create", Duration: 100 * time.Millisecond},
+		{Name: "list", Duration: 50 * time.Millisecond},
+	}})
+	if err := tb.Check(); err != nil {
+		t.Fatalf("Expected no regressions without a baseline; found %v", err)
+	}
+	tb.RebaselineCurrent()
+	if err := tb.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tb, err = LoadTimingBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tb.MinIncrease = 5 * time.Millisecond
+	// create regresses by 50%. The median of list is within 20% of its
+	// baseline, as its failed step is ignored, and new has no baseline.
+	tb.RecordResults(&ScenarioResult{Name: "orders", Steps: []StepResult{
+		{Name: "create", Duration: 150 * time.Millisecond},
+		{Name: "list", Duration: 52 * time.Millisecond},
+		{Name: "list", Duration: 53 * time.Millisecond},
+		{Name: "list", Duration: 500 * time.Millisecond, Err: os.ErrNotExist},
+		{Name: "new", Duration: time.Second},
+	}})
+	regressions := tb.Regressions()
+	if len(regressions) != 1 || regressions[0].Key != "orders: create" || regressions[0].Baseline != 100*time.Millisecond || regressions[0].Current != 150*time.Millisecond {
+		t.Fatalf("Unexpected regressions: %v", regressions)
+	}
+	if err := tb.ExpectNoRegressions().Go(); err == nil || !strings.Contains(err.Error(), "orders: create: 150ms, 50% slower than the baseline 100ms.") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tb.Threshold = 60
+	if err := tb.Check(); err != nil {
+		t.Fatalf("Expected no regressions with a higher threshold; found %v", err)
+	}
+
+	tb.Threshold = 0
+	tb.Rebaseline = true
+	if err := tb.Check(); err != nil {
+		t.Fatal(err)
+	} else if tb.Rebaseline = false; len(tb.Regressions()) != 0 {
+		t.Fatalf("Expected no regressions after rebaselining; found %v", tb.Regressions())
+	}
+}
+
+func TestTimingBaselineCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tb := NewTimingBaseline()
+	stop := tb.ObserveCalls()
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("GET", server.URL+"/teas?type=green", nil),
+		hc.ResponseStatusEquals(200),
+	}.Test(t)
+	stop()
+	tb.RebaselineCurrent()
+	if tb.baseline["GET "+server.URL+"/teas"] <= 0 {
+		t.Fatalf("Expected a baseline for the call; found %v", tb.baseline)
+	}
+}
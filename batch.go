@@ -0,0 +1,236 @@
+package argot
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// BatchPart is a request within a multipart/mixed batch request, as
+// used by OData and Google APIs to send several requests at once.
+type BatchPart struct {
+	Method string
+	// The URL of the request, typically only its path and query, for
+	// example "/v1/teas/1".
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// batchBody returns the multipart/mixed body of parts, with boundary.
+func batchBody(boundary string, parts []BatchPart) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	writer := multipart.NewWriter(buf)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+	for idx, part := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", "application/http")
+		header.Set("Content-Transfer-Encoding", "binary")
+		header.Set("Content-ID", fmt.Sprintf("<%d>", idx+1))
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(w, "%s %s HTTP/1.1\r\n", part.Method, part.URL)
+		for _, key := range sortedHeaderKeys(part.Header) {
+			for _, value := range part.Header[key] {
+				fmt.Fprintf(w, "%s: %s\r\n", key, value)
+			}
+		}
+		if len(part.Body) > 0 && part.Header.Get("Content-Length") == "" {
+			fmt.Fprintf(w, "Content-Length: %d\r\n", len(part.Body))
+		}
+		fmt.Fprint(w, "\r\n")
+		w.Write(part.Body)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewBatchRequest is a Step that when executed creates a new POST
+// request to urlStr (as NewRequest does) whose body is a
+// multipart/mixed batch of parts, each an application/http part with
+// a Content-ID of its (1-based) index.
+func (hc *HttpCall) NewBatchRequest(urlStr string, parts ...BatchPart) Step {
+	boundary := "batch_" + multipart.NewWriter(nil).Boundary()
+	body, err := batchBody(boundary, parts)
+	newRequest := hc.NewRequest(http.MethodPost, urlStr, bytes.NewReader(body))
+	return hc.step(fmt.Sprintf("NewBatchRequest(%s: %d parts)", hc.redactor().String(urlStr), len(parts)), func() error {
+		if err != nil {
+			return err
+		} else if err := newRequest.Go(); err != nil {
+			return err
+		}
+		hc.Request.Header.Set("Content-Type", "multipart/mixed; boundary="+boundary)
+		return nil
+	})
+}
+
+// readBatch calls read with each application/http part of the
+// multipart/mixed body, descending into nested multipart/mixed parts
+// (such as OData change sets).
+func readBatch(contentType string, body io.Reader, read func(*bufio.Reader) error) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return err
+	} else if mediaType != "multipart/mixed" {
+		return fmt.Errorf("Batch: Expected multipart/mixed; found %s.", mediaType)
+	}
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if partType := part.Header.Get("Content-Type"); strings.HasPrefix(partType, "multipart/mixed") {
+			err = readBatch(partType, part, read)
+		} else {
+			err = read(bufio.NewReader(part))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// batchRequests returns the requests within hc.Request's batch body,
+// with their URLs resolved against hc.Request.URL.
+func (hc *HttpCall) batchRequests() ([]*http.Request, error) {
+	if err := hc.AssertRequest(); err != nil {
+		return nil, err
+	} else if hc.Request.GetBody == nil {
+		return nil, fmt.Errorf("Batch: Request body cannot be re-read (no GetBody).")
+	}
+	body, err := hc.Request.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	requests := []*http.Request{}
+	err = readBatch(hc.Request.Header.Get("Content-Type"), body, func(r *bufio.Reader) error {
+		req, err := http.ReadRequest(r)
+		if err != nil {
+			return err
+		}
+		bites, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		inner, err := http.NewRequest(req.Method, hc.Request.URL.ResolveReference(req.URL).String(), bytes.NewReader(bites))
+		if err != nil {
+			return err
+		}
+		inner.Header = req.Header
+		requests = append(requests, inner)
+		return nil
+	})
+	return requests, err
+}
+
+// BatchResponses ensures there is a non-nil hc.ResponseBody, and
+// returns the responses within it, which must be a multipart/mixed
+// batch of application/http parts, in order. The body of each response
+// is read into memory, so it can be read again from the response.
+func (hc *HttpCall) BatchResponses() ([]*http.Response, error) {
+	if err := hc.ReceiveBody(); err != nil {
+		return nil, err
+	}
+	responses := []*http.Response{}
+	err := readBatch(hc.Response.Header.Get("Content-Type"), bytes.NewReader(hc.ResponseBody), func(r *bufio.Reader) error {
+		response, err := http.ReadResponse(r, nil)
+		if err != nil {
+			return err
+		}
+		bites, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			return err
+		}
+		response.Body = ioutil.NopCloser(bytes.NewReader(bites))
+		responses = append(responses, response)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Batch: %v", err)
+	}
+	return responses, nil
+}
+
+// ResponseBatchCount is a Step that when executed errors unless the
+// batch response (see BatchResponses) contains n responses.
+func (hc *HttpCall) ResponseBatchCount(n int) Step {
+	return hc.step(fmt.Sprintf("ResponseBatchCount(%d)", n), func() error {
+		if responses, err := hc.BatchResponses(); err != nil {
+			return err
+		} else if len(responses) != n {
+			return fmt.Errorf("Batch: Responses: Expected %d; found %d.", n, len(responses))
+		} else {
+			return nil
+		}
+	})
+}
+
+// ResponseBatchStatuses is a Step that when executed errors unless the
+// batch response (see BatchResponses) contains a response for each of
+// statuses, with that status. Every mismatch is reported.
+func (hc *HttpCall) ResponseBatchStatuses(statuses ...int) Step {
+	return hc.step(fmt.Sprintf("ResponseBatchStatuses(%v)", statuses), func() error {
+		responses, err := hc.BatchResponses()
+		if err != nil {
+			return err
+		} else if len(responses) != len(statuses) {
+			return fmt.Errorf("Batch: Responses: Expected %d; found %d.", len(statuses), len(responses))
+		}
+		failures := []string{}
+		for idx, response := range responses {
+			if response.StatusCode != statuses[idx] {
+				failures = append(failures, fmt.Sprintf("Response %d: Status: Expected %d; found %d.", idx, statuses[idx], response.StatusCode))
+			}
+		}
+		if len(failures) != 0 {
+			return fmt.Errorf("Batch:\n\t%s", strings.Join(failures, "\n\t"))
+		}
+		return nil
+	})
+}
+
+// ResponseBatchPart is a Step that when executed loads the idx-th
+// (0-based) response of the batch response (see BatchResponses), and
+// the corresponding request of the batch request, into inner, which
+// is first reset, so that any of inner's steps can assert on them, for
+// example inner.ResponseBodyJSONPathEquals.
+func (hc *HttpCall) ResponseBatchPart(idx int, inner *HttpCall) Step {
+	return hc.step(fmt.Sprintf("ResponseBatchPart(%d)", idx), func() error {
+		responses, err := hc.BatchResponses()
+		if err != nil {
+			return err
+		} else if idx < 0 || idx >= len(responses) {
+			return fmt.Errorf("Batch: Response %d: Expected fewer than %d responses; found %d.", idx, idx+1, len(responses))
+		}
+		requests, err := hc.batchRequests()
+		if err != nil {
+			return err
+		} else if idx >= len(requests) {
+			return fmt.Errorf("Batch: Request %d: Expected fewer than %d requests; found %d.", idx, idx+1, len(requests))
+		}
+		if err := inner.Reset(); err != nil {
+			return err
+		}
+		inner.Request = requests[idx]
+		inner.Response = responses[idx]
+		inner.Response.Request = inner.Request
+		return nil
+	})
+}
@@ -0,0 +1,102 @@
+package argot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		answers := []string{}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			} else if err != nil || part.Header.Get("Content-Type") != "application/http" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			req, err := http.ReadRequest(bufio.NewReader(part))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body, _ := ioutil.ReadAll(req.Body)
+			if req.URL.Path == "/teas/1" {
+				answers = append(answers, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n{\"name\": \"Earl Grey\"}")
+			} else if req.Method == http.MethodPost {
+				answers = append(answers, fmt.Sprintf("HTTP/1.1 201 Created\r\nContent-Type: application/json\r\n\r\n{\"created\": %s}", body))
+			} else {
+				answers = append(answers, "HTTP/1.1 404 Not Found\r\n\r\n")
+			}
+		}
+		// The last two responses are returned within a nested change set.
+		w.Header().Set("Content-Type", "multipart/mixed; boundary=batchresponse")
+		fmt.Fprintf(w, "--batchresponse\r\nContent-Type: application/http\r\n\r\n%s\r\n", answers[0])
+		fmt.Fprint(w, "--batchresponse\r\nContent-Type: multipart/mixed; boundary=changeset\r\n\r\n")
+		for _, answer := range answers[1:] {
+			fmt.Fprintf(w, "--changeset\r\nContent-Type: application/http\r\n\r\n%s\r\n", answer)
+		}
+		fmt.Fprint(w, "--changeset--\r\n\r\n--batchresponse--\r\n")
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	inner := NewHttpCall(nil)
+	defer inner.Reset()
+	Steps{
+		hc.NewBatchRequest(server.URL+"/batch",
+			BatchPart{Method: "GET", URL: "/teas/1", Header: http.Header{"Accept": {"application/json"}}},
+			BatchPart{Method: "POST", URL: "/teas", Header: http.Header{"Content-Type": {"application/json"}}, Body: []byte(`{"name": "Assam"}`)},
+			BatchPart{Method: "GET", URL: "/teas/2"},
+		),
+		hc.ResponseStatusEquals(http.StatusOK),
+		hc.ResponseBatchCount(3),
+		hc.ResponseBatchStatuses(200, 201, 404),
+		hc.ResponseBatchPart(0, inner),
+		inner.ResponseBodyJSONPathEquals("name", "Earl Grey"),
+		hc.ResponseBatchPart(1, inner),
+		inner.ResponseStatusEquals(http.StatusCreated),
+		inner.ResponseBodyJSONPathEquals("created.name", "Assam"),
+	}.Test(t)
+	if inner.Request.Method != "POST" || inner.Request.URL.String() != server.URL+"/teas" {
+		t.Fatalf("Expected the inner request to be POST %s/teas; found %s %s", server.URL, inner.Request.Method, inner.Request.URL)
+	} else if inner.Request.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected the inner request's headers; found %v", inner.Request.Header)
+	}
+
+	// Each mismatched status is reported.
+	err := hc.ResponseBatchStatuses(200, 200, 200).Go()
+	if err == nil || !strings.Contains(err.Error(), "Response 1: Status: Expected 200; found 201.") || !strings.Contains(err.Error(), "Response 2: Status: Expected 200; found 404.") {
+		t.Fatalf("Expected each mismatched status; found %v", err)
+	}
+	if err := hc.ResponseBatchPart(3, inner).Go(); err == nil || !strings.Contains(err.Error(), "found 3") {
+		t.Fatalf("Expected an error for a missing part; found %v", err)
+	}
+
+	// A response which is not a batch is an error.
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer plain.Close()
+	err = Steps{hc.NewBatchRequest(plain.URL), hc.ResponseBatchCount(0)}.Go()
+	if err == nil || !strings.Contains(err.Error(), "Expected multipart/mixed; found application/json.") {
+		t.Fatalf("Expected an error for a non-batch response; found %v", err)
+	}
+}
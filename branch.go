@@ -0,0 +1,36 @@
+package argot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OnStatus is a Step that when executed ensures there is a non-nil
+// hc.Response and runs the steps of branches keyed by its status, for
+// endpoints with more than one legitimate outcome, such as 200 when a
+// request is completed at once and 202 when it is accepted for later.
+// It errors if the status is not one of the keys of branches, or if
+// any of the steps run error.
+func (hc *HttpCall) OnStatus(branches map[int]Steps) Step {
+	statuses := make([]int, 0, len(branches))
+	for status := range branches {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	strs := make([]string, len(statuses))
+	for idx, status := range statuses {
+		strs[idx] = fmt.Sprint(status)
+	}
+	expected := strings.Join(strs, ", ")
+	return hc.step(fmt.Sprintf("OnStatus(%s)", expected), func() error {
+		hc.cover("status")
+		if err := hc.EnsureResponse(); err != nil {
+			return err
+		} else if steps, found := branches[hc.Response.StatusCode]; !found {
+			return fmt.Errorf("Status: Expected one of %s; found %d.", expected, hc.Response.StatusCode)
+		} else {
+			return steps.Go()
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOnStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/now":
+			w.Write([]byte("done"))
+		case "/later":
+			w.Header().Set("Location", "/jobs/1")
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	branches := map[int]Steps{
+		http.StatusOK:       {hc.ResponseBodyEquals("done")},
+		http.StatusAccepted: {hc.ResponseHeaderEquals("Location", "/jobs/1")},
+	}
+	for _, path := range []string{"/now", "/later"} {
+		if err := (Steps{hc.NewRequest(http.MethodPost, server.URL+path, nil), hc.OnStatus(branches)}).Go(); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	}
+
+	branches[http.StatusAccepted] = Steps{hc.ResponseHeaderEquals("Location", "/jobs/2")}
+	err := Steps{hc.NewRequest(http.MethodPost, server.URL+"/later", nil), hc.OnStatus(branches)}.Go()
+	if hcErr, ok := err.(*HttpCallError); !ok || !strings.HasPrefix(hcErr.Err.Error(), "Header: 'Location'") {
+		t.Fatalf("Expected the branch's failure; found %v", err)
+	}
+
+	step := hc.OnStatus(branches)
+	err = Steps{hc.NewRequest(http.MethodPost, server.URL+"/fail", nil), step}.Go()
+	if hcErr, ok := err.(*HttpCallError); !ok || hcErr.Err.Error() != "Status: Expected one of 200, 202; found 500." {
+		t.Fatalf("Expected the unmapped status to fail; found %v", err)
+	} else if name := step.(*NamedStep).String(); name != "OnStatus(200, 202)" {
+		t.Fatalf("Unexpected name: %s", name)
+	}
+}
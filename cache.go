@@ -0,0 +1,171 @@
+package argot
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResponseCache caches the responses to GET requests, so that static
+// resources fetched by many scenarios (such as discovery documents or
+// token metadata) are fetched only once. It is opt-in: share one
+// ResponseCache between the HttpCalls of the suite, adding it to each
+// with UseCache. Only GET requests with status 200 responses are
+// cached. Requests are identical if they have the same URL and the same
+// values of the KeyHeaders. A ResponseCache is safe for concurrent
+// use.
+type ResponseCache struct {
+	// TTL is how long responses are cached for. If zero, they are
+	// cached forever.
+	TTL time.Duration
+	// The request headers whose values are part of the key.
+	KeyHeaders []string
+
+	lock    sync.Mutex
+	entries map[string]*cachedResponse
+	hits    int
+	misses  int
+}
+
+type cachedResponse struct {
+	status  int
+	proto   string
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// NewResponseCache creates a new ResponseCache whose keys include the
+// Accept and Authorization headers, so that responses are not shared
+// between representations or users.
+func NewResponseCache(ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		TTL:        ttl,
+		KeyHeaders: []string{"Accept", "Authorization"},
+		entries:    make(map[string]*cachedResponse),
+	}
+}
+
+// UseCache adds cache to the middleware of hc (see Use), so that its
+// GET requests may be served from cache.
+func (hc *HttpCall) UseCache(cache *ResponseCache) {
+	hc.Use(cache.Middleware)
+}
+
+// key returns the key of req, or the empty string if req cannot be
+// cached.
+func (rc *ResponseCache) key(req *http.Request) string {
+	if req.Method != http.MethodGet || req.Body != nil && req.Body != http.NoBody {
+		return ""
+	}
+	parts := []string{req.URL.String()}
+	for _, header := range rc.KeyHeaders {
+		parts = append(parts, http.CanonicalHeaderKey(header)+": "+strings.Join(req.Header.Values(header), ", "))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Middleware is the Middleware (see Use) which serves requests from
+// the cache.
+func (rc *ResponseCache) Middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		key := rc.key(req)
+		if key == "" {
+			return next.RoundTrip(req)
+		}
+		rc.lock.Lock()
+		entry, found := rc.entries[key]
+		if found && (entry.expires.IsZero() || time.Now().Before(entry.expires)) {
+			rc.hits++
+			rc.lock.Unlock()
+			return entry.response(req), nil
+		}
+		rc.misses++
+		rc.lock.Unlock()
+
+		response, err := next.RoundTrip(req)
+		if err != nil || response.StatusCode != http.StatusOK {
+			return response, err
+		}
+		body, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		entry = &cachedResponse{status: response.StatusCode, proto: response.Proto, header: response.Header.Clone(), body: body}
+		if rc.TTL > 0 {
+			entry.expires = time.Now().Add(rc.TTL)
+		}
+		rc.lock.Lock()
+		if rc.entries == nil {
+			rc.entries = make(map[string]*cachedResponse)
+		}
+		rc.entries[key] = entry
+		rc.lock.Unlock()
+		return entry.response(req), nil
+	})
+}
+
+// response returns a new response to req from the cached response.
+func (cr *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", cr.status, http.StatusText(cr.status)),
+		StatusCode:    cr.status,
+		Proto:         cr.proto,
+		Header:        cr.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(cr.body)),
+		ContentLength: int64(len(cr.body)),
+		Request:       req,
+	}
+}
+
+// Hits returns the number of requests served from the cache.
+func (rc *ResponseCache) Hits() int {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	return rc.hits
+}
+
+// Misses returns the number of cacheable requests which were not
+// served from the cache.
+func (rc *ResponseCache) Misses() int {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	return rc.misses
+}
+
+// Clear empties the cache and zeroes its counts.
+func (rc *ResponseCache) Clear() {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	rc.entries = make(map[string]*cachedResponse)
+	rc.hits, rc.misses = 0, 0
+}
+
+// ExpectHits is a Step that when executed errors unless exactly n
+// requests have been served from the cache.
+func (rc *ResponseCache) ExpectHits(n int) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectCacheHits(%d)", n), func() error {
+		if found := rc.Hits(); found != n {
+			return fmt.Errorf("Cache: Expected %d hits; found %d.", n, found)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectMisses is a Step that when executed errors unless exactly n
+// cacheable requests have not been served from the cache.
+func (rc *ResponseCache) ExpectMisses(n int) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectCacheMisses(%d)", n), func() error {
+		if found := rc.Misses(); found != n {
+			return fmt.Errorf("Cache: Expected %d misses; found %d.", n, found)
+		} else {
+			return nil
+		}
+	})
+}
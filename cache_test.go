@@ -0,0 +1,64 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"issuer": "` + r.Header.Get("Authorization") + `"}`))
+	}))
+	defer server.Close()
+
+	cache := NewResponseCache(0)
+	for scenario := 0; scenario < 3; scenario++ {
+		hc := NewHttpCall(nil)
+		hc.UseCache(cache)
+		Steps{
+			hc.NewRequest("GET", server.URL+"/.well-known/openid-configuration", nil),
+			hc.ResponseStatusEquals(200),
+			hc.ResponseHeaderEquals("Content-Type", "application/json"),
+			hc.ResponseBodyEquals(`{"issuer": ""}`),
+			hc.NewRequest("GET", server.URL+"/.well-known/openid-configuration", nil),
+			hc.RequestHeader("Authorization", "Bearer other"),
+			hc.ResponseBodyEquals(`{"issuer": "Bearer other"}`),
+			hc.NewRequest("GET", server.URL+"/missing", nil),
+			hc.ResponseStatusEquals(404),
+			hc.NewRequest("POST", server.URL+"/.well-known/openid-configuration", nil),
+			hc.ResponseStatusEquals(200),
+		}.Test(t)
+		hc.Reset()
+	}
+	Steps{
+		cache.ExpectHits(4),
+		cache.ExpectMisses(5),
+		ExpectError(cache.ExpectHits(0)),
+	}.Test(t)
+	if found := atomic.LoadInt32(&fetches); found != 8 {
+		t.Fatalf("Expected 8 fetches; found %d.", found)
+	}
+
+	cache.Clear()
+	cache.TTL = time.Nanosecond
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	hc.UseCache(cache)
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseStatusEquals(200),
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseStatusEquals(200),
+		cache.ExpectHits(0),
+		cache.ExpectMisses(2),
+	}.Test(t)
+}
@@ -0,0 +1,312 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+)
+
+// CassetteMode determines whether a Cassette records or replays.
+type CassetteMode int
+
+const (
+	// ModeReplayOrRecord replays interactions if the cassette file
+	// exists and otherwise records them.
+	ModeReplayOrRecord CassetteMode = iota
+	// ModeRecord always performs real requests, recording them and
+	// discarding any previously recorded interactions.
+	ModeRecord
+	// ModeReplay never performs real requests: every request must
+	// match a recorded interaction.
+	ModeReplay
+)
+
+// RecordedBody is a request or response body as stored in a
+// cassette. Bodies which are not valid UTF-8 are stored base64
+// encoded.
+type RecordedBody struct {
+	Data   string `json:"data"`
+	Base64 bool   `json:"base64,omitempty"`
+}
+
+func newRecordedBody(bites []byte) RecordedBody {
+	if utf8.Valid(bites) {
+		return RecordedBody{Data: string(bites)}
+	} else {
+		return RecordedBody{Data: base64.StdEncoding.EncodeToString(bites), Base64: true}
+	}
+}
+
+// Bytes returns the decoded body.
+func (rb RecordedBody) Bytes() ([]byte, error) {
+	if rb.Base64 {
+		return base64.StdEncoding.DecodeString(rb.Data)
+	} else {
+		return []byte(rb.Data), nil
+	}
+}
+
+// RecordedRequest is a request as stored in a cassette.
+type RecordedRequest struct {
+	Method string       `json:"method"`
+	URL    string       `json:"url"`
+	Header http.Header  `json:"header,omitempty"`
+	Body   RecordedBody `json:"body"`
+}
+
+// RecordedResponse is a response as stored in a cassette.
+type RecordedResponse struct {
+	StatusCode int          `json:"statusCode"`
+	Header     http.Header  `json:"header,omitempty"`
+	Body       RecordedBody `json:"body"`
+}
+
+// Interaction is a single request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// CassetteMatcher decides whether a recorded request matches a live
+// request. body is the live request's body.
+type CassetteMatcher func(req *http.Request, body []byte, recorded *RecordedRequest) bool
+
+// DefaultCassetteMatcher matches requests on method, URL and body.
+func DefaultCassetteMatcher(req *http.Request, body []byte, recorded *RecordedRequest) bool {
+	recordedBody, err := recorded.Body.Bytes()
+	return err == nil && req.Method == recorded.Method && req.URL.String() == recorded.URL && bytes.Equal(body, recordedBody)
+}
+
+// Cassette is an http.RoundTripper which records real request and
+// response pairs to a file, and replays them in later runs. Install
+// it as the Transport of an HttpCall's Client. Interactions are
+// replayed in the order they were recorded, each at most once, so the
+// same request may be recorded several times with different
+// responses.
+type Cassette struct {
+	// Path is the file in which interactions are stored.
+	Path string
+	// Mode determines whether the cassette records or replays.
+	Mode CassetteMode
+	// Transport performs real requests when recording. If nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+	// Matcher decides which recorded interaction is replayed for a
+	// request. If nil, DefaultCassetteMatcher is used. The URL of the
+	// request it is given is redacted, as it is when recorded.
+	Matcher CassetteMatcher
+	// Redact lists headers whose values are never written to the
+	// cassette. NewCassette sets this to DefaultRedactedHeaders.
+	// Bodies are written as they are, as they are needed to match and
+	// replay interactions, so secrets must be kept out of them.
+	Redact []string
+	// Redactor redacts URLs and the values of the other headers
+	// before they are written to the cassette, and live requests are
+	// matched on their redacted URLs. Set it to the Redactor of the
+	// HttpCall using the cassette. If nil, DefaultRedactor is used.
+	Redactor *Redactor
+
+	lock         sync.Mutex
+	recording    bool
+	interactions []*Interaction
+	replayed     []bool
+}
+
+// NewCassette creates a Cassette backed by the file at path. Unless
+// recording, the file is loaded immediately.
+func NewCassette(path string, mode CassetteMode) (*Cassette, error) {
+	c := &Cassette{
+		Path:   path,
+		Mode:   mode,
+		Redact: DefaultRedactedHeaders,
+	}
+	switch _, err := os.Stat(path); {
+	case mode == ModeRecord, mode == ModeReplayOrRecord && os.IsNotExist(err):
+		c.recording = true
+		return c, nil
+	case err != nil:
+		return nil, err
+	}
+	if bites, err := ioutil.ReadFile(path); err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(bites, &c.interactions); err != nil {
+		return nil, fmt.Errorf("Cassette %s: %v", path, err)
+	} else {
+		c.replayed = make([]bool, len(c.interactions))
+		return c, nil
+	}
+}
+
+// Recording returns true iff the cassette is recording rather than
+// replaying.
+func (c *Cassette) Recording() bool {
+	return c.recording
+}
+
+func (c *Cassette) redactor() *Redactor {
+	if c.Redactor == nil {
+		return DefaultRedactor
+	} else {
+		return c.Redactor
+	}
+}
+
+func (c *Cassette) redact(header http.Header) http.Header {
+	header = redactHeader(header, c.Redact)
+	redactor := c.redactor()
+	for _, values := range header {
+		for idx, value := range values {
+			values[idx] = redactor.String(value)
+		}
+	}
+	return header
+}
+
+func (c *Cassette) redactURL(u *url.URL) string {
+	return c.redactor().String(u.String())
+}
+
+func cloneHeader(header http.Header) http.Header {
+	clone := make(http.Header, len(header))
+	for key, values := range header {
+		clone[key] = append([]string(nil), values...)
+	}
+	return clone
+}
+
+// readRequestBody returns the request body, leaving req able to be
+// sent.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	} else if req.GetBody != nil {
+		if body, err := req.GetBody(); err != nil {
+			return nil, err
+		} else {
+			defer body.Close()
+			return ioutil.ReadAll(body)
+		}
+	} else {
+		bites, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(bites))
+		return bites, err
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// The body must be replaced once read, which must not be done
+		// to the caller's request.
+		req = req.Clone(req.Context())
+	}
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	} else if c.recording {
+		return c.record(req, body)
+	} else {
+		return c.replay(req, body)
+	}
+}
+
+func (c *Cassette) record(req *http.Request, body []byte) (*http.Response, error) {
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	response, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.interactions = append(c.interactions, &Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    c.redactURL(req.URL),
+			Header: c.redact(req.Header),
+			Body:   newRecordedBody(body),
+		},
+		Response: RecordedResponse{
+			StatusCode: response.StatusCode,
+			Header:     c.redact(response.Header),
+			Body:       newRecordedBody(responseBody),
+		},
+	})
+	return response, nil
+}
+
+func (c *Cassette) replay(req *http.Request, body []byte) (*http.Response, error) {
+	matcher := c.Matcher
+	if matcher == nil {
+		matcher = DefaultCassetteMatcher
+	}
+	// Recorded URLs are redacted, so match on the redacted URL.
+	redacted := req.WithContext(req.Context())
+	if u, err := url.Parse(c.redactURL(req.URL)); err != nil {
+		return nil, err
+	} else {
+		redacted.URL = u
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for idx, interaction := range c.interactions {
+		if c.replayed[idx] || !matcher(redacted, body, &interaction.Request) {
+			continue
+		}
+		responseBody, err := interaction.Response.Body.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		c.replayed[idx] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        cloneHeader(interaction.Response.Header),
+			Body:          ioutil.NopCloser(bytes.NewReader(responseBody)),
+			ContentLength: int64(len(responseBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("Cassette %s: no unreplayed interaction matches %s %s", c.Path, req.Method, redacted.URL)
+}
+
+// Save writes recorded interactions to c.Path. It does nothing when
+// replaying.
+func (c *Cassette) Save() error {
+	if !c.recording {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.interactions == nil {
+		return errors.New("Cassette: nothing recorded")
+	} else if bites, err := json.MarshalIndent(c.interactions, "", "  "); err != nil {
+		return err
+	} else if err := os.MkdirAll(filepath.Dir(c.Path), 0755); err != nil {
+		return err
+	} else {
+		return ioutil.WriteFile(c.Path, append(bites, '\n'), 0644)
+	}
+}
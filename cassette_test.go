@@ -0,0 +1,98 @@
+package argot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "argot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassette.json")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello " + r.Header.Get("Authorization")))
+	}))
+	url := server.URL + "/?access_token=hunter2"
+
+	scenario := func(cassette *Cassette) error {
+		hc := NewHttpCall(&http.Client{Transport: cassette})
+		defer hc.Reset()
+		_, err := Steps{
+			hc.NewRequest("GET", url, nil),
+			hc.RequestHeader("Authorization", "secret"),
+			hc.ResponseStatusEquals(http.StatusOK),
+			hc.ResponseBodyEquals("hello secret"),
+		}.Test(nil)
+		return err
+	}
+
+	if cassette, err := NewCassette(path, ModeReplayOrRecord); err != nil {
+		t.Fatal(err)
+	} else if !cassette.Recording() {
+		t.Fatal("Expected cassette to be recording.")
+	} else if err := AnyError(scenario(cassette), cassette.Save()); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	if bites, err := ioutil.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if strings.Contains(string(bites), `"secret"`) {
+		t.Fatal("Expected Authorization header to be redacted.")
+	} else if strings.Contains(string(bites), "hunter2") || !strings.Contains(string(bites), "access_token=REDACTED") {
+		t.Fatalf("Expected the access token in the URL to be redacted:\n%s", bites)
+	} else if !strings.Contains(string(bites), `"REDACTED"`) {
+		t.Fatalf("Expected the redacted header to be recorded as REDACTED:\n%s", bites)
+	} else if !strings.Contains(string(bites), "hello secret") {
+		// Bodies are recorded as they are, so that they can be replayed.
+		t.Fatalf("Expected the response body to be recorded verbatim:\n%s", bites)
+	}
+
+	if cassette, err := NewCassette(path, ModeReplayOrRecord); err != nil {
+		t.Fatal(err)
+	} else if cassette.Recording() {
+		t.Fatal("Expected cassette to be replaying.")
+	} else if err := scenario(cassette); err != nil {
+		t.Fatal(err)
+	} else if err := scenario(cassette); err == nil {
+		t.Fatal("Expected interaction to be replayed only once.")
+	}
+}
+
+func TestCassetteLeavesRequestUnmodified(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	cassette := &Cassette{Path: "unused", recording: true}
+	body := ioutil.NopCloser(strings.NewReader("hello"))
+	req, err := http.NewRequest("POST", server.URL, body)
+	if err != nil {
+		t.Fatal(err)
+	} else if req.GetBody != nil {
+		t.Fatal("Expected the request to have no GetBody.")
+	}
+	response, err := cassette.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if req.Body != body {
+		t.Fatal("Expected the request body not to be replaced.")
+	} else if bites, _ := ioutil.ReadAll(response.Body); string(bites) != "hello" {
+		t.Fatalf("Expected the body to be sent; found '%s'", bites)
+	} else if recorded := cassette.interactions[0].Request.Body.Data; recorded != "hello" {
+		t.Fatalf("Expected the body to be recorded; found '%s'", recorded)
+	}
+}
@@ -0,0 +1,210 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Fault describes how a dependency is made to misbehave. The zero
+// Fault does nothing.
+type Fault struct {
+	// If true, the dependency is cut off entirely: connections to it
+	// are refused.
+	Down bool
+	// Added to the time taken by each response, give or take Jitter.
+	Latency time.Duration
+	Jitter  time.Duration
+	// If positive, the rate, in KB per second, to which the bandwidth
+	// of responses is limited.
+	BandwidthKBps int
+}
+
+func (f Fault) String() string {
+	parts := []string{}
+	if f.Down {
+		parts = append(parts, "down")
+	}
+	if f.Latency > 0 || f.Jitter > 0 {
+		parts = append(parts, fmt.Sprintf("latency %v±%v", f.Latency, f.Jitter))
+	}
+	if f.BandwidthKBps > 0 {
+		parts = append(parts, fmt.Sprintf("bandwidth %dKB/s", f.BandwidthKBps))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ChaosProvider cuts or degrades the named upstream dependencies of
+// the system under test, typically through a proxy which the system
+// under test is configured to reach them through.
+type ChaosProvider interface {
+	// Inject applies fault to dependency, in addition to any faults
+	// already applied.
+	Inject(dependency string, fault Fault) error
+	// Restore removes every fault applied to dependency.
+	Restore(dependency string) error
+}
+
+// Toxiproxy is a ChaosProvider for Toxiproxy
+// (https://github.com/Shopify/toxiproxy), through its HTTP API. A
+// dependency is the name of a proxy. Down disables the proxy; the
+// other faults are added as toxics, named with the prefix "argot_",
+// to responses (the downstream direction). Restore removes only those
+// toxics, and enables the proxy.
+type Toxiproxy struct {
+	// The URL of Toxiproxy's API, for example http://localhost:8474.
+	URL string
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// NewToxiproxy creates a new Toxiproxy for the API at url.
+func NewToxiproxy(url string) *Toxiproxy {
+	return &Toxiproxy{URL: url}
+}
+
+func (tp *Toxiproxy) proxyURL(dependency string) string {
+	return strings.TrimSuffix(tp.URL, "/") + "/proxies/" + url.PathEscape(dependency)
+}
+
+func (tp *Toxiproxy) addToxic(dependency, name string, attributes map[string]interface{}) error {
+	toxic := map[string]interface{}{
+		"name":       "argot_" + name,
+		"type":       name,
+		"stream":     "downstream",
+		"toxicity":   1.0,
+		"attributes": attributes,
+	}
+	return doFeatureFlagRequest(tp.Client, nil, http.MethodPost, tp.proxyURL(dependency)+"/toxics", "application/json", toxic, nil)
+}
+
+// Inject disables the proxy of dependency, or adds toxics to it.
+func (tp *Toxiproxy) Inject(dependency string, fault Fault) error {
+	if fault.Down {
+		if err := doFeatureFlagRequest(tp.Client, nil, http.MethodPost, tp.proxyURL(dependency), "application/json", map[string]interface{}{"enabled": false}, nil); err != nil {
+			return err
+		}
+	}
+	if fault.Latency > 0 || fault.Jitter > 0 {
+		if err := tp.addToxic(dependency, "latency", map[string]interface{}{
+			"latency": fault.Latency.Milliseconds(),
+			"jitter":  fault.Jitter.Milliseconds(),
+		}); err != nil {
+			return err
+		}
+	}
+	if fault.BandwidthKBps > 0 {
+		if err := tp.addToxic(dependency, "bandwidth", map[string]interface{}{"rate": fault.BandwidthKBps}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore removes the toxics added by Inject from the proxy of
+// dependency, and enables it.
+func (tp *Toxiproxy) Restore(dependency string) error {
+	toxics := []struct {
+		Name string `json:"name"`
+	}{}
+	if err := doFeatureFlagRequest(tp.Client, nil, http.MethodGet, tp.proxyURL(dependency)+"/toxics", "", nil, &toxics); err != nil {
+		return err
+	}
+	for _, toxic := range toxics {
+		if strings.HasPrefix(toxic.Name, "argot_") {
+			if err := doFeatureFlagRequest(tp.Client, nil, http.MethodDelete, tp.proxyURL(dependency)+"/toxics/"+url.PathEscape(toxic.Name), "", nil, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return doFeatureFlagRequest(tp.Client, nil, http.MethodPost, tp.proxyURL(dependency), "application/json", map[string]interface{}{"enabled": true}, nil)
+}
+
+// Chaos provides steps which cut or degrade the dependencies of the
+// system under test with a ChaosProvider, so that resilience
+// behaviours, such as fallbacks and circuit breakers, can be asserted
+// end to end. It remembers the dependencies faulted so that
+// RestoreAll can restore them; use WithFault to guarantee restoration
+// even if the scenario fails. A Chaos can only be used by a single
+// go-routine at a time.
+type Chaos struct {
+	Provider ChaosProvider
+
+	faulted []string
+}
+
+// NewChaos creates a new Chaos using provider.
+func NewChaos(provider ChaosProvider) *Chaos {
+	return &Chaos{Provider: provider}
+}
+
+// Inject is a Step that when executed applies fault to dependency.
+func (c *Chaos) Inject(dependency string, fault Fault) Step {
+	return NewNamedStep(fmt.Sprintf("InjectFault(%s: %v)", dependency, fault), func() error {
+		found := false
+		for _, faulted := range c.faulted {
+			found = found || faulted == dependency
+		}
+		if !found {
+			c.faulted = append(c.faulted, dependency)
+		}
+		if err := c.Provider.Inject(dependency, fault); err != nil {
+			return fmt.Errorf("Chaos: %s: %v", dependency, err)
+		}
+		return nil
+	})
+}
+
+// Restore is a Step that when executed removes every fault applied to
+// dependency.
+func (c *Chaos) Restore(dependency string) Step {
+	return NewNamedStep(fmt.Sprintf("RestoreDependency(%s)", dependency), func() error {
+		if err := c.Provider.Restore(dependency); err != nil {
+			return fmt.Errorf("Chaos: %s: %v", dependency, err)
+		}
+		for idx, faulted := range c.faulted {
+			if faulted == dependency {
+				c.faulted = append(c.faulted[:idx], c.faulted[idx+1:]...)
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// RestoreAll is a Step that when executed restores every dependency
+// faulted by Inject, in reverse order, continuing past failures (see
+// AllOf).
+func (c *Chaos) RestoreAll() Step {
+	return NewNamedStep("RestoreAllDependencies", func() error {
+		steps := []Step{}
+		for idx := len(c.faulted) - 1; idx >= 0; idx-- {
+			steps = append(steps, c.Restore(c.faulted[idx]))
+		}
+		return AllOf(steps...).Go()
+	})
+}
+
+// WithFault is a Step that when executed applies fault to dependency,
+// runs steps, and then, whether or not they succeed, restores
+// dependency. It errors if applying the fault, any of steps, or
+// restoring the dependency fails.
+func (c *Chaos) WithFault(dependency string, fault Fault, steps Steps) Step {
+	return NewNamedStep(fmt.Sprintf("WithFault(%s: %v)", dependency, fault), func() error {
+		err := c.Inject(dependency, fault).Go()
+		if err == nil {
+			err = steps.Go()
+		}
+		if restoreErr := c.Restore(dependency).Go(); err == nil {
+			err = restoreErr
+		} else if restoreErr != nil {
+			err = fmt.Errorf("%v\nRestoring %s: %v", err, dependency, restoreErr)
+		}
+		return err
+	})
+}
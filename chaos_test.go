@@ -0,0 +1,99 @@
+package argot
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeToxiproxy struct {
+	lock    sync.Mutex
+	enabled map[string]bool
+	toxics  map[string][]map[string]interface{}
+}
+
+func (tp *fakeToxiproxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tp.lock.Lock()
+	defer tp.lock.Unlock()
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/proxies/"), "/")
+	name := parts[0]
+	if _, found := tp.enabled[name]; !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		body := struct{ Enabled bool }{}
+		json.NewDecoder(r.Body).Decode(&body)
+		tp.enabled[name] = body.Enabled
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(tp.toxics[name])
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		toxic := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&toxic)
+		tp.toxics[name] = append(tp.toxics[name], toxic)
+	case len(parts) == 3 && r.Method == http.MethodDelete:
+		toxics := tp.toxics[name][:0]
+		for _, toxic := range tp.toxics[name] {
+			if toxic["name"] != parts[2] {
+				toxics = append(toxics, toxic)
+			}
+		}
+		tp.toxics[name] = toxics
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestChaos(t *testing.T) {
+	fake := &fakeToxiproxy{
+		enabled: map[string]bool{"db": true, "payments": true},
+		toxics:  map[string][]map[string]interface{}{"payments": {{"name": "mine", "type": "timeout"}}},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	chaos := NewChaos(NewToxiproxy(server.URL))
+	if err := chaos.Inject("db", Fault{Down: true}).Go(); err != nil {
+		t.Fatal(err)
+	} else if fake.enabled["db"] {
+		t.Fatal("Expected db to be disabled.")
+	}
+	if err := chaos.Inject("payments", Fault{Latency: 2 * time.Second, Jitter: 100 * time.Millisecond, BandwidthKBps: 10}).Go(); err != nil {
+		t.Fatal(err)
+	} else if len(fake.toxics["payments"]) != 3 {
+		t.Fatalf("Expected 3 toxics; found %v.", fake.toxics["payments"])
+	} else if attrs := fake.toxics["payments"][1]["attributes"].(map[string]interface{}); attrs["latency"] != 2000.0 || attrs["jitter"] != 100.0 {
+		t.Fatalf("Unexpected latency toxic: %v", fake.toxics["payments"][1])
+	}
+	if err := chaos.RestoreAll().Go(); err != nil {
+		t.Fatal(err)
+	} else if !fake.enabled["db"] {
+		t.Fatal("Expected db to be enabled.")
+	} else if len(fake.toxics["payments"]) != 1 || fake.toxics["payments"][0]["name"] != "mine" {
+		t.Fatalf("Expected only the toxic not added by argot; found %v.", fake.toxics["payments"])
+	} else if len(chaos.faulted) != 0 {
+		t.Fatalf("Expected no faulted dependencies; found %v.", chaos.faulted)
+	}
+
+	during := false
+	err := chaos.WithFault("db", Fault{Down: true}, Steps{NewNamedStep("Check", func() error {
+		during = !fake.enabled["db"]
+		return errors.New("fallback not used")
+	})}).Go()
+	if err == nil || !strings.Contains(err.Error(), "fallback not used") {
+		t.Fatalf("Expected the step's error; found %v", err)
+	} else if !during || !fake.enabled["db"] {
+		t.Fatal("Expected db to be down during the steps, and restored afterwards.")
+	}
+
+	if err := chaos.Inject("cache", Fault{Down: true}).Go(); err == nil || !strings.Contains(err.Error(), "Chaos: cache: ") {
+		t.Fatalf("Expected an error for an unknown proxy; found %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// DependencyDownError is the error of a request which was not sent
+// because the circuit of its host is open (see CircuitBreaker). It is
+// classified by ClassifyTransportError as DependencyDown.
+type DependencyDownError struct {
+	// The host (and port, if any) of the request.
+	Host string
+	// The number of consecutive transport failures which opened the
+	// circuit.
+	Failures int
+	// The transport error of the last of them.
+	Err error
+}
+
+func (e *DependencyDownError) Error() string {
+	return fmt.Sprintf("Dependency down: %s failed %d consecutive times; not sent (last error: %v)", e.Host, e.Failures, e.Err)
+}
+
+// Unwrap returns the transport error which opened the circuit.
+func (e *DependencyDownError) Unwrap() error {
+	return e.Err
+}
+
+// circuit is the state of the circuit of a single host.
+type circuit struct {
+	failures int
+	err      error
+	open     bool
+}
+
+// CircuitBreaker stops requests being sent to a host once Threshold
+// consecutive requests to it have failed with transport errors (see
+// TransportError), so that when a dependency, or a whole environment,
+// is down the remaining scenarios fail fast, with a DependencyDown
+// transport error, rather than each waiting for its requests to time
+// out. Any response, whatever its status, resets the count of its
+// host. Once open, the circuit of a host stays open until Reset. Share
+// one CircuitBreaker between the HttpCalls of the suite, adding it to
+// each with UseCircuitBreaker. A CircuitBreaker is safe for concurrent
+// use.
+type CircuitBreaker struct {
+	// The number of consecutive transport failures after which the
+	// circuit of a host opens.
+	Threshold int
+
+	lock  sync.Mutex
+	hosts map[string]*circuit
+}
+
+// NewCircuitBreaker creates a new CircuitBreaker which opens the
+// circuit of a host after threshold consecutive transport failures.
+func NewCircuitBreaker(threshold int) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold}
+}
+
+// UseCircuitBreaker adds breaker to the middleware of hc (see Use), so
+// that its requests are not sent to hosts which are down.
+func (hc *HttpCall) UseCircuitBreaker(breaker *CircuitBreaker) {
+	hc.Use(breaker.Middleware)
+}
+
+// Middleware is the Middleware (see Use) which fails requests to hosts
+// whose circuits are open, and counts the transport failures of the
+// others.
+func (cb *CircuitBreaker) Middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		host := req.URL.Host
+		cb.lock.Lock()
+		c, found := cb.hosts[host]
+		if !found {
+			c = &circuit{}
+			if cb.hosts == nil {
+				cb.hosts = make(map[string]*circuit)
+			}
+			cb.hosts[host] = c
+		}
+		if c.open {
+			err := &DependencyDownError{Host: host, Failures: c.failures, Err: c.err}
+			cb.lock.Unlock()
+			return nil, err
+		}
+		cb.lock.Unlock()
+
+		response, err := next.RoundTrip(req)
+		cb.lock.Lock()
+		defer cb.lock.Unlock()
+		if err == nil {
+			c.failures = 0
+			c.err = nil
+		} else if !c.open {
+			c.failures++
+			c.err = err
+			c.open = cb.Threshold > 0 && c.failures >= cb.Threshold
+		}
+		return response, err
+	})
+}
+
+// Open returns the hosts whose circuits are open, sorted.
+func (cb *CircuitBreaker) Open() []string {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	hosts := []string{}
+	for host, c := range cb.hosts {
+		if c.open {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// Reset closes every circuit, and forgets the failures counted.
+func (cb *CircuitBreaker) Reset() {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	cb.hosts = nil
+}
@@ -0,0 +1,83 @@
+package argot
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer other.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	breaker := NewCircuitBreaker(2)
+	hc := NewHttpCall(&http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if failing && req.URL.Host == host {
+			return nil, errors.New("unreachable")
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})})
+	defer hc.Reset()
+	hc.UseCircuitBreaker(breaker)
+
+	// Failures below the threshold are sent, and any response, even an
+	// error status, resets the count.
+	for idx := 0; idx < 3; idx++ {
+		failing = true
+		Steps{hc.NewRequest("GET", server.URL, nil), hc.ExpectTransportError(TransportErrorOther)}.Test(t)
+		failing = false
+		Steps{hc.NewRequest("GET", server.URL, nil), hc.ResponseStatusEquals(http.StatusServiceUnavailable)}.Test(t)
+	}
+	if open := breaker.Open(); len(open) != 0 {
+		t.Fatalf("Expected no open circuits; found %v", open)
+	}
+
+	// Once open, requests to the host fail fast, even once it is back,
+	// and other hosts are unaffected.
+	failing = true
+	Steps{
+		hc.NewRequest("GET", server.URL, nil), hc.ExpectTransportError(TransportErrorOther),
+		hc.NewRequest("GET", server.URL, nil), hc.ExpectTransportError(TransportErrorOther),
+		hc.NewRequest("GET", server.URL+"/other", nil), hc.ExpectTransportError(DependencyDown),
+		hc.NewRequest("GET", other.URL, nil), hc.ResponseStatusEquals(http.StatusOK),
+	}.Test(t)
+	if open := breaker.Open(); len(open) != 1 || open[0] != host {
+		t.Fatalf("Expected %s to be open; found %v", host, open)
+	}
+	failing = false
+	err := Steps{hc.NewRequest("GET", server.URL, nil), hc.ResponseStatusEquals(http.StatusServiceUnavailable)}.Go()
+	var downErr *DependencyDownError
+	if !errors.As(err, &downErr) || downErr.Host != host || downErr.Failures != 2 || ClassifyTransportError(err) != DependencyDown {
+		t.Fatalf("Expected a DependencyDownError; found %v", err)
+	} else if !strings.Contains(err.Error(), "Dependency down: "+host+" failed 2 consecutive times; not sent (last error: unreachable)") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	breaker.Reset()
+	Steps{hc.NewRequest("GET", server.URL, nil), hc.ResponseStatusEquals(http.StatusServiceUnavailable)}.Test(t)
+}
+
+func TestCircuitBreakerLiteral(t *testing.T) {
+	breaker := &CircuitBreaker{Threshold: 1}
+	hc := NewHttpCall(&http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("unreachable")
+	})})
+	defer hc.Reset()
+	hc.UseCircuitBreaker(breaker)
+
+	Steps{
+		hc.NewRequest("GET", "http://example.invalid", nil), hc.ExpectTransportError(TransportErrorOther),
+		hc.NewRequest("GET", "http://example.invalid", nil), hc.ExpectTransportError(DependencyDown),
+	}.Test(t)
+	if open := breaker.Open(); len(open) != 1 || open[0] != "example.invalid" {
+		t.Fatalf("Expected example.invalid to be open; found %v", open)
+	}
+}
@@ -0,0 +1,88 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Clock is a fake clock of the system under test, which scenarios
+// advance (see AdvanceClock) to test expiry and scheduling behaviour
+// deterministically, without waiting.
+type Clock interface {
+	// Advance moves the clock forwards by d.
+	Advance(d time.Duration) error
+}
+
+// ClockFunc is a Clock which calls itself to advance, for example the
+// fake clock of a system under test running in the same process.
+type ClockFunc func(d time.Duration) error
+
+// Advance calls f(d).
+func (f ClockFunc) Advance(d time.Duration) error {
+	return f(d)
+}
+
+// HTTPClock is a Clock advanced through an HTTP admin endpoint of the
+// system under test: each advance is a POST to URL of the JSON object
+// {"advance": "24h0m0s", "seconds": 86400}, giving the duration both
+// in the form of time.ParseDuration and in seconds, and succeeds if the
+// status is 2xx.
+type HTTPClock struct {
+	URL string
+	// The client with which to POST. If nil, http.DefaultClient is
+	// used.
+	Client *http.Client
+	// Sent with every request, for example to authenticate.
+	Header http.Header
+}
+
+// NewHTTPClock creates a new HTTPClock which POSTs to url.
+func NewHTTPClock(url string) *HTTPClock {
+	return &HTTPClock{URL: url}
+}
+
+// Advance POSTs the duration d to c.URL.
+func (c *HTTPClock) Advance(d time.Duration) error {
+	bites, err := json.Marshal(map[string]interface{}{"advance": d.String(), "seconds": d.Seconds()})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewReader(bites))
+	if err != nil {
+		return err
+	}
+	for key, values := range c.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("Status: Expected 2xx; found %d: '%s'.", response.StatusCode, string(body))
+	}
+	return nil
+}
+
+// AdvanceClock is a Step that when executed advances clock by d, for
+// example between a call which issues a token and one which should
+// find it expired.
+func AdvanceClock(clock Clock, d time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("AdvanceClock(%v)", d), func() error {
+		if err := clock.Advance(d); err != nil {
+			return fmt.Errorf("Clock: Advancing by %v: %v", d, err)
+		}
+		return nil
+	})
+}
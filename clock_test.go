@@ -0,0 +1,57 @@
+package argot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdvanceClock(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/admin/clock" {
+			var body struct {
+				Advance string  `json:"advance"`
+				Seconds float64 `json:"seconds"`
+			}
+			if r.Header.Get("X-Admin-Key") != "key" {
+				w.WriteHeader(http.StatusForbidden)
+			} else if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+			} else if d, err := time.ParseDuration(body.Advance); err != nil || d.Seconds() != body.Seconds {
+				w.WriteHeader(http.StatusBadRequest)
+			} else {
+				now = now.Add(d)
+			}
+			return
+		}
+		w.Write([]byte(now.Format(time.RFC3339)))
+	}))
+	defer server.Close()
+
+	clock := NewHTTPClock(server.URL + "/admin/clock")
+	clock.Header = http.Header{"X-Admin-Key": {"key"}}
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	advanced := time.Duration(0)
+	Steps{
+		AdvanceClock(clock, 36*time.Hour),
+		hc.NewRequest("GET", server.URL+"/now", nil),
+		hc.ResponseBodyEquals("2030-01-02T12:00:00Z"),
+		AdvanceClock(ClockFunc(func(d time.Duration) error {
+			advanced += d
+			return nil
+		}), time.Minute),
+	}.Test(t)
+	if advanced != time.Minute {
+		t.Fatalf("Expected the ClockFunc to be advanced; found %v.", advanced)
+	}
+
+	clock.Header = nil
+	if err := AdvanceClock(clock, time.Hour).Go(); err == nil || !strings.HasPrefix(err.Error(), "Clock: Advancing by 1h0m0s: Status: Expected 2xx; found 403") {
+		t.Fatalf("Expected the advance to be refused; found %v.", err)
+	}
+}
@@ -0,0 +1,85 @@
+package argot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Clone returns a new HttpCall independent of hc, so that a template
+// call can be forked, for example into A/B variants. The new HttpCall
+// has a copy of hc.Client (sharing its Transport, and so its
+// connections), and the same settings, middleware and BeforeSend
+// hooks, all of which may then be changed without affecting hc. If hc
+// has a Request, the new HttpCall has a deep copy of it, with a body
+// that can be re-read, and hc.Request's body is made re-readable too
+// (see RequestBodyBytes); this fails if hc.Request has already been
+// sent with a body which cannot be re-read. The response, if any, is
+// not copied.
+func (hc *HttpCall) Clone() (*HttpCall, error) {
+	clone := new(HttpCall)
+	if err := hc.cloneInto(clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// CloneTo is a Step that when executed resets dst and makes it a copy
+// of hc, as for Clone. Because steps are built before they run, this
+// allows a request built by earlier steps to be forked within a
+// scenario.
+func (hc *HttpCall) CloneTo(dst *HttpCall) Step {
+	return hc.step("CloneTo", func() error {
+		if dst == hc {
+			return errors.New("Clone: Cannot clone an HttpCall to itself.")
+		} else if err := dst.Reset(); err != nil {
+			return err
+		} else {
+			return hc.cloneInto(dst)
+		}
+	})
+}
+
+func (hc *HttpCall) cloneInto(dst *HttpCall) error {
+	client := *hc.Client
+	*dst = HttpCall{
+		Client:                    &client,
+		Snapshot:                  hc.Snapshot,
+		Spec:                      hc.Spec,
+		Pact:                      hc.Pact,
+		DumpOnFailure:             hc.DumpOnFailure,
+		Coverage:                  hc.Coverage,
+		JSONNormalisers:           append([]JSONNormaliser(nil), hc.JSONNormalisers...),
+		JSONExactNumbers:          hc.JSONExactNumbers,
+		JSONDisallowUnknownFields: hc.JSONDisallowUnknownFields,
+		Redactor:                  hc.Redactor,
+		Secrets:                   hc.Secrets,
+		SpoolThreshold:            hc.SpoolThreshold,
+		MaxBodySize:               hc.MaxBodySize,
+		Screenshot:                hc.Screenshot,
+		ErrorEnvelope:             hc.ErrorEnvelope,
+		middleware:                append([]Middleware(nil), hc.middleware...),
+		beforeSend:                append([]func(*http.Request) error(nil), hc.beforeSend...),
+		warmup:                    hc.warmup,
+	}
+	if hc.Request == nil {
+		return nil
+	} else if hc.Response != nil && hc.Request.Body != nil && hc.Request.Body != http.NoBody && hc.Request.GetBody == nil {
+		return errors.New("Clone: Request body cannot be copied: it has been sent and cannot be re-read.")
+	}
+	bites, err := RequestBodyBytes(hc.Request)
+	if err != nil {
+		return fmt.Errorf("Clone: Request body cannot be copied: %v", err)
+	}
+	req := hc.Request.Clone(hc.Request.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		req.GetBody = func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(bites)), nil }
+		req.Body, _ = req.GetBody()
+	}
+	dst.Request = req
+	dst.requestName = hc.requestName
+	return nil
+}
@@ -0,0 +1,70 @@
+package argot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Header.Get("X-Variant") + ":" + string(body)))
+	}))
+	defer server.Close()
+
+	template := NewHttpCall(&http.Client{Timeout: time.Minute})
+	defer template.Reset()
+	template.DumpOnFailure = true
+	template.JSONExactNumbers = true
+	template.JSONDisallowUnknownFields = true
+	a, b := NewHttpCall(nil), NewHttpCall(nil)
+	defer a.Reset()
+	defer b.Reset()
+	Steps{
+		template.NewRequest("POST", server.URL, ioutil.NopCloser(strings.NewReader("payload"))),
+		template.RequestHeader("X-Variant", "template"),
+		template.CloneTo(a),
+		template.CloneTo(b),
+		a.RequestHeader("X-Variant", "a"),
+		b.RequestHeader("X-Variant", "b"),
+		a.ResponseBodyEquals("a:payload"),
+		b.ResponseBodyEquals("b:payload"),
+		template.ResponseBodyEquals("template:payload"),
+		ExpectError(template.CloneTo(template)),
+	}.Test(t)
+	if !a.DumpOnFailure || a.Client == template.Client || a.Client.Timeout != time.Minute {
+		t.Fatal("Expected the clone to have a copy of the client and settings.")
+	}
+	if !a.JSONExactNumbers {
+		t.Fatal("Expected the clone to have JSONExactNumbers.")
+	}
+	if !a.JSONDisallowUnknownFields {
+		t.Fatal("Expected the clone to have JSONDisallowUnknownFields.")
+	}
+
+	// The template has been sent, but its body was made re-readable.
+	clone, err := template.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clone.Reset()
+	clone.Client.Timeout = time.Second
+	Steps{
+		clone.ResponseBodyEquals("template:payload"),
+	}.Test(t)
+	if template.Client.Timeout != time.Minute {
+		t.Fatal("Expected changing the clone's client not to affect the original.")
+	}
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("POST", server.URL, StreamingBody(ioutil.NopCloser(strings.NewReader("once")))),
+		hc.ResponseBodyEquals(":once"),
+		ExpectError(hc.CloneTo(a)),
+	}.Test(t)
+}
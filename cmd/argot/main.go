@@ -0,0 +1,565 @@
+// Command argot runs declarative argot scenario files (see
+// argot.Scenario) against a target, for example as post-deploy smoke
+// tests outside of go test.
+//
+// Usage:
+//
+//	argot [flags] path...
+//
+// Each path is a YAML or JSON scenario file, or a directory whose
+// .yaml, .yml and .json files are run in name order. Scenarios may
+// refer to the target with ${baseURL} (URLs beginning with "/" are
+// relative to it), to environment variables with ${env.NAME}, and to
+// values given with -var.
+//
+// With -environments, the target is instead described by the
+// environment (see argot.Environment) selected with -env or
+// $ARGOT_ENV: its base URL, services, credentials and vars are
+// available as ${baseURL}, ${service.NAME}, ${credential.NAME} and
+// ${NAME}, and its headers, TLS settings and read only restriction
+// apply to every request. -base-url, if given, overrides its base URL.
+//
+// Randomised steps, such as retry jitter, draw from argot's random
+// number generator, whose seed is printed should any scenario fail; to
+// reproduce such a run, pass the seed with -seed (or $ARGOT_SEED).
+//
+// Scenarios run in order of priority (see argot.Priority), P0 first,
+// then P1 and so on, and lastly those with no priority. With -priority,
+// only the scenarios of the given priority or higher are run, for
+// example -priority P0 runs just the smoke scenarios, as for a
+// deployment gate. With -fail-fast-priority, once a scenario of the
+// given priority or higher has failed, the scenarios of lower priority
+// are skipped.
+//
+// With -last-run, the outcome of each scenario is recorded in the given
+// file, merged with those of earlier runs, and with -failed only the
+// scenarios which failed when last run are run again (or, if none did,
+// every scenario), for a fast iterate-on-failure loop.
+//
+// With -quarantine, the scenarios listed in the given file (see
+// argot.Quarantine), by name or pattern, are known to be flaky: they
+// are run, but their failures are reported as quarantined rather than
+// failing the run. The file records how many consecutive runs each has
+// passed, and those which have started passing consistently are
+// reported, so that they can be released from quarantine.
+//
+// With -baseline, the timings of each step and HTTP call are compared
+// with those stored in the given file by earlier runs (see
+// argot.TimingBaseline), and the run fails if any has regressed by
+// more than -regression-threshold percent. With -rebaseline (or
+// $ARGOT_REBASELINE), the file is instead updated with the timings of
+// this run.
+//
+// With -circuit-breaker, once that many consecutive requests to a host
+// have failed to get a response, no further requests are sent to it
+// (see argot.CircuitBreaker): the remaining scenarios fail fast, as
+// "dependency down", rather than each waiting for its requests to time
+// out, and the hosts found to be down are listed.
+//
+// With -deprecations, every response is checked for Deprecation,
+// Sunset and Warning headers (see argot.DeprecationWatchdog): with
+// "report", the deprecations found are listed after the summary, and
+// with "fail", they also fail the steps which received them.
+//
+// With -diagnostics, should a scenario fail, the responses of the
+// target's /health and /version endpoints are captured (see
+// argot.AddTargetDiagnostics) and, with -artifacts, written alongside
+// the failed step's other artifacts.
+//
+// With -generate, no scenarios are run; instead skeleton scenarios are
+// generated from the given OpenAPI document (see
+// argot.OpenAPISpec.Scenarios), one file per operation, into the
+// directory given as the path, or, if the path ends in .go, as Go
+// source of the package named with -package. Given a HAR document
+// (a path ending in .har), such as one exported from a browser or a
+// recording proxy, -generate instead writes Go source of steps
+// replaying its requests (see argot.HAR.GoSource) to the .go path.
+//
+// With -record, no scenarios are run; instead a capture proxy (see
+// argot.CaptureProxy) listens on the given address until interrupted,
+// and the requests made through it, by a browser or other client
+// configured to use it, are written to the path: as Go source of
+// equivalent steps, ready to commit as a regression test, if the path
+// ends in .go, and otherwise as a HAR document.
+//
+// The exit code is 0 if every scenario passed, 1 if any failed, and 2
+// if the scenarios could not be loaded.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/msackman/argot"
+)
+
+type varsFlag map[string]string
+
+func (vf varsFlag) String() string {
+	return fmt.Sprint(map[string]string(vf))
+}
+
+func (vf varsFlag) Set(value string) error {
+	idx := strings.IndexByte(value, '=')
+	if idx <= 0 {
+		return fmt.Errorf("expected key=value; found %q", value)
+	}
+	vf[value[:idx]] = value[idx+1:]
+	return nil
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("argot", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	baseURL := flags.String("base-url", "", "the base URL of the target, available as ${baseURL}")
+	environments := flags.String("environments", "", "load environments from this file")
+	environment := flags.String("env", "", "the environment to run against (default $"+argot.EnvironmentEnv+")")
+	timeout := flags.Duration("timeout", 30*time.Second, "the timeout of each HTTP request")
+	jsonReport := flags.String("json", "", "write a JSON report to this file")
+	junitReport := flags.String("junit", "", "write a JUnit XML report to this file")
+	junitSteps := flags.Bool("junit-steps", false, "in the JUnit XML report, write a testcase per step rather than per scenario")
+	markdownReport := flags.String("markdown", "", "write a Markdown summary to this file")
+	artifacts := flags.String("artifacts", "", "write the artifacts attached by steps beneath this directory")
+	suite := flags.String("suite", "argot", "the name of the suite in reports")
+	lastRunPath := flags.String("last-run", "", "record the outcome of each scenario in this file, merged with earlier runs")
+	onlyFailed := flags.Bool("failed", false, "only run the scenarios which failed when last run (requires -last-run)")
+	quarantinePath := flags.String("quarantine", "", "tolerate the failures of the scenarios quarantined in this file, and record their passes in it")
+	baselinePath := flags.String("baseline", "", "compare the timings of steps and calls with the baseline in this file")
+	rebaseline := flags.Bool("rebaseline", false, "update the baseline with the timings of this run, rather than compare them (requires -baseline)")
+	threshold := flags.Float64("regression-threshold", 20, "the percentage by which timings may exceed the baseline")
+	breakAfter := flags.Int("circuit-breaker", 0, "stop sending requests to a host after this many consecutive transport failures (0 never stops)")
+	priorityFlag := flags.String("priority", "", "only run the scenarios of this priority or higher, such as P0 for the smoke subset")
+	failFastFlag := flags.String("fail-fast-priority", "", "skip the scenarios of lower priority once a scenario of this priority or higher has failed")
+	deprecations := flags.String("deprecations", "", "check responses for Deprecation, Sunset and Warning headers, and \"report\" them after the summary, or \"fail\" the steps receiving them too")
+	diagnose := flags.Bool("diagnostics", false, "on failure, capture the responses of the target's /health and /version endpoints as artifacts")
+	generate := flags.String("generate", "", "generate skeleton scenarios from this OpenAPI document into the path given, rather than run scenarios")
+	record := flags.String("record", "", "record the requests made through a capture proxy listening on this address, until interrupted, into the path given, rather than run scenarios")
+	pkg := flags.String("package", "scenarios", "the package of Go source generated with -generate or -record")
+	seed := flags.Int64("seed", 0, "seed the random number generator, to reproduce a run (default $"+argot.SeedEnv+", or chosen from the time)")
+	vars := varsFlag{}
+	flags.Var(vars, "var", "set a variable, as key=value (repeatable)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	} else if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "argot: no scenario files given")
+		flags.Usage()
+		return 2
+	}
+	if *generate != "" {
+		if flags.NArg() != 1 {
+			fmt.Fprintln(stderr, "argot: -generate requires a single path")
+			return 2
+		} else if err := generateScenarios(*generate, flags.Arg(0), *pkg); err != nil {
+			fmt.Fprintf(stderr, "argot: %v\n", err)
+			return 2
+		}
+		return 0
+	}
+	if *record != "" {
+		if flags.NArg() != 1 {
+			fmt.Fprintln(stderr, "argot: -record requires a single path")
+			return 2
+		}
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(interrupt)
+		if err := recordRequests(*record, flags.Arg(0), *pkg, stderr, interrupt); err != nil {
+			fmt.Fprintf(stderr, "argot: %v\n", err)
+			return 2
+		}
+		return 0
+	}
+	if *onlyFailed && *lastRunPath == "" {
+		fmt.Fprintln(stderr, "argot: -failed given without -last-run")
+		return 2
+	}
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			argot.SetSeed(*seed)
+		}
+	})
+
+	priority, err := argot.ParsePriority(*priorityFlag)
+	if err != nil {
+		fmt.Fprintf(stderr, "argot: -priority: %v\n", err)
+		return 2
+	}
+	failFast, err := argot.ParsePriority(*failFastFlag)
+	if err != nil {
+		fmt.Fprintf(stderr, "argot: -fail-fast-priority: %v\n", err)
+		return 2
+	}
+	scenarios, err := loadScenarios(flags.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "argot: %v\n", err)
+		return 2
+	}
+	if priority == argot.NoPriority {
+		scenarios = argot.Scenarios(scenarios).ByPriority()
+	} else {
+		scenarios = argot.Scenarios(scenarios).UpTo(priority)
+		fmt.Fprintf(stdout, "Running the %d scenarios of priority %v or higher\n", len(scenarios), priority)
+	}
+	var lastRun *argot.LastRun
+	if *lastRunPath != "" {
+		if lastRun, err = argot.LoadLastRun(*lastRunPath); err != nil {
+			fmt.Fprintf(stderr, "argot: %v\n", err)
+			return 2
+		}
+	}
+	if *onlyFailed {
+		rerun := make([]*argot.Scenario, 0, len(scenarios))
+		for _, scenario := range scenarios {
+			if lastRun.ShouldRun(scenario.Name) {
+				rerun = append(rerun, scenario)
+			}
+		}
+		if len(rerun) < len(scenarios) {
+			fmt.Fprintf(stdout, "Running the %d of %d scenarios which failed when last run\n", len(rerun), len(scenarios))
+		}
+		scenarios = rerun
+	}
+	var quarantine *argot.Quarantine
+	if *quarantinePath != "" {
+		if quarantine, err = argot.LoadQuarantine(*quarantinePath); err != nil {
+			fmt.Fprintf(stderr, "argot: %v\n", err)
+			return 2
+		}
+	}
+	var baseline *argot.TimingBaseline
+	if *baselinePath != "" {
+		if baseline, err = argot.LoadTimingBaseline(*baselinePath); err != nil {
+			fmt.Fprintf(stderr, "argot: %v\n", err)
+			return 2
+		}
+		baseline.Threshold = *threshold
+		baseline.Rebaseline = baseline.Rebaseline || *rebaseline
+		defer baseline.ObserveCalls()()
+	} else if *rebaseline {
+		fmt.Fprintln(stderr, "argot: -rebaseline given without -baseline")
+		return 2
+	}
+	var env *argot.Environment
+	if *environments != "" {
+		if envs, err := argot.LoadEnvironments(*environments); err != nil {
+			fmt.Fprintf(stderr, "argot: %v\n", err)
+			return 2
+		} else if env, err = envs.Select(*environment); err != nil {
+			fmt.Fprintf(stderr, "argot: %v\n", err)
+			return 2
+		}
+	} else if *environment != "" {
+		fmt.Fprintln(stderr, "argot: -env given without -environments")
+		return 2
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	if env != nil {
+		if client, err = env.Client(*timeout); err != nil {
+			fmt.Fprintf(stderr, "argot: %v\n", err)
+			return 2
+		}
+	}
+
+	if *diagnose {
+		target := *baseURL
+		if target == "" && env != nil {
+			target = env.BaseURL
+		}
+		defer argot.AddTargetDiagnostics(client, target)()
+	}
+
+	var watchdog *argot.DeprecationWatchdog
+	if *deprecations == "report" || *deprecations == "fail" {
+		watchdog = argot.NewDeprecationWatchdog(*deprecations == "fail")
+	} else if *deprecations != "" {
+		fmt.Fprintf(stderr, "argot: -deprecations: expected report or fail; found %q\n", *deprecations)
+		return 2
+	}
+
+	var breaker *argot.CircuitBreaker
+	if *breakAfter > 0 {
+		breaker = argot.NewCircuitBreaker(*breakAfter)
+	}
+
+	results := make([]*argot.ScenarioResult, 0, len(scenarios))
+	failed, skipped, quarantined := 0, 0, 0
+	var failedPriority argot.Priority
+	for _, scenario := range scenarios {
+		if failedPriority != argot.NoPriority && failedPriority.Before(scenario.Priority) {
+			skipped++
+			fmt.Fprintf(stdout, "SKIP %s (a %v scenario failed)\n", scenario.Name, failedPriority)
+			continue
+		}
+		var store *argot.Store
+		if env != nil {
+			store = env.Store()
+			if *baseURL != "" {
+				store.Set("baseURL", *baseURL)
+			}
+		} else {
+			store = argot.NewStore()
+			for _, env := range os.Environ() {
+				if idx := strings.IndexByte(env, '='); idx > 0 {
+					store.Set("env."+env[:idx], env[idx+1:])
+				}
+			}
+			store.Set("baseURL", *baseURL)
+		}
+		for key, value := range vars {
+			store.Set(key, value)
+		}
+		hc := argot.NewHttpCall(client)
+		hc.DumpOnFailure = *artifacts != ""
+		if breaker != nil {
+			hc.UseCircuitBreaker(breaker)
+		}
+		if watchdog != nil {
+			hc.ResponseInvariants(hc.ResponseNotDeprecated(watchdog))
+		}
+		result := argot.RunScenario(scenario.Name, scenario.Build(hc, store))
+		hc.Reset()
+		results = append(results, result)
+
+		if result.Passed() {
+			fmt.Fprintf(stdout, "PASS %s (%v)\n", result.Name, result.Duration.Round(time.Millisecond))
+			for _, step := range result.FlakySteps() {
+				fmt.Fprintf(stdout, "     Flaky Step: %s (%d attempts)\n", step.Name, step.Attempts)
+			}
+		} else {
+			status := ""
+			if quarantine != nil && quarantine.Quarantined(result.Name) {
+				quarantined++
+				status = ", quarantined"
+			} else {
+				failed++
+				if failFast != argot.NoPriority && scenario.Priority != argot.NoPriority && !failFast.Before(scenario.Priority) && failedPriority == argot.NoPriority {
+					failedPriority = scenario.Priority
+				}
+			}
+			fmt.Fprintf(stdout, "FAIL %s (%v%s)\n", result.Name, result.Duration.Round(time.Millisecond), status)
+			fmt.Fprintf(stdout, "     Failed Step: %s\n", result.FailedStep().Name)
+			fmt.Fprintf(stdout, "     Error: %s\n", strings.Replace(argot.DefaultRedactor.String(result.Err.Error()), "\n", "\n     ", -1))
+		}
+	}
+	summary := fmt.Sprintf("%d passed, %d failed", len(results)-failed-quarantined, failed)
+	if quarantined > 0 {
+		summary += fmt.Sprintf(", %d quarantined", quarantined)
+	}
+	if skipped > 0 {
+		summary += fmt.Sprintf(", %d skipped", skipped)
+	}
+	fmt.Fprintln(stdout, summary)
+	if watchdog != nil {
+		fmt.Fprint(stdout, watchdog.Summary())
+	}
+	if breaker != nil {
+		for _, host := range breaker.Open() {
+			fmt.Fprintf(stdout, "Dependency down: %s\n", host)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(stdout, "Seed: %d (rerun with -seed to reproduce)\n", argot.Seed())
+	}
+
+	regressed := false
+	if baseline != nil {
+		baseline.RecordResults(results...)
+		if err := baseline.Check(); err != nil {
+			regressed = true
+			fmt.Fprintf(stdout, "FAIL %s\n", strings.Replace(err.Error(), "\n", "\n     ", -1))
+		} else if baseline.Rebaseline {
+			if err := baseline.Save(*baselinePath); err != nil {
+				fmt.Fprintf(stderr, "argot: %v\n", err)
+				return 2
+			}
+			fmt.Fprintf(stdout, "Rebaselined timings in %s\n", *baselinePath)
+		}
+	}
+	if quarantine != nil {
+		quarantine.Record(results...)
+		for _, name := range quarantine.Stable() {
+			fmt.Fprintf(stdout, "Quarantined scenario %s has passed %d consecutive runs; consider releasing it\n", name, quarantine.Passes(name))
+		}
+		if err := quarantine.Save(*quarantinePath); err != nil {
+			fmt.Fprintf(stderr, "argot: %v\n", err)
+			return 2
+		}
+	}
+	if lastRun != nil {
+		lastRun.Record(results...)
+		if err := lastRun.Save(*lastRunPath); err != nil {
+			fmt.Fprintf(stderr, "argot: %v\n", err)
+			return 2
+		}
+	}
+	if *artifacts != "" {
+		if err := argot.WriteArtifacts(*artifacts, results); err != nil {
+			fmt.Fprintf(stderr, "argot: %v\n", err)
+			return 2
+		}
+	}
+	if *jsonReport != "" {
+		if err := writeReport(*jsonReport, func(w io.Writer) error { return argot.WriteJSONReport(w, results) }); err != nil {
+			fmt.Fprintf(stderr, "argot: %v\n", err)
+			return 2
+		}
+	}
+	if *junitReport != "" {
+		write := argot.WriteJUnitReport
+		if *junitSteps {
+			write = argot.WriteJUnitStepReport
+		}
+		if err := writeReport(*junitReport, func(w io.Writer) error { return write(w, *suite, results) }); err != nil {
+			fmt.Fprintf(stderr, "argot: %v\n", err)
+			return 2
+		}
+	}
+	if *markdownReport != "" {
+		if err := writeReport(*markdownReport, func(w io.Writer) error { return argot.WriteMarkdownReport(w, *suite, results) }); err != nil {
+			fmt.Fprintf(stderr, "argot: %v\n", err)
+			return 2
+		}
+	}
+	if failed > 0 || regressed {
+		return 1
+	}
+	return 0
+}
+
+func loadScenarios(paths []string) ([]*argot.Scenario, error) {
+	files := []string{}
+	for _, path := range paths {
+		if info, err := os.Stat(path); err != nil {
+			return nil, err
+		} else if !info.IsDir() {
+			files = append(files, path)
+		} else if entries, err := ioutil.ReadDir(path); err != nil {
+			return nil, err
+		} else {
+			names := []string{}
+			for _, entry := range entries {
+				switch filepath.Ext(entry.Name()) {
+				case ".yaml", ".yml", ".json":
+					if !entry.IsDir() {
+						names = append(names, filepath.Join(path, entry.Name()))
+					}
+				}
+			}
+			sort.Strings(names)
+			files = append(files, names...)
+		}
+	}
+	scenarios := make([]*argot.Scenario, 0, len(files))
+	for _, file := range files {
+		if scenario, err := argot.LoadScenario(file); err != nil {
+			return nil, err
+		} else {
+			scenarios = append(scenarios, scenario)
+		}
+	}
+	return scenarios, nil
+}
+
+// generateScenarios generates skeleton scenarios from the OpenAPI
+// document at specPath into the directory path, or the Go source file
+// path. If specPath is instead a HAR document, Go source of its
+// requests is generated.
+func generateScenarios(specPath, path, pkg string) error {
+	if filepath.Ext(specPath) == ".har" {
+		if filepath.Ext(path) != ".go" {
+			return fmt.Errorf("%s: Expected a .go path to generate from a HAR document.", path)
+		} else if har, err := argot.LoadHAR(specPath); err != nil {
+			return err
+		} else {
+			return writeGoSource(har, path, pkg)
+		}
+	}
+	spec, err := argot.LoadOpenAPISpec(specPath)
+	if err != nil {
+		return err
+	}
+	if filepath.Ext(path) == ".go" {
+		if source, err := spec.GoSource(pkg); err != nil {
+			return err
+		} else {
+			return ioutil.WriteFile(path, source, 0644)
+		}
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	used := make(map[string]bool)
+	for _, scenario := range spec.Scenarios() {
+		name := strings.Trim(unsafeFileChars.ReplaceAllString(scenario.Name, "-"), "-")
+		for idx := 2; used[name]; idx++ {
+			name = fmt.Sprintf("%s-%d", strings.Trim(unsafeFileChars.ReplaceAllString(scenario.Name, "-"), "-"), idx)
+		}
+		used[name] = true
+		if err := scenario.Save(filepath.Join(path, name+".yaml")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordRequests serves a capture proxy on addr until stop receives,
+// and then writes the requests made through it to path.
+func recordRequests(addr, path, pkg string, stderr io.Writer, stop <-chan os.Signal) error {
+	proxy := argot.NewCaptureProxy()
+	proxyURL, err := proxy.Start(addr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "argot: recording requests made through the proxy %s; interrupt to stop\n", proxyURL)
+	<-stop
+	if err := proxy.Close(); err != nil {
+		return err
+	}
+	har := proxy.HAR()
+	fmt.Fprintf(stderr, "argot: recorded %d requests into %s\n", len(har.Log.Entries), path)
+	if filepath.Ext(path) == ".go" {
+		return writeGoSource(har, path, pkg)
+	} else {
+		return proxy.SaveHAR(path)
+	}
+}
+
+// writeGoSource writes Go source of the requests of har to path, in a
+// function named after the file.
+func writeGoSource(har *argot.HAR, path, pkg string) error {
+	if source, err := har.GoSource(pkg, strings.TrimSuffix(filepath.Base(path), ".go"), true); err != nil {
+		return err
+	} else {
+		return ioutil.WriteFile(path, source, 0644)
+	}
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.]+`)
+
+func writeReport(path string, write func(io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
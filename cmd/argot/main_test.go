@@ -0,0 +1,472 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" && r.Header.Get("X-Token") == "secret" {
+			w.Write([]byte("ok"))
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "argot-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "a.yaml"), []byte(`
+name: health
+steps:
+  - request: {method: GET, url: /health, headers: {X-Token: "${env.ARGOT_TEST_TOKEN}"}}
+    expect: {status: 200, body: ok}
+`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"name": "missing", "steps": [
+  {"request": {"method": "GET", "url": "${baseURL}/${path}"}, "expect": {"status": 200}}]}`), 0644)
+	os.Setenv("ARGOT_TEST_TOKEN", "secret")
+	defer os.Unsetenv("ARGOT_TEST_TOKEN")
+
+	junit := filepath.Join(dir, "junit.xml")
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	code := run([]string{"-base-url", server.URL, "-var", "path=nope", "-junit", junit, "-json", filepath.Join(dir, "report.json"), dir}, stdout, stderr)
+	if code != 1 {
+		t.Fatalf("Expected exit code 1; found %d. Output:\n%s%s", code, stdout, stderr)
+	}
+	if output := stdout.String(); !strings.Contains(output, "PASS health") || !strings.Contains(output, "FAIL missing") || !strings.Contains(output, "Expected 200; found 404") {
+		t.Fatalf("Unexpected output:\n%s", output)
+	}
+	if report, err := ioutil.ReadFile(junit); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(string(report), `tests="2" failures="1"`) {
+		t.Fatalf("Unexpected JUnit report:\n%s", report)
+	}
+
+	if code := run([]string{"-base-url", server.URL, filepath.Join(dir, "a.yaml")}, stdout, stderr); code != 0 {
+		t.Fatalf("Expected exit code 0; found %d", code)
+	}
+	if code := run([]string{filepath.Join(dir, "none.yaml")}, stdout, stderr); code != 2 {
+		t.Fatalf("Expected exit code 2; found %d", code)
+	}
+}
+
+func TestRunFailed(t *testing.T) {
+	healthy := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/a" || healthy {
+			w.Write([]byte("ok"))
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "argot-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a", "b"} {
+		ioutil.WriteFile(filepath.Join(dir, name+".yaml"), []byte(`
+name: `+name+`
+steps:
+  - request: {method: GET, url: /`+name+`}
+    expect: {status: 200}
+`), 0644)
+	}
+	lastRun := filepath.Join(dir, "last-run.json")
+	args := []string{"-base-url", server.URL, "-last-run", lastRun, "-failed", filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")}
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if code := run(args, stdout, stderr); code != 1 {
+		t.Fatalf("Expected exit code 1; found %d. Output:\n%s%s", code, stdout, stderr)
+	} else if output := stdout.String(); !strings.Contains(output, "PASS a") || !strings.Contains(output, "FAIL b") {
+		t.Fatalf("Unexpected output:\n%s", output)
+	}
+
+	healthy = true
+	stdout.Reset()
+	if code := run(args, stdout, stderr); code != 0 {
+		t.Fatalf("Expected exit code 0; found %d. Output:\n%s%s", code, stdout, stderr)
+	} else if output := stdout.String(); strings.Contains(output, "PASS a") || !strings.Contains(output, "PASS b") || !strings.Contains(output, "1 of 2") {
+		t.Fatalf("Unexpected output:\n%s", output)
+	}
+
+	stdout.Reset()
+	if code := run(args, stdout, stderr); code != 0 {
+		t.Fatalf("Expected exit code 0; found %d", code)
+	} else if output := stdout.String(); !strings.Contains(output, "2 passed, 0 failed") {
+		t.Fatalf("Unexpected output:\n%s", output)
+	}
+
+	if code := run([]string{"-failed", filepath.Join(dir, "a.yaml")}, stdout, stderr); code != 2 {
+		t.Fatalf("Expected exit code 2; found %d", code)
+	}
+}
+
+func TestRunGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "argot-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	spec := filepath.Join(dir, "openapi.json")
+	ioutil.WriteFile(spec, []byte(`{"openapi": "3.0.0", "paths": {
+  "/health": {"get": {"responses": {"200": {}}}},
+  "/users/{id}": {"get": {"operationId": "getUser", "parameters": [{"name": "id", "in": "path", "schema": {"type": "string", "example": "alice"}}], "responses": {"200": {}}}}}}`), 0644)
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	out := filepath.Join(dir, "scenarios")
+	if code := run([]string{"-generate", spec, out}, stdout, stderr); code != 0 {
+		t.Fatalf("Expected exit code 0; found %d. Output:\n%s%s", code, stdout, stderr)
+	}
+	if files, err := filepath.Glob(filepath.Join(out, "*.yaml")); err != nil {
+		t.Fatal(err)
+	} else if len(files) != 2 || filepath.Base(files[0]) != "GET-health.yaml" || filepath.Base(files[1]) != "getUser.yaml" {
+		t.Fatalf("Unexpected files: %v", files)
+	}
+	if scenarios, err := loadScenarios([]string{out}); err != nil {
+		t.Fatal(err)
+	} else if scenarios[1].Vars["id"] != "alice" {
+		t.Fatalf("Unexpected scenario: %+v", scenarios[1])
+	}
+
+	source := filepath.Join(dir, "api.go")
+	if code := run([]string{"-generate", spec, "-package", "api", source}, stdout, stderr); code != 0 {
+		t.Fatalf("Expected exit code 0; found %d. Output:\n%s%s", code, stdout, stderr)
+	} else if bites, err := ioutil.ReadFile(source); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(string(bites), "package api") || !strings.Contains(string(bites), "func GetUser(") {
+		t.Fatalf("Unexpected source:\n%s", bites)
+	}
+}
+
+func TestRunRecord(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tea"))
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "argot-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stderr := new(syncWriter)
+	stop := make(chan os.Signal)
+	done := make(chan error)
+	source := filepath.Join(dir, "browse_teas.go")
+	go func() { done <- recordRequests("127.0.0.1:0", source, "recorded", stderr, stop) }()
+	var proxyURL *url.URL
+	for proxyURL == nil {
+		time.Sleep(10 * time.Millisecond)
+		if fields := strings.Fields(stderr.String()); len(fields) > 7 {
+			proxyURL, _ = url.Parse(strings.TrimSuffix(fields[7], ";"))
+		}
+	}
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	if _, err := client.Get(server.URL + "/teas"); err != nil {
+		t.Fatal(err)
+	}
+	stop <- os.Interrupt
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	} else if bites, err := ioutil.ReadFile(source); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(string(bites), "func BrowseTeas(") || !strings.Contains(string(bites), "baseURL+`/teas`") {
+		t.Fatalf("Unexpected source:\n%s", bites)
+	}
+
+	// The recording can also be kept as HAR, and converted later.
+	har := filepath.Join(dir, "session.har")
+	go func() { done <- recordRequests("127.0.0.1:0", har, "recorded", new(syncWriter), stop) }()
+	stop <- os.Interrupt
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	stdout, errout := new(bytes.Buffer), new(bytes.Buffer)
+	if code := run([]string{"-generate", har, "-package", "recorded", filepath.Join(dir, "session.go")}, stdout, errout); code != 0 {
+		t.Fatalf("Expected exit code 0; found %d. Output:\n%s%s", code, stdout, errout)
+	} else if code := run([]string{"-generate", har, filepath.Join(dir, "out")}, stdout, errout); code != 2 {
+		t.Fatalf("Expected exit code 2 for a HAR document without a .go path; found %d.", code)
+	}
+}
+
+type syncWriter struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (sw *syncWriter) Write(p []byte) (int, error) {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	return sw.buf.Write(p)
+}
+
+func (sw *syncWriter) String() string {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	return sw.buf.String()
+}
+
+func TestRunBaseline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "argot-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	scenario := filepath.Join(dir, "health.yaml")
+	ioutil.WriteFile(scenario, []byte(`
+name: health
+steps:
+  - request: {method: GET, url: /health}
+    expect: {status: 200}
+`), 0644)
+	baseline := filepath.Join(dir, "baseline.json")
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if code := run([]string{"-base-url", server.URL, "-baseline", baseline, "-rebaseline", scenario}, stdout, stderr); code != 0 {
+		t.Fatalf("Expected exit code 0; found %d. Output:\n%s%s", code, stdout, stderr)
+	} else if !strings.Contains(stdout.String(), "Rebaselined timings") {
+		t.Fatalf("Unexpected output:\n%s", stdout)
+	}
+	timings := map[string]float64{}
+	if bites, err := ioutil.ReadFile(baseline); err != nil {
+		t.Fatal(err)
+	} else if err := json.Unmarshal(bites, &timings); err != nil {
+		t.Fatal(err)
+	} else if _, found := timings["GET "+server.URL+"/health"]; !found {
+		t.Fatalf("Expected a timing for the call; found %v", timings)
+	}
+
+	for key := range timings {
+		timings[key] = 0.000001
+	}
+	bites, _ := json.Marshal(timings)
+	ioutil.WriteFile(baseline, bites, 0644)
+	stdout.Reset()
+	if code := run([]string{"-base-url", server.URL, "-baseline", baseline, scenario}, stdout, stderr); code != 1 {
+		t.Fatalf("Expected exit code 1; found %d. Output:\n%s%s", code, stdout, stderr)
+	} else if output := stdout.String(); !strings.Contains(output, "PASS health") || !strings.Contains(output, "slower than the baseline") {
+		t.Fatalf("Unexpected output:\n%s", output)
+	}
+	if code := run([]string{"-rebaseline", scenario}, stdout, stderr); code != 2 {
+		t.Fatalf("Expected exit code 2; found %d", code)
+	}
+}
+
+func TestRunCircuitBreaker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+	dir, err := ioutil.TempDir("", "argot-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a", "b", "c"} {
+		ioutil.WriteFile(filepath.Join(dir, name+".yaml"), []byte(`
+name: `+name+`
+steps:
+  - request: {method: GET, url: /`+name+`}
+    expect: {status: 200}
+`), 0644)
+	}
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if code := run([]string{"-base-url", server.URL, "-circuit-breaker", "2", dir}, stdout, stderr); code != 1 {
+		t.Fatalf("Expected exit code 1; found %d. Output:\n%s%s", code, stdout, stderr)
+	}
+	output := stdout.String()
+	if strings.Count(output, "Dependency down: "+strings.TrimPrefix(server.URL, "http://")+" failed 2 consecutive times") != 1 {
+		t.Fatalf("Expected only the third scenario to fail fast; found:\n%s", output)
+	} else if !strings.Contains(output, "0 passed, 3 failed\nDependency down: "+strings.TrimPrefix(server.URL, "http://")+"\n") {
+		t.Fatalf("Expected the host to be listed as down; found:\n%s", output)
+	}
+}
+
+func TestRunPriority(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "argot-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, header := range map[string]string{"a": "", "b": "priority: P1\n", "c": "priority: P0\n", "d": "priority: P1\n"} {
+		path := "/ok"
+		if name == "b" {
+			path = "/broken"
+		}
+		ioutil.WriteFile(filepath.Join(dir, name+".yaml"), []byte(`
+name: `+name+`
+`+header+`steps:
+  - request: {method: GET, url: `+path+`}
+    expect: {status: 200}
+`), 0644)
+	}
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if code := run([]string{"-base-url", server.URL, dir}, stdout, stderr); code != 1 {
+		t.Fatalf("Expected exit code 1; found %d. Output:\n%s%s", code, stdout, stderr)
+	} else if output := stdout.String(); !strings.HasPrefix(output, "PASS c") || !strings.Contains(output, "FAIL b") || strings.Index(output, "PASS d") > strings.Index(output, "PASS a") {
+		t.Fatalf("Expected the scenarios to run in priority order; found:\n%s", output)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-base-url", server.URL, "-priority", "P0", dir}, stdout, stderr); code != 0 {
+		t.Fatalf("Expected exit code 0; found %d. Output:\n%s%s", code, stdout, stderr)
+	} else if output := stdout.String(); !strings.Contains(output, "Running the 1 scenarios of priority P0 or higher\nPASS c") || !strings.Contains(output, "1 passed, 0 failed") {
+		t.Fatalf("Expected only the smoke scenario to run; found:\n%s", output)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-base-url", server.URL, "-fail-fast-priority", "P1", dir}, stdout, stderr); code != 1 {
+		t.Fatalf("Expected exit code 1; found %d. Output:\n%s%s", code, stdout, stderr)
+	} else if output := stdout.String(); !strings.Contains(output, "PASS d") || !strings.Contains(output, "SKIP a (a P1 scenario failed)") || !strings.Contains(output, "2 passed, 1 failed, 1 skipped") {
+		t.Fatalf("Expected the unprioritised scenario to be skipped; found:\n%s", output)
+	}
+
+	if code := run([]string{"-priority", "urgent", dir}, stdout, stderr); code != 2 {
+		t.Fatalf("Expected exit code 2; found %d", code)
+	}
+}
+
+func TestRunQuarantine(t *testing.T) {
+	broken := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if broken && r.URL.Path == "/flaky" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "argot-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	scenarios := filepath.Join(dir, "scenarios")
+	os.Mkdir(scenarios, 0755)
+	for _, name := range []string{"flaky", "stable"} {
+		ioutil.WriteFile(filepath.Join(scenarios, name+".yaml"), []byte(`
+name: `+name+`
+steps:
+  - request: {method: GET, url: /`+name+`}
+    expect: {status: 200}
+`), 0644)
+	}
+	quarantine := filepath.Join(dir, "quarantine.json")
+	ioutil.WriteFile(quarantine, []byte(`{"patterns": ["fla*"], "releaseAfter": 2}`), 0644)
+	args := []string{"-base-url", server.URL, "-quarantine", quarantine, scenarios}
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if code := run(args, stdout, stderr); code != 0 {
+		t.Fatalf("Expected exit code 0; found %d. Output:\n%s%s", code, stdout, stderr)
+	} else if output := stdout.String(); !strings.Contains(output, ", quarantined)\n") || !strings.Contains(output, "1 passed, 0 failed, 1 quarantined") {
+		t.Fatalf("Expected the failure to be quarantined; found:\n%s", output)
+	}
+
+	broken = false
+	for idx := 0; idx < 2; idx++ {
+		stdout.Reset()
+		if code := run(args, stdout, stderr); code != 0 {
+			t.Fatalf("Expected exit code 0; found %d. Output:\n%s%s", code, stdout, stderr)
+		}
+	}
+	if output := stdout.String(); !strings.Contains(output, "Quarantined scenario flaky has passed 2 consecutive runs; consider releasing it") {
+		t.Fatalf("Expected the scenario to be reported as stable; found:\n%s", output)
+	}
+}
+
+func TestRunDiagnostics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/version" {
+			w.Write([]byte("1.2.3"))
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "argot-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	scenario := filepath.Join(dir, "broken.yaml")
+	ioutil.WriteFile(scenario, []byte(`
+name: broken
+steps:
+  - request: {method: GET, url: /broken}
+    expect: {status: 200}
+`), 0644)
+	artifacts := filepath.Join(dir, "artifacts")
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if code := run([]string{"-base-url", server.URL, "-diagnostics", "-artifacts", artifacts, scenario}, stdout, stderr); code != 1 {
+		t.Fatalf("Expected exit code 1; found %d. Output:\n%s%s", code, stdout, stderr)
+	}
+	if version, err := ioutil.ReadFile(filepath.Join(artifacts, "broken", "3-diagnostics-version.txt")); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(string(version), "1.2.3") {
+		t.Fatalf("Unexpected version diagnostics:\n%s", version)
+	}
+	if _, err := os.Stat(filepath.Join(artifacts, "broken", "3-diagnostics-health.txt")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunDeprecations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "argot-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	scenario := filepath.Join(dir, "teas.yaml")
+	ioutil.WriteFile(scenario, []byte(`
+name: teas
+steps:
+  - request: {method: GET, url: /teas/1}
+    expect: {status: 200}
+`), 0644)
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if code := run([]string{"-base-url", server.URL, "-deprecations", "report", scenario}, stdout, stderr); code != 0 {
+		t.Fatalf("Expected exit code 0; found %d. Output:\n%s%s", code, stdout, stderr)
+	} else if output := stdout.String(); !strings.Contains(output, "1 passed, 0 failed\nDeprecations (1):\n\tGET /teas/{id}: Deprecation: true\n") {
+		t.Fatalf("Expected the deprecation to be reported; found:\n%s", output)
+	}
+	stdout.Reset()
+	if code := run([]string{"-base-url", server.URL, "-deprecations", "fail", scenario}, stdout, stderr); code != 1 {
+		t.Fatalf("Expected exit code 1; found %d. Output:\n%s%s", code, stdout, stderr)
+	} else if output := stdout.String(); !strings.Contains(output, "Deprecated: GET /teas/{id}: Deprecation: true") {
+		t.Fatalf("Expected the deprecation to fail the scenario; found:\n%s", output)
+	}
+	if code := run([]string{"-deprecations", "ignore", scenario}, stdout, stderr); code != 2 {
+		t.Fatalf("Expected exit code 2; found %d", code)
+	}
+}
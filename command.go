@@ -0,0 +1,282 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CommandCall captures all the state relating to running a single
+// external command (for example a migration or seeding CLI). As with
+// HttpCall, the command is created by one step (RunCommand), may be
+// configured by further steps (Setenv, Stdin), and is run lazily by
+// the first step that inspects its result. A CommandCall may be used
+// multiple times, but only by a single go-routine at a time.
+type CommandCall struct {
+	// The command to be run.
+	Cmd *exec.Cmd
+	// If non-zero, the command is killed if it runs for longer than
+	// Timeout.
+	Timeout time.Duration
+	// Once run, the exit code of the command.
+	ExitCode int
+	// Once run, the captured standard output.
+	Stdout []byte
+	// Once run, the captured standard error.
+	Stderr []byte
+
+	ran     bool
+	timeout bool
+	ssh     string
+}
+
+// NewCommandCall creates a new CommandCall.
+func NewCommandCall() *CommandCall {
+	return &CommandCall{}
+}
+
+// Reset is idempotent. It discards any previous command and result.
+func (cc *CommandCall) Reset() error {
+	cc.Cmd = nil
+	cc.ExitCode = 0
+	cc.Stdout = nil
+	cc.Stderr = nil
+	cc.ran = false
+	cc.timeout = false
+	cc.ssh = ""
+	return nil
+}
+
+// RunCommand is a Step that when executed creates a new command to
+// run name with args. The command inherits the current environment
+// (see Setenv). The step will automatically call cc.Reset. The
+// command is not run until a step needs its result (see
+// EnsureResult).
+func (cc *CommandCall) RunCommand(name string, args ...string) Step {
+	return NewNamedStep(fmt.Sprintf("RunCommand(%s)", strings.Join(append([]string{name}, args...), " ")), func() error {
+		if err := cc.Reset(); err != nil {
+			return err
+		}
+		cc.Cmd = exec.Command(name, args...)
+		cc.Cmd.Env = os.Environ()
+		return nil
+	})
+}
+
+func (cc *CommandCall) assertNotRun() error {
+	if cc.Cmd == nil {
+		return errors.New("No command set")
+	} else if cc.ran {
+		return errors.New("Command already run")
+	} else {
+		return nil
+	}
+}
+
+// Setenv is a Step that when executed sets the environment variable
+// key to value for the command. It must be used after RunCommand and
+// before the command has been run.
+func (cc *CommandCall) Setenv(key, value string) Step {
+	return NewNamedStep(fmt.Sprintf("Setenv(%s: %s)", key, DefaultRedactor.String(value)), func() error {
+		if err := cc.assertNotRun(); err != nil {
+			return err
+		}
+		cc.Cmd.Env = append(cc.Cmd.Env, key+"="+value)
+		return nil
+	})
+}
+
+// Dir is a Step that when executed sets the working directory of the
+// command.
+func (cc *CommandCall) Dir(dir string) Step {
+	return NewNamedStep(fmt.Sprintf("Dir(%s)", dir), func() error {
+		if err := cc.assertNotRun(); err != nil {
+			return err
+		}
+		cc.Cmd.Dir = dir
+		return nil
+	})
+}
+
+// Stdin is a Step that when executed sets the standard input of the
+// command to input.
+func (cc *CommandCall) Stdin(input string) Step {
+	return NewNamedStep("Stdin", func() error {
+		if err := cc.assertNotRun(); err != nil {
+			return err
+		}
+		cc.Cmd.Stdin = strings.NewReader(input)
+		return nil
+	})
+}
+
+// commandWaitDelay bounds how long EnsureResult waits for the output
+// of a timed out command to be closed once the command is killed.
+const commandWaitDelay = 100 * time.Millisecond
+
+// EnsureResult is idempotent. If the command has already been run
+// then it will return nil. Otherwise it runs the command to
+// completion, capturing its output and exit code. A non-zero exit
+// code is not an error (use ExitCodeEquals); failing to start the
+// command, exceeding Timeout, or, for RunSSH, failing to connect, is.
+func (cc *CommandCall) EnsureResult() error {
+	if cc.ran {
+		if cc.timeout {
+			return fmt.Errorf("Command timed out after %v.", cc.Timeout)
+		}
+		return cc.sshError()
+	} else if cc.Cmd == nil {
+		return errors.New("Cannot ensure result: no command.")
+	}
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	cc.Cmd.Stdout = stdout
+	cc.Cmd.Stderr = stderr
+	if cc.Timeout > 0 && cc.Cmd.WaitDelay == 0 {
+		// Any children left holding the output pipes once the command
+		// is killed would otherwise keep Wait from returning.
+		cc.Cmd.WaitDelay = commandWaitDelay
+	}
+	if err := cc.Cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cc.Cmd.Wait() }()
+	var timeout <-chan time.Time
+	if cc.Timeout > 0 {
+		timer := time.NewTimer(cc.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var err error
+	select {
+	case err = <-done:
+	case <-timeout:
+		cc.Cmd.Process.Kill()
+		err = <-done
+		cc.timeout = true
+	}
+	cc.ran = true
+	cc.Stdout = stdout.Bytes()
+	cc.Stderr = stderr.Bytes()
+	cc.ExitCode = cc.Cmd.ProcessState.ExitCode()
+	if cc.timeout {
+		return fmt.Errorf("Command timed out after %v.", cc.Timeout)
+	} else if _, isExitErr := err.(*exec.ExitError); err != nil && !isExitErr {
+		return err
+	} else {
+		return cc.sshError()
+	}
+}
+
+// Run is a Step that when executed runs the command. This is not
+// normally necessary: all steps that inspect the result run the
+// command when necessary.
+func (cc *CommandCall) Run() Step {
+	return NewNamedStep("Run", cc.EnsureResult)
+}
+
+// ExitCodeEquals is a Step that when executed ensures the command has
+// been run and errors unless its exit code equals code.
+func (cc *CommandCall) ExitCodeEquals(code int) Step {
+	return NewNamedStep(fmt.Sprintf("ExitCodeEquals(%d)", code), func() error {
+		if err := cc.EnsureResult(); err != nil {
+			return err
+		} else if cc.ExitCode != code {
+			return fmt.Errorf("Exit code: Expected %d; found %d. Stderr: '%s'.", code, cc.ExitCode, string(cc.Stderr))
+		} else {
+			return nil
+		}
+	})
+}
+
+func (cc *CommandCall) outputContains(stream string, output func() []byte, value string) Step {
+	return NewNamedStep(fmt.Sprintf("%sContains", stream), func() error {
+		if err := cc.EnsureResult(); err != nil {
+			return err
+		} else if found := string(output()); !strings.Contains(found, value) {
+			return fmt.Errorf("%s: Expected '%s'; found '%s'.", stream, value, found)
+		} else {
+			return nil
+		}
+	})
+}
+
+func (cc *CommandCall) outputMatches(stream string, output func() []byte, pattern *regexp.Regexp) Step {
+	return NewNamedStep(fmt.Sprintf("%sMatches(%v)", stream, pattern), func() error {
+		if err := cc.EnsureResult(); err != nil {
+			return err
+		} else if found := output(); !pattern.Match(found) {
+			return fmt.Errorf("%s: Expected to match the pattern '%v'; found '%s'.", stream, pattern, string(found))
+		} else {
+			return nil
+		}
+	})
+}
+
+// StdoutEquals is a Step that when executed ensures the command has
+// been run and errors unless its standard output equals value. Note
+// this is an exact match.
+func (cc *CommandCall) StdoutEquals(value string) Step {
+	return NewNamedStep("StdoutEquals", func() error {
+		if err := cc.EnsureResult(); err != nil {
+			return err
+		} else if found := string(cc.Stdout); found != value {
+			return diffError("stdout", value, found, "Stdout: Diff: '%s'.", diff(value, found))
+		} else {
+			return nil
+		}
+	})
+}
+
+// StdoutContains is a Step that when executed ensures the command
+// has been run and errors unless its standard output contains value
+// using strings.Contains.
+func (cc *CommandCall) StdoutContains(value string) Step {
+	return cc.outputContains("Stdout", func() []byte { return cc.Stdout }, value)
+}
+
+// StdoutMatches is a Step that when executed ensures the command has
+// been run and errors unless its standard output matches pattern.
+func (cc *CommandCall) StdoutMatches(pattern *regexp.Regexp) Step {
+	return cc.outputMatches("Stdout", func() []byte { return cc.Stdout }, pattern)
+}
+
+// StderrContains is a Step that when executed ensures the command
+// has been run and errors unless its standard error contains value
+// using strings.Contains.
+func (cc *CommandCall) StderrContains(value string) Step {
+	return cc.outputContains("Stderr", func() []byte { return cc.Stderr }, value)
+}
+
+// StderrMatches is a Step that when executed ensures the command has
+// been run and errors unless its standard error matches pattern.
+func (cc *CommandCall) StderrMatches(pattern *regexp.Regexp) Step {
+	return cc.outputMatches("Stderr", func() []byte { return cc.Stderr }, pattern)
+}
+
+// StdoutJSONMatchesStruct is a Step that when executed ensures the
+// command has been run, parses its standard output as JSON (via
+// encoding/json) based on the type of the expected structure and
+// errors unless it is equal to the expected value, as validated by
+// the pretty package.
+func (cc *CommandCall) StdoutJSONMatchesStruct(expected interface{}) Step {
+	return NewNamedStep("StdoutJSONMatchesStruct", func() error {
+		parseAs := reflect.New(reflect.TypeOf(expected)).Interface()
+		if err := cc.EnsureResult(); err != nil {
+			return err
+		} else if err := json.Unmarshal(cc.Stdout, parseAs); err != nil {
+			return err
+		} else if err := compareError(parseAs, expected); err != nil {
+			return err
+		} else {
+			return nil
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package argot
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestCommandCall(t *testing.T) {
+	cc := NewCommandCall()
+
+	Steps{
+		cc.RunCommand("sh", "-c", `echo "{\"name\": \"$NAME\"}"; echo oops >&2; exit 3`),
+		cc.Setenv("NAME", "argot"),
+		cc.ExitCodeEquals(3),
+		cc.StdoutJSONMatchesStruct(map[string]string{"name": "argot"}),
+		cc.StderrContains("oops"),
+		cc.StdoutMatches(regexp.MustCompile(`"name": "\w+"`)),
+		ExpectError(cc.Setenv("TOO", "late")),
+
+		cc.RunCommand("cat"),
+		cc.Stdin("hello"),
+		cc.StdoutEquals("hello"),
+		cc.ExitCodeEquals(0),
+
+		cc.RunCommand("sleep", "5"),
+		NewNamedStep("SetTimeout", func() error {
+			cc.Timeout = 50 * time.Millisecond
+			return nil
+		}),
+		ExpectError(cc.Run()),
+	}.Test(t)
+}
+
+func TestCommandCallTimeoutWithChild(t *testing.T) {
+	cc := NewCommandCall()
+	start := time.Now()
+
+	Steps{
+		cc.RunCommand("sh", "-c", "sleep 60; echo done"),
+		NewNamedStep("SetTimeout", func() error {
+			cc.Timeout = 50 * time.Millisecond
+			return nil
+		}),
+		ExpectError(cc.Run()),
+		NewNamedStep("Bounded", func() error {
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				return fmt.Errorf("Expected the timeout to bound the command; took %v.", elapsed)
+			}
+			return nil
+		}),
+	}.Test(t)
+}
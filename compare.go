@@ -0,0 +1,204 @@
+package argot
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+var (
+	// CompareConfig is the pretty configuration used to diff values by
+	// the steps which compare structures, such as ExpectPrettyEqual and
+	// ResponseBodyJSONMatchesStruct. Formatters for domain types are
+	// best added with RegisterFormatter.
+	CompareConfig = &pretty.Config{
+		Diffable:          true,
+		IncludeUnexported: true,
+		Formatter:         map[reflect.Type]interface{}{},
+	}
+
+	compareLock sync.RWMutex
+	comparators = make(map[reflect.Type]reflect.Value)
+)
+
+func init() {
+	for t, formatter := range pretty.DefaultFormatter {
+		CompareConfig.Formatter[t] = formatter
+	}
+}
+
+// funcOf panics unless fn is a function with the given number of
+// parameters, all of the same type, and a single result of type out,
+// and returns that parameter type.
+func funcOf(kind string, fn interface{}, in int, out reflect.Type) reflect.Type {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func || t.NumIn() != in || t.NumOut() != 1 || t.Out(0) != out {
+		panic(fmt.Sprintf("argot: %s must be a function of %d arguments returning %v; found %T", kind, in, out, fn))
+	}
+	for idx := 1; idx < in; idx++ {
+		if t.In(idx) != t.In(0) {
+			panic(fmt.Sprintf("argot: %s arguments must be of the same type; found %T", kind, fn))
+		}
+	}
+	return t.In(0)
+}
+
+// RegisterFormatter registers formatter, a function such as
+// func(T) string, which is used to print values of type T when they
+// are compared, for example to redact secrets or shorten verbose
+// types. Values which format identically compare equal.
+func RegisterFormatter(formatter interface{}) {
+	t := funcOf("formatter", formatter, 1, reflect.TypeOf(""))
+	compareLock.Lock()
+	defer compareLock.Unlock()
+	CompareConfig.Formatter[t] = formatter
+}
+
+// RegisterComparator registers comparator, a function such as
+// func(a, b T) bool, which decides whether two values of type T are
+// equal when they are compared, wherever they are found within the
+// compared values. For example, to compare times within a tolerance:
+//
+//	argot.RegisterComparator(func(a, b time.Time) bool {
+//		return a.Sub(b) < time.Second && b.Sub(a) < time.Second
+//	})
+func RegisterComparator(comparator interface{}) {
+	t := funcOf("comparator", comparator, 2, reflect.TypeOf(true))
+	compareLock.Lock()
+	defer compareLock.Unlock()
+	comparators[t] = reflect.ValueOf(comparator)
+}
+
+// compare returns a diff of got and want, or the empty string if they
+// are equal, using CompareConfig and any registered comparators. Values
+// which print identically are always equal: comparators can only
+// loosen the comparison.
+func compare(got, want interface{}) string {
+	return compareWithin(got, want, 0)
+}
+
+// compareWithin is as compare, but floating point numbers are equal if
+// they differ by no more than epsilon.
+func compareWithin(got, want interface{}, epsilon float64) string {
+	compareLock.RLock()
+	defer compareLock.RUnlock()
+	if diff := CompareConfig.Compare(got, want); diff == "" || len(comparators) == 0 && epsilon == 0 {
+		return diff
+	} else if (comparison{epsilon: epsilon}).equal(reflect.ValueOf(got), reflect.ValueOf(want)) {
+		return ""
+	} else {
+		return diff
+	}
+}
+
+type comparison struct {
+	epsilon float64
+}
+
+// equal compares a and b structurally, as printed by pretty (so
+// pointers are followed), using the registered comparators and
+// formatters wherever their types are found. compareLock must be
+// held.
+func (c comparison) equal(a, b reflect.Value) bool {
+	for {
+		if !a.IsValid() || !b.IsValid() {
+			return a.IsValid() == b.IsValid()
+		} else if a.Type() == b.Type() && a.CanInterface() && b.CanInterface() {
+			if comparator, found := comparators[a.Type()]; found {
+				return comparator.Call([]reflect.Value{a, b})[0].Bool()
+			} else if formatter, found := CompareConfig.Formatter[a.Type()]; found {
+				format := reflect.ValueOf(formatter)
+				return format.Call([]reflect.Value{a})[0].String() == format.Call([]reflect.Value{b})[0].String()
+			}
+		}
+		aRef := a.Kind() == reflect.Ptr || a.Kind() == reflect.Interface
+		bRef := b.Kind() == reflect.Ptr || b.Kind() == reflect.Interface
+		if aRef && a.IsNil() || bRef && b.IsNil() {
+			return aRef && bRef && a.IsNil() && b.IsNil()
+		} else if aRef {
+			a = a.Elem()
+		} else if bRef {
+			b = b.Elem()
+		} else {
+			break
+		}
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() == b.Float() || math.Abs(a.Float()-b.Float()) <= c.epsilon
+	case reflect.Complex64, reflect.Complex128:
+		return a.Complex() == b.Complex()
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Struct:
+		for idx := 0; idx < a.NumField(); idx++ {
+			if !c.equal(a.Field(idx), b.Field(idx)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice, reflect.Array:
+		if a.Kind() == reflect.Slice && a.IsNil() != b.IsNil() || a.Len() != b.Len() {
+			return false
+		}
+		for idx := 0; idx < a.Len(); idx++ {
+			if !c.equal(a.Index(idx), b.Index(idx)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.IsNil() != b.IsNil() || a.Len() != b.Len() {
+			return false
+		}
+		for _, key := range a.MapKeys() {
+			if !c.equal(a.MapIndex(key), b.MapIndex(key)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a.Pointer() == b.Pointer()
+	}
+}
+
+// ExpectPrettyEqual is a Step that when executed errors unless actual
+// equals expected, as compared by the pretty package using
+// CompareConfig and any registered comparators. The error will
+// contain a structured diff output as for
+// ResponseBodyJSONMatchesStruct.
+func ExpectPrettyEqual(expected, actual interface{}) Step {
+	return NewNamedStep("ExpectPrettyEqual", func() error {
+		if err := compareError(actual, expected); err != nil {
+			return err
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectPrettyEqualWithin is as ExpectPrettyEqual, except that
+// floating point numbers anywhere within the values are equal if they
+// differ by no more than epsilon, so that noise such as
+// 0.30000000000000004 does not cause a failure.
+func ExpectPrettyEqualWithin(expected, actual interface{}, epsilon float64) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectPrettyEqualWithin(%v)", epsilon), func() error {
+		if err := compareErrorWithin(actual, expected, epsilon); err != nil {
+			return err
+		} else {
+			return nil
+		}
+	})
+}
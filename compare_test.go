@@ -0,0 +1,74 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type compareMoney struct {
+	Pence int
+}
+
+type compareSecret string
+
+type compareOrder struct {
+	ID       int
+	Total    compareMoney
+	Token    compareSecret
+	Previous *compareOrder
+}
+
+func TestRegisterComparator(t *testing.T) {
+	RegisterComparator(func(a, b compareMoney) bool {
+		return a.Pence/100 == b.Pence/100
+	})
+	RegisterFormatter(func(s compareSecret) string { return "<redacted>" })
+
+	got := &compareOrder{ID: 1, Total: compareMoney{1099}, Token: "abc", Previous: &compareOrder{Total: compareMoney{250}}}
+	Steps{
+		ExpectPrettyEqual(compareOrder{ID: 1, Total: compareMoney{1000}, Token: "xyz", Previous: &compareOrder{Total: compareMoney{201}}}, got),
+		ExpectError(ExpectPrettyEqual(compareOrder{ID: 1, Total: compareMoney{1100}}, got)),
+		ExpectError(ExpectPrettyEqual(compareOrder{ID: 2, Total: compareMoney{1000}}, got)),
+		ExpectError(ExpectPrettyEqual(compareOrder{ID: 1, Total: compareMoney{1000}}, got)),
+	}.Test(t)
+
+	err := ExpectPrettyEqual(compareOrder{ID: 2}, compareOrder{ID: 1, Token: "abc"}).Go()
+	if err == nil || strings.Contains(err.Error(), "abc") || !strings.Contains(err.Error(), "<redacted>") {
+		t.Fatalf("Expected a redacted diff; found %v", err)
+	}
+}
+
+func TestRegisterComparatorPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected a panic.")
+		}
+	}()
+	RegisterComparator(func(a compareMoney, b int) bool { return true })
+}
+
+func TestCompareWithin(t *testing.T) {
+	type price struct {
+		Amount float64
+		Rates  []float64
+		Extra  map[string]interface{}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Amount":0.30000000000000004,"Rates":[1.0000001],"Extra":{"tax":0.2}}`))
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	expected := price{Amount: 0.3, Rates: []float64{1}, Extra: map[string]interface{}{"tax": 0.2000001}}
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		ExpectError(hc.ResponseBodyJSONMatchesStruct(expected)),
+		hc.ResponseBodyJSONMatchesStructWithin(expected, 1e-6),
+		ExpectError(hc.ResponseBodyJSONMatchesStructWithin(expected, 1e-9)),
+		ExpectPrettyEqualWithin(0.1+0.2, 0.3, 1e-9),
+		ExpectError(ExpectPrettyEqualWithin(0.31, 0.3, 1e-9)),
+	}.Test(t)
+}
@@ -0,0 +1,248 @@
+package argot
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CompressionStats are the sizes of the response bodies of one
+// endpoint, as recorded by a CompressionAnalyzer.
+type CompressionStats struct {
+	// The endpoint, identified as by Coverage, for example
+	// "GET /teas/{id}".
+	Endpoint string
+	// The media type of the last response, for example
+	// "application/json".
+	ContentType string
+	// The Content-Encoding of the last response, or "identity" if it
+	// was not compressed.
+	Encoding  string
+	Responses int
+	// The bytes of the bodies, uncompressed.
+	Size int64
+	// The bytes of the bodies as sent by the server.
+	WireSize int64
+	// The bytes of the bodies as compressed with gzip locally: what the
+	// server would have sent had it compressed them.
+	GzipSize int64
+}
+
+// Ratio returns the size of the bodies as sent as a proportion of
+// their uncompressed size: 1 if the server did not compress them.
+func (cs CompressionStats) Ratio() float64 {
+	if cs.Size == 0 {
+		return 1
+	}
+	return float64(cs.WireSize) / float64(cs.Size)
+}
+
+func (cs CompressionStats) String() string {
+	return fmt.Sprintf("%s: %d responses, %s, %s: %d bytes, %d on the wire (%.0f%%), %d with gzip (%.0f%%)",
+		cs.Endpoint, cs.Responses, cs.ContentType, cs.Encoding, cs.Size, cs.WireSize, 100*cs.Ratio(), cs.GzipSize, 100*float64(cs.GzipSize)/float64(cs.Size))
+}
+
+// CompressionAnalyzer audits how well the endpoints a suite uses
+// compress their responses. As Middleware (see
+// UseCompressionAnalyzer), it asks for gzip on each request which does
+// not itself set Accept-Encoding, and records, per endpoint, the size
+// of each response body as sent, uncompressed, and as compressed with
+// gzip locally. It decompresses the bodies it asked to be compressed,
+// as http.Transport would, so steps see the same responses as without
+// it. Endpoints which send large, compressible bodies (such as JSON)
+// uncompressed are flagged (see Uncompressed and ExpectCompressed).
+// Response bodies are buffered, so streamed responses (Server-Sent
+// Events and NDJSON) are passed through without analysis. A
+// CompressionAnalyzer is safe for concurrent use.
+type CompressionAnalyzer struct {
+	// Endpoints whose bodies average fewer than MinSize bytes are not
+	// flagged.
+	MinSize int64
+
+	lock      sync.Mutex
+	endpoints map[string]*CompressionStats
+}
+
+// NewCompressionAnalyzer creates a new CompressionAnalyzer which flags
+// endpoints whose compressible bodies average at least 1KiB.
+func NewCompressionAnalyzer() *CompressionAnalyzer {
+	return &CompressionAnalyzer{
+		MinSize:   1024,
+		endpoints: make(map[string]*CompressionStats),
+	}
+}
+
+// UseCompressionAnalyzer adds analyzer to the middleware of hc (see
+// Use), so that the compression of its responses is recorded.
+func (hc *HttpCall) UseCompressionAnalyzer(analyzer *CompressionAnalyzer) {
+	hc.Use(analyzer.Middleware)
+}
+
+// compressible returns true iff bodies of mediaType are worth
+// compressing.
+func compressible(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "xml") || strings.HasSuffix(mediaType, "javascript")
+}
+
+// decompress returns body decoded from encoding, or an error if the
+// encoding is not supported.
+func decompress(encoding string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	var err error
+	switch encoding {
+	case "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		if reader, err = zlib.NewReader(bytes.NewReader(body)); err != nil {
+			reader, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	default:
+		return nil, fmt.Errorf("Content-Encoding %s is not supported.", encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(reader)
+}
+
+// Middleware is the Middleware (see Use) which records the compression
+// of responses.
+func (ca *CompressionAnalyzer) Middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sent := req
+		transparent := req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" && req.Method != http.MethodHead
+		if transparent {
+			sent = req.Clone(req.Context())
+			sent.Header.Set("Accept-Encoding", "gzip")
+		}
+		response, err := next.RoundTrip(sent)
+		if err != nil {
+			return nil, err
+		}
+		response.Request = req
+		mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+		if mediaType == "text/event-stream" || mediaType == "application/x-ndjson" {
+			return response, nil
+		}
+		wire, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		response.Body = ioutil.NopCloser(bytes.NewReader(wire))
+		encoding := strings.ToLower(response.Header.Get("Content-Encoding"))
+		if encoding == "" {
+			encoding = "identity"
+		}
+		body, err := decompress(encoding, wire)
+		if err != nil {
+			return response, nil
+		}
+		if transparent && encoding != "identity" {
+			response.Header.Del("Content-Encoding")
+			response.Header.Del("Content-Length")
+			response.ContentLength = int64(len(body))
+			response.Uncompressed = true
+			response.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		if len(body) > 0 {
+			ca.record(req, mediaType, encoding, int64(len(wire)), body)
+		}
+		return response, nil
+	})
+}
+
+// record records a response body to req.
+func (ca *CompressionAnalyzer) record(req *http.Request, mediaType, encoding string, wireSize int64, body []byte) {
+	gzipped := new(byteCounter)
+	writer := gzip.NewWriter(gzipped)
+	writer.Write(body)
+	writer.Close()
+	endpoint := coverageEndpoint(req)
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	stats, found := ca.endpoints[endpoint]
+	if !found {
+		stats = &CompressionStats{Endpoint: endpoint}
+		ca.endpoints[endpoint] = stats
+	}
+	stats.ContentType = mediaType
+	stats.Encoding = encoding
+	stats.Responses++
+	stats.Size += int64(len(body))
+	stats.WireSize += wireSize
+	stats.GzipSize += int64(*gzipped)
+}
+
+// Stats returns the stats of every endpoint recorded, sorted by
+// endpoint.
+func (ca *CompressionAnalyzer) Stats() []CompressionStats {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	stats := make([]CompressionStats, 0, len(ca.endpoints))
+	for _, endpointStats := range ca.endpoints {
+		stats = append(stats, *endpointStats)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Endpoint < stats[j].Endpoint })
+	return stats
+}
+
+// Uncompressed returns the stats, sorted by endpoint, of the endpoints
+// which sent their last response uncompressed, although its content
+// type is compressible (such as JSON or text) and their bodies average
+// at least MinSize bytes.
+func (ca *CompressionAnalyzer) Uncompressed() []CompressionStats {
+	flagged := []CompressionStats{}
+	for _, stats := range ca.Stats() {
+		if stats.Encoding == "identity" && compressible(stats.ContentType) && stats.Size/int64(stats.Responses) >= ca.MinSize {
+			flagged = append(flagged, stats)
+		}
+	}
+	return flagged
+}
+
+// Report returns the stats of every endpoint recorded, one per line,
+// with those flagged by Uncompressed marked.
+func (ca *CompressionAnalyzer) Report() string {
+	flagged := make(map[string]bool)
+	for _, stats := range ca.Uncompressed() {
+		flagged[stats.Endpoint] = true
+	}
+	buf := new(bytes.Buffer)
+	for _, stats := range ca.Stats() {
+		if flagged[stats.Endpoint] {
+			fmt.Fprintf(buf, "UNCOMPRESSED %v\n", stats)
+		} else {
+			fmt.Fprintf(buf, "%v\n", stats)
+		}
+	}
+	return buf.String()
+}
+
+// ExpectCompressed is a Step that when executed errors if any
+// endpoint is flagged by Uncompressed, listing them.
+func (ca *CompressionAnalyzer) ExpectCompressed() Step {
+	return NewNamedStep("ExpectCompressed", func() error {
+		flagged := ca.Uncompressed()
+		if len(flagged) == 0 {
+			return nil
+		}
+		lines := make([]string, len(flagged))
+		for idx, stats := range flagged {
+			lines[idx] = stats.String()
+		}
+		return fmt.Errorf("Compression: Expected compressed responses; found:\n\t%s", strings.Join(lines, "\n\t"))
+	})
+}
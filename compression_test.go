@@ -0,0 +1,57 @@
+package argot
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressionAnalyzer(t *testing.T) {
+	big := `{"teas": [` + strings.Repeat(`{"name": "Earl Grey", "origin": "China"}, `, 100) + `{}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/small" {
+			w.Write([]byte(`{"name": "Assam"}`))
+		} else if r.URL.Path == "/compressed" && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			writer := gzip.NewWriter(w)
+			writer.Write([]byte(big))
+			writer.Close()
+		} else {
+			w.Write([]byte(big))
+		}
+	}))
+	defer server.Close()
+
+	analyzer := NewCompressionAnalyzer()
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	hc.UseCompressionAnalyzer(analyzer)
+	Steps{
+		hc.NewRequest("GET", server.URL+"/compressed", nil),
+		hc.ResponseHeaderNotExists("Content-Encoding"),
+		hc.ResponseBodyEquals(big),
+		hc.NewRequest("GET", server.URL+"/uncompressed/1", nil), hc.ResponseBodyEquals(big),
+		hc.NewRequest("GET", server.URL+"/uncompressed/2", nil), hc.ResponseBodyEquals(big),
+		hc.NewRequest("GET", server.URL+"/small", nil), hc.ResponseBodyEquals(`{"name": "Assam"}`),
+	}.Test(t)
+
+	stats := analyzer.Stats()
+	if len(stats) != 3 {
+		t.Fatalf("Expected 3 endpoints; found %v", stats)
+	} else if compressed := stats[0]; compressed.Endpoint != "GET /compressed" || compressed.Encoding != "gzip" || compressed.Size != int64(len(big)) || compressed.Ratio() > 0.1 {
+		t.Fatalf("Unexpected stats: %v", compressed)
+	} else if uncompressed := stats[2]; uncompressed.Endpoint != "GET /uncompressed/{id}" || uncompressed.Responses != 2 || uncompressed.WireSize != int64(2*len(big)) || uncompressed.Ratio() != 1 || uncompressed.GzipSize > uncompressed.Size/10 {
+		t.Fatalf("Unexpected stats: %v", uncompressed)
+	}
+	if flagged := analyzer.Uncompressed(); len(flagged) != 1 || flagged[0].Endpoint != "GET /uncompressed/{id}" {
+		t.Fatalf("Expected only the large uncompressed endpoint to be flagged; found %v", flagged)
+	} else if report := analyzer.Report(); !strings.Contains(report, "UNCOMPRESSED GET /uncompressed/{id}: 2 responses, application/json, identity") || !strings.Contains(report, "\nGET /small: 1 responses") {
+		t.Fatalf("Unexpected report:\n%s", report)
+	}
+	if err := analyzer.ExpectCompressed().Go(); err == nil || !strings.Contains(err.Error(), "Compression: Expected compressed responses; found:\n\tGET /uncompressed/{id}") {
+		t.Fatalf("Expected an error for the uncompressed endpoint; found %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package argot
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Concurrently is a Step that when executed creates n steps with
+// factory (passing each its index) and runs them all at the same
+// time, waiting for every one to finish. It errors if any of them
+// error, with a MultiError listing every failure. All n go-routines
+// are started and released together so that the steps race as
+// closely as possible, which makes it useful for reproducing race
+// conditions such as duplicate creation or lost updates. Each step
+// must use its own state: for example its own HttpCall.
+func Concurrently(n int, factory func(i int) Step) Step {
+	return NewNamedStep(fmt.Sprintf("Concurrently(%d)", n), func() error {
+		steps := make([]Step, n)
+		for idx := range steps {
+			steps[idx] = factory(idx)
+		}
+		errs := make([]error, n)
+		var ready, done sync.WaitGroup
+		start := make(chan struct{})
+		ready.Add(n)
+		done.Add(n)
+		for idx, step := range steps {
+			go func(idx int, step Step) {
+				defer done.Done()
+				defer func() {
+					if r := recover(); r != nil {
+						errs[idx] = fmt.Errorf("Panic: %v", r)
+					}
+				}()
+				ready.Done()
+				<-start
+				errs[idx] = step.Go()
+			}(idx, step)
+		}
+		ready.Wait()
+		close(start)
+		done.Wait()
+
+		failures := []StepFailure{}
+		for idx, err := range errs {
+			if err != nil {
+				failures = append(failures, StepFailure{Index: idx, Step: steps[idx], Err: err})
+			}
+		}
+		if len(failures) == 0 {
+			return nil
+		} else {
+			return &MultiError{Message: fmt.Sprintf("%d of %d concurrent steps failed", len(failures), n), Failures: failures}
+		}
+	})
+}
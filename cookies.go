@@ -0,0 +1,194 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// importedCookie is a cookie read from a cookie file, with the host
+// for which it was set.
+type importedCookie struct {
+	host     string
+	hostOnly bool
+	cookie   *http.Cookie
+}
+
+// jsonCookie is a cookie as exported by browser extensions such as
+// EditThisCookie and Cookie-Editor, or in a Playwright storage state
+// (which uses expires rather than expirationDate).
+type jsonCookie struct {
+	Domain         string   `json:"domain"`
+	HostOnly       *bool    `json:"hostOnly"`
+	Path           string   `json:"path"`
+	Secure         bool     `json:"secure"`
+	HttpOnly       bool     `json:"httpOnly"`
+	Session        bool     `json:"session"`
+	ExpirationDate *float64 `json:"expirationDate"`
+	Expires        *float64 `json:"expires"`
+	Name           string   `json:"name"`
+	Value          string   `json:"value"`
+}
+
+// LoadCookies reads the cookies in the file at path into jar, so that
+// the session of a browser, for example one logged in by hand, can be
+// used by automated scenarios. The file is either a Netscape cookie
+// file, as written by curl, wget and browser extensions such as "Get
+// cookies.txt", or a JSON export: an array of cookies, as written by
+// EditThisCookie and Cookie-Editor, or a Playwright storage state.
+// Cookies which have expired are skipped. The number of cookies loaded
+// is returned. Note cookie files hold credentials, so take care in
+// sharing them.
+func LoadCookies(jar http.CookieJar, path string) (int, error) {
+	bites, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var cookies []importedCookie
+	if trimmed := bytes.TrimSpace(bites); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		cookies, err = parseJSONCookies(trimmed)
+	} else {
+		cookies, err = parseNetscapeCookies(string(bites))
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Cookies %s: %v", path, err)
+	}
+	now := time.Now()
+	loaded := 0
+	for _, imported := range cookies {
+		cookie := imported.cookie
+		if !cookie.Expires.IsZero() && !cookie.Expires.After(now) {
+			continue
+		}
+		scheme := "http"
+		if cookie.Secure {
+			scheme = "https"
+		}
+		if cookie.Path == "" {
+			cookie.Path = "/"
+		}
+		if !imported.hostOnly {
+			cookie.Domain = imported.host
+		}
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: imported.host, Path: cookie.Path}, []*http.Cookie{cookie})
+		loaded++
+	}
+	return loaded, nil
+}
+
+// parseNetscapeCookies parses a Netscape cookie file: a line per
+// cookie of seven tab-separated fields (domain, whether subdomains are
+// included, path, whether secure, expiry in seconds since the epoch,
+// name and value), and comments beginning #. A domain prefixed
+// #HttpOnly_ marks an HttpOnly cookie.
+func parseNetscapeCookies(text string) ([]importedCookie, error) {
+	cookies := []importedCookie{}
+	for idx, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			line, httpOnly = strings.TrimPrefix(line, "#HttpOnly_"), true
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			fields = append(fields, "")
+		} else if len(fields) != 7 {
+			return nil, fmt.Errorf("Line %d: Expected 7 tab-separated fields; found %d.", idx+1, len(fields))
+		}
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: Expiry: %v", idx+1, err)
+		}
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if expiry > 0 {
+			cookie.Expires = time.Unix(expiry, 0)
+		}
+		cookies = append(cookies, importedCookie{
+			host:     strings.TrimPrefix(fields[0], "."),
+			hostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			cookie:   cookie,
+		})
+	}
+	return cookies, nil
+}
+
+// parseJSONCookies parses a JSON array of cookies, or an object with
+// the array as its "cookies", as a Playwright storage state has.
+func parseJSONCookies(bites []byte) ([]importedCookie, error) {
+	list := []jsonCookie{}
+	if bites[0] == '{' {
+		state := struct {
+			Cookies []jsonCookie `json:"cookies"`
+		}{}
+		if err := json.Unmarshal(bites, &state); err != nil {
+			return nil, err
+		}
+		list = state.Cookies
+	} else if err := json.Unmarshal(bites, &list); err != nil {
+		return nil, err
+	}
+	cookies := []importedCookie{}
+	for idx, jc := range list {
+		if jc.Domain == "" || jc.Name == "" {
+			return nil, fmt.Errorf("Cookie %d: Expected a domain and name; found %q and %q.", idx, jc.Domain, jc.Name)
+		}
+		cookie := &http.Cookie{Name: jc.Name, Value: jc.Value, Path: jc.Path, Secure: jc.Secure, HttpOnly: jc.HttpOnly}
+		expiry := jc.ExpirationDate
+		if expiry == nil {
+			expiry = jc.Expires
+		}
+		// Playwright marks session cookies with an expiry of -1.
+		if !jc.Session && expiry != nil && *expiry > 0 {
+			secs, frac := math.Modf(*expiry)
+			cookie.Expires = time.Unix(int64(secs), int64(frac*1e9))
+		}
+		hostOnly := !strings.HasPrefix(jc.Domain, ".")
+		if jc.HostOnly != nil {
+			hostOnly = *jc.HostOnly
+		}
+		cookies = append(cookies, importedCookie{host: strings.TrimPrefix(jc.Domain, "."), hostOnly: hostOnly, cookie: cookie})
+	}
+	return cookies, nil
+}
+
+// ImportCookies is a Step that when executed loads the cookies in the
+// file at path into hc.Client's Jar (see LoadCookies), creating a Jar
+// if it has none, so that subsequent requests are made as part of the
+// browser session the cookies were exported from. It errors if the
+// file contains no unexpired cookies.
+func (hc *HttpCall) ImportCookies(path string) Step {
+	return NewNamedStep(fmt.Sprintf("ImportCookies(%s)", path), func() error {
+		if hc.Client.Jar == nil {
+			jar, err := cookiejar.New(nil)
+			if err != nil {
+				return err
+			}
+			hc.Client.Jar = jar
+		}
+		if loaded, err := LoadCookies(hc.Client.Jar, path); err != nil {
+			return err
+		} else if loaded == 0 {
+			return errors.New("Cookies: Expected at least one unexpired cookie; found none.")
+		} else {
+			return nil
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package argot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImportCookies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "abc123" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "argot-cookies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	netscape := filepath.Join(dir, "cookies.txt")
+	if err := ioutil.WriteFile(netscape, []byte(strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		"#HttpOnly_127.0.0.1\tFALSE\t/\tFALSE\t0\tsession\tabc123",
+		"127.0.0.1\tFALSE\t/\tFALSE\t1\texpired\tgone",
+		"",
+	}, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	if err := (Steps{
+		hc.ImportCookies(netscape),
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseStatusEquals(200),
+	}).Go(); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	if cookies := hc.Client.Jar.Cookies(u); len(cookies) != 1 {
+		t.Fatalf("Expected the expired cookie to be skipped; found %v.", cookies)
+	}
+
+	export := filepath.Join(dir, "cookies.json")
+	if err := ioutil.WriteFile(export, []byte(`[
+		{"domain": ".example.com", "name": "sub", "value": "1", "path": "/", "secure": true, "expirationDate": 4102444800.5},
+		{"domain": "www.example.com", "hostOnly": true, "name": "host", "value": "2", "path": "/app", "session": true}
+	]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hc = NewHttpCall(nil)
+	if err := hc.ImportCookies(export).Go(); err != nil {
+		t.Fatal(err)
+	}
+	for urlStr, expected := range map[string]int{
+		"https://api.example.com/":      1,
+		"http://api.example.com/":       0,
+		"https://www.example.com/app/x": 2,
+		"https://www.example.com/":      1,
+	} {
+		u, _ := url.Parse(urlStr)
+		if cookies := hc.Client.Jar.Cookies(u); len(cookies) != expected {
+			t.Fatalf("%s: Expected %d cookies; found %v.", urlStr, expected, cookies)
+		}
+	}
+
+	state := filepath.Join(dir, "state.json")
+	if err := ioutil.WriteFile(state, []byte(`{"cookies": [{"domain": "example.com", "name": "pw", "value": "3", "path": "/", "expires": -1}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hc = NewHttpCall(nil)
+	if err := hc.ImportCookies(state).Go(); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := filepath.Join(dir, "bad.txt")
+	if err := ioutil.WriteFile(bad, []byte("example.com\tTRUE\t/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := hc.ImportCookies(bad).Go(); err == nil || !strings.Contains(err.Error(), "Line 1: Expected 7 tab-separated fields; found 3.") {
+		t.Fatalf("Expected a parse error; found %v.", err)
+	}
+	if err := hc.ImportCookies(filepath.Join(dir, "missing.txt")).Go(); err == nil {
+		t.Fatal("Expected an error for a missing file.")
+	}
+}
@@ -0,0 +1,259 @@
+package argot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CoordinationServer is a small HTTP server through which several
+// argot processes, for example a producer suite and a consumer suite
+// running on different hosts, synchronise the phases of a
+// cross-service scenario with the steps of Coordinator. Run it in one
+// of the processes, or on its own, and give its URL to all of them.
+//
+// A signal is raised once, with a value, and is then seen by every
+// process waiting for it. A barrier is passed once the given number
+// of distinct parties have arrived at it.
+type CoordinationServer struct {
+	// The base URL of the server, for example http://127.0.0.1:34567.
+	URL string
+
+	listener net.Listener
+	server   *http.Server
+	lock     sync.Mutex
+	signals  map[string]string
+	barriers map[string]*coordinationBarrier
+}
+
+type coordinationBarrier struct {
+	Parties int      `json:"parties"`
+	Arrived []string `json:"arrived"`
+}
+
+// NewCoordinationServer creates a new CoordinationServer listening on
+// addr. If addr is empty, a random port on the loopback interface is
+// used; to coordinate with other hosts, give an address they can
+// reach, such as ":7357". Close must be called to stop the server.
+func NewCoordinationServer(addr string) (*CoordinationServer, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	cs := &CoordinationServer{
+		URL:      "http://" + listener.Addr().String(),
+		listener: listener,
+		signals:  make(map[string]string),
+		barriers: make(map[string]*coordinationBarrier),
+	}
+	cs.server = &http.Server{Handler: http.HandlerFunc(cs.serveHTTP)}
+	go cs.server.Serve(listener)
+	return cs, nil
+}
+
+// Close stops the server.
+func (cs *CoordinationServer) Close() error {
+	return cs.server.Close()
+}
+
+// Reset forgets every signal and barrier, so that the server can be
+// used for another scenario.
+func (cs *CoordinationServer) Reset() {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.signals = make(map[string]string)
+	cs.barriers = make(map[string]*coordinationBarrier)
+}
+
+func (cs *CoordinationServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if name := strings.TrimPrefix(r.URL.Path, "/signals/"); name != r.URL.Path && name != "" {
+		switch r.Method {
+		case http.MethodGet:
+			if value, found := cs.signals[name]; found {
+				w.Write([]byte(value))
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			cs.signals[name] = string(body)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+
+	} else if name := strings.TrimPrefix(r.URL.Path, "/barriers/"); name != r.URL.Path && name != "" {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			parties, err := strconv.Atoi(r.URL.Query().Get("parties"))
+			party := r.URL.Query().Get("party")
+			if err != nil || parties < 1 || party == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			barrier, found := cs.barriers[name]
+			if !found {
+				barrier = &coordinationBarrier{Parties: parties, Arrived: []string{}}
+				cs.barriers[name] = barrier
+			} else if barrier.Parties != parties {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprintf(w, "Barrier %s: Expected %d parties; found %d.", name, barrier.Parties, parties)
+				return
+			}
+			arrived := false
+			for _, found := range barrier.Arrived {
+				arrived = arrived || found == party
+			}
+			if !arrived {
+				barrier.Arrived = append(barrier.Arrived, party)
+			}
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if barrier, found := cs.barriers[name]; !found {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(barrier)
+		}
+
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// Coordinator provides steps which synchronise with other argot
+// processes through a CoordinationServer.
+type Coordinator struct {
+	// The URL of the CoordinationServer.
+	URL string
+	// The name of this process, which must be distinct from those of
+	// the other processes arriving at the same barriers.
+	Party string
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// NewCoordinator creates a new Coordinator for party, using the
+// CoordinationServer at url.
+func NewCoordinator(url, party string) *Coordinator {
+	return &Coordinator{URL: url, Party: party}
+}
+
+func (c *Coordinator) do(method, path, body string) (int, []byte, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.URL, "/")+path, strings.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer response.Body.Close()
+	bites, err := ioutil.ReadAll(response.Body)
+	return response.StatusCode, bites, err
+}
+
+// Signal is a Step that when executed raises the signal called name,
+// with value, releasing every process waiting for it. Raising a
+// signal again replaces its value.
+func (c *Coordinator) Signal(name, value string) Step {
+	return NewNamedStep(fmt.Sprintf("Signal(%s)", name), func() error {
+		if status, body, err := c.do(http.MethodPut, "/signals/"+url.PathEscape(name), value); err != nil {
+			return fmt.Errorf("Signal %s: %v", name, err)
+		} else if status != http.StatusNoContent {
+			return fmt.Errorf("Signal %s: Status: Expected %d; found %d: '%s'.", name, http.StatusNoContent, status, string(body))
+		} else {
+			return nil
+		}
+	})
+}
+
+// WaitForSignal is a Step that when executed waits up to timeout for
+// the signal called name to be raised. If store is not nil, the
+// signal's value is stored in it as key, for example to pass the id of
+// a resource created by the process raising the signal.
+func (c *Coordinator) WaitForSignal(name string, timeout time.Duration, store *Store, key string) Step {
+	return NewNamedStep(fmt.Sprintf("WaitForSignal(%s)", name), func() error {
+		var value []byte
+		err := waitFor(timeout, func() error {
+			status, body, err := c.do(http.MethodGet, "/signals/"+url.PathEscape(name), "")
+			if err != nil {
+				return err
+			} else if status == http.StatusNotFound {
+				return fmt.Errorf("Signal %s not raised.", name)
+			} else if status != http.StatusOK {
+				return fmt.Errorf("Status: Expected %d; found %d: '%s'.", http.StatusOK, status, string(body))
+			}
+			value = body
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("Signal %s: %v", name, err)
+		}
+		if store != nil {
+			store.Set(key, string(value))
+		}
+		return nil
+	})
+}
+
+// Barrier is a Step that when executed arrives at the barrier called
+// name, and waits up to timeout for all of parties (including this
+// one) to arrive. Every party must give the same number of parties.
+func (c *Coordinator) Barrier(name string, parties int, timeout time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("Barrier(%s: %d)", name, parties), func() error {
+		query := url.Values{"parties": {strconv.Itoa(parties)}, "party": {c.Party}}
+		path := "/barriers/" + url.PathEscape(name)
+		barrier := coordinationBarrier{}
+		if status, body, err := c.do(http.MethodPost, path+"?"+query.Encode(), ""); err != nil {
+			return fmt.Errorf("Barrier %s: %v", name, err)
+		} else if status != http.StatusOK {
+			return fmt.Errorf("Barrier %s: Status: Expected %d; found %d: '%s'.", name, http.StatusOK, status, string(body))
+		} else if err := json.Unmarshal(body, &barrier); err != nil {
+			return fmt.Errorf("Barrier %s: %v", name, err)
+		}
+		err := waitFor(timeout, func() error {
+			if len(barrier.Arrived) >= parties {
+				return nil
+			}
+			status, body, err := c.do(http.MethodGet, path, "")
+			if err != nil {
+				return err
+			} else if status != http.StatusOK {
+				return fmt.Errorf("Status: Expected %d; found %d: '%s'.", http.StatusOK, status, string(body))
+			} else if err := json.Unmarshal(body, &barrier); err != nil {
+				return err
+			} else if len(barrier.Arrived) < parties {
+				return fmt.Errorf("Expected %d parties; found %d: %s.", parties, len(barrier.Arrived), strings.Join(barrier.Arrived, ", "))
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("Barrier %s: %v", name, err)
+		}
+		return nil
+	})
+}
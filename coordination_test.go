@@ -0,0 +1,54 @@
+package argot
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCoordination(t *testing.T) {
+	server, err := NewCoordinationServer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	producer := NewCoordinator(server.URL, "producer")
+	consumer := NewCoordinator(server.URL, "consumer")
+	store := NewStore()
+
+	var wg sync.WaitGroup
+	var producerErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		producerErr = Steps{
+			producer.Barrier("ready", 2, 5*time.Second),
+			producer.Signal("published", "order-42"),
+		}.Go()
+	}()
+	err = Steps{
+		consumer.Barrier("ready", 2, 5*time.Second),
+		consumer.WaitForSignal("published", 5*time.Second, store, "orderId"),
+	}.Go()
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	} else if producerErr != nil {
+		t.Fatal(producerErr)
+	} else if id := store.GetString("orderId"); id != "order-42" {
+		t.Fatalf("Expected order-42; found %s", id)
+	}
+
+	if err := producer.Barrier("ready", 3, time.Second).Go(); err == nil || !strings.Contains(err.Error(), "Expected 2 parties; found 3") {
+		t.Fatalf("Expected an error for mismatched parties; found %v", err)
+	}
+	if err := consumer.Barrier("alone", 2, 50*time.Millisecond).Go(); err == nil || !strings.Contains(err.Error(), "Not ready after") {
+		t.Fatalf("Expected a barrier timeout; found %v", err)
+	}
+	server.Reset()
+	if err := consumer.WaitForSignal("published", 50*time.Millisecond, nil, "").Go(); err == nil || !strings.Contains(err.Error(), "not raised") {
+		t.Fatalf("Expected a signal timeout after reset; found %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package argot
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	buf[6] = buf[6]&0x0f | 0x40
+	buf[8] = buf[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:]), nil
+}
+
+// CorrelationID returns the request ID most recently generated by
+// ExpectCorrelationID, or the empty string if there is none.
+func (hc *HttpCall) CorrelationID() string {
+	return hc.correlationID
+}
+
+// ExpectCorrelationID is a Step that when executed generates a random
+// request ID (a UUID), sets it as the header on hc.Request, ensures
+// there is a response, and errors unless the response echoes the ID
+// back in the same header. Each of logs (for example Process.Output)
+// must also contain the ID within logTimeout, so that a request can be
+// traced through the server's logs. The ID is available from
+// CorrelationID for later steps.
+func (hc *HttpCall) ExpectCorrelationID(header string, logTimeout time.Duration, logs ...func() string) Step {
+	return hc.step(fmt.Sprintf("ExpectCorrelationID(%s)", header), func() error {
+		if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
+			return err
+		}
+		id, err := newUUID()
+		if err != nil {
+			return err
+		}
+		hc.correlationID = id
+		hc.Request.Header.Set(header, id)
+		if err := hc.EnsureResponse(); err != nil {
+			return err
+		} else if echoed := hc.Response.Header.Get(header); echoed != id {
+			return fmt.Errorf("Header '%s': Expected the request ID '%s' to be echoed; found '%s'.", header, id, echoed)
+		}
+		for idx, log := range logs {
+			log := log
+			if err := waitFor(logTimeout, func() error {
+				if !strings.Contains(log(), id) {
+					return errors.New("not found")
+				}
+				return nil
+			}); err != nil {
+				return fmt.Errorf("Log %d: Expected the request ID '%s' within %v; found none.", idx+1, id, logTimeout)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,48 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExpectCorrelationID(t *testing.T) {
+	logs := new(syncBuffer)
+	echo := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if echo {
+			w.Header().Set("X-Request-ID", id)
+		}
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			logs.Write([]byte("handled request " + id + "\n"))
+		}()
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ExpectCorrelationID("X-Request-ID", time.Second, logs.String),
+		ExpectError(hc.ExpectCorrelationID("X-Request-ID", 0)),
+	}.Test(t)
+	if id := hc.CorrelationID(); len(id) != 36 || id[14] != '4' {
+		t.Fatalf("Expected a version 4 UUID; found '%s'", id)
+	}
+
+	hc.Reset()
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		ExpectError(hc.ExpectCorrelationID("X-Request-ID", 0, func() string { return "" })),
+	}.Test(t)
+
+	hc.Reset()
+	echo = false
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		ExpectError(hc.ExpectCorrelationID("X-Request-ID", 0)),
+	}.Test(t)
+}
@@ -0,0 +1,185 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy describes a cross-origin request and the policy the
+// server is expected to apply to it. See HttpCall.CORS.
+type CORSPolicy struct {
+	// Origin is sent as the Origin header.
+	Origin string
+	// Method is sent as Access-Control-Request-Method, and is the
+	// method of the actual request. If empty, GET is used.
+	Method string
+	// Headers are sent as Access-Control-Request-Headers.
+	Headers []string
+
+	// Allowed is true if the server is expected to permit the
+	// request. If false, the preflight is expected to be refused: no
+	// Access-Control-Allow-Origin matching Origin may be returned, and
+	// the remaining expectations are not checked.
+	Allowed bool
+	// AllowOrigin is the expected Access-Control-Allow-Origin. If
+	// empty, Origin is expected.
+	AllowOrigin string
+	// Credentials is true if Access-Control-Allow-Credentials: true
+	// is expected, and false if it is expected to be absent.
+	Credentials bool
+	// MaxAge, if non-zero, is the minimum expected
+	// Access-Control-Max-Age.
+	MaxAge time.Duration
+	// ExposeHeaders must all be listed in the
+	// Access-Control-Expose-Headers of the actual response.
+	ExposeHeaders []string
+
+	// Actual is true if, after the preflight, the actual request
+	// should be made (without a body) and its response checked.
+	Actual bool
+}
+
+func (cp CORSPolicy) method() string {
+	if cp.Method == "" {
+		return http.MethodGet
+	} else {
+		return cp.Method
+	}
+}
+
+func (cp CORSPolicy) allowOrigin() string {
+	if cp.AllowOrigin == "" {
+		return cp.Origin
+	} else {
+		return cp.AllowOrigin
+	}
+}
+
+// corsListIncludes returns the elements of expected missing from the
+// comma separated header value list. A "*" in list includes
+// everything, unless credentials are allowed, in which case browsers
+// treat it literally.
+func corsListIncludes(list string, expected []string, credentials bool) []string {
+	found := make(map[string]bool)
+	for _, item := range strings.Split(list, ",") {
+		found[strings.ToLower(strings.TrimSpace(item))] = true
+	}
+	if found["*"] && !credentials {
+		return nil
+	}
+	missing := []string{}
+	for _, item := range expected {
+		if !found[strings.ToLower(item)] {
+			missing = append(missing, item)
+		}
+	}
+	return missing
+}
+
+func (hc *HttpCall) checkCORSOrigin(policy CORSPolicy) error {
+	header := hc.Response.Header
+	if found := header.Get("Access-Control-Allow-Origin"); found != policy.allowOrigin() {
+		return fmt.Errorf("CORS Access-Control-Allow-Origin: Expected %q; found %q.", policy.allowOrigin(), found)
+	} else if found == "*" && policy.Credentials {
+		return fmt.Errorf("CORS Access-Control-Allow-Origin: Expected %q; found \"*\", which is not permitted with credentials.", policy.Origin)
+	} else if credentials := header.Get("Access-Control-Allow-Credentials") == "true"; credentials != policy.Credentials {
+		return fmt.Errorf("CORS Access-Control-Allow-Credentials: Expected %v; found %q.", policy.Credentials, header.Get("Access-Control-Allow-Credentials"))
+	} else if found != "*" && !corsVaryOrigin(header) {
+		return fmt.Errorf("CORS Vary: Expected to include Origin; found %q.", strings.Join(header["Vary"], ", "))
+	} else {
+		return nil
+	}
+}
+
+// corsVaryOrigin returns true iff header includes Origin in Vary, as
+// it must when Access-Control-Allow-Origin depends on the Origin.
+func corsVaryOrigin(header http.Header) bool {
+	return len(corsListIncludes(strings.Join(header["Vary"], ","), []string{"Origin"}, true)) == 0
+}
+
+func (hc *HttpCall) checkCORSPreflight(policy CORSPolicy) error {
+	header := hc.Response.Header
+	method, credentials := policy.method(), policy.Credentials
+	if hc.Response.StatusCode < 200 || hc.Response.StatusCode > 299 {
+		return fmt.Errorf("CORS preflight status: Expected 2xx; found %d.", hc.Response.StatusCode)
+	} else if err := hc.checkCORSOrigin(policy); err != nil {
+		return err
+	} else if allowed := header.Get("Access-Control-Allow-Methods"); !corsSimpleMethod(method) && len(corsListIncludes(allowed, []string{method}, credentials)) != 0 {
+		return fmt.Errorf("CORS Access-Control-Allow-Methods: Expected to include %s; found %q.", method, allowed)
+	} else if allowed := header.Get("Access-Control-Allow-Headers"); len(corsListIncludes(allowed, policy.Headers, credentials)) != 0 {
+		return fmt.Errorf("CORS Access-Control-Allow-Headers: Expected to include %s; found %q.", strings.Join(policy.Headers, ", "), allowed)
+	} else if maxAge := header.Get("Access-Control-Max-Age"); policy.MaxAge > 0 && corsMaxAge(maxAge) < policy.MaxAge {
+		return fmt.Errorf("CORS Access-Control-Max-Age: Expected at least %v; found %q.", policy.MaxAge, maxAge)
+	} else {
+		return nil
+	}
+}
+
+func corsMaxAge(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err != nil {
+		return 0
+	} else {
+		return time.Duration(seconds) * time.Second
+	}
+}
+
+// corsSimpleMethod returns true iff method need not be listed in
+// Access-Control-Allow-Methods.
+func corsSimpleMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodPost
+}
+
+// CORS is a Step that when executed issues a CORS preflight to urlStr:
+// an OPTIONS request with the Origin, Access-Control-Request-Method
+// and Access-Control-Request-Headers of policy. If policy.Allowed, it
+// errors unless the response has a 2xx status and
+// Access-Control-Allow-* headers that permit the request as described
+// by policy (including Vary: Origin when the allowed origin is not
+// "*"). Otherwise it errors if the response would permit the request.
+// If policy.Actual, the actual request is then made with Origin set,
+// and its Access-Control-Allow-Origin, -Credentials and
+// -Expose-Headers are checked. Either way, hc is left holding the
+// last response so that further steps can inspect it.
+func (hc *HttpCall) CORS(urlStr string, policy CORSPolicy) Step {
+	name := fmt.Sprintf("CORS(%s %s from %s)", policy.method(), hc.redactor().String(urlStr), policy.Origin)
+	return hc.step(name, func() error {
+		preflight := Steps{
+			hc.NewRequest(http.MethodOptions, urlStr, nil),
+			hc.RequestHeader("Origin", policy.Origin),
+			hc.RequestHeader("Access-Control-Request-Method", policy.method()),
+		}
+		if len(policy.Headers) != 0 {
+			preflight = append(preflight, hc.RequestHeader("Access-Control-Request-Headers", strings.ToLower(strings.Join(policy.Headers, ","))))
+		}
+		if err := append(preflight, hc.Call()).Go(); err != nil {
+			return err
+		} else if !policy.Allowed {
+			if found := hc.Response.Header.Get("Access-Control-Allow-Origin"); found == policy.Origin || found == "*" {
+				return fmt.Errorf("CORS Access-Control-Allow-Origin: Expected origin %s to be refused; found %q.", policy.Origin, found)
+			}
+			return nil
+		} else if err := hc.checkCORSPreflight(policy); err != nil {
+			return err
+		} else if !policy.Actual {
+			return nil
+		}
+
+		actual := Steps{
+			hc.NewRequest(policy.method(), urlStr, nil),
+			hc.RequestHeader("Origin", policy.Origin),
+			hc.Call(),
+		}
+		if err := actual.Go(); err != nil {
+			return err
+		} else if err := hc.checkCORSOrigin(policy); err != nil {
+			return err
+		} else if exposed := hc.Response.Header.Get("Access-Control-Expose-Headers"); len(corsListIncludes(exposed, policy.ExposeHeaders, policy.Credentials)) != 0 {
+			return fmt.Errorf("CORS Access-Control-Expose-Headers: Expected to include %s; found %q.", strings.Join(policy.ExposeHeaders, ", "), exposed)
+		} else {
+			return nil
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin == "https://app.example.com" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Add("Vary", "Origin")
+			if r.Method == http.MethodOptions {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, DELETE")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+				w.Header().Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.Header().Set("Access-Control-Expose-Headers", "ETag")
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	allowed := CORSPolicy{
+		Origin:        "https://app.example.com",
+		Method:        "PUT",
+		Headers:       []string{"Authorization"},
+		Allowed:       true,
+		Credentials:   true,
+		MaxAge:        5 * time.Minute,
+		ExposeHeaders: []string{"etag"},
+		Actual:        true,
+	}
+	headers := allowed
+	headers.Headers = []string{"X-Custom"}
+	patch := allowed
+	patch.Method = "PATCH"
+	maxAge := allowed
+	maxAge.MaxAge = time.Hour
+	expose := allowed
+	expose.ExposeHeaders = []string{"Location"}
+	Steps{
+		hc.CORS(server.URL, allowed),
+		hc.ResponseStatusEquals(http.StatusOK),
+		hc.CORS(server.URL, CORSPolicy{Origin: "https://evil.example.com", Method: "DELETE"}),
+		ExpectError(hc.CORS(server.URL, CORSPolicy{Origin: "https://app.example.com", Method: "DELETE"})),
+		ExpectError(hc.CORS(server.URL, CORSPolicy{Origin: "https://evil.example.com", Allowed: true})),
+		ExpectError(hc.CORS(server.URL, headers)),
+		ExpectError(hc.CORS(server.URL, patch)),
+		ExpectError(hc.CORS(server.URL, maxAge)),
+		ExpectError(hc.CORS(server.URL, expose)),
+	}.Test(t)
+}
@@ -0,0 +1,248 @@
+package argot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Coverage tracks, per endpoint, which parts of the responses received
+// were asserted on, so that endpoints whose responses aren't
+// meaningfully checked can be found. Set it as HttpCall.Coverage (the
+// same Coverage may be shared by many HttpCalls, and is safe for
+// concurrent use), run the suite, and then inspect Report or
+// WriteReport.
+//
+// A response's surface is its status, its headers (except those in
+// IgnoreHeaders) and, for JSON bodies, every field, named as for
+// JSONPath but with array indices elided: "status", "header:ETag",
+// "body:items[].sku". Steps which check a whole JSON value (such as
+// ResponseBodyJSONPathEquals) cover every field within it; steps
+// which check the whole body (such as ResponseBodyEquals) cover the
+// whole surface of the body. Values captured with CaptureJSON and
+// CaptureHeader count as asserted. Endpoints are identified by method
+// and path, with numeric and UUID path segments replaced by {id}.
+type Coverage struct {
+	// Response headers which are not part of the surface. Canonical
+	// header keys, as for http.CanonicalHeaderKey.
+	IgnoreHeaders map[string]bool
+
+	lock      sync.Mutex
+	endpoints map[string]*endpointCoverage
+}
+
+type endpointCoverage struct {
+	observed map[string]bool
+	asserted map[string]bool
+}
+
+// EndpointCoverage summarises the coverage of one endpoint.
+type EndpointCoverage struct {
+	Endpoint string
+	// The number of parts of the surface observed and asserted.
+	Observed, Asserted int
+	// The observed parts of the surface which were never asserted,
+	// sorted.
+	Unasserted []string
+}
+
+// NewCoverage creates a new Coverage which ignores headers that
+// describe the transport rather than the resource.
+func NewCoverage() *Coverage {
+	return &Coverage{
+		IgnoreHeaders: map[string]bool{
+			"Connection":        true,
+			"Content-Length":    true,
+			"Date":              true,
+			"Keep-Alive":        true,
+			"Server":            true,
+			"Transfer-Encoding": true,
+		},
+		endpoints: make(map[string]*endpointCoverage),
+	}
+}
+
+// coverageEndpoint returns the endpoint of req.
+func coverageEndpoint(req *http.Request) string {
+	segments := strings.Split(req.URL.Path, "/")
+	for idx, segment := range segments {
+		if uuidPattern.MatchString(segment) || segment != "" && strings.Trim(segment, "0123456789") == "" {
+			segments[idx] = "{id}"
+		}
+	}
+	return req.Method + " " + strings.Join(segments, "/")
+}
+
+func (cov *Coverage) endpoint(req *http.Request) *endpointCoverage {
+	key := coverageEndpoint(req)
+	endpoint, found := cov.endpoints[key]
+	if !found {
+		endpoint = &endpointCoverage{observed: make(map[string]bool), asserted: make(map[string]bool)}
+		cov.endpoints[key] = endpoint
+	}
+	return endpoint
+}
+
+// observeResponse records the status and headers of a response.
+func (cov *Coverage) observeResponse(req *http.Request, response *http.Response) {
+	cov.lock.Lock()
+	defer cov.lock.Unlock()
+	endpoint := cov.endpoint(req)
+	endpoint.observed["status"] = true
+	for key := range response.Header {
+		if !cov.IgnoreHeaders[key] {
+			endpoint.observed["header:"+key] = true
+		}
+	}
+}
+
+// observeBody records the fields of a JSON body. Bodies which are not
+// JSON have no fields, and are covered by the whole body steps.
+func (cov *Coverage) observeBody(req *http.Request, body []byte) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return
+	}
+	cov.lock.Lock()
+	defer cov.lock.Unlock()
+	for _, field := range jsonFields(doc, "body:") {
+		cov.endpoint(req).observed[field] = true
+	}
+}
+
+// jsonFields returns the names of the leaf values of doc, prefixed
+// with prefix.
+func jsonFields(doc interface{}, prefix string) []string {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return []string{prefix}
+		}
+		fields := []string{}
+		for key, elem := range v {
+			if strings.HasSuffix(prefix, ":") {
+				fields = append(fields, jsonFields(elem, prefix+key)...)
+			} else {
+				fields = append(fields, jsonFields(elem, prefix+"."+key)...)
+			}
+		}
+		return fields
+	case []interface{}:
+		fields := []string{}
+		for _, elem := range v {
+			fields = append(fields, jsonFields(elem, prefix+"[]")...)
+		}
+		if len(fields) == 0 {
+			return []string{prefix + "[]"}
+		}
+		return fields
+	default:
+		return []string{prefix}
+	}
+}
+
+// assert records that the given parts of the surface of req's endpoint
+// were asserted.
+func (cov *Coverage) assert(req *http.Request, parts ...string) {
+	cov.lock.Lock()
+	defer cov.lock.Unlock()
+	endpoint := cov.endpoint(req)
+	for _, part := range parts {
+		endpoint.asserted[part] = true
+	}
+}
+
+// covered reports whether the observed part is covered by an asserted
+// part: either the same, or within it.
+func covered(observed string, asserted map[string]bool) bool {
+	if asserted[observed] {
+		return true
+	}
+	for part := range asserted {
+		if strings.HasPrefix(observed, part) {
+			if rest := observed[len(part):]; strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, "[") || strings.HasSuffix(part, ":") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Report returns the coverage of every endpoint, sorted by endpoint.
+func (cov *Coverage) Report() []EndpointCoverage {
+	cov.lock.Lock()
+	defer cov.lock.Unlock()
+	report := []EndpointCoverage{}
+	for key, endpoint := range cov.endpoints {
+		summary := EndpointCoverage{Endpoint: key, Observed: len(endpoint.observed), Unasserted: []string{}}
+		for part := range endpoint.observed {
+			if covered(part, endpoint.asserted) {
+				summary.Asserted++
+			} else {
+				summary.Unasserted = append(summary.Unasserted, part)
+			}
+		}
+		sort.Strings(summary.Unasserted)
+		report = append(report, summary)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Endpoint < report[j].Endpoint })
+	return report
+}
+
+// WriteReport writes the coverage of every endpoint, listing the
+// unasserted surface of each.
+func (cov *Coverage) WriteReport(w io.Writer) error {
+	for _, endpoint := range cov.Report() {
+		if _, err := fmt.Fprintf(w, "%s: %d of %d asserted\n", endpoint.Endpoint, endpoint.Asserted, endpoint.Observed); err != nil {
+			return err
+		}
+		for _, part := range endpoint.Unasserted {
+			if _, err := fmt.Fprintf(w, "    unasserted %s\n", part); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cover records, if hc.Coverage is set, that the given parts of the
+// surface of the current request's endpoint were asserted.
+func (hc *HttpCall) cover(parts ...string) {
+	if hc.Coverage != nil && hc.Request != nil {
+		hc.Coverage.assert(hc.Request, parts...)
+	}
+}
+
+// coverHeader records that the header key was asserted.
+func (hc *HttpCall) coverHeader(key string) {
+	hc.cover("header:" + http.CanonicalHeaderKey(key))
+}
+
+// coverJSONPath records that the value at path (see JSONPath), and so
+// everything within it, was asserted.
+func (hc *HttpCall) coverJSONPath(path string) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	tokens := splitPath(path)
+	for idx, token := range tokens {
+		if strings.HasPrefix(token, "[") {
+			tokens[idx] = "[]"
+		} else if idx > 0 {
+			tokens[idx] = "." + token
+		}
+	}
+	hc.cover("body:" + strings.Join(tokens, ""))
+}
+
+// coverJSONValue records that every field of expected, as encoded to
+// JSON, was asserted.
+func (hc *HttpCall) coverJSONValue(expected interface{}) {
+	if hc.Coverage == nil {
+		return
+	} else if doc, err := normaliseJSON(expected); err == nil && doc != nil {
+		hc.cover(jsonFields(doc, "body:")...)
+	}
+}
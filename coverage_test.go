@@ -0,0 +1,61 @@
+package argot
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	hc := NewHandlerCall(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"id":7,"name":"alice","address":{"city":"Leeds","postcode":"LS1"},"orders":[{"id":1,"total":5},{"id":2,"total":6}]}`))
+	}))
+	defer hc.Reset()
+	hc.Coverage = NewCoverage()
+
+	type address struct {
+		City string `json:"city"`
+	}
+	Steps{
+		hc.NewRequest("GET", "http://example.com/users/7", nil),
+		hc.ResponseStatusEquals(200),
+		hc.ResponseHeaderEquals("content-type", "application/json"),
+		hc.ResponseBodyJSONPathEquals("id", 7),
+		hc.ResponseBodyJSONPathEquals("orders[0].id", 1),
+		hc.ResponseBodyJSONPathExists("address"),
+	}.Test(t)
+	hc.Reset()
+	Steps{
+		hc.NewRequest("GET", "http://example.com/users/8/address", nil),
+		hc.ResponseBodyJSONMatchesStruct(struct {
+			Address address `json:"address"`
+		}{Address: address{City: "Leeds"}}),
+		ExpectError(hc.ResponseBodyEquals("")),
+	}.Test(t)
+	hc.Reset()
+	Steps{
+		hc.NewRequest("GET", "http://example.com/users/3f1c2a9e-8b7d-4c6e-9f0a-1b2c3d4e5f60/address", nil),
+		hc.Call(),
+	}.Test(t)
+
+	report := hc.Coverage.Report()
+	if len(report) != 2 {
+		t.Fatalf("Expected 2 endpoints; found %+v", report)
+	}
+	if users := report[0]; users.Endpoint != "GET /users/{id}" || users.Observed != 9 || users.Asserted != 6 || strings.Join(users.Unasserted, ",") != "body:name,body:orders[].total,header:Etag" {
+		t.Fatalf("Unexpected coverage: %+v", users)
+	}
+	if address := report[1]; address.Endpoint != "GET /users/{id}/address" || address.Asserted != 6 || strings.Join(address.Unasserted, ",") != "header:Content-Type,header:Etag,status" {
+		t.Fatalf("Unexpected coverage: %+v", address)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := hc.Coverage.WriteReport(buf); err != nil {
+		t.Fatal(err)
+	} else if !strings.HasPrefix(buf.String(), "GET /users/{id}: 6 of 9 asserted\n    unasserted body:name\n") {
+		t.Fatalf("Unexpected report:\n%s", buf)
+	}
+}
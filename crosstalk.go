@@ -0,0 +1,145 @@
+package argot
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CrossTalk is a Step that when executed sends copies (at least two)
+// of the request built by earlier steps concurrently, each with a
+// distinct marker, and errors unless every response echoes the marker
+// of its own request. It catches proxies and servers which mix up
+// concurrent requests, for example by reusing a connection whose
+// response has not been fully read, or by keeping per-request context
+// in shared state. place says where the marker is put in the request:
+// "header:<name>" or "query:<name>". echo says where it must be found
+// in the response: "header:<name>", "json:<path>" (see JSONPath) or
+// "body", in which case the body must contain it. One of the requests
+// is sent by hc, which is left holding its response, and the others by
+// clones of hc (see Clone). Like Call, it must be used after the
+// request has been built, and before any step which needs the
+// response. The step errors if any copy cannot be sent, listing every
+// response which did not correspond to its request, and the request
+// whose marker it carried instead, if any.
+func (hc *HttpCall) CrossTalk(copies int, place, echo string) Step {
+	if copies < 2 {
+		copies = 2
+	}
+	return hc.step(fmt.Sprintf("CrossTalk(%d: %s, %s)", copies, place, echo), func() error {
+		if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
+			return err
+		} else if _, _, err := parsePlace(place); err != nil {
+			return fmt.Errorf("Cross-talk: Unknown marker placement '%s'.", place)
+		} else if echo != "body" && !strings.HasPrefix(echo, "header:") && !strings.HasPrefix(echo, "json:") {
+			return fmt.Errorf("Cross-talk: Unknown echo source '%s'.", echo)
+		}
+		ns := NewNamespace("crosstalk")
+		width := len(fmt.Sprint(copies - 1))
+		calls := []*HttpCall{hc}
+		markers := make([]string, copies)
+		defer func() {
+			for _, call := range calls[1:] {
+				call.Reset()
+			}
+		}()
+		for idx := range markers {
+			markers[idx] = ns.Name(fmt.Sprintf("%0*d", width, idx))
+			if idx > 0 {
+				if clone, err := hc.Clone(); err != nil {
+					return err
+				} else {
+					calls = append(calls, clone)
+				}
+			}
+			if err := placeValue(calls[idx].Request, place, markers[idx]); err != nil {
+				return fmt.Errorf("Cross-talk: %v", err)
+			}
+		}
+		if err := Concurrently(copies, func(i int) Step { return calls[i].Call() }).Go(); err != nil {
+			return fmt.Errorf("Cross-talk: %v", err)
+		}
+
+		mismatches := []string{}
+		for idx, call := range calls {
+			found, err := call.crossTalkMarker(echo, markers[idx], markers)
+			if err != nil {
+				mismatches = append(mismatches, fmt.Sprintf("[%d] %v", idx, err))
+			} else if found == markers[idx] {
+				continue
+			} else if owner := indexOf(markers, found); owner >= 0 {
+				mismatches = append(mismatches, fmt.Sprintf("[%d] Expected marker %s; found %s, of request %d.", idx, markers[idx], found, owner))
+			} else {
+				mismatches = append(mismatches, fmt.Sprintf("[%d] Expected marker %s; found '%s'.", idx, markers[idx], found))
+			}
+		}
+		if len(mismatches) != 0 {
+			return fmt.Errorf("Cross-talk: %d of %d responses did not correspond to their requests:\n\t%s", len(mismatches), copies, strings.Join(mismatches, "\n\t"))
+		}
+		return nil
+	})
+}
+
+// parsePlace splits place, "header:<name>" or "query:<name>", into
+// its kind ("header" or "query") and name.
+func parsePlace(place string) (kind, name string, err error) {
+	if idx := strings.Index(place, ":"); idx < 0 || idx == len(place)-1 {
+		return "", "", fmt.Errorf("Unknown placement '%s'.", place)
+	} else if kind, name = place[:idx], place[idx+1:]; kind != "header" && kind != "query" {
+		return "", "", fmt.Errorf("Unknown placement '%s'.", place)
+	} else {
+		return kind, name, nil
+	}
+}
+
+// placeValue sets value in req at place: "header:<name>" or
+// "query:<name>".
+func placeValue(req *http.Request, place, value string) error {
+	kind, name, err := parsePlace(place)
+	if err != nil {
+		return err
+	} else if kind == "header" {
+		req.Header.Set(name, value)
+	} else {
+		query := req.URL.Query()
+		query.Set(name, value)
+		req.URL.RawQuery = query.Encode()
+	}
+	return nil
+}
+
+// crossTalkMarker returns the marker echoed by hc.Response at echo. For
+// "body", it is marker if the body contains it, and otherwise the
+// first of markers it contains, if any.
+func (hc *HttpCall) crossTalkMarker(echo, marker string, markers []string) (string, error) {
+	if strings.HasPrefix(echo, "header:") {
+		return hc.Response.Header.Get(strings.TrimPrefix(echo, "header:")), nil
+	} else if strings.HasPrefix(echo, "json:") {
+		if value, err := hc.responseJSONPath(strings.TrimPrefix(echo, "json:")); err != nil {
+			return "", err
+		} else {
+			return fmt.Sprint(value), nil
+		}
+	} else if err := hc.ReceiveBody(); err != nil {
+		return "", err
+	} else if bytes.Contains(hc.ResponseBody, []byte(marker)) {
+		return marker, nil
+	}
+	for _, other := range markers {
+		if bytes.Contains(hc.ResponseBody, []byte(other)) {
+			return other, nil
+		}
+	}
+	return "", nil
+}
+
+// indexOf returns the index of str in strs, or -1.
+func indexOf(strs []string, str string) int {
+	for idx, elem := range strs {
+		if elem == str {
+			return idx
+		}
+	}
+	return -1
+}
@@ -0,0 +1,64 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCrossTalk(t *testing.T) {
+	var lock sync.Mutex
+	last := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		marker := r.Header.Get("X-Request-Id")
+		if r.URL.Path == "/mixed" {
+			// Keeps the marker in shared state, so concurrent requests
+			// receive each other's.
+			lock.Lock()
+			if last != "" {
+				marker, last = last, marker
+			} else {
+				last = marker
+			}
+			lock.Unlock()
+		} else if r.URL.Path == "/query" {
+			marker = r.URL.Query().Get("marker")
+		}
+		w.Header().Set("X-Request-Id", marker)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"request":{"id":"` + marker + `"}}`))
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	if err := (Steps{
+		hc.NewRequest(http.MethodGet, server.URL+"/echo", nil),
+		hc.CrossTalk(8, "header:X-Request-Id", "header:X-Request-Id"),
+		hc.ResponseStatusEquals(http.StatusOK),
+		hc.NewRequest(http.MethodGet, server.URL+"/query?tea=green", nil),
+		hc.CrossTalk(12, "query:marker", "json:request.id"),
+		hc.NewRequest(http.MethodGet, server.URL+"/query", nil),
+		hc.CrossTalk(3, "query:marker", "body"),
+	}).Go(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Steps{
+		hc.NewRequest(http.MethodGet, server.URL+"/mixed", nil),
+		hc.CrossTalk(4, "header:X-Request-Id", "json:request.id"),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "responses did not correspond to their requests:") ||
+		!strings.Contains(err.Error(), ", of request ") {
+		t.Fatalf("Expected cross-talk to be reported; found %v", err)
+	}
+
+	err = Steps{
+		hc.NewRequest(http.MethodGet, server.URL+"/echo", nil),
+		hc.CrossTalk(2, "cookie:marker", "body"),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "Cross-talk: Unknown marker placement 'cookie:marker'.") {
+		t.Fatalf("Expected the placement to be rejected; found %v", err)
+	}
+}
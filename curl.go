@@ -0,0 +1,66 @@
+package argot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// shellQuote quotes s for use as a single argument in a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// AsCurl returns a curl command line which, when pasted into a shell,
+// makes the same request as hc.Request. The request body is only
+// included if it can be re-read (see http.Request.GetBody). If there
+// is no request, the empty string is returned. Note that the command
+// is not redacted, and so may contain credentials.
+func (hc *HttpCall) AsCurl() string {
+	return hc.asCurl(nil)
+}
+
+// asCurl builds the curl command line, redacting it if redactor is
+// non-nil.
+func (hc *HttpCall) asCurl(redactor *Redactor) string {
+	req := hc.Request
+	if req == nil {
+		return ""
+	}
+	args := []string{"curl"}
+	if req.Method != "" && req.Method != "GET" {
+		args = append(args, "-X", shellQuote(req.Method))
+	}
+	args = append(args, shellQuote(req.URL.String()))
+
+	keys := make([]string, 0, len(req.Header))
+	for key := range req.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range req.Header[key] {
+			if redactor != nil {
+				value = redactor.HeaderValue(key, value)
+			}
+			args = append(args, "-H", shellQuote(fmt.Sprintf("%s: %s", key, value)))
+		}
+	}
+	if req.Host != "" && req.Host != req.URL.Host {
+		args = append(args, "-H", shellQuote("Host: "+req.Host))
+	}
+
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			defer body.Close()
+			if bites, err := ioutil.ReadAll(body); err == nil && len(bites) > 0 {
+				args = append(args, "--data-binary", shellQuote(string(bites)))
+			}
+		}
+	}
+	if redactor != nil {
+		return redactor.String(strings.Join(args, " "))
+	}
+	return strings.Join(args, " ")
+}
@@ -0,0 +1,27 @@
+package argot
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAsCurl(t *testing.T) {
+	hc := NewHttpCall(nil)
+	_, err := Steps{
+		hc.NewRequest("POST", "http://localhost:1/it's", strings.NewReader(`{"a": 1}`)),
+		hc.RequestHeader("Content-Type", "application/json"),
+	}.Test(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `curl -X 'POST' 'http://localhost:1/it'\''s' -H 'Content-Type: application/json' --data-binary '{"a": 1}'`
+	if curl := hc.AsCurl(); curl != expected {
+		t.Fatalf("Expected %s; found %s", expected, curl)
+	}
+
+	err = hc.ResponseStatusEquals(http.StatusOK).Go()
+	if hcErr, ok := err.(*HttpCallError); !ok || hcErr.Curl != expected {
+		t.Fatalf("Expected failure to include curl command; found %v", err)
+	}
+}
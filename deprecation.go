@@ -0,0 +1,129 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeprecationNotice is a deprecation announced by a response, in a
+// Deprecation (RFC 9745), Sunset (RFC 8594) or Warning header.
+type DeprecationNotice struct {
+	// The endpoint, identified as by Coverage, for example
+	// "GET /teas/{id}".
+	Endpoint string
+	Header   string
+	Value    string
+	// The time at which the endpoint will stop working, if the header
+	// is Sunset and its value is a valid HTTP date.
+	Sunset time.Time
+}
+
+func (dn DeprecationNotice) String() string {
+	str := fmt.Sprintf("%s: %s: %s", dn.Endpoint, dn.Header, dn.Value)
+	if !dn.Sunset.IsZero() {
+		if days := int(time.Until(dn.Sunset).Hours() / 24); days >= 0 {
+			str += fmt.Sprintf(" (in %d days)", days)
+		} else {
+			str += " (passed)"
+		}
+	}
+	return str
+}
+
+// DeprecationWatchdog gives early warning of deprecations announced by
+// the APIs a suite uses, from the responses its scenarios already
+// receive: each response checked with ResponseNotDeprecated (typically
+// as an invariant of every call, see ResponseInvariants) is searched
+// for the Headers, and the notices found are recorded, so that they
+// can be reported at the end of the run (see Notices and Summary).
+// With Fail set, a notice also fails the step. A DeprecationWatchdog is
+// safe for concurrent use.
+type DeprecationWatchdog struct {
+	// If true, ResponseNotDeprecated errors when it finds a notice.
+	Fail bool
+	// The headers which announce deprecations.
+	Headers []string
+
+	lock    sync.Mutex
+	notices map[DeprecationNotice]bool
+}
+
+// NewDeprecationWatchdog creates a new DeprecationWatchdog which
+// watches the Deprecation, Sunset and Warning headers, and fails steps
+// which find them if fail is true.
+func NewDeprecationWatchdog(fail bool) *DeprecationWatchdog {
+	return &DeprecationWatchdog{
+		Fail:    fail,
+		Headers: []string{"Deprecation", "Sunset", "Warning"},
+		notices: make(map[DeprecationNotice]bool),
+	}
+}
+
+// Notices returns every distinct notice recorded, sorted by endpoint
+// and header.
+func (dw *DeprecationWatchdog) Notices() []DeprecationNotice {
+	dw.lock.Lock()
+	defer dw.lock.Unlock()
+	notices := make([]DeprecationNotice, 0, len(dw.notices))
+	for notice := range dw.notices {
+		notices = append(notices, notice)
+	}
+	sort.Slice(notices, func(i, j int) bool {
+		if notices[i].Endpoint != notices[j].Endpoint {
+			return notices[i].Endpoint < notices[j].Endpoint
+		} else if notices[i].Header != notices[j].Header {
+			return notices[i].Header < notices[j].Header
+		} else {
+			return notices[i].Value < notices[j].Value
+		}
+	})
+	return notices
+}
+
+// Summary returns the notices recorded, one per line, or the empty
+// string if there are none.
+func (dw *DeprecationWatchdog) Summary() string {
+	notices := dw.Notices()
+	if len(notices) == 0 {
+		return ""
+	}
+	lines := make([]string, len(notices))
+	for idx, notice := range notices {
+		lines[idx] = notice.String()
+	}
+	return fmt.Sprintf("Deprecations (%d):\n\t%s\n", len(notices), strings.Join(lines, "\n\t"))
+}
+
+// ResponseNotDeprecated is a Step that when executed records, in dw,
+// a notice for each value of each of dw.Headers in hc.Response. If
+// dw.Fail is set, it errors if it finds any.
+func (hc *HttpCall) ResponseNotDeprecated(dw *DeprecationWatchdog) Step {
+	return hc.step("ResponseNotDeprecated", func() error {
+		if err := hc.EnsureResponse(); err != nil {
+			return err
+		}
+		endpoint := coverageEndpoint(hc.Request)
+		found := []string{}
+		dw.lock.Lock()
+		for _, header := range dw.Headers {
+			header = http.CanonicalHeaderKey(header)
+			for _, value := range hc.Response.Header.Values(header) {
+				notice := DeprecationNotice{Endpoint: endpoint, Header: header, Value: value}
+				if header == "Sunset" {
+					notice.Sunset, _ = http.ParseTime(value)
+				}
+				dw.notices[notice] = true
+				found = append(found, notice.String())
+			}
+		}
+		dw.lock.Unlock()
+		if dw.Fail && len(found) != 0 {
+			return fmt.Errorf("Deprecated: %s", strings.Join(found, "; "))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,57 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeprecationWatchdog(t *testing.T) {
+	sunset := time.Now().Add(72 * time.Hour).UTC().Format(http.TimeFormat)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/") {
+			w.Header().Set("Deprecation", "@1688169599")
+			w.Header().Set("Sunset", sunset)
+		} else if r.URL.Path == "/legacy" {
+			w.Header().Add("Warning", `299 - "Deprecated API"`)
+		}
+	}))
+	defer server.Close()
+
+	watchdog := NewDeprecationWatchdog(false)
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	hc.ResponseInvariants(hc.ResponseNotDeprecated(watchdog))
+	Steps{
+		hc.NewRequest("GET", server.URL+"/v1/teas/1", nil), hc.ResponseStatusEquals(200),
+		hc.NewRequest("GET", server.URL+"/v1/teas/2", nil), hc.ResponseStatusEquals(200),
+		hc.NewRequest("GET", server.URL+"/legacy", nil), hc.ResponseStatusEquals(200),
+		hc.NewRequest("GET", server.URL+"/v2/teas", nil), hc.ResponseStatusEquals(200),
+	}.Test(t)
+	notices := watchdog.Notices()
+	if len(notices) != 3 {
+		t.Fatalf("Expected 3 distinct notices; found %v", notices)
+	} else if notices[0].Endpoint != "GET /legacy" || notices[0].Header != "Warning" || notices[1].Header != "Deprecation" || notices[2].Header != "Sunset" {
+		t.Fatalf("Unexpected notices: %v", notices)
+	} else if notices[2].Sunset.IsZero() || notices[1].Value != "@1688169599" {
+		t.Fatalf("Unexpected notices: %v", notices)
+	}
+	summary := watchdog.Summary()
+	if !strings.HasPrefix(summary, "Deprecations (3):\n\tGET /legacy: Warning: 299 - \"Deprecated API\"\n") ||
+		!strings.Contains(summary, "GET /v1/teas/{id}: Sunset: "+sunset+" (in 2 days)\n") {
+		t.Fatalf("Unexpected summary:\n%s", summary)
+	}
+
+	failing := NewDeprecationWatchdog(true)
+	if err := (Steps{hc.NewRequest("GET", server.URL+"/v2/teas", nil), hc.ResponseNotDeprecated(failing)}).Go(); err != nil {
+		t.Fatal(err)
+	}
+	err := Steps{hc.NewRequest("GET", server.URL+"/legacy", nil), hc.ResponseNotDeprecated(failing)}.Go()
+	if err == nil || !strings.Contains(err.Error(), `Deprecated: GET /legacy: Warning: 299 - "Deprecated API"`) {
+		t.Fatalf("Expected an error for a deprecated endpoint; found %v", err)
+	} else if NewDeprecationWatchdog(false).Summary() != "" {
+		t.Fatal("Expected an empty summary without notices")
+	}
+}
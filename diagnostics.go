@@ -0,0 +1,145 @@
+package argot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiagnosticCollector gathers diagnostics about the environment under
+// test, such as the target's health, the logs of its containers or the
+// state of its pods, for the post-mortem of a failed scenario (see
+// AddDiagnosticCollector).
+type DiagnosticCollector func() ([]byte, error)
+
+type diagnosticCollector struct {
+	name    string
+	collect DiagnosticCollector
+}
+
+var (
+	diagnosticsLock sync.Mutex
+	diagnostics     = make(map[int]diagnosticCollector)
+	nextDiagnostic  int
+)
+
+// AddDiagnosticCollector registers collector, which is run whenever a
+// scenario run by RunScenario (or RunTable) fails. Its output is
+// attached, redacted by DefaultRedactor, to the result of the failed
+// step as the artifact "diagnostics-NAME.txt", or, should it fail, its
+// error as the artifact "diagnostics-NAME-error.txt". Collectors are
+// run in the order they were registered. The returned function
+// unregisters the collector.
+func AddDiagnosticCollector(name string, collector DiagnosticCollector) func() {
+	diagnosticsLock.Lock()
+	defer diagnosticsLock.Unlock()
+	id := nextDiagnostic
+	nextDiagnostic++
+	diagnostics[id] = diagnosticCollector{name: name, collect: collector}
+	return func() {
+		diagnosticsLock.Lock()
+		defer diagnosticsLock.Unlock()
+		delete(diagnostics, id)
+	}
+}
+
+// AddTargetDiagnostics registers collectors (see
+// AddDiagnosticCollector) of the responses of the target's /health and
+// /version endpoints, beneath baseURL, as "health" and "version". If
+// client is nil, a client with a ten second timeout is used. The
+// returned function unregisters both.
+func AddTargetDiagnostics(client *http.Client, baseURL string) func() {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	removeHealth := AddDiagnosticCollector("health", HTTPDiagnostic(client, baseURL+"/health"))
+	removeVersion := AddDiagnosticCollector("version", HTTPDiagnostic(client, baseURL+"/version"))
+	return func() {
+		removeHealth()
+		removeVersion()
+	}
+}
+
+// collectDiagnostics runs every registered collector, returning their
+// artifacts.
+func collectDiagnostics() []Artifact {
+	diagnosticsLock.Lock()
+	ids := make([]int, 0, len(diagnostics))
+	for id := range diagnostics {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	collectors := make([]diagnosticCollector, len(ids))
+	for idx, id := range ids {
+		collectors[idx] = diagnostics[id]
+	}
+	diagnosticsLock.Unlock()
+
+	var artifacts []Artifact
+	for _, collector := range collectors {
+		if output, err := collector.collect(); err != nil {
+			artifacts = append(artifacts, Artifact{Name: "diagnostics-" + collector.name + "-error.txt", MIMEType: "text/plain", Data: []byte(DefaultRedactor.String(err.Error()) + "\n")})
+		} else {
+			artifacts = append(artifacts, Artifact{Name: "diagnostics-" + collector.name + ".txt", MIMEType: "text/plain", Data: []byte(DefaultRedactor.String(string(output)))})
+		}
+	}
+	return artifacts
+}
+
+// HTTPDiagnostic returns a DiagnosticCollector which GETs urlStr with
+// client and returns the response, whatever its status, with its
+// status line and headers.
+func HTTPDiagnostic(client *http.Client, urlStr string) DiagnosticCollector {
+	return func() ([]byte, error) {
+		response, err := client.Get(urlStr)
+		if err != nil {
+			return nil, err
+		}
+		defer response.Body.Close()
+		return httputil.DumpResponse(response, true)
+	}
+}
+
+// CommandDiagnostic returns a DiagnosticCollector which runs the
+// command, killing it after timeout, and returns its combined stdout
+// and stderr. It errors, giving the output, if the command fails.
+func CommandDiagnostic(timeout time.Duration, name string, args ...string) DiagnosticCollector {
+	return func() ([]byte, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, output)
+		}
+		return output, nil
+	}
+}
+
+// ContainerLogs returns a DiagnosticCollector of the last lines of the
+// logs of the Docker container (see CommandDiagnostic).
+func ContainerLogs(container string, lines int) DiagnosticCollector {
+	return CommandDiagnostic(30*time.Second, "docker", "logs", "--tail", strconv.Itoa(lines), container)
+}
+
+// KubectlDescribe returns a DiagnosticCollector of the output of
+// `kubectl describe` of resource, such as "pods" or "deployment/api",
+// in namespace (see CommandDiagnostic).
+func KubectlDescribe(namespace, resource string) DiagnosticCollector {
+	return CommandDiagnostic(30*time.Second, "kubectl", "describe", "--namespace", namespace, resource)
+}
+
+// LogCaptureDiagnostic returns a DiagnosticCollector of everything
+// captured by lc.
+func LogCaptureDiagnostic(lc *LogCapture) DiagnosticCollector {
+	return func() ([]byte, error) {
+		return []byte(lc.String()), nil
+	}
+}
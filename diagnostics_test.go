@@ -0,0 +1,56 @@
+package argot
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiagnostics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"database": "down"}`))
+		} else {
+			w.Write([]byte(`{"version": "1.2.3"}`))
+		}
+	}))
+	defer server.Close()
+	logs := NewLogCapture()
+	logs.Write([]byte("connecting to database\n"))
+
+	defer AddTargetDiagnostics(nil, server.URL+"/")()
+	defer AddDiagnosticCollector("logs", LogCaptureDiagnostic(logs))()
+	defer AddDiagnosticCollector("echo", CommandDiagnostic(5*time.Second, "echo", "pods"))()
+	defer AddDiagnosticCollector("broken", CommandDiagnostic(5*time.Second, "false"))()
+
+	passing := RunScenario("passing", Steps{NewNamedStep("ok", func() error { return nil })})
+	if artifacts := passing.Steps[0].Artifacts; len(artifacts) != 0 {
+		t.Fatalf("Expected no diagnostics for a passing scenario; found %v", artifacts)
+	}
+
+	failing := RunScenario("failing", Steps{
+		NewNamedStep("ok", func() error { return nil }),
+		NewNamedStep("fail", func() error { return errors.New("boom") }),
+	})
+	if artifacts := failing.Steps[0].Artifacts; len(artifacts) != 0 {
+		t.Fatalf("Expected no diagnostics for a passing step; found %v", artifacts)
+	}
+	artifacts := failing.Steps[1].Artifacts
+	names := []string{}
+	for _, artifact := range artifacts {
+		names = append(names, artifact.Name)
+	}
+	if found := strings.Join(names, " "); found != "diagnostics-health.txt diagnostics-version.txt diagnostics-logs.txt diagnostics-echo.txt diagnostics-broken-error.txt" {
+		t.Fatalf("Unexpected diagnostics: %s", found)
+	} else if health := string(artifacts[0].Data); !strings.HasPrefix(health, "HTTP/1.1 503 Service Unavailable") || !strings.Contains(health, `{"database": "down"}`) {
+		t.Fatalf("Unexpected health diagnostics:\n%s", health)
+	} else if !strings.Contains(string(artifacts[1].Data), "1.2.3") || string(artifacts[2].Data) != "connecting to database\n" || string(artifacts[3].Data) != "pods\n" {
+		t.Fatalf("Unexpected diagnostics: %q %q %q", artifacts[1].Data, artifacts[2].Data, artifacts[3].Data)
+	} else if !strings.HasPrefix(string(artifacts[4].Data), "false : exit status 1") {
+		t.Fatalf("Unexpected error: %q", artifacts[4].Data)
+	}
+}
@@ -0,0 +1,207 @@
+package argot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// The kinds of Difference.
+const (
+	// DifferenceChanged is the kind of a Difference where a value was
+	// found, but not the expected value.
+	DifferenceChanged = "changed"
+	// DifferenceMissing is the kind of a Difference where an expected
+	// value, such as a map entry or slice element, was not found.
+	DifferenceMissing = "missing"
+	// DifferenceUnexpected is the kind of a Difference where a value
+	// was found which was not expected.
+	DifferenceUnexpected = "unexpected"
+)
+
+// Difference is a single difference found by an assertion between the
+// value expected and the value found. Path locates the difference
+// within the compared values, in the same syntax as the paths of
+// JSONNormalisers, for example "items[2].price"; it is empty if the
+// values differ as a whole. Expected is nil if Kind is
+// DifferenceUnexpected, and Actual is nil if Kind is
+// DifferenceMissing. Values without a registered formatter are
+// reported as they are, unless they cannot be encoded as JSON (such as
+// NaN, complex numbers, functions and channels), in which case they
+// are reported as formatted by fmt.Sprint.
+type Difference struct {
+	Path     string      `json:"path"`
+	Kind     string      `json:"kind"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual"`
+}
+
+// StepError is the error returned by the steps which compare values,
+// such as ExpectPrettyEqual, ResponseBodyEquals and
+// ResponseBodyJSONMatchesStruct. As well as the rendered message,
+// which may contain a coloured or pretty-printed diff, it carries the
+// Differences found, for tooling that triages failures. Steps such as
+// HttpCall steps may wrap it, so retrieve it with errors.As, or use
+// Differences.
+type StepError struct {
+	Message     string
+	Differences []Difference
+}
+
+func (e *StepError) Error() string {
+	return e.Message
+}
+
+// Differences returns the Differences carried by the StepError within
+// err, or nil if there is none.
+func Differences(err error) []Difference {
+	var stepErr *StepError
+	if errors.As(err, &stepErr) {
+		return stepErr.Differences
+	} else {
+		return nil
+	}
+}
+
+// diffError returns a StepError with the message formatted from
+// format and args, and a single Difference between expected and got
+// at path.
+func diffError(path string, expected, got interface{}, format string, args ...interface{}) *StepError {
+	return &StepError{
+		Message:     fmt.Sprintf(format, args...),
+		Differences: []Difference{{Path: path, Kind: DifferenceChanged, Expected: expected, Actual: got}},
+	}
+}
+
+// compareError is as compare, but returns nil if got and want are
+// equal, and otherwise a StepError carrying the diff and the
+// Differences between them.
+func compareError(got, want interface{}) error {
+	return compareErrorWithin(got, want, 0)
+}
+
+// compareErrorWithin is as compareError, but floating point numbers
+// are equal if they differ by no more than epsilon.
+func compareErrorWithin(got, want interface{}, epsilon float64) error {
+	diff := compareWithin(got, want, epsilon)
+	if diff == "" {
+		return nil
+	}
+	compareLock.RLock()
+	defer compareLock.RUnlock()
+	c := comparison{epsilon: epsilon}
+	return &StepError{
+		Message:     fmt.Sprintf("Did not match expected value: (-got +want)\n%s", diff),
+		Differences: c.differences("", reflect.ValueOf(got), reflect.ValueOf(want)),
+	}
+}
+
+// differences returns the Differences between got and want beneath
+// path, descending into structs, maps, slices and arrays of the same
+// type. Values with a registered comparator or formatter are compared
+// as a whole. compareLock must be held.
+func (c comparison) differences(path string, got, want reflect.Value) []Difference {
+	if c.equal(got, want) {
+		return nil
+	}
+	got, want = c.deref(got), c.deref(want)
+	changed := []Difference{{Path: path, Kind: DifferenceChanged, Expected: c.value(want), Actual: c.value(got)}}
+	if !got.IsValid() || !want.IsValid() || got.Type() != want.Type() || c.opaque(got.Type()) {
+		return changed
+	}
+	diffs := []Difference{}
+	switch got.Kind() {
+	case reflect.Struct:
+		for idx := 0; idx < got.NumField(); idx++ {
+			diffs = append(diffs, c.differences(joinPath(path, got.Type().Field(idx).Name), got.Field(idx), want.Field(idx))...)
+		}
+	case reflect.Map:
+		keys := append(got.MapKeys(), want.MapKeys()...)
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for idx, key := range keys {
+			if idx > 0 && fmt.Sprint(keys[idx-1]) == fmt.Sprint(key) {
+				continue
+			}
+			keyPath := path + "[" + fmt.Sprint(key) + "]"
+			if key.Kind() == reflect.String {
+				keyPath = joinPath(path, key.String())
+			}
+			gotElem, wantElem := got.MapIndex(key), want.MapIndex(key)
+			if !gotElem.IsValid() {
+				diffs = append(diffs, Difference{Path: keyPath, Kind: DifferenceMissing, Expected: c.value(wantElem)})
+			} else if !wantElem.IsValid() {
+				diffs = append(diffs, Difference{Path: keyPath, Kind: DifferenceUnexpected, Actual: c.value(gotElem)})
+			} else {
+				diffs = append(diffs, c.differences(keyPath, gotElem, wantElem)...)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for idx := 0; idx < got.Len() || idx < want.Len(); idx++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, idx)
+			if idx >= got.Len() {
+				diffs = append(diffs, Difference{Path: elemPath, Kind: DifferenceMissing, Expected: c.value(want.Index(idx))})
+			} else if idx >= want.Len() {
+				diffs = append(diffs, Difference{Path: elemPath, Kind: DifferenceUnexpected, Actual: c.value(got.Index(idx))})
+			} else {
+				diffs = append(diffs, c.differences(elemPath, got.Index(idx), want.Index(idx))...)
+			}
+		}
+	}
+	if len(diffs) == 0 {
+		// The values differ as a whole, for example functions or
+		// channels.
+		return changed
+	} else {
+		return diffs
+	}
+}
+
+// deref follows v through non-nil pointers and interfaces.
+func (c comparison) deref(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// opaque returns whether values of type t are compared as a whole,
+// with a registered comparator or formatter. compareLock must be held.
+func (c comparison) opaque(t reflect.Type) bool {
+	if _, found := comparators[t]; found {
+		return true
+	}
+	_, found := CompareConfig.Formatter[t]
+	return found
+}
+
+// value returns v as reported in a Difference: formatted by its
+// registered formatter, if any, and as a string if it cannot be
+// otherwise obtained, as for unexported fields, or cannot be encoded
+// as JSON. compareLock must be held.
+func (c comparison) value(v reflect.Value) interface{} {
+	var value interface{}
+	if !v.IsValid() {
+		return nil
+	} else if !v.CanInterface() {
+		return fmt.Sprint(v)
+	} else if formatter, found := CompareConfig.Formatter[v.Type()]; found {
+		value = reflect.ValueOf(formatter).Call([]reflect.Value{v})[0].Interface()
+	} else {
+		value = v.Interface()
+	}
+	if _, err := json.Marshal(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	return value
+}
+
+// joinPath appends the field or key name to path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	} else {
+		return path + "." + name
+	}
+}
@@ -0,0 +1,124 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type differencesItem struct {
+	SKU   string
+	Price float64
+}
+
+type differencesOrder struct {
+	ID    int
+	Items []differencesItem
+	Tags  map[string]string
+}
+
+func TestStepErrorDifferences(t *testing.T) {
+	expected := differencesOrder{
+		ID:    1,
+		Items: []differencesItem{{"a", 1.5}, {"b", 2}},
+		Tags:  map[string]string{"colour": "red", "size": "L"},
+	}
+	got := &differencesOrder{
+		ID:    1,
+		Items: []differencesItem{{"a", 1.75}, {"b", 2}, {"c", 3}},
+		Tags:  map[string]string{"colour": "blue", "fit": "slim"},
+	}
+	err := ExpectPrettyEqual(expected, got).Go()
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) {
+		t.Fatalf("Expected a StepError; found %v", err)
+	} else if !strings.HasPrefix(stepErr.Error(), "Did not match expected value: (-got +want)\n") {
+		t.Fatalf("Expected the rendered diff to be unchanged; found %s", stepErr)
+	}
+	found := []string{}
+	for _, diff := range stepErr.Differences {
+		found = append(found, fmt.Sprintf("%s %s %v %v", diff.Path, diff.Kind, diff.Expected, diff.Actual))
+	}
+	if expected := "Items[0].Price changed 1.5 1.75|Items[2] unexpected <nil> {c 3}|Tags.colour changed red blue|Tags.fit unexpected <nil> slim|Tags.size missing L <nil>"; strings.Join(found, "|") != expected {
+		t.Fatalf("Differences: Expected %s; found %s.", expected, strings.Join(found, "|"))
+	}
+	if diffs := Differences(ExpectPrettyEqual(1, "1").Go()); len(diffs) != 1 || diffs[0].Path != "" || diffs[0].Kind != DifferenceChanged {
+		t.Fatalf("Expected values of different types to have a single Difference; found %+v", diffs)
+	}
+	if diffs := Differences(errors.New("Expected 200; found 500.")); diffs != nil {
+		t.Fatalf("Expected no Differences from another error; found %+v", diffs)
+	}
+}
+
+func TestStepErrorDifferencesHttpCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Version", "2")
+		w.Write([]byte(`{"name": "argot", "ids": [1, 2]}`))
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{hc.NewRequest("GET", server.URL, nil)}.Test(t)
+	for _, test := range []struct {
+		step     Step
+		expected Difference
+	}{
+		{hc.ResponseHeaderEquals("X-Version", "1"), Difference{Path: "header.X-Version", Kind: DifferenceChanged, Expected: "1", Actual: "2"}},
+		{hc.ResponseBodyEquals("{}"), Difference{Path: "body", Kind: DifferenceChanged, Expected: "{}", Actual: `{"name": "argot", "ids": [1, 2]}`}},
+		{hc.ResponseBodyJSONMatchesStruct(map[string]interface{}{"name": "argot", "ids": []interface{}{1.0, 3.0}}), Difference{Path: "ids[1]", Kind: DifferenceChanged, Expected: 3.0, Actual: 2.0}},
+	} {
+		err := test.step.Go()
+		if _, ok := err.(*HttpCallError); !ok {
+			t.Fatalf("%v: Expected an HttpCallError; found %v", test.step, err)
+		} else if diffs := Differences(err); len(diffs) != 1 || diffs[0] != test.expected {
+			t.Fatalf("%v: Expected %+v; found %+v", test.step, test.expected, diffs)
+		}
+	}
+
+	result := RunScenario("body", Steps{hc.ResponseBodyJSONMatchesStruct(map[string]interface{}{"name": "argot", "ids": []interface{}{1.0}})})
+	buf := new(bytes.Buffer)
+	if err := WriteJSONReport(buf, []*ScenarioResult{result}); err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Scenarios []struct {
+			Steps []struct {
+				Differences []Difference `json:"differences"`
+			} `json:"steps"`
+		} `json:"scenarios"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	} else if diffs := report.Scenarios[0].Steps[0].Differences; len(diffs) != 1 || diffs[0] != (Difference{Path: "ids[1]", Kind: DifferenceUnexpected, Actual: 2.0}) {
+		t.Fatalf("Expected the report to contain the Differences; found %s", buf)
+	}
+}
+
+func TestStepErrorDifferencesUnencodable(t *testing.T) {
+	type holder struct {
+		Ratio    float64
+		Callback func()
+		Notes    []string
+	}
+	err := compareError(holder{Ratio: math.NaN(), Callback: func() {}, Notes: []string{"token=abc", "hunter2"}}, holder{Ratio: 1})
+	if _, marshalErr := json.Marshal(Differences(err)); marshalErr != nil {
+		t.Fatalf("Expected the Differences to be encodable as JSON; found %v", marshalErr)
+	}
+
+	redactor := &Redactor{}
+	redactor.AddValue("hunter2")
+	hcErr := &HttpCallError{Err: err, redactor: redactor}
+	bites, marshalErr := json.Marshal(redactedDifferences(hcErr))
+	if marshalErr != nil {
+		t.Fatal(marshalErr)
+	} else if str := string(bites); strings.Contains(str, "hunter2") || strings.Contains(str, "abc") || !strings.Contains(str, `"NaN"`) {
+		t.Fatalf("Expected nested secrets to be redacted and NaN to be formatted; found %s", str)
+	}
+}
@@ -0,0 +1,171 @@
+package argot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DNSCall performs DNS lookups, optionally against a specific
+// resolver, so that steps can assert on the records found (for example
+// to verify service discovery records before hitting endpoints).
+type DNSCall struct {
+	// The resolver used for lookups.
+	Resolver *net.Resolver
+	// Timeout bounds each lookup. If zero, 5 seconds is used.
+	Timeout time.Duration
+}
+
+// NewDNSCall creates a new DNSCall. If server is empty, the system
+// resolver is used. Otherwise server is the host:port of the DNS
+// server to query (for example "10.0.0.2:53"); queries are made with
+// Go's own resolver.
+func NewDNSCall(server string) *DNSCall {
+	if server == "" {
+		return &DNSCall{Resolver: net.DefaultResolver}
+	}
+	return &DNSCall{
+		Resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return new(net.Dialer).DialContext(ctx, network, server)
+			},
+		},
+	}
+}
+
+func (dc *DNSCall) context() (context.Context, context.CancelFunc) {
+	timeout := dc.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// expectAll errors unless found is non-empty and contains every
+// expected value, listing those which are missing. Values are compared
+// case-insensitively and ignoring any trailing dot.
+func expectAll(kind, name string, expected, found []string) error {
+	normalise := func(str string) string {
+		return strings.TrimSuffix(strings.ToLower(str), ".")
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("DNS: No %s records found for %s.", kind, name)
+	}
+	present := make(map[string]bool, len(found))
+	for _, str := range found {
+		present[normalise(str)] = true
+	}
+	missing := []string{}
+	for _, str := range expected {
+		if !present[normalise(str)] {
+			missing = append(missing, str)
+		}
+	}
+	if len(missing) != 0 {
+		sort.Strings(found)
+		return fmt.Errorf("DNS: %s records for %s: Expected %v; found %v; missing %v.", kind, name, expected, found, missing)
+	} else {
+		return nil
+	}
+}
+
+func (dc *DNSCall) lookupIP(network, kind, host string, expected []string) Step {
+	return NewNamedStep(fmt.Sprintf("Expect%s(%s: %s)", kind, host, strings.Join(expected, ", ")), func() error {
+		ctx, cancel := dc.context()
+		defer cancel()
+		if ips, err := dc.Resolver.LookupIP(ctx, network, host); err != nil {
+			return err
+		} else {
+			found := make([]string, len(ips))
+			for idx, ip := range ips {
+				found[idx] = ip.String()
+			}
+			return expectAll(kind, host, expected, found)
+		}
+	})
+}
+
+// ExpectA is a Step that when executed resolves the A records of host
+// and errors unless there is at least one and every expected IPv4
+// address is among them.
+func (dc *DNSCall) ExpectA(host string, expected ...string) Step {
+	return dc.lookupIP("ip4", "A", host, expected)
+}
+
+// ExpectAAAA is a Step that when executed resolves the AAAA records
+// of host and errors unless there is at least one and every expected
+// IPv6 address is among them.
+func (dc *DNSCall) ExpectAAAA(host string, expected ...string) Step {
+	return dc.lookupIP("ip6", "AAAA", host, expected)
+}
+
+// ExpectCNAME is a Step that when executed resolves the canonical name
+// of host and errors unless it equals expected.
+func (dc *DNSCall) ExpectCNAME(host, expected string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectCNAME(%s: %s)", host, expected), func() error {
+		ctx, cancel := dc.context()
+		defer cancel()
+		if cname, err := dc.Resolver.LookupCNAME(ctx, host); err != nil {
+			return err
+		} else {
+			return expectAll("CNAME", host, []string{expected}, []string{cname})
+		}
+	})
+}
+
+// ExpectSRV is a Step that when executed resolves the SRV records of
+// _service._proto.name and errors unless there is at least one and
+// every expected target is among them. Targets are given as
+// "host:port".
+func (dc *DNSCall) ExpectSRV(service, proto, name string, expected ...string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectSRV(%s.%s.%s: %s)", service, proto, name, strings.Join(expected, ", ")), func() error {
+		ctx, cancel := dc.context()
+		defer cancel()
+		if _, srvs, err := dc.Resolver.LookupSRV(ctx, service, proto, name); err != nil {
+			return err
+		} else {
+			found := make([]string, len(srvs))
+			for idx, srv := range srvs {
+				found[idx] = net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), fmt.Sprint(srv.Port))
+			}
+			return expectAll("SRV", name, expected, found)
+		}
+	})
+}
+
+// ExpectTXT is a Step that when executed resolves the TXT records of
+// host and errors unless there is at least one and every expected
+// value is among them.
+func (dc *DNSCall) ExpectTXT(host string, expected ...string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectTXT(%s: %s)", host, strings.Join(expected, ", ")), func() error {
+		ctx, cancel := dc.context()
+		defer cancel()
+		if txts, err := dc.Resolver.LookupTXT(ctx, host); err != nil {
+			return err
+		} else {
+			return expectAll("TXT", host, expected, txts)
+		}
+	})
+}
+
+// ExpectNotFound is a Step that when executed errors unless looking
+// up host fails because the name does not exist.
+func (dc *DNSCall) ExpectNotFound(host string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectNotFound(%s)", host), func() error {
+		ctx, cancel := dc.context()
+		defer cancel()
+		var dnsErr *net.DNSError
+		if addrs, err := dc.Resolver.LookupHost(ctx, host); err == nil {
+			return fmt.Errorf("DNS: Expected %s not to be found; found %v.", host, addrs)
+		} else if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			return fmt.Errorf("DNS: Expected %s not to be found; found %v.", host, err)
+		} else {
+			return nil
+		}
+	})
+}
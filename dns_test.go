@@ -0,0 +1,96 @@
+package argot
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+// dnsStub is a minimal DNS server which answers A and TXT queries for
+// the names in records, with NXDOMAIN for any other name, until conn
+// is closed.
+func dnsStub(t *testing.T, records map[string]map[uint16][][]byte) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query := buf[:n]
+			// The question starts after the 12 byte header: a sequence
+			// of labels ending with an empty one, then the type and class.
+			end, labels := 12, []string{}
+			for end < n && query[end] != 0 {
+				labels = append(labels, string(query[end+1:end+1+int(query[end])]))
+				end += 1 + int(query[end])
+			}
+			end += 5
+			if end > n {
+				continue
+			}
+			qtype := binary.BigEndian.Uint16(query[end-4:])
+			name := strings.ToLower(strings.Join(labels, ".")) + "."
+			types, found := records[name]
+			answers := types[qtype]
+			response := append([]byte{}, query[:2]...)
+			if found {
+				response = append(response, 0x81, 0x80)
+			} else {
+				response = append(response, 0x81, 0x83)
+			}
+			response = append(response, 0, 1, byte(len(answers)>>8), byte(len(answers)), 0, 0, 0, 0)
+			response = append(response, query[12:end]...)
+			for _, answer := range answers {
+				// A pointer to the name in the question, the type, class
+				// IN, a TTL of 60 seconds, and the data.
+				response = append(response, 0xc0, 12, byte(qtype>>8), byte(qtype), 0, 1, 0, 0, 0, 60, byte(len(answer)>>8), byte(len(answer)))
+				response = append(response, answer...)
+			}
+			conn.WriteTo(response, addr)
+		}
+	}()
+	return conn
+}
+
+func TestDNSCall(t *testing.T) {
+	conn := dnsStub(t, map[string]map[uint16][][]byte{
+		"api.test.": {
+			1:  {{10, 0, 0, 1}, {10, 0, 0, 2}},
+			16: {append([]byte{3}, "v=1"...)},
+		},
+	})
+	defer conn.Close()
+	dc := NewDNSCall(conn.LocalAddr().String())
+	Steps{
+		dc.ExpectA("api.test.", "10.0.0.2"),
+		dc.ExpectA("api.test.", "10.0.0.1", "10.0.0.2"),
+		dc.ExpectTXT("api.test.", "v=1"),
+		dc.ExpectNotFound("gone.test."),
+		ExpectError(dc.ExpectNotFound("api.test.")),
+		ExpectError(dc.ExpectTXT("api.test.", "v=2")),
+	}.Test(t)
+
+	err := dc.ExpectA("api.test.", "10.0.0.1", "10.0.0.3").Go()
+	if err == nil || err.Error() != "DNS: A records for api.test.: Expected [10.0.0.1 10.0.0.3]; found [10.0.0.1 10.0.0.2]; missing [10.0.0.3]." {
+		t.Fatalf("Expected the missing record to be reported; found %v", err)
+	}
+}
+
+func TestDNSExpectAll(t *testing.T) {
+	if err := expectAll("CNAME", "www.example.com", []string{"Web.example.com."}, []string{"web.example.com"}); err != nil {
+		t.Fatalf("Expected values to be compared without regard to case or trailing dots; found %v", err)
+	}
+	if err := expectAll("TXT", "example.com", nil, nil); err == nil || err.Error() != "DNS: No TXT records found for example.com." {
+		t.Fatalf("Expected no records to be an error; found %v", err)
+	}
+	err := expectAll("TXT", "example.com", []string{"a", "c", "d"}, []string{"b", "a"})
+	if err == nil || err.Error() != "DNS: TXT records for example.com: Expected [a c d]; found [a b]; missing [c d]." {
+		t.Fatalf("Expected the missing records to be listed; found %v", err)
+	}
+}
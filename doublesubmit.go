@@ -0,0 +1,59 @@
+package argot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DoubleSubmit is a Step that when executed sends the request built by
+// earlier steps copies times (at least twice) concurrently, as a user
+// double-clicking "Pay" or a client retrying too eagerly would, and
+// then runs verify, which should check that only one resource was
+// created: for example by listing the resources and counting them.
+// One of the requests is sent by hc, which is left holding its
+// response, and the others by clones of hc (see Clone), so headers
+// such as an idempotency key are the same on every copy. The step
+// errors if any copy cannot be sent or receives a 5xx status, as a
+// duplicate submission should be refused or deduplicated rather than
+// fail, or if verify errors. Like Call, it must be used after the
+// request has been built, and before any step which needs the
+// response.
+func (hc *HttpCall) DoubleSubmit(copies int, verify Step) Step {
+	if copies < 2 {
+		copies = 2
+	}
+	return hc.step(fmt.Sprintf("DoubleSubmit(%d)", copies), func() error {
+		if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
+			return err
+		}
+		calls := []*HttpCall{hc}
+		for len(calls) < copies {
+			if clone, err := hc.Clone(); err != nil {
+				return err
+			} else {
+				calls = append(calls, clone)
+			}
+		}
+		defer func() {
+			for _, call := range calls[1:] {
+				call.Reset()
+			}
+		}()
+		if err := Concurrently(copies, func(i int) Step { return calls[i].Call() }).Go(); err != nil {
+			return fmt.Errorf("Double submit: %v", err)
+		}
+		statuses := make([]string, len(calls))
+		failed := false
+		for idx, call := range calls {
+			statuses[idx] = fmt.Sprint(call.Response.StatusCode)
+			failed = failed || call.Response.StatusCode >= 500
+		}
+		if failed {
+			return fmt.Errorf("Double submit: Expected no 5xx statuses; found %s.", strings.Join(statuses, ", "))
+		} else if err := verify.Go(); err != nil {
+			return fmt.Errorf("Double submit (statuses %s): %v", strings.Join(statuses, ", "), err)
+		} else {
+			return nil
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package argot
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDoubleSubmit(t *testing.T) {
+	var lock sync.Mutex
+	payments := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		key := r.Header.Get("Idempotency-Key")
+		if r.URL.Path == "/deduplicated" && payments[key] > 0 {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		payments[key]++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	onePayment := func(key string) Step {
+		return NewNamedStep("OnePayment", func() error {
+			lock.Lock()
+			defer lock.Unlock()
+			if payments[key] != 1 {
+				return errors.New("Expected one payment.")
+			}
+			return nil
+		})
+	}
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("POST", server.URL+"/deduplicated", strings.NewReader(`{"amount": 10}`)),
+		hc.RequestHeader("Idempotency-Key", "a"),
+		hc.DoubleSubmit(3, onePayment("a")),
+	}.Test(t)
+
+	if _, err := (Steps{
+		hc.NewRequest("POST", server.URL+"/naive", strings.NewReader(`{"amount": 10}`)),
+		hc.RequestHeader("Idempotency-Key", "b"),
+		hc.DoubleSubmit(2, onePayment("b")),
+	}).Test(nil); err == nil {
+		t.Fatal("Expected a duplicate payment to be detected.")
+	} else if !strings.Contains(err.Error(), "Double submit (statuses 201, 201): Expected one payment.") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
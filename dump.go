@@ -0,0 +1,41 @@
+package argot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httputil"
+)
+
+// Dump returns the request and, if there is one, the response in wire
+// format (see httputil.DumpRequestOut and httputil.DumpResponse),
+// including bodies. The dump is redacted by hc.Redactor (or
+// DefaultRedactor). If there is a response, its body is received.
+func (hc *HttpCall) Dump() string {
+	if hc.Request == nil {
+		return ""
+	}
+	buf := new(bytes.Buffer)
+	req := *hc.Request
+	req.Header = hc.redactor().Header(req.Header)
+	if body, err := readRequestBody(hc.Request); err == nil && body != nil {
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	if dump, err := httputil.DumpRequestOut(&req, true); err != nil {
+		buf.WriteString("(request could not be dumped: " + err.Error() + ")\n")
+	} else {
+		buf.Write(dump)
+	}
+
+	if hc.Response != nil && hc.ReceiveBody() == nil {
+		response := *hc.Response
+		response.Header = hc.redactor().Header(response.Header)
+		response.Body = ioutil.NopCloser(bytes.NewReader(hc.ResponseBody))
+		if dump, err := httputil.DumpResponse(&response, true); err != nil {
+			buf.WriteString("\n(response could not be dumped: " + err.Error() + ")\n")
+		} else {
+			buf.WriteString("\n")
+			buf.Write(dump)
+		}
+	}
+	return hc.redactor().String(buf.String())
+}
@@ -0,0 +1,58 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "hunter2"})
+		w.Header().Set("X-Served-By", "teapot")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	if dump := hc.Dump(); dump != "" {
+		t.Fatalf("Expected an empty dump without a request; found %q", dump)
+	}
+	hc.DumpOnFailure = true
+	err := Steps{
+		hc.NewRequest("POST", server.URL+"/teas", strings.NewReader("milk=no")),
+		hc.RequestHeader("Authorization", "Bearer hunter2"),
+		hc.RequestHeader("X-Trace", "abc123"),
+		hc.ResponseStatusEquals(http.StatusOK),
+	}.Go()
+	hcErr, ok := err.(*HttpCallError)
+	if !ok {
+		t.Fatalf("Expected an HttpCallError; found %v", err)
+	}
+
+	for _, dump := range []string{hc.Dump(), hcErr.Dump} {
+		for _, expected := range []string{
+			"POST /teas HTTP/1.1\r\n",
+			"Authorization: REDACTED\r\n",
+			"X-Trace: abc123\r\n",
+			"\r\n\r\nmilk=no",
+			"HTTP/1.1 418 I'm a teapot\r\n",
+			"Set-Cookie: REDACTED\r\n",
+			"X-Served-By: teapot\r\n",
+			"\r\n\r\nshort and stout",
+		} {
+			if !strings.Contains(dump, expected) {
+				t.Fatalf("Expected %q in dump:\n%s", expected, dump)
+			}
+		}
+		if strings.Contains(dump, "hunter2") {
+			t.Fatalf("Expected secret headers to be redacted:\n%s", dump)
+		}
+	}
+	if !strings.Contains(hcErr.Error(), "Dump:\n"+hcErr.Dump) {
+		t.Fatalf("Expected the error to include the dump; found %v", hcErr)
+	}
+}
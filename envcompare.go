@@ -0,0 +1,184 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// comparedExchange is a response recorded by an
+// EnvironmentComparator, with the request it answered.
+type comparedExchange struct {
+	Method string
+	Path   string
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// recordingTransport records every response received through
+// transport.
+type recordingTransport struct {
+	transport http.RoundTripper
+	lock      sync.Mutex
+	exchanges []comparedExchange
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	response, err := rt.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	rt.exchanges = append(rt.exchanges, comparedExchange{
+		Method: req.Method,
+		Path:   req.URL.RequestURI(),
+		Status: response.StatusCode,
+		Header: response.Header,
+		Body:   body,
+	})
+	return response, nil
+}
+
+// EnvironmentComparator runs the same pipeline of steps against two
+// deployments of a service, such as the current release and a canary,
+// and asserts that they respond equivalently: every response of the
+// candidate must have the same status, the same values of Headers,
+// and the same body (after Normalisers, if JSON) as the corresponding
+// response of the baseline. Differences are reported field by field
+// (see Differences), at paths such as "responses[1].body.items[0].price".
+type EnvironmentComparator struct {
+	// The client on which those of the two HttpCalls are based. If
+	// nil, http.DefaultClient is used.
+	Client *http.Client
+	// Applied in order to both JSON bodies before they are compared,
+	// for example to drop timestamps or ids which legitimately differ.
+	Normalisers []JSONNormaliser
+	// The response headers which are compared; other headers are
+	// ignored.
+	Headers []string
+}
+
+// NewEnvironmentComparator creates a new EnvironmentComparator, whose
+// HttpCalls are based on client (which may be nil), applying
+// normalisers to JSON bodies.
+func NewEnvironmentComparator(client *http.Client, normalisers ...JSONNormaliser) *EnvironmentComparator {
+	return &EnvironmentComparator{Client: client, Normalisers: normalisers}
+}
+
+// run runs the pipeline against baseURL with a new HttpCall, returning
+// the responses received.
+func (ec *EnvironmentComparator) run(baseURL string, pipeline func(hc *HttpCall, baseURL string) Steps) ([]comparedExchange, error) {
+	client := http.DefaultClient
+	if ec.Client != nil {
+		client = ec.Client
+	}
+	clone := *client
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	recorder := &recordingTransport{transport: transport}
+	clone.Transport = recorder
+	hc := NewHttpCall(&clone)
+	defer hc.Reset()
+	err := pipeline(hc, baseURL).Go()
+	return recorder.exchanges, err
+}
+
+// body returns the body, decoded and normalised if it is JSON, or as a
+// string otherwise.
+func (ec *EnvironmentComparator) body(bites []byte) interface{} {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(bites))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil || decoder.More() {
+		return string(bites)
+	}
+	return applyNormalisers(doc, ec.Normalisers)
+}
+
+// differences returns the Differences between the responses of the
+// baseline and the candidate.
+func (ec *EnvironmentComparator) differences(baseline, candidate []comparedExchange) []Difference {
+	diffs := []Difference{}
+	compareLock.RLock()
+	defer compareLock.RUnlock()
+	c := comparison{}
+	for idx := 0; idx < len(baseline) || idx < len(candidate); idx++ {
+		path := fmt.Sprintf("responses[%d]", idx)
+		if idx >= len(candidate) {
+			diffs = append(diffs, Difference{Path: path, Kind: DifferenceMissing, Expected: baseline[idx].Method + " " + baseline[idx].Path})
+			continue
+		} else if idx >= len(baseline) {
+			diffs = append(diffs, Difference{Path: path, Kind: DifferenceUnexpected, Actual: candidate[idx].Method + " " + candidate[idx].Path})
+			continue
+		}
+		want, got := baseline[idx], candidate[idx]
+		if want.Method != got.Method || want.Path != got.Path {
+			diffs = append(diffs, Difference{Path: joinPath(path, "request"), Kind: DifferenceChanged, Expected: want.Method + " " + want.Path, Actual: got.Method + " " + got.Path})
+			continue
+		}
+		if want.Status != got.Status {
+			diffs = append(diffs, Difference{Path: joinPath(path, "status"), Kind: DifferenceChanged, Expected: want.Status, Actual: got.Status})
+		}
+		for _, header := range ec.Headers {
+			key := http.CanonicalHeaderKey(header)
+			if wantValues, gotValues := want.Header[key], got.Header[key]; !reflect.DeepEqual(wantValues, gotValues) {
+				diffs = append(diffs, Difference{Path: joinPath(joinPath(path, "headers"), key), Kind: DifferenceChanged, Expected: strings.Join(wantValues, ", "), Actual: strings.Join(gotValues, ", ")})
+			}
+		}
+		wantBody, gotBody := ec.body(want.Body), ec.body(got.Body)
+		diffs = append(diffs, c.differences(joinPath(path, "body"), reflect.ValueOf(gotBody), reflect.ValueOf(wantBody))...)
+	}
+	return diffs
+}
+
+// Compare is a Step that when executed runs the steps returned by
+// pipeline against baselineURL and then against candidateURL, each
+// with a new HttpCall, and errors if either fails or if any of the
+// candidate's responses is not equivalent to the baseline's. The
+// error is a StepError carrying the Differences found.
+func (ec *EnvironmentComparator) Compare(baselineURL, candidateURL string, pipeline func(hc *HttpCall, baseURL string) Steps) Step {
+	return NewNamedStep(fmt.Sprintf("CompareEnvironments(%s: %s)", baselineURL, candidateURL), func() error {
+		baseline, err := ec.run(baselineURL, pipeline)
+		if err != nil {
+			return fmt.Errorf("Baseline %s: %v", baselineURL, err)
+		}
+		candidate, err := ec.run(candidateURL, pipeline)
+		if err != nil {
+			return fmt.Errorf("Candidate %s: %v", candidateURL, err)
+		}
+		diffs := ec.differences(baseline, candidate)
+		if len(diffs) == 0 {
+			return nil
+		}
+		lines := make([]string, len(diffs))
+		for idx, diff := range diffs {
+			switch diff.Kind {
+			case DifferenceMissing:
+				lines[idx] = fmt.Sprintf("%s: Missing; expected %v.", diff.Path, diff.Expected)
+			case DifferenceUnexpected:
+				lines[idx] = fmt.Sprintf("%s: Unexpected %v.", diff.Path, diff.Actual)
+			default:
+				lines[idx] = fmt.Sprintf("%s: Expected %v; found %v.", diff.Path, diff.Expected, diff.Actual)
+			}
+		}
+		return &StepError{
+			Message:     fmt.Sprintf("Candidate %s differs from baseline %s:\n\t%s", candidateURL, baselineURL, strings.Join(lines, "\n\t")),
+			Differences: diffs,
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEnvironmentComparator(t *testing.T) {
+	serve := func(price string, version string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Version", version)
+			if r.URL.Path == "/teas/sencha" {
+				fmt.Fprintf(w, `{"name": "sencha", "price": %s, "servedAt": "%s"}`, price, version)
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte("not found"))
+			}
+		}))
+	}
+	current, canary, broken := serve("4.5", "v1"), serve("4.5", "v2"), serve("5", "v3")
+	defer current.Close()
+	defer canary.Close()
+	defer broken.Close()
+
+	pipeline := func(hc *HttpCall, baseURL string) Steps {
+		return Steps{
+			hc.NewRequest("GET", baseURL+"/teas/sencha", nil),
+			hc.ResponseStatusEquals(http.StatusOK),
+			NewNamedStep("Reset", func() error { hc.Reset(); return nil }),
+			hc.NewRequest("GET", baseURL+"/teas/earl-grey", nil),
+			hc.ResponseStatusEquals(http.StatusNotFound),
+		}
+	}
+
+	comparator := NewEnvironmentComparator(nil, DropPaths("servedAt"))
+	if err := comparator.Compare(current.URL, canary.URL, pipeline).Go(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := comparator.Compare(current.URL, broken.URL, pipeline).Go()
+	if diffs := Differences(err); len(diffs) != 1 || diffs[0].Path != "responses[0].body.price" || fmt.Sprint(diffs[0].Expected) != "4.5" || fmt.Sprint(diffs[0].Actual) != "5" {
+		t.Fatalf("Unexpected differences: %v (%v)", diffs, err)
+	} else if !strings.Contains(err.Error(), "responses[0].body.price: Expected 4.5; found 5.") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	comparator = NewEnvironmentComparator(nil)
+	comparator.Headers = []string{"x-version"}
+	err = comparator.Compare(current.URL, canary.URL, pipeline).Go()
+	if diffs := Differences(err); len(diffs) != 3 || diffs[0].Path != "responses[0].headers.X-Version" || diffs[1].Path != "responses[0].body.servedAt" || diffs[2].Path != "responses[1].headers.X-Version" {
+		t.Fatalf("Unexpected differences: %v", diffs)
+	}
+
+	if err := comparator.Compare(current.URL, "http://127.0.0.1:1", pipeline).Go(); err == nil || !strings.HasPrefix(err.Error(), "Candidate http://127.0.0.1:1: ") {
+		t.Fatalf("Expected the candidate to fail; found %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+package argot
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvironmentEnv is the name of the environment variable which, if
+// Environments.Select is given no name, selects the active
+// environment.
+const EnvironmentEnv = "ARGOT_ENV"
+
+// Environment describes a deployment of the system under test, such as
+// local, staging or prod-readonly, so that the same suite can run
+// against each. Environments are typically loaded from a YAML or JSON
+// file with LoadEnvironments, for example:
+//
+//	staging:
+//	  baseURL: https://staging.example.com
+//	  services:
+//	    auth: https://auth.staging.example.com
+//	  credentials:
+//	    apiKey: ${env.STAGING_API_KEY}
+//	  headers:
+//	    X-Client: argot
+//	prod-readonly:
+//	  baseURL: https://example.com
+//	  readOnly: true
+//	  tls:
+//	    caFile: certs/prod-ca.pem
+//
+// Strings may refer to the process's environment variables with
+// ${env.NAME}; these are resolved when the environment is selected,
+// so only the selected environment's variables need be set.
+type Environment struct {
+	// The name under which the environment was loaded.
+	Name string `yaml:"-"`
+	// The base URL of the system under test.
+	BaseURL string `yaml:"baseURL"`
+	// The base URLs of other services, by name.
+	Services map[string]string `yaml:"services"`
+	// Credentials, by name.
+	Credentials map[string]string `yaml:"credentials"`
+	// Headers added to every request, unless already set.
+	Headers map[string]string `yaml:"headers"`
+	// Any other values, by name.
+	Vars map[string]string `yaml:"vars"`
+	// The TLS settings of the client.
+	TLS EnvironmentTLS `yaml:"tls"`
+	// If true, only GET, HEAD and OPTIONS requests may be made, so
+	// that a suite cannot modify a production system.
+	ReadOnly bool `yaml:"readOnly"`
+}
+
+// EnvironmentTLS holds the TLS settings of an Environment. Files are
+// PEM encoded.
+type EnvironmentTLS struct {
+	// Trust the certificates in this file rather than the system's.
+	CAFile string `yaml:"caFile"`
+	// The client certificate and key, for mutual TLS.
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// Overrides the server name used to verify the certificate.
+	ServerName string `yaml:"serverName"`
+	// Skips verification of the server's certificate. Only for local
+	// environments with self-signed certificates.
+	InsecureSkipVerify bool `yaml:"insecureSkipVerify"`
+}
+
+// Environments holds environments by name.
+type Environments map[string]*Environment
+
+// LoadEnvironments loads Environments from the YAML or JSON file at
+// path.
+func LoadEnvironments(path string) (Environments, error) {
+	if data, err := ioutil.ReadFile(path); err != nil {
+		return nil, err
+	} else if envs, err := ParseEnvironments(data); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	} else {
+		return envs, nil
+	}
+}
+
+// ParseEnvironments parses Environments from YAML or JSON: an object
+// mapping each environment's name to its settings. Unknown fields are
+// errors.
+func ParseEnvironments(data []byte) (Environments, error) {
+	envs := Environments{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&envs); err != nil && err != io.EOF {
+		return nil, err
+	}
+	for name, env := range envs {
+		if env == nil {
+			env = new(Environment)
+			envs[name] = env
+		}
+		env.Name = name
+	}
+	return envs, nil
+}
+
+// Names returns the names of the environments, sorted.
+func (envs Environments) Names() []string {
+	names := make([]string, 0, len(envs))
+	for name := range envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Select returns a copy of the environment called name, or, if name
+// is empty, the one named by the EnvironmentEnv environment variable,
+// with references to environment variables resolved.
+func (envs Environments) Select(name string) (*Environment, error) {
+	if name == "" {
+		name = os.Getenv(EnvironmentEnv)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("No environment selected: set %s to one of %s.", EnvironmentEnv, strings.Join(envs.Names(), ", "))
+	}
+	env, found := envs[name]
+	if !found {
+		return nil, fmt.Errorf("Environment '%s' not found: Expected one of %s.", name, strings.Join(envs.Names(), ", "))
+	}
+	return env.resolve()
+}
+
+// osEnvStore returns a Store holding the process's environment
+// variables as env.NAME.
+func osEnvStore() *Store {
+	store := NewStore()
+	for _, env := range os.Environ() {
+		if idx := strings.IndexByte(env, '='); idx > 0 {
+			store.Set("env."+env[:idx], env[idx+1:])
+		}
+	}
+	return store
+}
+
+// resolve returns a copy of env with references to environment
+// variables resolved.
+func (env *Environment) resolve() (*Environment, error) {
+	store := osEnvStore()
+	resolved := *env
+	var err error
+	str := func(value string) string {
+		if err != nil {
+			return ""
+		}
+		var result string
+		if result, err = store.Interpolate(value); err != nil {
+			err = fmt.Errorf("Environment '%s': %v", env.Name, err)
+		}
+		return result
+	}
+	strs := func(values map[string]string) map[string]string {
+		result := make(map[string]string, len(values))
+		for key, value := range values {
+			result[key] = str(value)
+		}
+		return result
+	}
+	resolved.BaseURL = str(env.BaseURL)
+	resolved.Services = strs(env.Services)
+	resolved.Credentials = strs(env.Credentials)
+	resolved.Headers = strs(env.Headers)
+	resolved.Vars = strs(env.Vars)
+	resolved.TLS.CAFile = str(env.TLS.CAFile)
+	resolved.TLS.CertFile = str(env.TLS.CertFile)
+	resolved.TLS.KeyFile = str(env.TLS.KeyFile)
+	resolved.TLS.ServerName = str(env.TLS.ServerName)
+	if err != nil {
+		return nil, err
+	}
+	return &resolved, nil
+}
+
+// URL returns path resolved against the environment's base URL.
+func (env *Environment) URL(path string) string {
+	return strings.TrimSuffix(env.BaseURL, "/") + path
+}
+
+// ServiceURL returns path resolved against the base URL of the named
+// service.
+func (env *Environment) ServiceURL(service, path string) (string, error) {
+	if base, found := env.Services[service]; !found {
+		return "", fmt.Errorf("Environment '%s': Service '%s' not found.", env.Name, service)
+	} else {
+		return strings.TrimSuffix(base, "/") + path, nil
+	}
+}
+
+// Credential returns the named credential.
+func (env *Environment) Credential(name string) (string, error) {
+	if credential, found := env.Credentials[name]; !found {
+		return "", fmt.Errorf("Environment '%s': Credential '%s' not found.", env.Name, name)
+	} else {
+		return credential, nil
+	}
+}
+
+// Store returns a new Store holding the environment's values, for use
+// with Scenario.Build and Store.Interpolate: baseURL, each service's
+// base URL as service.NAME, each credential as credential.NAME, each
+// var, and the process's environment variables as env.NAME. The
+// credentials are redacted by the HttpCalls created with NewHttpCall
+// (see Redactor).
+func (env *Environment) Store() *Store {
+	store := osEnvStore()
+	store.Set("baseURL", env.BaseURL)
+	for name, value := range env.Services {
+		store.Set("service."+name, value)
+	}
+	for name, value := range env.Credentials {
+		store.Set("credential."+name, value)
+	}
+	for name, value := range env.Vars {
+		store.Set(name, value)
+	}
+	return store
+}
+
+// tlsConfig returns the TLS configuration of the environment, or nil
+// if it has no TLS settings.
+func (env *Environment) tlsConfig() (*tls.Config, error) {
+	settings := env.TLS
+	if settings == (EnvironmentTLS{}) {
+		return nil, nil
+	}
+	config := &tls.Config{ServerName: settings.ServerName, InsecureSkipVerify: settings.InsecureSkipVerify}
+	if settings.CAFile != "" {
+		pool := x509.NewCertPool()
+		if pem, err := ioutil.ReadFile(settings.CAFile); err != nil {
+			return nil, err
+		} else if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Environment '%s': No certificates found in %s.", env.Name, settings.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if settings.CertFile != "" || settings.KeyFile != "" {
+		if cert, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile); err != nil {
+			return nil, err
+		} else {
+			config.Certificates = []tls.Certificate{cert}
+		}
+	}
+	return config, nil
+}
+
+// Client returns a new http.Client with the given timeout, configured
+// for the environment: with its TLS settings, adding its headers to
+// every request, and, if it is read only, refusing requests which
+// could modify the system.
+func (env *Environment) Client(timeout time.Duration) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config, err := env.tlsConfig(); err != nil {
+		return nil, err
+	} else if config != nil {
+		transport.TLSClientConfig = config
+	}
+	return &http.Client{Timeout: timeout, Transport: &environmentTransport{Transport: transport, env: env}}, nil
+}
+
+// NewHttpCall creates a new HttpCall whose client (see Client) is
+// configured for the environment, and whose Redactor is
+// env.Redactor().
+func (env *Environment) NewHttpCall(timeout time.Duration) (*HttpCall, error) {
+	if client, err := env.Client(timeout); err != nil {
+		return nil, err
+	} else {
+		hc := NewHttpCall(client)
+		hc.Redactor = env.Redactor()
+		return hc, nil
+	}
+}
+
+// Redactor returns a new copy of DefaultRedactor to which every
+// credential of the environment has been added.
+func (env *Environment) Redactor() *Redactor {
+	redactor := DefaultRedactor.Copy()
+	for _, value := range env.Credentials {
+		redactor.AddValue(value)
+	}
+	return redactor
+}
+
+// environmentTransport adds the environment's headers to requests,
+// and enforces ReadOnly.
+type environmentTransport struct {
+	Transport http.RoundTripper
+	env       *Environment
+}
+
+func (et *environmentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if et.env.ReadOnly && req.Method != http.MethodGet && req.Method != http.MethodHead && req.Method != http.MethodOptions {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("Environment '%s' is read only: refusing %s request.", et.env.Name, req.Method)
+	}
+	missing := []string{}
+	for key := range et.env.Headers {
+		if req.Header.Get(key) == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		req = req.Clone(req.Context())
+		for _, key := range missing {
+			req.Header.Set(key, et.env.Headers[key])
+		}
+	}
+	return et.Transport.RoundTrip(req)
+}
@@ -0,0 +1,136 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testEnvironments = `
+staging:
+  baseURL: ${env.ARGOT_TEST_BASE_URL}
+  services:
+    auth: https://auth.example.com/
+  credentials:
+    apiKey: ${env.ARGOT_TEST_API_KEY}
+  headers:
+    X-Client: argot
+  vars:
+    tenant: acme
+prod-readonly:
+  baseURL: https://example.com
+  readOnly: true
+`
+
+func TestEnvironmentSelect(t *testing.T) {
+	envs, err := ParseEnvironments([]byte(testEnvironments))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Join(envs.Names(), ","); names != "prod-readonly,staging" {
+		t.Fatalf("Names: Expected prod-readonly,staging; found %s.", names)
+	}
+	if _, err := ParseEnvironments([]byte("staging:\n  baseUrl: x\n")); err == nil {
+		t.Fatal("Expected an unknown field to be an error.")
+	}
+
+	os.Setenv("ARGOT_TEST_BASE_URL", "https://staging.example.com/")
+	os.Setenv("ARGOT_TEST_API_KEY", "s3cret")
+	defer os.Unsetenv("ARGOT_TEST_BASE_URL")
+	defer os.Unsetenv("ARGOT_TEST_API_KEY")
+	os.Setenv(EnvironmentEnv, "staging")
+	defer os.Unsetenv(EnvironmentEnv)
+
+	env, err := envs.Select("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Name != "staging" {
+		t.Fatalf("Expected staging to be selected; found %s.", env.Name)
+	} else if url := env.URL("/users"); url != "https://staging.example.com/users" {
+		t.Fatalf("URL: Expected https://staging.example.com/users; found %s.", url)
+	} else if url, err := env.ServiceURL("auth", "/token"); err != nil || url != "https://auth.example.com/token" {
+		t.Fatalf("ServiceURL: Expected https://auth.example.com/token; found %s (%v).", url, err)
+	} else if _, err := env.ServiceURL("billing", "/"); err == nil {
+		t.Fatal("Expected an unknown service to be an error.")
+	} else if key, err := env.Credential("apiKey"); err != nil || key != "s3cret" {
+		t.Fatalf("Credential: Expected s3cret; found %s (%v).", key, err)
+	} else if redacted := env.Redactor().String("key s3cret"); redacted != "key REDACTED" {
+		t.Fatalf("Redactor: Expected the credential to be redacted; found %s.", redacted)
+	} else if DefaultRedactor.String("key s3cret") != "key s3cret" {
+		t.Fatal("Expected the credential not to be added to DefaultRedactor.")
+	}
+	if envs["staging"].BaseURL != "${env.ARGOT_TEST_BASE_URL}" {
+		t.Fatal("Expected Select not to modify the loaded environment.")
+	}
+
+	store := env.Store()
+	for key, expected := range map[string]string{
+		"baseURL":                "https://staging.example.com/",
+		"service.auth":           "https://auth.example.com/",
+		"credential.apiKey":      "s3cret",
+		"tenant":                 "acme",
+		"env.ARGOT_TEST_API_KEY": "s3cret",
+	} {
+		if value, found := store.Get(key); !found || value != expected {
+			t.Fatalf("Store: Expected %s to be %s; found %v.", key, expected, value)
+		}
+	}
+
+	if _, err := envs.Select("dev"); err == nil || !strings.Contains(err.Error(), "prod-readonly, staging") {
+		t.Fatalf("Expected an unknown environment to be an error listing the environments; found %v.", err)
+	}
+	os.Unsetenv("ARGOT_TEST_API_KEY")
+	if _, err := envs.Select("staging"); err == nil {
+		t.Fatal("Expected an unset environment variable to be an error.")
+	}
+	if _, err := envs.Select("prod-readonly"); err != nil {
+		t.Fatalf("Expected only the selected environment's variables to be needed; found %v.", err)
+	}
+}
+
+func TestEnvironmentClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Client", r.Header.Get("X-Client"))
+	}))
+	defer server.Close()
+
+	env := &Environment{Name: "test", BaseURL: server.URL, Headers: map[string]string{"X-Client": "argot"}}
+	hc, err := env.NewHttpCall(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("GET", env.URL("/"), nil),
+		hc.ResponseHeaderEquals("X-Client", "argot"),
+	}.Test(t)
+	hc.Reset()
+	Steps{
+		hc.NewRequest("GET", env.URL("/"), nil),
+		hc.RequestHeader("X-Client", "custom"),
+		hc.ResponseHeaderEquals("X-Client", "custom"),
+	}.Test(t)
+	hc.Reset()
+
+	env.ReadOnly = true
+	if hc, err = env.NewHttpCall(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("GET", env.URL("/"), nil),
+		hc.ResponseStatusEquals(200),
+	}.Test(t)
+	hc.Reset()
+	err = Steps{
+		hc.NewRequest("POST", env.URL("/"), strings.NewReader("{}")),
+		hc.ResponseStatusEquals(200),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "read only") {
+		t.Fatalf("Expected a POST to a read only environment to be refused; found %v.", err)
+	}
+}
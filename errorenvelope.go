@@ -0,0 +1,131 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// ErrorEnvelope describes the standard form of the error responses of
+// a family of services, so that ResponseIsStandardError can check the
+// whole of an error response at once. Paths are as for JSONPath.
+type ErrorEnvelope struct {
+	// The media type of error responses, for example
+	// "application/problem+json". If empty, it is not checked.
+	ContentType string
+	// The status of each error code. The status of a response whose
+	// code is not listed must be a 4xx or 5xx.
+	Statuses map[string]int
+	// The path of the error code, which must equal the expected code.
+	CodePath string
+	// The path of the human-readable message, which must be a
+	// non-empty string. If empty, it is not checked.
+	MessagePath string
+	// The path of the trace ID, which must be a non-empty string. If
+	// empty, it is not checked.
+	TraceIDPath string
+	// If non-empty, the header which must also carry the trace ID, with
+	// the same value as the body.
+	TraceIDHeader string
+	// If non-empty, the path of the status, which must equal the
+	// response's status, as in RFC 9457 problem details.
+	StatusPath string
+}
+
+// DefaultErrorEnvelope is used by HttpCalls that have no ErrorEnvelope
+// of their own. It describes errors of the form:
+//
+//	{"error": {"code": "...", "message": "...", "traceId": "..."}}
+var DefaultErrorEnvelope = &ErrorEnvelope{
+	ContentType: "application/json",
+	CodePath:    "error.code",
+	MessagePath: "error.message",
+	TraceIDPath: "error.traceId",
+}
+
+func (hc *HttpCall) errorEnvelope() *ErrorEnvelope {
+	if hc.ErrorEnvelope == nil {
+		return DefaultErrorEnvelope
+	} else {
+		return hc.ErrorEnvelope
+	}
+}
+
+// ResponseIsStandardError is a Step that when executed ensures there
+// is a non-nil hc.ResponseBody and errors unless it is an error
+// response, with the given code, in the form described by
+// hc.ErrorEnvelope (or DefaultErrorEnvelope if that is nil): its
+// status, content type, code, message and trace ID are all checked,
+// and every discrepancy is reported.
+func (hc *HttpCall) ResponseIsStandardError(code string) Step {
+	return hc.step(fmt.Sprintf("ResponseIsStandardError(%s)", code), func() error {
+		envelope := hc.errorEnvelope()
+		hc.cover("status")
+		if err := hc.ReceiveBody(); err != nil {
+			return err
+		}
+		failures := []string{}
+		status := hc.Response.StatusCode
+		if expected, found := envelope.Statuses[code]; found && status != expected {
+			failures = append(failures, fmt.Sprintf("Status: Expected %d; found %d.", expected, status))
+		} else if !found && status < 400 {
+			failures = append(failures, fmt.Sprintf("Status: Expected 4xx or 5xx; found %d.", status))
+		}
+		if envelope.ContentType != "" {
+			hc.coverHeader("Content-Type")
+			if mediaType, _, _ := mime.ParseMediaType(hc.Response.Header.Get("Content-Type")); mediaType != envelope.ContentType {
+				failures = append(failures, fmt.Sprintf("Content-Type: Expected %s; found '%s'.", envelope.ContentType, hc.Response.Header.Get("Content-Type")))
+			}
+		}
+
+		var doc interface{}
+		decoder := json.NewDecoder(bytes.NewReader(hc.ResponseBody))
+		decoder.UseNumber()
+		if err := decoder.Decode(&doc); err != nil {
+			failures = append(failures, fmt.Sprintf("Body: Expected JSON: %v", err))
+			return fmt.Errorf("Error envelope:\n\t%s", strings.Join(failures, "\n\t"))
+		}
+		lookup := func(path string) (interface{}, bool) {
+			hc.coverJSONPath(path)
+			value, err := JSONPath(doc, path)
+			if err != nil {
+				failures = append(failures, err.Error())
+				return nil, false
+			}
+			return value, true
+		}
+		if value, ok := lookup(envelope.CodePath); ok && fmt.Sprint(value) != code {
+			failures = append(failures, fmt.Sprintf("Code: Expected '%s'; found '%v'.", code, value))
+		}
+		if envelope.MessagePath != "" {
+			if value, ok := lookup(envelope.MessagePath); ok {
+				if str, isStr := value.(string); !isStr || str == "" {
+					failures = append(failures, fmt.Sprintf("Message: Expected a non-empty string; found '%v'.", value))
+				}
+			}
+		}
+		if envelope.TraceIDPath != "" {
+			if value, ok := lookup(envelope.TraceIDPath); ok {
+				if str, isStr := value.(string); !isStr || str == "" {
+					failures = append(failures, fmt.Sprintf("Trace ID: Expected a non-empty string; found '%v'.", value))
+				} else if envelope.TraceIDHeader != "" {
+					hc.coverHeader(envelope.TraceIDHeader)
+					if header := hc.Response.Header.Get(envelope.TraceIDHeader); header != str {
+						failures = append(failures, fmt.Sprintf("Trace ID: Expected header %s to be '%s'; found '%s'.", envelope.TraceIDHeader, str, header))
+					}
+				}
+			}
+		}
+		if envelope.StatusPath != "" {
+			if value, ok := lookup(envelope.StatusPath); ok && fmt.Sprint(value) != fmt.Sprint(status) {
+				failures = append(failures, fmt.Sprintf("Status in body: Expected %d; found '%v'.", status, value))
+			}
+		}
+		if len(failures) != 0 {
+			return fmt.Errorf("Error envelope:\n\t%s", strings.Join(failures, "\n\t"))
+		}
+		return nil
+	})
+}
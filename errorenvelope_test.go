@@ -0,0 +1,85 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseIsStandardError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Header().Set("X-Trace-Id", "abc123")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":"tea_not_found","message":"No such tea.","traceId":"abc123"}}`))
+		case "/sloppy":
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"error":{"code":"oops","message":""}}`))
+		case "/problem":
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"type":"about:blank","code":"duplicate","detail":"Already exists.","status":409}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Internal error"))
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	if err := (Steps{
+		hc.NewRequest(http.MethodGet, server.URL+"/missing", nil),
+		hc.ResponseIsStandardError("tea_not_found"),
+	}).Go(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Steps{
+		hc.NewRequest(http.MethodGet, server.URL+"/sloppy", nil),
+		hc.ResponseIsStandardError("tea_not_found"),
+	}.Go()
+	expected := "Error envelope:\n\t" + strings.Join([]string{
+		"Status: Expected 4xx or 5xx; found 200.",
+		"Content-Type: Expected application/json; found 'text/plain'.",
+		"Code: Expected 'tea_not_found'; found 'oops'.",
+		"Message: Expected a non-empty string; found ''.",
+		"JSON path 'error.traceId': Key 'traceId' not found.",
+	}, "\n\t")
+	if hcErr, ok := err.(*HttpCallError); !ok || hcErr.Err.Error() != expected {
+		t.Fatalf("Expected every discrepancy to be reported; found %v", err)
+	}
+
+	err = Steps{
+		hc.NewRequest(http.MethodGet, server.URL+"/broken", nil),
+		hc.ResponseIsStandardError("internal"),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "Body: Expected JSON: ") {
+		t.Fatalf("Expected a non-JSON body to be reported; found %v", err)
+	}
+
+	hc.ErrorEnvelope = &ErrorEnvelope{
+		ContentType: "application/problem+json",
+		Statuses:    map[string]int{"duplicate": http.StatusConflict},
+		CodePath:    "code",
+		MessagePath: "detail",
+		StatusPath:  "status",
+	}
+	if err := (Steps{
+		hc.NewRequest(http.MethodPost, server.URL+"/problem", nil),
+		hc.ResponseIsStandardError("duplicate"),
+	}).Go(); err != nil {
+		t.Fatal(err)
+	}
+	hc.ErrorEnvelope.Statuses["duplicate"] = http.StatusUnprocessableEntity
+	err = Steps{
+		hc.NewRequest(http.MethodPost, server.URL+"/problem", nil),
+		hc.ResponseIsStandardError("duplicate"),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "Status: Expected 422; found 409.") {
+		t.Fatalf("Expected the status to be reported; found %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OptimisticConcurrency is a Step that when executed checks that the
+// resource at urlStr is protected by ETag-based optimistic
+// concurrency control: it GETs the resource, which must have status
+// 200 and an ETag, then PUTs first (with the given Content-Type) with
+// If-Match set to the ETag, which must succeed with a 2xx status, and
+// finally PUTs second with the same, now stale, If-Match, which must
+// be refused with 412 Precondition Failed, as the second of two
+// conflicting updates would be. If the first PUT's response has an
+// ETag, it must differ from the original. hc is left holding the
+// response to the second PUT.
+func (hc *HttpCall) OptimisticConcurrency(urlStr, contentType, first, second string) Step {
+	return hc.step(fmt.Sprintf("OptimisticConcurrency(%s)", hc.redactor().String(urlStr)), func() error {
+		put := func(body, etag string) Steps {
+			return Steps{
+				hc.NewRequest(http.MethodPut, urlStr, strings.NewReader(body)),
+				hc.RequestHeader("Content-Type", contentType),
+				hc.RequestHeader("If-Match", etag),
+				hc.Call(),
+			}
+		}
+		unwrap := func(stage string, err error) error {
+			if hcErr, ok := err.(*HttpCallError); ok {
+				err = hcErr.Err
+			}
+			return fmt.Errorf("%s: %v", stage, err)
+		}
+		if err := (Steps{hc.NewRequest(http.MethodGet, urlStr, nil), hc.ResponseStatusEquals(http.StatusOK)}).Go(); err != nil {
+			return unwrap("GET", err)
+		}
+		hc.coverHeader("ETag")
+		etag := hc.Response.Header.Get("ETag")
+		if etag == "" {
+			return errors.New("GET: Expected an ETag; found none.")
+		} else if err := put(first, etag).Go(); err != nil {
+			return unwrap("First PUT", err)
+		} else if status := hc.Response.StatusCode; status < 200 || status > 299 {
+			return fmt.Errorf("First PUT: Status: Expected 2xx; found %d.", status)
+		} else if updated := hc.Response.Header.Get("ETag"); updated == etag {
+			return fmt.Errorf("First PUT: Expected the ETag to change from %s; found it unchanged.", etag)
+		} else if err := put(second, etag).Go(); err != nil {
+			return unwrap("Second PUT", err)
+		} else if status := hc.Response.StatusCode; status != http.StatusPreconditionFailed {
+			return fmt.Errorf("Second PUT: Status: Expected %d with the stale ETag %s; found %d.", http.StatusPreconditionFailed, etag, status)
+		} else {
+			return nil
+		}
+	})
+}
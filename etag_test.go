@@ -0,0 +1,48 @@
+package argot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOptimisticConcurrency(t *testing.T) {
+	var lock sync.Mutex
+	version, body := 1, "earl grey"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		etag := fmt.Sprintf(`"v%d"`, version)
+		switch {
+		case r.Method == http.MethodGet:
+		case r.URL.Path == "/checked" && r.Header.Get("If-Match") != etag:
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		default:
+			bites, _ := ioutil.ReadAll(r.Body)
+			version, body = version+1, string(bites)
+			etag = fmt.Sprintf(`"v%d"`, version)
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.OptimisticConcurrency(server.URL+"/checked", "text/plain", "assam", "darjeeling"),
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseBodyEquals("assam"),
+	}.Test(t)
+
+	if _, err := (Steps{hc.OptimisticConcurrency(server.URL+"/unchecked", "text/plain", "assam", "darjeeling")}).Test(nil); err == nil {
+		t.Fatal("Expected the lost update to be detected.")
+	} else if !strings.Contains(err.Error(), "Second PUT: Status: Expected 412 with the stale ETag") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
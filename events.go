@@ -0,0 +1,162 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of an Event.
+type EventType int
+
+const (
+	// ScenarioStarted is sent when RunScenario starts a scenario.
+	ScenarioStarted EventType = iota
+	// ScenarioFinished is sent when RunScenario finishes a scenario.
+	ScenarioFinished
+	// StepStarted is sent before a step runs.
+	StepStarted
+	// StepFinished is sent after a step has run, whether or not it
+	// succeeded.
+	StepFinished
+	// RequestFinished is sent when an HttpCall receives a response,
+	// or fails to.
+	RequestFinished
+)
+
+func (et EventType) String() string {
+	switch et {
+	case ScenarioStarted:
+		return "ScenarioStarted"
+	case ScenarioFinished:
+		return "ScenarioFinished"
+	case StepStarted:
+		return "StepStarted"
+	case StepFinished:
+		return "StepFinished"
+	case RequestFinished:
+		return "RequestFinished"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(et))
+	}
+}
+
+// Event describes progress whilst steps run, so that external tools
+// can present running scenarios live (see AddEventListener). Steps
+// within nested Steps and Groups send their own events, between those
+// of the enclosing step.
+type Event struct {
+	Type EventType
+	Time time.Time
+	// The scenario name, for ScenarioStarted and ScenarioFinished.
+	Scenario string
+	// The parameters of the scenario's case (see RunTable), if any, for
+	// ScenarioStarted and ScenarioFinished.
+	Params Case
+	// The step name, for StepStarted and StepFinished.
+	Step string
+	// How long the scenario, step or request took, for the Finished
+	// events.
+	Duration time.Duration
+	// The request, for RequestFinished.
+	Method string
+	URL    string
+	// The response status for RequestFinished, or 0 if there is none.
+	Status int
+	// The response headers for RequestFinished, or nil if there is no
+	// response.
+	Header http.Header
+	// The error, if any, for the Finished events.
+	Err error
+	// True, for RequestFinished, if the request was made whilst warming
+	// up (see WarmupStep).
+	Warmup bool
+}
+
+var (
+	listenersLock sync.RWMutex
+	listeners     = make(map[int]func(Event))
+	nextListener  int
+)
+
+// AddEventListener registers listener to be called with every Event
+// from now on, and returns a function which unregisters it. listener
+// is called synchronously from the go-routine running the steps, so
+// it should be quick, and must be safe for concurrent use if steps
+// run concurrently.
+func AddEventListener(listener func(Event)) func() {
+	listenersLock.Lock()
+	defer listenersLock.Unlock()
+	id := nextListener
+	nextListener++
+	listeners[id] = listener
+	return func() {
+		listenersLock.Lock()
+		defer listenersLock.Unlock()
+		delete(listeners, id)
+	}
+}
+
+// EventChannel registers a listener which sends every Event to the
+// returned channel, which has the given buffer size. So that a slow
+// reader cannot stall the steps, events which do not fit in the buffer
+// are dropped. The returned function unregisters the listener and
+// closes the channel.
+func EventChannel(buffer int) (<-chan Event, func()) {
+	events := make(chan Event, buffer)
+	var lock sync.Mutex
+	closed := false
+	remove := AddEventListener(func(event Event) {
+		lock.Lock()
+		defer lock.Unlock()
+		if !closed {
+			select {
+			case events <- event:
+			default:
+			}
+		}
+	})
+	return events, func() {
+		remove()
+		lock.Lock()
+		defer lock.Unlock()
+		if !closed {
+			closed = true
+			close(events)
+		}
+	}
+}
+
+func currentListeners() []func(Event) {
+	listenersLock.RLock()
+	defer listenersLock.RUnlock()
+	current := make([]func(Event), 0, len(listeners))
+	for _, listener := range listeners {
+		current = append(current, listener)
+	}
+	return current
+}
+
+func emit(event Event) {
+	if current := currentListeners(); len(current) > 0 {
+		event.Time = time.Now()
+		for _, listener := range current {
+			listener(event)
+		}
+	}
+}
+
+// runStep runs step, sending StepStarted and StepFinished events.
+func runStep(step Step) (time.Duration, error) {
+	name := ""
+	if len(currentListeners()) > 0 {
+		name = DefaultRedactor.String(fmt.Sprint(step))
+	}
+	emit(Event{Type: StepStarted, Step: name})
+	start := time.Now()
+	err := step.Go()
+	duration := time.Since(start)
+	emit(Event{Type: StepFinished, Step: name, Duration: duration, Err: err})
+	return duration, err
+}
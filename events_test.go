@@ -0,0 +1,67 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEventChannel(t *testing.T) {
+	hc := NewHandlerCall(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer hc.Reset()
+
+	events, stop := EventChannel(100)
+	result := RunScenario("brew", Steps{
+		hc.NewRequest("GET", "http://example.com/pot", nil),
+		Steps{hc.ResponseStatusEquals(http.StatusTeapot)},
+		NewNamedStep("fail", func() error { return errors.New("cold") }),
+	})
+	stop()
+	if result.Passed() {
+		t.Fatal("Expected the scenario to fail.")
+	}
+
+	found := []string{}
+	for event := range events {
+		summary := event.Type.String()
+		if event.Step != "" {
+			summary += " " + event.Step
+		} else if event.Scenario != "" {
+			summary += " " + event.Scenario
+		} else if event.URL != "" {
+			summary += fmt.Sprintf(" %s %s %d", event.Method, event.URL, event.Status)
+		}
+		if event.Err != nil {
+			summary += ": " + event.Err.Error()
+		}
+		found = append(found, summary)
+	}
+	expected := []string{
+		"ScenarioStarted brew",
+		"StepStarted NewRequest(GET: http://example.com/pot)",
+		"StepFinished NewRequest(GET: http://example.com/pot)",
+		"StepStarted [ResponseStatusEquals(418)]",
+		"StepStarted ResponseStatusEquals(418)",
+		"RequestFinished GET http://example.com/pot 418",
+		"StepFinished ResponseStatusEquals(418)",
+		"StepFinished [ResponseStatusEquals(418)]",
+		"StepStarted fail",
+		"StepFinished fail: cold",
+		"ScenarioFinished brew: cold",
+	}
+	if strings.Join(found, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected events:\n%s\nfound:\n%s", strings.Join(expected, "\n"), strings.Join(found, "\n"))
+	}
+
+	// Once stopped, no more events are sent.
+	if _, err := (Steps{StepFunc(func() error { return nil })}).Test(t); err != nil {
+		t.Fatal(err)
+	}
+	if len(currentListeners()) != 0 {
+		t.Fatal("Expected no listeners.")
+	}
+}
@@ -0,0 +1,113 @@
+package argot
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// ExpectError is a Step that when executed runs the given step and
+// errors unless that step errors. Prefer the dedicated negated steps
+// (ExpectNotNil, ExpectNotDeepEqual, ExpectNotContains) where they
+// exist as their failure messages are clearer. So that a negative test
+// shows what failed, the error of the given step is attached to the
+// result (see RunScenario) as the artifact "expected-error.txt", along
+// with any artifacts of the step and its error.
+func ExpectError(step Step) Step {
+	return NewArtifactStep(fmt.Sprintf("ExpectError(%v)", step), func(attach func(name, mimeType string, data []byte)) error {
+		if err := step.Go(); err == nil {
+			return fmt.Errorf("Expected step '%v' to error; it succeeded.", step)
+		} else {
+			msg := fmt.Sprintf("Step: %v\nError: %v\n", step, err)
+			attach("expected-error.txt", "text/plain", []byte(DefaultRedactor.String(msg)))
+			for _, artifact := range stepArtifacts(step, err) {
+				attach(artifact.Name, artifact.MIMEType, artifact.Data)
+			}
+			return nil
+		}
+	})
+}
+
+// ExpectNil is a Step that when executed errors unless value is nil
+// (including typed nils such as nil pointers, maps and slices).
+func ExpectNil(value interface{}) Step {
+	return NewNamedStep("ExpectNil", func() error {
+		if !isNil(value) {
+			return fmt.Errorf("Expected nil; found %v.", value)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectNotNil is a Step that when executed errors if value is nil
+// (including typed nils such as nil pointers, maps and slices).
+func ExpectNotNil(value interface{}) Step {
+	return NewNamedStep("ExpectNotNil", func() error {
+		if isNil(value) {
+			return fmt.Errorf("Expected a non-nil value; found %#v.", value)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectDeepEqual is a Step that when executed errors unless expected
+// and actual are equal according to reflect.DeepEqual.
+func ExpectDeepEqual(expected, actual interface{}) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectDeepEqual(%v)", expected), func() error {
+		if !reflect.DeepEqual(expected, actual) {
+			return fmt.Errorf("Expected %v; found %v.", expected, actual)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectNotDeepEqual is a Step that when executed errors if
+// unexpected and actual are equal according to reflect.DeepEqual.
+func ExpectNotDeepEqual(unexpected, actual interface{}) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectNotDeepEqual(%v)", unexpected), func() error {
+		if reflect.DeepEqual(unexpected, actual) {
+			return fmt.Errorf("Expected any value other than %v; found exactly that.", unexpected)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectContains is a Step that when executed errors unless s
+// contains substr, using strings.Contains.
+func ExpectContains(s, substr string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectContains(%s)", substr), func() error {
+		if !strings.Contains(s, substr) {
+			return fmt.Errorf("Expected '%s' to contain '%s'.", s, substr)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectNotContains is a Step that when executed errors if s
+// contains substr, using strings.Contains.
+func ExpectNotContains(s, substr string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectNotContains(%s)", substr), func() error {
+		if idx := strings.Index(s, substr); idx != -1 {
+			return fmt.Errorf("Expected '%s' not to contain '%s'; found it at offset %d.", s, substr, idx)
+		} else {
+			return nil
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package argot
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	fakeFirstNames = []string{
+		"Ada", "Alan", "Amara", "Barbara", "Chen", "Dmitri", "Edsger", "Fatima",
+		"Grace", "Hedy", "Ines", "Jun", "Katherine", "Linus", "Margaret", "Niklaus",
+		"Olu", "Priya", "Radia", "Sofia", "Tim", "Uma", "Yukihiro", "Zainab",
+	}
+	fakeLastNames = []string{
+		"Allen", "Babbage", "Dijkstra", "Eriksson", "Hamilton", "Hopper", "Johnson", "Kapoor",
+		"Knuth", "Lamarr", "Liskov", "Lovelace", "Martinez", "Nakamura", "Okafor", "Perlman",
+		"Ritchie", "Silva", "Thompson", "Turing", "Wirth", "Yamamoto", "Zhang", "Ziegler",
+	}
+	fakeDomains = []string{"example.com", "example.org", "example.net"}
+	fakeWords   = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing elit sed do
+		eiusmod tempor incididunt ut labore et dolore magna aliqua enim ad minim veniam quis
+		nostrud exercitation ullamco laboris nisi aliquip ex ea commodo consequat duis aute irure
+		in reprehenderit voluptate velit esse cillum fugiat nulla pariatur excepteur sint occaecat
+		cupidatat non proident sunt culpa qui officia deserunt mollit anim id est laborum`)
+)
+
+// Faker generates plausible test data, such as names, email addresses
+// and UUIDs, deterministically from a seed: two Fakers with the same
+// seed generate the same values in the same order, so that a failing
+// run can be reproduced. Email addresses use the domains reserved for
+// examples, and phone numbers the range reserved for fiction, so that
+// generated data can never reach a real person. A Faker is safe for
+// concurrent use, though values are then generated in an
+// unpredictable order.
+type Faker struct {
+	lock sync.Mutex
+	rand *rand.Rand
+}
+
+// NewFaker creates a new Faker generating values from seed.
+func NewFaker(seed int64) *Faker {
+	return &Faker{rand: rand.New(rand.NewSource(seed))}
+}
+
+// NewRandomFaker creates a new Faker seeded from argot's random number
+// generator (see Seed), so that its values differ from run to run yet
+// a failing run can be reproduced.
+func NewRandomFaker() *Faker {
+	return NewFaker(randInt63n(1<<62) + 1)
+}
+
+func (f *Faker) intn(n int) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.rand.Intn(n)
+}
+
+func (f *Faker) pick(values []string) string {
+	return values[f.intn(len(values))]
+}
+
+// FirstName returns a first name.
+func (f *Faker) FirstName() string {
+	return f.pick(fakeFirstNames)
+}
+
+// LastName returns a last name.
+func (f *Faker) LastName() string {
+	return f.pick(fakeLastNames)
+}
+
+// Name returns a full name.
+func (f *Faker) Name() string {
+	return f.FirstName() + " " + f.LastName()
+}
+
+// Email returns an email address at one of the example domains.
+func (f *Faker) Email() string {
+	return fmt.Sprintf("%s.%s%d@%s", strings.ToLower(f.FirstName()), strings.ToLower(f.LastName()), f.intn(1000), f.pick(fakeDomains))
+}
+
+// UUID returns a random (version 4) UUID.
+func (f *Faker) UUID() string {
+	buf := make([]byte, 16)
+	f.lock.Lock()
+	f.rand.Read(buf)
+	f.lock.Unlock()
+	buf[6] = buf[6]&0x0f | 0x40
+	buf[8] = buf[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:])
+}
+
+// Phone returns a North American phone number in the range 555-0100 to
+// 555-0199, which is reserved for fictional use.
+func (f *Faker) Phone() string {
+	return fmt.Sprintf("+1-%d-555-01%02d", 200+f.intn(800), f.intn(100))
+}
+
+// Int returns an int from min to max inclusive.
+func (f *Faker) Int(min, max int) int {
+	return min + f.intn(max-min+1)
+}
+
+// Lorem returns n words of lorem ipsum text.
+func (f *Faker) Lorem(n int) string {
+	words := make([]string, n)
+	for idx := range words {
+		words[idx] = f.pick(fakeWords)
+	}
+	return strings.Join(words, " ")
+}
+
+// FakeLorem returns a function generating n words of lorem ipsum text,
+// for use with Faker.SetStep and Faker.Populate.
+func FakeLorem(n int) func(*Faker) string {
+	return func(f *Faker) string {
+		return f.Lorem(n)
+	}
+}
+
+// SetStep is a Step that when executed sets key in store to the value
+// generated by fake, typically a method expression such as
+// (*Faker).Email.
+func (f *Faker) SetStep(store *Store, key string, fake func(*Faker) string) Step {
+	return NewNamedStep(fmt.Sprintf("Fake(%s)", key), func() error {
+		store.Set(key, fake(f))
+		return nil
+	})
+}
+
+// Populate is a Step that when executed sets each key of fakes in
+// store to the value generated by the corresponding function, in
+// order of key so that the values are deterministic. For example:
+//
+//	faker.Populate(store, map[string]func(*argot.Faker) string{
+//		"name":  (*argot.Faker).Name,
+//		"email": (*argot.Faker).Email,
+//		"bio":   argot.FakeLorem(12),
+//	})
+func (f *Faker) Populate(store *Store, fakes map[string]func(*Faker) string) Step {
+	keys := make([]string, 0, len(fakes))
+	for key := range fakes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return NewNamedStep(fmt.Sprintf("Populate(%s)", strings.Join(keys, ", ")), func() error {
+		for _, key := range keys {
+			store.Set(key, fakes[key](f))
+		}
+		return nil
+	})
+}
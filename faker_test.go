@@ -0,0 +1,54 @@
+package argot
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFaker(t *testing.T) {
+	fakes := map[string]func(*Faker) string{
+		"name":  (*Faker).Name,
+		"email": (*Faker).Email,
+		"id":    (*Faker).UUID,
+		"phone": (*Faker).Phone,
+		"bio":   FakeLorem(5),
+	}
+	first, second, other := NewStore(), NewStore(), NewStore()
+	Steps{
+		NewFaker(7).Populate(first, fakes),
+		NewFaker(7).Populate(second, fakes),
+		NewFaker(8).Populate(other, fakes),
+	}.Test(t)
+
+	for key := range fakes {
+		if first.GetString(key) != second.GetString(key) {
+			t.Fatalf("%s: Expected the same seed to generate the same value; found %s and %s.", key, first.GetString(key), second.GetString(key))
+		}
+	}
+	if first.GetString("id") == other.GetString("id") {
+		t.Fatal("Expected different seeds to generate different values.")
+	}
+	for key, pattern := range map[string]string{
+		"name":  `^[A-Z][a-z]+ [A-Z][a-z]+$`,
+		"email": `^[a-z]+\.[a-z]+\d+@example\.(com|org|net)$`,
+		"id":    uuidPattern.String(),
+		"phone": `^\+1-\d{3}-555-01\d\d$`,
+		"bio":   `^[a-z]+( [a-z]+){4}$`,
+	} {
+		if value := first.GetString(key); !regexp.MustCompile(pattern).MatchString(value) {
+			t.Fatalf("%s: Expected to match %s; found %s.", key, pattern, value)
+		}
+	}
+
+	faker, store := NewFaker(1), NewStore()
+	Steps{faker.SetStep(store, "email", (*Faker).Email)}.Test(t)
+	if !strings.Contains(store.GetString("email"), "@example.") {
+		t.Fatalf("Unexpected email %s.", store.GetString("email"))
+	}
+	for idx := 0; idx < 100; idx++ {
+		if n := faker.Int(3, 5); n < 3 || n > 5 {
+			t.Fatalf("Int: Expected 3 to 5; found %d.", n)
+		}
+	}
+}
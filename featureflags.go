@@ -0,0 +1,284 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// FeatureFlagProvider reads and writes the feature flags of the
+// system under test. Values are those of JSON: typically bool, but
+// also string, float64 and so on.
+type FeatureFlagProvider interface {
+	// Get returns the value of flag.
+	Get(flag string) (interface{}, error)
+	// Set sets the value of flag.
+	Set(flag string, value interface{}) error
+}
+
+// doFeatureFlagRequest makes the request, with header, and decodes the
+// JSON response, if any, into result.
+func doFeatureFlagRequest(client *http.Client, header http.Header, method, urlStr, contentType string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		bites, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(bites)
+	}
+	req, err := http.NewRequest(method, urlStr, reader)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	bites, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	} else if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s %s: Status: Expected 2xx; found %d: '%s'.", method, urlStr, response.StatusCode, string(bites))
+	} else if result != nil {
+		return json.Unmarshal(bites, result)
+	} else {
+		return nil
+	}
+}
+
+// HTTPFeatureFlags is a FeatureFlagProvider for a simple HTTP flag
+// service, or an admin endpoint of the system under test: the value
+// of a flag is the JSON body of URL/FLAG, read with GET and written
+// with PUT.
+type HTTPFeatureFlags struct {
+	URL string
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+	// Sent with every request, for example to authenticate.
+	Header http.Header
+}
+
+// NewHTTPFeatureFlags creates a new HTTPFeatureFlags for the flags
+// beneath url.
+func NewHTTPFeatureFlags(url string) *HTTPFeatureFlags {
+	return &HTTPFeatureFlags{URL: url}
+}
+
+func (f *HTTPFeatureFlags) flagURL(flag string) string {
+	return strings.TrimSuffix(f.URL, "/") + "/" + url.PathEscape(flag)
+}
+
+// Get GETs the value of flag.
+func (f *HTTPFeatureFlags) Get(flag string) (interface{}, error) {
+	var value interface{}
+	err := doFeatureFlagRequest(f.Client, f.Header, http.MethodGet, f.flagURL(flag), "", nil, &value)
+	return value, err
+}
+
+// Set PUTs the value of flag.
+func (f *HTTPFeatureFlags) Set(flag string, value interface{}) error {
+	return doFeatureFlagRequest(f.Client, f.Header, http.MethodPut, f.flagURL(flag), "application/json", value, nil)
+}
+
+// LaunchDarklyFlags is a FeatureFlagProvider for the flags of one
+// environment of a LaunchDarkly project, through LaunchDarkly's REST
+// API. A flag's value is whether its targeting is on, as a bool:
+// setting true or false turns targeting on or off, whereupon the flag
+// serves its targeting rules or its off variation. Other values are
+// not supported.
+type LaunchDarklyFlags struct {
+	// The API's base URL. If empty, https://app.launchdarkly.com is
+	// used.
+	BaseURL     string
+	AccessToken string
+	Project     string
+	Environment string
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// NewLaunchDarklyFlags creates a new LaunchDarklyFlags for the
+// environment of the project, authenticating with accessToken.
+func NewLaunchDarklyFlags(accessToken, project, environment string) *LaunchDarklyFlags {
+	return &LaunchDarklyFlags{AccessToken: accessToken, Project: project, Environment: environment}
+}
+
+func (f *LaunchDarklyFlags) flagURL(flag string) string {
+	base := f.BaseURL
+	if base == "" {
+		base = "https://app.launchdarkly.com"
+	}
+	return fmt.Sprintf("%s/api/v2/flags/%s/%s", strings.TrimSuffix(base, "/"), url.PathEscape(f.Project), url.PathEscape(flag))
+}
+
+// Get returns whether the targeting of flag is on.
+func (f *LaunchDarklyFlags) Get(flag string) (interface{}, error) {
+	result := struct {
+		Environments map[string]struct {
+			On bool `json:"on"`
+		} `json:"environments"`
+	}{}
+	header := http.Header{"Authorization": {f.AccessToken}}
+	if err := doFeatureFlagRequest(f.Client, header, http.MethodGet, f.flagURL(flag)+"?env="+url.QueryEscape(f.Environment), "", nil, &result); err != nil {
+		return nil, err
+	} else if env, found := result.Environments[f.Environment]; !found {
+		return nil, fmt.Errorf("LaunchDarkly: Flag %s: Environment %s not found.", flag, f.Environment)
+	} else {
+		return env.On, nil
+	}
+}
+
+// Set turns the targeting of flag on or off, as value is true or
+// false.
+func (f *LaunchDarklyFlags) Set(flag string, value interface{}) error {
+	on, ok := value.(bool)
+	if !ok {
+		return fmt.Errorf("LaunchDarkly: Flag %s: Expected a bool; found %T.", flag, value)
+	}
+	kind := "turnFlagOff"
+	if on {
+		kind = "turnFlagOn"
+	}
+	body := map[string]interface{}{
+		"environmentKey": f.Environment,
+		"instructions":   []interface{}{map[string]interface{}{"kind": kind}},
+	}
+	header := http.Header{"Authorization": {f.AccessToken}}
+	return doFeatureFlagRequest(f.Client, header, http.MethodPatch, f.flagURL(flag), "application/json; domain-model=launchdarkly.semanticpatch", body, nil)
+}
+
+// FeatureFlags provides steps which set the feature flags of a
+// FeatureFlagProvider for the duration of a scenario, remembering the
+// value each flag had before it was first set so that Restore can
+// return the flags to how they were found. Use WithFlags to guarantee
+// restoration even if the scenario fails. A FeatureFlags can only be
+// used by a single go-routine at a time.
+type FeatureFlags struct {
+	Provider FeatureFlagProvider
+
+	originals map[string]interface{}
+	order     []string
+}
+
+// NewFeatureFlags creates a new FeatureFlags using provider.
+func NewFeatureFlags(provider FeatureFlagProvider) *FeatureFlags {
+	return &FeatureFlags{Provider: provider}
+}
+
+// SetFlag is a Step that when executed sets flag to value, first
+// remembering its value, if it has not already been set, for Restore.
+func (ff *FeatureFlags) SetFlag(flag string, value interface{}) Step {
+	return NewNamedStep(fmt.Sprintf("SetFlag(%s: %v)", flag, value), func() error {
+		if _, found := ff.originals[flag]; !found {
+			original, err := ff.Provider.Get(flag)
+			if err != nil {
+				return fmt.Errorf("Flag %s: %v", flag, err)
+			}
+			if ff.originals == nil {
+				ff.originals = make(map[string]interface{})
+			}
+			ff.originals[flag] = original
+			ff.order = append(ff.order, flag)
+		}
+		if err := ff.Provider.Set(flag, value); err != nil {
+			return fmt.Errorf("Flag %s: %v", flag, err)
+		}
+		return nil
+	})
+}
+
+// Restore is a Step that when executed returns every flag set by
+// SetFlag to the value it had before, in reverse order, continuing
+// past failures (see AllOf). Flags restored are forgotten, so that
+// Restore may safely be run more than once.
+func (ff *FeatureFlags) Restore() Step {
+	return NewNamedStep("RestoreFlags", func() error {
+		steps := []Step{}
+		for idx := len(ff.order) - 1; idx >= 0; idx-- {
+			flag := ff.order[idx]
+			original := ff.originals[flag]
+			steps = append(steps, NewNamedStep(fmt.Sprintf("RestoreFlag(%s: %v)", flag, original), func() error {
+				if err := ff.Provider.Set(flag, original); err != nil {
+					return fmt.Errorf("Flag %s: %v", flag, err)
+				}
+				delete(ff.originals, flag)
+				return nil
+			}))
+		}
+		err := AllOf(steps...).Go()
+		order := ff.order[:0]
+		for _, flag := range ff.order {
+			if _, found := ff.originals[flag]; found {
+				order = append(order, flag)
+			}
+		}
+		ff.order = order
+		return err
+	})
+}
+
+// WithFlags is a Step that when executed sets each of flags (in the
+// order of their names), runs steps, and then, whether or not they
+// succeed, restores the flags (see Restore). It errors if setting the
+// flags, any of steps, or restoring the flags fails.
+func (ff *FeatureFlags) WithFlags(flags map[string]interface{}, steps Steps) Step {
+	names := make([]string, 0, len(flags))
+	for _, name := range sortedInterfaceKeys(flags) {
+		names = append(names, fmt.Sprintf("%s: %v", name, flags[name]))
+	}
+	return NewNamedStep(fmt.Sprintf("WithFlags(%s)", strings.Join(names, ", ")), func() error {
+		var err error
+		for _, flag := range sortedInterfaceKeys(flags) {
+			if err = ff.SetFlag(flag, flags[flag]).Go(); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = steps.Go()
+		}
+		if restoreErr := ff.Restore().Go(); err == nil {
+			err = restoreErr
+		} else if restoreErr != nil {
+			err = fmt.Errorf("%v\nRestoring flags: %v", err, restoreErr)
+		}
+		return err
+	})
+}
+
+// ExpectFlag is a Step that when executed errors unless flag has
+// value, compared as JSON.
+func (ff *FeatureFlags) ExpectFlag(flag string, value interface{}) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectFlag(%s: %v)", flag, value), func() error {
+		found, err := ff.Provider.Get(flag)
+		if err != nil {
+			return fmt.Errorf("Flag %s: %v", flag, err)
+		}
+		var expected interface{}
+		if bites, err := json.Marshal(value); err != nil {
+			return err
+		} else if err := json.Unmarshal(bites, &expected); err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(expected, found) {
+			return fmt.Errorf("Flag %s: Expected %v; found %v.", flag, expected, found)
+		}
+		return nil
+	})
+}
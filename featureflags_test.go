@@ -0,0 +1,97 @@
+package argot
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	var lock sync.Mutex
+	flags := map[string]interface{}{"new-checkout": false, "max-items": 10.0}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		name := strings.TrimPrefix(r.URL.Path, "/flags/")
+		if r.Method == http.MethodPut {
+			var value interface{}
+			if err := json.NewDecoder(r.Body).Decode(&value); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			flags[name] = value
+		} else if value, found := flags[name]; found {
+			json.NewEncoder(w).Encode(value)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	checkout := func() Steps {
+		return Steps{
+			hc.NewRequest("GET", server.URL+"/flags/new-checkout", nil),
+			hc.ResponseBodyEquals("true\n"),
+		}
+	}
+
+	ff := NewFeatureFlags(NewHTTPFeatureFlags(server.URL + "/flags"))
+	Steps{
+		ff.WithFlags(map[string]interface{}{"new-checkout": true, "max-items": 3}, Steps{
+			ff.ExpectFlag("max-items", 3),
+			checkout()[0], checkout()[1],
+			ff.SetFlag("new-checkout", "beta"),
+		}),
+		ff.ExpectFlag("new-checkout", false),
+		ff.ExpectFlag("max-items", 10),
+	}.Test(t)
+
+	// Flags are restored even if the steps fail.
+	err := ff.WithFlags(map[string]interface{}{"new-checkout": true}, Steps{
+		NewNamedStep("fail", func() error { return errors.New("boom") }),
+	}).Go()
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("Expected the steps' error; found %v.", err)
+	} else if err := ff.ExpectFlag("new-checkout", false).Go(); err != nil {
+		t.Fatal(err)
+	} else if err := ff.SetFlag("missing", true).Go(); err == nil || !strings.Contains(err.Error(), "Flag missing: GET ") {
+		t.Fatalf("Expected an unknown flag to fail; found %v.", err)
+	}
+}
+
+func TestLaunchDarklyFlags(t *testing.T) {
+	on := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "api-token" || r.URL.Path != "/api/v2/flags/shop/new-checkout" {
+			w.WriteHeader(http.StatusNotFound)
+		} else if r.Method == http.MethodPatch {
+			body, _ := ioutil.ReadAll(r.Body)
+			if r.Header.Get("Content-Type") != "application/json; domain-model=launchdarkly.semanticpatch" || !strings.Contains(string(body), `"environmentKey":"staging"`) {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			on = strings.Contains(string(body), "turnFlagOn")
+		} else if r.URL.Query().Get("env") == "staging" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"environments": map[string]interface{}{"staging": map[string]interface{}{"on": on}}})
+		}
+	}))
+	defer server.Close()
+
+	provider := NewLaunchDarklyFlags("api-token", "shop", "staging")
+	provider.BaseURL = server.URL
+	ff := NewFeatureFlags(provider)
+	Steps{
+		ff.SetFlag("new-checkout", true),
+		ff.ExpectFlag("new-checkout", true),
+		ff.Restore(),
+		ff.ExpectFlag("new-checkout", false),
+		ff.Restore(),
+	}.Test(t)
+	if err := provider.Set("new-checkout", "variation-b"); err == nil || err.Error() != "LaunchDarkly: Flag new-checkout: Expected a bool; found string." {
+		t.Fatalf("Expected a non-bool value to be refused; found %v.", err)
+	}
+}
@@ -0,0 +1,200 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FieldPins pins, per endpoint, the JSON fields of responses on which
+// a suite relies, so that a server which stops returning one of them
+// is caught, whilst fields it adds are tolerated: a lighter-weight
+// alternative to a full consumer contract (see Pact). Endpoints are
+// identified as by Coverage, by method and path with numeric and UUID
+// path segments replaced by {id}, for example "GET /teas/{id}". Fields
+// are named as by Coverage, but without the "body:" prefix, for
+// example "items[].sku": a field within an array must be present in
+// every element of the array. Pins are declared with Pin, learned
+// from the fields a suite asserts on with PinAsserted, and persisted
+// with Save. FieldPins is safe for concurrent use.
+type FieldPins struct {
+	lock      sync.Mutex
+	endpoints map[string]map[string]bool
+}
+
+// NewFieldPins creates a new, empty, FieldPins.
+func NewFieldPins() *FieldPins {
+	return &FieldPins{endpoints: make(map[string]map[string]bool)}
+}
+
+// LoadFieldPins loads the FieldPins saved to path by Save: a JSON
+// object of endpoints to arrays of fields. If path does not exist, an
+// empty FieldPins is returned.
+func LoadFieldPins(path string) (*FieldPins, error) {
+	fp := NewFieldPins()
+	bites, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fp, nil
+	} else if err != nil {
+		return nil, err
+	}
+	pins := make(map[string][]string)
+	if err := json.Unmarshal(bites, &pins); err != nil {
+		return nil, fmt.Errorf("Field pins %s: %v", path, err)
+	}
+	for endpoint, fields := range pins {
+		fp.Pin(endpoint, fields...)
+	}
+	return fp, nil
+}
+
+// Save writes the pins to path, with the fields of each endpoint
+// sorted.
+func (fp *FieldPins) Save(path string) error {
+	pins := make(map[string][]string)
+	for _, endpoint := range fp.Endpoints() {
+		pins[endpoint] = fp.Fields(endpoint)
+	}
+	if bites, err := json.MarshalIndent(pins, "", "  "); err != nil {
+		return err
+	} else if err := ioutil.WriteFile(path, append(bites, '\n'), 0644); err != nil {
+		return fmt.Errorf("Field pins %s: %v", path, err)
+	} else {
+		return nil
+	}
+}
+
+// Pin pins fields of the responses of endpoint, in addition to any
+// already pinned.
+func (fp *FieldPins) Pin(endpoint string, fields ...string) {
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+	pinned, found := fp.endpoints[endpoint]
+	if !found {
+		pinned = make(map[string]bool)
+		fp.endpoints[endpoint] = pinned
+	}
+	for _, field := range fields {
+		pinned[field] = true
+	}
+}
+
+// PinAsserted pins every JSON field which was asserted on, according
+// to cov, and observed in a response of the same endpoint: that is,
+// the fields on which the suite relies.
+func (fp *FieldPins) PinAsserted(cov *Coverage) {
+	cov.lock.Lock()
+	pins := make(map[string][]string)
+	for endpoint, coverage := range cov.endpoints {
+		for part := range coverage.asserted {
+			if !strings.HasPrefix(part, "body:") || part == "body:" {
+				continue
+			}
+			for observed := range coverage.observed {
+				if covered(observed, map[string]bool{part: true}) {
+					pins[endpoint] = append(pins[endpoint], strings.TrimPrefix(part, "body:"))
+					break
+				}
+			}
+		}
+	}
+	cov.lock.Unlock()
+	for endpoint, fields := range pins {
+		fp.Pin(endpoint, fields...)
+	}
+}
+
+// Endpoints returns the endpoints with pinned fields, sorted.
+func (fp *FieldPins) Endpoints() []string {
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+	endpoints := make([]string, 0, len(fp.endpoints))
+	for endpoint := range fp.endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+// Fields returns the fields pinned for endpoint, sorted.
+func (fp *FieldPins) Fields(endpoint string) []string {
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+	fields := make([]string, 0, len(fp.endpoints[endpoint]))
+	for field := range fp.endpoints[endpoint] {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// hasField returns whether the field, split into tokens, is present
+// in doc: "[]" tokens must be arrays, every element of which must have
+// the rest of the field.
+func hasField(doc interface{}, tokens []string) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		elem, found := v[tokens[0]]
+		return found && !strings.HasPrefix(tokens[0], "[") && hasField(elem, tokens[1:])
+	case []interface{}:
+		if tokens[0] != "[]" {
+			return false
+		}
+		for _, elem := range v {
+			if !hasField(elem, tokens[1:]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// ResponseHasPinnedFields is a Step that when executed ensures there is
+// a non-nil hc.ResponseBody, parses it as JSON and errors unless every
+// field pinned for the endpoint of hc.Request is present. Fields which
+// are not pinned are ignored. The error is a StepError with a
+// DifferenceMissing for each missing field.
+func (hc *HttpCall) ResponseHasPinnedFields(pins *FieldPins) Step {
+	return hc.step("ResponseHasPinnedFields", func() error {
+		if err := AnyError(hc.AssertRequest(), hc.ReceiveBody()); err != nil {
+			return err
+		}
+		endpoint := coverageEndpoint(hc.Request)
+		fields := pins.Fields(endpoint)
+		if len(fields) == 0 {
+			return nil
+		}
+		var doc interface{}
+		decoder := json.NewDecoder(bytes.NewReader(hc.ResponseBody))
+		decoder.UseNumber()
+		if err := decoder.Decode(&doc); err != nil {
+			return fmt.Errorf("Pinned fields of %s: %v", endpoint, err)
+		}
+		missing := []string{}
+		diffs := []Difference{}
+		for _, field := range fields {
+			hc.cover("body:" + field)
+			if !hasField(doc, splitPath(field)) {
+				missing = append(missing, field)
+				diffs = append(diffs, Difference{Path: field, Kind: DifferenceMissing})
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		return &StepError{
+			Message:     fmt.Sprintf("Pinned fields of %s: Missing %s.", endpoint, strings.Join(missing, ", ")),
+			Differences: diffs,
+		}
+	})
+}
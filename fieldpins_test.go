@@ -0,0 +1,73 @@
+package argot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFieldPins(t *testing.T) {
+	body := `{"name": "sencha", "price": 4.5, "items": [{"sku": "a", "qty": 1}, {"sku": "b"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	// Learn the pins from the fields the suite asserts on.
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	hc.Coverage = NewCoverage()
+	Steps{
+		hc.NewRequest("GET", server.URL+"/teas/42", nil),
+		hc.ResponseBodyJSONPathEquals("name", "sencha"),
+		hc.ResponseBodyJSONPathEquals("items[0].sku", "a"),
+		hc.ResponseBodyJSONPathEquals("missing", nil),
+	}.Go()
+	pins := NewFieldPins()
+	pins.PinAsserted(hc.Coverage)
+	if fields := pins.Fields("GET /teas/{id}"); !reflect.DeepEqual(fields, []string{"items[].sku", "name"}) {
+		t.Fatalf("Unexpected pins: %v", fields)
+	}
+
+	dir, err := ioutil.TempDir("", "argot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "pins.json")
+	if err := pins.Save(path); err != nil {
+		t.Fatal(err)
+	} else if pins, err = LoadFieldPins(path); err != nil {
+		t.Fatal(err)
+	}
+	pins.Pin("GET /teas/{id}", "price")
+
+	hc.Reset()
+	Steps{
+		hc.NewRequest("GET", server.URL+"/teas/7", nil),
+		hc.ResponseHasPinnedFields(pins),
+	}.Test(t)
+
+	// Additions are tolerated; removals are not.
+	body = `{"name": "sencha", "origin": "Japan", "items": [{"sku": "a"}, {"qty": 2}]}`
+	hc.Reset()
+	err = Steps{
+		hc.NewRequest("GET", server.URL+"/teas/7", nil),
+		hc.ResponseHasPinnedFields(pins),
+	}.Go()
+	if diffs := Differences(err); len(diffs) != 2 || diffs[0].Path != "items[].sku" || diffs[1].Path != "price" || diffs[0].Kind != DifferenceMissing {
+		t.Fatalf("Unexpected differences: %v (%v)", diffs, err)
+	}
+
+	// Endpoints without pins are not checked.
+	hc.Reset()
+	Steps{
+		hc.NewRequest("GET", server.URL+"/orders", nil),
+		hc.ResponseHasPinnedFields(pins),
+	}.Test(t)
+}
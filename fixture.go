@@ -0,0 +1,149 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFixture is a Step that when executed loads the fixture file at
+// path (see ParseFixture) and sets key in store to its value, so that
+// request bodies and expected values can be maintained as data files
+// rather than Go literals. The format is chosen by the file's
+// extension: .json, .yaml, .yml or .csv. For example:
+//
+//	argot.Steps{
+//		argot.LoadFixture(store, "order", "testdata/order.yaml"),
+//		hc.NewRequestFromStore("POST", url, store, "order"),
+//		...
+//	}
+func LoadFixture(store *Store, key, path string) Step {
+	return NewNamedStep(fmt.Sprintf("LoadFixture(%s: %s)", key, path), func() error {
+		return loadFixture(store, key, path, false)
+	})
+}
+
+// LoadFixtureTemplate is a Step that when executed loads the fixture
+// file at path as LoadFixture does, but first interpolates values from
+// store into its text (see Store.Interpolate), for example an id
+// captured from an earlier response. Interpolated values are not
+// escaped, so must not break the syntax of the fixture.
+func LoadFixtureTemplate(store *Store, key, path string) Step {
+	return NewNamedStep(fmt.Sprintf("LoadFixtureTemplate(%s: %s)", key, path), func() error {
+		return loadFixture(store, key, path, true)
+	})
+}
+
+func loadFixture(store *Store, key, path string, interpolate bool) error {
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Fixture: %v", err)
+	}
+	if interpolate {
+		if str, err := store.Interpolate(string(data)); err != nil {
+			return fmt.Errorf("Fixture %s: %v", path, err)
+		} else {
+			data = []byte(str)
+		}
+	}
+	if value, err := ParseFixture(data, format); err != nil {
+		return fmt.Errorf("Fixture %s: %v", path, err)
+	} else {
+		store.Set(key, value)
+		return nil
+	}
+}
+
+// ParseFixture parses a fixture in the given format: "json", "yaml"
+// (or "yml") or "csv". The value is as encoding/json decodes into an
+// interface{}, with numbers as json.Number, so that it compares equal
+// to values captured from responses (see CaptureJSON); YAML is
+// converted to the same. A CSV fixture's first record is its header,
+// and its value is an array of objects, one per subsequent record,
+// mapping each field of the header to the record's string value.
+func ParseFixture(data []byte, format string) (interface{}, error) {
+	var value interface{}
+	switch format {
+	case "json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		} else if _, err := decoder.Token(); err != io.EOF {
+			return nil, fmt.Errorf("Expected a single JSON value.")
+		}
+		return value, nil
+	case "yaml", "yml":
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, err
+		} else if bites, err := json.Marshal(value); err != nil {
+			return nil, err
+		} else {
+			return ParseFixture(bites, "json")
+		}
+	case "csv":
+		return parseCSVFixture(data)
+	default:
+		return nil, fmt.Errorf("Unknown fixture format '%s'.", format)
+	}
+}
+
+func parseCSVFixture(data []byte) (interface{}, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	} else if len(records) == 0 {
+		return nil, fmt.Errorf("Expected a header record.")
+	}
+	header := records[0]
+	rows := make([]interface{}, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for idx, field := range header {
+			row[field] = record[idx]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// NewRequestFromStore is a Step that when executed creates a new
+// request, as NewRequest does, whose body is the value of key in
+// store (typically loaded by LoadFixture) encoded as JSON, with a
+// Content-Type of application/json.
+func (hc *HttpCall) NewRequestFromStore(method, urlStr string, store *Store, key string) Step {
+	return hc.step(fmt.Sprintf("NewRequestFromStore(%s: %s, %s)", method, hc.redactor().String(urlStr), key), func() error {
+		if value, found := store.Get(key); !found {
+			return fmt.Errorf("Store: '%s' not found.", key)
+		} else if bites, err := json.Marshal(value); err != nil {
+			return err
+		} else if err := hc.NewRequest(method, urlStr, bytes.NewReader(bites)).Go(); err != nil {
+			return err
+		} else {
+			hc.Request.Header.Set("Content-Type", "application/json")
+			return nil
+		}
+	})
+}
+
+// ResponseBodyJSONEqualsStore is a Step that when executed errors
+// unless the response body, parsed as JSON, equals the value of key in
+// store (typically loaded by LoadFixture), as ResponseBodyJSONEquals
+// does.
+func (hc *HttpCall) ResponseBodyJSONEqualsStore(store *Store, key string) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyJSONEqualsStore(%s)", key), func() error {
+		if value, found := store.Get(key); !found {
+			return fmt.Errorf("Store: '%s' not found.", key)
+		} else {
+			return hc.ResponseBodyJSONEquals(value).Go()
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package argot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFixture(t *testing.T) {
+	fromJSON, err := ParseFixture([]byte(`{"name": "tea", "sizes": [1, 2.5]}`), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromYAML, err := ParseFixture([]byte("name: tea\nsizes:\n  - 1\n  - 2.5\n"), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ExpectPrettyEqual(fromJSON, fromYAML).Go(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExpectPrettyEqual(json.Number("2.5"), fromYAML.(map[string]interface{})["sizes"].([]interface{})[1]).Go(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ParseFixture([]byte("name,size\ntea,1\ncoffee,2\n"), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{
+		map[string]interface{}{"name": "tea", "size": "1"},
+		map[string]interface{}{"name": "coffee", "size": "2"},
+	}
+	if err := ExpectPrettyEqual(expected, rows).Go(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseFixture([]byte(`{} {}`), "json"); err == nil {
+		t.Fatal("Expected trailing JSON to error.")
+	} else if _, err := ParseFixture([]byte(`a`), "xml"); err == nil {
+		t.Fatal("Expected an unknown format to error.")
+	}
+}
+
+func TestLoadFixture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "argot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "order.yaml"), []byte("tea: ${tea}\nsugars: 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	store := NewStore()
+	store.Set("tea", "earl grey")
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	path := filepath.Join(dir, "order.yaml")
+	if _, err := (Steps{
+		LoadFixtureTemplate(store, "order", path),
+		hc.NewRequestFromStore("POST", server.URL, store, "order"),
+		hc.ResponseStatusEquals(http.StatusOK),
+		hc.ResponseBodyJSONEqualsStore(store, "order"),
+		hc.ResponseBodyJSONPathEquals("tea", "earl grey"),
+	}).Test(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadFixture(store, "order", path).Go(); err != nil {
+		t.Fatal(err)
+	} else if _, err := (Steps{
+		hc.NewRequest("POST", server.URL, nil),
+		hc.ResponseBodyJSONEqualsStore(store, "order"),
+	}).Test(nil); err == nil {
+		t.Fatal("Expected the uninterpolated fixture not to match.")
+	} else if err := LoadFixture(store, "order", filepath.Join(dir, "missing.json")).Go(); err == nil {
+		t.Fatal("Expected a missing fixture to error.")
+	}
+}
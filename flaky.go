@@ -0,0 +1,48 @@
+package argot
+
+import (
+	"fmt"
+)
+
+// FlakyStep is a Step which quarantines a step known to be flaky: it
+// retries the step up to Retries times, and passes if any attempt
+// passes. Rather than hiding the flakiness, it records each attempt,
+// and RunScenario copies these into the step's StepResult so that
+// reports can track flaky steps. To retry a request together with its
+// assertions, wrap a Group whose first step is NewRequest (which
+// resets the HttpCall).
+type FlakyStep struct {
+	Step    Step
+	Retries int
+	// The number of attempts made when the step last ran.
+	Attempts int
+	// The errors of the failed attempts when the step last ran.
+	Errors []error
+}
+
+// Flaky creates a new FlakyStep which runs step up to retries + 1
+// times.
+func Flaky(step Step, retries int) *FlakyStep {
+	return &FlakyStep{Step: step, Retries: retries}
+}
+
+func (fs *FlakyStep) String() string {
+	return fmt.Sprintf("Flaky(%v)", fs.Step)
+}
+
+// Go runs the step until it passes or has been retried Retries times,
+// returning the error of the last attempt if none passes.
+func (fs *FlakyStep) Go() error {
+	fs.Attempts, fs.Errors = 0, nil
+	for {
+		fs.Attempts++
+		err := fs.Step.Go()
+		if err == nil {
+			return nil
+		}
+		fs.Errors = append(fs.Errors, err)
+		if fs.Attempts > fs.Retries {
+			return fmt.Errorf("Flaky: Failed all %d attempts: %v", fs.Attempts, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFlaky(t *testing.T) {
+	calls := 0
+	failTwice := NewNamedStep("failTwice", func() error {
+		if calls++; calls <= 2 {
+			return fmt.Errorf("attempt %d failed", calls)
+		}
+		return nil
+	})
+	result := RunScenario("flaky", Steps{
+		Flaky(failTwice, 3),
+		NewNamedStep("steady", func() error { return nil }),
+	})
+	if !result.Passed() {
+		t.Fatalf("Expected the flaky step to pass; found %v.", result.Err)
+	}
+	flaky := result.FlakySteps()
+	if len(flaky) != 1 || flaky[0].Name != "Flaky(failTwice)" || flaky[0].Attempts != 3 || len(flaky[0].RetriedErrors) != 2 {
+		t.Fatalf("Expected the flakiness to be recorded; found %+v.", flaky)
+	} else if result.Steps[1].Attempts != 1 || result.Steps[1].Flaky() {
+		t.Fatalf("Expected the steady step not to be flaky; found %+v.", result.Steps[1])
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteJSONReport(buf, []*ScenarioResult{result}); err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Scenarios []struct {
+			Flaky int `json:"flakySteps"`
+			Steps []struct {
+				Attempts      int      `json:"attempts"`
+				RetriedErrors []string `json:"retriedErrors"`
+			} `json:"steps"`
+		} `json:"scenarios"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	} else if scenario := report.Scenarios[0]; scenario.Flaky != 1 || scenario.Steps[0].Attempts != 3 ||
+		strings.Join(scenario.Steps[0].RetriedErrors, ",") != "attempt 1 failed,attempt 2 failed" || scenario.Steps[1].Attempts != 0 {
+		t.Fatalf("Expected the JSON report to record the flakiness; found %s.", buf)
+	}
+	buf.Reset()
+	if err := WriteMarkdownReport(buf, "Suite", []*ScenarioResult{result}); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(buf.String(), "- flaky: `Flaky(failTwice)` passed after 3 attempts") {
+		t.Fatalf("Expected the Markdown report to list the flaky step; found %s.", buf)
+	}
+
+	calls = -10
+	result = RunScenario("broken", Steps{Flaky(failTwice, 1)})
+	if result.Passed() || !strings.Contains(result.Err.Error(), "Failed all 2 attempts") {
+		t.Fatalf("Expected every attempt to fail; found %v.", result.Err)
+	} else if step := result.Steps[0]; step.Attempts != 2 || len(step.RetriedErrors) != 1 || step.Flaky() {
+		t.Fatalf("Expected a failed flaky step to record its attempts; found %+v.", step)
+	}
+}
@@ -0,0 +1,155 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// FluentCall is a fluent facade over the HttpCall steps, for writing
+// short scenarios readably:
+//
+//	hc.Get(url).
+//		WithHeader("Accept", "application/json").
+//		ExpectStatus(200).
+//		ExpectJSONPath("$.id", 42)
+//
+// Each method appends the equivalent HttpCall step, and the FluentCall
+// is itself a Step which runs them all in order, so it can be used
+// anywhere a Step can, for example in Steps{...}.Test(t). Steps
+// returns the underlying steps.
+type FluentCall struct {
+	hc    *HttpCall
+	steps Steps
+}
+
+// Do starts a FluentCall which creates a new request (see
+// NewRequest).
+func (hc *HttpCall) Do(method, urlStr string, body io.Reader) *FluentCall {
+	return &FluentCall{hc: hc, steps: Steps{hc.NewRequest(method, urlStr, body)}}
+}
+
+// Get starts a FluentCall with a GET request.
+func (hc *HttpCall) Get(urlStr string) *FluentCall {
+	return hc.Do(http.MethodGet, urlStr, nil)
+}
+
+// Delete starts a FluentCall with a DELETE request.
+func (hc *HttpCall) Delete(urlStr string) *FluentCall {
+	return hc.Do(http.MethodDelete, urlStr, nil)
+}
+
+// Post starts a FluentCall with a POST request.
+func (hc *HttpCall) Post(urlStr string, body io.Reader) *FluentCall {
+	return hc.Do(http.MethodPost, urlStr, body)
+}
+
+// Put starts a FluentCall with a PUT request.
+func (hc *HttpCall) Put(urlStr string, body io.Reader) *FluentCall {
+	return hc.Do(http.MethodPut, urlStr, body)
+}
+
+// Patch starts a FluentCall with a PATCH request.
+func (hc *HttpCall) Patch(urlStr string, body io.Reader) *FluentCall {
+	return hc.Do(http.MethodPatch, urlStr, body)
+}
+
+// Go runs the steps in order, stopping at the first error.
+func (fc *FluentCall) Go() error {
+	return fc.steps.Go()
+}
+
+func (fc *FluentCall) String() string {
+	names := make([]string, len(fc.steps))
+	for idx, step := range fc.steps {
+		names[idx] = fmt.Sprint(step)
+	}
+	return strings.Join(names, ".")
+}
+
+// Steps returns the steps the FluentCall expands to.
+func (fc *FluentCall) Steps() Steps {
+	return append(Steps{}, fc.steps...)
+}
+
+// Then appends an arbitrary step.
+func (fc *FluentCall) Then(step Step) *FluentCall {
+	fc.steps = append(fc.steps, step)
+	return fc
+}
+
+// WithHeader sets a request header (see RequestHeader).
+func (fc *FluentCall) WithHeader(key, value string) *FluentCall {
+	return fc.Then(fc.hc.RequestHeader(key, value))
+}
+
+// WithJSON marshals value as the JSON request body, replacing any
+// body given when the FluentCall was started, and sets the
+// Content-Type.
+func (fc *FluentCall) WithJSON(value interface{}) *FluentCall {
+	hc := fc.hc
+	return fc.Then(hc.step("WithJSON", func() error {
+		if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
+			return err
+		} else if body, err := json.Marshal(value); err != nil {
+			return err
+		} else {
+			hc.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+			hc.Request.GetBody = func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(body)), nil }
+			hc.Request.ContentLength = int64(len(body))
+			hc.Request.Header.Set("Content-Type", "application/json")
+			return nil
+		}
+	}))
+}
+
+// ExpectStatus checks the status (see ResponseStatusEquals).
+func (fc *FluentCall) ExpectStatus(status int) *FluentCall {
+	return fc.Then(fc.hc.ResponseStatusEquals(status))
+}
+
+// ExpectHeader checks a response header (see ResponseHeaderEquals).
+func (fc *FluentCall) ExpectHeader(key, value string) *FluentCall {
+	return fc.Then(fc.hc.ResponseHeaderEquals(key, value))
+}
+
+// ExpectBody checks the body (see ResponseBodyEquals).
+func (fc *FluentCall) ExpectBody(value string) *FluentCall {
+	return fc.Then(fc.hc.ResponseBodyEquals(value))
+}
+
+// ExpectBodyContains checks the body (see ResponseBodyContains).
+func (fc *FluentCall) ExpectBodyContains(value string) *FluentCall {
+	return fc.Then(fc.hc.ResponseBodyContains(value))
+}
+
+// ExpectBodyMatches checks the body (see ResponseBodyMatches).
+func (fc *FluentCall) ExpectBodyMatches(pattern *regexp.Regexp) *FluentCall {
+	return fc.Then(fc.hc.ResponseBodyMatches(pattern))
+}
+
+// ExpectJSON checks the body (see ResponseBodyJSONMatchesStruct).
+func (fc *FluentCall) ExpectJSON(expected interface{}) *FluentCall {
+	return fc.Then(fc.hc.ResponseBodyJSONMatchesStruct(expected))
+}
+
+// ExpectJSONPath checks a value in the body (see
+// ResponseBodyJSONPathEquals).
+func (fc *FluentCall) ExpectJSONPath(path string, expected interface{}) *FluentCall {
+	return fc.Then(fc.hc.ResponseBodyJSONPathEquals(path, expected))
+}
+
+// ExpectJSONSchema checks the body (see ResponseBodyJSONSchema).
+func (fc *FluentCall) ExpectJSONSchema(schema string) *FluentCall {
+	return fc.Then(fc.hc.ResponseBodyJSONSchema(schema))
+}
+
+// Capture stores a value from the body in store (see CaptureJSON).
+func (fc *FluentCall) Capture(store *Store, key, path string) *FluentCall {
+	return fc.Then(fc.hc.CaptureJSON(store, key, path))
+}
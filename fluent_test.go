@@ -0,0 +1,43 @@
+package argot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFluentCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if r.Method == "POST" {
+			json.NewDecoder(r.Body).Decode(&body)
+			w.WriteHeader(http.StatusCreated)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": 42, "accept": r.Header.Get("Accept"), "echo": body})
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	store := NewStore()
+	get := hc.Get(server.URL).
+		WithHeader("Accept", "application/json").
+		ExpectStatus(200).
+		ExpectJSONPath("$.id", 42).
+		ExpectJSONPath("accept", "application/json").
+		Capture(store, "id", "id")
+	Steps{
+		get,
+		store.ExpectEquals("id", "42"),
+		hc.Post(server.URL, nil).
+			WithJSON(map[string]string{"name": "alice"}).
+			ExpectStatus(http.StatusCreated).
+			ExpectJSONPath("echo.name", "alice"),
+		ExpectError(hc.Get(server.URL).ExpectStatus(404)),
+	}.Test(t)
+	if steps := get.Steps(); len(steps) != 6 {
+		t.Fatalf("Expected 6 steps; found %d: %v", len(steps), get)
+	}
+}
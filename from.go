@@ -0,0 +1,262 @@
+package argot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// funcName returns the name of the function fn, without its package
+// path: for example "checkInventory", "(*Shop).Restock" or
+// "TestOrders.func1".
+func funcName(fn interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	name = name[strings.LastIndexByte(name, '/')+1:]
+	if idx := strings.IndexByte(name, '.'); idx >= 0 {
+		name = name[idx+1:]
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// From creates a Step from an ordinary function, named after the
+// function. fn must be one of:
+//
+//	func()
+//	func() error
+//	func(context.Context) error
+//	func(testing.TB)
+//
+// A func(context.Context) error is passed context.Background(). A
+// func(testing.TB) is passed a testing.TB which records failures
+// rather than failing the test, so that the usual assertion helpers
+// can be used; the step errors with the messages of Error, Fatal and
+// so on if the function fails. To run a func(*testing.T), use FromT.
+// From panics if fn has any other type.
+func From(fn interface{}) Step {
+	name := ""
+	if fn != nil && reflect.TypeOf(fn).Kind() == reflect.Func {
+		name = funcName(fn)
+	}
+	switch f := fn.(type) {
+	case func():
+		return NewNamedStep(name, func() error {
+			f()
+			return nil
+		})
+	case func() error:
+		return NewNamedStep(name, f)
+	case StepFunc:
+		return NewNamedStep(name, f)
+	case func(context.Context) error:
+		return NewNamedStep(name, func() error { return f(context.Background()) })
+	case func(testing.TB):
+		return NewNamedStep(name, func() error { return runTB(name, f) })
+	case func(*testing.T):
+		panic(fmt.Sprintf("argot: From cannot run %s, a func(*testing.T): use FromT", name))
+	default:
+		panic(fmt.Sprintf("argot: From requires func(), func() error, func(context.Context) error or func(testing.TB); found %T", fn))
+	}
+}
+
+// FromT creates a Step, named after fn, which runs fn as a subtest of
+// t (see testing.T.Run), and errors if the subtest fails.
+func FromT(t *testing.T, fn func(*testing.T)) Step {
+	name := funcName(fn)
+	return NewNamedStep(name, func() error {
+		if !t.Run(name, fn) {
+			return fmt.Errorf("Subtest %s failed.", name)
+		}
+		return nil
+	})
+}
+
+// runTB runs fn with a stepTB, returning an error if it fails.
+func runTB(name string, fn func(testing.TB)) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	tb := &stepTB{name: name, ctx: ctx, cancel: cancel}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				tb.Errorf("panic: %v", r)
+			}
+		}()
+		defer tb.cleanup()
+		fn(tb)
+	}()
+	<-done
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+	if tb.failed {
+		if len(tb.errors) == 0 {
+			return errors.New("Failed.")
+		}
+		return errors.New(strings.Join(tb.errors, "\n"))
+	}
+	return nil
+}
+
+// stepTB is the testing.TB passed to functions run by From. It
+// embeds a nil testing.TB only to satisfy the interface's unexported
+// method; every method is implemented.
+type stepTB struct {
+	testing.TB
+	name     string
+	ctx      context.Context
+	cancel   context.CancelFunc
+	lock     sync.Mutex
+	failed   bool
+	skipped  bool
+	errors   []string
+	cleanups []func()
+}
+
+func (tb *stepTB) cleanup() {
+	tb.cancel()
+	tb.lock.Lock()
+	cleanups := tb.cleanups
+	tb.cleanups = nil
+	tb.lock.Unlock()
+	for idx := len(cleanups) - 1; idx >= 0; idx-- {
+		cleanups[idx]()
+	}
+}
+
+// ArtifactDir returns a new temporary directory, as with TempDir:
+// artifacts are not kept.
+func (tb *stepTB) ArtifactDir() string {
+	return tb.TempDir()
+}
+
+// Attr, like Log, discards its arguments.
+func (tb *stepTB) Attr(key, value string) {}
+
+// Chdir changes the working directory of the process to dir, and
+// restores it when the function finishes.
+func (tb *stepTB) Chdir(dir string) {
+	previous, err := os.Getwd()
+	if err != nil {
+		tb.Fatal(err)
+	} else if err := os.Chdir(dir); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { os.Chdir(previous) })
+}
+
+func (tb *stepTB) Cleanup(fn func()) {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+	tb.cleanups = append(tb.cleanups, fn)
+}
+
+// Context returns a context which is cancelled when the function
+// finishes, before the functions registered with Cleanup are run.
+func (tb *stepTB) Context() context.Context {
+	return tb.ctx
+}
+
+func (tb *stepTB) Error(args ...interface{}) {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+	tb.errors = append(tb.errors, strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+	tb.failed = true
+}
+
+func (tb *stepTB) Errorf(format string, args ...interface{}) {
+	tb.Error(fmt.Sprintf(format, args...))
+}
+
+func (tb *stepTB) Fail() {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+	tb.failed = true
+}
+
+func (tb *stepTB) FailNow() {
+	tb.Fail()
+	runtime.Goexit()
+}
+
+func (tb *stepTB) Failed() bool {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+	return tb.failed
+}
+
+func (tb *stepTB) Fatal(args ...interface{}) {
+	tb.Error(args...)
+	runtime.Goexit()
+}
+
+func (tb *stepTB) Fatalf(format string, args ...interface{}) {
+	tb.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+func (tb *stepTB) Helper() {}
+
+func (tb *stepTB) Log(args ...interface{}) {}
+
+func (tb *stepTB) Logf(format string, args ...interface{}) {}
+
+// Output returns a writer which, like Log, discards what is written.
+func (tb *stepTB) Output() io.Writer {
+	return ioutil.Discard
+}
+
+func (tb *stepTB) Name() string {
+	return tb.name
+}
+
+func (tb *stepTB) Setenv(key, value string) {
+	previous, found := os.LookupEnv(key)
+	os.Setenv(key, value)
+	tb.Cleanup(func() {
+		if found {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// Skip, and the other skip methods, stop the function without
+// failing the step.
+func (tb *stepTB) Skip(args ...interface{}) {
+	tb.SkipNow()
+}
+
+func (tb *stepTB) Skipf(format string, args ...interface{}) {
+	tb.SkipNow()
+}
+
+func (tb *stepTB) SkipNow() {
+	tb.lock.Lock()
+	tb.skipped = true
+	tb.lock.Unlock()
+	runtime.Goexit()
+}
+
+func (tb *stepTB) Skipped() bool {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+	return tb.skipped
+}
+
+func (tb *stepTB) TempDir() string {
+	dir, err := ioutil.TempDir("", "argot-")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
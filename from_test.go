@@ -0,0 +1,103 @@
+package argot
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func checkInventory() error {
+	return nil
+}
+
+type shop struct{ stock int }
+
+func (s *shop) restock(ctx context.Context) error {
+	if ctx == nil {
+		return errors.New("no context")
+	}
+	s.stock++
+	return nil
+}
+
+func TestFrom(t *testing.T) {
+	s := &shop{}
+	ran := false
+	steps := Steps{
+		From(checkInventory),
+		From(s.restock),
+		From(func() { ran = true }),
+		From(func(tb testing.TB) {
+			tb.Helper()
+			tb.Setenv("ARGOT_FROM_TEST", "set")
+			if dir := tb.TempDir(); dir == "" {
+				tb.Fatal("no temp dir")
+			}
+		}),
+	}
+	for idx, name := range []string{"checkInventory", "(*shop).restock", "TestFrom.func1", "TestFrom.func2"} {
+		if found := steps[idx].(*NamedStep).name; found != name {
+			t.Fatalf("Expected step %d to be named %s; found %s.", idx, name, found)
+		}
+	}
+	steps.Test(t)
+	if !ran || s.stock != 1 {
+		t.Fatal("Expected the functions to run.")
+	} else if _, found := os.LookupEnv("ARGOT_FROM_TEST"); found {
+		t.Fatal("Expected Setenv to be undone by cleanup.")
+	}
+
+	after := false
+	err := From(func(tb testing.TB) {
+		tb.Errorf("first %d", 1)
+		tb.Fatal("second")
+		after = true
+	}).Go()
+	if err == nil || err.Error() != "first 1\nsecond" || after {
+		t.Fatalf("Expected Fatal to stop the function and fail the step; found %v.", err)
+	}
+	if err := From(func(tb testing.TB) { tb.Skip("not today") }).Go(); err != nil {
+		t.Fatalf("Expected a skip not to fail the step; found %v.", err)
+	}
+	if err := From(func(tb testing.TB) { panic("boom") }).Go(); err == nil || !strings.Contains(err.Error(), "panic: boom") {
+		t.Fatalf("Expected a panic to fail the step; found %v.", err)
+	}
+
+	Steps{FromT(t, func(t *testing.T) {})}.Test(t)
+	func() {
+		defer func() {
+			if r := recover(); r == nil || !strings.Contains(r.(string), "use FromT") {
+				t.Fatalf("Expected From to refuse a func(*testing.T); found %v.", r)
+			}
+		}()
+		From(func(t *testing.T) {})
+	}()
+}
+
+func TestFromContextAndChdir(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	var ctx context.Context
+	cancelledFirst := false
+	Steps{From(func(tb testing.TB) {
+		ctx = tb.Context()
+		if ctx.Err() != nil {
+			tb.Fatal("Expected the context not to be cancelled yet.")
+		}
+		tb.Cleanup(func() { cancelledFirst = ctx.Err() != nil })
+		tb.Chdir(dir)
+		if found, _ := os.Getwd(); found != dir {
+			tb.Fatalf("Expected to be in %s; found %s.", dir, found)
+		}
+	})}.Test(t)
+	if !cancelledFirst {
+		t.Fatal("Expected the context to be cancelled before the cleanups ran.")
+	} else if found, _ := os.Getwd(); found != wd {
+		t.Fatalf("Expected the working directory to be restored to %s; found %s.", wd, found)
+	}
+}
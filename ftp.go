@@ -0,0 +1,296 @@
+package argot
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FTPClient is a minimal FTP client, implementing RemoteFiles, so that
+// files dropped onto an FTP server as a side effect of API calls can be
+// verified (see RemoteFileCall). Transfers are binary, in passive
+// mode. If TLSConfig is set, the session is secured with explicit TLS
+// (AUTH TLS), as FTPS servers require; implicit TLS is not supported.
+// The connection is made lazily and kept open until Close. An
+// FTPClient can only be used by a single go-routine at a time.
+type FTPClient struct {
+	// The host:port of the FTP server.
+	Addr string
+	// The user to log in as. If empty, "anonymous" is used.
+	User     string
+	Password string
+	// If non-nil, the control and data connections use TLS.
+	TLSConfig *tls.Config
+	// Timeout bounds connecting and each command. If zero, 5 seconds
+	// is used.
+	Timeout time.Duration
+
+	conn    net.Conn
+	control *textproto.Conn
+}
+
+// NewFTPClient creates a new FTPClient for the server at addr.
+func NewFTPClient(addr, user, password string) *FTPClient {
+	return &FTPClient{Addr: addr, User: user, Password: password}
+}
+
+// Close is idempotent. It ends the session and closes the connection,
+// if any.
+func (fc *FTPClient) Close() error {
+	if fc.control != nil {
+		fc.conn.SetDeadline(time.Now().Add(fc.timeout()))
+		fc.control.Cmd("QUIT")
+		fc.control.Close()
+	}
+	fc.conn = nil
+	fc.control = nil
+	return nil
+}
+
+func (fc *FTPClient) timeout() time.Duration {
+	if fc.Timeout == 0 {
+		return 5 * time.Second
+	} else {
+		return fc.Timeout
+	}
+}
+
+func (fc *FTPClient) tlsConfig() *tls.Config {
+	config := fc.TLSConfig.Clone()
+	if config.ServerName == "" {
+		if host, _, err := net.SplitHostPort(fc.Addr); err == nil {
+			config.ServerName = host
+		}
+	}
+	return config
+}
+
+func (fc *FTPClient) ensureConn() error {
+	if fc.control != nil {
+		return nil
+	}
+	conn, err := net.DialTimeout("tcp", fc.Addr, fc.timeout())
+	if err != nil {
+		return err
+	}
+	fc.conn, fc.control = conn, textproto.NewConn(conn)
+	if _, err := fc.response(220); err != nil {
+		fc.abandon()
+		return err
+	}
+	if fc.TLSConfig != nil {
+		if _, err := fc.cmd(234, "AUTH TLS"); err != nil {
+			fc.abandon()
+			return err
+		}
+		tlsConn := tls.Client(conn, fc.tlsConfig())
+		fc.conn, fc.control = tlsConn, textproto.NewConn(tlsConn)
+		if _, err := fc.cmd(200, "PBSZ 0"); err != nil {
+			fc.abandon()
+			return err
+		} else if _, err := fc.cmd(200, "PROT P"); err != nil {
+			fc.abandon()
+			return err
+		}
+	}
+	user := fc.User
+	if user == "" {
+		user = "anonymous"
+	}
+	if code, _, err := fc.cmdCode("USER %s", user); err != nil {
+		fc.abandon()
+		return err
+	} else if code == 331 {
+		if _, err := fc.cmd(230, "PASS %s", fc.Password); err != nil {
+			fc.abandon()
+			return err
+		}
+	} else if code != 230 {
+		fc.abandon()
+		return fmt.Errorf("FTP: USER: Expected 230 or 331; found %d.", code)
+	}
+	if _, err := fc.cmd(200, "TYPE I"); err != nil {
+		fc.abandon()
+		return err
+	}
+	return nil
+}
+
+// abandon closes the connection without ending the session, as it is
+// in an unknown state.
+func (fc *FTPClient) abandon() {
+	if fc.conn != nil {
+		fc.conn.Close()
+	}
+	fc.conn = nil
+	fc.control = nil
+}
+
+func (fc *FTPClient) response(expectCode int) (string, error) {
+	fc.conn.SetDeadline(time.Now().Add(fc.timeout()))
+	code, msg, err := fc.control.ReadResponse(expectCode)
+	if _, isProtoErr := err.(*textproto.Error); err != nil && !isProtoErr {
+		fc.abandon()
+	} else if err != nil {
+		return msg, fmt.Errorf("FTP: Expected %d; found %d %s.", expectCode, code, msg)
+	}
+	return msg, err
+}
+
+// cmdCode sends a command and returns the code and message of the
+// reply, whatever the code.
+func (fc *FTPClient) cmdCode(format string, args ...interface{}) (int, string, error) {
+	fc.conn.SetDeadline(time.Now().Add(fc.timeout()))
+	if _, err := fc.control.Cmd(format, args...); err != nil {
+		fc.abandon()
+		return 0, "", err
+	}
+	code, msg, err := fc.control.ReadResponse(0)
+	if _, isProtoErr := err.(*textproto.Error); err != nil && !isProtoErr {
+		fc.abandon()
+		return 0, "", err
+	}
+	return code, msg, nil
+}
+
+// cmd sends a command and errors unless the reply has expectCode.
+func (fc *FTPClient) cmd(expectCode int, format string, args ...interface{}) (string, error) {
+	if code, msg, err := fc.cmdCode(format, args...); err != nil {
+		return "", err
+	} else if code != expectCode {
+		return msg, fmt.Errorf("FTP: %s: Expected %d; found %d %s.", strings.Fields(format)[0], expectCode, code, msg)
+	} else {
+		return msg, nil
+	}
+}
+
+var ftpPASVAddr = regexp.MustCompile(`(\d+),(\d+),(\d+),(\d+),(\d+),(\d+)`)
+
+// dataConn opens a passive mode data connection, to the host of the
+// control connection, with EPSV or, failing that, PASV.
+func (fc *FTPClient) dataConn() (net.Conn, error) {
+	host, _, err := net.SplitHostPort(fc.Addr)
+	if err != nil {
+		return nil, err
+	}
+	port := 0
+	if code, msg, err := fc.cmdCode("EPSV"); err != nil {
+		return nil, err
+	} else if code == 229 {
+		start, end := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+		if start < 0 || end < start+4 {
+			return nil, fmt.Errorf("FTP: EPSV: Malformed reply %q.", msg)
+		} else if port, err = strconv.Atoi(msg[start+4 : end]); err != nil {
+			return nil, fmt.Errorf("FTP: EPSV: Malformed reply %q.", msg)
+		}
+	} else if msg, err := fc.cmd(227, "PASV"); err != nil {
+		return nil, err
+	} else if match := ftpPASVAddr.FindStringSubmatch(msg); match == nil {
+		return nil, fmt.Errorf("FTP: PASV: Malformed reply %q.", msg)
+	} else {
+		high, _ := strconv.Atoi(match[5])
+		low, _ := strconv.Atoi(match[6])
+		port = high<<8 | low
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), fc.timeout())
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(fc.timeout()))
+	if fc.TLSConfig != nil {
+		conn = tls.Client(conn, fc.tlsConfig())
+	}
+	return conn, nil
+}
+
+// transfer opens a data connection, sends the command, and calls fn
+// with the data connection, before closing it and awaiting the end of
+// the transfer.
+func (fc *FTPClient) transfer(fn func(net.Conn) error, format string, args ...interface{}) error {
+	if err := fc.ensureConn(); err != nil {
+		return err
+	}
+	data, err := fc.dataConn()
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+	if code, msg, err := fc.cmdCode(format, args...); err != nil {
+		return err
+	} else if code == 550 {
+		return &os.PathError{Op: strings.Fields(format)[0], Path: fmt.Sprint(args...), Err: os.ErrNotExist}
+	} else if code != 125 && code != 150 {
+		return fmt.Errorf("FTP: %s: Expected 125 or 150; found %d %s.", strings.Fields(format)[0], code, msg)
+	}
+	if tlsConn, ok := data.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+	}
+	err = fn(data)
+	if closeErr := data.Close(); err == nil {
+		err = closeErr
+	}
+	if _, respErr := fc.response(226); err == nil {
+		err = respErr
+	}
+	return err
+}
+
+// Size returns the size of the file at path, erroring with
+// os.ErrNotExist if there is no such file.
+func (fc *FTPClient) Size(path string) (int64, error) {
+	if err := fc.ensureConn(); err != nil {
+		return 0, err
+	} else if code, msg, err := fc.cmdCode("SIZE %s", path); err != nil {
+		return 0, err
+	} else if code == 550 {
+		return 0, &os.PathError{Op: "SIZE", Path: path, Err: os.ErrNotExist}
+	} else if code != 213 {
+		return 0, fmt.Errorf("FTP: SIZE: Expected 213; found %d %s.", code, msg)
+	} else {
+		return strconv.ParseInt(strings.TrimSpace(msg), 10, 64)
+	}
+}
+
+// ReadFile returns the contents of the file at path.
+func (fc *FTPClient) ReadFile(path string) ([]byte, error) {
+	var data []byte
+	err := fc.transfer(func(conn net.Conn) error {
+		var err error
+		data, err = ioutil.ReadAll(conn)
+		return err
+	}, "RETR %s", path)
+	return data, err
+}
+
+// WriteFile creates or replaces the file at path with data.
+func (fc *FTPClient) WriteFile(path string, data []byte) error {
+	return fc.transfer(func(conn net.Conn) error {
+		_, err := conn.Write(data)
+		return err
+	}, "STOR %s", path)
+}
+
+// Remove removes the file at path, erroring with os.ErrNotExist if
+// there is no such file.
+func (fc *FTPClient) Remove(path string) error {
+	if err := fc.ensureConn(); err != nil {
+		return err
+	} else if code, msg, err := fc.cmdCode("DELE %s", path); err != nil {
+		return err
+	} else if code == 550 {
+		return &os.PathError{Op: "DELE", Path: path, Err: os.ErrNotExist}
+	} else if code != 250 {
+		return fmt.Errorf("FTP: DELE: Expected 250; found %d %s.", code, msg)
+	} else {
+		return nil
+	}
+}
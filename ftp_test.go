@@ -0,0 +1,146 @@
+package argot
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFTP implements just enough of FTP, passive mode only, to
+// exercise FTPClient.
+func fakeFTP(t *testing.T, files map[string][]byte) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lock sync.Mutex
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				fmt.Fprint(conn, "220-fake\r\n220 ready\r\n")
+				var data net.Listener
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					fields := strings.SplitN(strings.TrimRight(line, "\r\n"), " ", 2)
+					arg := ""
+					if len(fields) == 2 {
+						arg = fields[1]
+					}
+					lock.Lock()
+					switch fields[0] {
+					case "USER":
+						fmt.Fprint(conn, "331 password please\r\n")
+					case "PASS":
+						if arg == "secret" {
+							fmt.Fprint(conn, "230 logged in\r\n")
+						} else {
+							fmt.Fprint(conn, "530 wrong\r\n")
+						}
+					case "TYPE":
+						fmt.Fprint(conn, "200 binary\r\n")
+					case "EPSV":
+						fmt.Fprint(conn, "500 not understood\r\n")
+					case "PASV":
+						data, _ = net.Listen("tcp", "127.0.0.1:0")
+						port := data.Addr().(*net.TCPAddr).Port
+						fmt.Fprintf(conn, "227 Entering Passive Mode (127,0,0,1,%d,%d).\r\n", port>>8, port&0xff)
+					case "SIZE":
+						if file, found := files[arg]; found {
+							fmt.Fprintf(conn, "213 %d\r\n", len(file))
+						} else {
+							fmt.Fprint(conn, "550 not found\r\n")
+						}
+					case "RETR", "STOR":
+						file, found := files[arg]
+						if fields[0] == "RETR" && !found {
+							data.Close()
+							fmt.Fprint(conn, "550 not found\r\n")
+							break
+						}
+						fmt.Fprint(conn, "150 opening\r\n")
+						dataConn, err := data.Accept()
+						data.Close()
+						if err != nil {
+							lock.Unlock()
+							return
+						}
+						if fields[0] == "RETR" {
+							dataConn.Write(file)
+						} else {
+							files[arg], _ = ioutil.ReadAll(dataConn)
+						}
+						dataConn.Close()
+						fmt.Fprint(conn, "226 done\r\n")
+					case "DELE":
+						if _, found := files[arg]; found {
+							delete(files, arg)
+							fmt.Fprint(conn, "250 deleted\r\n")
+						} else {
+							fmt.Fprint(conn, "550 not found\r\n")
+						}
+					case "QUIT":
+						fmt.Fprint(conn, "221 bye\r\n")
+						lock.Unlock()
+						return
+					default:
+						fmt.Fprint(conn, "502 not implemented\r\n")
+					}
+					lock.Unlock()
+				}
+			}()
+		}
+	}()
+	return listener
+}
+
+func TestFTPClient(t *testing.T) {
+	files := map[string][]byte{"/out/report.csv": []byte("id,total\n1,42\n")}
+	listener := fakeFTP(t, files)
+	defer listener.Close()
+
+	digest := sha256.Sum256(files["/out/report.csv"])
+	rfc := NewRemoteFileCall(NewFTPClient(listener.Addr().String(), "user", "secret"))
+	defer rfc.Reset()
+	if err := (Steps{
+		rfc.ExpectFile("/out/report.csv", time.Second),
+		rfc.ExpectFileSize("/out/report.csv", 14),
+		rfc.ExpectFileSHA256("/out/report.csv", strings.ToUpper(hex.EncodeToString(digest[:]))),
+		rfc.ExpectFileContains("/out/report.csv", "1,42"),
+		rfc.Upload("/in/fixture.txt", []byte("fixture")),
+		rfc.ExpectFileSize("/in/fixture.txt", 7),
+		rfc.Cleanup(),
+		rfc.ExpectNoFile("/in/fixture.txt"),
+	}).Go(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rfc.ExpectFile("/out/missing.csv", 50*time.Millisecond).Go(); err == nil || !strings.Contains(err.Error(), "Files: Expected /out/missing.csv to exist within 50ms; not found.") {
+		t.Fatalf("Expected a missing file to fail; found %v.", err)
+	} else if err := rfc.ExpectFileSHA256("/out/missing.csv", "00").Go(); err == nil || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected a missing file not to be read; found %v.", err)
+	}
+
+	wrong := NewFTPClient(listener.Addr().String(), "user", "wrong")
+	defer wrong.Close()
+	if _, err := wrong.Size("/out/report.csv"); err == nil || !strings.Contains(err.Error(), "FTP: PASS: Expected 230; found 530 wrong.") {
+		t.Fatalf("Expected the login to fail; found %v.", err)
+	}
+}
@@ -0,0 +1,240 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// FuzzDictionary is a named list of hostile values for use with Fuzz.
+type FuzzDictionary struct {
+	Name   string
+	Values []string
+}
+
+var (
+	// SQLInjectionDictionary holds common SQL injection payloads.
+	SQLInjectionDictionary = FuzzDictionary{Name: "sqli", Values: []string{
+		"'",
+		"''",
+		"' OR '1'='1",
+		"' OR 1=1--",
+		"\" OR \"\"=\"",
+		"1; DROP TABLE users--",
+		"' UNION SELECT NULL,NULL--",
+		"1' AND SLEEP(5)--",
+		"admin'--",
+	}}
+	// XSSDictionary holds common cross-site scripting payloads.
+	XSSDictionary = FuzzDictionary{Name: "xss", Values: []string{
+		"<script>alert(1)</script>",
+		"\"><script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"javascript:alert(1)",
+		"<svg/onload=alert(1)>",
+		"'-alert(1)-'",
+	}}
+	// PathTraversalDictionary holds common path traversal payloads.
+	PathTraversalDictionary = FuzzDictionary{Name: "traversal", Values: []string{
+		"../../../../etc/passwd",
+		"..\\..\\..\\..\\windows\\win.ini",
+		"%2e%2e%2f%2e%2e%2fetc%2fpasswd",
+		"....//....//etc/passwd",
+		"/etc/passwd\x00.png",
+	}}
+	// OversizedDictionary holds strings of increasing length.
+	OversizedDictionary = FuzzDictionary{Name: "oversized", Values: []string{
+		strings.Repeat("A", 8*1024),
+		strings.Repeat("A", 64*1024),
+		strings.Repeat("A", 1024*1024),
+	}}
+	// InvalidUnicodeDictionary holds malformed UTF-8 and awkward code
+	// points.
+	InvalidUnicodeDictionary = FuzzDictionary{Name: "unicode", Values: []string{
+		"\xff\xfe",
+		"\xc3\x28",
+		"\xed\xa0\x80",
+		"\xf0\x28\x8c\x28",
+		"\x00",
+		"\u202eAB",
+		"\ufeff",
+	}}
+
+	// DefaultFuzzDictionaries is used when no dictionaries are given.
+	DefaultFuzzDictionaries = []FuzzDictionary{
+		SQLInjectionDictionary,
+		XSSDictionary,
+		PathTraversalDictionary,
+		OversizedDictionary,
+		InvalidUnicodeDictionary,
+	}
+)
+
+// DefaultLeakPatterns match response bodies which reveal stack traces
+// or database errors. See ResponseRejected.
+var DefaultLeakPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`goroutine \d+ \[`),
+	regexp.MustCompile(`\.go:\d+ \+0x`),
+	regexp.MustCompile(`(?m)^panic: `),
+	regexp.MustCompile(`Traceback \(most recent call last\)`),
+	regexp.MustCompile(`(?m)^\s+at [\w.$<>]+\(.*\)`),
+	regexp.MustCompile(`Exception in thread`),
+	regexp.MustCompile(`System\.\w+Exception`),
+	regexp.MustCompile(`SQLSTATE\[`),
+	regexp.MustCompile(`(?i)syntax error at or near`),
+	regexp.MustCompile(`You have an error in your SQL syntax`),
+	regexp.MustCompile(`ORA-\d{5}`),
+}
+
+func fuzzDictionaries(dictionaries []FuzzDictionary) []FuzzDictionary {
+	if len(dictionaries) == 0 {
+		return DefaultFuzzDictionaries
+	} else {
+		return dictionaries
+	}
+}
+
+// fuzzValueName abbreviates value for use in step names.
+func fuzzValueName(value string) string {
+	if len(value) > 32 {
+		return fmt.Sprintf("%q... (%d bytes)", value[:32], len(value))
+	} else {
+		return fmt.Sprintf("%q", value)
+	}
+}
+
+// Fuzz returns a Step for every value in each of dictionaries (or
+// DefaultFuzzDictionaries if none are given). Each step runs the steps
+// returned by calling build with the value, and names the dictionary
+// and value in any error. build should substitute the value into a
+// request and assert on the response, typically with
+// ResponseRejected. FuzzQueryParam and FuzzJSONField are convenient
+// builders for common cases.
+func Fuzz(build func(value string) Steps, dictionaries ...FuzzDictionary) Steps {
+	steps := Steps{}
+	for _, dictionary := range fuzzDictionaries(dictionaries) {
+		for idx, value := range dictionary.Values {
+			name := fmt.Sprintf("Fuzz(%s #%d: %s)", dictionary.Name, idx+1, fuzzValueName(value))
+			value := value
+			steps = append(steps, NewNamedStep(name, func() error {
+				if err := build(value).Go(); err != nil {
+					return fmt.Errorf("%s: %v", name, err)
+				} else {
+					return nil
+				}
+			}))
+		}
+	}
+	return steps
+}
+
+func (hc *HttpCall) bodyLeaks() error {
+	body := string(hc.ResponseBody)
+	for _, pattern := range DefaultLeakPatterns {
+		if match := pattern.FindString(body); match != "" {
+			return fmt.Errorf("Body: Expected no stack traces or internal errors; found %q.", match)
+		}
+	}
+	return nil
+}
+
+// ResponseRejected is a Step that when executed ensures there is a
+// non-nil hc.ResponseBody and errors unless the status is 4xx and the
+// body matches none of DefaultLeakPatterns: that is, the request was
+// rejected cleanly, without a server error or a stack trace.
+func (hc *HttpCall) ResponseRejected() Step {
+	return hc.step("ResponseRejected", func() error {
+		if err := hc.ReceiveBody(); err != nil {
+			return err
+		} else if status := hc.Response.StatusCode; status < 400 || status > 499 {
+			return fmt.Errorf("Status: Expected 4xx; found %d.", status)
+		} else {
+			return hc.bodyLeaks()
+		}
+	})
+}
+
+// ResponseNoServerError is a Step that when executed ensures there is
+// a non-nil hc.ResponseBody and errors if the status is 5xx or the body
+// matches any of DefaultLeakPatterns. It is a more lenient alternative
+// to ResponseRejected for endpoints which may legitimately accept
+// hostile values, such as free text search.
+func (hc *HttpCall) ResponseNoServerError() Step {
+	return hc.step("ResponseNoServerError", func() error {
+		if err := hc.ReceiveBody(); err != nil {
+			return err
+		} else if status := hc.Response.StatusCode; status >= 500 {
+			return fmt.Errorf("Status: Expected below 500; found %d.", status)
+		} else {
+			return hc.bodyLeaks()
+		}
+	})
+}
+
+// FuzzQueryParam returns steps (see Fuzz) which each make a request to
+// urlStr with the query parameter param set to a hostile value, and
+// check the response with ResponseRejected.
+func (hc *HttpCall) FuzzQueryParam(method, urlStr, param string, dictionaries ...FuzzDictionary) Steps {
+	return Fuzz(func(value string) Steps {
+		u, err := url.Parse(urlStr)
+		if err != nil {
+			return Steps{StepFunc(func() error { return err })}
+		}
+		query := u.Query()
+		query.Set(param, value)
+		u.RawQuery = query.Encode()
+		return Steps{hc.NewRequest(method, u.String(), nil), hc.ResponseRejected()}
+	}, dictionaries...)
+}
+
+// FuzzJSONField returns steps (see Fuzz) which each make a request to
+// urlStr with a JSON body of body with field set to a hostile value,
+// and check the response with ResponseRejected. Unlike
+// json.Marshal, invalid UTF-8 in the value is sent unaltered.
+func (hc *HttpCall) FuzzJSONField(method, urlStr string, body map[string]interface{}, field string, dictionaries ...FuzzDictionary) Steps {
+	return Fuzz(func(value string) Steps {
+		// Marshal with a placeholder, then substitute the raw value so
+		// that invalid UTF-8 is not replaced.
+		fields := make(map[string]interface{}, len(body)+1)
+		for key, value := range body {
+			fields[key] = value
+		}
+		placeholder := "argot-fuzz-placeholder"
+		fields[field] = placeholder
+		encoded, err := json.Marshal(fields)
+		if err != nil {
+			return Steps{StepFunc(func() error { return err })}
+		}
+		encoded = bytes.Replace(encoded, []byte(`"`+placeholder+`"`), jsonRawString(value), 1)
+		return Steps{
+			hc.NewRequest(method, urlStr, bytes.NewReader(encoded)),
+			hc.RequestHeader("Content-Type", "application/json"),
+			hc.ResponseRejected(),
+		}
+	}, dictionaries...)
+}
+
+// jsonRawString quotes s as a JSON string, escaping quotes, backslashes
+// and control characters but leaving invalid UTF-8 bytes as they are.
+func jsonRawString(s string) []byte {
+	buf := []byte{'"'}
+	for idx := 0; idx < len(s); {
+		r, size := utf8.DecodeRuneInString(s[idx:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			buf = append(buf, s[idx])
+		case r == '"' || r == '\\':
+			buf = append(buf, '\\', byte(r))
+		case r < 0x20:
+			buf = append(buf, fmt.Sprintf(`\u%04x`, r)...)
+		default:
+			buf = append(buf, s[idx:idx+size]...)
+		}
+		idx += size
+	}
+	return append(buf, '"')
+}
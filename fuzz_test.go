@@ -0,0 +1,48 @@
+package argot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFuzz(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Name string }
+		name := r.URL.Query().Get("name")
+		if r.Method == "POST" {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "bad json", http.StatusBadRequest)
+				return
+			}
+			name = body.Name
+		}
+		if name == "'" || strings.Contains(name, "' OR") {
+			// A naive service that leaks database errors.
+			http.Error(w, "You have an error in your SQL syntax near '"+name+"'", http.StatusInternalServerError)
+		} else if name != "alice" {
+			http.Error(w, "invalid name", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	safe := []FuzzDictionary{XSSDictionary, PathTraversalDictionary, OversizedDictionary, InvalidUnicodeDictionary}
+	steps := append(hc.FuzzQueryParam("GET", server.URL+"/users?limit=1", "name", safe...),
+		hc.FuzzJSONField("POST", server.URL, map[string]interface{}{"Age": 3}, "Name", safe...)...)
+	steps = append(steps,
+		ExpectError(hc.FuzzQueryParam("GET", server.URL, "name", SQLInjectionDictionary)[0]),
+		ExpectError(hc.FuzzJSONField("POST", server.URL, nil, "Name", SQLInjectionDictionary)[2]),
+		hc.NewRequest("GET", server.URL+"?name=alice", nil),
+		ExpectError(hc.ResponseRejected()),
+		hc.ResponseNoServerError(),
+	)
+	steps.Test(t)
+
+	if got := string(jsonRawString("a\"\\\n\xff")); got != "\"a\\\"\\\\\\u000a\xff\"" {
+		t.Fatalf("Unexpected raw JSON string: %q", got)
+	}
+}
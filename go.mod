@@ -1,9 +1,12 @@
-module "github.com/msackman/argot"
+module github.com/msackman/argot
+
+go 1.24
 
 require (
-	"github.com/kylelemons/godebug" v0.0.0-20170820004349-d65d576e9348
-	"github.com/sergi/go-diff" v1.0.0
-	"github.com/xeipuuv/gojsonpointer" v0.0.0-20180127040702-4e3ac2762d5f
-	"github.com/xeipuuv/gojsonreference" v0.0.0-20180127040603-bd5ef7bd5415
-	"github.com/xeipuuv/gojsonschema" v0.0.0-20180207214316-8bcffc811467
+	github.com/kylelemons/godebug v0.0.0-20170820004349-d65d576e9348
+	github.com/sergi/go-diff v1.0.0
+	github.com/xeipuuv/gojsonpointer v0.0.0-20180127040702-4e3ac2762d5f
+	github.com/xeipuuv/gojsonreference v0.0.0-20180127040603-bd5ef7bd5415
+	github.com/xeipuuv/gojsonschema v0.0.0-20180207214316-8bcffc811467
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,134 @@
+package argot
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultGoroutineFilters lists substrings of goroutine stack traces
+// which are never considered to be leaks: they belong to the runtime,
+// the testing package, or are idle keep-alive HTTP connections.
+var DefaultGoroutineFilters = []string{
+	"runtime.ensureSigM",
+	"os/signal.signal_recv",
+	"os/signal.loop",
+	"testing.(*T).Run",
+	"testing.tRunner",
+	"testing.runTests",
+	"net/http.(*persistConn).readLoop",
+	"net/http.(*persistConn).writeLoop",
+	"net/http.(*conn).readRequest",
+	"net/http.(*Server).Serve",
+	"net/http/httptest.(*Server).goServe",
+}
+
+// GoroutineBaseline records the goroutines which exist at some point
+// in a scenario (typically its start), so that
+// ExpectNoGoroutineLeaks can later find goroutines that have been
+// started but not finished.
+type GoroutineBaseline struct {
+	// Grace is how long ExpectNoGoroutineLeaks will wait for new
+	// goroutines to finish before declaring them leaked. If zero, one
+	// second is used.
+	Grace time.Duration
+	// Filters lists additional substrings of stack traces which
+	// identify goroutines that should not be considered leaks.
+	Filters []string
+	ids     map[int]bool
+}
+
+// NewGoroutineBaseline creates a GoroutineBaseline and captures the
+// currently running goroutines.
+func NewGoroutineBaseline() *GoroutineBaseline {
+	gb := new(GoroutineBaseline)
+	gb.capture()
+	return gb
+}
+
+func (gb *GoroutineBaseline) capture() {
+	gb.ids = make(map[int]bool)
+	for id := range goroutineStacks() {
+		gb.ids[id] = true
+	}
+}
+
+// Capture is a Step that when executed (re)captures the currently
+// running goroutines as the baseline.
+func (gb *GoroutineBaseline) Capture() Step {
+	return NewNamedStep("CaptureGoroutineBaseline", func() error {
+		gb.capture()
+		return nil
+	})
+}
+
+func (gb *GoroutineBaseline) filtered(stack string) bool {
+	for _, filters := range [][]string{DefaultGoroutineFilters, gb.Filters} {
+		for _, filter := range filters {
+			if strings.Contains(stack, filter) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (gb *GoroutineBaseline) leaked() []string {
+	leaks := []string{}
+	for id, stack := range goroutineStacks() {
+		if !gb.ids[id] && !gb.filtered(stack) {
+			leaks = append(leaks, stack)
+		}
+	}
+	return leaks
+}
+
+// goroutineStacks returns the stack traces of all goroutines, keyed
+// by goroutine id.
+func goroutineStacks() map[int]string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	stacks := make(map[int]string)
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		fields := strings.Fields(stack)
+		if len(fields) < 2 || fields[0] != "goroutine" {
+			continue
+		} else if id, err := strconv.Atoi(fields[1]); err == nil {
+			stacks[id] = stack
+		}
+	}
+	return stacks
+}
+
+// ExpectNoGoroutineLeaks is a Step that when executed errors if there
+// are goroutines running which were not running when the baseline was
+// captured. Goroutines are given up to baseline.Grace to finish, and
+// goroutines matching DefaultGoroutineFilters or baseline.Filters are
+// ignored.
+func ExpectNoGoroutineLeaks(baseline *GoroutineBaseline) Step {
+	return NewNamedStep("ExpectNoGoroutineLeaks", func() error {
+		grace := baseline.Grace
+		if grace == 0 {
+			grace = time.Second
+		}
+		deadline := time.Now().Add(grace)
+		for {
+			leaks := baseline.leaked()
+			if len(leaks) == 0 {
+				return nil
+			} else if time.Now().After(deadline) {
+				return fmt.Errorf("Goroutines: Expected no leaks; found %d:\n%s", len(leaks), strings.Join(leaks, "\n\n"))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
@@ -0,0 +1,21 @@
+package argot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpectNoGoroutineLeaks(t *testing.T) {
+	baseline := NewGoroutineBaseline()
+	baseline.Grace = 50 * time.Millisecond
+	stop := make(chan struct{})
+	go func() { <-stop }()
+
+	if err := ExpectNoGoroutineLeaks(baseline).Go(); err == nil {
+		t.Fatal("Expected leaked goroutine to be detected.")
+	}
+	close(stop)
+	if err := ExpectNoGoroutineLeaks(baseline).Go(); err != nil {
+		t.Fatal(err)
+	}
+}
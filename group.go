@@ -0,0 +1,229 @@
+package argot
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Group is a Step which runs a named sequence of steps, stopping at
+// the first error. If a step fails, the error is a *GroupError which
+// lists the steps of the group that were achieved and the step that
+// failed, so that the failure output shows the group's sub-steps
+// nested beneath it.
+//
+// A best effort group instead runs every step, recording those that
+// fail in Failures, and never fails itself. This suits sections such
+// as cleanup or optional verification, whose failures should be
+// reported without aborting the rest of the scenario.
+//
+// A group may have a Budget: the most time its steps may take in
+// total, such as the SLA of an end-to-end flow (log in and fetch the
+// dashboard within 2s). If the steps succeed but exceed the budget,
+// the group fails with a *BudgetError, whatever the timeouts of the
+// individual requests.
+type Group struct {
+	Name  string
+	Steps Steps
+	// If true, the group is best effort.
+	BestEffort bool
+	// The steps that failed when a best effort group last ran.
+	Failures []StepResult
+	// If non-zero, the budget of the group. It is not enforced for a
+	// best effort group.
+	Budget time.Duration
+}
+
+// NewGroup creates a new Group.
+func NewGroup(name string, steps Steps) *Group {
+	return &Group{Name: name, Steps: steps}
+}
+
+// NewBudgetedGroup creates a new Group with the given Budget.
+func NewBudgetedGroup(name string, budget time.Duration, steps Steps) *Group {
+	return &Group{Name: name, Steps: steps, Budget: budget}
+}
+
+// NewBestEffortGroup creates a new best effort Group.
+func NewBestEffortGroup(name string, steps Steps) *Group {
+	return &Group{Name: name, Steps: steps, BestEffort: true}
+}
+
+func (g *Group) String() string {
+	return g.Name
+}
+
+// Go runs the steps of the group.
+func (g *Group) Go() error {
+	if g.BestEffort {
+		g.Failures = nil
+		for _, step := range g.Steps {
+			start := time.Now()
+			if err := step.Go(); err != nil {
+				g.Failures = append(g.Failures, StepResult{
+					Name:     DefaultRedactor.String(fmt.Sprint(step)),
+					Duration: time.Since(start),
+					Err:      err,
+				})
+			}
+		}
+		return nil
+	}
+	timings := make([]StepResult, 0, len(g.Steps))
+	var elapsed time.Duration
+	for idx, step := range g.Steps {
+		duration, err := runStep(step)
+		if err != nil {
+			return &GroupError{Group: g.Name, Results: g.Steps[:idx+1], Err: err}
+		}
+		elapsed += duration
+		timings = append(timings, StepResult{Name: DefaultRedactor.String(fmt.Sprint(step)), Duration: duration})
+	}
+	if g.Budget > 0 && elapsed > g.Budget {
+		return &BudgetError{Group: g.Name, Budget: g.Budget, Elapsed: elapsed, Timings: timings}
+	}
+	return nil
+}
+
+// BudgetError is the error returned by a Group whose steps succeeded
+// but took longer, in total, than its Budget. Timings holds the
+// duration of each step, to show where the time went.
+type BudgetError struct {
+	Group   string
+	Budget  time.Duration
+	Elapsed time.Duration
+	Timings []StepResult
+}
+
+func (e *BudgetError) Error() string {
+	lines := []string{fmt.Sprintf("%s: Expected to take at most %v; took %v.", e.Group, e.Budget, e.Elapsed.Round(time.Millisecond))}
+	for _, timing := range e.Timings {
+		lines = append(lines, fmt.Sprintf("%8v %s", timing.Duration.Round(time.Millisecond), timing.Name))
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// GroupError is the error returned by a Group whose step failed. As
+// with Steps.Test, Results holds the steps that succeeded followed by
+// the step that failed.
+type GroupError struct {
+	Group   string
+	Results Steps
+	Err     error
+}
+
+func (e *GroupError) Error() string {
+	lines := []string{e.Group + ":"}
+	for idx, step := range e.Results {
+		if idx == len(e.Results)-1 {
+			lines = append(lines, fmt.Sprintf("FAIL %v", step))
+		} else {
+			lines = append(lines, fmt.Sprintf("ok   %v", step))
+		}
+	}
+	lines = append(lines, "Error: "+strings.Replace(e.Err.Error(), "\n", "\n\t", -1))
+	return strings.Join(lines, "\n\t")
+}
+
+// Unwrap returns the error of the failed step.
+func (e *GroupError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	templatesLock sync.RWMutex
+	templates     = make(map[string]reflect.Value)
+	stepsType     = reflect.TypeOf(Steps{})
+)
+
+// RegisterTemplate registers a reusable parameterised scenario under
+// name, for use with Include. template must be a function returning
+// Steps, for example
+//
+//	func Checkout(user, item string) argot.Steps
+//
+// RegisterTemplate panics if template is not such a function or if
+// name is already registered, so it is typically called from an init
+// function.
+func RegisterTemplate(name string, template interface{}) {
+	value := reflect.ValueOf(template)
+	if value.Kind() != reflect.Func || value.Type().NumOut() != 1 || value.Type().Out(0) != stepsType {
+		panic(fmt.Sprintf("argot: template %s must be a function returning Steps; found %T", name, template))
+	}
+	templatesLock.Lock()
+	defer templatesLock.Unlock()
+	if _, found := templates[name]; found {
+		panic(fmt.Sprintf("argot: template %s is already registered", name))
+	}
+	templates[name] = value
+}
+
+// unregisterTemplate removes the template registered under name, so
+// that tests can register theirs afresh each run.
+func unregisterTemplate(name string) {
+	templatesLock.Lock()
+	defer templatesLock.Unlock()
+	delete(templates, name)
+}
+
+func numericKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
+
+// instantiate calls the template registered under name with args.
+func instantiate(name string, args []interface{}) (Steps, error) {
+	templatesLock.RLock()
+	template, found := templates[name]
+	templatesLock.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("Template %s is not registered.", name)
+	}
+	templateType := template.Type()
+	if templateType.IsVariadic() && len(args) < templateType.NumIn()-1 || !templateType.IsVariadic() && len(args) != templateType.NumIn() {
+		return nil, fmt.Errorf("Template %s: Expected %d arguments; found %d.", name, templateType.NumIn(), len(args))
+	}
+	values := make([]reflect.Value, len(args))
+	for idx, arg := range args {
+		var paramType reflect.Type
+		if templateType.IsVariadic() && idx >= templateType.NumIn()-1 {
+			paramType = templateType.In(templateType.NumIn() - 1).Elem()
+		} else {
+			paramType = templateType.In(idx)
+		}
+		if arg == nil {
+			values[idx] = reflect.Zero(paramType)
+		} else if value := reflect.ValueOf(arg); value.Type().AssignableTo(paramType) {
+			values[idx] = value
+		} else if value.Type().ConvertibleTo(paramType) && (value.Kind() == paramType.Kind() || numericKind(value.Kind()) && numericKind(paramType.Kind())) {
+			values[idx] = value.Convert(paramType)
+		} else {
+			return nil, fmt.Errorf("Template %s argument %d: Expected %v; found %T.", name, idx+1, paramType, arg)
+		}
+	}
+	return template.Call(values)[0].Interface().(Steps), nil
+}
+
+// Include is a Step that when executed instantiates the template
+// registered under name (see RegisterTemplate) with args, and runs
+// the resulting steps as a Group named after the template and its
+// arguments, for example "Checkout(alice, book)". Numeric arguments
+// are converted to the template's numeric parameter types, and
+// arguments may be given for named parameter types by their
+// underlying type. It errors if the template is not registered or the
+// arguments do not fit it.
+func Include(name string, args ...interface{}) Step {
+	formatted := make([]string, len(args))
+	for idx, arg := range args {
+		formatted[idx] = fmt.Sprint(arg)
+	}
+	groupName := fmt.Sprintf("%s(%s)", name, strings.Join(formatted, ", "))
+	return NewNamedStep(groupName, func() error {
+		if steps, err := instantiate(name, args); err != nil {
+			return err
+		} else {
+			return NewGroup(groupName, steps).Go()
+		}
+	})
+}
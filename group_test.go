@@ -0,0 +1,110 @@
+package argot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInclude(t *testing.T) {
+	basket := []string{}
+	t.Cleanup(func() {
+		unregisterTemplate("AddToBasket")
+		unregisterTemplate("Checkout")
+	})
+	RegisterTemplate("AddToBasket", func(item string, quantity int) Steps {
+		return Steps{
+			NewNamedStep("Add("+item+")", func() error {
+				basket = append(basket, strings.Repeat(item, quantity))
+				return nil
+			}),
+		}
+	})
+	RegisterTemplate("Checkout", func(user string, items ...string) Steps {
+		steps := Steps{}
+		for _, item := range items {
+			steps = append(steps, Include("AddToBasket", item, 1))
+		}
+		return append(steps, NewNamedStep("Pay("+user+")", func() error {
+			if user == "mallory" {
+				return errors.New("Payment declined.")
+			}
+			return nil
+		}))
+	})
+
+	Steps{
+		Include("Checkout", "alice", "book", "pen"),
+		Include("AddToBasket", "x", int64(3)),
+		ExpectError(Include("Missing")),
+		ExpectError(Include("AddToBasket", "x")),
+		ExpectError(Include("AddToBasket", 1, 2)),
+	}.Test(t)
+	if strings.Join(basket, ",") != "book,pen,xxx" {
+		t.Fatalf("Unexpected basket: %v", basket)
+	}
+
+	err := Include("Checkout", "mallory", "book").Go()
+	expected := "Checkout(mallory, book):\n\tok   AddToBasket(book, 1)\n\tFAIL Pay(mallory)\n\tError: Payment declined."
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Expected grouped failure:\n%s\nfound:\n%v", expected, err)
+	}
+}
+
+func TestBestEffortGroup(t *testing.T) {
+	ran := []string{}
+	step := func(name string, err error) Step {
+		return NewNamedStep(name, func() error {
+			ran = append(ran, name)
+			return err
+		})
+	}
+	cleanup := NewBestEffortGroup("Cleanup", Steps{
+		step("DeleteUser", errors.New("User not found.")),
+		NewGroup("DeleteOrders", Steps{
+			step("DeleteOrder(1)", nil),
+			step("DeleteOrder(2)", errors.New("Order locked.")),
+			step("DeleteOrder(3)", nil),
+		}),
+		step("DeleteBasket", nil),
+	})
+	Steps{
+		step("Checkout", nil),
+		cleanup,
+		step("Verify", nil),
+	}.Test(t)
+
+	if strings.Join(ran, ",") != "Checkout,DeleteUser,DeleteOrder(1),DeleteOrder(2),DeleteBasket,Verify" {
+		t.Fatalf("Unexpected steps run: %v", ran)
+	} else if len(cleanup.Failures) != 2 {
+		t.Fatalf("Expected 2 failures; found %d", len(cleanup.Failures))
+	} else if failure := cleanup.Failures[0]; failure.Name != "DeleteUser" || failure.Err.Error() != "User not found." {
+		t.Fatalf("Unexpected failure: %+v", failure)
+	} else if failure := cleanup.Failures[1]; failure.Name != "DeleteOrders" || !strings.Contains(failure.Err.Error(), "FAIL DeleteOrder(2)") {
+		t.Fatalf("Unexpected failure: %+v", failure)
+	}
+}
+
+func TestBudgetedGroup(t *testing.T) {
+	sleep := func(name string, d time.Duration) Step {
+		return NewNamedStep(name, func() error { time.Sleep(d); return nil })
+	}
+	if err := NewBudgetedGroup("fast", time.Second, Steps{sleep("login", time.Millisecond)}).Go(); err != nil {
+		t.Fatal(err)
+	}
+	err := NewBudgetedGroup("slow", 20*time.Millisecond, Steps{sleep("login", 5*time.Millisecond), sleep("dashboard", 30*time.Millisecond)}).Go()
+	var budgetErr *BudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.Elapsed < 35*time.Millisecond || len(budgetErr.Timings) != 2 {
+		t.Fatalf("Unexpected error: %v", err)
+	} else if msg := err.Error(); !strings.HasPrefix(msg, "slow: Expected to take at most 20ms; took ") || !strings.Contains(msg, " dashboard") {
+		t.Fatalf("Unexpected message:\n%s", msg)
+	}
+
+	// A failing step is reported as such, rather than as over budget.
+	fail := NewNamedStep("fail", func() error { return errors.New("boom") })
+	var groupErr *GroupError
+	if err := NewBudgetedGroup("failing", time.Nanosecond, Steps{sleep("login", time.Millisecond), fail}).Go(); !errors.As(err, &groupErr) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
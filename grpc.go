@@ -0,0 +1,474 @@
+package argot
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GRPCError is a call which failed with a non-zero gRPC status.
+type GRPCError struct {
+	Code    int
+	Message string
+}
+
+func (e *GRPCError) Error() string {
+	return fmt.Sprintf("gRPC: status %d: %s", e.Code, e.Message)
+}
+
+// grpcUnimplemented is the status of a call of a method the server
+// does not implement.
+const grpcUnimplemented = 12
+
+// GRPCHealthStatus is the serving status reported by the standard
+// gRPC health service (grpc.health.v1.Health).
+type GRPCHealthStatus int
+
+const (
+	GRPCHealthUnknown GRPCHealthStatus = iota
+	GRPCHealthServing
+	GRPCHealthNotServing
+	GRPCHealthServiceUnknown
+)
+
+func (s GRPCHealthStatus) String() string {
+	switch s {
+	case GRPCHealthServing:
+		return "SERVING"
+	case GRPCHealthNotServing:
+		return "NOT_SERVING"
+	case GRPCHealthServiceUnknown:
+		return "SERVICE_UNKNOWN"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// GRPCCall is a minimal gRPC client, speaking the gRPC protocol over
+// HTTP/2 and encoding the few protobuf messages it needs by hand, so
+// that deployment smoke tests can check a gRPC server through its
+// standard health and reflection services without generated code.
+// Compression is not supported.
+type GRPCCall struct {
+	// The host:port of the server.
+	Target string
+	// If nil, calls are made over unencrypted HTTP/2 (h2c); otherwise
+	// with TLS.
+	TLSConfig *tls.Config
+	// Timeout bounds each call. If zero, 5 seconds is used.
+	Timeout time.Duration
+
+	client *http.Client
+}
+
+// NewGRPCCall creates a new GRPCCall for the server at target. If
+// tlsConfig is nil, unencrypted HTTP/2 is used.
+func NewGRPCCall(target string, tlsConfig *tls.Config) *GRPCCall {
+	return &GRPCCall{Target: target, TLSConfig: tlsConfig}
+}
+
+// Reset is idempotent. You should ensure this is called at the end of
+// life for each GRPCCall. It closes any connections.
+func (gc *GRPCCall) Reset() error {
+	if gc.client != nil {
+		gc.client.CloseIdleConnections()
+	}
+	gc.client = nil
+	return nil
+}
+
+func (gc *GRPCCall) timeout() time.Duration {
+	if gc.Timeout == 0 {
+		return 5 * time.Second
+	} else {
+		return gc.Timeout
+	}
+}
+
+func (gc *GRPCCall) httpClient() *http.Client {
+	if gc.client == nil {
+		protocols := new(http.Protocols)
+		if gc.TLSConfig == nil {
+			protocols.SetUnencryptedHTTP2(true)
+		} else {
+			protocols.SetHTTP2(true)
+		}
+		gc.client = &http.Client{Transport: &http.Transport{TLSClientConfig: gc.TLSConfig, Protocols: protocols}}
+	}
+	return gc.client
+}
+
+// Invoke calls method (for example "/grpc.health.v1.Health/Check")
+// with request, an encoded protobuf message, and returns the encoded
+// messages of the response. For a streaming method, request is the
+// only message sent. A non-zero status is returned as a GRPCError.
+func (gc *GRPCCall) Invoke(method string, request []byte) ([][]byte, error) {
+	scheme := "http"
+	if gc.TLSConfig != nil {
+		scheme = "https"
+	}
+	frame := make([]byte, 5, 5+len(request))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(request)))
+	frame = append(frame, request...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), gc.timeout())
+	defer cancel()
+	u := &url.URL{Scheme: scheme, Host: gc.Target, Path: method}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(frame))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	response, err := gc.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gRPC: %s: Expected HTTP status 200; found %d.", method, response.StatusCode)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// A call which fails at once may have its status in the headers.
+	status := response.Trailer.Get("Grpc-Status")
+	message := response.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, message = response.Header.Get("Grpc-Status"), response.Header.Get("Grpc-Message")
+	}
+	if code, err := strconv.Atoi(status); err != nil {
+		return nil, fmt.Errorf("gRPC: %s: Expected a grpc-status; found %q.", method, status)
+	} else if code != 0 {
+		if unescaped, err := url.PathUnescape(message); err == nil {
+			message = unescaped
+		}
+		return nil, &GRPCError{Code: code, Message: message}
+	}
+
+	messages := [][]byte{}
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return nil, fmt.Errorf("gRPC: %s: Truncated message.", method)
+		} else if body[0] != 0 {
+			return nil, fmt.Errorf("gRPC: %s: Compressed messages are not supported.", method)
+		}
+		size := binary.BigEndian.Uint32(body[1:5])
+		if uint32(len(body)-5) < size {
+			return nil, fmt.Errorf("gRPC: %s: Truncated message.", method)
+		}
+		messages = append(messages, body[5:5+size])
+		body = body[5+size:]
+	}
+	return messages, nil
+}
+
+// Health returns the serving status of service, as reported by the
+// standard health service. The empty service is the server as a whole.
+func (gc *GRPCCall) Health(service string) (GRPCHealthStatus, error) {
+	request := []byte{}
+	if service != "" {
+		request = protoAppendBytes(request, 1, []byte(service))
+	}
+	if messages, err := gc.Invoke("/grpc.health.v1.Health/Check", request); err != nil {
+		return GRPCHealthUnknown, err
+	} else if len(messages) != 1 {
+		return GRPCHealthUnknown, fmt.Errorf("gRPC: Health: Expected 1 message; found %d.", len(messages))
+	} else if fields, err := protoDecode(messages[0]); err != nil {
+		return GRPCHealthUnknown, err
+	} else {
+		status := GRPCHealthUnknown
+		for _, field := range fields {
+			if field.num == 1 {
+				status = GRPCHealthStatus(field.varint)
+			}
+		}
+		return status, nil
+	}
+}
+
+// ExpectHealth is a Step that when executed errors unless the standard
+// health service reports service (or, if empty, the server as a whole)
+// as having status.
+func (gc *GRPCCall) ExpectHealth(service string, status GRPCHealthStatus) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectGRPCHealth(%s, %v)", service, status), func() error {
+		if found, err := gc.Health(service); err != nil {
+			return err
+		} else if found != status {
+			return fmt.Errorf("gRPC: Health of '%s': Expected %v; found %v.", service, status, found)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectServing is a Step that when executed errors unless the
+// standard health service reports service (or, if empty, the server as
+// a whole) as serving.
+func (gc *GRPCCall) ExpectServing(service string) Step {
+	return gc.ExpectHealth(service, GRPCHealthServing)
+}
+
+// reflect sends request to the reflection service, version v1 or,
+// should the server not implement it, v1alpha, and returns the fields
+// of the response.
+func (gc *GRPCCall) reflect(request []byte) ([]protoField, error) {
+	messages, err := gc.Invoke("/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", request)
+	if grpcErr, ok := err.(*GRPCError); ok && grpcErr.Code == grpcUnimplemented {
+		messages, err = gc.Invoke("/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo", request)
+	}
+	if err != nil {
+		return nil, err
+	} else if len(messages) != 1 {
+		return nil, fmt.Errorf("gRPC: Reflection: Expected 1 message; found %d.", len(messages))
+	}
+	fields, err := protoDecode(messages[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		if field.num == 7 { // error_response
+			errFields, err := protoDecode(field.bytes)
+			if err != nil {
+				return nil, err
+			}
+			grpcErr := &GRPCError{}
+			for _, errField := range errFields {
+				if errField.num == 1 {
+					grpcErr.Code = int(errField.varint)
+				} else if errField.num == 2 {
+					grpcErr.Message = string(errField.bytes)
+				}
+			}
+			return nil, grpcErr
+		}
+	}
+	return fields, nil
+}
+
+// Services returns the names, sorted, of the services the server
+// exposes, as listed by the reflection service.
+func (gc *GRPCCall) Services() ([]string, error) {
+	fields, err := gc.reflect(protoAppendBytes(nil, 7, []byte("*"))) // list_services
+	if err != nil {
+		return nil, err
+	}
+	services := []string{}
+	for _, field := range fields {
+		if field.num != 6 { // list_services_response
+			continue
+		}
+		listFields, err := protoDecode(field.bytes)
+		if err != nil {
+			return nil, err
+		}
+		for _, listField := range listFields {
+			if listField.num != 1 {
+				continue
+			}
+			serviceFields, err := protoDecode(listField.bytes)
+			if err != nil {
+				return nil, err
+			}
+			for _, serviceField := range serviceFields {
+				if serviceField.num == 1 {
+					services = append(services, string(serviceField.bytes))
+				}
+			}
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+// Methods returns the names, sorted, of the methods of service (its
+// full name, such as "grpc.health.v1.Health"), as described by the
+// reflection service.
+func (gc *GRPCCall) Methods(service string) ([]string, error) {
+	fields, err := gc.reflect(protoAppendBytes(nil, 4, []byte(service))) // file_containing_symbol
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		if field.num != 4 { // file_descriptor_response
+			continue
+		}
+		responseFields, err := protoDecode(field.bytes)
+		if err != nil {
+			return nil, err
+		}
+		for _, responseField := range responseFields {
+			if responseField.num != 1 {
+				continue
+			}
+			if methods, found, err := protoFileServiceMethods(responseField.bytes, service); err != nil {
+				return nil, err
+			} else if found {
+				sort.Strings(methods)
+				return methods, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("gRPC: Reflection: Service %s not described.", service)
+}
+
+// protoFileServiceMethods returns the names of the methods of service
+// if it is described by the FileDescriptorProto file.
+func protoFileServiceMethods(file []byte, service string) ([]string, bool, error) {
+	fields, err := protoDecode(file)
+	if err != nil {
+		return nil, false, err
+	}
+	pkg := ""
+	for _, field := range fields {
+		if field.num == 2 {
+			pkg = string(field.bytes)
+		}
+	}
+	for _, field := range fields {
+		if field.num != 6 { // service
+			continue
+		}
+		serviceFields, err := protoDecode(field.bytes)
+		if err != nil {
+			return nil, false, err
+		}
+		name, methods := "", []string{}
+		for _, serviceField := range serviceFields {
+			if serviceField.num == 1 {
+				name = string(serviceField.bytes)
+			} else if serviceField.num == 2 {
+				methodFields, err := protoDecode(serviceField.bytes)
+				if err != nil {
+					return nil, false, err
+				}
+				for _, methodField := range methodFields {
+					if methodField.num == 1 {
+						methods = append(methods, string(methodField.bytes))
+					}
+				}
+			}
+		}
+		if pkg != "" {
+			name = pkg + "." + name
+		}
+		if name == service {
+			return methods, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// ExpectServices is a Step that when executed errors unless the server
+// exposes every one of services, as listed by the reflection service.
+func (gc *GRPCCall) ExpectServices(services ...string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectGRPCServices(%s)", strings.Join(services, ", ")), func() error {
+		found, err := gc.Services()
+		if err != nil {
+			return err
+		}
+		return expectNames("Services", services, found)
+	})
+}
+
+// ExpectMethods is a Step that when executed errors unless service
+// has every one of methods, as described by the reflection service.
+func (gc *GRPCCall) ExpectMethods(service string, methods ...string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectGRPCMethods(%s: %s)", service, strings.Join(methods, ", ")), func() error {
+		found, err := gc.Methods(service)
+		if err != nil {
+			return err
+		}
+		return expectNames(service+" methods", methods, found)
+	})
+}
+
+// expectNames errors unless found contains every name in expected.
+func expectNames(what string, expected, found []string) error {
+	present := make(map[string]bool, len(found))
+	for _, name := range found {
+		present[name] = true
+	}
+	missing := []string{}
+	for _, name := range expected {
+		if !present[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("gRPC: %s: Expected %s; missing from %s.", what, strings.Join(missing, ", "), strings.Join(found, ", "))
+	}
+	return nil
+}
+
+// protoField is a field of an encoded protobuf message. Fields of
+// wire type 0 (varint) have varint set; those of wire type 2 (length
+// delimited) have bytes set; others are skipped.
+type protoField struct {
+	num    int
+	varint uint64
+	bytes  []byte
+}
+
+// protoDecode decodes the fields of an encoded protobuf message.
+func protoDecode(message []byte) ([]protoField, error) {
+	fields := []protoField{}
+	for len(message) > 0 {
+		key, n := binary.Uvarint(message)
+		if n <= 0 {
+			return nil, errors.New("protobuf: Malformed field key.")
+		}
+		message = message[n:]
+		field := protoField{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			if field.varint, n = binary.Uvarint(message); n <= 0 {
+				return nil, errors.New("protobuf: Malformed varint.")
+			}
+			message = message[n:]
+		case 1:
+			if len(message) < 8 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			message = message[8:]
+			continue
+		case 2:
+			size, n := binary.Uvarint(message)
+			if n <= 0 || uint64(len(message)-n) < size {
+				return nil, errors.New("protobuf: Malformed length.")
+			}
+			field.bytes = message[n : n+int(size)]
+			message = message[n+int(size):]
+		case 5:
+			if len(message) < 4 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			message = message[4:]
+			continue
+		default:
+			return nil, fmt.Errorf("protobuf: Unsupported wire type %d.", key&7)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// protoAppendBytes appends field num, with wire type 2, to message.
+func protoAppendBytes(message []byte, num int, value []byte) []byte {
+	message = binary.AppendUvarint(message, uint64(num)<<3|2)
+	message = binary.AppendUvarint(message, uint64(len(value)))
+	return append(message, value...)
+}
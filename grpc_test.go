@@ -0,0 +1,105 @@
+package argot
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeGRPC serves the health service, and v1alpha (but not v1) of the
+// reflection service, over unencrypted HTTP/2.
+func fakeGRPC(t *testing.T) *httptest.Server {
+	file := protoAppendBytes(nil, 1, []byte("shop.proto"))
+	file = protoAppendBytes(file, 2, []byte("shop.v1"))
+	service := protoAppendBytes(nil, 1, []byte("Orders"))
+	for _, method := range []string{"Place", "Cancel"} {
+		service = protoAppendBytes(service, 2, protoAppendBytes(nil, 1, []byte(method)))
+	}
+	file = protoAppendBytes(file, 6, service)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.ProtoMajor != 2 || r.Header.Get("Content-Type") != "application/grpc" || len(body) < 5 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fields, _ := protoDecode(body[5:])
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		reply := func(message []byte) {
+			frame := make([]byte, 5)
+			binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+			w.Write(append(frame, message...))
+			w.Header().Set("Grpc-Status", "0")
+		}
+		switch r.URL.Path {
+		case "/grpc.health.v1.Health/Check":
+			status := uint64(GRPCHealthServing)
+			if len(fields) == 1 && string(fields[0].bytes) == "shop.v1.Refunds" {
+				status = uint64(GRPCHealthNotServing)
+			} else if len(fields) == 1 && string(fields[0].bytes) != "shop.v1.Orders" {
+				w.Header().Set("Grpc-Status", "5")
+				w.Header().Set("Grpc-Message", "unknown%20service")
+				return
+			}
+			reply(binary.AppendUvarint(binary.AppendUvarint(nil, 1<<3), status))
+		case "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo":
+			switch fields[0].num {
+			case 7:
+				list := []byte{}
+				for _, name := range []string{"shop.v1.Orders", "grpc.health.v1.Health"} {
+					list = protoAppendBytes(list, 1, protoAppendBytes(nil, 1, []byte(name)))
+				}
+				reply(protoAppendBytes(nil, 6, list))
+			case 4:
+				if string(fields[0].bytes) == "shop.v1.Orders" {
+					reply(protoAppendBytes(nil, 4, protoAppendBytes(nil, 1, file)))
+				} else {
+					errResponse := binary.AppendUvarint(binary.AppendUvarint(nil, 1<<3), 5)
+					reply(protoAppendBytes(nil, 7, protoAppendBytes(errResponse, 2, []byte("symbol not found"))))
+				}
+			}
+		default:
+			w.Header().Set("Grpc-Status", "12")
+		}
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetHTTP1(true)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	return server
+}
+
+func TestGRPCCall(t *testing.T) {
+	server := fakeGRPC(t)
+	defer server.Close()
+
+	gc := NewGRPCCall(strings.TrimPrefix(server.URL, "http://"), nil)
+	defer gc.Reset()
+	if err := (Steps{
+		gc.ExpectServing(""),
+		gc.ExpectServing("shop.v1.Orders"),
+		gc.ExpectHealth("shop.v1.Refunds", GRPCHealthNotServing),
+		gc.ExpectServices("shop.v1.Orders", "grpc.health.v1.Health"),
+		gc.ExpectMethods("shop.v1.Orders", "Place", "Cancel"),
+	}).Go(); err != nil {
+		t.Fatal(err)
+	}
+
+	var grpcErr *GRPCError
+	if err := gc.ExpectServing("shop.v1.Refunds").Go(); err == nil || err.Error() != "gRPC: Health of 'shop.v1.Refunds': Expected SERVING; found NOT_SERVING." {
+		t.Fatalf("Expected the service not to be serving; found %v.", err)
+	} else if err := gc.ExpectServing("shop.v1.Missing").Go(); !errors.As(err, &grpcErr) || grpcErr.Code != 5 || grpcErr.Message != "unknown service" {
+		t.Fatalf("Expected status 5; found %v.", err)
+	} else if err := gc.ExpectServices("shop.v1.Payments").Go(); err == nil || err.Error() != "gRPC: Services: Expected shop.v1.Payments; missing from grpc.health.v1.Health, shop.v1.Orders." {
+		t.Fatalf("Expected a missing service; found %v.", err)
+	} else if err := gc.ExpectMethods("shop.v1.Orders", "Refund").Go(); err == nil || !strings.Contains(err.Error(), "Expected Refund; missing from Cancel, Place.") {
+		t.Fatalf("Expected a missing method; found %v.", err)
+	} else if err := gc.ExpectMethods("shop.v1.Payments", "Pay").Go(); !errors.As(err, &grpcErr) || grpcErr.Message != "symbol not found" {
+		t.Fatalf("Expected the reflection error; found %v.", err)
+	}
+}
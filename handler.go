@@ -0,0 +1,55 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+)
+
+// HandlerTransport is an http.RoundTripper which serves requests by
+// calling Handler directly, recording the response with an
+// httptest.ResponseRecorder. No sockets are used, so there are no
+// ports to allocate and no connections to manage. Note that
+// streaming responses are only seen once the handler has returned.
+type HandlerTransport struct {
+	Handler http.Handler
+}
+
+// NewHandlerCall creates a new HttpCall whose requests are served
+// in-process by handler (see HandlerTransport). Requests should still
+// use absolute URLs, for example http://example.com/path.
+func NewHandlerCall(handler http.Handler) *HttpCall {
+	return NewHttpCall(&http.Client{Transport: &HandlerTransport{Handler: handler}})
+}
+
+// RoundTrip implements http.RoundTripper. If the handler panics, the
+// panic is returned as an error.
+func (ht *HandlerTransport) RoundTrip(req *http.Request) (response *http.Response, err error) {
+	body := req.Body
+	if body == nil {
+		body = http.NoBody
+	}
+	serverReq := httptest.NewRequest(req.Method, req.URL.String(), body)
+	serverReq = serverReq.WithContext(req.Context())
+	serverReq.Header = cloneHeader(req.Header)
+	serverReq.ContentLength = req.ContentLength
+	if req.Host != "" {
+		serverReq.Host = req.Host
+	}
+	for _, cookie := range req.Cookies() {
+		if _, err := serverReq.Cookie(cookie.Name); err != nil {
+			serverReq.AddCookie(cookie)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	defer func() {
+		if r := recover(); r != nil {
+			response, err = nil, fmt.Errorf("Handler panicked: %v", r)
+		}
+	}()
+	ht.Handler.ServeHTTP(recorder, serverReq)
+	response = recorder.Result()
+	response.Request = req
+	return response, nil
+}
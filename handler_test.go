@@ -0,0 +1,37 @@
+package argot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHandlerCall(t *testing.T) {
+	hc := NewHandlerCall(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Echo", r.Header.Get("X-Test"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+	defer hc.Reset()
+
+	Steps{
+		hc.NewRequest("POST", "http://example.com/echo", strings.NewReader("hello")),
+		hc.RequestHeader("X-Test", "value"),
+		hc.ResponseStatusEquals(http.StatusCreated),
+		hc.ResponseHeaderEquals("X-Echo", "value"),
+		hc.ResponseBodyEquals("hello"),
+	}.Test(t)
+
+	_, err := Steps{
+		hc.NewRequest("GET", "http://example.com/panic", nil),
+		hc.Call(),
+	}.Test(nil)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("Expected handler panic to be reported; found %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package argot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// HAR is the subset of an HTTP Archive (HAR 1.2) document needed to
+// replay the requests it contains.
+type HAR struct {
+	Log struct {
+		Entries []HAREntry `json:"entries"`
+	} `json:"log"`
+}
+
+// HAREntry is a single request and response from a HAR document.
+type HAREntry struct {
+	Request  HARRequest  `json:"request"`
+	Response HARResponse `json:"response"`
+}
+
+// HARRequest is a request from a HAR document.
+type HARRequest struct {
+	Method   string         `json:"method"`
+	URL      string         `json:"url"`
+	Headers  []HARNameValue `json:"headers"`
+	PostData *HARPostData   `json:"postData,omitempty"`
+}
+
+// HARResponse is a response from a HAR document.
+type HARResponse struct {
+	Status int `json:"status"`
+}
+
+// HARNameValue is a header (or other name value pair) from a HAR
+// document.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is the body of a request from a HAR document.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// harSkippedHeaders are headers which are set by net/http itself, or
+// which would change how the response is presented to later steps
+// (for example Accept-Encoding disables transparent decompression).
+var harSkippedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Connection":        true,
+	"Accept-Encoding":   true,
+	"Transfer-Encoding": true,
+}
+
+// LoadHAR reads and parses the HAR document at path.
+func LoadHAR(path string) (*HAR, error) {
+	if bites, err := ioutil.ReadFile(path); err != nil {
+		return nil, err
+	} else {
+		har := new(HAR)
+		if err := json.Unmarshal(bites, har); err != nil {
+			return nil, fmt.Errorf("HAR %s: %v", path, err)
+		}
+		return har, nil
+	}
+}
+
+// headers returns the headers of the request to be replayed: HTTP/2
+// pseudo-headers and headers that net/http manages itself are
+// skipped, and, if the request has a body but no Content-Type, one is
+// added from the MIME type of the body.
+func (req *HARRequest) headers() []HARNameValue {
+	headers := []HARNameValue{}
+	contentTypeSet := false
+	for _, header := range req.Headers {
+		name := http.CanonicalHeaderKey(header.Name)
+		if strings.HasPrefix(name, ":") || harSkippedHeaders[name] {
+			continue
+		}
+		contentTypeSet = contentTypeSet || name == "Content-Type"
+		headers = append(headers, HARNameValue{Name: name, Value: header.Value})
+	}
+	if req.PostData != nil && req.PostData.Text != "" && !contentTypeSet && req.PostData.MimeType != "" {
+		headers = append(headers, HARNameValue{Name: "Content-Type", Value: req.PostData.MimeType})
+	}
+	return headers
+}
+
+// Steps converts every entry in the HAR document into Steps using hc:
+// a NewRequest step, followed by a RequestHeader step for each
+// header. HTTP/2 pseudo-headers and headers that net/http manages
+// itself are skipped. If assertStatus is true then each request is
+// followed by a ResponseStatusEquals step asserting the status that
+// was captured in the HAR document; otherwise a Call step is used so
+// that the request is still made.
+func (har *HAR) Steps(hc *HttpCall, assertStatus bool) Steps {
+	steps := Steps{}
+	for _, entry := range har.Log.Entries {
+		var body io.Reader
+		if entry.Request.PostData != nil && entry.Request.PostData.Text != "" {
+			body = strings.NewReader(entry.Request.PostData.Text)
+		}
+		steps = append(steps, hc.NewRequest(entry.Request.Method, entry.Request.URL, body))
+		for _, header := range entry.Request.headers() {
+			steps = append(steps, hc.RequestHeader(header.Name, header.Value))
+		}
+		if assertStatus && entry.Response.Status != 0 {
+			steps = append(steps, hc.ResponseStatusEquals(entry.Response.Status))
+		} else {
+			steps = append(steps, hc.Call())
+		}
+	}
+	return steps
+}
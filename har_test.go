@@ -0,0 +1,87 @@
+package argot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHAR(t *testing.T) {
+	var lock sync.Mutex
+	received := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		body, _ := ioutil.ReadAll(r.Body)
+		received = append(received, fmt.Sprintf("%s %s %s %s %s %s", r.Method, r.URL.Path,
+			r.Header.Get("X-Tea"), r.Header.Get("Content-Type"), r.Header.Get("Accept-Encoding"), body))
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "argot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.har")
+	document := `{"log": {"entries": [
+		{"request": {"method": "POST", "url": "` + server.URL + `/orders",
+			"headers": [
+				{"name": ":authority", "value": "example.com"},
+				{"name": "host", "value": "example.com"},
+				{"name": "accept-encoding", "value": "br"},
+				{"name": "content-length", "value": "17"},
+				{"name": "x-tea", "value": "sencha"}],
+			"postData": {"mimeType": "application/json", "text": "{\"tea\": \"sencha\"}"}},
+		 "response": {"status": 200}},
+		{"request": {"method": "GET", "url": "` + server.URL + `/missing", "headers": []},
+		 "response": {"status": 200}}]}}`
+	if err := ioutil.WriteFile(path, []byte(document), 0644); err != nil {
+		t.Fatal(err)
+	}
+	har, err := LoadHAR(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(har.Log.Entries) != 2 {
+		t.Fatalf("Expected 2 entries; found %d.", len(har.Log.Entries))
+	}
+
+	// Without assertions, every request is still made, and the
+	// headers net/http manages itself are skipped.
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	steps := har.Steps(hc, false)
+	if len(steps) != 6 {
+		t.Fatalf("Expected 6 steps; found %d.", len(steps))
+	}
+	steps.Test(t)
+	if expected := []string{
+		`POST /orders sencha application/json gzip {"tea": "sencha"}`,
+		`GET /missing   gzip `,
+	}; strings.Join(received, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Unexpected requests received:\n%s", strings.Join(received, "\n"))
+	}
+
+	// With assertions, the captured status must match.
+	if _, err := har.Steps(hc, true).Test(nil); err == nil || !strings.Contains(err.Error(), "Status: Expected 200; found 404") {
+		t.Fatalf("Expected the changed status to be reported; found %v", err)
+	}
+
+	// An explicit Content-Type is kept.
+	har.Log.Entries = har.Log.Entries[:1]
+	har.Log.Entries[0].Request.Headers = append(har.Log.Entries[0].Request.Headers, HARNameValue{Name: "content-type", Value: "text/plain"})
+	received = nil
+	har.Steps(hc, true).Test(t)
+	if len(received) != 1 || !strings.Contains(received[0], " text/plain ") {
+		t.Fatalf("Expected the captured Content-Type to be sent; found %v", received)
+	}
+}
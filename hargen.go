@@ -0,0 +1,101 @@
+package argot
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"net/url"
+	"strings"
+)
+
+// GoSource generates the Go source of package pkg with a function,
+// named after name (see OpenAPISpec.GoSource), returning Steps
+// equivalent to those of Steps, so that a session recorded by
+// CaptureProxy (or exported as HAR by a browser or another tool) can be
+// committed as a regression test. The function takes the HttpCall with
+// which to make the requests and a base URL, which replaces the scheme
+// and host of the first request wherever they appear; requests to
+// other hosts keep their recorded URLs.
+//
+// So that no credentials are committed, the values of headers which
+// DefaultRedactor redacts are not recorded in the source: they are
+// read from environment variables, named ARGOT_ followed by the header
+// name, upper-cased with underscores for hyphens (for example
+// ARGOT_AUTHORIZATION). URLs, request bodies and the values of other
+// headers are passed through DefaultRedactor.String, so any secrets
+// within them which it recognises, such as an access_token query
+// parameter, appear as REDACTED and must be filled in by hand.
+func (har *HAR) GoSource(pkg, name string, assertStatus bool) ([]byte, error) {
+	name = goIdentifier(name)
+	origin := ""
+	if len(har.Log.Entries) > 0 {
+		if u, err := url.Parse(har.Log.Entries[0].Request.URL); err != nil {
+			return nil, err
+		} else if u.IsAbs() {
+			origin = u.Scheme + "://" + u.Host
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	usesStrings, usesOS := false, false
+	if origin == "" {
+		fmt.Fprintf(buf, "\n// %s returns the steps of the %d recorded requests.\n", name, len(har.Log.Entries))
+	} else {
+		fmt.Fprintf(buf, "\n// %s returns the steps of the %d recorded requests, made against\n// baseURL rather than %s.\n", name, len(har.Log.Entries), origin)
+	}
+	fmt.Fprintf(buf, "func %s(hc *argot.HttpCall, baseURL string) argot.Steps {\n\treturn argot.Steps{\n", name)
+	for idx, entry := range har.Log.Entries {
+		if idx > 0 {
+			fmt.Fprint(buf, "\n")
+		}
+		redactedURL := DefaultRedactor.String(entry.Request.URL)
+		urlStr := goString(redactedURL)
+		if origin != "" && (redactedURL == origin || strings.HasPrefix(redactedURL, origin+"/") || strings.HasPrefix(redactedURL, origin+"?")) {
+			if rest := redactedURL[len(origin):]; rest == "" {
+				urlStr = "baseURL"
+			} else {
+				urlStr = "baseURL+" + goString(rest)
+			}
+		}
+		body := "nil"
+		if entry.Request.PostData != nil && entry.Request.PostData.Text != "" {
+			body = fmt.Sprintf("strings.NewReader(%s)", goString(DefaultRedactor.String(entry.Request.PostData.Text)))
+			usesStrings = true
+		}
+		fmt.Fprintf(buf, "\t\thc.NewRequest(%q, %s, %s),\n", entry.Request.Method, urlStr, body)
+		for _, header := range entry.Request.headers() {
+			if DefaultRedactor.RedactsHeader(header.Name) {
+				fmt.Fprintf(buf, "\t\thc.RequestHeader(%q, os.Getenv(%q)),\n", header.Name, "ARGOT_"+strings.ToUpper(strings.Replace(header.Name, "-", "_", -1)))
+				usesOS = true
+			} else {
+				fmt.Fprintf(buf, "\t\thc.RequestHeader(%s, %s),\n", goString(header.Name), goString(DefaultRedactor.String(header.Value)))
+			}
+		}
+		if assertStatus && entry.Response.Status != 0 {
+			fmt.Fprintf(buf, "\t\thc.ResponseStatusEquals(%d),\n", entry.Response.Status)
+		} else {
+			fmt.Fprint(buf, "\t\thc.Call(),\n")
+		}
+	}
+	fmt.Fprint(buf, "\t}\n}\n")
+
+	header := new(bytes.Buffer)
+	fmt.Fprintf(header, "// Code generated by argot from recorded requests. Edit as required.\n\npackage %s\n\nimport (\n", pkg)
+	if usesOS {
+		fmt.Fprint(header, "\t\"os\"\n")
+	}
+	if usesStrings {
+		fmt.Fprint(header, "\t\"strings\"\n")
+	}
+	if usesOS || usesStrings {
+		fmt.Fprint(header, "\n")
+	}
+	fmt.Fprint(header, "\t\"github.com/msackman/argot\"\n)\n")
+	return format.Source(append(header.Bytes(), buf.Bytes()...))
+}
+
+// GoSource generates Go source of the requests captured so far, as
+// HAR.GoSource does.
+func (cp *CaptureProxy) GoSource(pkg, name string, assertStatus bool) ([]byte, error) {
+	return cp.HAR().GoSource(pkg, name, assertStatus)
+}
@@ -0,0 +1,52 @@
+package argot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHARGoSource(t *testing.T) {
+	har := new(HAR)
+	har.Log.Entries = []HAREntry{
+		{
+			Request: HARRequest{Method: "GET", URL: "http://localhost:8080/teas?type=green&access_token=hunter2", Headers: []HARNameValue{
+				{Name: ":authority", Value: "localhost:8080"},
+				{Name: "accept", Value: "application/json"},
+				{Name: "authorization", Value: "Bearer secret"},
+			}},
+			Response: HARResponse{Status: 200},
+		},
+		{
+			Request:  HARRequest{Method: "POST", URL: "http://localhost:8080/orders", PostData: &HARPostData{MimeType: "application/json", Text: `{"tea": "sencha", "password": "hunter2"}`}},
+			Response: HARResponse{Status: 201},
+		},
+		{
+			Request:  HARRequest{Method: "GET", URL: "https://cdn.example.com/logo.png"},
+			Response: HARResponse{Status: 200},
+		},
+	}
+	bites, err := har.GoSource("recorded", "checkout flow", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := string(bites)
+	for _, expected := range []string{
+		"package recorded",
+		"\t\"os\"\n\t\"strings\"\n",
+		"func CheckoutFlow(hc *argot.HttpCall, baseURL string) argot.Steps {",
+		"hc.NewRequest(\"GET\", baseURL+`/teas?type=green&access_token=REDACTED`, nil),",
+		"hc.RequestHeader(`Accept`, `application/json`),",
+		"hc.RequestHeader(\"Authorization\", os.Getenv(\"ARGOT_AUTHORIZATION\")),",
+		"hc.NewRequest(\"POST\", baseURL+`/orders`, strings.NewReader(`{\"tea\": \"sencha\", \"password\": \"REDACTED\"}`)),",
+		"hc.RequestHeader(`Content-Type`, `application/json`),",
+		"hc.ResponseStatusEquals(201),",
+		"hc.NewRequest(\"GET\", `https://cdn.example.com/logo.png`, nil),",
+	} {
+		if !strings.Contains(source, expected) {
+			t.Fatalf("Expected %q in source:\n%s", expected, source)
+		}
+	}
+	if strings.Contains(source, "secret") || strings.Contains(source, "hunter2") || strings.Contains(source, ":authority") {
+		t.Fatalf("Unexpected header in source:\n%s", source)
+	}
+}
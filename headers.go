@@ -0,0 +1,125 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// headerValues returns the values of the header key, whose case is
+// ignored. Headers are normally stored under their canonical keys (see
+// http.CanonicalHeaderKey), but those which cannot be canonicalised,
+// and those added to a Header directly, need not be.
+func headerValues(header http.Header, key string) ([]string, bool) {
+	if values, found := header[key]; found {
+		return values, true
+	} else if values, found := header[http.CanonicalHeaderKey(key)]; found {
+		return values, true
+	}
+	for name, values := range header {
+		if strings.EqualFold(name, key) {
+			return values, true
+		}
+	}
+	return nil, false
+}
+
+// HeaderMatch configures how ResponseHeaderMatches compares a header's
+// value with the expected value.
+type HeaderMatch struct {
+	// If true, case is ignored.
+	IgnoreCase bool
+	// If true, leading and trailing whitespace is ignored, and runs of
+	// whitespace are equivalent to a single space.
+	IgnoreWhitespace bool
+	// If true, the header is treated as a comma-separated list (as
+	// Vary, Accept-Encoding and Cache-Control are), joining repeated
+	// headers, and the expected value must equal one of its elements,
+	// which are trimmed of whitespace. Commas within quoted strings
+	// are not understood.
+	ListMember bool
+}
+
+func (hm HeaderMatch) String() string {
+	modes := []string{}
+	if hm.IgnoreCase {
+		modes = append(modes, "ignoring case")
+	}
+	if hm.IgnoreWhitespace {
+		modes = append(modes, "ignoring whitespace")
+	}
+	if hm.ListMember {
+		modes = append(modes, "list member")
+	}
+	return strings.Join(modes, ", ")
+}
+
+func (hm HeaderMatch) normalise(value string) string {
+	if hm.IgnoreWhitespace {
+		value = strings.Join(strings.Fields(value), " ")
+	}
+	if hm.IgnoreCase {
+		value = strings.ToLower(value)
+	}
+	return value
+}
+
+// matches returns true iff the values of a header match value.
+func (hm HeaderMatch) matches(values []string, value string) bool {
+	value = hm.normalise(value)
+	if !hm.ListMember {
+		return len(values) > 0 && hm.normalise(values[0]) == value
+	}
+	for _, elem := range headerListElements(values) {
+		if hm.normalise(elem) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// headerListElements returns the elements of the comma-separated list
+// header with values, trimmed of whitespace, omitting empty elements.
+func headerListElements(values []string) []string {
+	elems := []string{}
+	for _, value := range values {
+		for _, elem := range strings.Split(value, ",") {
+			if elem = strings.TrimSpace(elem); elem != "" {
+				elems = append(elems, elem)
+			}
+		}
+	}
+	return elems
+}
+
+// ResponseHeaderMatches is a Step that when executed ensures there is
+// a non-nil hc.Response and errors unless the header key, which is
+// case-insensitive, matches value as configured by match: for example
+// ignoring case, or as a member of a comma-separated list.
+func (hc *HttpCall) ResponseHeaderMatches(key, value string, match HeaderMatch) Step {
+	redactor := hc.redactor()
+	return hc.step(fmt.Sprintf("ResponseHeaderMatches(%s: %s; %v)", key, redactor.HeaderValue(key, value), match), func() error {
+		hc.coverHeader(key)
+		if err := hc.EnsureResponse(); err != nil {
+			return err
+		}
+		values, found := headerValues(hc.Response.Header, key)
+		if !found {
+			return fmt.Errorf("Header '%s' not found.", key)
+		} else if match.matches(values, value) {
+			return nil
+		} else if match.ListMember {
+			return fmt.Errorf("Header '%s': Expected a list containing '%s' (%v); found '%s'.", key, redactor.HeaderValue(key, value), match, redactor.HeaderValue(key, strings.Join(values, ", ")))
+		} else {
+			return fmt.Errorf("Header '%s': Expected '%s' (%v); found '%s'.", key, redactor.HeaderValue(key, value), match, redactor.HeaderValue(key, values[0]))
+		}
+	})
+}
+
+// ResponseHeaderHasToken is a Step that when executed ensures there is
+// a non-nil hc.Response and errors unless the comma-separated list
+// header key contains token, ignoring case and whitespace: for example
+// that Vary includes Accept-Encoding. See ResponseHeaderMatches.
+func (hc *HttpCall) ResponseHeaderHasToken(key, token string) Step {
+	return hc.ResponseHeaderMatches(key, token, HeaderMatch{IgnoreCase: true, IgnoreWhitespace: true, ListMember: true})
+}
@@ -0,0 +1,41 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseHeaderMatches(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "Application/JSON;  charset=UTF-8")
+		w.Header().Add("Vary", "accept-encoding ,Origin")
+		w.Header().Add("Vary", "Accept-Language")
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseHeaderExists("content-type"),
+		hc.ResponseHeaderNotExists("etag"),
+		hc.ResponseHeaderMatches("content-type", "application/json; charset=utf-8", HeaderMatch{IgnoreCase: true, IgnoreWhitespace: true}),
+		ExpectError(hc.ResponseHeaderMatches("Content-Type", "application/json; charset=utf-8", HeaderMatch{IgnoreCase: true})),
+		hc.ResponseHeaderHasToken("vary", "Accept-Encoding"),
+		hc.ResponseHeaderHasToken("Vary", "accept-language"),
+		ExpectError(hc.ResponseHeaderHasToken("Vary", "Accept")),
+		ExpectError(hc.ResponseHeaderMatches("Vary", "origin", HeaderMatch{ListMember: true})),
+		NewNamedStep("AddRawHeader", func() error {
+			hc.Response.Header["x_raw"] = []string{"value"}
+			return nil
+		}),
+		hc.ResponseHeaderExists("X_Raw"),
+	}.Test(t)
+
+	err := hc.ResponseHeaderHasToken("Vary", "Cookie").Go()
+	if err == nil || !strings.Contains(err.Error(), "Header 'Vary': Expected a list containing 'Cookie' (ignoring case, ignoring whitespace, list member); found 'accept-encoding ,Origin, Accept-Language'.") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
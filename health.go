@@ -0,0 +1,44 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WaitForHealthy is a Step that when executed polls urlStr with GETs,
+// backing off exponentially from 10ms to 1s, until it responds with
+// status 200 and, if schema is not empty, a JSON body satisfying the
+// JSON schema, erroring if it has not within timeout. The error
+// includes the reason the last response was not healthy and its body.
+// hc is left holding the last response.
+func (hc *HttpCall) WaitForHealthy(urlStr, schema string, timeout time.Duration) Step {
+	return hc.step(fmt.Sprintf("WaitForHealthy(%s, %v)", hc.redactor().String(urlStr), timeout), func() error {
+		var body []byte
+		err := waitFor(timeout, func() error {
+			body = nil
+			steps := Steps{
+				hc.NewRequest(http.MethodGet, urlStr, nil),
+				hc.ResponseStatusEquals(http.StatusOK),
+			}
+			if schema != "" {
+				steps = append(steps, hc.ResponseBodyJSONSchema(schema))
+			}
+			err := steps.Go()
+			if hc.Response != nil && hc.ReceiveBody() == nil {
+				body = hc.ResponseBody
+			}
+			if hcErr, ok := err.(*HttpCallError); ok {
+				err = hcErr.Err
+			}
+			return err
+		})
+		if err == nil {
+			return nil
+		} else if body == nil {
+			return fmt.Errorf("Unhealthy: %v", err)
+		} else {
+			return fmt.Errorf("Unhealthy: %v\nLast body: %s", err, hc.redactor().String(string(body)))
+		}
+	})
+}
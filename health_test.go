@@ -0,0 +1,40 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitForHealthy(t *testing.T) {
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&polls, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status": "starting"}`))
+		case 2:
+			w.Write([]byte(`{"status": "degraded"}`))
+		default:
+			w.Write([]byte(`{"status": "ok"}`))
+		}
+	}))
+	defer server.Close()
+
+	schema := `{"type": "object", "properties": {"status": {"enum": ["ok"]}}, "required": ["status"]}`
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	if err := hc.WaitForHealthy(server.URL, schema, 5*time.Second).Go(); err != nil {
+		t.Fatal(err)
+	} else if found := atomic.LoadInt32(&polls); found != 3 {
+		t.Fatalf("Expected 3 polls; found %d.", found)
+	}
+
+	err := hc.WaitForHealthy(server.URL, `{"properties": {"status": {"enum": ["up"]}}}`, 50*time.Millisecond).Go()
+	if err == nil || !strings.Contains(err.Error(), "Unhealthy: Not ready after 50ms: ") || !strings.Contains(err.Error(), `Last body: {"status": "ok"}`) {
+		t.Fatalf("Expected the last body to be reported; found %v.", err)
+	}
+}
@@ -1,6 +1,7 @@
 package argot
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
@@ -8,12 +9,12 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 
-	"github.com/kylelemons/godebug/pretty"
-	"github.com/sergi/go-diff/diffmatchpatch"
 	"github.com/xeipuuv/gojsonschema"
 )
 
@@ -29,6 +30,134 @@ type HttpCall struct {
 	Response *http.Response
 	// The body which once received can be repeatedly reused.
 	ResponseBody []byte
+	// Options used by ResponseMatchesSnapshot.
+	Snapshot SnapshotOptions
+	// The OpenAPI document used by RequestConformsToSpec and
+	// ResponseConformsToSpec.
+	Spec *OpenAPISpec
+	// If non-nil, every interaction is recorded in the Pact. Note
+	// this causes response bodies to be received eagerly.
+	Pact *Pact
+	// If true, the errors of failing steps include a dump of the
+	// request and response (see Dump).
+	DumpOnFailure bool
+	// If non-nil, records which parts of responses are asserted.
+	Coverage *Coverage
+	// Applied to both sides of JSON comparisons and to snapshots.
+	JSONNormalisers []JSONNormaliser
+	// If true, ResponseBodyJSONEquals and ResponseBodyJSONMatchesStruct
+	// decode numbers as json.Number (see json.Decoder.UseNumber)
+	// rather than float64, so that integers too large for a float64,
+	// such as snowflake IDs, must be exactly equal.
+	JSONExactNumbers bool
+	// If true, ResponseBodyJSONMatchesStruct errors if the body
+	// contains fields which are not in the type of the expected value
+	// (and so would be silently ignored when decoding), for example to
+	// detect accidental exposure of data. Fields removed by
+	// hc.JSONNormalisers are permitted.
+	JSONDisallowUnknownFields bool
+	// Redacts sensitive data from step names and failure output. If
+	// nil, DefaultRedactor is used until a step adds a secret, when it
+	// is set to a copy of DefaultRedactor holding the secret.
+	Redactor *Redactor
+	// Provides the secrets used by steps such as
+	// RequestBearerTokenFromSecret.
+	Secrets SecretProvider
+	// If positive, response bodies larger than this many bytes are
+	// spooled to a temporary file rather than held in
+	// hc.ResponseBody. Only the streaming body steps
+	// (ResponseBodyContains, ResponseBodyMatches and
+	// ResponseBodySHA256) and ResponseBodyReader can inspect a spooled
+	// body; steps which need the whole body in memory error.
+	SpoolThreshold int64
+	// If positive, receiving a response body larger than this many
+	// bytes fails, so that an endpoint sending an unbounded body fails
+	// fast rather than exhausting memory or disk.
+	MaxBodySize int64
+	// If non-nil, when a step fails whilst hc holds an HTML response,
+	// the response is rendered by Screenshot and the image attached to
+	// the failure as an artifact (see ScreenshotFunc).
+	Screenshot ScreenshotFunc
+	// Describes the error responses checked by
+	// ResponseIsStandardError. If nil, DefaultErrorEnvelope is used.
+	ErrorEnvelope *ErrorEnvelope
+
+	requestName string
+	trace       *callTrace
+	transfer    *callTransfer
+	spool       string
+	spoolSize   int64
+
+	correlationID string
+	middleware    []Middleware
+	beforeSend    []func(*http.Request) error
+	invariants    []Step
+	checking      bool
+	attempts      int
+	streamed      bool
+	chunks        []StreamChunk
+	// Counts the WarmupSteps running which use hc, or the HttpCall it
+	// was cloned from (see WarmupStep).
+	warmup *int32
+}
+
+// HttpCallError is the error returned by an HttpCall step that fails
+// once a request has been created. As well as the underlying error it
+// carries the request as a curl command (see AsCurl) so that the
+// failing call can be reproduced by hand, and, if
+// HttpCall.DumpOnFailure is set, a dump of the request and response,
+// and, if HttpCall.Screenshot is set and the response is HTML, a
+// screenshot of the response.
+type HttpCallError struct {
+	Err  error
+	Curl string
+	Dump string
+	// The PNG screenshot of the response, if any, or the error from
+	// taking it.
+	Screenshot    []byte
+	ScreenshotErr error
+
+	redactor *Redactor
+}
+
+func (e *HttpCallError) Error() string {
+	msg := e.Err.Error()
+	if e.redactor != nil {
+		msg = e.redactor.String(msg)
+	}
+	if e.Dump == "" {
+		return fmt.Sprintf("%s\nRequest: %s", msg, e.Curl)
+	} else {
+		return fmt.Sprintf("%s\nRequest: %s\nDump:\n%s", msg, e.Curl, e.Dump)
+	}
+}
+
+// Unwrap returns the underlying error.
+func (e *HttpCallError) Unwrap() error {
+	return e.Err
+}
+
+// step creates a NamedStep which, should it fail whilst hc has a
+// request, decorates its error as an HttpCallError. The name should
+// already have been redacted.
+func (hc *HttpCall) step(name string, step StepFunc) *NamedStep {
+	return NewNamedStep(name, func() error {
+		if err := step(); err == nil {
+			return nil
+		} else if _, decorated := err.(*HttpCallError); decorated || hc.Request == nil {
+			return err
+		} else {
+			redactor := hc.redactor()
+			hcErr := &HttpCallError{Err: err, Curl: hc.asCurl(redactor), redactor: redactor}
+			if hc.DumpOnFailure {
+				hcErr.Dump = hc.Dump()
+			}
+			if hc.Screenshot != nil {
+				hcErr.Screenshot, hcErr.ScreenshotErr = hc.screenshot()
+			}
+			return hcErr
+		}
+	})
 }
 
 // NewHttpCall creates a new HttpCall. If client is nil, a new
@@ -39,6 +168,7 @@ func NewHttpCall(client *http.Client) *HttpCall {
 	}
 	return &HttpCall{
 		Client: client,
+		warmup: new(int32),
 	}
 }
 
@@ -71,8 +201,10 @@ func (hc *HttpCall) AssertNoResponse() error {
 
 // EnsureResponse is idempotent. If there is already a response then
 // it will return nil. Otherwise if there is no Request then it will
-// return non-nil. Otherwise it will use hc.Client.Do to perform the
-// request, set hc.Response, and return any error that occurs.
+// return non-nil. Otherwise it will run any BeforeSend hooks, use
+// hc.Client.Do (through any middleware added with Use) to perform the
+// request, set hc.Response, and return any error that occurs: if no
+// response is received, a *TransportError.
 //
 // Always use this in any step where you want to inspect the
 // hc.Response.
@@ -81,13 +213,36 @@ func (hc *HttpCall) EnsureResponse() error {
 		return nil
 	} else if hc.Request == nil {
 		return errors.New("Cannot ensure response: no request.")
-	} else if response, err := hc.Client.Do(hc.Request); err != nil {
-		safeURL := *hc.Request.URL
-		safeURL.User = nil
-		return fmt.Errorf("Error when making call of %v: %v", safeURL, err)
+	} else if err := hc.runBeforeSend(); err != nil {
+		return err
+	}
+	hc.trace = newCallTrace()
+	hc.transfer = new(callTransfer)
+	req := hc.trace.traced(hc.Request)
+	hc.transfer.countRequest(req)
+	start := time.Now()
+	response, err := hc.client().Do(req)
+	safeURL := *hc.Request.URL
+	safeURL.User = nil
+	event := Event{Type: RequestFinished, Method: hc.Request.Method, URL: hc.redactor().String(safeURL.String()), Duration: time.Since(start), Err: err, Warmup: hc.warmingUp()}
+	if err != nil {
+		emit(event)
+		return &TransportError{Kind: ClassifyTransportError(err), URL: safeURL.String(), Err: err}
 	} else {
+		event.Status = response.StatusCode
+		event.Header = response.Header
+		emit(event)
+		hc.transfer.countResponse(response)
+		if hc.Coverage != nil {
+			hc.Coverage.observeResponse(hc.Request, response)
+		}
 		hc.Response = response
-		return nil
+		if hc.Pact != nil {
+			if err := hc.Pact.record(hc); err != nil {
+				return err
+			}
+		}
+		return hc.checkInvariants()
 	}
 }
 
@@ -95,38 +250,102 @@ func (hc *HttpCall) EnsureResponse() error {
 // hc.EnsureResponse. If there is already a non-nil hc.ResponseBody
 // then it will return nil. Otherwise it will receive the
 // Response.Body, store it in hc.ResponseBody, and return any error
-// that occurs.
+// that occurs. If the body was spooled to disk (see SpoolThreshold)
+// it returns an error, as hc.ResponseBody is not available.
 //
 // Always use this in any step where you want to inspect the
 // hc.ResponseBody.
 func (hc *HttpCall) ReceiveBody() error {
+	if err := hc.receiveBody(); err != nil {
+		return err
+	} else if hc.spool != "" {
+		return fmt.Errorf("Body: %d bytes were spooled to disk; use a streaming step such as ResponseBodyContains, ResponseBodyMatches or ResponseBodySHA256.", hc.spoolSize)
+	} else {
+		return nil
+	}
+}
+
+// receiveBody is as ReceiveBody, except that it succeeds if the body
+// is spooled to disk.
+func (hc *HttpCall) receiveBody() error {
 	if err := hc.EnsureResponse(); err != nil {
 		return err
-	} else if hc.ResponseBody != nil {
+	} else if hc.ResponseBody != nil || hc.spool != "" {
 		return nil
+	} else if hc.streamed {
+		return errors.New("Body: The body was consumed by a streaming step such as ResponseStream.")
 	} else {
 		defer hc.Response.Body.Close()
+		var body io.Reader = hc.Response.Body
+		if hc.MaxBodySize > 0 {
+			body = &bodyLimitReader{Reader: body, limit: hc.MaxBodySize}
+		}
 		bites := new(bytes.Buffer)
-		if _, err = io.Copy(bites, hc.Response.Body); err != nil {
+		if hc.SpoolThreshold > 0 {
+			_, err = io.CopyN(bites, body, hc.SpoolThreshold+1)
+			if err == io.EOF {
+				err = nil
+			} else if err == nil && int64(bites.Len()) > hc.SpoolThreshold {
+				err = hc.spoolBody(bites.Bytes(), body)
+			}
+		} else {
+			_, err = io.Copy(bites, body)
+		}
+		if err != nil {
 			return err
 		} else {
-			hc.ResponseBody = bites.Bytes()
+			if hc.spool == "" {
+				hc.ResponseBody = bites.Bytes()
+				if hc.Coverage != nil {
+					hc.Coverage.observeBody(hc.Request, hc.ResponseBody)
+				}
+			}
+			if hc.trace != nil {
+				hc.trace.mark(&hc.trace.bodyDone)()
+			}
 			return nil
 		}
 	}
 }
 
+// bodyLimitReader errors once more than limit bytes have been read.
+type bodyLimitReader struct {
+	io.Reader
+	limit int64
+	read  int64
+}
+
+func (r *bodyLimitReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if r.read += int64(n); r.read > r.limit {
+		return n, fmt.Errorf("Body: Expected at most %d bytes (see MaxBodySize); found more.", r.limit)
+	}
+	return n, err
+}
+
 // Reset is idempotent. You should ensure this is called at the end of
 // life for each HttpCall. It drains Response bodies if necessary, and
 // cleans up resources.
 func (hc *HttpCall) Reset() error {
 	hc.Request = nil
-	if hc.Response != nil && hc.ResponseBody == nil {
+	if hc.Response != nil && hc.ResponseBody == nil && hc.spool == "" {
 		io.Copy(ioutil.Discard, hc.Response.Body)
 		hc.Response.Body.Close()
 	}
+	if hc.spool != "" {
+		os.Remove(hc.spool)
+	}
 	hc.Response = nil
 	hc.ResponseBody = nil
+	hc.spool = ""
+	hc.spoolSize = 0
+	hc.correlationID = ""
+	hc.attempts = 0
+	hc.streamed = false
+	hc.chunks = nil
+	hc.requestName = ""
+	hc.trace = nil
+	hc.transfer = nil
 	return nil
 }
 
@@ -134,25 +353,73 @@ func (hc *HttpCall) Reset() error {
 // using the given parameters. The step will automatically call
 // hc.Reset to tidy up any previous use of hc, and thus prepare hc for
 // the new request.
+//
+// The body, if any, is read into memory the first time the step is
+// executed, so that the request body can be re-read (see
+// http.Request.GetBody) by retries, dumps, signing and assertions such
+// as RequestBodyEquals, and so that executing the step again sends the
+// same body. If the body is an io.Closer, such as an *os.File, it is
+// closed once it has been read. A body which must instead be generated
+// as it is sent, such as a very large one, should be wrapped with
+// StreamingBody.
 func (hc *HttpCall) NewRequest(method, urlStr string, body io.Reader) Step {
-	return NewNamedStep(fmt.Sprintf("NewRequest(%s: %s)", method, urlStr), func() error {
+	name := fmt.Sprintf("NewRequest(%s: %s)", method, hc.redactor().String(urlStr))
+	var buffered []byte
+	read := false
+	return hc.step(name, func() error {
 		if err := hc.Reset(); err != nil {
 			return err
-		} else if req, err := http.NewRequest(method, urlStr, body); err != nil {
+		}
+		reqBody := body
+		if streaming, ok := body.(streamingBody); ok {
+			reqBody = streaming.Reader
+		} else if body != nil {
+			if !read {
+				var err error
+				buffered, err = ioutil.ReadAll(body)
+				if closer, ok := body.(io.Closer); ok {
+					if closeErr := closer.Close(); err == nil {
+						err = closeErr
+					}
+				}
+				if err != nil {
+					return err
+				}
+				read = true
+			}
+			reqBody = bytes.NewReader(buffered)
+		}
+		if req, err := http.NewRequest(method, urlStr, reqBody); err != nil {
 			return err
 		} else {
 			hc.Request = req
+			hc.requestName = name
 			return nil
 		}
 	})
 }
 
+// streamingBody marks a request body which NewRequest must not read
+// into memory.
+type streamingBody struct {
+	io.Reader
+}
+
+// StreamingBody wraps r so that NewRequest sends it as it is read,
+// with chunked transfer encoding, rather than reading it into memory
+// first. The request body then cannot be re-read, so the request
+// cannot be retried, and the step creating it cannot usefully be
+// executed more than once.
+func StreamingBody(r io.Reader) io.Reader {
+	return streamingBody{Reader: r}
+}
+
 // RequestHeader is a Step that when executed will set the given key
 // and value as a header on the HTTP Request. This can only be done
 // after hc.Request has been created (with NewRequest), and before
 // hc.Response has been created.
 func (hc *HttpCall) RequestHeader(key, value string) Step {
-	return NewNamedStep(fmt.Sprintf("RequestHeader(%s: %s)", key, value), func() error {
+	return hc.step(fmt.Sprintf("RequestHeader(%s: %s)", key, hc.redactor().HeaderValue(key, value)), func() error {
 		if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
 			return err
 		} else {
@@ -168,14 +435,15 @@ func (hc *HttpCall) RequestHeader(key, value string) Step {
 // some tests, you may not care about inspecting the HTTP Response but
 // nevertheless wish the HTTP Request to be made.
 func (hc *HttpCall) Call() Step {
-	return NewNamedStep("Call", hc.EnsureResponse)
+	return hc.step("Call", hc.EnsureResponse)
 }
 
 // ResponseStatusEquals is a Step that when executed ensures there is
 // a non-nil hc.Response and errors unless the hc.Response.StatusCode
 // equals the status parameter.
 func (hc *HttpCall) ResponseStatusEquals(status int) Step {
-	return NewNamedStep(fmt.Sprintf("ResponseStatusEquals(%d)", status), func() error {
+	return hc.step(fmt.Sprintf("ResponseStatusEquals(%d)", status), func() error {
+		hc.cover("status")
 		if err := hc.EnsureResponse(); err != nil {
 			return err
 		} else if hc.Response.StatusCode != status {
@@ -187,13 +455,15 @@ func (hc *HttpCall) ResponseStatusEquals(status int) Step {
 }
 
 // ResponseHeaderExists is a Step that when executed ensures there is
-// a non-nil hc.Response and errors unless hc.Response.Header[key]
-// exists. It says nothing about the value of the header.
+// a non-nil hc.Response and errors unless the header key exists. As
+// header names are case-insensitive, so is key. It says nothing about
+// the value of the header.
 func (hc *HttpCall) ResponseHeaderExists(key string) Step {
-	return NewNamedStep(fmt.Sprintf("ResponseHeaderExists(%s)", key), func() error {
+	return hc.step(fmt.Sprintf("ResponseHeaderExists(%s)", key), func() error {
+		hc.coverHeader(key)
 		if err := hc.EnsureResponse(); err != nil {
 			return err
-		} else if _, found := hc.Response.Header[key]; !found {
+		} else if _, found := headerValues(hc.Response.Header, key); !found {
 			return fmt.Errorf("Header '%s' not found.", key)
 		} else {
 			return nil
@@ -202,13 +472,14 @@ func (hc *HttpCall) ResponseHeaderExists(key string) Step {
 }
 
 // ResponseHeaderNotExists is a Step that when executed ensures there
-// is a non-nil hc.Response and errors unless hc.Response.Header[key]
-// does not exist.
+// is a non-nil hc.Response and errors unless the header key, which is
+// case-insensitive, does not exist.
 func (hc *HttpCall) ResponseHeaderNotExists(key string) Step {
-	return NewNamedStep(fmt.Sprintf("ResponseHeaderNotExists(%s)", key), func() error {
+	return hc.step(fmt.Sprintf("ResponseHeaderNotExists(%s)", key), func() error {
+		hc.coverHeader(key)
 		if err := hc.EnsureResponse(); err != nil {
 			return err
-		} else if _, found := hc.Response.Header[key]; found {
+		} else if _, found := headerValues(hc.Response.Header, key); found {
 			return fmt.Errorf("Header '%s' found.", key)
 		} else {
 			return nil
@@ -216,25 +487,19 @@ func (hc *HttpCall) ResponseHeaderNotExists(key string) Step {
 	})
 }
 
-// Diff two strings, output as coloured string, the expected parts will
-// be removed/red if they're missing, the found/inserted parts will be
-// green if present, if the parts are the same, no colour is applied.
-func diff(expected string, got string) string {
-	dmp := diffmatchpatch.New()
-	diffs := dmp.DiffMain(expected, got, false)
-	return dmp.DiffPrettyText(diffs)
-}
-
 // ResponseHeaderEquals is a Step that when executed ensures there is
 // a non-nil hc.Response and errors unless the
 // hc.Response.Header.Get(key) equals the value parameter. Note this
 // is an exact match.
 func (hc *HttpCall) ResponseHeaderEquals(key, value string) Step {
-	return NewNamedStep(fmt.Sprintf("ResponseHeaderEquals(%s: %s)", key, value), func() error {
+	return hc.step(fmt.Sprintf("ResponseHeaderEquals(%s: %s)", key, hc.redactor().HeaderValue(key, value)), func() error {
+		hc.coverHeader(key)
 		if err := hc.EnsureResponse(); err != nil {
 			return err
-		} else if header := hc.Response.Header.Get(key); header != value {
-			return fmt.Errorf("Header: '%s': Diff: '%s'.", key, diff(value, header))
+		} else if header := hc.Response.Header.Get(key); header != value && hc.redactor().RedactsHeader(key) {
+			return fmt.Errorf("Header: '%s': Expected %s; found a different %s value.", key, redactedValue, redactedValue)
+		} else if header != value {
+			return diffError(joinPath("header", key), value, header, "Header: '%s': Diff: '%s'.", key, diff(value, header))
 		} else {
 			return nil
 		}
@@ -246,11 +511,13 @@ func (hc *HttpCall) ResponseHeaderEquals(key, value string) Step {
 // hc.Response.Header.Get(key) contains the value parameter using
 // strings.Contains.
 func (hc *HttpCall) ResponseHeaderContains(key, value string) Step {
-	return NewNamedStep(fmt.Sprintf("ResponseHeaderContains(%s: %s)", key, value), func() error {
+	return hc.step(fmt.Sprintf("ResponseHeaderContains(%s: %s)", key, hc.redactor().HeaderValue(key, value)), func() error {
+		hc.coverHeader(key)
 		if err := hc.EnsureResponse(); err != nil {
 			return err
 		} else if header := hc.Response.Header.Get(key); !strings.Contains(header, value) {
-			return fmt.Errorf("Header '%s': Expected '%s'; found '%s'.", key, value, header)
+			redactor := hc.redactor()
+			return fmt.Errorf("Header '%s': Expected '%s'; found '%s'.", key, redactor.HeaderValue(key, value), redactor.HeaderValue(key, header))
 		} else {
 			return nil
 		}
@@ -261,11 +528,12 @@ func (hc *HttpCall) ResponseHeaderContains(key, value string) Step {
 // non-nil hc.ResponseBody and errors unless the hc.ResponseBody
 // equals the value parameter. Note this is an exact match.
 func (hc *HttpCall) ResponseBodyEquals(value string) Step {
-	return NewNamedStep("ResponseBodyEquals", func() error {
+	return hc.step("ResponseBodyEquals", func() error {
+		hc.cover("body:")
 		if err := hc.ReceiveBody(); err != nil {
 			return err
 		} else if bodyStr := string(hc.ResponseBody); bodyStr != value {
-			return fmt.Errorf("Body: Diff: '%s'.", diff(value, bodyStr))
+			return diffError("body", value, bodyStr, "Body: Diff: '%s'.", diff(value, bodyStr))
 		} else {
 			return nil
 		}
@@ -274,13 +542,22 @@ func (hc *HttpCall) ResponseBodyEquals(value string) Step {
 
 // ResponseBodyContains is a Step that when executed ensures there is
 // a non-nil hc.ResponseBody and errors unless the hc.ResponseBody
-// contains the value parameter using strings.Contains.
+// contains the value parameter using strings.Contains. If the body
+// was spooled to disk (see SpoolThreshold) it is searched as a
+// stream.
 func (hc *HttpCall) ResponseBodyContains(value string) Step {
-	return NewNamedStep("ResponseBodyContains", func() error {
-		if err := hc.ReceiveBody(); err != nil {
+	return hc.step("ResponseBodyContains", func() error {
+		if err := hc.receiveBody(); err != nil {
 			return err
-		} else if !strings.Contains(string(hc.ResponseBody), value) {
-			return fmt.Errorf("Body: Expected '%s'; found '%s'.", value, string(hc.ResponseBody))
+		} else if hc.spool == "" {
+			if !strings.Contains(string(hc.ResponseBody), value) {
+				return fmt.Errorf("Body: Expected '%s'; found '%s'.", value, string(hc.ResponseBody))
+			}
+			return nil
+		} else if found, err := hc.streamBody(func(r io.Reader) (bool, error) { return readerContains(r, []byte(value)) }); err != nil {
+			return err
+		} else if !found {
+			return fmt.Errorf("Body: Expected '%s'; found none in %d spooled bytes.", value, hc.spoolSize)
 		} else {
 			return nil
 		}
@@ -289,13 +566,21 @@ func (hc *HttpCall) ResponseBodyContains(value string) Step {
 
 // ResponseBodyMatches is a Step that when executed ensures there is
 // a non-nil hc.ResponseBody and errors unless the hc.ResponseBody
-// matches the regular expression parameter.
+// matches the regular expression parameter. If the body was spooled
+// to disk (see SpoolThreshold) it is matched as a stream.
 func (hc *HttpCall) ResponseBodyMatches(pattern *regexp.Regexp) Step {
-	return NewNamedStep(fmt.Sprintf("ResponseBodyMatches(%v)", pattern), func() error {
-		if err := hc.ReceiveBody(); err != nil {
+	return hc.step(fmt.Sprintf("ResponseBodyMatches(%v)", pattern), func() error {
+		if err := hc.receiveBody(); err != nil {
 			return err
-		} else if !pattern.MatchString(string(hc.ResponseBody)) {
-			return fmt.Errorf("Body: Expected to match the pattern '%v'; found '%s'.", pattern, string(hc.ResponseBody))
+		} else if hc.spool == "" {
+			if !pattern.MatchString(string(hc.ResponseBody)) {
+				return fmt.Errorf("Body: Expected to match the pattern '%v'; found '%s'.", pattern, string(hc.ResponseBody))
+			}
+			return nil
+		} else if found, err := hc.streamBody(func(r io.Reader) (bool, error) { return pattern.MatchReader(bufio.NewReader(r)), nil }); err != nil {
+			return err
+		} else if !found {
+			return fmt.Errorf("Body: Expected to match the pattern '%v'; found no match in %d spooled bytes.", pattern, hc.spoolSize)
 		} else {
 			return nil
 		}
@@ -306,45 +591,125 @@ func (hc *HttpCall) ResponseBodyMatches(pattern *regexp.Regexp) Step {
 // is a non-nil hc.ResponseBody and errors unless the hc.ResponseBody
 // can be validated against the schema parameter using gojsonschema.
 func (hc *HttpCall) ResponseBodyJSONSchema(schema string) Step {
-	return NewNamedStep("ResponseBodyJSONSchema", func() error {
+	return hc.step("ResponseBodyJSONSchema", func() error {
+		hc.cover("body:")
 		if err := hc.ReceiveBody(); err != nil {
 			return err
 		} else {
 			schemaLoader := gojsonschema.NewStringLoader(schema)
 			bodyLoader := gojsonschema.NewStringLoader(string(hc.ResponseBody))
-			if result, err := gojsonschema.Validate(schemaLoader, bodyLoader); err != nil {
-				return err
-			} else if !result.Valid() {
-				msg := "Validation failure:\n"
-				for _, err := range result.Errors() {
-					msg += fmt.Sprintf("\t%v\n", err)
-				}
-				return errors.New(msg[:len(msg)-1])
-			} else {
-				return nil
-			}
+			return validateJSONSchema(schemaLoader, bodyLoader)
+		}
+	})
+}
+
+// RequestBodyEquals is a Step that when executed errors unless the
+// body of hc.Request equals the value parameter exactly. It may be used
+// before or after the request has been sent, provided the body can be
+// re-read (see NewRequest).
+func (hc *HttpCall) RequestBodyEquals(value string) Step {
+	return hc.step("RequestBodyEquals", func() error {
+		if err := hc.AssertRequest(); err != nil {
+			return err
+		} else if hc.Response != nil && hc.Request.Body != nil && hc.Request.Body != http.NoBody && hc.Request.GetBody == nil {
+			return errors.New("Request body cannot be re-read: it has been sent.")
+		} else if body, err := RequestBodyBytes(hc.Request); err != nil {
+			return err
+		} else if string(body) != value {
+			return fmt.Errorf("Request body: Expected '%s'; found '%s'.", value, body)
+		} else {
+			return nil
+		}
+	})
+}
+
+// RequestBodyConformsToJSONSchema is a Step that when executed errors
+// unless the body of hc.Request, which is yet to be sent, can be
+// validated against the schema parameter using gojsonschema, so that
+// mistakes in test data are caught before they provoke confusing
+// errors from the server. This can only be done after hc.Request has
+// been created (with NewRequest), and before hc.Response has been
+// created.
+func (hc *HttpCall) RequestBodyConformsToJSONSchema(schema string) Step {
+	return hc.step("RequestBodyConformsToJSONSchema", func() error {
+		if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
+			return err
+		} else if body, err := readRequestBody(hc.Request); err != nil {
+			return err
+		} else if err := validateJSONSchema(gojsonschema.NewStringLoader(schema), gojsonschema.NewBytesLoader(body)); err != nil {
+			return fmt.Errorf("Request body: %v", err)
+		} else {
+			return nil
 		}
 	})
 }
 
+// validateJSONSchema validates the document against the schema,
+// returning an error listing every validation failure (see
+// RegisterJSONSchemaFormat for custom formats).
+func validateJSONSchema(schemaLoader, documentLoader gojsonschema.JSONLoader) error {
+	if result, err := gojsonschema.Validate(schemaLoader, documentLoader); err != nil {
+		return err
+	} else if !result.Valid() {
+		return errors.New(jsonSchemaFailure("", result.Errors()))
+	} else {
+		return nil
+	}
+}
+
 // ResponseBodyJSONMatchesStruct is a Step that when executed ensures
 // there is a non-nil hc.ResponseBody, parses it as JSON (via
 // encoding/json) based on the type of the expected structure and errors
 // unless it is equal to the expected value, as validated by the pretty
-// package.  The error will contain a structured diff output with a
-// plus/"+" marking the values that were expected and a minus/"-"
-// marking the values that were actually present.
+// package (see CompareConfig and RegisterComparator). The error will
+// contain a structured diff output with a plus/"+" marking the values
+// that were expected and a minus/"-" marking the values that were
+// actually present. Numbers decoded into interface{} values are
+// float64 unless hc.JSONExactNumbers is set.
 func (hc *HttpCall) ResponseBodyJSONMatchesStruct(expected interface{}) Step {
-	return NewNamedStep("ResponseBodyJSONMatchesStruct", func() error {
-		parseAs := reflect.New(reflect.TypeOf(expected)).Interface()
-		if err := hc.ReceiveBody(); err != nil {
+	return hc.step("ResponseBodyJSONMatchesStruct", func() error {
+		return hc.jsonMatchesStruct(expected, 0)
+	})
+}
+
+// ResponseBodyJSONMatchesStructWithin is as
+// ResponseBodyJSONMatchesStruct, except that floating point numbers
+// anywhere within the values are equal if they differ by no more than
+// epsilon.
+func (hc *HttpCall) ResponseBodyJSONMatchesStructWithin(expected interface{}, epsilon float64) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyJSONMatchesStructWithin(%v)", epsilon), func() error {
+		return hc.jsonMatchesStruct(expected, epsilon)
+	})
+}
+
+// jsonMatchesStruct parses the body as JSON based on the type of
+// expected, normalising both it and expected if there are
+// hc.JSONNormalisers or hc.JSONExactNumbers is set, and errors unless
+// they are equal, or if hc.JSONDisallowUnknownFields is set and the
+// body has fields unknown to the type of expected.
+func (hc *HttpCall) jsonMatchesStruct(expected interface{}, epsilon float64) error {
+	hc.coverJSONValue(expected)
+	parseAs := reflect.New(reflect.TypeOf(expected)).Interface()
+	if err := hc.ReceiveBody(); err != nil {
+		return err
+	} else if err := json.Unmarshal(hc.ResponseBody, parseAs); err != nil {
+		return err
+	} else if hc.JSONDisallowUnknownFields {
+		if err := hc.unknownFieldsError(hc.ResponseBody, reflect.TypeOf(expected)); err != nil {
 			return err
-		} else if err := json.Unmarshal(hc.ResponseBody, parseAs); err != nil {
+		}
+	}
+	if len(hc.JSONNormalisers) > 0 || hc.JSONExactNumbers {
+		var err error
+		if parseAs, err = hc.normaliseInto(json.RawMessage(hc.ResponseBody), reflect.TypeOf(expected)); err != nil {
+			return err
+		} else if expected, err = hc.normaliseInto(expected, reflect.TypeOf(expected)); err != nil {
 			return err
-		} else if diff := pretty.Compare(parseAs, expected); diff != "" {
-			return fmt.Errorf("Did not match expected value: (-got +want)\n%s", diff)
-		} else {
-			return nil
 		}
-	})
+	}
+	if err := compareErrorWithin(parseAs, expected, epsilon); err != nil {
+		return err
+	} else {
+		return nil
+	}
 }
@@ -0,0 +1,59 @@
+package argot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestBodyReplayable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	newRequest := hc.NewRequest("POST", server.URL, ioutil.NopCloser(strings.NewReader("payload")))
+	Steps{
+		newRequest,
+		hc.RequestBodyEquals("payload"),
+		hc.ResponseBodyEquals("payload"),
+		hc.RequestBodyEquals("payload"),
+		ExpectError(hc.RequestBodyEquals("other")),
+		// The body is sent again when the step is executed again.
+		newRequest,
+		hc.ResponseBodyEquals("payload"),
+	}.Test(t)
+
+	Steps{
+		hc.NewRequest("POST", server.URL, StreamingBody(ioutil.NopCloser(strings.NewReader("streamed")))),
+		hc.ResponseBodyEquals("streamed"),
+		ExpectError(hc.RequestBodyEquals("streamed")),
+	}.Test(t)
+	if hc.Request.ContentLength != 0 {
+		t.Fatalf("Expected a streamed body to have no Content-Length; found %d.", hc.Request.ContentLength)
+	}
+
+	file := &closeRecorder{Reader: strings.NewReader("file")}
+	Steps{
+		hc.NewRequest("POST", server.URL, file),
+		hc.ResponseBodyEquals("file"),
+	}.Test(t)
+	if !file.closed {
+		t.Fatal("Expected the body to be closed once it had been buffered.")
+	}
+}
+
+type closeRecorder struct {
+	*strings.Reader
+	closed bool
+}
+
+func (cr *closeRecorder) Close() error {
+	cr.closed = true
+	return nil
+}
@@ -0,0 +1,442 @@
+package argot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheOutcome is how an HTTPCache handled a request.
+type CacheOutcome int
+
+const (
+	// CacheMiss is a request forwarded to the server unconditionally.
+	CacheMiss CacheOutcome = iota
+	// CacheHit is a request served from a fresh stored response.
+	CacheHit
+	// CacheRevalidated is a request served from a stale (or no-cache)
+	// stored response, after the server confirmed with 304 Not
+	// Modified that it is still valid.
+	CacheRevalidated
+)
+
+func (o CacheOutcome) String() string {
+	switch o {
+	case CacheHit:
+		return "hit"
+	case CacheRevalidated:
+		return "revalidated"
+	default:
+		return "miss"
+	}
+}
+
+// HTTPCache is an in-memory simulation of an HTTP cache, following the
+// rules of RFC 9111 closely enough to check that a server's responses
+// are cached as intended: which responses are stored (Cache-Control
+// no-store and private, and requests with Authorization), how long
+// they are fresh for (s-maxage, max-age and Expires; heuristic
+// freshness is not used), revalidation of stale and no-cache responses
+// with If-None-Match or If-Modified-Since, and selection of stored
+// responses with Vary. Only GET requests without bodies, and status
+// 200 responses, are cached. Use it as Middleware (see
+// HttpCall.Use), or through HttpCall.CacheSemantics. Its clock may be
+// advanced with Advance, so that responses can be made stale without
+// waiting. An HTTPCache is safe for concurrent use.
+type HTTPCache struct {
+	// If true, the cache is shared, as a CDN or proxy is, rather than
+	// private, as a browser's is.
+	Shared bool
+
+	lock     sync.Mutex
+	offset   time.Duration
+	entries  map[string][]*httpCacheEntry
+	outcomes []CacheOutcome
+}
+
+type httpCacheEntry struct {
+	vary     http.Header
+	header   http.Header
+	body     []byte
+	stored   time.Time
+	lifetime time.Duration
+}
+
+// NewHTTPCache creates a new, empty, HTTPCache.
+func NewHTTPCache(shared bool) *HTTPCache {
+	return &HTTPCache{Shared: shared, entries: make(map[string][]*httpCacheEntry)}
+}
+
+// Advance moves the cache's clock forwards by d.
+func (c *HTTPCache) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.offset += d
+}
+
+func (c *HTTPCache) now() time.Time {
+	return time.Now().Add(c.offset)
+}
+
+// Outcomes returns the outcome of every request the cache has
+// handled, in order.
+func (c *HTTPCache) Outcomes() []CacheOutcome {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]CacheOutcome{}, c.outcomes...)
+}
+
+// LastOutcome returns the outcome of the last request the cache
+// handled, or CacheMiss if there has been none.
+func (c *HTTPCache) LastOutcome() CacheOutcome {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.outcomes) == 0 {
+		return CacheMiss
+	}
+	return c.outcomes[len(c.outcomes)-1]
+}
+
+// ExpectOutcome is a Step that when executed errors unless the last
+// request the cache handled had the given outcome.
+func (c *HTTPCache) ExpectOutcome(outcome CacheOutcome) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectCacheOutcome(%v)", outcome), func() error {
+		if found := c.LastOutcome(); found != outcome {
+			return fmt.Errorf("Cache: Expected a %v; found a %v.", outcome, found)
+		} else {
+			return nil
+		}
+	})
+}
+
+// cacheControl parses the Cache-Control directives of header,
+// lower-casing their names. Directives without values map to "".
+func cacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, arg := directive, ""
+			if idx := strings.IndexByte(directive, '='); idx >= 0 {
+				name, arg = directive[:idx], strings.Trim(strings.TrimSpace(directive[idx+1:]), `"`)
+			}
+			directives[strings.ToLower(strings.TrimSpace(name))] = arg
+		}
+	}
+	return directives
+}
+
+// storable returns nil iff the response to req may be stored, or the
+// reason it may not.
+func (c *HTTPCache) storable(req *http.Request, response *http.Response) error {
+	cc := cacheControl(response.Header)
+	_, noStore := cc["no-store"]
+	_, private := cc["private"]
+	_, public := cc["public"]
+	_, sMaxAge := cc["s-maxage"]
+	_, mustRevalidate := cc["must-revalidate"]
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("its status is %d", response.StatusCode)
+	} else if noStore {
+		return errors.New("Cache-Control has no-store")
+	} else if _, reqNoStore := cacheControl(req.Header)["no-store"]; reqNoStore {
+		return errors.New("the request's Cache-Control has no-store")
+	} else if c.Shared && private {
+		return errors.New("Cache-Control has private")
+	} else if c.Shared && req.Header.Get("Authorization") != "" && !public && !sMaxAge && !mustRevalidate {
+		return errors.New("the request has Authorization, and Cache-Control has none of public, s-maxage and must-revalidate")
+	} else if response.Header.Get("Vary") == "*" {
+		return errors.New("Vary is *")
+	} else {
+		return nil
+	}
+}
+
+// lifetime returns the freshness lifetime of a response with header.
+func (c *HTTPCache) lifetime(header http.Header) time.Duration {
+	cc := cacheControl(header)
+	if value, found := cc["s-maxage"]; found && c.Shared {
+		if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Duration(secs) * time.Second
+		}
+	}
+	if value, found := cc["max-age"]; found {
+		if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Duration(secs) * time.Second
+		}
+		return 0
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		date, err := http.ParseTime(header.Get("Date"))
+		if err != nil {
+			return 0
+		}
+		if lifetime := expiresAt.Sub(date); lifetime > 0 {
+			return lifetime
+		}
+	}
+	return 0
+}
+
+// matches returns true iff the entry was stored from a request whose
+// values of the headers named by its Vary equal those of req.
+func (e *httpCacheEntry) matches(req *http.Request) bool {
+	for name, values := range e.vary {
+		if strings.Join(req.Header.Values(name), ", ") != strings.Join(values, ", ") {
+			return false
+		}
+	}
+	return true
+}
+
+// varyValues returns the values of the headers of req named by the
+// Vary of header.
+func varyValues(req *http.Request, header http.Header) http.Header {
+	vary := make(http.Header)
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" {
+				vary[name] = req.Header.Values(name)
+			}
+		}
+	}
+	return vary
+}
+
+// lookup returns the stored entry matching req, if any.
+func (c *HTTPCache) lookup(req *http.Request) *httpCacheEntry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, entry := range c.entries[req.URL.String()] {
+		if entry.matches(req) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// store stores the response to req, replacing any entry it matches.
+func (c *HTTPCache) store(req *http.Request, header http.Header, body []byte) *httpCacheEntry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry := &httpCacheEntry{vary: varyValues(req, header), header: header, body: body, stored: c.now(), lifetime: c.lifetime(header)}
+	key := req.URL.String()
+	entries := []*httpCacheEntry{entry}
+	for _, existing := range c.entries[key] {
+		if !existing.matches(req) {
+			entries = append(entries, existing)
+		}
+	}
+	if c.entries == nil {
+		c.entries = make(map[string][]*httpCacheEntry)
+	}
+	c.entries[key] = entries
+	return entry
+}
+
+func (c *HTTPCache) record(outcome CacheOutcome) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.outcomes = append(c.outcomes, outcome)
+}
+
+// response returns a new response to req from the stored entry, with
+// an Age header.
+func (c *HTTPCache) response(req *http.Request, entry *httpCacheEntry) *http.Response {
+	c.lock.Lock()
+	age := c.now().Sub(entry.stored)
+	c.lock.Unlock()
+	header := entry.header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(entry.body)),
+		ContentLength: int64(len(entry.body)),
+		Request:       req,
+	}
+}
+
+// Middleware is the Middleware (see HttpCall.Use) which serves
+// requests from the cache.
+func (c *HTTPCache) Middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodGet || req.Body != nil && req.Body != http.NoBody {
+			c.record(CacheMiss)
+			return next.RoundTrip(req)
+		}
+		entry := c.lookup(req)
+		if entry != nil {
+			_, noCache := cacheControl(entry.header)["no-cache"]
+			c.lock.Lock()
+			fresh := !noCache && c.now().Sub(entry.stored) < entry.lifetime
+			c.lock.Unlock()
+			if fresh {
+				c.record(CacheHit)
+				return c.response(req, entry), nil
+			}
+		}
+
+		out := req
+		if entry != nil {
+			if etag := entry.header.Get("ETag"); etag != "" {
+				out = req.Clone(req.Context())
+				out.Header.Set("If-None-Match", etag)
+			} else if lastModified := entry.header.Get("Last-Modified"); lastModified != "" {
+				out = req.Clone(req.Context())
+				out.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+		response, err := next.RoundTrip(out)
+		if err != nil {
+			return nil, err
+		}
+		if out != req && response.StatusCode == http.StatusNotModified {
+			response.Body.Close()
+			header := entry.header.Clone()
+			for key, values := range response.Header {
+				header[key] = values
+			}
+			entry = c.store(req, header, entry.body)
+			c.record(CacheRevalidated)
+			return c.response(req, entry), nil
+		}
+		c.record(CacheMiss)
+		if c.storable(req, response) != nil {
+			return response, nil
+		}
+		body, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		response.Body = ioutil.NopCloser(bytes.NewReader(body))
+		c.store(req, response.Header.Clone(), body)
+		return response, nil
+	})
+}
+
+// CacheExpectation describes how a resource is intended to be cached,
+// for HttpCall.CacheSemantics.
+type CacheExpectation struct {
+	// If true, check the behaviour of a shared cache, such as a CDN,
+	// rather than a private one.
+	Shared bool
+	// Header is sent with every request.
+	Header http.Header
+	// If true, the response must not be stored at all, as personal
+	// data must not be by a shared cache. The fields below are then
+	// not checked.
+	NotStored bool
+	// If non-zero, the response must be fresh for at least this long,
+	// so that a repeated request is a hit.
+	MinFreshness time.Duration
+	// If true, once stale (or at once, if it has Cache-Control
+	// no-cache) the response must be revalidated, the server
+	// responding 304 Not Modified to a conditional request.
+	Revalidate bool
+	// Vary maps each request header which the response must vary on
+	// (that is, list in its Vary) to a value other than that in Header:
+	// a request with that value must not be served the stored
+	// response.
+	Vary map[string]string
+}
+
+// CacheSemantics is a Step that when executed GETs urlStr through a
+// new HTTPCache, and errors unless the server's responses are cached
+// as expect describes: not stored; or stored and fresh for at least
+// MinFreshness, a repeated request then being a hit; varying on the
+// headers of Vary; and revalidated once stale. Each response must have
+// status 200. hc is left holding the last response.
+func (hc *HttpCall) CacheSemantics(urlStr string, expect CacheExpectation) Step {
+	return hc.step(fmt.Sprintf("CacheSemantics(%s)", hc.redactor().String(urlStr)), func() error {
+		cache := NewHTTPCache(expect.Shared)
+		middleware := hc.middleware
+		hc.middleware = append(append([]Middleware(nil), middleware...), cache.Middleware)
+		defer func() { hc.middleware = middleware }()
+
+		get := func(stage string, key, value string) error {
+			steps := Steps{hc.NewRequest(http.MethodGet, urlStr, nil)}
+			for _, name := range sortedHeaderKeys(expect.Header) {
+				if name != http.CanonicalHeaderKey(key) {
+					steps = append(steps, hc.RequestHeader(name, expect.Header.Get(name)))
+				}
+			}
+			if key != "" {
+				steps = append(steps, hc.RequestHeader(key, value))
+			}
+			steps = append(steps, hc.ResponseStatusEquals(http.StatusOK))
+			if err := steps.Go(); err != nil {
+				if hcErr, ok := err.(*HttpCallError); ok {
+					err = hcErr.Err
+				}
+				return fmt.Errorf("%s: %v", stage, err)
+			}
+			return nil
+		}
+
+		if err := get("First GET", "", ""); err != nil {
+			return err
+		}
+		directives := hc.Response.Header.Get("Cache-Control")
+		entry := cache.lookup(hc.Request)
+		if expect.NotStored {
+			if entry != nil {
+				return fmt.Errorf("Cache: Expected the response not to be stored; it was (Cache-Control: %q).", directives)
+			}
+			return nil
+		} else if entry == nil {
+			return fmt.Errorf("Cache: Expected the response to be stored; it was not, as %v.", cache.storable(hc.Request, hc.Response))
+		}
+
+		if expect.MinFreshness > 0 {
+			if entry.lifetime < expect.MinFreshness {
+				return fmt.Errorf("Cache: Expected to be fresh for at least %v; fresh for %v (Cache-Control: %q).", expect.MinFreshness, entry.lifetime, directives)
+			} else if err := get("Second GET", "", ""); err != nil {
+				return err
+			} else if outcome := cache.LastOutcome(); outcome != CacheHit {
+				return fmt.Errorf("Cache: Second GET: Expected a %v; found a %v.", CacheHit, outcome)
+			}
+		}
+
+		for _, key := range sortedKeys(expect.Vary) {
+			if _, found := entry.vary[http.CanonicalHeaderKey(key)]; !found {
+				return fmt.Errorf("Cache: Vary: Expected %s; found %q.", http.CanonicalHeaderKey(key), strings.Join(entry.header.Values("Vary"), ", "))
+			} else if err := get(fmt.Sprintf("GET with %s: %s", key, expect.Vary[key]), key, expect.Vary[key]); err != nil {
+				return err
+			} else if outcome := cache.LastOutcome(); outcome == CacheHit {
+				return fmt.Errorf("Cache: GET with %s: %s: Expected a %v; found a %v.", key, expect.Vary[key], CacheMiss, outcome)
+			}
+		}
+
+		if expect.Revalidate {
+			if entry.header.Get("ETag") == "" && entry.header.Get("Last-Modified") == "" {
+				return errors.New("Cache: Expected a validator (ETag or Last-Modified) with which to revalidate; found none.")
+			}
+			cache.Advance(entry.lifetime + time.Second)
+			if err := get("Stale GET", "", ""); err != nil {
+				return err
+			} else if outcome := cache.LastOutcome(); outcome != CacheRevalidated {
+				return fmt.Errorf("Cache: Stale GET: Expected to be revalidated with 304 Not Modified; found a %v.", outcome)
+			}
+		}
+		return nil
+	})
+}
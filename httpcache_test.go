@@ -0,0 +1,99 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheSemantics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/static":
+			w.Header().Set("Cache-Control", "public, max-age=3600")
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Vary", "Accept-Language")
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte("hello " + r.Header.Get("Accept-Language")))
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+			w.Write([]byte("yours"))
+		case "/unvalidated":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Write([]byte("data"))
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.CacheSemantics(server.URL+"/static", CacheExpectation{
+			Shared:       true,
+			Header:       http.Header{"Accept-Language": {"en"}},
+			MinFreshness: time.Hour,
+			Revalidate:   true,
+			Vary:         map[string]string{"Accept-Language": "fr"},
+		}),
+		hc.ResponseBodyEquals("hello en"),
+		hc.CacheSemantics(server.URL+"/private", CacheExpectation{Shared: true, NotStored: true}),
+		hc.CacheSemantics(server.URL+"/private", CacheExpectation{MinFreshness: time.Minute}),
+	}.Test(t)
+
+	for _, c := range []struct {
+		path   string
+		expect CacheExpectation
+		err    string
+	}{
+		{"/static", CacheExpectation{MinFreshness: 2 * time.Hour}, "Expected to be fresh for at least 2h0m0s; fresh for 1h0m0s"},
+		{"/static", CacheExpectation{NotStored: true}, "Expected the response not to be stored"},
+		{"/private", CacheExpectation{Shared: true}, "Expected the response to be stored; it was not, as Cache-Control has private."},
+		{"/unvalidated", CacheExpectation{Revalidate: true}, "Expected a validator"},
+		{"/unvalidated", CacheExpectation{Vary: map[string]string{"Accept": "text/plain"}}, "Vary: Expected Accept; found \"\"."},
+	} {
+		if err := hc.CacheSemantics(server.URL+c.path, c.expect).Go(); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: Unexpected error: %v", c.path, err)
+		}
+	}
+}
+
+func TestHTTPCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=10")
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if r.Header.Get("If-Modified-Since") != "" {
+			w.WriteHeader(http.StatusNotModified)
+		} else {
+			w.Write([]byte("body"))
+		}
+	}))
+	defer server.Close()
+
+	cache := NewHTTPCache(false)
+	hc := NewHttpCall(nil)
+	hc.Use(cache.Middleware)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseBodyEquals("body"),
+		cache.ExpectOutcome(CacheMiss),
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseBodyEquals("body"),
+		cache.ExpectOutcome(CacheHit),
+		NewNamedStep("Advance", func() error { cache.Advance(time.Minute); return nil }),
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseBodyEquals("body"),
+		hc.ResponseHeaderEquals("Age", "0"),
+		cache.ExpectOutcome(CacheRevalidated),
+	}.Test(t)
+	if requests != 2 {
+		t.Fatalf("Expected 2 requests to reach the server; found %d.", requests)
+	}
+}
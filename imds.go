@@ -0,0 +1,307 @@
+package argot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AWSCredentials are the temporary credentials of an EC2 instance's IAM
+// role, as served by IMDSStub.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Token           string
+	Expiration      time.Time
+}
+
+// IMDSStub is a local HTTP server which stubs the instance metadata
+// services of cloud providers, so that a service which reads its
+// credentials or identity from instance metadata can be tested
+// hermetically, with steps controlling what it is served. Point the
+// system under test at URL: for example with
+// AWS_EC2_METADATA_SERVICE_ENDPOINT for the AWS SDKs, or
+// GCE_METADATA_HOST (without the scheme) for the Google Cloud
+// libraries.
+//
+// Values are set by their path, and served as set. Paths beneath
+// /latest/ follow AWS's IMDS: a session token is issued by PUT
+// /latest/api/token, and must then be valid if sent (and, with
+// RequireToken, must be sent, as for IMDSv2 only); a GET of a
+// directory, such as /latest/meta-data/, lists its children; and
+// instance-id, placement/region, placement/availability-zone and the
+// instance identity document have default values. Paths beneath
+// /computeMetadata/ require the header Metadata-Flavor: Google, as
+// Google Cloud's metadata server does, and paths beneath /metadata/
+// require Metadata: true, as Azure's does.
+type IMDSStub struct {
+	// The base URL of the stub, for example http://127.0.0.1:34567.
+	URL string
+
+	listener     net.Listener
+	server       *http.Server
+	lock         sync.Mutex
+	values       map[string]string
+	tokens       map[string]time.Time
+	requireToken bool
+	status       int
+	requested    []string
+}
+
+// NewIMDSStub creates a new IMDSStub listening on addr. If addr is
+// empty, a random port on the loopback interface is used. Close must
+// be called to stop the stub.
+func NewIMDSStub(addr string) (*IMDSStub, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	stub := &IMDSStub{
+		URL:      "http://" + listener.Addr().String(),
+		listener: listener,
+		values:   make(map[string]string),
+		tokens:   make(map[string]time.Time),
+	}
+	stub.Set("/latest/meta-data/instance-id", "i-0123456789abcdef0")
+	stub.Set("/latest/meta-data/placement/region", "us-east-1")
+	stub.Set("/latest/meta-data/placement/availability-zone", "us-east-1a")
+	stub.SetAWSIdentityDocument(map[string]interface{}{
+		"accountId":        "123456789012",
+		"instanceId":       "i-0123456789abcdef0",
+		"region":           "us-east-1",
+		"availabilityZone": "us-east-1a",
+		"instanceType":     "t3.micro",
+		"imageId":          "ami-0123456789abcdef0",
+		"privateIp":        "10.0.0.1",
+	})
+	stub.server = &http.Server{Handler: http.HandlerFunc(stub.serveHTTP)}
+	go stub.server.Serve(listener)
+	return stub, nil
+}
+
+// Close stops the stub.
+func (stub *IMDSStub) Close() error {
+	return stub.server.Close()
+}
+
+// Set sets the value served at path, for example
+// "/latest/meta-data/local-ipv4".
+func (stub *IMDSStub) Set(path, value string) {
+	stub.lock.Lock()
+	defer stub.lock.Unlock()
+	stub.values[path] = value
+}
+
+// Delete removes the value at path, which is then not found.
+func (stub *IMDSStub) Delete(path string) {
+	stub.lock.Lock()
+	defer stub.lock.Unlock()
+	delete(stub.values, path)
+}
+
+func (stub *IMDSStub) setJSON(path string, value interface{}) error {
+	bites, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	stub.Set(path, string(bites))
+	return nil
+}
+
+// SetAWSCredentials sets the credentials of the IAM role called role,
+// which becomes the instance's only role.
+func (stub *IMDSStub) SetAWSCredentials(role string, credentials AWSCredentials) error {
+	stub.lock.Lock()
+	for path := range stub.values {
+		if strings.HasPrefix(path, "/latest/meta-data/iam/security-credentials/") {
+			delete(stub.values, path)
+		}
+	}
+	stub.lock.Unlock()
+	return stub.setJSON("/latest/meta-data/iam/security-credentials/"+role, map[string]string{
+		"Code":            "Success",
+		"LastUpdated":     time.Now().UTC().Format(time.RFC3339),
+		"Type":            "AWS-HMAC",
+		"AccessKeyId":     credentials.AccessKeyID,
+		"SecretAccessKey": credentials.SecretAccessKey,
+		"Token":           credentials.Token,
+		"Expiration":      credentials.Expiration.UTC().Format(time.RFC3339),
+	})
+}
+
+// SetAWSIdentityDocument sets the instance identity document, served
+// as JSON at /latest/dynamic/instance-identity/document.
+func (stub *IMDSStub) SetAWSIdentityDocument(document map[string]interface{}) error {
+	return stub.setJSON("/latest/dynamic/instance-identity/document", document)
+}
+
+// SetGCPAccessToken sets the access token of the default service
+// account, served by Google Cloud's metadata server.
+func (stub *IMDSStub) SetGCPAccessToken(token string, expiresIn time.Duration) error {
+	return stub.setJSON("/computeMetadata/v1/instance/service-accounts/default/token", map[string]interface{}{
+		"access_token": token,
+		"expires_in":   int64(expiresIn / time.Second),
+		"token_type":   "Bearer",
+	})
+}
+
+// Requested returns the paths requested of the stub, in order.
+func (stub *IMDSStub) Requested() []string {
+	stub.lock.Lock()
+	defer stub.lock.Unlock()
+	return append([]string{}, stub.requested...)
+}
+
+func (stub *IMDSStub) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	stub.lock.Lock()
+	defer stub.lock.Unlock()
+	path := r.URL.Path
+	stub.requested = append(stub.requested, path)
+	if stub.status != 0 {
+		w.WriteHeader(stub.status)
+		return
+	}
+
+	switch {
+	case path == "/latest/api/token":
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		ttl, err := strconv.Atoi(r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+		if err != nil || ttl < 1 || ttl > 21600 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		bites := make([]byte, 16)
+		rand.Read(bites)
+		token := hex.EncodeToString(bites)
+		stub.tokens[token] = time.Now().Add(time.Duration(ttl) * time.Second)
+		w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", strconv.Itoa(ttl))
+		w.Write([]byte(token))
+		return
+	case strings.HasPrefix(path, "/latest/"):
+		if token := r.Header.Get("X-aws-ec2-metadata-token"); token != "" {
+			if expiry, found := stub.tokens[token]; !found || time.Now().After(expiry) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		} else if stub.requireToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	case strings.HasPrefix(path, "/computeMetadata/"):
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Metadata-Flavor", "Google")
+	case strings.HasPrefix(path, "/metadata/"):
+		if r.Header.Get("Metadata") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	value, found := stub.values[path]
+	if !found && strings.HasSuffix(path, "/") {
+		children := []string{}
+		seen := make(map[string]bool)
+		for key := range stub.values {
+			if !strings.HasPrefix(key, path) {
+				continue
+			}
+			child := key[len(path):]
+			if idx := strings.IndexByte(child, '/'); idx >= 0 {
+				child = child[:idx+1]
+			}
+			if !seen[child] {
+				seen[child] = true
+				children = append(children, child)
+			}
+		}
+		sort.Strings(children)
+		value, found = strings.Join(children, "\n"), len(children) > 0
+	}
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+	} else if strings.HasPrefix(value, "{") {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(value))
+	} else {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(value))
+	}
+}
+
+// SetValue is a Step that when executed sets the value served at path
+// (see Set).
+func (stub *IMDSStub) SetValue(path, value string) Step {
+	return NewNamedStep(fmt.Sprintf("SetIMDSValue(%s)", path), func() error {
+		stub.Set(path, value)
+		return nil
+	})
+}
+
+// SetCredentials is a Step that when executed sets the credentials of
+// the IAM role called role (see SetAWSCredentials), for example to
+// check that the system under test picks up rotated credentials.
+func (stub *IMDSStub) SetCredentials(role string, credentials AWSCredentials) Step {
+	return NewNamedStep(fmt.Sprintf("SetIMDSCredentials(%s)", role), func() error {
+		return stub.SetAWSCredentials(role, credentials)
+	})
+}
+
+// RequireToken is a Step that when executed sets whether requests
+// beneath /latest/ must carry a session token, as when an instance is
+// configured for IMDSv2 only. By default they need not.
+func (stub *IMDSStub) RequireToken(require bool) Step {
+	return NewNamedStep(fmt.Sprintf("RequireIMDSToken(%v)", require), func() error {
+		stub.lock.Lock()
+		defer stub.lock.Unlock()
+		stub.requireToken = require
+		return nil
+	})
+}
+
+// RespondWith is a Step that when executed makes the stub respond to
+// every subsequent request with status, to check how the system under
+// test copes with the metadata service being unavailable. A status of
+// 0 restores normal service.
+func (stub *IMDSStub) RespondWith(status int) Step {
+	return NewNamedStep(fmt.Sprintf("RespondWith(%d)", status), func() error {
+		stub.lock.Lock()
+		defer stub.lock.Unlock()
+		stub.status = status
+		return nil
+	})
+}
+
+// ExpectRequested is a Step that when executed errors unless path has
+// been requested of the stub.
+func (stub *IMDSStub) ExpectRequested(path string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectIMDSRequested(%s)", path), func() error {
+		requested := stub.Requested()
+		for _, found := range requested {
+			if found == path {
+				return nil
+			}
+		}
+		return fmt.Errorf("IMDS: Expected %s to be requested; found %d other requests.", path, len(requested))
+	})
+}
@@ -0,0 +1,87 @@
+package argot
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIMDSStub(t *testing.T) {
+	stub, err := NewIMDSStub("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stub.Close()
+
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	token := NewStore()
+	if err := (Steps{
+		stub.RequireToken(true),
+		stub.SetCredentials("app-role", AWSCredentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret", Token: "session", Expiration: expiry}),
+
+		hc.NewRequest("GET", stub.URL+"/latest/meta-data/instance-id", nil),
+		hc.ResponseStatusEquals(http.StatusUnauthorized),
+
+		hc.NewRequest("PUT", stub.URL+"/latest/api/token", nil),
+		hc.RequestHeader("X-aws-ec2-metadata-token-ttl-seconds", "60"),
+		hc.ResponseStatusEquals(http.StatusOK),
+		NewNamedStep("save token", func() error {
+			err := hc.ReceiveBody()
+			token.Set("token", string(hc.ResponseBody))
+			return err
+		}),
+	}).Go(); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string, header ...string) Steps {
+		steps := Steps{hc.NewRequest("GET", stub.URL+path, nil)}
+		if len(header) == 0 {
+			steps = append(steps, hc.RequestHeader("X-aws-ec2-metadata-token", token.GetString("token")))
+		} else {
+			steps = append(steps, hc.RequestHeader(header[0], header[1]))
+		}
+		return steps
+	}
+	steps := Steps{}
+	steps = append(steps, get("/latest/meta-data/iam/security-credentials/")...)
+	steps = append(steps, hc.ResponseBodyEquals("app-role"))
+	steps = append(steps, get("/latest/meta-data/iam/security-credentials/app-role")...)
+	steps = append(steps,
+		hc.ResponseBodyJSONPathEquals("$.AccessKeyId", "AKIAEXAMPLE"),
+		hc.ResponseBodyJSONPathEquals("$.Token", "session"),
+		hc.ResponseBodyJSONPathEquals("$.Expiration", "2030-01-01T00:00:00Z"),
+	)
+	steps = append(steps, get("/latest/meta-data/placement/")...)
+	steps = append(steps, hc.ResponseBodyEquals("availability-zone\nregion"))
+	steps = append(steps, get("/latest/dynamic/instance-identity/document")...)
+	steps = append(steps, hc.ResponseBodyContains(`"region": "us-east-1"`))
+	steps = append(steps, get("/latest/meta-data/missing")...)
+	steps = append(steps, hc.ResponseStatusEquals(http.StatusNotFound))
+	steps = append(steps, stub.ExpectRequested("/latest/api/token"))
+	if err := steps.Go(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stub.SetGCPAccessToken("ya29.token", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	steps = Steps{hc.NewRequest("GET", stub.URL+"/computeMetadata/v1/instance/service-accounts/default/token", nil), hc.ResponseStatusEquals(http.StatusForbidden)}
+	steps = append(steps, get("/computeMetadata/v1/instance/service-accounts/default/token", "Metadata-Flavor", "Google")...)
+	steps = append(steps, hc.ResponseBodyJSONMatchesStruct(map[string]interface{}{"access_token": "ya29.token", "expires_in": 3600.0, "token_type": "Bearer"}))
+	steps = append(steps, stub.SetValue("/metadata/instance", `{"compute": {"location": "westeurope"}}`))
+	steps = append(steps, get("/metadata/instance", "Metadata", "true")...)
+	steps = append(steps, hc.ResponseBodyContains("westeurope"))
+	steps = append(steps, stub.RespondWith(http.StatusServiceUnavailable))
+	steps = append(steps, get("/latest/meta-data/instance-id")...)
+	steps = append(steps, hc.ResponseStatusEquals(http.StatusServiceUnavailable))
+	if err := steps.Go(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stub.ExpectRequested("/latest/user-data").Go(); err == nil || !strings.HasPrefix(err.Error(), "IMDS: Expected /latest/user-data to be requested; found ") {
+		t.Fatalf("Expected the unrequested path to fail; found %v.", err)
+	}
+}
@@ -0,0 +1,42 @@
+package argot
+
+import "fmt"
+
+// ResponseInvariants adds steps which are run, in order, on every
+// response hc receives, as soon as EnsureResponse has received it, so
+// that cross-cutting assertions (such as every response having an
+// X-Request-Id header, or no response body containing "panic") need
+// not be repeated in every scenario. They are typically steps of hc
+// itself, for example:
+//
+//	hc.ResponseInvariants(
+//		hc.ResponseHeaderExists("X-Request-Id"),
+//		argot.ExpectError(hc.ResponseBodyContains("panic")),
+//	)
+//
+// If an invariant errors, so does EnsureResponse, and thus whichever
+// step caused the response to be received. Invariants persist across
+// Reset, but are not copied by Clone, as they refer to hc.
+func (hc *HttpCall) ResponseInvariants(steps ...Step) {
+	hc.invariants = append(hc.invariants, steps...)
+}
+
+// checkInvariants runs the ResponseInvariants on hc.Response. Should
+// an invariant make a request of its own, the invariants are not run
+// on its response.
+func (hc *HttpCall) checkInvariants() error {
+	if hc.checking {
+		return nil
+	}
+	hc.checking = true
+	defer func() { hc.checking = false }()
+	for _, step := range hc.invariants {
+		if err := step.Go(); err != nil {
+			if hcErr, ok := err.(*HttpCallError); ok {
+				err = hcErr.Err
+			}
+			return fmt.Errorf("Invariant %v: %v", step, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseInvariants(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/untraced" {
+			w.Header().Set("X-Request-Id", "42")
+		}
+		if r.URL.Path == "/crash" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("panic: runtime error"))
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	hc.ResponseInvariants(
+		hc.ResponseHeaderExists("X-Request-Id"),
+		ExpectError(hc.ResponseBodyContains("panic")),
+	)
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseStatusEquals(http.StatusOK),
+		hc.NewRequest("GET", server.URL+"/again", nil),
+		hc.Call(),
+	}.Test(t)
+
+	for path, expected := range map[string]string{
+		"/untraced": "Invariant ResponseHeaderExists(X-Request-Id): ",
+		"/crash":    "Invariant ExpectError(ResponseBodyContains): ",
+	} {
+		if _, err := (Steps{hc.NewRequest("GET", server.URL+path, nil), hc.ResponseStatusEquals(http.StatusOK)}).Test(nil); err == nil {
+			t.Fatalf("%s: Expected an invariant to fail.", path)
+		} else if hcErr, ok := err.(*HttpCallError); !ok || !strings.HasPrefix(hcErr.Err.Error(), expected) {
+			t.Fatalf("%s: Expected %q; found %v.", path, expected, err)
+		}
+	}
+}
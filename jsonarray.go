@@ -0,0 +1,284 @@
+package argot
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// JSONMatcher is a function which errors unless a decoded JSON value
+// (as produced by encoding/json decoding into an interface{}, with
+// numbers as json.Number) is acceptable. It is used by
+// ResponseBodyJSONArrayEach.
+type JSONMatcher func(value interface{}) error
+
+// JSONArrayOptions modify how ResponseBodyJSONArrayMatches compares
+// arrays.
+type JSONArrayOptions struct {
+	// If true, the expected elements may be found in any order.
+	AnyOrder bool
+	// If true, the array may contain elements besides those expected.
+	// Unless AnyOrder is also set, the expected elements must be found
+	// in order, but need not be adjacent.
+	Partial bool
+}
+
+// responseJSONArray ensures there is a non-nil hc.ResponseBody, parses
+// it as JSON and returns the array at path, its numbers canonicalised
+// as by exactJSON.
+func (hc *HttpCall) responseJSONArray(path string) ([]interface{}, error) {
+	if value, err := hc.responseJSONPath(path); err != nil {
+		return nil, err
+	} else if arr, ok := value.([]interface{}); !ok {
+		return nil, fmt.Errorf("JSON path '%s': Expected an array; found %T.", path, value)
+	} else if canonical, err := exactJSON(arr); err != nil {
+		return nil, err
+	} else {
+		return canonical.([]interface{}), nil
+	}
+}
+
+// jsonElementMatches returns whether found matches want, both decoded
+// JSON values: objects match if found has every member of want, with
+// a matching value, and arrays if they are of the same length and
+// their elements match in order. Other values must be equal.
+func jsonElementMatches(found, want interface{}) bool {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		f, ok := found.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, wantValue := range w {
+			if foundValue, present := f[key]; !present || !jsonElementMatches(foundValue, wantValue) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		f, ok := found.([]interface{})
+		if !ok || len(f) != len(w) {
+			return false
+		}
+		for idx := range w {
+			if !jsonElementMatches(f[idx], w[idx]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(found, want)
+	}
+}
+
+// jsonString returns the JSON encoding of value, for error messages.
+func jsonString(value interface{}) string {
+	if bites, err := json.Marshal(value); err != nil {
+		return fmt.Sprint(value)
+	} else {
+		return string(bites)
+	}
+}
+
+// ResponseBodyJSONArrayLen is a Step that when executed ensures there
+// is a non-nil hc.ResponseBody, parses it as JSON and errors unless
+// the value at path (see JSONPath) is an array of length n.
+func (hc *HttpCall) ResponseBodyJSONArrayLen(path string, n int) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyJSONArrayLen(%s, %d)", path, n), func() error {
+		if arr, err := hc.responseJSONArray(path); err != nil {
+			return err
+		} else if len(arr) != n {
+			return fmt.Errorf("JSON path '%s': Expected an array of length %d; found %d.", path, n, len(arr))
+		} else {
+			return nil
+		}
+	})
+}
+
+// ResponseBodyJSONArrayContains is a Step that when executed ensures
+// there is a non-nil hc.ResponseBody, parses it as JSON and errors
+// unless the value at path (see JSONPath) is an array with an element
+// which matches the JSON encoding of element. Objects match if they
+// have at least the members of element, so an element can be found by
+// its ID alone; numbers match if they are numerically equal.
+func (hc *HttpCall) ResponseBodyJSONArrayContains(path string, element interface{}) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyJSONArrayContains(%s)", path), func() error {
+		arr, err := hc.responseJSONArray(path)
+		if err != nil {
+			return err
+		}
+		want, err := exactJSON(element)
+		if err != nil {
+			return err
+		}
+		for _, found := range arr {
+			if jsonElementMatches(found, want) {
+				return nil
+			}
+		}
+		return &StepError{
+			Message:     fmt.Sprintf("JSON path '%s': Expected an element matching %s; found none of %d.", path, jsonString(want), len(arr)),
+			Differences: []Difference{{Path: path, Kind: DifferenceMissing, Expected: want}},
+		}
+	})
+}
+
+// ResponseBodyJSONArrayEach is a Step that when executed ensures there
+// is a non-nil hc.ResponseBody, parses it as JSON and errors unless
+// the value at path (see JSONPath) is an array every element of which
+// matches matcher. The matcher is either a JSON schema, as a string, or
+// a JSONMatcher. The error gives the index of the first element which
+// does not match.
+func (hc *HttpCall) ResponseBodyJSONArrayEach(path string, matcher interface{}) Step {
+	var match JSONMatcher
+	switch m := matcher.(type) {
+	case string:
+		match = func(value interface{}) error {
+			return validateJSONSchema(gojsonschema.NewStringLoader(m), gojsonschema.NewGoLoader(value))
+		}
+	case JSONMatcher:
+		match = m
+	case func(interface{}) error:
+		match = m
+	default:
+		panic(fmt.Sprintf("argot: ResponseBodyJSONArrayEach: matcher must be a JSON schema or a JSONMatcher; found %T", matcher))
+	}
+	return hc.step(fmt.Sprintf("ResponseBodyJSONArrayEach(%s)", path), func() error {
+		if arr, err := hc.responseJSONArray(path); err != nil {
+			return err
+		} else {
+			for idx, elem := range arr {
+				if err := match(elem); err != nil {
+					return fmt.Errorf("JSON path '%s': Element %d: %v", path, idx, err)
+				}
+			}
+			return nil
+		}
+	})
+}
+
+// ResponseBodyJSONArrayMatches is a Step that when executed ensures
+// there is a non-nil hc.ResponseBody, parses it as JSON and errors
+// unless the value at path (see JSONPath) is an array whose elements
+// match those of the JSON encoding of expected, which must encode to
+// an array. Elements match as for ResponseBodyJSONArrayContains. By
+// default the array must have the same number of elements, in the same
+// order: opts relaxes this. The error carries the elements which do
+// not match as Differences (see StepError).
+func (hc *HttpCall) ResponseBodyJSONArrayMatches(path string, expected interface{}, opts JSONArrayOptions) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyJSONArrayMatches(%s)", path), func() error {
+		arr, err := hc.responseJSONArray(path)
+		if err != nil {
+			return err
+		}
+		doc, err := exactJSON(expected)
+		if err != nil {
+			return err
+		}
+		want, ok := doc.([]interface{})
+		if !ok {
+			return fmt.Errorf("JSON path '%s': Expected value must be an array; found %T.", path, expected)
+		}
+		var diffs []Difference
+		if opts.AnyOrder {
+			diffs = matchJSONArrayAnyOrder(path, arr, want, opts.Partial)
+		} else {
+			diffs = matchJSONArrayInOrder(path, arr, want, opts.Partial)
+		}
+		if len(diffs) == 0 {
+			return nil
+		}
+		msgs := make([]string, len(diffs))
+		for idx, diff := range diffs {
+			switch diff.Kind {
+			case DifferenceMissing:
+				msgs[idx] = fmt.Sprintf("%s: Expected %s; not found.", diff.Path, jsonString(diff.Expected))
+			case DifferenceUnexpected:
+				msgs[idx] = fmt.Sprintf("%s: Unexpected %s.", diff.Path, jsonString(diff.Actual))
+			default:
+				msgs[idx] = fmt.Sprintf("%s: Expected %s; found %s.", diff.Path, jsonString(diff.Expected), jsonString(diff.Actual))
+			}
+		}
+		return &StepError{
+			Message:     fmt.Sprintf("JSON path '%s': Array did not match:\n\t%s", path, strings.Join(msgs, "\n\t")),
+			Differences: diffs,
+		}
+	})
+}
+
+// matchJSONArrayInOrder returns the Differences between arr and want,
+// whose elements must match in order. If partial, want need only be a
+// subsequence of arr, matched greedily.
+func matchJSONArrayInOrder(path string, arr, want []interface{}, partial bool) []Difference {
+	diffs := []Difference{}
+	if partial {
+		idx := 0
+		for wantIdx, elem := range want {
+			for idx < len(arr) && !jsonElementMatches(arr[idx], elem) {
+				idx++
+			}
+			if idx == len(arr) {
+				for _, missing := range want[wantIdx:] {
+					diffs = append(diffs, Difference{Path: path, Kind: DifferenceMissing, Expected: missing})
+				}
+				break
+			}
+			idx++
+		}
+		return diffs
+	}
+	for idx := 0; idx < len(arr) || idx < len(want); idx++ {
+		elemPath := fmt.Sprintf("%s[%d]", path, idx)
+		if idx >= len(arr) {
+			diffs = append(diffs, Difference{Path: elemPath, Kind: DifferenceMissing, Expected: want[idx]})
+		} else if idx >= len(want) {
+			diffs = append(diffs, Difference{Path: elemPath, Kind: DifferenceUnexpected, Actual: arr[idx]})
+		} else if !jsonElementMatches(arr[idx], want[idx]) {
+			diffs = append(diffs, Difference{Path: elemPath, Kind: DifferenceChanged, Expected: want[idx], Actual: arr[idx]})
+		}
+	}
+	return diffs
+}
+
+// matchJSONArrayAnyOrder returns the Differences between arr and want,
+// whose elements may match in any order. As an object may match
+// several expected elements, elements are assigned by maximum
+// bipartite matching, so that an assignment is found if one exists.
+// Unless partial, elements of arr which are not assigned are
+// unexpected.
+func matchJSONArrayAnyOrder(path string, arr, want []interface{}, partial bool) []Difference {
+	assigned := make([]int, len(arr)) // the index within want of the element of arr
+	for idx := range assigned {
+		assigned[idx] = -1
+	}
+	var assign func(wantIdx int, seen []bool) bool
+	assign = func(wantIdx int, seen []bool) bool {
+		for idx := range arr {
+			if !seen[idx] && jsonElementMatches(arr[idx], want[wantIdx]) {
+				seen[idx] = true
+				if assigned[idx] < 0 || assign(assigned[idx], seen) {
+					assigned[idx] = wantIdx
+					return true
+				}
+			}
+		}
+		return false
+	}
+	diffs := []Difference{}
+	for wantIdx, elem := range want {
+		if !assign(wantIdx, make([]bool, len(arr))) {
+			diffs = append(diffs, Difference{Path: path, Kind: DifferenceMissing, Expected: elem})
+		}
+	}
+	if !partial {
+		for idx, wantIdx := range assigned {
+			if wantIdx < 0 {
+				diffs = append(diffs, Difference{Path: fmt.Sprintf("%s[%d]", path, idx), Kind: DifferenceUnexpected, Actual: arr[idx]})
+			}
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,59 @@
+package argot
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseBodyJSONArray(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"users": [{"id": 1, "name": "ann", "role": "admin"}, {"id": 2, "name": "bob", "role": "ops"}, {"id": 3.0, "name": "cat", "role": "ops"}], "total": 3}`))
+	}))
+	defer server.Close()
+
+	type user struct {
+		ID   int    `json:"id"`
+		Role string `json:"role,omitempty"`
+	}
+	schema := `{"type": "object", "required": ["id", "name"], "properties": {"id": {"type": "integer"}}}`
+	hasName := JSONMatcher(func(value interface{}) error {
+		if name, _ := value.(map[string]interface{})["name"].(string); name == "" {
+			return errors.New("No name.")
+		}
+		return nil
+	})
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseBodyJSONArrayLen("users", 3),
+		ExpectError(hc.ResponseBodyJSONArrayLen("users", 2)),
+		ExpectError(hc.ResponseBodyJSONArrayLen("total", 3)),
+		hc.ResponseBodyJSONArrayContains("users", user{ID: 3}),
+		hc.ResponseBodyJSONArrayContains("$.users", map[string]interface{}{"name": "bob", "role": "ops"}),
+		ExpectError(hc.ResponseBodyJSONArrayContains("users", user{ID: 2, Role: "admin"})),
+		hc.ResponseBodyJSONArrayEach("users", schema),
+		hc.ResponseBodyJSONArrayEach("users", hasName),
+		ExpectError(hc.ResponseBodyJSONArrayEach("users", `{"properties": {"role": {"enum": ["admin"]}}}`)),
+		hc.ResponseBodyJSONArrayMatches("users", []user{{ID: 1}, {ID: 2}, {ID: 3}}, JSONArrayOptions{}),
+		ExpectError(hc.ResponseBodyJSONArrayMatches("users", []user{{ID: 3}, {ID: 1}, {ID: 2}}, JSONArrayOptions{})),
+		hc.ResponseBodyJSONArrayMatches("users", []user{{ID: 3}, {ID: 1}, {ID: 2}}, JSONArrayOptions{AnyOrder: true}),
+		ExpectError(hc.ResponseBodyJSONArrayMatches("users", []user{{ID: 3}, {ID: 1}}, JSONArrayOptions{AnyOrder: true})),
+		hc.ResponseBodyJSONArrayMatches("users", []user{{ID: 1}, {ID: 3}}, JSONArrayOptions{Partial: true}),
+		ExpectError(hc.ResponseBodyJSONArrayMatches("users", []user{{ID: 3}, {ID: 1}}, JSONArrayOptions{Partial: true})),
+		hc.ResponseBodyJSONArrayMatches("users", []interface{}{map[string]string{"role": "ops"}, user{ID: 2}}, JSONArrayOptions{AnyOrder: true, Partial: true}),
+	}.Test(t)
+
+	err := hc.ResponseBodyJSONArrayEach("users", `{"properties": {"role": {"enum": ["admin"]}}}`).Go()
+	if err == nil || !strings.Contains(err.Error(), "Element 1:") {
+		t.Fatalf("Expected the first failing element to be identified; found %v", err)
+	}
+	err = hc.ResponseBodyJSONArrayMatches("users", []user{{ID: 1}, {ID: 4}}, JSONArrayOptions{}).Go()
+	diffs := Differences(err)
+	if len(diffs) != 2 || diffs[0].Path != "users[1]" || diffs[0].Kind != DifferenceChanged || diffs[1].Path != "users[2]" || diffs[1].Kind != DifferenceUnexpected {
+		t.Fatalf("Expected the mismatched elements as Differences; found %+v (%v)", diffs, err)
+	}
+}
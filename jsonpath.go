@@ -0,0 +1,170 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPath extracts a value from a decoded JSON document (as produced
+// by encoding/json decoding into an interface{}). The path is a
+// sequence of object keys separated by dots, each optionally followed
+// by array indices in brackets, for example "items[0].id" or
+// "$.data.users[2]". A leading "$" and an empty path both denote the
+// whole document.
+func JSONPath(doc interface{}, path string) (interface{}, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	value := doc
+	if path == "" {
+		return value, nil
+	}
+	for _, segment := range strings.Split(path, ".") {
+		key, rest := segment, ""
+		if idx := strings.IndexByte(segment, '['); idx >= 0 {
+			key, rest = segment[:idx], segment[idx:]
+		}
+		if key != "" {
+			if obj, ok := value.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("JSON path '%s': Expected an object at '%s'; found %T.", path, key, value)
+			} else if value, ok = obj[key]; !ok {
+				return nil, fmt.Errorf("JSON path '%s': Key '%s' not found.", path, key)
+			}
+		}
+		for rest != "" {
+			end := strings.IndexByte(rest, ']')
+			if rest[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("JSON path '%s': Malformed index in '%s'.", path, segment)
+			}
+			idx, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("JSON path '%s': Malformed index in '%s'.", path, segment)
+			}
+			rest = rest[end+1:]
+			if arr, ok := value.([]interface{}); !ok {
+				return nil, fmt.Errorf("JSON path '%s': Expected an array in '%s'; found %T.", path, segment, value)
+			} else if idx < 0 || idx >= len(arr) {
+				return nil, fmt.Errorf("JSON path '%s': Index %d out of range (length %d).", path, idx, len(arr))
+			} else {
+				value = arr[idx]
+			}
+		}
+	}
+	return value, nil
+}
+
+// WalkJSON calls visit with the path and value of every value within
+// doc, a decoded JSON document, parents before their children and
+// object keys in sorted order. Paths are as accepted by JSONPath, for
+// example "items[0].id"; the path of doc itself is empty. If visit
+// errors, the walk stops and WalkJSON returns the error.
+func WalkJSON(doc interface{}, visit func(path string, value interface{}) error) error {
+	return walkJSONPaths("", doc, visit)
+}
+
+func walkJSONPaths(path string, value interface{}, visit func(path string, value interface{}) error) error {
+	if err := visit(path, value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			if err := walkJSONPaths(child, v[key], visit); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for idx, elem := range v {
+			if err := walkJSONPaths(fmt.Sprintf("%s[%d]", path, idx), elem, visit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var jsonIndexPattern = regexp.MustCompile(`\[\d+\]`)
+
+// JSONField returns path, as visited by WalkJSON, with its array
+// indices elided, for example "items[].id", so that it identifies the
+// same field of every element of an array.
+func JSONField(path string) string {
+	return jsonIndexPattern.ReplaceAllString(path, "[]")
+}
+
+// normaliseJSON round-trips value through encoding/json so that
+// values of different Go types which encode identically (for example
+// int and float64, or a struct and a map) compare as equal.
+func normaliseJSON(value interface{}) (interface{}, error) {
+	var normalised interface{}
+	if bites, err := json.Marshal(value); err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(bites, &normalised); err != nil {
+		return nil, err
+	} else {
+		return normalised, nil
+	}
+}
+
+// responseJSONPath ensures there is a non-nil hc.ResponseBody, parses
+// it as JSON and returns the value at path. Numbers are returned as
+// json.Number so that they are captured exactly.
+func (hc *HttpCall) responseJSONPath(path string) (interface{}, error) {
+	var doc interface{}
+	hc.coverJSONPath(path)
+	if err := hc.ReceiveBody(); err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(hc.ResponseBody))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	} else {
+		return JSONPath(doc, path)
+	}
+}
+
+// ResponseBodyJSONPathEquals is a Step that when executed ensures
+// there is a non-nil hc.ResponseBody, parses it as JSON and errors
+// unless the value at path (see JSONPath) equals expected. Values are
+// compared by their JSON encoding, so numeric types need not match
+// exactly, but integers are compared exactly, however large, as if
+// hc.JSONExactNumbers were set.
+func (hc *HttpCall) ResponseBodyJSONPathEquals(path string, expected interface{}) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyJSONPathEquals(%s)", path), func() error {
+		if value, err := hc.responseJSONPath(path); err != nil {
+			return err
+		} else if found, err := exactJSON(value); err != nil {
+			return err
+		} else if want, err := exactJSON(expected); err != nil {
+			return err
+		} else if !reflect.DeepEqual(found, want) {
+			return fmt.Errorf("JSON path '%s': Expected %v; found %v.", path, want, found)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ResponseBodyJSONPathExists is a Step that when executed ensures
+// there is a non-nil hc.ResponseBody, parses it as JSON and errors
+// unless there is a value at path (see JSONPath).
+func (hc *HttpCall) ResponseBodyJSONPathExists(path string) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyJSONPathExists(%s)", path), func() error {
+		_, err := hc.responseJSONPath(path)
+		return err
+	})
+}
@@ -0,0 +1,27 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseBodyJSONPathEquals(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 9007199254740993, "price": 1.50, "count": 2.0, "tags": ["a"]}`))
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseBodyJSONPathEquals("id", int64(9007199254740993)),
+		// Equal to the id as float64s.
+		ExpectError(hc.ResponseBodyJSONPathEquals("id", int64(9007199254740992))),
+		hc.ResponseBodyJSONPathEquals("price", 1.5),
+		hc.ResponseBodyJSONPathEquals("count", 2),
+		hc.ResponseBodyJSONPathEquals("tags", []string{"a"}),
+		ExpectError(hc.ResponseBodyJSONPathEquals("count", 3)),
+	}.Test(t)
+}
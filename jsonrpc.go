@@ -0,0 +1,283 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// JSONRPCRequest is a single JSON-RPC 2.0 request, for use with
+// JSONRPCCall.Batch. Notifications are requests which carry no id and
+// to which the server sends no response.
+type JSONRPCRequest struct {
+	Method       string
+	Params       interface{}
+	Notification bool
+}
+
+// JSONRPCError is the error object of a JSON-RPC 2.0 response.
+type JSONRPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *JSONRPCError) Error() string {
+	if len(e.Data) == 0 {
+		return fmt.Sprintf("JSON-RPC error %d: %s", e.Code, e.Message)
+	} else {
+		return fmt.Sprintf("JSON-RPC error %d: %s (%s)", e.Code, e.Message, string(e.Data))
+	}
+}
+
+// JSONRPCResponse is a single JSON-RPC 2.0 response.
+type JSONRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type jsonRPCEnvelope struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+	ID      *int64      `json:"id,omitempty"`
+}
+
+// JSONRPCCall captures the state of JSON-RPC 2.0 calls made over
+// HTTP. Each request (or batch of requests) is POSTed to URL using
+// HttpCall, so all the HttpCall steps remain available for inspecting
+// the HTTP exchange itself. Request ids are allocated automatically
+// and are unique for the life of the JSONRPCCall. A JSONRPCCall can
+// only be used by a single go-routine at a time.
+type JSONRPCCall struct {
+	// The underlying HTTP call.
+	HttpCall *HttpCall
+	// The URL of the JSON-RPC endpoint.
+	URL string
+
+	nextID    int64
+	ids       []*int64
+	batch     bool
+	responses []*JSONRPCResponse
+}
+
+// NewJSONRPCCall creates a new JSONRPCCall which POSTs to url. If
+// client is nil, a new http.Client is used.
+func NewJSONRPCCall(client *http.Client, url string) *JSONRPCCall {
+	return &JSONRPCCall{
+		HttpCall: NewHttpCall(client),
+		URL:      url,
+	}
+}
+
+// Reset is idempotent. You should ensure this is called at the end of
+// life for each JSONRPCCall.
+func (rpc *JSONRPCCall) Reset() error {
+	rpc.ids = nil
+	rpc.batch = false
+	rpc.responses = nil
+	return rpc.HttpCall.Reset()
+}
+
+func (rpc *JSONRPCCall) envelope(req *JSONRPCRequest) jsonRPCEnvelope {
+	env := jsonRPCEnvelope{JSONRPC: "2.0", Method: req.Method, Params: req.Params}
+	if !req.Notification {
+		rpc.nextID++
+		id := rpc.nextID
+		env.ID = &id
+	}
+	rpc.ids = append(rpc.ids, env.ID)
+	return env
+}
+
+func (rpc *JSONRPCCall) newRequest(name string, batch bool, reqs []*JSONRPCRequest) Step {
+	hc := rpc.HttpCall
+	return hc.step(name, func() error {
+		if err := rpc.Reset(); err != nil {
+			return err
+		}
+		envs := make([]jsonRPCEnvelope, len(reqs))
+		for idx, req := range reqs {
+			envs[idx] = rpc.envelope(req)
+		}
+		var payload interface{} = envs
+		if !batch {
+			payload = envs[0]
+		}
+		if body, err := json.Marshal(payload); err != nil {
+			return err
+		} else if req, err := http.NewRequest("POST", rpc.URL, bytes.NewReader(body)); err != nil {
+			return err
+		} else {
+			req.Header.Set("Content-Type", "application/json")
+			hc.Request = req
+			hc.requestName = name
+			rpc.batch = batch
+			return nil
+		}
+	})
+}
+
+// Request is a Step that when executed creates a new JSON-RPC request
+// calling method with params, allocating it a fresh id. params may be
+// nil, a slice (positional parameters) or a map or struct (named
+// parameters).
+func (rpc *JSONRPCCall) Request(method string, params interface{}) Step {
+	return rpc.newRequest(fmt.Sprintf("JSONRPCRequest(%s)", method), false,
+		[]*JSONRPCRequest{{Method: method, Params: params}})
+}
+
+// Notification is a Step that when executed creates a new JSON-RPC
+// notification (a request without an id) calling method with params.
+func (rpc *JSONRPCCall) Notification(method string, params interface{}) Step {
+	return rpc.newRequest(fmt.Sprintf("JSONRPCNotification(%s)", method), false,
+		[]*JSONRPCRequest{{Method: method, Params: params, Notification: true}})
+}
+
+// Batch is a Step that when executed creates a new JSON-RPC batch
+// request containing reqs. Responses are matched to requests by id,
+// so assertions refer to requests by their index within reqs,
+// regardless of the order in which the server responds.
+func (rpc *JSONRPCCall) Batch(reqs ...*JSONRPCRequest) Step {
+	return rpc.newRequest(fmt.Sprintf("JSONRPCBatch(%d)", len(reqs)), true, reqs)
+}
+
+// ensureResponses receives and parses the response body, and checks
+// every response is a well formed JSON-RPC 2.0 response to a request
+// that was made.
+func (rpc *JSONRPCCall) ensureResponses() error {
+	if rpc.responses != nil {
+		return nil
+	} else if err := rpc.HttpCall.ReceiveBody(); err != nil {
+		return err
+	}
+	body := bytes.TrimSpace(rpc.HttpCall.ResponseBody)
+	var responses []*JSONRPCResponse
+	if len(body) == 0 {
+		responses = []*JSONRPCResponse{}
+	} else if body[0] == '[' {
+		if err := json.Unmarshal(body, &responses); err != nil {
+			return fmt.Errorf("JSON-RPC: Unable to parse batch response: %v", err)
+		}
+	} else {
+		response := new(JSONRPCResponse)
+		if err := json.Unmarshal(body, response); err != nil {
+			return fmt.Errorf("JSON-RPC: Unable to parse response: %v", err)
+		}
+		responses = []*JSONRPCResponse{response}
+	}
+	for _, response := range responses {
+		if response.JSONRPC != "2.0" {
+			return fmt.Errorf("JSON-RPC: Expected jsonrpc version \"2.0\"; found %q.", response.JSONRPC)
+		} else if response.Error == nil && response.Result == nil {
+			return fmt.Errorf("JSON-RPC: Response with id %s has neither result nor error.", string(response.ID))
+		} else if response.Error != nil && response.Result != nil {
+			return fmt.Errorf("JSON-RPC: Response with id %s has both result and error.", string(response.ID))
+		}
+	}
+	rpc.responses = responses
+	return nil
+}
+
+// Response returns the response to the request at index idx (always
+// 0 unless a Batch was sent). It errors if the request was a
+// notification or if the server sent no response to it. A response
+// with a null id (which servers send when they could not parse the
+// request) is returned for idx 0 of a non-batch request.
+func (rpc *JSONRPCCall) Response(idx int) (*JSONRPCResponse, error) {
+	if err := rpc.ensureResponses(); err != nil {
+		return nil, err
+	} else if idx < 0 || idx >= len(rpc.ids) {
+		return nil, fmt.Errorf("JSON-RPC: No request at index %d.", idx)
+	} else if rpc.ids[idx] == nil {
+		return nil, fmt.Errorf("JSON-RPC: Request at index %d is a notification.", idx)
+	}
+	expected := fmt.Sprint(*rpc.ids[idx])
+	for _, response := range rpc.responses {
+		if string(bytes.TrimSpace(response.ID)) == expected {
+			return response, nil
+		}
+	}
+	if !rpc.batch && len(rpc.responses) == 1 && string(rpc.responses[0].ID) == "null" {
+		return rpc.responses[0], nil
+	}
+	return nil, fmt.Errorf("JSON-RPC: No response found for id %s.", expected)
+}
+
+// ExpectResult is a Step that when executed errors unless the
+// response to a single request has a result which, parsed as JSON
+// based on the type of expected, is equal to expected as validated
+// by the pretty package.
+func (rpc *JSONRPCCall) ExpectResult(expected interface{}) Step {
+	return rpc.expectResult("ExpectResult", 0, expected)
+}
+
+// ExpectResultAt is a Step that when executed errors unless the
+// response to the request at index idx of a batch has a result which
+// is equal to expected. See ExpectResult.
+func (rpc *JSONRPCCall) ExpectResultAt(idx int, expected interface{}) Step {
+	return rpc.expectResult(fmt.Sprintf("ExpectResultAt(%d)", idx), idx, expected)
+}
+
+func (rpc *JSONRPCCall) expectResult(name string, idx int, expected interface{}) Step {
+	return rpc.HttpCall.step(name, func() error {
+		parseAs := reflect.New(reflect.TypeOf(expected)).Interface()
+		if response, err := rpc.Response(idx); err != nil {
+			return err
+		} else if response.Error != nil {
+			return fmt.Errorf("JSON-RPC: Expected a result; found %v.", response.Error)
+		} else if err := json.Unmarshal(response.Result, parseAs); err != nil {
+			return err
+		} else if err := compareError(parseAs, expected); err != nil {
+			return err
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectError is a Step that when executed errors unless the response
+// to a single request is an error with the given code.
+func (rpc *JSONRPCCall) ExpectError(code int) Step {
+	return rpc.expectError(fmt.Sprintf("ExpectError(%d)", code), 0, code)
+}
+
+// ExpectErrorAt is a Step that when executed errors unless the
+// response to the request at index idx of a batch is an error with
+// the given code.
+func (rpc *JSONRPCCall) ExpectErrorAt(idx, code int) Step {
+	return rpc.expectError(fmt.Sprintf("ExpectErrorAt(%d: %d)", idx, code), idx, code)
+}
+
+func (rpc *JSONRPCCall) expectError(name string, idx, code int) Step {
+	return rpc.HttpCall.step(name, func() error {
+		if response, err := rpc.Response(idx); err != nil {
+			return err
+		} else if response.Error == nil {
+			return fmt.Errorf("JSON-RPC: Expected error %d; found result %s.", code, string(response.Result))
+		} else if response.Error.Code != code {
+			return fmt.Errorf("JSON-RPC: Expected error %d; found %v.", code, response.Error)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectNoResponse is a Step that when executed errors unless the
+// server sent no JSON-RPC responses at all, as is required when every
+// request sent was a notification.
+func (rpc *JSONRPCCall) ExpectNoResponse() Step {
+	return rpc.HttpCall.step("ExpectNoResponse", func() error {
+		if err := rpc.ensureResponses(); err != nil {
+			return err
+		} else if len(rpc.responses) != 0 {
+			return fmt.Errorf("JSON-RPC: Expected no responses; found %d.", len(rpc.responses))
+		} else {
+			return nil
+		}
+	})
+}
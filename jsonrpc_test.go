@@ -0,0 +1,87 @@
+package argot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func jsonRPCServer() *httptest.Server {
+	type request struct {
+		Method string          `json:"method"`
+		Params []int           `json:"params"`
+		ID     json.RawMessage `json:"id"`
+	}
+	handle := func(req request) map[string]interface{} {
+		if req.ID == nil {
+			return nil
+		}
+		response := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if req.Method == "sum" {
+			total := 0
+			for _, p := range req.Params {
+				total += p
+			}
+			response["result"] = total
+		} else {
+			response["error"] = map[string]interface{}{"code": -32601, "message": "Method not found"}
+		}
+		return response
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var batch []request
+		if err := json.Unmarshal(body, &batch); err == nil {
+			responses := []interface{}{}
+			// Respond in reverse order to check matching by id.
+			for idx := len(batch) - 1; idx >= 0; idx-- {
+				if response := handle(batch[idx]); response != nil {
+					responses = append(responses, response)
+				}
+			}
+			if len(responses) == 0 {
+				w.WriteHeader(http.StatusNoContent)
+			} else {
+				json.NewEncoder(w).Encode(responses)
+			}
+			return
+		}
+		var single request
+		json.Unmarshal(body, &single)
+		if response := handle(single); response == nil {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			json.NewEncoder(w).Encode(response)
+		}
+	}))
+}
+
+func TestJSONRPCCall(t *testing.T) {
+	server := jsonRPCServer()
+	defer server.Close()
+	rpc := NewJSONRPCCall(nil, server.URL)
+	defer rpc.Reset()
+
+	Steps{
+		rpc.Request("sum", []int{1, 2, 3}),
+		rpc.HttpCall.ResponseStatusEquals(http.StatusOK),
+		rpc.ExpectResult(6),
+		rpc.Request("nope", nil),
+		rpc.ExpectError(-32601),
+		rpc.Notification("sum", []int{1}),
+		rpc.ExpectNoResponse(),
+		rpc.Batch(
+			&JSONRPCRequest{Method: "sum", Params: []int{1, 1}},
+			&JSONRPCRequest{Method: "log", Notification: true},
+			&JSONRPCRequest{Method: "missing"},
+			&JSONRPCRequest{Method: "sum", Params: []int{5}},
+		),
+		rpc.ExpectResultAt(0, 2),
+		rpc.ExpectErrorAt(2, -32601),
+		rpc.ExpectResultAt(3, 5),
+		ExpectError(rpc.ExpectResultAt(1, 0)),
+		ExpectError(rpc.ExpectResultAt(2, 0)),
+	}.Test(t)
+}
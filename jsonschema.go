@@ -0,0 +1,77 @@
+package argot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// jsonSchemaFormat adapts a function to gojsonschema.FormatChecker.
+type jsonSchemaFormat func(value string) bool
+
+// IsFormat returns true for any value which is not a string: as in
+// JSON Schema, formats constrain only strings.
+func (f jsonSchemaFormat) IsFormat(input interface{}) bool {
+	if str, ok := input.(string); ok {
+		return f(str)
+	} else {
+		return true
+	}
+}
+
+// RegisterJSONSchemaFormat registers isFormat as the validator of
+// strings whose schema has the given "format", such as "uuid-v7" or
+// "iso-duration", for every JSON schema assertion (such as
+// ResponseBodyJSONSchema). It replaces any validator already
+// registered for the format, including gojsonschema's own. It is not
+// safe to call concurrently with validation, so it is typically called
+// from an init function.
+func RegisterJSONSchemaFormat(name string, isFormat func(value string) bool) {
+	gojsonschema.FormatCheckers.Add(name, jsonSchemaFormat(isFormat))
+}
+
+// jsonSchemaInstancePath converts the context of a validation error,
+// such as "(root).items.0.id", into a JSON path such as
+// "$.items[0].id".
+func jsonSchemaInstancePath(context *gojsonschema.JsonContext) string {
+	if context == nil {
+		return "$"
+	}
+	path := "$"
+	for _, token := range strings.Split(context.String("\x00"), "\x00")[1:] {
+		if isDigits(token) {
+			path += "[" + token + "]"
+		} else {
+			path += "." + token
+		}
+	}
+	return path
+}
+
+func isDigits(str string) bool {
+	if str == "" {
+		return false
+	}
+	for _, r := range str {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonSchemaFailure formats the errors of a failed validation, one per
+// line, each prefixed by the path of the failing instance, so that
+// failures within large documents are easy to locate.
+func jsonSchemaFailure(prefix string, errs []gojsonschema.ResultError) string {
+	lines := make([]string, len(errs))
+	for idx, err := range errs {
+		lines[idx] = fmt.Sprintf("\t%s: %s", jsonSchemaInstancePath(err.Context()), err.Description())
+	}
+	noun := "errors"
+	if len(errs) == 1 {
+		noun = "error"
+	}
+	return fmt.Sprintf("%sValidation failure (%d %s):\n%s", prefix, len(errs), noun, strings.Join(lines, "\n"))
+}
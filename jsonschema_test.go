@@ -0,0 +1,77 @@
+package argot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRegisterJSONSchemaFormat(t *testing.T) {
+	RegisterJSONSchemaFormat("iso-duration", regexp.MustCompile(`^P(\d+D)?(T(\d+H)?(\d+M)?(\d+S)?)?$`).MatchString)
+	body := `{"items": [{"duration": "PT5M"}, {"duration": "5 minutes"}, {"duration": 7}], "name": 3}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	schema := `{
+  "type": "object",
+  "properties": {
+    "items": {"type": "array", "items": {"properties": {"duration": {"type": "string", "format": "iso-duration"}}}},
+    "name": {"type": "string"}
+  }
+}`
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	_, err := Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseBodyJSONSchema(schema),
+	}.Test(nil)
+	if err == nil {
+		t.Fatal("Expected the schema assertion to fail.")
+	}
+	msg := err.Error()
+	for _, expected := range []string{
+		"Validation failure (3 errors):",
+		"\t$.items[1].duration: Does not match format 'iso-duration'",
+		"\t$.items[2].duration: Invalid type. Expected: string, given: integer",
+		"\t$.name: Invalid type. Expected: string, given: integer",
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, msg)
+		}
+	}
+	if strings.Contains(msg, "items[0]") {
+		t.Errorf("Unexpected failure of a valid duration:\n%s", msg)
+	}
+}
+
+func TestRequestBodyConformsToJSONSchema(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	schema := `{"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}`
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("POST", server.URL, strings.NewReader(`{"name": "alice"}`)),
+		hc.RequestBodyConformsToJSONSchema(schema),
+		hc.ResponseBodyEquals(`{"name": "alice"}`),
+	}.Test(t)
+
+	_, err := Steps{
+		hc.NewRequest("POST", server.URL, strings.NewReader(`{"nom": "alice"}`)),
+		hc.RequestBodyConformsToJSONSchema(schema),
+	}.Test(nil)
+	if err == nil || !strings.Contains(err.Error(), "Request body: Validation failure (1 error):\n\t$: name is required") {
+		t.Fatalf("Unexpected error: %v", err)
+	} else if hc.Response != nil {
+		t.Fatal("Expected the request not to have been sent.")
+	}
+}
@@ -0,0 +1,124 @@
+package argot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
+// LastRun records the outcome of every scenario, keyed by name, as of
+// the last time it was run, so that a run can be restricted to the
+// scenarios which failed last time, for a fast iterate-on-failure
+// loop. It is persisted in the format of WriteJSONReport, and so can
+// also be loaded from such a report. A LastRun is safe for concurrent
+// use.
+type LastRun struct {
+	lock      sync.Mutex
+	scenarios map[string]jsonScenarioReport
+}
+
+// NewLastRun creates a new, empty, LastRun.
+func NewLastRun() *LastRun {
+	return &LastRun{scenarios: make(map[string]jsonScenarioReport)}
+}
+
+// LoadLastRun loads the LastRun saved to path by Save (or written by
+// WriteJSONReport). If path does not exist, as before the first run,
+// an empty LastRun is returned.
+func LoadLastRun(path string) (*LastRun, error) {
+	lr := NewLastRun()
+	bites, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return lr, nil
+	} else if err != nil {
+		return nil, err
+	}
+	report := jsonReport{}
+	if err := json.Unmarshal(bites, &report); err != nil {
+		return nil, fmt.Errorf("Last run %s: %v", path, err)
+	}
+	for _, scenario := range report.Scenarios {
+		lr.scenarios[scenario.Name] = scenario
+	}
+	return lr, nil
+}
+
+// Record records the outcome of each of results, replacing that of any
+// earlier run of a scenario of the same name.
+func (lr *LastRun) Record(results ...*ScenarioResult) {
+	lr.lock.Lock()
+	defer lr.lock.Unlock()
+	for _, result := range results {
+		lr.scenarios[result.Name] = newJSONScenarioReport(result)
+	}
+}
+
+// Failed returns the names, sorted, of the scenarios which failed when
+// they were last run.
+func (lr *LastRun) Failed() []string {
+	lr.lock.Lock()
+	defer lr.lock.Unlock()
+	failed := []string{}
+	for name, scenario := range lr.scenarios {
+		if !scenario.Passed {
+			failed = append(failed, name)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// ShouldRun returns true iff the scenario called name should be run
+// when re-running failures: that is, iff it failed when last run, or
+// no scenario failed when last run, in which case everything is run.
+func (lr *LastRun) ShouldRun(name string) bool {
+	lr.lock.Lock()
+	defer lr.lock.Unlock()
+	anyFailed := false
+	for _, scenario := range lr.scenarios {
+		if !scenario.Passed {
+			anyFailed = true
+			break
+		}
+	}
+	if !anyFailed {
+		return true
+	} else if scenario, found := lr.scenarios[name]; found {
+		return !scenario.Passed
+	} else {
+		return false
+	}
+}
+
+// Save writes the outcome of every scenario recorded to path, in the
+// format of WriteJSONReport, with the scenarios in name order.
+func (lr *LastRun) Save(path string) error {
+	lr.lock.Lock()
+	names := make([]string, 0, len(lr.scenarios))
+	for name := range lr.scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	report := jsonReport{Scenarios: []jsonScenarioReport{}}
+	for _, name := range names {
+		scenario := lr.scenarios[name]
+		if scenario.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Duration += scenario.Duration
+		report.Scenarios = append(report.Scenarios, scenario)
+	}
+	lr.lock.Unlock()
+	if bites, err := json.MarshalIndent(report, "", "  "); err != nil {
+		return err
+	} else if err := ioutil.WriteFile(path, append(bites, '\n'), 0644); err != nil {
+		return fmt.Errorf("Last run %s: %v", path, err)
+	} else {
+		return nil
+	}
+}
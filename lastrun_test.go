@@ -0,0 +1,55 @@
+package argot
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLastRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "argot-lastrun")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "last-run.json")
+
+	ok := NewNamedStep("ok", func() error { return nil })
+	fail := NewNamedStep("fail", func() error { return errors.New("Expected 200; found 500.") })
+
+	lr, err := LoadLastRun(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if !lr.ShouldRun("a") || len(lr.Failed()) != 0 {
+		t.Fatal("Expected every scenario to run before the first run.")
+	}
+	lr.Record(RunScenario("a", Steps{ok}), RunScenario("b", Steps{fail}), RunScenario("c", Steps{ok, fail}))
+	if err := lr.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if lr, err = LoadLastRun(path); err != nil {
+		t.Fatal(err)
+	} else if failed := lr.Failed(); !reflect.DeepEqual(failed, []string{"b", "c"}) {
+		t.Fatalf("Unexpected failed scenarios: %v", failed)
+	} else if lr.ShouldRun("a") || !lr.ShouldRun("b") || lr.ShouldRun("new") {
+		t.Fatal("Expected only the failed scenarios to run.")
+	}
+
+	lr.Record(RunScenario("b", Steps{ok}))
+	if failed := lr.Failed(); !reflect.DeepEqual(failed, []string{"c"}) {
+		t.Fatalf("Unexpected failed scenarios: %v", failed)
+	}
+	lr.Record(RunScenario("c", Steps{ok}))
+	if !lr.ShouldRun("a") || !lr.ShouldRun("new") {
+		t.Fatal("Expected every scenario to run once none fail.")
+	}
+
+	ioutil.WriteFile(path, []byte("not json"), 0644)
+	if _, err := LoadLastRun(path); err == nil {
+		t.Fatal("Expected an error loading an invalid last run.")
+	}
+}
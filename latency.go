@@ -0,0 +1,114 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LatencyBudget lists the latency limits checked by
+// HttpCall.LatencyPercentiles. Zero limits are not checked.
+type LatencyBudget struct {
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+func (lb LatencyBudget) String() string {
+	parts := []string{}
+	for _, limit := range []struct {
+		name  string
+		limit time.Duration
+	}{{"p50", lb.P50}, {"p95", lb.P95}, {"p99", lb.P99}, {"max", lb.Max}} {
+		if limit.limit > 0 {
+			parts = append(parts, fmt.Sprintf("%s<%v", limit.name, limit.limit))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// replayRequest sends a copy of hc.Request, returning the response.
+// hc.Response is not touched.
+func (hc *HttpCall) replayRequest() (*http.Response, error) {
+	req := hc.Request.WithContext(hc.Request.Context())
+	if hc.Request.Body != nil && hc.Request.Body != http.NoBody {
+		if hc.Request.GetBody == nil {
+			return nil, errors.New("Request body cannot be replayed (no GetBody).")
+		} else if body, err := hc.Request.GetBody(); err != nil {
+			return nil, err
+		} else {
+			req.Body = body
+		}
+	}
+	return hc.client().Do(req)
+}
+
+// repeatRequest sends a copy of hc.Request, reading and discarding the
+// response body, and returns how long that took.
+func (hc *HttpCall) repeatRequest() (time.Duration, error) {
+	start := time.Now()
+	response, err := hc.replayRequest()
+	if err != nil {
+		return 0, err
+	}
+	_, err = io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+	return time.Since(start), err
+}
+
+// LatencyPercentiles is a Step that when executed sends n copies of
+// hc.Request, preceded by warmup copies whose timings are discarded,
+// and errors unless the distribution of latencies (each measured
+// until the response body has been read) is within budget. Any
+// transport error is an error. The requests are sent sequentially and
+// their responses are discarded: hc.Response is not set. The request
+// body, if any, must be replayable (as it is for bodies given to
+// NewRequest as a *bytes.Buffer, *bytes.Reader or *strings.Reader).
+func (hc *HttpCall) LatencyPercentiles(n, warmup int, budget LatencyBudget) Step {
+	return hc.step(fmt.Sprintf("LatencyPercentiles(%d: %v)", n, budget), func() error {
+		if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
+			return err
+		} else if n <= 0 {
+			return errors.New("Latency: n must be positive.")
+		}
+		for idx := 0; idx < warmup; idx++ {
+			if _, err := hc.repeatRequest(); err != nil {
+				return fmt.Errorf("Latency: warmup request %d: %v", idx+1, err)
+			}
+		}
+		latencies := make([]time.Duration, n)
+		for idx := range latencies {
+			var err error
+			if latencies[idx], err = hc.repeatRequest(); err != nil {
+				return fmt.Errorf("Latency: request %d: %v", idx+1, err)
+			}
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		found := LatencyBudget{
+			P50: percentile(latencies, 50),
+			P95: percentile(latencies, 95),
+			P99: percentile(latencies, 99),
+			Max: latencies[n-1],
+		}
+		failures := []string{}
+		for _, check := range []struct {
+			name         string
+			limit, found time.Duration
+		}{{"p50", budget.P50, found.P50}, {"p95", budget.P95, found.P95}, {"p99", budget.P99, found.P99}, {"max", budget.Max, found.Max}} {
+			if check.limit > 0 && check.found >= check.limit {
+				failures = append(failures, fmt.Sprintf("%s: Expected under %v; found %v.", check.name, check.limit, check.found))
+			}
+		}
+		if len(failures) != 0 {
+			return fmt.Errorf("Latency over %d requests (p50 %v, p95 %v, p99 %v, max %v):\n\t%s",
+				n, found.P50, found.P95, found.P99, found.Max, strings.Join(failures, "\n\t"))
+		}
+		return nil
+	})
+}
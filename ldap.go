@@ -0,0 +1,220 @@
+package argot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LDAPScope is the scope of an LDAP search.
+type LDAPScope int
+
+const (
+	// LDAPScopeBase searches only the base entry itself.
+	LDAPScopeBase LDAPScope = iota
+	// LDAPScopeOneLevel searches the immediate children of the base
+	// entry.
+	LDAPScopeOneLevel
+	// LDAPScopeSubtree searches the base entry and all its
+	// descendants.
+	LDAPScopeSubtree
+)
+
+// LDAPEntry is an entry found by an LDAP search.
+type LDAPEntry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Get returns the values of the attribute called name, which, as in
+// LDAP, is matched case-insensitively.
+func (e *LDAPEntry) Get(name string) []string {
+	if values, found := e.Attributes[name]; found {
+		return values
+	}
+	for key, values := range e.Attributes {
+		if strings.EqualFold(key, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// LDAPClient is the subset of LDAP operations used by LDAPCall. argot
+// does not bundle an LDAP implementation: wrap the connection of your
+// LDAP library of choice in a few lines, for example with
+// github.com/go-ldap/ldap, Search is Conn.Search, converting each
+// ldap.Entry to an LDAPEntry.
+type LDAPClient interface {
+	// Bind authenticates the connection as dn with a simple bind.
+	Bind(dn, password string) error
+	// Search returns the entries within scope of baseDN which match
+	// filter, in the string form of RFC 4515, with the attributes
+	// named (or, if none are, all user attributes). If baseDN does not
+	// exist, Search must return no entries and no error.
+	Search(baseDN string, scope LDAPScope, filter string, attributes ...string) ([]*LDAPEntry, error)
+}
+
+// LDAPCall provides steps for verifying the entries of an LDAP
+// directory (such as Active Directory), for testing APIs, such as
+// those which provision users, whose side effects live in a directory.
+type LDAPCall struct {
+	Client LDAPClient
+	// The credentials with which the client is rebound after
+	// ExpectBind and ExpectBindFails, which bind as other users. If
+	// BindDN is empty, the client is not rebound.
+	BindDN   string
+	Password string
+}
+
+// NewLDAPCall creates a new LDAPCall using client, which is rebound as
+// bindDN after binding as other users.
+func NewLDAPCall(client LDAPClient, bindDN, password string) *LDAPCall {
+	return &LDAPCall{Client: client, BindDN: bindDN, Password: password}
+}
+
+// Bind is a Step that when executed binds the client as lc.BindDN.
+func (lc *LDAPCall) Bind() Step {
+	return NewNamedStep(fmt.Sprintf("LDAPBind(%s)", lc.BindDN), func() error {
+		return lc.Client.Bind(lc.BindDN, lc.Password)
+	})
+}
+
+// rebind binds the client as lc.BindDN, if it is set.
+func (lc *LDAPCall) rebind() error {
+	if lc.BindDN == "" {
+		return nil
+	} else if err := lc.Client.Bind(lc.BindDN, lc.Password); err != nil {
+		return fmt.Errorf("LDAP: Rebinding as %s: %v", lc.BindDN, err)
+	} else {
+		return nil
+	}
+}
+
+// ExpectBind is a Step that when executed errors unless the client can
+// bind as dn with password, for example to check that a provisioned
+// user can log in. The client is then rebound as lc.BindDN.
+func (lc *LDAPCall) ExpectBind(dn, password string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectLDAPBind(%s)", dn), func() error {
+		if err := lc.Client.Bind(dn, password); err != nil {
+			return fmt.Errorf("LDAP: Expected to bind as %s; failed: %v", dn, err)
+		}
+		return lc.rebind()
+	})
+}
+
+// ExpectBindFails is a Step that when executed errors if the client
+// can bind as dn with password, for example to check that a
+// deprovisioned user can no longer log in. The client is then rebound
+// as lc.BindDN.
+func (lc *LDAPCall) ExpectBindFails(dn, password string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectLDAPBindFails(%s)", dn), func() error {
+		err := lc.Client.Bind(dn, password)
+		if rebindErr := lc.rebind(); rebindErr != nil {
+			return rebindErr
+		} else if err == nil {
+			return fmt.Errorf("LDAP: Expected binding as %s to fail; it succeeded.", dn)
+		} else {
+			return nil
+		}
+	})
+}
+
+// entry returns the entry dn, or nil if it does not exist.
+func (lc *LDAPCall) entry(dn string, attributes ...string) (*LDAPEntry, error) {
+	if entries, err := lc.Client.Search(dn, LDAPScopeBase, "(objectClass=*)", attributes...); err != nil {
+		return nil, err
+	} else if len(entries) == 0 {
+		return nil, nil
+	} else {
+		return entries[0], nil
+	}
+}
+
+// ExpectEntry is a Step that when executed errors unless the entry dn
+// exists and, for each attribute of attributes, has exactly the given
+// values, in any order. Attributes not given are not checked; an
+// attribute given with no values must be absent.
+func (lc *LDAPCall) ExpectEntry(dn string, attributes map[string][]string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectLDAPEntry(%s)", dn), func() error {
+		names := sortedStringSliceKeys(attributes)
+		entry, err := lc.entry(dn, names...)
+		if err != nil {
+			return err
+		} else if entry == nil {
+			return fmt.Errorf("LDAP: Expected entry %s; not found.", dn)
+		}
+		for _, name := range names {
+			expected := append([]string{}, attributes[name]...)
+			found := append([]string{}, entry.Get(name)...)
+			sort.Strings(expected)
+			sort.Strings(found)
+			if strings.Join(expected, "\x00") != strings.Join(found, "\x00") || len(expected) != len(found) {
+				return fmt.Errorf("LDAP: %s: %s: Expected %q; found %q.", dn, name, expected, found)
+			}
+		}
+		return nil
+	})
+}
+
+// ExpectAttributeContains is a Step that when executed errors unless
+// the entry dn exists and its attribute called name has value amongst
+// its values, for example to check group membership with memberOf.
+func (lc *LDAPCall) ExpectAttributeContains(dn, name, value string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectLDAPAttributeContains(%s, %s, %s)", dn, name, value), func() error {
+		entry, err := lc.entry(dn, name)
+		if err != nil {
+			return err
+		} else if entry == nil {
+			return fmt.Errorf("LDAP: Expected entry %s; not found.", dn)
+		}
+		for _, found := range entry.Get(name) {
+			if found == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("LDAP: %s: %s: Expected to contain %q; found %q.", dn, name, value, entry.Get(name))
+	})
+}
+
+// ExpectNoEntry is a Step that when executed errors if the entry dn
+// exists.
+func (lc *LDAPCall) ExpectNoEntry(dn string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectNoLDAPEntry(%s)", dn), func() error {
+		if entry, err := lc.entry(dn); err != nil {
+			return err
+		} else if entry != nil {
+			return fmt.Errorf("LDAP: Expected no entry %s; found one.", dn)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectSearchCount is a Step that when executed searches the subtree
+// of baseDN for entries matching filter, and errors unless count are
+// found.
+func (lc *LDAPCall) ExpectSearchCount(baseDN, filter string, count int) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectLDAPSearchCount(%s, %s, %d)", baseDN, filter, count), func() error {
+		if entries, err := lc.Client.Search(baseDN, LDAPScopeSubtree, filter, "1.1"); err != nil {
+			return err
+		} else if len(entries) != count {
+			dns := make([]string, len(entries))
+			for idx, entry := range entries {
+				dns[idx] = entry.DN
+			}
+			return fmt.Errorf("LDAP: %s %s: Expected %d entries; found %d: %q.", baseDN, filter, count, len(entries), dns)
+		} else {
+			return nil
+		}
+	})
+}
+
+func sortedStringSliceKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
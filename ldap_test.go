@@ -0,0 +1,89 @@
+package argot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// memoryLDAP is an LDAPClient over a fixed set of entries, which
+// understands only the filters (objectClass=*) and (attr=value).
+type memoryLDAP struct {
+	entries   []*LDAPEntry
+	passwords map[string]string
+	bound     string
+}
+
+func (m *memoryLDAP) Bind(dn, password string) error {
+	if expected, found := m.passwords[dn]; !found || expected != password {
+		return errors.New("LDAP Result Code 49 \"Invalid Credentials\"")
+	}
+	m.bound = dn
+	return nil
+}
+
+func (m *memoryLDAP) Search(baseDN string, scope LDAPScope, filter string, attributes ...string) ([]*LDAPEntry, error) {
+	if m.bound == "" {
+		return nil, errors.New("not bound")
+	}
+	pair := strings.SplitN(strings.Trim(filter, "()"), "=", 2)
+	found := []*LDAPEntry{}
+	for _, entry := range m.entries {
+		if scope == LDAPScopeBase && entry.DN != baseDN || !strings.HasSuffix(entry.DN, baseDN) {
+			continue
+		} else if pair[1] == "*" || strings.Contains(strings.Join(entry.Get(pair[0]), "\x00")+"\x00", pair[1]+"\x00") {
+			found = append(found, entry)
+		}
+	}
+	return found, nil
+}
+
+func TestLDAPCall(t *testing.T) {
+	client := &memoryLDAP{
+		entries: []*LDAPEntry{
+			{DN: "ou=people,dc=example,dc=com", Attributes: map[string][]string{"objectClass": {"organizationalUnit"}}},
+			{DN: "uid=ada,ou=people,dc=example,dc=com", Attributes: map[string][]string{
+				"objectClass": {"inetOrgPerson"},
+				"mail":        {"ada@example.com"},
+				"memberOf":    {"cn=admins,dc=example,dc=com", "cn=staff,dc=example,dc=com"},
+			}},
+		},
+		passwords: map[string]string{"cn=admin,dc=example,dc=com": "admin", "uid=ada,ou=people,dc=example,dc=com": "engine"},
+	}
+	lc := NewLDAPCall(client, "cn=admin,dc=example,dc=com", "admin")
+	ada := "uid=ada,ou=people,dc=example,dc=com"
+	if err := (Steps{
+		lc.Bind(),
+		lc.ExpectEntry(ada, map[string][]string{
+			"Mail":     {"ada@example.com"},
+			"memberOf": {"cn=staff,dc=example,dc=com", "cn=admins,dc=example,dc=com"},
+			"phone":    {},
+		}),
+		lc.ExpectAttributeContains(ada, "memberof", "cn=admins,dc=example,dc=com"),
+		lc.ExpectNoEntry("uid=bob,ou=people,dc=example,dc=com"),
+		lc.ExpectSearchCount("dc=example,dc=com", "(objectClass=inetOrgPerson)", 1),
+		lc.ExpectBind(ada, "engine"),
+		lc.ExpectBindFails(ada, "wrong"),
+	}).Go(); err != nil {
+		t.Fatal(err)
+	} else if client.bound != lc.BindDN {
+		t.Fatalf("Expected the client to be rebound as %s; found %s.", lc.BindDN, client.bound)
+	}
+
+	for _, c := range []struct {
+		step     Step
+		expected string
+	}{
+		{lc.ExpectEntry(ada, map[string][]string{"mail": {"ada@example.org"}}), `LDAP: uid=ada,ou=people,dc=example,dc=com: mail: Expected ["ada@example.org"]; found ["ada@example.com"].`},
+		{lc.ExpectEntry("uid=bob,ou=people,dc=example,dc=com", nil), "LDAP: Expected entry uid=bob,ou=people,dc=example,dc=com; not found."},
+		{lc.ExpectAttributeContains(ada, "memberOf", "cn=root,dc=example,dc=com"), `Expected to contain "cn=root,dc=example,dc=com"`},
+		{lc.ExpectNoEntry(ada), "LDAP: Expected no entry uid=ada,ou=people,dc=example,dc=com; found one."},
+		{lc.ExpectSearchCount("dc=example,dc=com", "(objectClass=*)", 1), "Expected 1 entries; found 2"},
+		{lc.ExpectBind(ada, "wrong"), "LDAP: Expected to bind as uid=ada,ou=people,dc=example,dc=com; failed: LDAP Result Code 49"},
+		{lc.ExpectBindFails(ada, "engine"), "LDAP: Expected binding as uid=ada,ou=people,dc=example,dc=com to fail; it succeeded."},
+	} {
+		if err := c.step.Go(); err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Fatalf("%v: Expected error containing %q; found %v.", c.step, c.expected, err)
+		}
+	}
+}
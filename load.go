@@ -0,0 +1,258 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LoadOptions controls a LoadTest.
+type LoadOptions struct {
+	// Concurrency is the number of workers, each repeatedly running
+	// its own pipeline. If zero, 1 is used.
+	Concurrency int
+	// Duration is how long to run for. If zero, Iterations must be
+	// set.
+	Duration time.Duration
+	// Iterations is the total number of pipeline runs, across all
+	// workers. If Duration is also set, whichever limit is reached
+	// first ends the test.
+	Iterations int
+	// TargetRPS limits the rate at which pipeline runs are started,
+	// across all workers. If zero, runs are started as fast as the
+	// workers allow.
+	TargetRPS float64
+}
+
+// LoadResult aggregates the outcome of a LoadTest.
+type LoadResult struct {
+	// Iterations is the number of pipeline runs completed.
+	Iterations int
+	// Errors is the number of pipeline runs which errored.
+	Errors int
+	// FirstError is the first error encountered, if any.
+	FirstError error
+	// Duration is the wall-clock duration of the test.
+	Duration time.Duration
+	// Latencies holds the duration of every pipeline run, sorted.
+	Latencies []time.Duration
+}
+
+// Throughput returns the number of pipeline runs completed per
+// second.
+func (lr *LoadResult) Throughput() float64 {
+	if lr.Duration <= 0 {
+		return 0
+	}
+	return float64(lr.Iterations) / lr.Duration.Seconds()
+}
+
+// ErrorRate returns the fraction of pipeline runs which errored.
+func (lr *LoadResult) ErrorRate() float64 {
+	if lr.Iterations == 0 {
+		return 0
+	}
+	return float64(lr.Errors) / float64(lr.Iterations)
+}
+
+// Percentile returns the pth percentile (0 < p <= 100) of the
+// latencies, using the nearest-rank method.
+func (lr *LoadResult) Percentile(p float64) time.Duration {
+	return percentile(lr.Latencies, p)
+}
+
+func (lr *LoadResult) String() string {
+	return fmt.Sprintf("%d iterations in %v (%.1f/s), %d errors, p50 %v, p95 %v, p99 %v",
+		lr.Iterations, lr.Duration, lr.Throughput(), lr.Errors,
+		lr.Percentile(50), lr.Percentile(95), lr.Percentile(99))
+}
+
+// percentile returns the pth percentile of sorted, using the
+// nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	} else if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// LoadTest is a Step which runs a pipeline of steps repeatedly,
+// across several workers, and records throughput, error rate and
+// latency. Assertions over the aggregate are made with further steps
+// such as PercentileUnder. For example:
+//
+//	load := Load(func() Steps {
+//		hc := NewHttpCall(nil)
+//		return Steps{hc.NewRequest("GET", url, nil), hc.ResponseStatusEquals(200), StepFunc(hc.Reset)}
+//	}, LoadOptions{Concurrency: 10, Duration: 10 * time.Second})
+//	Steps{load, load.PercentileUnder(99, 300*time.Millisecond), load.ErrorRateUnder(0.01)}.Test(t)
+//
+// As an HttpCall can only be used by a single go-routine at a time,
+// each worker calls build to construct its own pipeline. Alternatively,
+// LoadCalls draws the HttpCall for every run from an HttpCallPool.
+type LoadTest struct {
+	build   func() Steps
+	options LoadOptions
+	// Result is set once the LoadTest has run.
+	Result *LoadResult
+}
+
+// Load creates a new LoadTest. build is called once per worker.
+func Load(build func() Steps, options LoadOptions) *LoadTest {
+	return &LoadTest{build: build, options: options}
+}
+
+// LoadCalls creates a new LoadTest in which every pipeline run takes
+// an HttpCall from pool, builds its steps with build, and returns the
+// HttpCall to the pool afterwards (see HttpCallPool.With).
+func LoadCalls(pool *HttpCallPool, build func(hc *HttpCall) Steps, options LoadOptions) *LoadTest {
+	step := pool.With(build)
+	return Load(func() Steps { return Steps{step} }, options)
+}
+
+func (lt *LoadTest) String() string {
+	return fmt.Sprintf("Load(concurrency %d, duration %v, iterations %d, rps %v)",
+		lt.options.Concurrency, lt.options.Duration, lt.options.Iterations, lt.options.TargetRPS)
+}
+
+// Go runs the load test, setting lt.Result. It errors only if the
+// options are invalid; use the assertion steps to check the result.
+func (lt *LoadTest) Go() error {
+	options := lt.options
+	if options.Duration <= 0 && options.Iterations <= 0 {
+		return errors.New("Load: Either Duration or Iterations must be set.")
+	}
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var lock sync.Mutex
+	result := &LoadResult{}
+	started := 0
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	halt := func() { stopOnce.Do(func() { close(stop) }) }
+
+	// claim reserves an iteration, returning false once the test is
+	// over.
+	claim := func() bool {
+		select {
+		case <-stop:
+			return false
+		default:
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		if options.Iterations > 0 && started >= options.Iterations {
+			return false
+		}
+		started++
+		return true
+	}
+
+	var ticker *time.Ticker
+	if options.TargetRPS > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / options.TargetRPS))
+		defer ticker.Stop()
+	}
+	start := time.Now()
+	if options.Duration > 0 {
+		timer := time.AfterFunc(options.Duration, halt)
+		defer timer.Stop()
+	}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency; worker++ {
+		steps := lt.build()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if ticker != nil {
+					select {
+					case <-ticker.C:
+					case <-stop:
+						return
+					}
+				}
+				if !claim() {
+					return
+				}
+				iterStart := time.Now()
+				err := steps.Go()
+				latency := time.Since(iterStart)
+				lock.Lock()
+				result.Iterations++
+				result.Latencies = append(result.Latencies, latency)
+				if err != nil {
+					result.Errors++
+					if result.FirstError == nil {
+						result.FirstError = err
+					}
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	halt()
+	result.Duration = time.Since(start)
+	sort.Slice(result.Latencies, func(i, j int) bool { return result.Latencies[i] < result.Latencies[j] })
+	lt.Result = result
+	return nil
+}
+
+func (lt *LoadTest) assertion(name string, check func(result *LoadResult) error) Step {
+	return NewNamedStep(name, func() error {
+		if lt.Result == nil {
+			return errors.New("Load: The load test has not been run.")
+		} else if lt.Result.Iterations == 0 {
+			return errors.New("Load: No iterations completed.")
+		} else {
+			return check(lt.Result)
+		}
+	})
+}
+
+// PercentileUnder is a Step that when executed errors unless the pth
+// percentile latency of the completed load test is under limit.
+func (lt *LoadTest) PercentileUnder(p float64, limit time.Duration) Step {
+	return lt.assertion(fmt.Sprintf("PercentileUnder(p%v: %v)", p, limit), func(result *LoadResult) error {
+		if found := result.Percentile(p); found >= limit {
+			return fmt.Errorf("Load: p%v: Expected under %v; found %v. %v", p, limit, found, result)
+		}
+		return nil
+	})
+}
+
+// ErrorRateUnder is a Step that when executed errors unless the
+// fraction of pipeline runs which errored is at most rate.
+func (lt *LoadTest) ErrorRateUnder(rate float64) Step {
+	return lt.assertion(fmt.Sprintf("ErrorRateUnder(%v)", rate), func(result *LoadResult) error {
+		if found := result.ErrorRate(); found > rate {
+			return fmt.Errorf("Load: Error rate: Expected at most %v; found %v. First error: %v", rate, found, result.FirstError)
+		}
+		return nil
+	})
+}
+
+// ThroughputAtLeast is a Step that when executed errors unless the
+// load test completed at least rps pipeline runs per second.
+func (lt *LoadTest) ThroughputAtLeast(rps float64) Step {
+	return lt.assertion(fmt.Sprintf("ThroughputAtLeast(%v)", rps), func(result *LoadResult) error {
+		if found := result.Throughput(); found < rps {
+			return fmt.Errorf("Load: Throughput: Expected at least %v/s; found %.2f/s.", rps, found)
+		}
+		return nil
+	})
+}
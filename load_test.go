@@ -0,0 +1,75 @@
+package argot
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	var count int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&count, 1)%10 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	load := Load(func() Steps {
+		hc := NewHttpCall(nil)
+		return Steps{
+			hc.NewRequest("GET", server.URL, nil),
+			hc.ResponseStatusEquals(http.StatusOK),
+			StepFunc(hc.Reset),
+		}
+	}, LoadOptions{Concurrency: 4, Iterations: 100})
+
+	Steps{
+		load,
+		load.PercentileUnder(100, time.Second),
+		load.ErrorRateUnder(0.1),
+		ExpectError(load.ErrorRateUnder(0.05)),
+		ExpectError(load.ThroughputAtLeast(1e9)),
+	}.Test(t)
+	if load.Result.Iterations != 100 || load.Result.Errors != 10 {
+		t.Fatalf("Unexpected result: %v", load.Result)
+	}
+}
+
+func TestLoadRate(t *testing.T) {
+	load := Load(func() Steps {
+		return Steps{StepFunc(func() error { return errors.New("nope") })}
+	}, LoadOptions{Concurrency: 2, Duration: 200 * time.Millisecond, TargetRPS: 50})
+	Steps{load, ExpectError(load.ErrorRateUnder(0.5))}.Test(t)
+	if iterations := load.Result.Iterations; iterations < 5 || iterations > 12 {
+		t.Fatalf("Expected about 10 iterations at 50 rps for 200ms; found %d", iterations)
+	}
+}
+
+func TestLoadCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+	}))
+	defer server.Close()
+
+	pool := NewHttpCallPool(nil)
+	load := LoadCalls(pool, func(hc *HttpCall) Steps {
+		return Steps{
+			hc.NewRequest("GET", server.URL, nil),
+			hc.ResponseStatusEquals(http.StatusOK),
+		}
+	}, LoadOptions{Concurrency: 4, Iterations: 50})
+	Steps{load, load.ErrorRateUnder(0)}.Test(t)
+	if created := pool.Created(); created < 1 || created > 4 {
+		t.Fatalf("Expected between 1 and 4 pooled calls; found %d", created)
+	}
+
+	hc := pool.Get()
+	if hc.Request != nil || hc.Response != nil {
+		t.Fatal("Expected pooled call to have been reset.")
+	}
+	pool.Put(hc)
+}
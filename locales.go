@@ -0,0 +1,138 @@
+package argot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// LocaleVariant is an Accept-Language header value to request, and
+// the response expected for it. See HttpCall.Locales.
+type LocaleVariant struct {
+	// AcceptLanguage is sent as the Accept-Language header, for
+	// example "fr-FR" or "de;q=0.9, en;q=0.5".
+	AcceptLanguage string
+	// ContentLanguage, if non-empty, is the language tag expected in
+	// the Content-Language header of the response, compared without
+	// regard to case.
+	ContentLanguage string
+	// Contains are the translated strings which the body must
+	// contain, such as "Panier" for a French basket.
+	Contains []string
+	// Steps are run against the response, for example to check the
+	// format of a localised price.
+	Steps Steps
+}
+
+// check checks hc.Response against the variant, returning every
+// discrepancy.
+func (lv LocaleVariant) check(hc *HttpCall) []string {
+	failures := []string{}
+	if lv.ContentLanguage != "" {
+		hc.coverHeader("Content-Language")
+		found := false
+		for _, tag := range strings.Split(hc.Response.Header.Get("Content-Language"), ",") {
+			found = found || strings.EqualFold(strings.TrimSpace(tag), lv.ContentLanguage)
+		}
+		if !found {
+			failures = append(failures, fmt.Sprintf("Content-Language: Expected %s; found '%s'.", lv.ContentLanguage, hc.Response.Header.Get("Content-Language")))
+		}
+	}
+	if len(lv.Contains) != 0 {
+		if err := hc.ReceiveBody(); err != nil {
+			return append(failures, err.Error())
+		}
+		for _, str := range lv.Contains {
+			if !bytes.Contains(hc.ResponseBody, []byte(str)) {
+				failures = append(failures, fmt.Sprintf("Body: Expected to contain '%s'.", str))
+			}
+		}
+	}
+	if err := lv.Steps.Go(); err != nil {
+		if hcErr, ok := err.(*HttpCallError); ok {
+			err = hcErr.Err
+		}
+		failures = append(failures, err.Error())
+	}
+	return failures
+}
+
+// replay resets hc and sets hc.Request to a copy of template, whose
+// body is body, so that the request built by earlier steps can be sent
+// repeatedly with variations.
+func (hc *HttpCall) replay(template *http.Request, body []byte) {
+	hc.Reset()
+	hc.Request = template.Clone(template.Context())
+	if len(body) != 0 {
+		hc.Request.GetBody = func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(body)), nil }
+		hc.Request.Body, _ = hc.Request.GetBody()
+	}
+}
+
+// Locales is a Step that when executed replays the request built by
+// earlier steps once for each variant, with the variant's
+// Accept-Language header, and checks each response's Content-Language
+// and body against the variant, and runs the variant's Steps. Every
+// variant is run, whatever the outcome of the others, and the step
+// errors if any fails, with a matrix of the outcome of every variant.
+// For example:
+//
+//	Steps{
+//		hc.NewRequest("GET", url, nil),
+//		hc.Locales(
+//			argot.LocaleVariant{AcceptLanguage: "en-GB", ContentLanguage: "en-GB", Contains: []string{"Basket"}},
+//			argot.LocaleVariant{AcceptLanguage: "fr-FR", ContentLanguage: "fr-FR", Contains: []string{"Panier"}},
+//			argot.LocaleVariant{AcceptLanguage: "xx", ContentLanguage: "en-GB"},
+//		),
+//	}
+//
+// Like Call, it must be used after the request has been built, and
+// before any step which needs the response. hc is left holding the
+// response to the last variant.
+func (hc *HttpCall) Locales(variants ...LocaleVariant) Step {
+	tags := make([]string, len(variants))
+	for idx, variant := range variants {
+		tags[idx] = variant.AcceptLanguage
+	}
+	return hc.step(fmt.Sprintf("Locales(%s)", strings.Join(tags, " | ")), func() error {
+		if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
+			return err
+		}
+		template := hc.Request
+		body, err := RequestBodyBytes(template)
+		if err != nil {
+			return err
+		}
+		width := 0
+		for _, tag := range tags {
+			if len(tag) > width {
+				width = len(tag)
+			}
+		}
+		rows := make([]string, len(variants))
+		failed := 0
+		for idx, variant := range variants {
+			hc.replay(template, body)
+			hc.Request.Header.Set("Accept-Language", variant.AcceptLanguage)
+			failures := []string{}
+			if err := hc.EnsureResponse(); err != nil {
+				failures = append(failures, err.Error())
+			} else {
+				failures = variant.check(hc)
+			}
+			outcome := "ok"
+			if len(failures) != 0 {
+				failed++
+				outcome = strings.Join(failures, "\n\t"+strings.Repeat(" ", width+2))
+			}
+			rows[idx] = fmt.Sprintf("%-*s  %s", width, variant.AcceptLanguage, outcome)
+		}
+		if failed != 0 {
+			return fmt.Errorf("Locales: %d of %d failed:\n\t%s", failed, len(variants), strings.Join(rows, "\n\t"))
+		}
+		return nil
+	})
+}
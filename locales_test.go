@@ -0,0 +1,53 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLocales(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch lang := r.Header.Get("Accept-Language"); {
+		case strings.HasPrefix(lang, "fr"):
+			w.Header().Set("Content-Language", "fr-FR")
+			w.Write([]byte("<h1>Panier</h1>"))
+		case strings.HasPrefix(lang, "de"):
+			// Untranslated.
+			w.Header().Set("Content-Language", "en-GB")
+			w.Write([]byte("<h1>Basket</h1>"))
+		default:
+			w.Header().Set("Content-Language", "en-GB")
+			w.Write([]byte("<h1>Basket</h1>"))
+		}
+	}))
+	defer server.Close()
+
+	en := LocaleVariant{AcceptLanguage: "en-GB", ContentLanguage: "en-gb", Contains: []string{"Basket"}}
+	fr := LocaleVariant{AcceptLanguage: "fr-FR", ContentLanguage: "fr-FR", Contains: []string{"Panier"}}
+	de := LocaleVariant{AcceptLanguage: "de", ContentLanguage: "de", Contains: []string{"Warenkorb"}}
+	hc := NewHttpCall(nil)
+	fr.Steps = Steps{hc.ResponseStatusEquals(http.StatusOK)}
+	if err := (Steps{
+		hc.NewRequest(http.MethodPost, server.URL+"/basket", strings.NewReader("tea")),
+		hc.Locales(en, fr),
+	}).Go(); err != nil {
+		t.Fatal(err)
+	} else if lang := hc.Request.Header.Get("Accept-Language"); lang != "fr-FR" {
+		t.Fatalf("Expected hc to hold the last variant; found %s", lang)
+	}
+
+	err := Steps{
+		hc.NewRequest(http.MethodGet, server.URL+"/basket", nil),
+		hc.Locales(en, de, fr),
+	}.Go()
+	expected := "Locales: 1 of 3 failed:\n" +
+		"\ten-GB  ok\n" +
+		"\tde     Content-Language: Expected de; found 'en-GB'.\n" +
+		"\t       Body: Expected to contain 'Warenkorb'.\n" +
+		"\tfr-FR  ok"
+	if hcErr, ok := err.(*HttpCallError); !ok || hcErr.Err.Error() != expected {
+		t.Fatalf("Expected a matrix of the outcomes; found %v", err)
+	}
+}
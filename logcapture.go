@@ -0,0 +1,111 @@
+package argot
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogCapture is an io.Writer which captures log output line by line,
+// so that steps can assert on what the system under test logs. Use it
+// as the output of an in-process logger (for example log.New or
+// slog.NewTextHandler), or set it as Process.Logs to capture the
+// output of a spawned process. It is safe for concurrent use.
+type LogCapture struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	lines   []string
+	partial []byte
+}
+
+// NewLogCapture creates a new, empty LogCapture.
+func NewLogCapture() *LogCapture {
+	lc := &LogCapture{}
+	lc.cond = sync.NewCond(&lc.lock)
+	return lc
+}
+
+// Write implements io.Writer. Each complete line written becomes
+// available to the steps; an incomplete final line is held until it
+// is completed.
+func (lc *LogCapture) Write(p []byte) (int, error) {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	lc.partial = append(lc.partial, p...)
+	for {
+		idx := bytes.IndexByte(lc.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		lc.lines = append(lc.lines, strings.TrimSuffix(string(lc.partial[:idx]), "\r"))
+		lc.partial = lc.partial[idx+1:]
+	}
+	lc.cond.Broadcast()
+	return len(p), nil
+}
+
+// Lines returns the complete lines captured so far.
+func (lc *LogCapture) Lines() []string {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	return append([]string{}, lc.lines...)
+}
+
+// String returns everything captured so far, including any incomplete
+// final line.
+func (lc *LogCapture) String() string {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	all := strings.Join(lc.lines, "\n")
+	if len(lc.lines) > 0 {
+		all += "\n"
+	}
+	return all + string(lc.partial)
+}
+
+// Clear discards everything captured so far.
+func (lc *LogCapture) Clear() {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	lc.lines = nil
+	lc.partial = nil
+}
+
+// ExpectLogLineMatching is a Step that when executed waits up to
+// timeout for a captured line to match pattern, erroring if none
+// does.
+func (lc *LogCapture) ExpectLogLineMatching(pattern *regexp.Regexp, timeout time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectLogLineMatching(%v)", pattern), func() error {
+		lc.lock.Lock()
+		defer lc.lock.Unlock()
+		if waitForCond(lc.cond, timeout, func() bool {
+			for _, line := range lc.lines {
+				if pattern.MatchString(line) {
+					return true
+				}
+			}
+			return false
+		}) {
+			return nil
+		} else {
+			return fmt.Errorf("Log: Expected a line matching '%v' within %v; found none in %d lines.", pattern, timeout, len(lc.lines))
+		}
+	})
+}
+
+// ExpectNoLogLineMatching is a Step that when executed errors if any
+// line captured so far matches pattern, for example to check that
+// secrets are not logged.
+func (lc *LogCapture) ExpectNoLogLineMatching(pattern *regexp.Regexp) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectNoLogLineMatching(%v)", pattern), func() error {
+		for idx, line := range lc.Lines() {
+			if pattern.MatchString(line) {
+				return fmt.Errorf("Log: Expected no line matching '%v'; found line %d: '%s'.", pattern, idx+1, line)
+			}
+		}
+		return nil
+	})
+}
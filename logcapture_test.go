@@ -0,0 +1,44 @@
+package argot
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestLogCapture(t *testing.T) {
+	logs := NewLogCapture()
+	logger := log.New(logs, "", 0)
+	hc := NewHandlerCall(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			logger.Printf("ERROR payment failed for order %s", r.URL.Query().Get("order"))
+		}()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hc.Reset()
+
+	Steps{
+		hc.NewRequest("GET", "http://example.com/pay?order=42", nil),
+		hc.ResponseStatusEquals(http.StatusAccepted),
+		logs.ExpectLogLineMatching(regexp.MustCompile(`^ERROR .* order 42$`), time.Second),
+		ExpectError(logs.ExpectLogLineMatching(regexp.MustCompile(`order 43`), 50*time.Millisecond)),
+		logs.ExpectNoLogLineMatching(regexp.MustCompile(`password`)),
+		ExpectError(logs.ExpectNoLogLineMatching(regexp.MustCompile(`payment`))),
+	}.Test(t)
+
+	logs.Clear()
+	p := NewProcess("sh", "-c", "echo starting; printf 'partial'")
+	p.Logs = logs
+	defer p.Kill()
+	Steps{
+		p.Start(),
+		logs.ExpectLogLineMatching(regexp.MustCompile(`^starting$`), 5*time.Second),
+		ExpectError(logs.ExpectLogLineMatching(regexp.MustCompile(`ERROR`), 0)),
+	}.Test(t)
+	if lines := logs.Lines(); len(lines) != 1 {
+		t.Fatalf("Expected only complete lines; found %v", lines)
+	}
+}
@@ -0,0 +1,88 @@
+package argot
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// startCall performs hc.Request in the background, returning a channel
+// which receives the result of hc.EnsureResponse, and a function
+// which cancels the request and waits for it to finish. hc must not
+// be touched until the result has been received or the request
+// cancelled.
+func (hc *HttpCall) startCall() (<-chan error, func()) {
+	ctx, cancel := context.WithCancel(hc.Request.Context())
+	hc.Request = hc.Request.WithContext(ctx)
+	done := make(chan error, 1)
+	go func() { done <- hc.EnsureResponse() }()
+	return done, func() {
+		cancel()
+		<-done
+	}
+}
+
+// LongPoll is a Step that when executed tests the blocking semantics
+// of a long-polling endpoint. It sends hc.Request in the background
+// and errors if a response arrives within hold. It then runs trigger
+// (typically a step that causes the awaited event, perhaps through
+// another HttpCall) and errors unless the response arrives within
+// within of trigger completing. hc.Response is set, so the response
+// can then be inspected as normal. If the step errors before the
+// response arrives, the request is cancelled.
+func (hc *HttpCall) LongPoll(hold time.Duration, trigger Step, within time.Duration) Step {
+	return hc.step(fmt.Sprintf("LongPoll(hold %v, %v, within %v)", hold, trigger, within), func() error {
+		if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
+			return err
+		}
+		done, cancel := hc.startCall()
+		select {
+		case err := <-done:
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("Long poll: Expected no response before the trigger; found status %d.", hc.Response.StatusCode)
+		case <-time.After(hold):
+		}
+		if err := trigger.Go(); err != nil {
+			cancel()
+			return fmt.Errorf("Long poll: trigger %v: %v", trigger, err)
+		}
+		triggered := time.Now()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(within):
+			cancel()
+			return fmt.Errorf("Long poll: Expected a response within %v of the trigger; found none after %v.", within, time.Since(triggered))
+		}
+	})
+}
+
+// ResponseArrivesBetween is a Step that when executed sends hc.Request
+// and errors unless the response arrives no sooner than min and no
+// later than max after the request was sent. This can be used to test
+// a long-polling endpoint's timeout, when nothing triggers it. As with
+// LongPoll, hc.Response is set, and if the step errors before the
+// response arrives the request is cancelled.
+func (hc *HttpCall) ResponseArrivesBetween(min, max time.Duration) Step {
+	return hc.step(fmt.Sprintf("ResponseArrivesBetween(%v, %v)", min, max), func() error {
+		if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
+			return err
+		}
+		start := time.Now()
+		done, cancel := hc.startCall()
+		select {
+		case err := <-done:
+			if err != nil {
+				return err
+			} else if elapsed := time.Since(start); elapsed < min {
+				return fmt.Errorf("Long poll: Expected a response after at least %v; found one after %v.", min, elapsed)
+			}
+			return nil
+		case <-time.After(max):
+			cancel()
+			return fmt.Errorf("Long poll: Expected a response within %v; found none.", max)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLongPoll(t *testing.T) {
+	events := make(chan string, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/poll", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case event := <-events:
+			w.Write([]byte(event))
+		case <-time.After(200 * time.Millisecond):
+			w.WriteHeader(http.StatusNoContent)
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/publish", func(w http.ResponseWriter, r *http.Request) {
+		events <- r.URL.Query().Get("event")
+	})
+	mux.HandleFunc("/eager", func(w http.ResponseWriter, r *http.Request) {})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	trigger := NewHttpCall(nil)
+	defer trigger.Reset()
+	publish := Steps{
+		trigger.NewRequest("POST", server.URL+"/publish?event=hello", nil),
+		trigger.ResponseStatusEquals(http.StatusOK),
+	}
+	Steps{
+		hc.NewRequest("GET", server.URL+"/poll", nil),
+		hc.LongPoll(50*time.Millisecond, publish, 100*time.Millisecond),
+		hc.ResponseStatusEquals(http.StatusOK),
+		hc.ResponseBodyEquals("hello"),
+
+		hc.NewRequest("GET", server.URL+"/poll", nil),
+		hc.ResponseArrivesBetween(150*time.Millisecond, time.Second),
+		hc.ResponseStatusEquals(http.StatusNoContent),
+
+		hc.NewRequest("GET", server.URL+"/poll", nil),
+		ExpectError(hc.ResponseArrivesBetween(0, 50*time.Millisecond)),
+
+		hc.NewRequest("GET", server.URL+"/eager", nil),
+		ExpectError(hc.LongPoll(50*time.Millisecond, publish, time.Second)),
+	}.Test(t)
+	if len(events) != 0 {
+		t.Fatal("Expected the trigger not to run when the response was eager.")
+	}
+}
@@ -0,0 +1,224 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// endpointMetadata is the metadata of the responses of one endpoint.
+type endpointMetadata struct {
+	statuses map[int]int
+	headers  map[string]map[string]int
+}
+
+// ResponseMetadata aggregates the metadata of every response received
+// during a run, across scenarios: the distribution of statuses, and
+// the values seen of chosen headers, such as Server, Deprecation or
+// Sunset, per endpoint. Endpoints are identified as by Coverage, for
+// example "GET /teas/{id}". Responses are recorded as they are received
+// once Observe is called, and can be queried, or asserted on with
+// steps such as ExpectHeaderValues, at any point, for example after
+// every scenario has run. A ResponseMetadata is safe for concurrent
+// use.
+type ResponseMetadata struct {
+	// The canonical names of the headers whose values are aggregated.
+	Headers []string
+
+	lock      sync.Mutex
+	endpoints map[string]*endpointMetadata
+}
+
+// NewResponseMetadata creates a new, empty, ResponseMetadata which
+// aggregates the values of headers. If no headers are given, Server,
+// Deprecation, Sunset and Warning are.
+func NewResponseMetadata(headers ...string) *ResponseMetadata {
+	if len(headers) == 0 {
+		headers = []string{"Server", "Deprecation", "Sunset", "Warning"}
+	}
+	canonical := make([]string, len(headers))
+	for idx, header := range headers {
+		canonical[idx] = http.CanonicalHeaderKey(header)
+	}
+	return &ResponseMetadata{
+		Headers:   canonical,
+		endpoints: make(map[string]*endpointMetadata),
+	}
+}
+
+// Observe registers an event listener (see AddEventListener) which
+// records every response received by an HttpCall, other than during
+// a WarmupStep. The returned function unregisters it.
+func (rm *ResponseMetadata) Observe() func() {
+	return AddEventListener(func(event Event) {
+		if event.Type == RequestFinished && event.Err == nil && !event.Warmup {
+			rm.Record(event.Method, event.URL, event.Status, event.Header)
+		}
+	})
+}
+
+// Record records a response of status, with header, to a request of
+// method to urlStr.
+func (rm *ResponseMetadata) Record(method, urlStr string, status int, header http.Header) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		u = &url.URL{Path: urlStr}
+	}
+	key := coverageEndpoint(&http.Request{Method: method, URL: u})
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	endpoint, found := rm.endpoints[key]
+	if !found {
+		endpoint = &endpointMetadata{statuses: make(map[int]int), headers: make(map[string]map[string]int)}
+		rm.endpoints[key] = endpoint
+	}
+	endpoint.statuses[status]++
+	for _, name := range rm.Headers {
+		for _, value := range header[name] {
+			values, found := endpoint.headers[name]
+			if !found {
+				values = make(map[string]int)
+				endpoint.headers[name] = values
+			}
+			values[value]++
+		}
+	}
+}
+
+// Endpoints returns the endpoints which have responded, sorted.
+func (rm *ResponseMetadata) Endpoints() []string {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	endpoints := make([]string, 0, len(rm.endpoints))
+	for endpoint := range rm.endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+// Total returns the number of responses recorded.
+func (rm *ResponseMetadata) Total() int {
+	total := 0
+	for _, count := range rm.StatusCounts() {
+		total += count
+	}
+	return total
+}
+
+// StatusCounts returns the number of responses of each status, across
+// every endpoint.
+func (rm *ResponseMetadata) StatusCounts() map[int]int {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	counts := make(map[int]int)
+	for _, endpoint := range rm.endpoints {
+		for status, count := range endpoint.statuses {
+			counts[status] += count
+		}
+	}
+	return counts
+}
+
+// EndpointStatusCounts returns the number of responses of each status
+// of endpoint.
+func (rm *ResponseMetadata) EndpointStatusCounts(endpoint string) map[int]int {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	counts := make(map[int]int)
+	if metadata, found := rm.endpoints[endpoint]; found {
+		for status, count := range metadata.statuses {
+			counts[status] = count
+		}
+	}
+	return counts
+}
+
+// HeaderValues returns the number of responses, across every endpoint,
+// with each value of the header name, which must be one of rm.Headers.
+func (rm *ResponseMetadata) HeaderValues(name string) map[string]int {
+	name = http.CanonicalHeaderKey(name)
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	counts := make(map[string]int)
+	for _, endpoint := range rm.endpoints {
+		for value, count := range endpoint.headers[name] {
+			counts[value] += count
+		}
+	}
+	return counts
+}
+
+// EndpointsWithHeader returns the endpoints, sorted, which responded
+// with the header name, which must be one of rm.Headers, with value,
+// or with any value if value is empty.
+func (rm *ResponseMetadata) EndpointsWithHeader(name, value string) []string {
+	name = http.CanonicalHeaderKey(name)
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	endpoints := []string{}
+	for key, endpoint := range rm.endpoints {
+		if values := endpoint.headers[name]; value == "" && len(values) > 0 || value != "" && values[value] > 0 {
+			endpoints = append(endpoints, key)
+		}
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+// ExpectHeaderValues is a Step that when executed errors if any
+// response recorded had the header name, which must be one of
+// rm.Headers, with a value other than those allowed, listing the
+// endpoints which did. With no values allowed, the step errors if any
+// response had the header at all: for example,
+// ExpectHeaderValues("Deprecation") asserts that no endpoint used by
+// the suite is deprecated.
+func (rm *ResponseMetadata) ExpectHeaderValues(name string, allowed ...string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectHeaderValues(%s: %s)", name, strings.Join(allowed, ", ")), func() error {
+		permitted := make(map[string]bool, len(allowed))
+		for _, value := range allowed {
+			permitted[value] = true
+		}
+		unexpected := []string{}
+		values := rm.HeaderValues(name)
+		sorted := make([]string, 0, len(values))
+		for value := range values {
+			sorted = append(sorted, value)
+		}
+		sort.Strings(sorted)
+		for _, value := range sorted {
+			if !permitted[value] {
+				unexpected = append(unexpected, fmt.Sprintf("'%s' from %s", value, strings.Join(rm.EndpointsWithHeader(name, value), ", ")))
+			}
+		}
+		if len(unexpected) != 0 {
+			return fmt.Errorf("Header %s: Unexpected values:\n\t%s", http.CanonicalHeaderKey(name), strings.Join(unexpected, "\n\t"))
+		}
+		return nil
+	})
+}
+
+// ExpectNoStatus is a Step that when executed errors if any response
+// recorded had one of statuses, listing the endpoints which did: for
+// example, ExpectNoStatus(500, 502, 503) asserts that no endpoint
+// failed during the run, even in steps which tolerated the failure.
+func (rm *ResponseMetadata) ExpectNoStatus(statuses ...int) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectNoStatus(%v)", statuses), func() error {
+		found := []string{}
+		for _, endpoint := range rm.Endpoints() {
+			counts := rm.EndpointStatusCounts(endpoint)
+			for _, status := range statuses {
+				if counts[status] > 0 {
+					found = append(found, fmt.Sprintf("%s: %d x %d", endpoint, counts[status], status))
+				}
+			}
+		}
+		if len(found) != 0 {
+			return fmt.Errorf("Statuses: Expected none of %v; found:\n\t%s", statuses, strings.Join(found, "\n\t"))
+		}
+		return nil
+	})
+}
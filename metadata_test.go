@@ -0,0 +1,59 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "teapot/1.2")
+		if strings.HasPrefix(r.URL.Path, "/v1/") {
+			w.Header().Set("Deprecation", "true")
+		}
+		if r.URL.Path == "/v2/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	rm := NewResponseMetadata()
+	remove := rm.Observe()
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		Warmup(Steps{hc.NewRequest("GET", server.URL+"/v2/warm", nil), hc.ResponseStatusEquals(200)}, hc),
+		hc.NewRequest("GET", server.URL+"/v1/teas/1", nil), hc.ResponseStatusEquals(200),
+		hc.NewRequest("GET", server.URL+"/v1/teas/2", nil), hc.ResponseStatusEquals(200),
+		hc.NewRequest("GET", server.URL+"/v2/teas?page=2", nil), hc.ResponseStatusEquals(200),
+		hc.NewRequest("GET", server.URL+"/v2/broken", nil), hc.ResponseStatusEquals(500),
+	}.Test(t)
+	remove()
+	Steps{hc.NewRequest("GET", server.URL+"/v2/after", nil), hc.ResponseStatusEquals(200)}.Test(t)
+
+	if total := rm.Total(); total != 4 {
+		t.Fatalf("Expected 4 responses; found %d", total)
+	} else if counts := rm.StatusCounts(); counts[200] != 3 || counts[500] != 1 {
+		t.Fatalf("Unexpected status counts: %v", counts)
+	} else if endpoints := strings.Join(rm.Endpoints(), ", "); endpoints != "GET /v1/teas/{id}, GET /v2/broken, GET /v2/teas" {
+		t.Fatalf("Unexpected endpoints: %s", endpoints)
+	} else if servers := rm.HeaderValues("server"); len(servers) != 1 || servers["teapot/1.2"] != 4 {
+		t.Fatalf("Unexpected Server values: %v", servers)
+	} else if deprecated := rm.EndpointsWithHeader("Deprecation", ""); len(deprecated) != 1 || deprecated[0] != "GET /v1/teas/{id}" {
+		t.Fatalf("Unexpected deprecated endpoints: %v", deprecated)
+	}
+
+	Steps{
+		rm.ExpectHeaderValues("Server", "teapot/1.2"),
+		rm.ExpectHeaderValues("Sunset"),
+		rm.ExpectNoStatus(502, 503),
+	}.Test(t)
+	if err := rm.ExpectHeaderValues("Deprecation").Go(); err == nil || !strings.Contains(err.Error(), "Header Deprecation: Unexpected values:\n\t'true' from GET /v1/teas/{id}") {
+		t.Fatalf("Expected an error for an unknown Deprecation header; found %v", err)
+	}
+	if err := rm.ExpectNoStatus(500).Go(); err == nil || !strings.Contains(err.Error(), "GET /v2/broken: 1 x 500") {
+		t.Fatalf("Expected an error for a 500; found %v", err)
+	}
+}
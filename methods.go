@@ -0,0 +1,83 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// sweepMethods are the methods sent by MethodSweep. CONNECT is omitted
+// as it is not a request for a URL.
+var sweepMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodOptions, http.MethodTrace,
+}
+
+// DisallowedMethodPolicy is how a server is expected to respond to a
+// method a URL does not allow. See HttpCall.MethodSweep.
+type DisallowedMethodPolicy int
+
+const (
+	// MethodNotAllowed expects 405 Method Not Allowed, with an Allow
+	// header listing exactly the allowed methods.
+	MethodNotAllowed DisallowedMethodPolicy = iota
+	// MethodNotFound expects 404 Not Found, for servers which do not
+	// reveal which methods a URL allows.
+	MethodNotFound
+)
+
+// MethodSweep is a Step that when executed sends a request (without a
+// body) to urlStr with each of GET, HEAD, POST, PUT, PATCH, DELETE,
+// OPTIONS and TRACE which is not in allowed, and errors at the first
+// whose response does not follow policy. Servers which implicitly
+// allow HEAD with GET, or answer OPTIONS themselves, should have those
+// methods included in allowed. As the requests are sent, only a URL
+// whose allowed methods are all safe to call should be swept, and the
+// server must not perform the disallowed ones. hc is left holding the
+// last response.
+func (hc *HttpCall) MethodSweep(urlStr string, allowed []string, policy DisallowedMethodPolicy) Step {
+	isAllowed := make(map[string]bool, len(allowed))
+	for _, method := range allowed {
+		isAllowed[strings.ToUpper(method)] = true
+	}
+	expectedAllow := make([]string, 0, len(isAllowed))
+	for method := range isAllowed {
+		expectedAllow = append(expectedAllow, method)
+	}
+	sort.Strings(expectedAllow)
+	name := fmt.Sprintf("MethodSweep(%s: %s)", hc.redactor().String(urlStr), strings.Join(expectedAllow, ", "))
+	return hc.step(name, func() error {
+		for _, method := range sweepMethods {
+			if isAllowed[method] {
+				continue
+			}
+			if err := (Steps{hc.NewRequest(method, urlStr, nil), hc.Call()}).Go(); err != nil {
+				return err
+			}
+			status := hc.Response.StatusCode
+			if policy == MethodNotFound {
+				if status != http.StatusNotFound {
+					return fmt.Errorf("%s: Status: Expected %d; found %d.", method, http.StatusNotFound, status)
+				}
+				continue
+			} else if status != http.StatusMethodNotAllowed {
+				return fmt.Errorf("%s: Status: Expected %d; found %d.", method, http.StatusMethodNotAllowed, status)
+			}
+			hc.coverHeader("Allow")
+			allow := []string{}
+			for _, value := range hc.Response.Header.Values("Allow") {
+				for _, method := range strings.Split(value, ",") {
+					if method = strings.ToUpper(strings.TrimSpace(method)); method != "" {
+						allow = append(allow, method)
+					}
+				}
+			}
+			sort.Strings(allow)
+			if strings.Join(allow, ", ") != strings.Join(expectedAllow, ", ") {
+				return fmt.Errorf("%s: Allow: Expected '%s'; found '%s'.", method, strings.Join(expectedAllow, ", "), strings.Join(hc.Response.Header.Values("Allow"), ", "))
+			}
+		}
+		return nil
+	})
+}
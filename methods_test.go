@@ -0,0 +1,50 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMethodSweep(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/hidden" && r.Method != http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/wrong-allow" && r.Method != http.MethodGet:
+			w.Header().Set("Allow", "GET, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/accepts-delete" && r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method != http.MethodGet && r.Method != http.MethodHead:
+			w.Header().Add("Allow", "GET")
+			w.Header().Add("Allow", "head")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.MethodSweep(server.URL+"/tea", []string{"GET", "HEAD"}, MethodNotAllowed),
+		hc.MethodSweep(server.URL+"/hidden", []string{"GET"}, MethodNotFound),
+	}.Test(t)
+
+	for path, expected := range map[string]string{
+		"/tea":            "HEAD: Status: Expected 405; found 200.",
+		"/wrong-allow":    "POST: Allow: Expected 'GET, HEAD'; found 'GET, DELETE'.",
+		"/accepts-delete": "DELETE: Status: Expected 405; found 204.",
+	} {
+		allowed := []string{"get", "head"}
+		if path == "/tea" {
+			allowed = allowed[:1]
+		}
+		if _, err := (Steps{hc.MethodSweep(server.URL+path, allowed, MethodNotAllowed)}).Test(nil); err == nil {
+			t.Fatalf("%s: Expected an error.", path)
+		} else if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%s: Expected %q; found %q.", path, expected, err.Error())
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package argot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Middleware wraps the http.RoundTripper which sends a request, for
+// example to log, sign, cache or inject faults into requests and
+// responses. See HttpCall.Use.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an adapter to allow the use of an ordinary
+// function as an http.RoundTripper, as http.HandlerFunc is for
+// http.Handler. It is convenient for writing Middleware.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (fn RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+// Use adds middleware to the chain through which hc sends its
+// requests, without replacing or modifying hc.Client. Middleware added
+// first is outermost: it sees each request first and each response
+// last. The innermost middleware wraps hc.Client.Transport (or
+// http.DefaultTransport if that is nil). Middleware persists across
+// Reset.
+func (hc *HttpCall) Use(middleware ...Middleware) {
+	hc.middleware = append(hc.middleware, middleware...)
+}
+
+// client returns hc.Client, or, if hc has middleware, a copy of it
+// whose Transport is wrapped in the middleware.
+func (hc *HttpCall) client() *http.Client {
+	if len(hc.middleware) == 0 {
+		return hc.Client
+	}
+	client := *hc.Client
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for idx := len(hc.middleware) - 1; idx >= 0; idx-- {
+		transport = hc.middleware[idx](transport)
+	}
+	client.Transport = transport
+	return &client
+}
+
+// BeforeSend adds hooks which are run, in order, on hc.Request just
+// before it is sent (inside EnsureResponse), after all the steps which
+// build the request have run. They allow late-bound values such as
+// timestamps, nonces and signatures over the final body to be added.
+// The request is modified in place, so dumps and curl commands show
+// it as sent; requests replayed from it (as by LatencyPercentiles)
+// are not hooked again. If a hook errors, the request is not sent.
+// Hooks persist across Reset.
+func (hc *HttpCall) BeforeSend(hooks ...func(*http.Request) error) {
+	hc.beforeSend = append(hc.beforeSend, hooks...)
+}
+
+// runBeforeSend runs the BeforeSend hooks on hc.Request.
+func (hc *HttpCall) runBeforeSend() error {
+	for idx, hook := range hc.beforeSend {
+		if err := hook(hc.Request); err != nil {
+			return fmt.Errorf("BeforeSend hook %d: %v", idx+1, err)
+		}
+	}
+	return nil
+}
+
+// RequestBodyBytes returns the body of req without consuming it: if
+// the body cannot be re-read (see http.Request.GetBody), it is read
+// and replaced with one that can. It is intended for BeforeSend hooks
+// and Middleware which sign or digest the body.
+func RequestBodyBytes(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return []byte{}, nil
+	} else if req.GetBody != nil {
+		if body, err := req.GetBody(); err != nil {
+			return nil, err
+		} else {
+			defer body.Close()
+			return ioutil.ReadAll(body)
+		}
+	}
+	bites, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.GetBody = func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(bites)), nil }
+	req.Body, _ = req.GetBody()
+	req.ContentLength = int64(len(bites))
+	return bites, nil
+}
@@ -0,0 +1,108 @@
+package argot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Order")))
+	}))
+	defer server.Close()
+
+	order := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				req = req.Clone(req.Context())
+				req.Header.Add("X-Order", name)
+				req.Header.Set("X-Order", strings.Join(req.Header.Values("X-Order"), ","))
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	client := &http.Client{}
+	hc := NewHttpCall(client)
+	defer hc.Reset()
+	hc.Use(order("outer"), order("inner"))
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseBodyEquals("outer,inner"),
+		// Middleware persists across requests.
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseBodyEquals("outer,inner"),
+	}.Test(t)
+	if hc.Client != client || client.Transport != nil {
+		t.Fatal("Expected Use not to replace or modify the client.")
+	}
+
+	hc.Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("injected fault")
+		})
+	})
+	err := Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseStatusEquals(200),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "injected fault") {
+		t.Fatalf("Expected the injected fault; found %v.", err)
+	}
+}
+
+func TestBeforeSend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		digest := sha256.Sum256(append([]byte(r.Header.Get("X-Nonce")), body...))
+		if r.Header.Get("X-Signature") != hex.EncodeToString(digest[:]) {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	nonce := 0
+	hc.BeforeSend(func(req *http.Request) error {
+		nonce++
+		req.Header.Set("X-Nonce", strings.Repeat("n", nonce))
+		return nil
+	}, func(req *http.Request) error {
+		if req.Header.Get("Content-Type") != "text/plain" {
+			return errors.New("no content type")
+		} else if body, err := RequestBodyBytes(req); err != nil {
+			return err
+		} else {
+			digest := sha256.Sum256(append([]byte(req.Header.Get("X-Nonce")), body...))
+			req.Header.Set("X-Signature", hex.EncodeToString(digest[:]))
+			return nil
+		}
+	})
+	Steps{
+		hc.NewRequest("POST", server.URL, ioutil.NopCloser(strings.NewReader("signed"))),
+		hc.RequestHeader("Content-Type", "text/plain"),
+		hc.ResponseStatusEquals(200),
+		hc.ResponseBodyEquals("signed"),
+		hc.NewRequest("POST", server.URL, strings.NewReader("again")),
+		hc.RequestHeader("Content-Type", "text/plain"),
+		hc.ResponseStatusEquals(200),
+	}.Test(t)
+	if nonce := hc.Request.Header.Get("X-Nonce"); nonce != "nn" {
+		t.Fatalf("Expected the hooks to modify hc.Request: Expected nonce nn; found %s.", nonce)
+	}
+
+	err := Steps{
+		hc.NewRequest("POST", server.URL, strings.NewReader("unsigned")),
+		hc.ResponseStatusEquals(200),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "BeforeSend hook 2: no content type") {
+		t.Fatalf("Expected the hook's error; found %v.", err)
+	}
+}
@@ -0,0 +1,224 @@
+package argot
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MQTTMessage is a message published to, or received from, an MQTT
+// broker.
+type MQTTMessage struct {
+	Topic    string
+	Payload  []byte
+	QoS      byte
+	Retained bool
+}
+
+// MQTTClient is the subset of MQTT operations used by MQTTCall. argot
+// does not bundle an MQTT implementation: wrap the client of your MQTT
+// library of choice (for example github.com/eclipse/paho.mqtt.golang)
+// in a few lines. The client should already be connected.
+type MQTTClient interface {
+	// Publish publishes payload to topic.
+	Publish(topic string, payload []byte, qos byte, retained bool) error
+	// Subscribe subscribes to the topic filter, which may contain
+	// wildcards, calling handler for every message received. Retained
+	// messages must be delivered with Retained set.
+	Subscribe(filter string, qos byte, handler func(*MQTTMessage)) error
+	// Unsubscribe removes the subscription to the topic filter.
+	Unsubscribe(filter string) error
+}
+
+// MQTTCall provides publish, subscribe and expect-message steps for
+// an MQTT broker. Messages received on any subscription are buffered
+// until consumed by ExpectMessage.
+type MQTTCall struct {
+	Client MQTTClient
+
+	lock     sync.Mutex
+	cond     *sync.Cond
+	received []*MQTTMessage
+}
+
+// NewMQTTCall creates a new MQTTCall using client.
+func NewMQTTCall(client MQTTClient) *MQTTCall {
+	return &MQTTCall{Client: client}
+}
+
+// condition returns the condition signalled when a message is
+// received, creating it if necessary. mc.lock must be held.
+func (mc *MQTTCall) condition() *sync.Cond {
+	if mc.cond == nil {
+		mc.cond = sync.NewCond(&mc.lock)
+	}
+	return mc.cond
+}
+
+func (mc *MQTTCall) deliver(msg *MQTTMessage) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	mc.received = append(mc.received, msg)
+	mc.condition().Broadcast()
+}
+
+// Received returns the messages received but not yet consumed.
+func (mc *MQTTCall) Received() []*MQTTMessage {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	return append([]*MQTTMessage{}, mc.received...)
+}
+
+// Subscribe is a Step that when executed subscribes to the topic
+// filter with the given QoS. Subscribe before performing the action
+// which should cause messages to be published.
+func (mc *MQTTCall) Subscribe(filter string, qos byte) Step {
+	return NewNamedStep(fmt.Sprintf("Subscribe(%s: QoS %d)", filter, qos), func() error {
+		return mc.Client.Subscribe(filter, qos, mc.deliver)
+	})
+}
+
+// Unsubscribe is a Step that when executed unsubscribes from the
+// topic filter.
+func (mc *MQTTCall) Unsubscribe(filter string) Step {
+	return NewNamedStep(fmt.Sprintf("Unsubscribe(%s)", filter), func() error {
+		return mc.Client.Unsubscribe(filter)
+	})
+}
+
+// Publish is a Step that when executed publishes payload to topic.
+func (mc *MQTTCall) Publish(topic string, payload []byte, qos byte, retained bool) Step {
+	return NewNamedStep(fmt.Sprintf("Publish(%s: QoS %d, retained %v)", topic, qos, retained), func() error {
+		return mc.Client.Publish(topic, payload, qos, retained)
+	})
+}
+
+// ClearRetained is a Step that when executed removes the retained
+// message of topic, by publishing an empty retained message.
+func (mc *MQTTCall) ClearRetained(topic string) Step {
+	return NewNamedStep(fmt.Sprintf("ClearRetained(%s)", topic), func() error {
+		return mc.Client.Publish(topic, []byte{}, 1, true)
+	})
+}
+
+// MQTTTopicMatches reports whether topic matches the MQTT topic
+// filter, which may contain the single level (+) and multi level (#)
+// wildcards.
+func MQTTTopicMatches(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for idx, level := range filterLevels {
+		if level == "#" {
+			return true
+		} else if idx >= len(topicLevels) {
+			return false
+		} else if level != "+" && level != topicLevels[idx] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+// MQTTMatcher returns nil iff the message matches.
+type MQTTMatcher func(msg *MQTTMessage) error
+
+// MQTTPayloadContains matches messages whose payload contains value
+// using strings.Contains.
+func MQTTPayloadContains(value string) MQTTMatcher {
+	return func(msg *MQTTMessage) error {
+		if !strings.Contains(string(msg.Payload), value) {
+			return fmt.Errorf("Payload: Expected '%s'; found '%s'.", value, string(msg.Payload))
+		}
+		return nil
+	}
+}
+
+// MQTTPayloadJSONMatchesStruct matches messages whose payload, parsed
+// as JSON based on the type of expected, is equal to expected as
+// validated by the pretty package.
+func MQTTPayloadJSONMatchesStruct(expected interface{}) MQTTMatcher {
+	return func(msg *MQTTMessage) error {
+		parseAs := reflect.New(reflect.TypeOf(expected)).Interface()
+		if err := json.Unmarshal(msg.Payload, parseAs); err != nil {
+			return err
+		} else if err := compareError(parseAs, expected); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// MQTTQoS matches messages delivered with the given QoS.
+func MQTTQoS(qos byte) MQTTMatcher {
+	return func(msg *MQTTMessage) error {
+		if msg.QoS != qos {
+			return fmt.Errorf("QoS: Expected %d; found %d.", qos, msg.QoS)
+		}
+		return nil
+	}
+}
+
+// MQTTRetained matches messages whose retained flag equals retained.
+func MQTTRetained(retained bool) MQTTMatcher {
+	return func(msg *MQTTMessage) error {
+		if msg.Retained != retained {
+			return fmt.Errorf("Retained: Expected %v; found %v.", retained, msg.Retained)
+		}
+		return nil
+	}
+}
+
+// ExpectMessage is a Step that when executed waits up to timeout for
+// a message, received on a topic matching filter, which satisfies
+// every matcher. The matching message is consumed.
+func (mc *MQTTCall) ExpectMessage(filter string, timeout time.Duration, matchers ...MQTTMatcher) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectMessage(%s)", filter), func() error {
+		mc.lock.Lock()
+		defer mc.lock.Unlock()
+		var lastErr error
+		if waitForCond(mc.condition(), timeout, func() bool {
+			for idx, msg := range mc.received {
+				if !MQTTTopicMatches(filter, msg.Topic) {
+					continue
+				}
+				if lastErr = matchMQTT(msg, matchers); lastErr == nil {
+					mc.received = append(mc.received[:idx], mc.received[idx+1:]...)
+					return true
+				}
+			}
+			return false
+		}) {
+			return nil
+		} else if lastErr == nil {
+			return fmt.Errorf("Topic '%s': No message received within %v.", filter, timeout)
+		} else {
+			return fmt.Errorf("Topic '%s': No matching message received within %v. Last mismatch: %v", filter, timeout, lastErr)
+		}
+	})
+}
+
+// ExpectNoMessage is a Step that when executed waits for wait and
+// errors if any message has been received on a topic matching filter.
+func (mc *MQTTCall) ExpectNoMessage(filter string, wait time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectNoMessage(%s)", filter), func() error {
+		time.Sleep(wait)
+		for _, msg := range mc.Received() {
+			if MQTTTopicMatches(filter, msg.Topic) {
+				return fmt.Errorf("Topic '%s': Expected no message; found '%s' on %s.", filter, string(msg.Payload), msg.Topic)
+			}
+		}
+		return nil
+	})
+}
+
+func matchMQTT(msg *MQTTMessage, matchers []MQTTMatcher) error {
+	for _, matcher := range matchers {
+		if err := matcher(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
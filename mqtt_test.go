@@ -0,0 +1,99 @@
+package argot
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryMQTT is an MQTTClient which acts as its own broker.
+type memoryMQTT struct {
+	lock     sync.Mutex
+	subs     map[string]func(*MQTTMessage)
+	retained map[string]*MQTTMessage
+}
+
+func (m *memoryMQTT) Publish(topic string, payload []byte, qos byte, retained bool) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	msg := &MQTTMessage{Topic: topic, Payload: payload, QoS: qos}
+	if retained && len(payload) == 0 {
+		delete(m.retained, topic)
+	} else if retained {
+		m.retained[topic] = &MQTTMessage{Topic: topic, Payload: payload, QoS: qos, Retained: true}
+	}
+	for filter, handler := range m.subs {
+		if MQTTTopicMatches(filter, topic) {
+			handler(msg)
+		}
+	}
+	return nil
+}
+
+func (m *memoryMQTT) Subscribe(filter string, qos byte, handler func(*MQTTMessage)) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.subs[filter] = handler
+	for topic, msg := range m.retained {
+		if MQTTTopicMatches(filter, topic) {
+			handler(msg)
+		}
+	}
+	return nil
+}
+
+func (m *memoryMQTT) Unsubscribe(filter string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.subs, filter)
+	return nil
+}
+
+func TestMQTTTopicMatches(t *testing.T) {
+	for _, c := range []struct {
+		filter, topic string
+		matches       bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a/b/c", true},
+		{"a/#", "a", true},
+		{"+/b", "a/c", false},
+	} {
+		if MQTTTopicMatches(c.filter, c.topic) != c.matches {
+			t.Errorf("MQTTTopicMatches(%q, %q): expected %v", c.filter, c.topic, c.matches)
+		}
+	}
+}
+
+func TestMQTTCall(t *testing.T) {
+	broker := &memoryMQTT{subs: make(map[string]func(*MQTTMessage)), retained: make(map[string]*MQTTMessage)}
+	mc := NewMQTTCall(broker)
+
+	Steps{
+		mc.Publish("devices/1/state", []byte(`{"on":true}`), 1, true),
+		mc.Subscribe("devices/+/state", 1),
+		mc.ExpectMessage("devices/1/state", time.Second, MQTTRetained(true), MQTTPayloadContains(`"on":true`)),
+		mc.Publish("devices/2/state", []byte("off"), 0, false),
+		mc.ExpectMessage("devices/#", time.Second, MQTTRetained(false), MQTTQoS(0)),
+		mc.ExpectNoMessage("devices/#", 0),
+		mc.ClearRetained("devices/1/state"),
+		mc.ExpectMessage("devices/1/state", time.Second),
+		mc.Unsubscribe("devices/+/state"),
+		mc.Subscribe("devices/1/state", 1),
+		ExpectError(mc.ExpectMessage("devices/1/state", 20*time.Millisecond)),
+	}.Test(t)
+}
+
+func TestMQTTCallLiteral(t *testing.T) {
+	broker := &memoryMQTT{subs: make(map[string]func(*MQTTMessage)), retained: make(map[string]*MQTTMessage)}
+	mc := &MQTTCall{Client: broker}
+
+	Steps{
+		mc.Subscribe("devices/+/state", 1),
+		mc.Publish("devices/1/state", []byte("on"), 1, false),
+		mc.ExpectMessage("devices/1/state", time.Second, MQTTPayloadContains("on")),
+		ExpectError(mc.ExpectMessage("devices/1/state", 20*time.Millisecond)),
+	}.Test(t)
+}
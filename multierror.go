@@ -0,0 +1,79 @@
+package argot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StepFailure is the failure of one of the steps run by a combinator
+// such as Concurrently or AllOf.
+type StepFailure struct {
+	// Index is the position of the step amongst those the combinator
+	// ran, counting from zero.
+	Index int
+	Step  Step
+	Err   error
+}
+
+// MultiError is the error returned by combinators which run several
+// steps and report every failure rather than only the first, such as
+// Concurrently and AllOf. The errors are available through
+// errors.Is and errors.As, which search each of them.
+type MultiError struct {
+	// Message summarises the failures, for example "2 of 5 concurrent
+	// steps failed".
+	Message  string
+	Failures []StepFailure
+}
+
+func (e *MultiError) Error() string {
+	lines := make([]string, len(e.Failures))
+	for idx, failure := range e.Failures {
+		lines[idx] = fmt.Sprintf("\t[%d] %v: %v", failure.Index, failure.Step, strings.Replace(failure.Err.Error(), "\n", "\n\t", -1))
+	}
+	return e.Message + ":\n" + strings.Join(lines, "\n")
+}
+
+// Unwrap returns the error of every failed step.
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for idx, failure := range e.Failures {
+		errs[idx] = failure.Err
+	}
+	return errs
+}
+
+// format renders each failure beneath the step which produced it, for
+// formatFatalSteps.
+func (e *MultiError) format() string {
+	msg := e.Message + ":"
+	for _, failure := range e.Failures {
+		msg += fmt.Sprintf("\n[%d] %v:\n\t%s", failure.Index, failure.Step, strings.Replace(failure.Err.Error(), "\n", "\n\t", -1))
+	}
+	return msg
+}
+
+// AllOf is a Step that when executed runs every one of steps in order,
+// continuing past failures, as soft assertions do, and errors with a
+// MultiError listing every failure, should any step fail. This is
+// useful where several independent properties of a response are
+// checked, so that one run reveals every one which is wrong.
+func AllOf(steps ...Step) Step {
+	names := make([]string, len(steps))
+	for idx, step := range steps {
+		names[idx] = fmt.Sprint(step)
+	}
+	return NewNamedStep(fmt.Sprintf("AllOf(%s)", strings.Join(names, ", ")), func() error {
+		failures := []StepFailure{}
+		for idx, step := range steps {
+			if err := step.Go(); err != nil {
+				failures = append(failures, StepFailure{Index: idx, Step: step, Err: err})
+			}
+		}
+		if len(failures) == 0 {
+			return nil
+		} else {
+			return &MultiError{Message: fmt.Sprintf("%d of %d steps failed", len(failures), len(steps)), Failures: failures}
+		}
+	})
+}
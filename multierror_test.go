@@ -0,0 +1,37 @@
+package argot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAllOf(t *testing.T) {
+	sentinel := errors.New("Expected 200; found 404.")
+	ran := 0
+	ok := NewNamedStep("ok", func() error { ran++; return nil })
+	fail := NewNamedStep("fail", func() error { ran++; return sentinel })
+	multiline := NewNamedStep("multiline", func() error { ran++; return errors.New("first\nsecond") })
+
+	if err := AllOf(ok, ok).Go(); err != nil {
+		t.Fatal(err)
+	}
+	ran = 0
+	err := AllOf(fail, ok, multiline).Go()
+	var multi *MultiError
+	if ran != 3 {
+		t.Fatalf("Expected every step to run; %d ran.", ran)
+	} else if !errors.As(err, &multi) || len(multi.Failures) != 2 || multi.Failures[1].Index != 2 {
+		t.Fatalf("Unexpected error: %#v", err)
+	} else if !errors.Is(err, sentinel) {
+		t.Fatal("Expected errors.Is to find the error of a failed step.")
+	} else if err.Error() != "2 of 3 steps failed:\n\t[0] fail: Expected 200; found 404.\n\t[2] multiline: first\n\tsecond" {
+		t.Fatalf("Unexpected message: %q", err.Error())
+	}
+
+	step := AllOf(fail, multiline)
+	msg := formatFatalSteps(Steps{ok, step}, step.Go())
+	if !strings.Contains(msg, "Error: 2 of 2 steps failed:\n[0] fail:\n\tExpected 200; found 404.\n[1] multiline:\n\tfirst\n\tsecond") {
+		t.Fatalf("Unexpected message:\n%s", msg)
+	}
+}
@@ -0,0 +1,166 @@
+package argot
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NamespaceKey is the key under which Namespace.Begin sets the prefix
+// in a Store, so that it can be interpolated as ${namespace}.
+const NamespaceKey = "namespace"
+
+// Namespace isolates the test data of a scenario run from that of
+// every other run, including concurrent runs of the same suite against
+// a shared environment, by giving it a unique prefix, such as
+// "argot-3f9a1c2e". Rather than fixed identifiers, which collide,
+// scenarios name the data they create with the prefix, either with
+// Name or by interpolating ${namespace} (see Begin and
+// Store.Interpolate), and can then assert, once they have cleaned up,
+// that nothing under the prefix remains (see ExpectNone and
+// ResponseExcludesNamespace). The prefix is chosen afresh by each
+// execution of Begin, so steps built once may be run many times. A
+// Namespace is safe for concurrent use.
+type Namespace struct {
+	// The start of every prefix, for example "argot".
+	Base string
+
+	lock   sync.Mutex
+	prefix string
+}
+
+// NewNamespace creates a new Namespace whose prefixes start with base,
+// or "argot" if base is empty, with a prefix already chosen.
+func NewNamespace(base string) *Namespace {
+	if base == "" {
+		base = "argot"
+	}
+	ns := &Namespace{Base: base}
+	ns.renew()
+	return ns
+}
+
+// renew chooses a new prefix.
+func (ns *Namespace) renew() string {
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	ns.lock.Lock()
+	defer ns.lock.Unlock()
+	ns.prefix = fmt.Sprintf("%s-%x", ns.Base, buf)
+	return ns.prefix
+}
+
+// Prefix returns the current prefix.
+func (ns *Namespace) Prefix() string {
+	ns.lock.Lock()
+	defer ns.lock.Unlock()
+	return ns.prefix
+}
+
+func (ns *Namespace) String() string {
+	return ns.Prefix()
+}
+
+// Name returns name within the namespace: the current prefix and name
+// joined with a hyphen.
+func (ns *Namespace) Name(name string) string {
+	return ns.Prefix() + "-" + name
+}
+
+// Owns returns true iff str contains the current prefix.
+func (ns *Namespace) Owns(str string) bool {
+	return strings.Contains(str, ns.Prefix())
+}
+
+// Begin is a Step that when executed chooses a new prefix and, if
+// store is non-nil, sets it as NamespaceKey in store. It should be the
+// first step of a scenario.
+func (ns *Namespace) Begin(store *Store) Step {
+	return NewNamedStep(fmt.Sprintf("NamespaceBegin(%s)", ns.Base), func() error {
+		prefix := ns.renew()
+		if store != nil {
+			store.Set(NamespaceKey, prefix)
+		}
+		return nil
+	})
+}
+
+// ExpectNone is a Step that when executed calls list, which should
+// return the names or identifiers of the test data remaining in the
+// system under test (for example the rows of a table), and errors,
+// listing them, if any are owned by the namespace (see Owns).
+func (ns *Namespace) ExpectNone(what string, list func() ([]string, error)) Step {
+	return NewNamedStep(fmt.Sprintf("NamespaceExpectNone(%s)", what), func() error {
+		names, err := list()
+		if err != nil {
+			return fmt.Errorf("Namespace %s: %s: %v", ns.Prefix(), what, err)
+		}
+		remaining := []string{}
+		for _, name := range names {
+			if ns.Owns(name) {
+				remaining = append(remaining, name)
+			}
+		}
+		if len(remaining) != 0 {
+			return fmt.Errorf("Namespace %s: %s: Expected none to remain; found:\n\t%s", ns.Prefix(), what, strings.Join(remaining, "\n\t"))
+		}
+		return nil
+	})
+}
+
+// namespaceMatches appends to matches the path of every string within
+// value, a decoded JSON document, which ns owns.
+func (ns *Namespace) namespaceMatches(path string, value interface{}, matches []string) []string {
+	switch value := value.(type) {
+	case string:
+		if ns.Owns(value) {
+			matches = append(matches, fmt.Sprintf("%s: '%s'", path, value))
+		}
+	case []interface{}:
+		for idx, elem := range value {
+			matches = ns.namespaceMatches(fmt.Sprintf("%s[%d]", path, idx), elem, matches)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if ns.Owns(key) {
+				matches = append(matches, fmt.Sprintf("%s: key '%s'", path, key))
+			}
+			matches = ns.namespaceMatches(path+"."+key, value[key], matches)
+		}
+	}
+	return matches
+}
+
+// ResponseExcludesNamespace is a Step that when executed ensures there
+// is a non-nil hc.ResponseBody, typically a listing or search of the
+// resources the scenario created, and errors if it mentions the
+// namespace's prefix. If the body is JSON, the JSON path of each
+// string (or object key) containing the prefix is given.
+func (hc *HttpCall) ResponseExcludesNamespace(ns *Namespace) Step {
+	return hc.step("ResponseExcludesNamespace", func() error {
+		if err := hc.ReceiveBody(); err != nil {
+			return err
+		}
+		prefix := ns.Prefix()
+		if !bytes.Contains(hc.ResponseBody, []byte(prefix)) {
+			return nil
+		}
+		var doc interface{}
+		if err := json.Unmarshal(hc.ResponseBody, &doc); err == nil {
+			if matches := ns.namespaceMatches("$", doc, nil); len(matches) != 0 {
+				return fmt.Errorf("Namespace %s: Expected none to remain; found:\n\t%s", prefix, strings.Join(matches, "\n\t"))
+			}
+			return nil
+		}
+		return fmt.Errorf("Namespace %s: Expected none to remain; found %d mentions in the body.", prefix, bytes.Count(hc.ResponseBody, []byte(prefix)))
+	})
+}
@@ -0,0 +1,109 @@
+package argot
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNamespace(t *testing.T) {
+	var lock sync.Mutex
+	teas := []string{"assam"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if r.Method == http.MethodPost {
+			teas = append(teas, r.URL.Query().Get("name"))
+			w.WriteHeader(http.StatusCreated)
+		} else if r.URL.Path == "/text" {
+			w.Write([]byte(strings.Join(teas, "\n")))
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"items":[{"name":"` + strings.Join(teas, `"},{"name":"`) + `"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	ns := NewNamespace("")
+	if !regexp.MustCompile(`^argot-[0-9a-f]{8}$`).MatchString(ns.Prefix()) {
+		t.Fatalf("Unexpected prefix: %s", ns.Prefix())
+	}
+	store := NewStore()
+	hc := NewHttpCall(nil)
+	first := ""
+	steps := Steps{
+		ns.Begin(store),
+		NewNamedStep("Remember", func() error {
+			first = store.GetString(NamespaceKey)
+			return nil
+		}),
+		NewNamedStep("Create", func() error {
+			urlStr, err := store.Interpolate(server.URL + "/teas?name=${namespace}-green")
+			if err != nil {
+				return err
+			}
+			return hc.NewRequest(http.MethodPost, urlStr, nil).Go()
+		}),
+		hc.ResponseStatusEquals(http.StatusCreated),
+		hc.NewRequest(http.MethodGet, server.URL+"/teas", nil),
+		hc.ResponseExcludesNamespace(ns),
+	}
+	err := steps.Go()
+	if hcErr, ok := err.(*HttpCallError); !ok ||
+		hcErr.Err.Error() != "Namespace "+first+": Expected none to remain; found:\n\t$.items[1].name: '"+first+"-green'" {
+		t.Fatalf("Expected the remaining tea to be reported; found %v", err)
+	} else if first != ns.Prefix() || ns.Name("green") != first+"-green" {
+		t.Fatalf("Expected the store to hold the prefix; found %s and %s", first, ns.Prefix())
+	}
+	err = Steps{hc.NewRequest(http.MethodGet, server.URL+"/text", nil), hc.ResponseExcludesNamespace(ns)}.Go()
+	if err == nil || !strings.Contains(err.Error(), "found 1 mentions in the body.") {
+		t.Fatalf("Expected the remaining tea to be reported; found %v", err)
+	}
+
+	// A new run has a new prefix, so is unaffected by the data of the
+	// last.
+	last := first
+	if err := steps.Go(); err == nil {
+		t.Fatal("Expected the run's own tea to be reported.")
+	} else if last == ns.Prefix() || first != ns.Prefix() {
+		t.Fatalf("Expected a new prefix; found %s again", last)
+	}
+	lock.Lock()
+	teas = teas[:2]
+	lock.Unlock()
+	if err := (Steps{hc.NewRequest(http.MethodGet, server.URL+"/teas", nil), hc.ResponseExcludesNamespace(ns)}).Go(); err != nil {
+		t.Fatal(err)
+	}
+
+	list := func() ([]string, error) { return []string{"assam", ns.Name("green")}, nil }
+	if err := ns.ExpectNone("teas", list).Go(); err == nil || !strings.HasSuffix(err.Error(), "teas: Expected none to remain; found:\n\t"+ns.Name("green")) {
+		t.Fatalf("Expected the remaining tea to be reported; found %v", err)
+	}
+	if err := ns.ExpectNone("teas", func() ([]string, error) { return nil, errors.New("Boom") }).Go(); err == nil || !strings.HasSuffix(err.Error(), "teas: Boom") {
+		t.Fatalf("Expected the error to be reported; found %v", err)
+	}
+}
+
+func TestScenarioNamespace(t *testing.T) {
+	names := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		names = append(names, r.URL.Query().Get("name"))
+	}))
+	defer server.Close()
+
+	sc := &Scenario{Name: "create", Steps: []*ScenarioStep{{Request: &ScenarioRequest{Method: http.MethodPost, URL: server.URL + "/teas?name=${namespace}-green"}, Expect: &ScenarioExpect{Status: http.StatusOK}}}}
+	store := NewStore()
+	steps := sc.Build(NewHttpCall(nil), store)
+	for idx := 0; idx < 2; idx++ {
+		if err := steps.Go(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(names) != 2 || names[0] == names[1] || names[1] != store.GetString(NamespaceKey)+"-green" {
+		t.Fatalf("Expected each run to have its own namespace; found %v", names)
+	}
+}
@@ -0,0 +1,90 @@
+package argot
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// validateNDJSONLine validates one line of newline delimited JSON
+// against schema. Blank lines are valid.
+func validateNDJSONLine(schema *gojsonschema.Schema, lineNo int, line []byte) error {
+	if line = bytes.TrimSpace(line); len(line) == 0 {
+		return nil
+	} else if result, err := schema.Validate(gojsonschema.NewBytesLoader(line)); err != nil {
+		return fmt.Errorf("NDJSON: Line %d: %v", lineNo, err)
+	} else if !result.Valid() {
+		return errors.New(jsonSchemaFailure(fmt.Sprintf("NDJSON: Line %d: ", lineNo), result.Errors()))
+	} else {
+		return nil
+	}
+}
+
+// ndjsonLines calls fn with each line of r, numbered from one, without
+// its line ending. Lines may be of any length.
+func ndjsonLines(r io.Reader, fn func(lineNo int, line []byte) error) error {
+	reader := bufio.NewReader(r)
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		} else if len(line) > 0 {
+			if err := fn(lineNo, bytes.TrimRight(line, "\r\n")); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// ResponseBodyNDJSONEachMatchesSchema is a Step that when executed
+// ensures the body has been received and, treating it as newline
+// delimited JSON (JSON lines), errors unless every line can be
+// validated against the schema parameter using gojsonschema. Each line
+// is validated independently, and the error gives the number of the
+// first line which fails. Blank lines are ignored. Spooled bodies (see
+// SpoolThreshold) are read from disk line by line.
+func (hc *HttpCall) ResponseBodyNDJSONEachMatchesSchema(schema string) Step {
+	return hc.step("ResponseBodyNDJSONEachMatchesSchema", func() error {
+		hc.cover("body:")
+		compiled, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema))
+		if err != nil {
+			return err
+		}
+		_, err = hc.streamBody(func(r io.Reader) (bool, error) {
+			return true, ndjsonLines(r, func(lineNo int, line []byte) error {
+				return validateNDJSONLine(compiled, lineNo, line)
+			})
+		})
+		return err
+	})
+}
+
+// ResponseBodyNDJSONCount is a Step that when executed ensures the body
+// has been received and, treating it as newline delimited JSON, errors
+// unless it contains exactly n records (non-blank lines).
+func (hc *HttpCall) ResponseBodyNDJSONCount(n int) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyNDJSONCount(%d)", n), func() error {
+		count := 0
+		if _, err := hc.streamBody(func(r io.Reader) (bool, error) {
+			return true, ndjsonLines(r, func(lineNo int, line []byte) error {
+				if len(bytes.TrimSpace(line)) > 0 {
+					count++
+				}
+				return nil
+			})
+		}); err != nil {
+			return err
+		} else if count != n {
+			return fmt.Errorf("NDJSON: Expected %d records; found %d.", n, count)
+		} else {
+			return nil
+		}
+	})
+}
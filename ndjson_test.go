@@ -0,0 +1,47 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseBodyNDJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bad":
+			w.Write([]byte("{\"id\": 1}\n{\"id\": \"two\"}\n{\"id\": 3}\n"))
+		case "/invalid":
+			w.Write([]byte("{\"id\": 1}\n{\"id\": 2}\n{\"id\"\n"))
+		default:
+			w.Write([]byte("{\"id\": 1}\r\n\n{\"id\": 2, \"padding\": \"" + strings.Repeat("x", 128*1024) + "\"}\n{\"id\": 3}"))
+		}
+	}))
+	defer server.Close()
+	schema := `{"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}}}`
+
+	for _, threshold := range []int64{0, 1024} {
+		hc := NewHttpCall(nil)
+		hc.SpoolThreshold = threshold
+		Steps{
+			hc.NewRequest("GET", server.URL, nil),
+			hc.ResponseBodyNDJSONEachMatchesSchema(schema),
+			hc.ResponseBodyNDJSONCount(3),
+			ExpectError(hc.ResponseBodyNDJSONCount(4)),
+		}.Test(t)
+		hc.Reset()
+	}
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	for path, line := range map[string]string{"/bad": "Line 2", "/invalid": "Line 3"} {
+		err := Steps{
+			hc.NewRequest("GET", server.URL+path, nil),
+			hc.ResponseBodyNDJSONEachMatchesSchema(schema),
+		}.Go()
+		if err == nil || !strings.Contains(err.Error(), "NDJSON: "+line) {
+			t.Fatalf("%s: Expected %s to fail; found %v.", path, line, err)
+		}
+	}
+}
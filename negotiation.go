@@ -0,0 +1,96 @@
+package argot
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// NegotiationVariant is an Accept header value to request, and the
+// response expected for it. See HttpCall.Negotiate.
+type NegotiationVariant struct {
+	// Accept is sent as the Accept header. If empty, no Accept header
+	// is sent.
+	Accept string
+	// Status is the expected status. If zero, 200 is expected, unless
+	// NotAcceptable.
+	Status int
+	// NotAcceptable is true if the server is expected to refuse the
+	// Accept value with 406 Not Acceptable.
+	NotAcceptable bool
+	// ContentType, if non-empty, is the media type expected in the
+	// Content-Type of the response. Parameters, such as charset, are
+	// ignored.
+	ContentType string
+	// Steps are run against the response, for example to check that
+	// an XML body is well formed.
+	Steps Steps
+}
+
+// UnsupportedMediaType is an Accept value no server should support,
+// for a NegotiationVariant which is expected to be NotAcceptable.
+const UnsupportedMediaType = "application/x-argot-unsupported"
+
+func (nv NegotiationVariant) status() int {
+	if nv.Status != 0 {
+		return nv.Status
+	} else if nv.NotAcceptable {
+		return http.StatusNotAcceptable
+	} else {
+		return http.StatusOK
+	}
+}
+
+func (hc *HttpCall) checkNegotiationVariant(method, urlStr string, variant NegotiationVariant) error {
+	steps := Steps{hc.NewRequest(method, urlStr, nil)}
+	if variant.Accept != "" {
+		steps = append(steps, hc.RequestHeader("Accept", variant.Accept))
+	}
+	steps = append(steps, hc.ResponseStatusEquals(variant.status()))
+	if err := steps.Go(); err != nil {
+		return err
+	}
+	if variant.ContentType != "" {
+		hc.coverHeader("Content-Type")
+		contentType := hc.Response.Header.Get("Content-Type")
+		if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || !strings.EqualFold(mediaType, variant.ContentType) {
+			return fmt.Errorf("Content-Type: Expected %s; found '%s'.", variant.ContentType, contentType)
+		}
+	}
+	return variant.Steps.Go()
+}
+
+// Negotiate is a Step that when executed makes the request (without a
+// body) once for each variant, with the variant's Accept header, and
+// errors at the first whose response does not have the variant's
+// status and Content-Type, or for which the variant's Steps error. For
+// example:
+//
+//	hc.Negotiate("GET", url,
+//		argot.NegotiationVariant{Accept: "application/json", ContentType: "application/json",
+//			Steps: argot.Steps{hc.ResponseBodyJSONPathExists("id")}},
+//		argot.NegotiationVariant{Accept: "application/xml", ContentType: "application/xml"},
+//		argot.NegotiationVariant{Accept: "text/html", ContentType: "text/html"},
+//		argot.NegotiationVariant{Accept: argot.UnsupportedMediaType, NotAcceptable: true},
+//	)
+//
+// hc is left holding the response to the last variant run.
+func (hc *HttpCall) Negotiate(method, urlStr string, variants ...NegotiationVariant) Step {
+	accepts := make([]string, len(variants))
+	for idx, variant := range variants {
+		accepts[idx] = variant.Accept
+	}
+	name := fmt.Sprintf("Negotiate(%s %s: %s)", method, hc.redactor().String(urlStr), strings.Join(accepts, ", "))
+	return hc.step(name, func() error {
+		for _, variant := range variants {
+			if err := hc.checkNegotiationVariant(method, urlStr, variant); err != nil {
+				if hcErr, ok := err.(*HttpCallError); ok {
+					err = hcErr.Err
+				}
+				return fmt.Errorf("Accept '%s': %v", variant.Accept, err)
+			}
+		}
+		return nil
+	})
+}
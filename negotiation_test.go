@@ -0,0 +1,55 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Accept")
+		switch accept := r.Header.Get("Accept"); {
+		case accept == "" || strings.Contains(accept, "application/json"):
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"id": 1}`))
+		case strings.Contains(accept, "application/xml"):
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte(`<tea id="1"/>`))
+		case strings.Contains(accept, "text/html"):
+			// Wrongly sends JSON.
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id": 1}`))
+		default:
+			w.WriteHeader(http.StatusNotAcceptable)
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	json := NegotiationVariant{Accept: "application/json", ContentType: "application/json", Steps: Steps{hc.ResponseBodyJSONPathEquals("id", 1)}}
+	xml := NegotiationVariant{Accept: "application/xml", ContentType: "application/xml", Steps: Steps{hc.ResponseBodyContains(`<tea`)}}
+	html := NegotiationVariant{Accept: "text/html", ContentType: "text/html"}
+	unsupported := NegotiationVariant{Accept: UnsupportedMediaType, NotAcceptable: true}
+	Steps{
+		hc.Negotiate("GET", server.URL, json, xml, unsupported, NegotiationVariant{ContentType: "application/json"}),
+		hc.ResponseStatusEquals(http.StatusOK),
+	}.Test(t)
+
+	for variant, expected := range map[*NegotiationVariant]string{
+		&html:                          "Accept 'text/html': Content-Type: Expected text/html; found 'application/json'.",
+		{Accept: UnsupportedMediaType}: "Accept 'application/x-argot-unsupported': Status: Expected 200; found 406.",
+		{Accept: "application/json", NotAcceptable: true}:                            "Accept 'application/json': Status: Expected 406; found 200.",
+		{Accept: "application/xml", Steps: Steps{hc.ResponseBodyContains("coffee")}}: "Accept 'application/xml': Body: ",
+	} {
+		if _, err := (Steps{hc.Negotiate("GET", server.URL, json, *variant)}).Test(nil); err == nil {
+			t.Fatalf("Expected %s to error.", variant.Accept)
+		} else if hcErr, ok := err.(*HttpCallError); !ok {
+			t.Fatalf("Expected an HttpCallError; found %T.", err)
+		} else if !strings.HasPrefix(hcErr.Err.Error(), expected) {
+			t.Fatalf("Expected error starting %q; found %q.", expected, hcErr.Err.Error())
+		}
+	}
+}
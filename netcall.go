@@ -0,0 +1,223 @@
+package argot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"syscall"
+	"time"
+)
+
+// NetCall captures the state of a raw TCP or UDP connection, for
+// smoke-testing listeners which do not speak HTTP. Received data is
+// buffered: each Expect step consumes the buffer up to the end of
+// what it matched, so successive Expect steps work through the
+// stream in order. A NetCall can only be used by a single go-routine
+// at a time.
+type NetCall struct {
+	// The Dialer used to connect. If nil, a zero net.Dialer is used.
+	Dialer *net.Dialer
+	// The connection, once connected.
+	Conn net.Conn
+
+	received []byte
+	closed   bool
+}
+
+// NewNetCall creates a new NetCall.
+func NewNetCall() *NetCall {
+	return &NetCall{}
+}
+
+// Reset is idempotent. You should ensure this is called at the end of
+// life for each NetCall. It closes any connection.
+func (nc *NetCall) Reset() error {
+	if nc.Conn != nil {
+		nc.Conn.Close()
+	}
+	nc.Conn = nil
+	nc.received = nil
+	nc.closed = false
+	return nil
+}
+
+// Received returns the data received but not yet consumed by an
+// Expect step.
+func (nc *NetCall) Received() []byte {
+	return nc.received
+}
+
+func (nc *NetCall) dialer() *net.Dialer {
+	if nc.Dialer == nil {
+		return new(net.Dialer)
+	} else {
+		return nc.Dialer
+	}
+}
+
+// Connect is a Step that when executed will connect to address over
+// network ("tcp", "tcp4", "tcp6", "udp", "unix" etc., as for
+// net.Dial). The step will automatically call nc.Reset first.
+func (nc *NetCall) Connect(network, address string) Step {
+	return NewNamedStep(fmt.Sprintf("Connect(%s: %s)", network, address), func() error {
+		if err := nc.Reset(); err != nil {
+			return err
+		} else if conn, err := nc.dialer().Dial(network, address); err != nil {
+			return err
+		} else {
+			nc.Conn = conn
+			return nil
+		}
+	})
+}
+
+// ExpectRefused is a Step that when executed attempts to connect to
+// address over network and errors unless the connection is refused.
+// Any existing connection is left untouched.
+func (nc *NetCall) ExpectRefused(network, address string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectRefused(%s: %s)", network, address), func() error {
+		if conn, err := nc.dialer().Dial(network, address); err == nil {
+			conn.Close()
+			return fmt.Errorf("Connect: Expected %s to be refused; connected.", address)
+		} else if !errors.Is(err, syscall.ECONNREFUSED) {
+			return fmt.Errorf("Connect: Expected %s to be refused; found %v.", address, err)
+		} else {
+			return nil
+		}
+	})
+}
+
+func (nc *NetCall) assertConnected() error {
+	if nc.Conn == nil {
+		return errors.New("Not connected.")
+	} else {
+		return nil
+	}
+}
+
+// Send is a Step that when executed writes data to the connection.
+// For UDP connections data is sent as a single datagram.
+func (nc *NetCall) Send(data []byte) Step {
+	return NewNamedStep(fmt.Sprintf("Send(%d bytes)", len(data)), func() error {
+		if err := nc.assertConnected(); err != nil {
+			return err
+		}
+		_, err := nc.Conn.Write(data)
+		return err
+	})
+}
+
+// SendString is a Step that when executed writes str to the
+// connection.
+func (nc *NetCall) SendString(str string) Step {
+	return NewNamedStep(fmt.Sprintf("SendString(%q)", str), func() error {
+		if err := nc.assertConnected(); err != nil {
+			return err
+		}
+		_, err := io.WriteString(nc.Conn, str)
+		return err
+	})
+}
+
+// receiveUntil reads from the connection until match finds something
+// in the buffer, the connection is closed, or timeout elapses. On
+// success the buffer is consumed up to the end index returned by
+// match.
+func (nc *NetCall) receiveUntil(timeout time.Duration, match func([]byte) int) error {
+	if err := nc.assertConnected(); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	defer nc.Conn.SetReadDeadline(time.Time{})
+	buf := make([]byte, 64*1024)
+	for {
+		if end := match(nc.received); end >= 0 {
+			nc.received = nc.received[end:]
+			return nil
+		} else if nc.closed {
+			return fmt.Errorf("Connection closed; received %q.", nc.received)
+		}
+		nc.Conn.SetReadDeadline(deadline)
+		n, err := nc.Conn.Read(buf)
+		nc.received = append(nc.received, buf[:n]...)
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			if end := match(nc.received); end >= 0 {
+				nc.received = nc.received[end:]
+				return nil
+			}
+			return fmt.Errorf("Timed out after %v; received %q.", timeout, nc.received)
+		} else if err != nil {
+			nc.closed = true
+		}
+	}
+}
+
+// Expect is a Step that when executed errors unless data is received
+// on the connection within timeout. Anything received before data is
+// skipped.
+func (nc *NetCall) Expect(data []byte, timeout time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("Expect(%q)", data), func() error {
+		return nc.receiveUntil(timeout, func(received []byte) int {
+			if idx := bytes.Index(received, data); idx >= 0 {
+				return idx + len(data)
+			} else {
+				return -1
+			}
+		})
+	})
+}
+
+// ExpectString is a Step that when executed errors unless str is
+// received on the connection within timeout.
+func (nc *NetCall) ExpectString(str string, timeout time.Duration) Step {
+	return nc.Expect([]byte(str), timeout)
+}
+
+// ExpectMatch is a Step that when executed errors unless data
+// matching pattern is received on the connection within timeout.
+func (nc *NetCall) ExpectMatch(pattern *regexp.Regexp, timeout time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectMatch(%v)", pattern), func() error {
+		return nc.receiveUntil(timeout, func(received []byte) int {
+			if loc := pattern.FindIndex(received); loc != nil {
+				return loc[1]
+			} else {
+				return -1
+			}
+		})
+	})
+}
+
+// ExpectClosed is a Step that when executed errors unless the remote
+// end closes the connection within timeout. Any data received in the
+// meantime is consumed.
+func (nc *NetCall) ExpectClosed(timeout time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectClosed(%v)", timeout), func() error {
+		err := nc.receiveUntil(timeout, func(received []byte) int {
+			if nc.closed {
+				return len(received)
+			} else {
+				return -1
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("Expected the connection to be closed: %v", err)
+		} else {
+			return nil
+		}
+	})
+}
+
+// Close is a Step that when executed closes the connection.
+func (nc *NetCall) Close() Step {
+	return NewNamedStep("Close", func() error {
+		if err := nc.assertConnected(); err != nil {
+			return err
+		}
+		err := nc.Conn.Close()
+		nc.Conn = nil
+		return err
+	})
+}
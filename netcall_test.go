@@ -0,0 +1,58 @@
+package argot
+
+import (
+	"bufio"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNetCall(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte("220 ready\r\n"))
+				reader := bufio.NewReader(conn)
+				line, _ := reader.ReadString('\n')
+				if line == "QUIT\r\n" {
+					conn.Write([]byte("221 bye\r\n"))
+					return
+				}
+				conn.Write([]byte("500 " + line))
+			}()
+		}
+	}()
+	address := listener.Addr().String()
+	nc := NewNetCall()
+	defer nc.Reset()
+
+	Steps{
+		nc.Connect("tcp", address),
+		nc.ExpectMatch(regexp.MustCompile(`^220 .*\r\n`), time.Second),
+		nc.SendString("HELO\r\n"),
+		nc.ExpectString("500 HELO", time.Second),
+		nc.ExpectClosed(time.Second),
+
+		nc.Connect("tcp", address),
+		nc.ExpectString("220", time.Second),
+		ExpectError(nc.ExpectString("221", 50*time.Millisecond)),
+		nc.SendString("QUIT\r\n"),
+		nc.ExpectString("221 bye", time.Second),
+		nc.Close(),
+	}.Test(t)
+
+	listener.Close()
+	Steps{
+		nc.ExpectRefused("tcp", address),
+	}.Test(t)
+}
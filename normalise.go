@@ -0,0 +1,280 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONNormaliser rewrites a decoded JSON document (as produced by
+// encoding/json decoding into an interface{}, so objects are
+// map[string]interface{} and arrays []interface{}) into a canonical
+// form, so that differences which don't matter to a test are ignored.
+// It may modify the document in place, and returns the result.
+//
+// Normalisers listed in HttpCall.JSONNormalisers are applied in order
+// to both the expected and the actual values before JSON comparisons
+// and snapshots.
+type JSONNormaliser func(doc interface{}) interface{}
+
+// NormaliseJSON round-trips value through encoding/json and then
+// applies the normalisers in order.
+func NormaliseJSON(value interface{}, normalisers ...JSONNormaliser) (interface{}, error) {
+	if doc, err := normaliseJSON(value); err != nil {
+		return nil, err
+	} else {
+		return applyNormalisers(doc, normalisers), nil
+	}
+}
+
+func applyNormalisers(doc interface{}, normalisers []JSONNormaliser) interface{} {
+	for _, normaliser := range normalisers {
+		doc = normaliser(doc)
+	}
+	return doc
+}
+
+// walkJSON calls fn on every value in doc, children before parents,
+// replacing each with the result.
+func walkJSON(doc interface{}, fn func(interface{}) interface{}) interface{} {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for key, elem := range v {
+			v[key] = walkJSON(elem, fn)
+		}
+	case []interface{}:
+		for idx, elem := range v {
+			v[idx] = walkJSON(elem, fn)
+		}
+	}
+	return fn(doc)
+}
+
+// jsonLess orders JSON values: numbers numerically, and anything else
+// by its formatting.
+func jsonLess(a, b interface{}) bool {
+	aNum, aErr := strconv.ParseFloat(fmt.Sprint(a), 64)
+	bNum, bErr := strconv.ParseFloat(fmt.Sprint(b), 64)
+	if aErr == nil && bErr == nil {
+		return aNum < bNum
+	} else {
+		return fmt.Sprint(a) < fmt.Sprint(b)
+	}
+}
+
+// SortArraysByKey sorts every array whose elements are all objects
+// containing key by the value of that key, so that arrays whose order
+// is not significant compare equal.
+func SortArraysByKey(key string) JSONNormaliser {
+	return func(doc interface{}) interface{} {
+		return walkJSON(doc, func(value interface{}) interface{} {
+			arr, ok := value.([]interface{})
+			if !ok {
+				return value
+			}
+			for _, elem := range arr {
+				if obj, ok := elem.(map[string]interface{}); !ok {
+					return value
+				} else if _, found := obj[key]; !found {
+					return value
+				}
+			}
+			sort.SliceStable(arr, func(i, j int) bool {
+				return jsonLess(arr[i].(map[string]interface{})[key], arr[j].(map[string]interface{})[key])
+			})
+			return arr
+		})
+	}
+}
+
+// DropPaths removes the values at the given paths, which are as for
+// JSONPath except that "*" matches any object key and "[*]" any array
+// index, for example "items[*].updatedAt". Dropped array elements are
+// replaced with null so that the indices of the others are unchanged.
+// Paths which are not found are ignored.
+func DropPaths(paths ...string) JSONNormaliser {
+	return func(doc interface{}) interface{} {
+		for _, path := range paths {
+			path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+			if path != "" {
+				dropPath(doc, splitPath(path))
+			}
+		}
+		return doc
+	}
+}
+
+// splitPath splits a path into object keys and bracketed array
+// indices, for example "a.b[2]" becomes "a", "b", "[2]".
+func splitPath(path string) []string {
+	tokens := []string{}
+	for _, segment := range strings.Split(path, ".") {
+		for segment != "" {
+			if idx := strings.IndexByte(segment[1:], '['); idx >= 0 {
+				tokens = append(tokens, segment[:idx+1])
+				segment = segment[idx+1:]
+			} else {
+				tokens = append(tokens, segment)
+				segment = ""
+			}
+		}
+	}
+	return tokens
+}
+
+func dropPath(value interface{}, tokens []string) {
+	token, last := tokens[0], len(tokens) == 1
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if strings.HasPrefix(token, "[") {
+			return
+		}
+		for key, elem := range v {
+			if token == "*" || token == key {
+				if last {
+					delete(v, key)
+				} else {
+					dropPath(elem, tokens[1:])
+				}
+			}
+		}
+	case []interface{}:
+		if !strings.HasPrefix(token, "[") || !strings.HasSuffix(token, "]") {
+			return
+		}
+		for idx, elem := range v {
+			if token == "[*]" || token == fmt.Sprintf("[%d]", idx) {
+				if last {
+					v[idx] = nil
+				} else {
+					dropPath(elem, tokens[1:])
+				}
+			}
+		}
+	}
+}
+
+// LowercaseKeys lowercases every object key.
+func LowercaseKeys() JSONNormaliser {
+	return func(doc interface{}) interface{} {
+		return walkJSON(doc, func(value interface{}) interface{} {
+			if obj, ok := value.(map[string]interface{}); ok {
+				lowered := make(map[string]interface{}, len(obj))
+				for key, elem := range obj {
+					lowered[strings.ToLower(key)] = elem
+				}
+				return lowered
+			}
+			return value
+		})
+	}
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// MaskedUUID is the value with which MaskUUIDs replaces UUIDs.
+const MaskedUUID = "<uuid>"
+
+// MaskUUIDs replaces every string value which is a UUID with
+// MaskedUUID.
+func MaskUUIDs() JSONNormaliser {
+	return func(doc interface{}) interface{} {
+		return walkJSON(doc, func(value interface{}) interface{} {
+			if str, ok := value.(string); ok && uuidPattern.MatchString(str) {
+				return MaskedUUID
+			}
+			return value
+		})
+	}
+}
+
+// exactJSON is as normaliseJSON, but numbers are decoded as
+// json.Number, in a canonical form so that numbers are equal exactly
+// when their values are: integers (including those written with a
+// fraction or exponent, such as 1.0 and 1e3) in decimal, and other
+// numbers as formatted by strconv.FormatFloat.
+func exactJSON(value interface{}) (interface{}, error) {
+	var doc interface{}
+	if bites, err := json.Marshal(value); err != nil {
+		return nil, err
+	} else if err := decodeExactJSON(bites, &doc); err != nil {
+		return nil, err
+	}
+	return walkJSON(doc, func(value interface{}) interface{} {
+		if number, ok := value.(json.Number); !ok {
+			return value
+		} else if rat, ok := new(big.Rat).SetString(string(number)); ok && rat.IsInt() {
+			return json.Number(rat.Num().String())
+		} else if float, err := number.Float64(); err == nil {
+			return json.Number(strconv.FormatFloat(float, 'g', -1, 64))
+		} else {
+			return number
+		}
+	}), nil
+}
+
+func decodeExactJSON(bites []byte, value interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(bites))
+	decoder.UseNumber()
+	return decoder.Decode(value)
+}
+
+// normalise round-trips value through encoding/json and applies
+// hc.JSONNormalisers. If hc.JSONExactNumbers is set, numbers are
+// decoded exactly (see exactJSON).
+func (hc *HttpCall) normalise(value interface{}) (interface{}, error) {
+	if !hc.JSONExactNumbers {
+		return NormaliseJSON(value, hc.JSONNormalisers...)
+	} else if doc, err := exactJSON(value); err != nil {
+		return nil, err
+	} else {
+		return applyNormalisers(doc, hc.JSONNormalisers), nil
+	}
+}
+
+// normaliseInto normalises the JSON encoding of value (see normalise)
+// and decodes the result into a new value of type t.
+func (hc *HttpCall) normaliseInto(value interface{}, t reflect.Type) (interface{}, error) {
+	normalisedAs := reflect.New(t).Interface()
+	if doc, err := hc.normalise(value); err != nil {
+		return nil, err
+	} else if bites, err := json.Marshal(doc); err != nil {
+		return nil, err
+	} else if hc.JSONExactNumbers {
+		if err := decodeExactJSON(bites, normalisedAs); err != nil {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(bites, normalisedAs); err != nil {
+		return nil, err
+	}
+	return normalisedAs, nil
+}
+
+// ResponseBodyJSONEquals is a Step that when executed ensures there is
+// a non-nil hc.ResponseBody, parses it as JSON, and errors unless it
+// equals the JSON encoding of expected, after both have been
+// normalised by hc.JSONNormalisers. Numbers are compared as float64
+// unless hc.JSONExactNumbers is set. The error will contain a
+// structured diff output as for ResponseBodyJSONMatchesStruct.
+func (hc *HttpCall) ResponseBodyJSONEquals(expected interface{}) Step {
+	return hc.step("ResponseBodyJSONEquals", func() error {
+		hc.coverJSONValue(expected)
+		if err := hc.ReceiveBody(); err != nil {
+			return err
+		} else if got, err := hc.normalise(json.RawMessage(hc.ResponseBody)); err != nil {
+			return err
+		} else if want, err := hc.normalise(expected); err != nil {
+			return err
+		} else if err := compareError(got, want); err != nil {
+			return err
+		} else {
+			return nil
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package argot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestJSONNormalisers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "argot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	body := `{"ID":"3f1c2a9e-8b7d-4c6e-9f0a-1b2c3d4e5f60","Items":[{"sku":"b","qty":2,"updatedAt":"now"},{"sku":"a","qty":1,"updatedAt":"then"}],"meta":{"requestId":"r1"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	type item struct {
+		SKU       string
+		Qty       int
+		UpdatedAt string
+	}
+	type order struct {
+		ID    string
+		Items []item
+	}
+	expected := map[string]interface{}{
+		"id": "00000000-0000-0000-0000-000000000000",
+		"items": []interface{}{
+			map[string]interface{}{"sku": "a", "qty": 1},
+			map[string]interface{}{"sku": "b", "qty": 2},
+		},
+	}
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	hc.Snapshot.Dir = dir
+	hc.JSONNormalisers = []JSONNormaliser{LowercaseKeys(), MaskUUIDs(), SortArraysByKey("sku"), DropPaths("items[*].updatedat", "meta")}
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseBodyJSONEquals(expected),
+		hc.ResponseBodyJSONMatchesStruct(order{ID: "0c0b0a09-0807-0605-0403-020100000000", Items: []item{{SKU: "a", Qty: 1}, {SKU: "b", Qty: 2}}}),
+		ExpectError(hc.ResponseBodyJSONMatchesStruct(order{Items: []item{{SKU: "b", Qty: 2}, {SKU: "a", Qty: 1}}})),
+		hc.ResponseMatchesSnapshot("normalised"),
+	}.Test(t)
+
+	hc.Reset()
+	hc.JSONNormalisers = nil
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		ExpectError(hc.ResponseBodyJSONEquals(expected)),
+		ExpectError(hc.ResponseMatchesSnapshot("normalised")),
+	}.Test(t)
+
+	if normalised, err := NormaliseJSON(map[string]interface{}{"a": []int{1, 2}}, DropPaths("a[0]", "missing.path")); err != nil {
+		t.Fatal(err)
+	} else if err := ExpectPrettyEqual(map[string]interface{}{"a": []interface{}{nil, 2.0}}, normalised).Go(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONExactNumbers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 1234567890123456789, "count": 2, "scale": 1.0e3, "ratio": 0.50, "tags": {"parent": 1234567890123456789}}`))
+	}))
+	defer server.Close()
+
+	type record struct {
+		ID    interface{} `json:"id"`
+		Count int         `json:"count"`
+		Ratio float64     `json:"ratio"`
+	}
+	wrongID := map[string]interface{}{"id": int64(1234567890123456788), "count": 2, "scale": 1000, "ratio": 0.5, "tags": map[string]interface{}{"parent": int64(1234567890123456789)}}
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseBodyJSONEquals(wrongID),
+	}.Test(t)
+	hc.Reset()
+
+	hc.JSONExactNumbers = true
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		ExpectError(hc.ResponseBodyJSONEquals(wrongID)),
+		hc.ResponseBodyJSONEquals(map[string]interface{}{"id": int64(1234567890123456789), "count": 2, "scale": 1000, "ratio": 0.5, "tags": map[string]interface{}{"parent": uint64(1234567890123456789)}}),
+		ExpectError(hc.ResponseBodyJSONMatchesStruct(record{ID: int64(1234567890123456788), Count: 2, Ratio: 0.5})),
+		hc.ResponseBodyJSONMatchesStruct(record{ID: int64(1234567890123456789), Count: 2, Ratio: 0.5}),
+	}.Test(t)
+}
@@ -0,0 +1,376 @@
+package argot
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OIDCConfiguration is the OpenID Provider metadata published at
+// /.well-known/openid-configuration.
+type OIDCConfiguration struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint,omitempty"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	ScopesSupported                  []string `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// OIDCTokens are the tokens returned by the token endpoint.
+type OIDCTokens struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token"`
+}
+
+// OIDCCall captures the state of an OpenID Connect login against a
+// test identity provider: discovery, an authorization code flow, and
+// the resulting tokens. Discovery and token requests are made using
+// HttpCall, so the HttpCall steps remain available for inspecting the
+// most recent exchange. An OIDCCall can only be used by a single
+// go-routine at a time.
+type OIDCCall struct {
+	// The underlying HTTP call.
+	HttpCall *HttpCall
+	// Issuer is the identifier of the provider, for example
+	// https://idp.example.com/realms/test.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Scopes are requested in addition to openid.
+	Scopes []string
+	// Login is called with the authorization URL, and must perform the
+	// user's part of the flow: it must cause the provider to redirect
+	// the user agent to the redirect URL. If nil, the URL is fetched
+	// with HttpCall.Client, following redirects, which suffices for
+	// test providers which approve logins automatically.
+	Login func(authorizationURL string) error
+	// Timeout limits how long to wait for the redirect. If zero, ten
+	// seconds is used.
+	Timeout time.Duration
+
+	// Config is set by Discover.
+	Config *OIDCConfiguration
+	// Tokens is set by AuthorizationCodeFlow.
+	Tokens *OIDCTokens
+}
+
+// NewOIDCCall creates a new OIDCCall. If client is nil, a new
+// http.Client is used.
+func NewOIDCCall(client *http.Client, issuer, clientID, clientSecret string) *OIDCCall {
+	return &OIDCCall{
+		HttpCall:     NewHttpCall(client),
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+	}
+}
+
+// Reset is idempotent. You should ensure this is called at the end of
+// life for each OIDCCall.
+func (oc *OIDCCall) Reset() error {
+	oc.Config = nil
+	oc.Tokens = nil
+	return oc.HttpCall.Reset()
+}
+
+// Discover is a Step that when executed fetches the provider's
+// /.well-known/openid-configuration, setting oc.Config, and errors
+// unless the request succeeds and the required fields are present,
+// the issuer matches oc.Issuer, and the code response type is
+// supported.
+func (oc *OIDCCall) Discover() Step {
+	hc := oc.HttpCall
+	return hc.step("OIDCDiscover", func() error {
+		config := new(OIDCConfiguration)
+		if err := (Steps{
+			hc.NewRequest("GET", oc.Issuer+"/.well-known/openid-configuration", nil),
+			hc.ResponseStatusEquals(http.StatusOK),
+		}).Go(); err != nil {
+			return err
+		} else if err := hc.ReceiveBody(); err != nil {
+			return err
+		} else if err := json.Unmarshal(hc.ResponseBody, config); err != nil {
+			return fmt.Errorf("OIDC discovery: %v", err)
+		}
+		missing := []string{}
+		for _, field := range []struct {
+			name    string
+			present bool
+		}{
+			{"issuer", config.Issuer != ""},
+			{"authorization_endpoint", config.AuthorizationEndpoint != ""},
+			{"token_endpoint", config.TokenEndpoint != ""},
+			{"jwks_uri", config.JWKSURI != ""},
+			{"response_types_supported", len(config.ResponseTypesSupported) != 0},
+			{"subject_types_supported", len(config.SubjectTypesSupported) != 0},
+			{"id_token_signing_alg_values_supported", len(config.IDTokenSigningAlgValuesSupported) != 0},
+		} {
+			if !field.present {
+				missing = append(missing, field.name)
+			}
+		}
+		if len(missing) != 0 {
+			return fmt.Errorf("OIDC discovery: Expected required fields; missing %s.", strings.Join(missing, ", "))
+		} else if config.Issuer != oc.Issuer {
+			return fmt.Errorf("OIDC discovery issuer: Expected %s; found %s.", oc.Issuer, config.Issuer)
+		} else if !containsString(config.ResponseTypesSupported, "code") {
+			return fmt.Errorf("OIDC discovery: Expected response type code to be supported; found %v.", config.ResponseTypesSupported)
+		}
+		oc.Config = config
+		return nil
+	})
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// redirectListener listens locally for the provider's redirect,
+// delivering the query parameters of the first request received.
+type redirectListener struct {
+	listener net.Listener
+	server   *http.Server
+	received chan url.Values
+}
+
+func newRedirectListener() (*redirectListener, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	rl := &redirectListener{listener: listener, received: make(chan url.Values, 1)}
+	rl.server = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case rl.received <- r.URL.Query():
+		default:
+		}
+		io.WriteString(w, "Login complete. You may close this window.")
+	})}
+	go rl.server.Serve(listener)
+	return rl, nil
+}
+
+func (rl *redirectListener) url() string {
+	return "http://" + rl.listener.Addr().String() + "/callback"
+}
+
+func (rl *redirectListener) close() {
+	rl.server.Close()
+}
+
+func (oc *OIDCCall) login(authorizationURL string) error {
+	if oc.Login != nil {
+		return oc.Login(authorizationURL)
+	} else if response, err := oc.HttpCall.Client.Get(authorizationURL); err != nil {
+		return err
+	} else {
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+		return nil
+	}
+}
+
+// AuthorizationCodeFlow is a Step that when executed performs an
+// authorization code login, setting oc.Tokens. It listens for the
+// redirect on a local port, builds the authorization URL (with state
+// and nonce) and passes it to oc.Login, waits for the redirect,
+// exchanges the code at the token endpoint, and errors unless an
+// access token and an ID token are returned whose issuer, audience
+// and nonce claims are as expected. The ID token's signature is not
+// verified. Discover must have succeeded first.
+func (oc *OIDCCall) AuthorizationCodeFlow() Step {
+	hc := oc.HttpCall
+	return hc.step("OIDCAuthorizationCodeFlow", func() error {
+		if oc.Config == nil {
+			return errors.New("OIDC: Discover must succeed before logging in.")
+		}
+		state, err := randomToken()
+		if err != nil {
+			return err
+		}
+		nonce, err := randomToken()
+		if err != nil {
+			return err
+		}
+		listener, err := newRedirectListener()
+		if err != nil {
+			return err
+		}
+		defer listener.close()
+
+		authURL, err := url.Parse(oc.Config.AuthorizationEndpoint)
+		if err != nil {
+			return fmt.Errorf("OIDC authorization endpoint: %v", err)
+		}
+		query := authURL.Query()
+		query.Set("response_type", "code")
+		query.Set("client_id", oc.ClientID)
+		query.Set("redirect_uri", listener.url())
+		query.Set("scope", strings.Join(append([]string{"openid"}, oc.Scopes...), " "))
+		query.Set("state", state)
+		query.Set("nonce", nonce)
+		authURL.RawQuery = query.Encode()
+
+		loginErr := make(chan error, 1)
+		go func() { loginErr <- oc.login(authURL.String()) }()
+		timeout := oc.Timeout
+		if timeout == 0 {
+			timeout = 10 * time.Second
+		}
+		var params url.Values
+		select {
+		case params = <-listener.received:
+		case err := <-loginErr:
+			if err != nil {
+				return fmt.Errorf("OIDC login: %v", err)
+			}
+			select {
+			case params = <-listener.received:
+			case <-time.After(timeout):
+				return errors.New("OIDC login: The provider did not redirect back.")
+			}
+		case <-time.After(timeout):
+			return fmt.Errorf("OIDC login: No redirect received within %v.", timeout)
+		}
+
+		if errCode := params.Get("error"); errCode != "" {
+			return fmt.Errorf("OIDC login: %s: %s", errCode, params.Get("error_description"))
+		} else if found := params.Get("state"); found != state {
+			return fmt.Errorf("OIDC state: Expected %s; found %s.", state, found)
+		} else if params.Get("code") == "" {
+			return errors.New("OIDC login: No code in the redirect.")
+		}
+		return oc.exchange(params.Get("code"), listener.url(), nonce)
+	})
+}
+
+func (oc *OIDCCall) exchange(code, redirectURL, nonce string) error {
+	hc := oc.HttpCall
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURL},
+		"client_id":    {oc.ClientID},
+	}
+	tokens := new(OIDCTokens)
+	if err := hc.Reset(); err != nil {
+		return err
+	} else if req, err := http.NewRequest("POST", oc.Config.TokenEndpoint, strings.NewReader(form.Encode())); err != nil {
+		return err
+	} else {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if oc.ClientSecret != "" {
+			req.SetBasicAuth(url.QueryEscape(oc.ClientID), url.QueryEscape(oc.ClientSecret))
+		}
+		hc.Request = req
+		hc.requestName = "OIDCTokenRequest"
+	}
+	if err := hc.ResponseStatusEquals(http.StatusOK).Go(); err != nil {
+		return err
+	} else if err := hc.ReceiveBody(); err != nil {
+		return err
+	} else if err := json.Unmarshal(hc.ResponseBody, tokens); err != nil {
+		return fmt.Errorf("OIDC token response: %v", err)
+	} else if tokens.AccessToken == "" || tokens.IDToken == "" {
+		return errors.New("OIDC token response: Expected access_token and id_token.")
+	} else if err := oc.checkIDToken(tokens.IDToken, nonce); err != nil {
+		return err
+	}
+	oc.Tokens = tokens
+	return nil
+}
+
+// checkIDToken decodes the claims of an ID token (without verifying
+// its signature) and checks the issuer, audience and nonce.
+func (oc *OIDCCall) checkIDToken(idToken, nonce string) error {
+	var claims struct {
+		Issuer   string          `json:"iss"`
+		Audience json.RawMessage `json:"aud"`
+		Nonce    string          `json:"nonce"`
+	}
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return errors.New("OIDC ID token: Expected a JWT.")
+	} else if payload, err := base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+		return fmt.Errorf("OIDC ID token: %v", err)
+	} else if err := json.Unmarshal(payload, &claims); err != nil {
+		return fmt.Errorf("OIDC ID token: %v", err)
+	}
+	var audiences []string
+	var audience string
+	if err := json.Unmarshal(claims.Audience, &audience); err == nil {
+		audiences = []string{audience}
+	} else {
+		json.Unmarshal(claims.Audience, &audiences)
+	}
+	if claims.Issuer != oc.Config.Issuer {
+		return fmt.Errorf("OIDC ID token iss: Expected %s; found %s.", oc.Config.Issuer, claims.Issuer)
+	} else if !containsString(audiences, oc.ClientID) {
+		return fmt.Errorf("OIDC ID token aud: Expected to include %s; found %v.", oc.ClientID, audiences)
+	} else if claims.Nonce != nonce {
+		return fmt.Errorf("OIDC ID token nonce: Expected %s; found %s.", nonce, claims.Nonce)
+	} else {
+		return nil
+	}
+}
+
+// StoreTokens is a Step that when executed stores the tokens from the
+// login in store, under prefix followed by accessToken, idToken and
+// refreshToken, for use in later requests (see Store.Interpolate).
+func (oc *OIDCCall) StoreTokens(store *Store, prefix string) Step {
+	return NewNamedStep(fmt.Sprintf("OIDCStoreTokens(%s)", prefix), func() error {
+		if oc.Tokens == nil {
+			return errors.New("OIDC: No tokens: AuthorizationCodeFlow must succeed first.")
+		}
+		store.Set(prefix+"accessToken", oc.Tokens.AccessToken)
+		store.Set(prefix+"idToken", oc.Tokens.IDToken)
+		store.Set(prefix+"refreshToken", oc.Tokens.RefreshToken)
+		return nil
+	})
+}
+
+// Authorize is a Step that when executed sets the Authorization header
+// of hc's request to the access token from the login. As with
+// RequestHeader, this can only be done after hc.Request has been
+// created, and before hc.Response has been created.
+func (oc *OIDCCall) Authorize(hc *HttpCall) Step {
+	return hc.step("OIDCAuthorize", func() error {
+		if oc.Tokens == nil {
+			return errors.New("OIDC: No tokens: AuthorizationCodeFlow must succeed first.")
+		} else if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
+			return err
+		} else {
+			hc.Request.Header.Set("Authorization", "Bearer "+oc.Tokens.AccessToken)
+			return nil
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package argot
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestOIDC(t *testing.T) {
+	var issuer, nonce string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&OIDCConfiguration{
+			Issuer:                           issuer,
+			AuthorizationEndpoint:            issuer + "/authorize",
+			TokenEndpoint:                    issuer + "/token",
+			JWKSURI:                          issuer + "/jwks",
+			ResponseTypesSupported:           []string{"code"},
+			SubjectTypesSupported:            []string{"public"},
+			IDTokenSigningAlgValuesSupported: []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		nonce = query.Get("nonce")
+		redirect := query.Get("redirect_uri") + "?" + url.Values{"code": {"the-code"}, "state": {query.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect, http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "client" || password != "secret" || r.FormValue("code") != "the-code" {
+			http.Error(w, "invalid_grant", http.StatusBadRequest)
+			return
+		}
+		claims, _ := json.Marshal(map[string]interface{}{"iss": issuer, "aud": "client", "nonce": nonce})
+		json.NewEncoder(w).Encode(&OIDCTokens{
+			AccessToken: "access",
+			TokenType:   "Bearer",
+			IDToken:     "e30." + base64.RawURLEncoding.EncodeToString(claims) + ".sig",
+		})
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	issuer = server.URL
+
+	oc := NewOIDCCall(nil, server.URL, "client", "secret")
+	defer oc.Reset()
+	store := NewStore()
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		ExpectError(oc.AuthorizationCodeFlow()),
+		oc.Discover(),
+		oc.AuthorizationCodeFlow(),
+		oc.StoreTokens(store, "user."),
+		store.ExpectEquals("user.accessToken", "access"),
+		hc.NewRequest("GET", server.URL+"/api", nil),
+		oc.Authorize(hc),
+		hc.ResponseStatusEquals(http.StatusOK),
+	}.Test(t)
+
+	wrong := NewOIDCCall(nil, server.URL, "client", "wrong")
+	defer wrong.Reset()
+	Steps{
+		wrong.Discover(),
+		ExpectError(wrong.AuthorizationCodeFlow()),
+		ExpectError(NewOIDCCall(nil, server.URL+"/other", "client", "secret").Discover()),
+	}.Test(t)
+}
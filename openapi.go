@@ -0,0 +1,349 @@
+package argot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// OpenAPISpec is an OpenAPI 3 document against which requests and
+// responses can be validated. Only JSON documents are supported; only
+// JSON request and response bodies have their contents validated.
+type OpenAPISpec struct {
+	doc      map[string]interface{}
+	prefixes []string
+	paths    []*openAPIPath
+}
+
+type openAPIPath struct {
+	template string
+	pattern  *regexp.Regexp
+	params   []string
+	item     map[string]interface{}
+}
+
+// openAPIOperation is the operation that a request was matched to.
+type openAPIOperation struct {
+	method     string
+	path       *openAPIPath
+	pathValues map[string]string
+	op         map[string]interface{}
+}
+
+func (op *openAPIOperation) String() string {
+	return fmt.Sprintf("%s %s", op.method, op.path.template)
+}
+
+var openAPIPathParam = regexp.MustCompile(`\{([^}/]+)\}`)
+
+// LoadOpenAPISpec reads and parses the OpenAPI 3 JSON document at
+// path.
+func LoadOpenAPISpec(path string) (*OpenAPISpec, error) {
+	if bites, err := ioutil.ReadFile(path); err != nil {
+		return nil, err
+	} else if spec, err := NewOpenAPISpec(bites); err != nil {
+		return nil, fmt.Errorf("OpenAPI %s: %v", path, err)
+	} else {
+		return spec, nil
+	}
+}
+
+// NewOpenAPISpec parses an OpenAPI 3 JSON document.
+func NewOpenAPISpec(doc []byte) (*OpenAPISpec, error) {
+	spec := &OpenAPISpec{}
+	if err := json.Unmarshal(doc, &spec.doc); err != nil {
+		return nil, err
+	}
+	servers, _ := spec.doc["servers"].([]interface{})
+	for _, server := range servers {
+		serverURL, _ := jsonObject(server)["url"].(string)
+		if u, err := url.Parse(serverURL); err == nil && u.Path != "" && u.Path != "/" {
+			spec.prefixes = append(spec.prefixes, strings.TrimSuffix(u.Path, "/"))
+		}
+	}
+	paths := jsonObject(spec.doc["paths"])
+	if len(paths) == 0 {
+		return nil, errors.New("No paths defined.")
+	}
+	for template, item := range paths {
+		path := &openAPIPath{template: template, item: jsonObject(item)}
+		pattern := "^"
+		last := 0
+		for _, match := range openAPIPathParam.FindAllStringSubmatchIndex(template, -1) {
+			pattern += regexp.QuoteMeta(template[last:match[0]]) + "([^/]+)"
+			path.params = append(path.params, template[match[2]:match[3]])
+			last = match[1]
+		}
+		path.pattern = regexp.MustCompile(pattern + regexp.QuoteMeta(template[last:]) + "$")
+		spec.paths = append(spec.paths, path)
+	}
+	// Literal paths must take precedence over templated paths.
+	sort.Slice(spec.paths, func(i, j int) bool {
+		pi, pj := spec.paths[i], spec.paths[j]
+		if len(pi.params) != len(pj.params) {
+			return len(pi.params) < len(pj.params)
+		}
+		return pi.template < pj.template
+	})
+	return spec, nil
+}
+
+func jsonObject(value interface{}) map[string]interface{} {
+	obj, _ := value.(map[string]interface{})
+	return obj
+}
+
+// resolve follows a local "$ref" (if any) within the document.
+func (spec *OpenAPISpec) resolve(value interface{}) map[string]interface{} {
+	obj := jsonObject(value)
+	for i := 0; i < 32; i++ {
+		ref, found := obj["$ref"].(string)
+		if !found || !strings.HasPrefix(ref, "#/") {
+			return obj
+		}
+		var cur interface{} = spec.doc
+		for _, token := range strings.Split(ref[2:], "/") {
+			token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+			cur = jsonObject(cur)[token]
+		}
+		obj = jsonObject(cur)
+	}
+	return obj
+}
+
+func (spec *OpenAPISpec) operation(req *http.Request) (*openAPIOperation, error) {
+	path := req.URL.Path
+	for _, prefix := range spec.prefixes {
+		if strings.HasPrefix(path, prefix+"/") {
+			path = path[len(prefix):]
+			break
+		}
+	}
+	for _, p := range spec.paths {
+		matches := p.pattern.FindStringSubmatch(path)
+		if matches == nil {
+			continue
+		}
+		op := jsonObject(p.item[strings.ToLower(req.Method)])
+		if op == nil {
+			return nil, fmt.Errorf("OpenAPI: Method %s not defined for path %s.", req.Method, p.template)
+		}
+		values := make(map[string]string, len(p.params))
+		for idx, name := range p.params {
+			if value, err := url.PathUnescape(matches[idx+1]); err == nil {
+				values[name] = value
+			} else {
+				values[name] = matches[idx+1]
+			}
+		}
+		return &openAPIOperation{method: req.Method, path: p, pathValues: values, op: op}, nil
+	}
+	return nil, fmt.Errorf("OpenAPI: No path matches %s.", req.URL.Path)
+}
+
+// validate validates value against the schema, which may contain
+// references to the document's components.
+func (spec *OpenAPISpec) validate(schema map[string]interface{}, value interface{}) error {
+	root := make(map[string]interface{}, len(schema)+1)
+	for key, elem := range schema {
+		root[key] = elem
+	}
+	if _, found := root["components"]; !found {
+		root["components"] = spec.doc["components"]
+	}
+	return validateJSONSchema(gojsonschema.NewGoLoader(root), gojsonschema.NewGoLoader(value))
+}
+
+// parameterValue converts the string form of a parameter into a
+// value suitable for validation against the parameter's schema.
+func parameterValue(schema map[string]interface{}, value string) interface{} {
+	switch schema["type"] {
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+func (spec *OpenAPISpec) validateParameters(op *openAPIOperation, req *http.Request) error {
+	params := []interface{}{}
+	if list, ok := op.path.item["parameters"].([]interface{}); ok {
+		params = append(params, list...)
+	}
+	if list, ok := op.op["parameters"].([]interface{}); ok {
+		params = append(params, list...)
+	}
+	query := req.URL.Query()
+	for _, param := range params {
+		param := spec.resolve(param)
+		name, _ := param["name"].(string)
+		in, _ := param["in"].(string)
+		required, _ := param["required"].(bool)
+		var value string
+		var found bool
+		switch in {
+		case "path":
+			value, found = op.pathValues[name]
+		case "query":
+			_, found = query[name]
+			value = query.Get(name)
+		case "header":
+			_, found = req.Header[http.CanonicalHeaderKey(name)]
+			value = req.Header.Get(name)
+		case "cookie":
+			if cookie, err := req.Cookie(name); err == nil {
+				value, found = cookie.Value, true
+			}
+		default:
+			continue
+		}
+		if !found {
+			if required || in == "path" {
+				return fmt.Errorf("OpenAPI %v: Required %s parameter '%s' missing.", op, in, name)
+			}
+			continue
+		}
+		if schema := spec.resolve(param["schema"]); schema != nil {
+			if err := spec.validate(schema, parameterValue(schema, value)); err != nil {
+				return fmt.Errorf("OpenAPI %v: %s parameter '%s': %v", op, in, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateBody validates body against the content map (of media type
+// to media type object) from a request body or response.
+func (spec *OpenAPISpec) validateBody(op *openAPIOperation, what string, content map[string]interface{}, contentType string, body []byte) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("OpenAPI %v: %s Content-Type '%s' invalid: %v", op, what, contentType, err)
+	}
+	media, found := content[mediaType]
+	if !found {
+		if slash := strings.Index(mediaType, "/"); slash != -1 {
+			media, found = content[mediaType[:slash]+"/*"]
+		}
+		if !found {
+			media, found = content["*/*"]
+		}
+	}
+	if !found {
+		types := make([]string, 0, len(content))
+		for key := range content {
+			types = append(types, key)
+		}
+		sort.Strings(types)
+		return fmt.Errorf("OpenAPI %v: %s Content-Type: Expected one of %v; found '%s'.", op, what, types, mediaType)
+	}
+	schema := spec.resolve(jsonObject(media)["schema"])
+	if schema == nil || !strings.Contains(mediaType, "json") {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("OpenAPI %v: %s body is not valid JSON: %v", op, what, err)
+	} else if err := spec.validate(schema, value); err != nil {
+		return fmt.Errorf("OpenAPI %v: %s body: %v", op, what, err)
+	} else {
+		return nil
+	}
+}
+
+func (spec *OpenAPISpec) validateRequest(req *http.Request) error {
+	op, err := spec.operation(req)
+	if err != nil {
+		return err
+	} else if err := spec.validateParameters(op, req); err != nil {
+		return err
+	}
+	body, err := readRequestBody(req)
+	if err != nil {
+		return err
+	}
+	requestBody := spec.resolve(op.op["requestBody"])
+	if requestBody == nil {
+		return nil
+	} else if required, _ := requestBody["required"].(bool); len(body) == 0 && required {
+		return fmt.Errorf("OpenAPI %v: Request body required; none found.", op)
+	} else if len(body) == 0 {
+		return nil
+	} else {
+		return spec.validateBody(op, "Request", jsonObject(requestBody["content"]), req.Header.Get("Content-Type"), body)
+	}
+}
+
+func (spec *OpenAPISpec) validateResponse(req *http.Request, response *http.Response, body []byte) error {
+	op, err := spec.operation(req)
+	if err != nil {
+		return err
+	}
+	responses := jsonObject(op.op["responses"])
+	status := strconv.Itoa(response.StatusCode)
+	declared, found := responses[status]
+	if !found {
+		declared, found = responses[status[:1]+"XX"]
+	}
+	if !found {
+		declared, found = responses["default"]
+	}
+	if !found {
+		return fmt.Errorf("OpenAPI %v: Status %d not documented.", op, response.StatusCode)
+	}
+	content := jsonObject(spec.resolve(declared)["content"])
+	if len(content) == 0 || len(body) == 0 {
+		return nil
+	} else {
+		return spec.validateBody(op, "Response", content, response.Header.Get("Content-Type"), body)
+	}
+}
+
+// RequestConformsToSpec is a Step that when executed errors unless
+// hc.Request conforms to the operation in hc.Spec that it matches:
+// required parameters must be present, parameter values and JSON
+// request bodies must validate against their schemas. This must be
+// used after the request has been built, but may be used before or
+// after the request has been sent.
+func (hc *HttpCall) RequestConformsToSpec() Step {
+	return hc.step("RequestConformsToSpec", func() error {
+		if hc.Spec == nil {
+			return errors.New("No OpenAPI Spec set.")
+		} else if err := hc.AssertRequest(); err != nil {
+			return err
+		} else {
+			return hc.Spec.validateRequest(hc.Request)
+		}
+	})
+}
+
+// ResponseConformsToSpec is a Step that when executed ensures there
+// is a non-nil hc.ResponseBody and errors unless the response status
+// is documented for the operation in hc.Spec that hc.Request matches,
+// and the response Content-Type and any JSON body conform to the
+// documented response.
+func (hc *HttpCall) ResponseConformsToSpec() Step {
+	return hc.step("ResponseConformsToSpec", func() error {
+		if hc.Spec == nil {
+			return errors.New("No OpenAPI Spec set.")
+		} else if err := hc.ReceiveBody(); err != nil {
+			return err
+		} else {
+			return hc.Spec.validateResponse(hc.Request, hc.Response, hc.ResponseBody)
+		}
+	})
+}
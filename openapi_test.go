@@ -0,0 +1,79 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testOpenAPISpec = `{
+  "openapi": "3.0.0",
+  "servers": [{"url": "http://example.com/v1"}],
+  "paths": {
+    "/pets/{petId}": {
+      "parameters": [{"name": "petId", "in": "path", "required": true, "schema": {"type": "integer"}}],
+      "put": {
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}}}
+        },
+        "responses": {
+          "200": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}}}}
+        }
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Pet": {
+        "type": "object",
+        "required": ["name"],
+        "properties": {"name": {"type": "string"}}
+      }
+    }
+  }
+}`
+
+func TestOpenAPIConformance(t *testing.T) {
+	spec, err := NewOpenAPISpec([]byte(testOpenAPISpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("broken") != "" {
+			w.Write([]byte(`{"name": 7}`))
+		} else {
+			w.Write([]byte(`{"name": "rex"}`))
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	hc.Spec = spec
+	defer hc.Reset()
+
+	request := func(path, body string) Steps {
+		return Steps{
+			hc.NewRequest("PUT", server.URL+"/v1"+path, strings.NewReader(body)),
+			hc.RequestHeader("Content-Type", "application/json"),
+		}
+	}
+	Steps{
+		request("/pets/1", `{"name": "rex"}`),
+		hc.RequestConformsToSpec(),
+		hc.ResponseConformsToSpec(),
+	}.Test(t)
+
+	for _, steps := range []Steps{
+		{request("/pets/rex", `{"name": "rex"}`), hc.RequestConformsToSpec()},
+		{request("/pets/1", `{}`), hc.RequestConformsToSpec()},
+		{request("/cats/1", `{"name": "rex"}`), hc.RequestConformsToSpec()},
+		{request("/pets/1?broken=1", `{"name": "rex"}`), hc.ResponseConformsToSpec()},
+	} {
+		if _, err := steps.Test(nil); err == nil {
+			t.Errorf("Expected %v to fail.", steps)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// openAPIMethods are the methods an OpenAPI path item may define, in
+// the order in which their operations are generated.
+var openAPIMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions, http.MethodTrace,
+}
+
+// Scenarios generates a skeleton Scenario for every operation in the
+// document, ordered by path and then method, to bootstrap coverage of
+// an API which would be laborious to cover by hand. Each scenario
+// makes a single request, with example values for its path
+// parameters (as vars, so that they are easily changed), required
+// query and header parameters and JSON request body, and expects the
+// operation's first documented success status and, if that response
+// has a JSON schema, a body satisfying it. Examples are taken from the
+// document where given, and otherwise made up from the schemas, so the
+// scenarios typically need editing before they pass. URLs are
+// relative to ${baseURL}, which should include any path of the API's
+// server. Each scenario is named after its operationId, if it has one,
+// and otherwise its method and path.
+func (spec *OpenAPISpec) Scenarios() []*Scenario {
+	paths := make([]*openAPIPath, len(spec.paths))
+	copy(paths, spec.paths)
+	sort.Slice(paths, func(i, j int) bool { return paths[i].template < paths[j].template })
+	scenarios := []*Scenario{}
+	for _, path := range paths {
+		for _, method := range openAPIMethods {
+			if op := jsonObject(path.item[strings.ToLower(method)]); op != nil {
+				scenarios = append(scenarios, spec.scenario(&openAPIOperation{method: method, path: path, op: op}))
+			}
+		}
+	}
+	return scenarios
+}
+
+func (spec *OpenAPISpec) scenario(op *openAPIOperation) *Scenario {
+	name, _ := op.op["operationId"].(string)
+	if name == "" {
+		name = op.String()
+	}
+	req := &ScenarioRequest{Method: op.method, URL: openAPIPathParam.ReplaceAllString(op.path.template, "$${$1}")}
+	scenario := &Scenario{Name: name, Steps: []*ScenarioStep{{Request: req}}}
+
+	params := []interface{}{}
+	if list, ok := op.path.item["parameters"].([]interface{}); ok {
+		params = append(params, list...)
+	}
+	if list, ok := op.op["parameters"].([]interface{}); ok {
+		params = append(params, list...)
+	}
+	query := []string{}
+	for _, param := range params {
+		param := spec.resolve(param)
+		name, _ := param["name"].(string)
+		in, _ := param["in"].(string)
+		required, _ := param["required"].(bool)
+		if in != "path" && !required {
+			continue
+		}
+		value, found := param["example"]
+		if !found {
+			value = spec.example(spec.resolve(param["schema"]), 0)
+		}
+		str := exampleString(value)
+		switch in {
+		case "path":
+			if scenario.Vars == nil {
+				scenario.Vars = make(map[string]string)
+			}
+			scenario.Vars[name] = str
+		case "query":
+			query = append(query, name+"="+str)
+		case "header":
+			if req.Headers == nil {
+				req.Headers = make(map[string]string)
+			}
+			req.Headers[name] = str
+		}
+	}
+	if len(query) > 0 {
+		req.URL += "?" + strings.Join(query, "&")
+	}
+
+	if requestBody := spec.resolve(op.op["requestBody"]); requestBody != nil {
+		if media, found := openAPIJSONMedia(jsonObject(requestBody["content"])); found {
+			req.JSON = spec.mediaExample(media)
+		}
+	}
+
+	expect := &ScenarioExpect{}
+	responses := jsonObject(op.op["responses"])
+	statuses := make([]string, 0, len(responses))
+	for status := range responses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		if code, err := strconv.Atoi(status); err == nil {
+			expect.Status = code
+		} else {
+			expect.Status = http.StatusOK
+		}
+		if media, found := openAPIJSONMedia(jsonObject(spec.resolve(responses[status])["content"])); found {
+			if schema := spec.resolve(media["schema"]); schema != nil {
+				if bites, err := json.Marshal(spec.inline(schema, 0)); err == nil {
+					expect.JSONSchema = string(bites)
+				}
+			}
+		}
+		break
+	}
+	if expect.Status != 0 {
+		scenario.Steps[0].Expect = expect
+	}
+	return scenario
+}
+
+// openAPIJSONMedia returns the media type object for JSON within
+// content, if any.
+func openAPIJSONMedia(content map[string]interface{}) (map[string]interface{}, bool) {
+	if media, found := content["application/json"]; found {
+		return jsonObject(media), true
+	}
+	types := make([]string, 0, len(content))
+	for mediaType := range content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	for _, mediaType := range types {
+		if strings.HasSuffix(mediaType, "+json") {
+			return jsonObject(content[mediaType]), true
+		}
+	}
+	return nil, false
+}
+
+// mediaExample returns the example of a media type object, or one made
+// up from its schema.
+func (spec *OpenAPISpec) mediaExample(media map[string]interface{}) interface{} {
+	if example, found := media["example"]; found {
+		return example
+	}
+	examples := jsonObject(media["examples"])
+	for _, key := range sortedInterfaceKeys(examples) {
+		if value, found := spec.resolve(examples[key])["value"]; found {
+			return value
+		}
+	}
+	return spec.example(spec.resolve(media["schema"]), 0)
+}
+
+func sortedInterfaceKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// example returns the schema's example, default or first enumerated
+// value, or failing those, a value made up from its type and format.
+func (spec *OpenAPISpec) example(schema map[string]interface{}, depth int) interface{} {
+	if schema == nil || depth > 8 {
+		return nil
+	} else if example, found := schema["example"]; found {
+		return example
+	} else if value, found := schema["default"]; found {
+		return value
+	} else if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	} else if allOf, ok := schema["allOf"].([]interface{}); ok {
+		merged := map[string]interface{}{}
+		for _, elem := range allOf {
+			for key, value := range jsonObject(spec.example(spec.resolve(elem), depth+1)) {
+				merged[key] = value
+			}
+		}
+		return merged
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if alternatives, ok := schema[key].([]interface{}); ok && len(alternatives) > 0 {
+			return spec.example(spec.resolve(alternatives[0]), depth+1)
+		}
+	}
+	switch schema["type"] {
+	case "string":
+		switch schema["format"] {
+		case "date-time":
+			return "2006-01-02T15:04:05Z"
+		case "date":
+			return "2006-01-02"
+		case "uuid":
+			return "00000000-0000-0000-0000-000000000000"
+		case "email":
+			return "user@example.com"
+		case "uri", "url":
+			return "https://example.com/"
+		default:
+			return "string"
+		}
+	case "integer", "number":
+		return 1
+	case "boolean":
+		return true
+	case "array":
+		return []interface{}{spec.example(spec.resolve(schema["items"]), depth+1)}
+	}
+	properties := jsonObject(schema["properties"])
+	if schema["type"] != "object" && properties == nil {
+		return nil
+	}
+	obj := make(map[string]interface{}, len(properties))
+	for key, property := range properties {
+		obj[key] = spec.example(spec.resolve(property), depth+1)
+	}
+	return obj
+}
+
+// exampleString formats an example value as a parameter.
+func exampleString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		bites, _ := json.Marshal(v)
+		return string(bites)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// inline returns value with its local references replaced by what
+// they refer to, so that a schema stands alone. References nested too
+// deeply, as recursive schemas' are, become the empty schema.
+func (spec *OpenAPISpec) inline(value interface{}, depth int) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if _, found := v["$ref"]; found {
+			if depth >= 8 {
+				return map[string]interface{}{}
+			}
+			return spec.inline(spec.resolve(v), depth+1)
+		}
+		result := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			result[key] = spec.inline(elem, depth)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for idx, elem := range v {
+			result[idx] = spec.inline(elem, depth)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// GoSource generates the Go source of package pkg with a function for
+// every operation in the document, returning skeleton Steps equivalent
+// to the scenario generated by Scenarios. Each function is named after
+// the operation, and takes the HttpCall with which to make the request
+// and the base URL of the API.
+func (spec *OpenAPISpec) GoSource(pkg string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	usesStrings := false
+	used := make(map[string]bool)
+	for _, scenario := range spec.Scenarios() {
+		name := goIdentifier(scenario.Name)
+		for idx := 2; used[name]; idx++ {
+			name = fmt.Sprintf("%s%d", goIdentifier(scenario.Name), idx)
+		}
+		used[name] = true
+
+		step := scenario.Steps[0]
+		req := step.Request
+		store := NewStore()
+		for key, value := range scenario.Vars {
+			store.Set(key, value)
+		}
+		urlStr, err := store.Interpolate(req.URL)
+		if err != nil {
+			return nil, err
+		}
+		body := "nil"
+		if req.JSON != nil {
+			if bites, err := json.Marshal(req.JSON); err != nil {
+				return nil, err
+			} else {
+				body = fmt.Sprintf("strings.NewReader(%s)", goString(string(bites)))
+				usesStrings = true
+			}
+		}
+		fmt.Fprintf(buf, "\n// %s returns the steps of %s %s.\nfunc %s(hc *argot.HttpCall, baseURL string) argot.Steps {\n\treturn argot.Steps{\n", name, req.Method, step.Request.URL, name)
+		fmt.Fprintf(buf, "\t\thc.NewRequest(%q, baseURL+%s, %s),\n", req.Method, goString(urlStr), body)
+		if req.JSON != nil {
+			fmt.Fprint(buf, "\t\thc.RequestHeader(\"Content-Type\", \"application/json\"),\n")
+		}
+		for _, key := range sortedKeys(req.Headers) {
+			fmt.Fprintf(buf, "\t\thc.RequestHeader(%s, %s),\n", goString(key), goString(req.Headers[key]))
+		}
+		if expect := step.Expect; expect == nil {
+			fmt.Fprint(buf, "\t\thc.Call(),\n")
+		} else {
+			fmt.Fprintf(buf, "\t\thc.ResponseStatusEquals(%d),\n", expect.Status)
+			if expect.JSONSchema != "" {
+				fmt.Fprintf(buf, "\t\thc.ResponseBodyJSONSchema(%s),\n", goString(expect.JSONSchema))
+			}
+		}
+		fmt.Fprint(buf, "\t}\n}\n")
+	}
+	header := new(bytes.Buffer)
+	fmt.Fprintf(header, "// Code generated by argot from an OpenAPI document. Edit as required.\n\npackage %s\n\nimport (\n", pkg)
+	if usesStrings {
+		fmt.Fprint(header, "\t\"strings\"\n\n")
+	}
+	fmt.Fprint(header, "\t\"github.com/msackman/argot\"\n)\n")
+	return format.Source(append(header.Bytes(), buf.Bytes()...))
+}
+
+// goString returns str as a Go string literal, raw if possible.
+func goString(str string) string {
+	if !strconv.CanBackquote(str) {
+		return strconv.Quote(str)
+	} else {
+		return "`" + str + "`"
+	}
+}
+
+// goIdentifier converts name, such as an operationId or "GET
+// /users/{id}", into an exported Go identifier, such as GetUsersId.
+func goIdentifier(name string) string {
+	result := []rune{}
+	upper := true
+	var previous rune
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+		} else if upper {
+			result = append(result, unicode.ToUpper(r))
+			upper = false
+		} else if unicode.IsUpper(previous) && unicode.IsUpper(r) {
+			result = append(result, unicode.ToLower(r))
+		} else {
+			result = append(result, r)
+		}
+		previous = r
+	}
+	if len(result) == 0 || !unicode.IsLetter(result[0]) {
+		result = append([]rune("Op"), result...)
+	}
+	return string(result)
+}
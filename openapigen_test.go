@@ -0,0 +1,89 @@
+package argot
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIScenarios(t *testing.T) {
+	spec, err := NewOpenAPISpec([]byte(strings.Replace(testOpenAPISpec, `"put": {`, `"get": {
+        "operationId": "getPet",
+        "parameters": [{"name": "X-Tenant", "in": "header", "required": true, "example": "acme"}],
+        "responses": {"200": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}}}}, "404": {}}
+      },
+      "put": {`, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scenarios := spec.Scenarios()
+	if len(scenarios) != 2 || scenarios[0].Name != "getPet" || scenarios[1].Name != "PUT /pets/{petId}" {
+		t.Fatalf("Unexpected scenarios: %v", scenarios)
+	}
+	get, put := scenarios[0], scenarios[1]
+	if req := get.Steps[0].Request; req.URL != "/pets/${petId}" || req.Headers["X-Tenant"] != "acme" || get.Vars["petId"] != "1" {
+		t.Fatalf("Unexpected request: %+v (vars %v)", req, get.Vars)
+	} else if req := put.Steps[0].Request; req.Method != "PUT" || exampleString(req.JSON) != `{"name":"string"}` {
+		t.Fatalf("Unexpected request: %+v", req)
+	} else if expect := put.Steps[0].Expect; expect.Status != 200 || !strings.Contains(expect.JSONSchema, `"required":["name"]`) {
+		t.Fatalf("Unexpected expectations: %+v", expect)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/pets/1" {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.Write([]byte(`{"name": "rex"}`))
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "argot-openapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "get.yaml")
+	if err := get.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadScenario(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	store := NewStore()
+	store.Set("baseURL", server.URL)
+	loaded.Build(hc, store).Test(t)
+
+	source, err := spec.GoSource("api")
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err := parser.ParseFile(token.NewFileSet(), "api.go", source, 0); err != nil {
+		t.Fatalf("%v:\n%s", err, source)
+	} else if src := string(source); !strings.Contains(src, "func GetPet(hc *argot.HttpCall, baseURL string) argot.Steps {") ||
+		!strings.Contains(src, "func PutPetsPetId(") || !strings.Contains(src, "hc.NewRequest(\"GET\", baseURL+`/pets/1`, nil)") ||
+		!strings.Contains(src, "strings.NewReader(`{\"name\":\"string\"}`)") {
+		t.Fatalf("Unexpected source:\n%s", source)
+	}
+}
+
+func TestGoIdentifier(t *testing.T) {
+	for name, expected := range map[string]string{
+		"listUsers":       "ListUsers",
+		"GET /users/{id}": "GetUsersId",
+		"2fa-enable":      "Op2faEnable",
+	} {
+		if found := goIdentifier(name); found != expected {
+			t.Errorf("%s: Expected %s; found %s.", name, expected, found)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package argot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Pact collects the interactions performed by HttpCalls so that they
+// can be written out as a Pact (specification version 2) consumer
+// contract. Set an HttpCall's Pact field to record its interactions;
+// a single Pact may be shared by several HttpCalls.
+type Pact struct {
+	// Consumer is the name of the consumer (the suite).
+	Consumer string
+	// Provider is the name of the provider (the service under test).
+	Provider string
+	// Dir is the directory in which the contract is written. If
+	// empty, "pacts" is used.
+	Dir string
+	// ResponseHeaders lists the response headers which are included
+	// in the contract. NewPact sets this to just Content-Type.
+	ResponseHeaders []string
+
+	lock         sync.Mutex
+	interactions []*pactInteraction
+	descriptions map[string]int
+}
+
+type pactInteraction struct {
+	Description string       `json:"description"`
+	Request     pactRequest  `json:"request"`
+	Response    pactResponse `json:"response"`
+}
+
+type pactRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   string            `json:"query,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+}
+
+type pactResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+}
+
+// NewPact creates a new Pact between the consumer and provider.
+func NewPact(consumer, provider string) *Pact {
+	return &Pact{
+		Consumer:        consumer,
+		Provider:        provider,
+		ResponseHeaders: []string{"Content-Type"},
+	}
+}
+
+func pactBody(body []byte, redactor *Redactor) interface{} {
+	var value interface{}
+	redacted := redactor.String(string(body))
+	if len(body) == 0 {
+		return nil
+	} else if err := json.Unmarshal([]byte(redacted), &value); err == nil {
+		return value
+	} else {
+		return redacted
+	}
+}
+
+func pactHeaders(header http.Header, keys []string, redactor *Redactor) map[string]string {
+	headers := make(map[string]string)
+	for _, key := range keys {
+		if values, found := header[http.CanonicalHeaderKey(key)]; found {
+			headers[http.CanonicalHeaderKey(key)] = redactor.HeaderValue(key, strings.Join(values, ", "))
+		}
+	}
+	return headers
+}
+
+// record adds the current interaction of hc to the pact. The
+// interaction is described by the name of the step that created the
+// request, with the scheme and host removed so that the description
+// is stable across environments. Request headers whose values are
+// redacted by hc's Redactor, such as Authorization and Cookie, are
+// left out, and the query, the other headers and the bodies are
+// redacted by it, so that credentials are not written into the
+// contract.
+func (p *Pact) record(hc *HttpCall) error {
+	requestBody, err := readRequestBody(hc.Request)
+	if err != nil {
+		return err
+	} else if err := hc.ReceiveBody(); err != nil {
+		return err
+	}
+	redactor := hc.redactor()
+	requestHeaders := make([]string, 0, len(hc.Request.Header))
+	for key := range hc.Request.Header {
+		if !redactor.RedactsHeader(key) {
+			requestHeaders = append(requestHeaders, key)
+		}
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.descriptions == nil {
+		p.descriptions = make(map[string]int)
+	}
+	origin := hc.Request.URL.Scheme + "://" + hc.Request.URL.Host
+	description := strings.Replace(hc.requestName, origin, "", 1)
+	if description == "" {
+		description = fmt.Sprintf("%s %s", hc.Request.Method, hc.Request.URL.Path)
+	}
+	p.descriptions[description]++
+	if count := p.descriptions[description]; count > 1 {
+		description = fmt.Sprintf("%s (%d)", description, count)
+	}
+	p.interactions = append(p.interactions, &pactInteraction{
+		Description: description,
+		Request: pactRequest{
+			Method:  hc.Request.Method,
+			Path:    hc.Request.URL.Path,
+			Query:   redactor.String(hc.Request.URL.RawQuery),
+			Headers: pactHeaders(hc.Request.Header, requestHeaders, redactor),
+			Body:    pactBody(requestBody, redactor),
+		},
+		Response: pactResponse{
+			Status:  hc.Response.StatusCode,
+			Headers: pactHeaders(hc.Response.Header, p.ResponseHeaders, redactor),
+			Body:    pactBody(hc.ResponseBody, redactor),
+		},
+	})
+	return nil
+}
+
+// Write writes the contract to Dir/consumer-provider.json.
+func (p *Pact) Write() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	contract := map[string]interface{}{
+		"consumer":     map[string]string{"name": p.Consumer},
+		"provider":     map[string]string{"name": p.Provider},
+		"interactions": p.interactions,
+		"metadata": map[string]interface{}{
+			"pactSpecification": map[string]string{"version": "2.0.0"},
+		},
+	}
+	dir := p.Dir
+	if dir == "" {
+		dir = "pacts"
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.json", p.Consumer, p.Provider))
+	if bites, err := json.MarshalIndent(contract, "", "  "); err != nil {
+		return err
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	} else {
+		return ioutil.WriteFile(path, append(bites, '\n'), 0644)
+	}
+}
@@ -0,0 +1,78 @@
+package argot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "argot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Ignored", "yes")
+		w.Header().Set("Set-Cookie", "session=hunter2")
+		w.Write([]byte(`{"name": "earl grey", "token": "hunter2"}`))
+	}))
+	defer server.Close()
+
+	pact := NewPact("shop", "teas")
+	pact.Dir = dir
+	pact.ResponseHeaders = append(pact.ResponseHeaders, "Set-Cookie")
+	hc := NewHttpCall(nil)
+	hc.Pact = pact
+	defer hc.Reset()
+	for idx := 0; idx < 2; idx++ {
+		Steps{
+			hc.NewRequest("POST", server.URL+"/teas/1?fields=name&api_key=hunter2", strings.NewReader(`{"password": "hunter2"}`)),
+			hc.RequestHeader("Authorization", "Bearer hunter2"),
+			hc.RequestHeader("Cookie", "session=hunter2"),
+			hc.RequestHeader("Accept", "application/json"),
+			hc.ResponseStatusEquals(http.StatusOK),
+		}.Test(t)
+		hc.Reset()
+	}
+	if err := pact.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	bites, err := ioutil.ReadFile(filepath.Join(dir, "shop-teas.json"))
+	if err != nil {
+		t.Fatal(err)
+	} else if strings.Contains(string(bites), "hunter2") {
+		t.Fatalf("Expected credentials to be left out of the contract:\n%s", bites)
+	}
+	var contract struct {
+		Consumer     map[string]string `json:"consumer"`
+		Interactions []pactInteraction `json:"interactions"`
+	}
+	if err := json.Unmarshal(bites, &contract); err != nil {
+		t.Fatal(err)
+	} else if contract.Consumer["name"] != "shop" || len(contract.Interactions) != 2 {
+		t.Fatalf("Unexpected contract:\n%s", bites)
+	}
+	first, second := contract.Interactions[0], contract.Interactions[1]
+	if first.Description != "NewRequest(POST: /teas/1?fields=name&api_key=REDACTED)" || second.Description != first.Description+" (2)" {
+		t.Fatalf("Unexpected descriptions: %q, %q", first.Description, second.Description)
+	} else if first.Request.Path != "/teas/1" || first.Request.Query != "fields=name&api_key=REDACTED" {
+		t.Fatalf("Unexpected request: %+v", first.Request)
+	} else if body, ok := first.Request.Body.(map[string]interface{}); !ok || body["password"] != "REDACTED" {
+		t.Fatalf("Expected the request body to be redacted; found %v", first.Request.Body)
+	} else if len(first.Request.Headers) != 1 || first.Request.Headers["Accept"] != "application/json" {
+		t.Fatalf("Expected only the Accept request header; found %v", first.Request.Headers)
+	} else if len(first.Response.Headers) != 2 || first.Response.Headers["Content-Type"] != "application/json" || first.Response.Headers["Set-Cookie"] != "REDACTED" {
+		t.Fatalf("Expected only the listed response headers, redacted; found %v", first.Response.Headers)
+	} else if body, ok := first.Response.Body.(map[string]interface{}); !ok || body["name"] != "earl grey" || body["token"] != "REDACTED" {
+		t.Fatalf("Unexpected response body: %v", first.Response.Body)
+	}
+}
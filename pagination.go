@@ -0,0 +1,191 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// JSONExtractor extracts a value from a decoded JSON document (as
+// produced by encoding/json decoding into an interface{}, with numbers
+// as json.Number).
+type JSONExtractor func(doc interface{}) (interface{}, error)
+
+// JSONPathExtractor returns a JSONExtractor which extracts the value at
+// path (see JSONPath).
+func JSONPathExtractor(path string) JSONExtractor {
+	return func(doc interface{}) (interface{}, error) {
+		return JSONPath(doc, path)
+	}
+}
+
+// defaultMaxPages is the default of Pagination.MaxPages.
+const defaultMaxPages = 1000
+
+// Pagination describes a paginated endpoint, either cursor or offset
+// based, to be walked by WalkPages, and the invariants which must
+// hold across all of its pages. Once walked, the items found are
+// available from Results.
+type Pagination struct {
+	// Page returns the Step which creates the request for a page, for
+	// example with NewRequest and RequestHeader. For the first page,
+	// cursor is empty and offset is zero; thereafter cursor is the
+	// previous page's next cursor, and offset the number of items
+	// received so far.
+	Page func(cursor string, offset int) Step
+	// Items extracts the array of a page's items. Required.
+	Items JSONExtractor
+	// NextCursor extracts the cursor of the next page. The walk ends
+	// when the cursor is null or empty, or cannot be extracted. If
+	// nil, pagination is offset based, and the walk ends with an empty
+	// page.
+	NextCursor JSONExtractor
+	// If positive, a page with fewer items than this ends the walk.
+	PageSize int
+	// If non-nil, extracts from the first page the total number of
+	// items, which must equal the number found across all pages.
+	Total JSONExtractor
+	// If non-nil, extracts each item's ID. IDs must be unique across
+	// all pages.
+	ID JSONExtractor
+	// If non-nil, extracts each item's sort key. Keys must be in
+	// ascending (or, if Descending, descending) order across all pages.
+	// Numbers are ordered numerically and other values by their
+	// formatting.
+	OrderBy    JSONExtractor
+	Descending bool
+	// The maximum number of pages to walk, so that an endpoint which
+	// never ends fails. If zero, 1000.
+	MaxPages int
+
+	items []interface{}
+	pages int
+}
+
+// Results returns the items found across all pages by the last walk.
+func (p *Pagination) Results() []interface{} {
+	return p.items
+}
+
+// Pages returns the number of pages requested by the last walk.
+func (p *Pagination) Pages() int {
+	return p.pages
+}
+
+// WalkPages is a Step that when executed requests every page of the
+// paginated endpoint described by p, using hc, and errors if any page
+// does not have status 200, or unless the invariants of p hold across
+// all the pages: the total number of items, unique IDs and ordering.
+func (hc *HttpCall) WalkPages(p *Pagination) Step {
+	return hc.step("WalkPages", func() error {
+		p.items, p.pages = nil, 0
+		if p.Page == nil || p.Items == nil {
+			return errors.New("Pagination: Page and Items must be set.")
+		}
+		maxPages := p.MaxPages
+		if maxPages <= 0 {
+			maxPages = defaultMaxPages
+		}
+		total := -1
+		seen := make(map[string]int)
+		var last interface{}
+		cursor := ""
+		for {
+			if p.pages == maxPages {
+				return fmt.Errorf("Pagination: Expected at most %d pages; found more.", maxPages)
+			}
+			p.pages++
+			doc, err := hc.page(p.Page(cursor, len(p.items)))
+			if err != nil {
+				return fmt.Errorf("Pagination: Page %d: %v", p.pages, err)
+			}
+			value, err := p.Items(doc)
+			if err != nil {
+				return fmt.Errorf("Pagination: Page %d: %v", p.pages, err)
+			}
+			items, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("Pagination: Page %d: Expected an array of items; found %T.", p.pages, value)
+			}
+			if p.pages == 1 && p.Total != nil {
+				if total, err = extractInt(p.Total, doc); err != nil {
+					return fmt.Errorf("Pagination: Total: %v", err)
+				}
+			}
+			for _, item := range items {
+				if p.ID != nil {
+					if id, err := p.ID(item); err != nil {
+						return fmt.Errorf("Pagination: Page %d: ID: %v", p.pages, err)
+					} else if page, found := seen[jsonString(id)]; found {
+						return fmt.Errorf("Pagination: Page %d: Duplicate ID %s, first found on page %d.", p.pages, jsonString(id), page)
+					} else {
+						seen[jsonString(id)] = p.pages
+					}
+				}
+				if p.OrderBy != nil {
+					key, err := p.OrderBy(item)
+					if err != nil {
+						return fmt.Errorf("Pagination: Page %d: OrderBy: %v", p.pages, err)
+					} else if len(p.items) > 0 && (!p.Descending && jsonLess(key, last) || p.Descending && jsonLess(last, key)) {
+						return fmt.Errorf("Pagination: Page %d: Item %d out of order: %s after %s.", p.pages, len(p.items), jsonString(key), jsonString(last))
+					}
+					last = key
+				}
+				p.items = append(p.items, item)
+			}
+			if p.NextCursor != nil {
+				if next, err := p.NextCursor(doc); err != nil || next == nil || fmt.Sprint(next) == "" {
+					break
+				} else if next := fmt.Sprint(next); next == cursor {
+					return fmt.Errorf("Pagination: Page %d: Next cursor '%s' repeats the current cursor.", p.pages, next)
+				} else {
+					cursor = next
+				}
+			} else if len(items) == 0 || p.PageSize > 0 && len(items) < p.PageSize {
+				break
+			}
+		}
+		if total >= 0 && total != len(p.items) {
+			return fmt.Errorf("Pagination: Expected %d items in total; found %d in %d pages.", total, len(p.items), p.pages)
+		}
+		return nil
+	})
+}
+
+// page runs step to create the request for a page, and returns the
+// decoded body of its response. Errors are not decorated as
+// HttpCallErrors, as WalkPages is itself an HttpCall step.
+func (hc *HttpCall) page(step Step) (interface{}, error) {
+	var doc interface{}
+	err := step.Go()
+	if hcErr, ok := err.(*HttpCallError); ok {
+		err = hcErr.Err
+	}
+	if err != nil {
+		return nil, err
+	} else if err := hc.ReceiveBody(); err != nil {
+		return nil, err
+	} else if hc.Response.StatusCode != 200 {
+		return nil, fmt.Errorf("Expected status 200; found %d.", hc.Response.StatusCode)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(hc.ResponseBody))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	} else {
+		return doc, nil
+	}
+}
+
+// extractInt extracts an integer from doc.
+func extractInt(extractor JSONExtractor, doc interface{}) (int, error) {
+	if value, err := extractor(doc); err != nil {
+		return 0, err
+	} else if n, err := strconv.Atoi(fmt.Sprint(value)); err != nil {
+		return 0, fmt.Errorf("Expected an integer; found %s.", jsonString(value))
+	} else {
+		return n, nil
+	}
+}
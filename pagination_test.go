@@ -0,0 +1,93 @@
+package argot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWalkPages(t *testing.T) {
+	ids := []int{1, 2, 3, 4, 5, 6, 7}
+	overlap := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+			start, _ = strconv.Atoi(cursor)
+		}
+		if overlap && start > 0 {
+			start--
+		}
+		end := start + 3
+		if end > len(ids) {
+			end = len(ids)
+		}
+		items := []map[string]int{}
+		for _, id := range ids[start:end] {
+			items = append(items, map[string]int{"id": id})
+		}
+		page := map[string]interface{}{"data": items, "meta": map[string]interface{}{"total": len(ids)}}
+		if end < len(ids) {
+			page["next"] = strconv.Itoa(end)
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	offset := &Pagination{
+		Page: func(cursor string, offset int) Step {
+			return hc.NewRequest("GET", fmt.Sprintf("%s?offset=%d", server.URL, offset), nil)
+		},
+		Items:   JSONPathExtractor("data"),
+		Total:   JSONPathExtractor("meta.total"),
+		ID:      JSONPathExtractor("id"),
+		OrderBy: JSONPathExtractor("id"),
+	}
+	cursor := &Pagination{
+		Page: func(cursor string, offset int) Step {
+			return hc.NewRequest("GET", server.URL+"?cursor="+cursor, nil)
+		},
+		Items:      JSONPathExtractor("data"),
+		NextCursor: JSONPathExtractor("next"),
+		Total:      JSONPathExtractor("meta.total"),
+		ID:         JSONPathExtractor("id"),
+	}
+	Steps{
+		hc.WalkPages(offset),
+		hc.WalkPages(cursor),
+	}.Test(t)
+	if offset.Pages() != 4 || len(offset.Results()) != 7 {
+		t.Fatalf("Offset: Expected 7 items in 4 pages; found %d in %d.", len(offset.Results()), offset.Pages())
+	} else if cursor.Pages() != 3 || len(cursor.Results()) != 7 {
+		t.Fatalf("Cursor: Expected 7 items in 3 pages; found %d in %d.", len(cursor.Results()), cursor.Pages())
+	}
+
+	offset.PageSize = 3
+	Steps{hc.WalkPages(offset)}.Test(t)
+	if offset.Pages() != 3 {
+		t.Fatalf("Expected a short page to end the walk; found %d pages.", offset.Pages())
+	}
+
+	overlap = true
+	if err := hc.WalkPages(cursor).Go(); err == nil || !strings.Contains(err.Error(), "Duplicate ID 3, first found on page 1.") {
+		t.Fatalf("Expected a duplicate ID to be found; found %v", err)
+	}
+	overlap = false
+	ids = []int{1, 2, 3, 5, 4}
+	if err := hc.WalkPages(offset).Go(); err == nil || !strings.Contains(err.Error(), "Item 4 out of order: 4 after 5.") {
+		t.Fatalf("Expected misordering to be found; found %v", err)
+	}
+	offset.OrderBy, offset.Total = nil, JSONPathExtractor("meta.count")
+	if err := hc.WalkPages(offset).Go(); err == nil || !strings.Contains(err.Error(), "Total") {
+		t.Fatalf("Expected a missing total to be an error; found %v", err)
+	}
+	cursor.MaxPages = 1
+	if err := hc.WalkPages(cursor).Go(); err == nil || !strings.Contains(err.Error(), "at most 1 pages") {
+		t.Fatalf("Expected MaxPages to bound the walk; found %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package argot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// AbusivePayload is a request body designed to exhaust a server which
+// does not limit what it accepts, such as an oversized or deeply
+// nested JSON document, or a gzip bomb. See HttpCall.PayloadRejected.
+type AbusivePayload struct {
+	Name            string
+	ContentType     string
+	ContentEncoding string
+	// Length is sent as the Content-Length. If zero, the body is sent
+	// chunked, so that the server cannot reject it from its headers
+	// alone.
+	Length int64
+	// Body returns a new reader of the body, which is generated as it
+	// is sent rather than held in memory.
+	Body func() io.Reader
+	// Statuses are those with which the server may reject the payload.
+	Statuses []int
+}
+
+// byteRepeater is an endless reader of a single byte.
+type byteRepeater byte
+
+func (br byteRepeater) Read(buf []byte) (int, error) {
+	for idx := range buf {
+		buf[idx] = byte(br)
+	}
+	return len(buf), nil
+}
+
+// OversizedJSONPayload returns an AbusivePayload of a JSON object
+// of size bytes, almost all of them a single string value, which
+// should be rejected with 413 Payload Too Large (or 400 Bad Request).
+func OversizedJSONPayload(size int64) AbusivePayload {
+	prefix, suffix := `{"data": "`, `"}`
+	padding := size - int64(len(prefix)+len(suffix))
+	if padding < 0 {
+		padding = 0
+	}
+	return AbusivePayload{
+		Name:        fmt.Sprintf("OversizedJSON(%d)", size),
+		ContentType: "application/json",
+		Length:      int64(len(prefix)+len(suffix)) + padding,
+		Body: func() io.Reader {
+			return io.MultiReader(strings.NewReader(prefix), io.LimitReader(byteRepeater('a'), padding), strings.NewReader(suffix))
+		},
+		Statuses: []int{http.StatusRequestEntityTooLarge, http.StatusBadRequest},
+	}
+}
+
+// NestedJSONPayload returns an AbusivePayload of a JSON document of
+// depth nested arrays, which should be rejected with 400 Bad Request
+// (or 413 Payload Too Large) rather than overflow the parser's stack.
+func NestedJSONPayload(depth int) AbusivePayload {
+	return AbusivePayload{
+		Name:        fmt.Sprintf("NestedJSON(%d)", depth),
+		ContentType: "application/json",
+		Length:      2 * int64(depth),
+		Body: func() io.Reader {
+			return io.MultiReader(io.LimitReader(byteRepeater('['), int64(depth)), io.LimitReader(byteRepeater(']'), int64(depth)))
+		},
+		Statuses: []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge},
+	}
+}
+
+// GzipBombPayload returns an AbusivePayload of a gzipped JSON object
+// which is small on the wire but decompresses to size bytes, and
+// which should be rejected with 413 Payload Too Large, 400 Bad
+// Request, or, by a server which does not accept compressed bodies,
+// 415 Unsupported Media Type. The payload is compressed when this is
+// called, which takes time proportional to size.
+func GzipBombPayload(size int64) (AbusivePayload, error) {
+	compressed := new(bytes.Buffer)
+	writer, err := gzip.NewWriterLevel(compressed, gzip.BestCompression)
+	if err != nil {
+		return AbusivePayload{}, err
+	} else if _, err := io.Copy(writer, OversizedJSONPayload(size).Body()); err != nil {
+		return AbusivePayload{}, err
+	} else if err := writer.Close(); err != nil {
+		return AbusivePayload{}, err
+	}
+	bites := compressed.Bytes()
+	return AbusivePayload{
+		Name:            fmt.Sprintf("GzipBomb(%d)", size),
+		ContentType:     "application/json",
+		ContentEncoding: "gzip",
+		Length:          int64(len(bites)),
+		Body:            func() io.Reader { return bytes.NewReader(bites) },
+		Statuses:        []int{http.StatusRequestEntityTooLarge, http.StatusBadRequest, http.StatusUnsupportedMediaType},
+	}, nil
+}
+
+// connectionRefused returns true iff err shows that the server closed
+// the connection whilst the request was being sent, as a server may
+// do to a request it will not read.
+func connectionRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// PayloadRejected is a Step that when executed sends payload to urlStr
+// with method, and errors unless, within budget, the server rejects it
+// with one of the payload's Statuses, or closes the connection before
+// the payload has been sent, rather than consuming resources without
+// limit. The response, if any, becomes hc.Response.
+func (hc *HttpCall) PayloadRejected(method, urlStr string, payload AbusivePayload, budget time.Duration) Step {
+	return hc.step(fmt.Sprintf("PayloadRejected(%s %s: %s within %v)", method, hc.redactor().String(urlStr), payload.Name, budget), func() error {
+		steps := Steps{
+			hc.NewRequest(method, urlStr, StreamingBody(payload.Body())),
+			hc.RequestHeader("Content-Type", payload.ContentType),
+		}
+		if payload.ContentEncoding != "" {
+			steps = append(steps, hc.RequestHeader("Content-Encoding", payload.ContentEncoding))
+		}
+		if err := steps.Go(); err != nil {
+			return err
+		}
+		hc.Request.ContentLength = payload.Length
+		ctx, cancel := context.WithTimeout(hc.Request.Context(), budget)
+		defer cancel()
+		hc.Request = hc.Request.WithContext(ctx)
+		start := time.Now()
+		err := hc.ReceiveBody()
+		elapsed := time.Since(start)
+		if errors.Is(err, context.DeadlineExceeded) || elapsed > budget {
+			return fmt.Errorf("Payload %s: Expected to be rejected within %v; found no response after %v.", payload.Name, budget, elapsed.Round(time.Millisecond))
+		} else if err != nil && hc.Response == nil && connectionRefused(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		for _, status := range payload.Statuses {
+			if hc.Response.StatusCode == status {
+				return nil
+			}
+		}
+		return fmt.Errorf("Payload %s: Status: Expected one of %v; found %d after %v.", payload.Name, payload.Statuses, hc.Response.StatusCode, elapsed.Round(time.Millisecond))
+	})
+}
@@ -0,0 +1,80 @@
+package argot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPayloadRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = http.MaxBytesReader(w, r.Body, 64*1024)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			reader, err := gzip.NewReader(body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = io.LimitReader(reader, 64*1024+1)
+		}
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(300 * time.Millisecond)
+			io.Copy(ioutil.Discard, r.Body)
+		case "/accepting":
+			io.Copy(ioutil.Discard, r.Body)
+		default:
+			depth := 0
+			reader := bufio.NewReader(body)
+			for total := 0; ; total++ {
+				if c, err := reader.ReadByte(); err == io.EOF {
+					return
+				} else if err != nil || total >= 64*1024 {
+					w.WriteHeader(http.StatusRequestEntityTooLarge)
+					return
+				} else if c == '[' {
+					if depth++; depth > 32 {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+				}
+			}
+		}
+	}))
+	defer server.Close()
+
+	bomb, err := GzipBombPayload(10 * 1024 * 1024)
+	if err != nil {
+		t.Fatal(err)
+	} else if bomb.Length > 64*1024 {
+		t.Fatalf("Expected the bomb to be small; found %d bytes.", bomb.Length)
+	}
+	chunked := OversizedJSONPayload(1024 * 1024)
+	chunked.Length = 0
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.PayloadRejected("POST", server.URL, OversizedJSONPayload(1024*1024), 5*time.Second),
+		hc.PayloadRejected("POST", server.URL, chunked, 5*time.Second),
+		hc.PayloadRejected("POST", server.URL, NestedJSONPayload(100000), 5*time.Second),
+		hc.PayloadRejected("POST", server.URL, bomb, 5*time.Second),
+	}.Test(t)
+
+	for path, expected := range map[string]string{
+		"/slow":      "Expected to be rejected within 100ms",
+		"/accepting": "Status: Expected one of [413 400]; found 200",
+	} {
+		if _, err := (Steps{hc.PayloadRejected("POST", server.URL+path, OversizedJSONPayload(1024), 100*time.Millisecond)}).Test(nil); err == nil {
+			t.Fatalf("%s: Expected an error.", path)
+		} else if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%s: Expected %q; found %v", path, expected, err)
+		}
+	}
+}
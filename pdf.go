@@ -0,0 +1,348 @@
+package argot
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	pdfStreamPattern = regexp.MustCompile(`>>\s*stream\r?\n`)
+	pdfPagePattern   = regexp.MustCompile(`/Type\s*/Page\b`)
+)
+
+// pdfDocument is the result of a best-effort parse of a PDF: enough
+// to check that a response is a PDF, count its pages and find its
+// text, but not to render it.
+type pdfDocument struct {
+	streams [][]byte
+	pages   int
+	text    string
+}
+
+// parsePDF parses body as a PDF. It errors unless body has a PDF
+// header, a cross-reference offset and an end of file marker, and
+// every FlateDecode stream (such as a compressed page content or
+// object stream) decompresses. Pages are counted from the page
+// objects, whether or not they are within object streams. Text is
+// extracted from the text showing operators of the content streams,
+// without regard to fonts, so text in fonts with custom encodings is
+// not found.
+func parsePDF(body []byte) (*pdfDocument, error) {
+	if !bytes.HasPrefix(body, []byte("%PDF-")) {
+		return nil, fmt.Errorf("PDF: Expected a PDF header; found '%s'.", fuzzValueName(string(body[:minInt(len(body), 16)])))
+	}
+	doc := new(pdfDocument)
+	if tail := body[len(body)-minInt(len(body), 1024):]; !bytes.Contains(tail, []byte("%%EOF")) {
+		return nil, fmt.Errorf("PDF: Expected an end of file marker; the document may be truncated.")
+	} else if !bytes.Contains(tail, []byte("startxref")) {
+		return nil, fmt.Errorf("PDF: Expected a cross-reference offset.")
+	}
+	objects := [][]byte{body}
+	for _, match := range pdfStreamPattern.FindAllIndex(body, -1) {
+		// The stream's dictionary is that of its object.
+		dict, start := body[bytes.LastIndex(body[:match[0]], []byte("obj"))+1:match[0]], match[1]
+		end := bytes.Index(body[start:], []byte("endstream"))
+		if end < 0 {
+			return nil, fmt.Errorf("PDF: Unterminated stream at offset %d.", start)
+		}
+		data := bytes.TrimRight(body[start:start+end], "\r\n")
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			reader, err := zlib.NewReader(bytes.NewReader(data))
+			if err == nil {
+				data, err = ioutil.ReadAll(reader)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("PDF: Stream at offset %d: %v", start, err)
+			}
+			// Uncompressed streams were already searched for pages
+			// as part of body.
+			objects = append(objects, data)
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue
+		}
+		doc.streams = append(doc.streams, data)
+	}
+	text := new(strings.Builder)
+	for _, object := range objects {
+		doc.pages += len(pdfPagePattern.FindAll(object, -1))
+	}
+	for _, stream := range doc.streams {
+		pdfText(stream, text)
+	}
+	doc.text = text.String()
+	return doc, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	} else {
+		return b
+	}
+}
+
+// pdfText appends the text shown by the content stream to text, with
+// a space wherever the text position moves.
+func pdfText(stream []byte, text *strings.Builder) {
+	var operands []interface{}
+	for idx := 0; idx < len(stream); {
+		c := stream[idx]
+		switch {
+		case c == '(':
+			str, next := pdfLiteralString(stream, idx)
+			operands = append(operands, str)
+			idx = next
+		case c == '<' && idx+1 < len(stream) && stream[idx+1] == '<', c == '>' && idx+1 < len(stream) && stream[idx+1] == '>':
+			idx += 2
+		case c == '<':
+			end := bytes.IndexByte(stream[idx:], '>')
+			if end < 0 {
+				return
+			}
+			operands = append(operands, pdfHexString(stream[idx+1:idx+end]))
+			idx += end + 1
+		case c == '[':
+			operands = append(operands, "[")
+			idx++
+		case c == ']':
+			array := []interface{}{}
+			for len(operands) > 0 {
+				last := operands[len(operands)-1]
+				operands = operands[:len(operands)-1]
+				if last == "[" {
+					break
+				}
+				array = append([]interface{}{last}, array...)
+			}
+			operands = append(operands, array)
+			idx++
+		case c == '%':
+			for idx < len(stream) && stream[idx] != '\n' && stream[idx] != '\r' {
+				idx++
+			}
+		case isPDFSpace(c) || c == '>' || c == '{' || c == '}':
+			idx++
+		default:
+			start := idx
+			for idx++; idx < len(stream) && !isPDFSpace(stream[idx]) && !bytes.ContainsRune([]byte("()<>[]{}/%"), rune(stream[idx])); idx++ {
+			}
+			token := string(stream[start:idx])
+			if c == '/' {
+				operands = append(operands, token)
+				continue
+			} else if c == '-' || c == '+' || c == '.' || c >= '0' && c <= '9' {
+				operands = append(operands, pdfNumber(token))
+				continue
+			}
+			pdfOperator(token, operands, text)
+			operands = operands[:0]
+		}
+	}
+}
+
+// pdfNumber parses a numeric token, returning zero should it be
+// malformed.
+func pdfNumber(token string) float64 {
+	var n float64
+	fmt.Sscan(token, &n)
+	return n
+}
+
+// pdfOperator appends the text shown by the operator to text.
+func pdfOperator(operator string, operands []interface{}, text *strings.Builder) {
+	space := func() {
+		if str := text.String(); len(str) > 0 && !strings.HasSuffix(str, " ") {
+			text.WriteByte(' ')
+		}
+	}
+	switch operator {
+	case "Tj", "'", "\"":
+		if operator != "Tj" {
+			space()
+		}
+		if len(operands) > 0 {
+			if str, ok := operands[len(operands)-1].(pdfString); ok {
+				text.WriteString(string(str))
+			}
+		}
+	case "TJ":
+		if len(operands) > 0 {
+			if array, ok := operands[len(operands)-1].([]interface{}); ok {
+				for _, elem := range array {
+					if str, ok := elem.(pdfString); ok {
+						text.WriteString(string(str))
+					} else if n, ok := elem.(float64); ok && n < -200 {
+						space()
+					}
+				}
+			}
+		}
+	case "Td", "TD", "T*", "Tm", "ET", "BT":
+		space()
+	}
+}
+
+// pdfString is a decoded PDF string operand.
+type pdfString string
+
+// pdfLiteralString decodes the literal string starting with the '('
+// at idx, returning it and the index following its ')'.
+func pdfLiteralString(stream []byte, idx int) (pdfString, int) {
+	buf := []byte{}
+	depth := 0
+	for idx++; idx < len(stream); idx++ {
+		c := stream[idx]
+		if c == '\\' && idx+1 < len(stream) {
+			idx++
+			switch e := stream[idx]; e {
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'b':
+				buf = append(buf, '\b')
+			case 'f':
+				buf = append(buf, '\f')
+			case '\r', '\n':
+				if e == '\r' && idx+1 < len(stream) && stream[idx+1] == '\n' {
+					idx++
+				}
+			default:
+				if e >= '0' && e <= '7' {
+					n := 0
+					for end := idx + 3; idx < end && idx < len(stream) && stream[idx] >= '0' && stream[idx] <= '7'; idx++ {
+						n = n*8 + int(stream[idx]-'0')
+					}
+					idx--
+					buf = append(buf, byte(n))
+				} else {
+					buf = append(buf, e)
+				}
+			}
+		} else if c == '(' {
+			depth++
+			buf = append(buf, c)
+		} else if c == ')' {
+			if depth == 0 {
+				return pdfDecodeString(buf), idx + 1
+			}
+			depth--
+			buf = append(buf, c)
+		} else {
+			buf = append(buf, c)
+		}
+	}
+	return pdfDecodeString(buf), idx
+}
+
+// pdfHexString decodes the contents of a hexadecimal string.
+func pdfHexString(digits []byte) pdfString {
+	clean := make([]byte, 0, len(digits)+1)
+	for _, c := range digits {
+		if !isPDFSpace(c) {
+			clean = append(clean, c)
+		}
+	}
+	if len(clean)%2 == 1 {
+		clean = append(clean, '0')
+	}
+	buf, _ := hex.DecodeString(string(clean))
+	return pdfDecodeString(buf)
+}
+
+// pdfDecodeString decodes a string as UTF-16BE if it has a byte order
+// mark, and otherwise as Latin-1, which agrees with PDFDocEncoding for
+// printable ASCII.
+func pdfDecodeString(buf []byte) pdfString {
+	if len(buf) >= 2 && buf[0] == 0xfe && buf[1] == 0xff {
+		units := make([]uint16, 0, len(buf)/2)
+		for idx := 2; idx+1 < len(buf); idx += 2 {
+			units = append(units, uint16(buf[idx])<<8|uint16(buf[idx+1]))
+		}
+		return pdfString(utf16.Decode(units))
+	}
+	runes := make([]rune, len(buf))
+	for idx, b := range buf {
+		runes[idx] = rune(b)
+	}
+	return pdfString(runes)
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+// responsePDF parses the body as a PDF.
+func (hc *HttpCall) responsePDF() (*pdfDocument, error) {
+	if err := hc.ReceiveBody(); err != nil {
+		return nil, err
+	} else {
+		return parsePDF(hc.ResponseBody)
+	}
+}
+
+// ResponseBodyIsPDF is a Step that when executed ensures there is a
+// non-nil hc.ResponseBody and errors unless it is a parsable PDF: it
+// has a PDF header, a cross-reference offset and an end of file
+// marker, its compressed streams decompress, and it has at least one
+// page. This is a smoke test, not a validation against the PDF
+// specification.
+func (hc *HttpCall) ResponseBodyIsPDF() Step {
+	return hc.step("ResponseBodyIsPDF", func() error {
+		hc.cover("body:")
+		if doc, err := hc.responsePDF(); err != nil {
+			return err
+		} else if doc.pages == 0 {
+			return fmt.Errorf("PDF: Expected at least one page; found none.")
+		} else {
+			return nil
+		}
+	})
+}
+
+// ResponseBodyPDFPageCount is a Step that when executed ensures there
+// is a non-nil hc.ResponseBody and errors unless it is a parsable PDF
+// (see ResponseBodyIsPDF) with n pages.
+func (hc *HttpCall) ResponseBodyPDFPageCount(n int) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyPDFPageCount(%d)", n), func() error {
+		hc.cover("body:")
+		if doc, err := hc.responsePDF(); err != nil {
+			return err
+		} else if doc.pages != n {
+			return fmt.Errorf("PDF: Expected %d pages; found %d.", n, doc.pages)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ResponseBodyPDFTextContains is a Step that when executed ensures
+// there is a non-nil hc.ResponseBody and errors unless it is a
+// parsable PDF (see ResponseBodyIsPDF) whose text contains value.
+// Runs of whitespace in both the text and value are treated as a
+// single space, as the layout of text in a PDF rarely preserves it.
+// Text is extracted on a best-effort basis: text in fonts with custom
+// encodings, or within images, is not found.
+func (hc *HttpCall) ResponseBodyPDFTextContains(value string) Step {
+	return hc.step(fmt.Sprintf("ResponseBodyPDFTextContains(%s)", value), func() error {
+		hc.cover("body:")
+		doc, err := hc.responsePDF()
+		if err != nil {
+			return err
+		}
+		text, value := strings.Join(strings.Fields(doc.text), " "), strings.Join(strings.Fields(value), " ")
+		if !strings.Contains(text, value) {
+			return fmt.Errorf("PDF: Expected text to contain '%s'; found '%s'.", value, text)
+		} else {
+			return nil
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package argot
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testPDF builds a PDF with a page per content stream, compressing
+// the content streams if compress is true.
+func testPDF(compress bool, contents ...string) []byte {
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	kids := ""
+	for _, content := range contents {
+		page := len(objects) + 1
+		kids += fmt.Sprintf("%d 0 R ", page)
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents %d 0 R >>", page+1))
+		if compress {
+			buf := new(bytes.Buffer)
+			w := zlib.NewWriter(buf)
+			w.Write([]byte(content))
+			w.Close()
+			content = buf.String()
+			objects = append(objects, fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", len(content), content))
+		} else {
+			objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+		}
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(contents))
+	pdf := new(bytes.Buffer)
+	pdf.WriteString("%PDF-1.4\n")
+	offsets := []int{}
+	for idx, object := range objects {
+		offsets = append(offsets, pdf.Len())
+		fmt.Fprintf(pdf, "%d 0 obj\n%s\nendobj\n", idx+1, object)
+	}
+	xref := pdf.Len()
+	fmt.Fprintf(pdf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(pdf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(pdf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return pdf.Bytes()
+}
+
+func TestResponseBodyPDF(t *testing.T) {
+	invoice := "BT /F1 12 Tf 72 720 Td (Invoice \\(draft\\)) Tj 0 -14 Td [(To) -250 (tal:) -300 <FEFF00A3> (42.00)] TJ ET"
+	terms := "BT /F1 10 Tf 72 700 Td (Payment due\\nwithin 30 days) Tj T* <5468616e6b20796f75> Tj ET"
+	bodies := map[string][]byte{
+		"/plain":      testPDF(false, invoice, terms),
+		"/compressed": testPDF(true, invoice, terms),
+		"/truncated":  testPDF(false, invoice)[:200],
+		"/html":       []byte("<html>Invoice</html>"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bodies[r.URL.Path])
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	for _, path := range []string{"/plain", "/compressed"} {
+		Steps{
+			hc.NewRequest("GET", server.URL+path, nil),
+			hc.ResponseBodyIsPDF(),
+			hc.ResponseBodyPDFPageCount(2),
+			ExpectError(hc.ResponseBodyPDFPageCount(1)),
+			hc.ResponseBodyPDFTextContains("Invoice (draft)"),
+			hc.ResponseBodyPDFTextContains("To tal: £42.00"),
+			hc.ResponseBodyPDFTextContains("Payment due within 30 days Thank you"),
+			ExpectError(hc.ResponseBodyPDFTextContains("Overdue")),
+		}.Test(t)
+	}
+	for _, path := range []string{"/truncated", "/html"} {
+		Steps{
+			hc.NewRequest("GET", server.URL+path, nil),
+			ExpectError(hc.ResponseBodyIsPDF()),
+		}.Test(t)
+	}
+}
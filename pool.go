@@ -0,0 +1,90 @@
+package argot
+
+import (
+	"net/http"
+	"sync"
+)
+
+// HttpCallPool hands out HttpCalls to concurrent workers. As an
+// HttpCall can only be used by a single go-routine at a time,
+// concurrent scenarios (see Concurrently and LoadCalls) should take a
+// call from the pool for each run and return it afterwards. Calls
+// are Reset when they are returned, so no request or response state
+// leaks from one run to the next. An HttpCallPool is safe for
+// concurrent use.
+type HttpCallPool struct {
+	// New creates a new HttpCall when the pool is empty. Use it to
+	// configure calls, for example with a Redactor or DumpOnFailure.
+	New func() *HttpCall
+
+	lock    sync.Mutex
+	free    []*HttpCall
+	created int
+}
+
+// NewHttpCallPool creates a new HttpCallPool whose calls all share
+// client (an http.Client is safe for concurrent use, and sharing it
+// shares its connection pool). If client is nil, a new http.Client
+// is used.
+func NewHttpCallPool(client *http.Client) *HttpCallPool {
+	if client == nil {
+		client = new(http.Client)
+	}
+	return &HttpCallPool{New: func() *HttpCall { return NewHttpCall(client) }}
+}
+
+// Get removes an HttpCall from the pool, creating one if the pool is
+// empty. The caller has exclusive use of it until it is returned with
+// Put.
+func (p *HttpCallPool) Get() *HttpCall {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if n := len(p.free); n > 0 {
+		hc := p.free[n-1]
+		p.free = p.free[:n-1]
+		return hc
+	}
+	p.created++
+	return p.New()
+}
+
+// Put resets hc and returns it to the pool. hc must not be used by
+// the caller afterwards.
+func (p *HttpCallPool) Put(hc *HttpCall) error {
+	err := hc.Reset()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.free = append(p.free, hc)
+	return err
+}
+
+// Created returns the number of HttpCalls the pool has created: the
+// greatest number that have been in use at the same time.
+func (p *HttpCallPool) Created() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.created
+}
+
+// With is a Step that when executed takes an HttpCall from the pool,
+// passes it to build, runs the resulting steps, and returns the
+// HttpCall to the pool whether or not the steps succeeded. It errors
+// if any of the steps error. The step may be executed by several
+// go-routines at once. For example:
+//
+//	pool := NewHttpCallPool(nil)
+//	Concurrently(10, func(i int) Step {
+//		return pool.With(func(hc *HttpCall) Steps {
+//			return Steps{hc.NewRequest("POST", url, nil), hc.ResponseStatusEquals(201)}
+//		})
+//	})
+func (p *HttpCallPool) With(build func(hc *HttpCall) Steps) Step {
+	return NewNamedStep("HttpCallPool.With", func() error {
+		hc := p.Get()
+		err := build(hc).Go()
+		if putErr := p.Put(hc); err == nil {
+			err = putErr
+		}
+		return err
+	})
+}
@@ -0,0 +1,95 @@
+package argot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Priority is the priority of a scenario: P0, the highest, marks the
+// smoke scenarios which a deployment gate runs, then P1, P2 and so on.
+// The zero value, NoPriority, is lower than every other.
+type Priority int
+
+const (
+	NoPriority Priority = iota
+	P0
+	P1
+	P2
+	P3
+)
+
+// ParsePriority parses a Priority such as "P0" or "p2". The empty
+// string is NoPriority.
+func ParsePriority(str string) (Priority, error) {
+	if str == "" {
+		return NoPriority, nil
+	} else if len(str) < 2 || (str[0] != 'P' && str[0] != 'p') {
+		return NoPriority, fmt.Errorf("Priority: Expected P0, P1, etc; found '%s'.", str)
+	} else if n, err := strconv.Atoi(str[1:]); err != nil || n < 0 || strings.HasPrefix(str[1:], "+") {
+		return NoPriority, fmt.Errorf("Priority: Expected P0, P1, etc; found '%s'.", str)
+	} else {
+		return Priority(n + 1), nil
+	}
+}
+
+func (p Priority) String() string {
+	if p <= NoPriority {
+		return "none"
+	} else {
+		return fmt.Sprintf("P%d", p-1)
+	}
+}
+
+// Before returns true iff scenarios of priority p run before those of
+// priority other.
+func (p Priority) Before(other Priority) bool {
+	return p != NoPriority && (other == NoPriority || p < other)
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, parsing the priority with
+// ParsePriority.
+func (p *Priority) UnmarshalYAML(value *yaml.Node) error {
+	str := ""
+	if err := value.Decode(&str); err != nil {
+		return err
+	}
+	priority, err := ParsePriority(str)
+	if err != nil {
+		return err
+	}
+	*p = priority
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (p Priority) MarshalYAML() (interface{}, error) {
+	return p.String(), nil
+}
+
+// ByPriority returns the scenarios sorted so that those of higher
+// priority run first, with those of the same priority, and those with
+// no priority, in their existing order.
+func (scs Scenarios) ByPriority() Scenarios {
+	sorted := append(Scenarios{}, scs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority.Before(sorted[j].Priority)
+	})
+	return sorted
+}
+
+// UpTo returns, in priority order (see ByPriority), the scenarios of
+// priority p or higher: for example UpTo(P0) is the smoke subset.
+// Scenarios with no priority are never included.
+func (scs Scenarios) UpTo(p Priority) Scenarios {
+	subset := Scenarios{}
+	for _, sc := range scs.ByPriority() {
+		if sc.Priority != NoPriority && !p.Before(sc.Priority) {
+			subset = append(subset, sc)
+		}
+	}
+	return subset
+}
@@ -0,0 +1,82 @@
+package argot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPriority(t *testing.T) {
+	for str, expected := range map[string]Priority{"": NoPriority, "P0": P0, "p1": P1, "P3": P3, "P10": Priority(11)} {
+		if p, err := ParsePriority(str); err != nil || p != expected {
+			t.Fatalf("ParsePriority(%q): Expected %v; found %v (%v)", str, expected, p, err)
+		}
+	}
+	for _, str := range []string{"P", "0", "high", "P-1", "P+1"} {
+		if _, err := ParsePriority(str); err == nil {
+			t.Fatalf("ParsePriority(%q): Expected an error", str)
+		}
+	}
+	if P0.String() != "P0" || P2.String() != "P2" || NoPriority.String() != "none" {
+		t.Fatalf("Unexpected strings: %v %v %v", P0, P2, NoPriority)
+	}
+
+	scs := Scenarios{
+		{Name: "none-a"},
+		{Name: "p1-a", Priority: P1},
+		{Name: "p0-a", Priority: P0},
+		{Name: "none-b"},
+		{Name: "p1-b", Priority: P1},
+		{Name: "p0-b", Priority: P0},
+	}
+	names := func(scs Scenarios) string {
+		found := []string{}
+		for _, sc := range scs {
+			found = append(found, sc.Name)
+		}
+		return strings.Join(found, " ")
+	}
+	if found := names(scs.ByPriority()); found != "p0-a p0-b p1-a p1-b none-a none-b" {
+		t.Fatalf("Unexpected order: %s", found)
+	} else if found := names(scs.UpTo(P0)); found != "p0-a p0-b" {
+		t.Fatalf("Unexpected smoke subset: %s", found)
+	} else if found := names(scs.UpTo(P1)); found != "p0-a p0-b p1-a p1-b" {
+		t.Fatalf("Unexpected subset: %s", found)
+	} else if scs[0].Name != "none-a" {
+		t.Fatal("Expected the scenarios not to be reordered in place")
+	}
+}
+
+func TestScenarioPriority(t *testing.T) {
+	sc, err := ParseScenario([]byte("name: smoke\npriority: P0\n"))
+	if err != nil {
+		t.Fatal(err)
+	} else if sc.Priority != P0 {
+		t.Fatalf("Expected P0; found %v", sc.Priority)
+	}
+	if _, err := ParseScenario([]byte("name: smoke\npriority: urgent\n")); err == nil || !strings.Contains(err.Error(), "Expected P0, P1, etc; found 'urgent'.") {
+		t.Fatalf("Expected an error for an invalid priority; found %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "argot-priority")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "smoke.yaml")
+	if err := sc.Save(path); err != nil {
+		t.Fatal(err)
+	} else if loaded, err := LoadScenario(path); err != nil {
+		t.Fatal(err)
+	} else if loaded.Priority != P0 {
+		t.Fatalf("Expected the priority to be saved; found %v", loaded.Priority)
+	}
+	sc.Priority = NoPriority
+	if err := sc.Save(path); err != nil {
+		t.Fatal(err)
+	} else if bites, _ := ioutil.ReadFile(path); strings.Contains(string(bites), "priority") {
+		t.Fatalf("Expected no priority to be omitted; found:\n%s", bites)
+	}
+}
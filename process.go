@@ -0,0 +1,274 @@
+package argot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// waitFor calls check until it returns nil or timeout elapses,
+// backing off exponentially from 10ms to 1s between attempts. The
+// error returned on timeout includes check's last error.
+func waitFor(timeout time.Duration, check func() error) error {
+	deadline := time.Now().Add(timeout)
+	backoff := 10 * time.Millisecond
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		} else if remaining := time.Until(deadline); remaining <= 0 {
+			return fmt.Errorf("Not ready after %v: %v", timeout, err)
+		} else if backoff > remaining {
+			backoff = remaining
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > time.Second {
+			backoff = time.Second
+		}
+	}
+}
+
+// waitForCond waits until ready returns true or timeout elapses. cond.L
+// must be held by the caller, and is held whenever ready is called:
+// once straight away, and again each time cond is broadcast. It
+// returns true iff ready returned true.
+func waitForCond(cond *sync.Cond, timeout time.Duration, ready func() bool) bool {
+	// The timer may fire before the caller first waits, so it sets a
+	// flag rather than relying on its broadcast being seen.
+	expired := false
+	timer := time.AfterFunc(timeout, func() {
+		cond.L.Lock()
+		defer cond.L.Unlock()
+		expired = true
+		cond.Broadcast()
+	})
+	defer timer.Stop()
+	for !ready() {
+		if expired {
+			return false
+		}
+		cond.Wait()
+	}
+	return true
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+	return sb.buf.String()
+}
+
+// Process manages the lifecycle of a long running process, typically
+// the server under test: starting it, waiting for it to become ready,
+// and stopping it. Its output is captured and included in the errors
+// of failing steps.
+type Process struct {
+	// The command to run. Set its Env, Dir etc. before Start.
+	Cmd *exec.Cmd
+	// How long Stop waits after signalling the process before
+	// killing it. If zero, 10 seconds is used.
+	ShutdownTimeout time.Duration
+	// If non-nil, the output of the process is also written to Logs,
+	// so that steps can assert on what it logs.
+	Logs *LogCapture
+
+	output *syncBuffer
+	done   chan struct{}
+	err    error
+}
+
+// NewProcess creates a new Process which will run name with args.
+func NewProcess(name string, args ...string) *Process {
+	return &Process{Cmd: exec.Command(name, args...)}
+}
+
+// Output returns the combined standard output and standard error of
+// the process so far.
+func (p *Process) Output() string {
+	if p.output == nil {
+		return ""
+	} else {
+		return p.output.String()
+	}
+}
+
+// Running reports whether the process has been started and has not
+// yet exited.
+func (p *Process) Running() bool {
+	if p.done == nil {
+		return false
+	}
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (p *Process) exited() error {
+	if p.err == nil {
+		return fmt.Errorf("Process exited unexpectedly. Output:\n%s", p.Output())
+	} else {
+		return fmt.Errorf("Process exited unexpectedly (%v). Output:\n%s", p.err, p.Output())
+	}
+}
+
+// Start is a Step that when executed starts the process.
+func (p *Process) Start() Step {
+	return NewNamedStep(fmt.Sprintf("Start(%s)", strings.Join(p.Cmd.Args, " ")), func() error {
+		if p.done != nil {
+			return errors.New("Process already started.")
+		}
+		p.output = new(syncBuffer)
+		if p.Logs == nil {
+			p.Cmd.Stdout = p.output
+		} else {
+			p.Cmd.Stdout = io.MultiWriter(p.output, p.Logs)
+		}
+		p.Cmd.Stderr = p.Cmd.Stdout
+		if err := p.Cmd.Start(); err != nil {
+			return err
+		}
+		p.done = make(chan struct{})
+		go func() {
+			p.err = p.Cmd.Wait()
+			close(p.done)
+		}()
+		return nil
+	})
+}
+
+// WaitForPort is a Step that when executed waits up to timeout for a
+// TCP connection to addr to succeed, erroring if it does not or if the
+// process exits first.
+func (p *Process) WaitForPort(addr string, timeout time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("WaitForPort(%s)", addr), func() error {
+		return p.waitFor(timeout, func() error {
+			if conn, err := net.DialTimeout("tcp", addr, time.Second); err != nil {
+				return err
+			} else {
+				return conn.Close()
+			}
+		})
+	})
+}
+
+// WaitForHTTP is a Step that when executed waits up to timeout for a
+// GET of url to return a 2xx status, erroring if it does not or if the
+// process exits first.
+func (p *Process) WaitForHTTP(url string, timeout time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("WaitForHTTP(%s)", DefaultRedactor.String(url)), func() error {
+		client := &http.Client{Timeout: time.Second}
+		return p.waitFor(timeout, func() error {
+			if response, err := client.Get(url); err != nil {
+				return err
+			} else {
+				response.Body.Close()
+				if response.StatusCode < 200 || response.StatusCode > 299 {
+					return fmt.Errorf("Status: Expected 2xx; found %d.", response.StatusCode)
+				}
+				return nil
+			}
+		})
+	})
+}
+
+func (p *Process) waitFor(timeout time.Duration, check func() error) error {
+	if p.done == nil {
+		return errors.New("Process not started.")
+	}
+	err := waitFor(timeout, func() error {
+		if !p.Running() {
+			return nil
+		}
+		return check()
+	})
+	if !p.Running() {
+		return p.exited()
+	} else if err != nil {
+		return fmt.Errorf("%v. Output:\n%s", err, p.Output())
+	} else {
+		return nil
+	}
+}
+
+// ExpectRunning is a Step that when executed errors unless the
+// process is still running.
+func (p *Process) ExpectRunning() Step {
+	return NewNamedStep("ExpectRunning", func() error {
+		if p.done == nil {
+			return errors.New("Process not started.")
+		} else if !p.Running() {
+			return p.exited()
+		} else {
+			return nil
+		}
+	})
+}
+
+// Stop is a Step that when executed asks the process to shut down
+// (with SIGTERM) and waits for it to exit. It errors unless the
+// process shuts down gracefully: exiting with status 0 within
+// ShutdownTimeout. If the timeout elapses the process is killed.
+// Stopping a process which is not running is an error.
+func (p *Process) Stop() Step {
+	return NewNamedStep("Stop", func() error {
+		if p.done == nil {
+			return errors.New("Process not started.")
+		} else if !p.Running() {
+			return p.exited()
+		}
+		timeout := p.ShutdownTimeout
+		if timeout == 0 {
+			timeout = 10 * time.Second
+		}
+		if err := p.Cmd.Process.Signal(syscall.SIGTERM); err != nil {
+			p.Cmd.Process.Signal(os.Kill)
+		}
+		select {
+		case <-p.done:
+			if p.err != nil {
+				return fmt.Errorf("Process did not shut down gracefully: %v. Output:\n%s", p.err, p.Output())
+			}
+			return nil
+		case <-time.After(timeout):
+			p.Cmd.Process.Kill()
+			<-p.done
+			return fmt.Errorf("Process did not shut down within %v and was killed. Output:\n%s", timeout, p.Output())
+		}
+	})
+}
+
+// Kill terminates the process immediately, if it is running, and
+// waits for it to exit. It is intended to be deferred as a safety net
+// in case Stop is not reached.
+func (p *Process) Kill() error {
+	if !p.Running() {
+		return nil
+	}
+	err := p.Cmd.Process.Kill()
+	<-p.done
+	return err
+}
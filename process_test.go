@@ -0,0 +1,88 @@
+package argot
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProcess(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	// The process starts listening after a delay, so that WaitForPort
+	// has to retry.
+	p := NewProcess("sh", "-c", "trap 'echo stopping; exit 0' TERM; sleep 0.1; echo listening; while true; do sleep 0.05; done")
+	defer p.Kill()
+	listeners := make(chan net.Listener, 1)
+	listenLater := NewNamedStep("ListenLater", func() error {
+		time.AfterFunc(100*time.Millisecond, func() {
+			listener, err := net.Listen("tcp", addr)
+			if err == nil {
+				go listener.Accept()
+			}
+			listeners <- listener
+		})
+		return nil
+	})
+
+	Steps{
+		p.Start(),
+		ExpectError(p.Start()),
+		listenLater,
+		p.WaitForPort(addr, 5*time.Second),
+		p.ExpectRunning(),
+		p.Stop(),
+		ExpectError(p.ExpectRunning()),
+	}.Test(t)
+	if listener := <-listeners; listener != nil {
+		listener.Close()
+	}
+	if output := p.Output(); output != "listening\nstopping\n" {
+		t.Fatalf("Expected graceful shutdown output; found %q", output)
+	}
+
+	failing := NewProcess("sh", "-c", "echo broken; exit 1")
+	Steps{
+		failing.Start(),
+		ExpectError(failing.WaitForPort(addr, 5*time.Second)),
+	}.Test(t)
+	if output := failing.Output(); output != "broken\n" {
+		t.Fatalf("Expected output to be captured; found %q", output)
+	}
+}
+
+func TestWaitForCond(t *testing.T) {
+	var lock sync.Mutex
+	cond := sync.NewCond(&lock)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// The timeout elapses whilst ready is first called, before there
+	// is any waiting.
+	start := time.Now()
+	if waitForCond(cond, 10*time.Millisecond, func() bool {
+		time.Sleep(20 * time.Millisecond)
+		return false
+	}) {
+		t.Fatal("Expected the wait to time out.")
+	} else if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected the wait to end at the timeout; took %v.", elapsed)
+	}
+
+	ready := false
+	go func() {
+		lock.Lock()
+		defer lock.Unlock()
+		ready = true
+		cond.Broadcast()
+	}()
+	if !waitForCond(cond, time.Minute, func() bool { return ready }) {
+		t.Fatal("Expected the wait to end once ready.")
+	}
+}
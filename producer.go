@@ -0,0 +1,70 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+)
+
+// StepProducer constructs a Step when it is run, for steps which can
+// only be built once earlier steps have run (for example, steps which
+// depend on a value captured from a response).
+type StepProducer func() (Step, error)
+
+// ProducedStep is a Step which, when executed, calls Producer to
+// construct a step, and runs that. Once produced, the step's name
+// includes the produced step's, so that results and failure output
+// show what actually ran. Errors from Producer are reported as a
+// *ProductionError, distinct from the errors of the produced step.
+type ProducedStep struct {
+	Name     string
+	Producer StepProducer
+	// If true, the step is produced only once, and reused if the
+	// ProducedStep is run again (for example by Flaky).
+	Cache bool
+	// The step most recently produced.
+	Produced Step
+}
+
+// Produce creates a new ProducedStep.
+func Produce(name string, producer StepProducer) *ProducedStep {
+	return &ProducedStep{Name: name, Producer: producer}
+}
+
+func (ps *ProducedStep) String() string {
+	if ps.Produced == nil {
+		return ps.Name
+	} else {
+		return fmt.Sprintf("%s: %v", ps.Name, ps.Produced)
+	}
+}
+
+// Go produces the step, unless it is cached, and runs it.
+func (ps *ProducedStep) Go() error {
+	if !ps.Cache || ps.Produced == nil {
+		ps.Produced = nil
+		if step, err := ps.Producer(); err != nil {
+			return &ProductionError{Step: ps.Name, Err: err}
+		} else if step == nil {
+			return &ProductionError{Step: ps.Name, Err: errors.New("No step produced.")}
+		} else {
+			ps.Produced = step
+		}
+	}
+	return ps.Produced.Go()
+}
+
+// ProductionError is the error returned by a ProducedStep whose
+// Producer failed, as opposed to a produced step which failed.
+type ProductionError struct {
+	Step string
+	Err  error
+}
+
+func (e *ProductionError) Error() string {
+	return fmt.Sprintf("Producing step %s: %v", e.Step, e.Err)
+}
+
+// Unwrap returns the error of the Producer.
+func (e *ProductionError) Unwrap() error {
+	return e.Err
+}
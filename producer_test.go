@@ -0,0 +1,47 @@
+package argot
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProduce(t *testing.T) {
+	store := NewStore()
+	productions := 0
+	produced := Produce("checkUser", func() (Step, error) {
+		productions++
+		if id, found := store.Get("userID"); !found {
+			return nil, errors.New("no userID")
+		} else {
+			return ExpectContains(id.(string), "42"), nil
+		}
+	})
+
+	err := produced.Go()
+	var productionErr *ProductionError
+	if !errors.As(err, &productionErr) || productionErr.Step != "checkUser" {
+		t.Fatalf("Expected a ProductionError; found %v.", err)
+	}
+
+	store.Set("userID", "user-42")
+	result := RunScenario("produce", Steps{produced})
+	if !result.Passed() || result.Steps[0].Name != "checkUser: ExpectContains(42)" {
+		t.Fatalf("Expected the produced step's name in the results; found %+v.", result.Steps[0])
+	}
+
+	store.Set("userID", "user-7")
+	err = produced.Go()
+	if err == nil || errors.As(err, &productionErr) {
+		t.Fatalf("Expected the produced step to fail, not its production; found %v.", err)
+	}
+
+	produced.Cache = true
+	produced.Go()
+	produced.Go()
+	if productions != 3 {
+		t.Fatalf("Expected a cached step to be produced once; found %d productions.", productions)
+	}
+	if err := Produce("nothing", func() (Step, error) { return nil, nil }).Go(); err == nil {
+		t.Fatal("Expected producing no step to be an error.")
+	}
+}
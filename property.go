@@ -0,0 +1,247 @@
+package argot
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing/quick"
+)
+
+// Generator generates a value for property-based testing (see
+// Property) using r. Size bounds the size of the value, for example
+// the length of a string or the range of a number, so that smaller
+// sizes generate simpler values. A Generator must depend only on r and
+// size, so that values can be regenerated from a seed.
+type Generator func(r *rand.Rand, size int) interface{}
+
+// GenInt returns a Generator of ints from min to max inclusive, no
+// more than size above min.
+func GenInt(min, max int) Generator {
+	return func(r *rand.Rand, size int) interface{} {
+		if span := max - min; span < size {
+			size = span
+		}
+		return min + r.Intn(size+1)
+	}
+}
+
+// GenString returns a Generator of strings of up to size characters
+// from alphabet, or printable ASCII if alphabet is empty.
+func GenString(alphabet string) Generator {
+	runes := []rune(alphabet)
+	if len(runes) == 0 {
+		for r := ' '; r <= '~'; r++ {
+			runes = append(runes, r)
+		}
+	}
+	return func(r *rand.Rand, size int) interface{} {
+		str := make([]rune, r.Intn(size+1))
+		for idx := range str {
+			str[idx] = runes[r.Intn(len(runes))]
+		}
+		return string(str)
+	}
+}
+
+// GenOneOf returns a Generator which chooses one of values. Smaller
+// sizes choose from fewer of the first values.
+func GenOneOf(values ...interface{}) Generator {
+	return func(r *rand.Rand, size int) interface{} {
+		if size >= len(values) {
+			size = len(values) - 1
+		}
+		return values[r.Intn(size+1)]
+	}
+}
+
+// GenSlice returns a Generator of slices of up to size values from
+// elem.
+func GenSlice(elem Generator) Generator {
+	return func(r *rand.Rand, size int) interface{} {
+		slice := make([]interface{}, r.Intn(size+1))
+		for idx := range slice {
+			slice[idx] = elem(r, size)
+		}
+		return slice
+	}
+}
+
+// GenObject returns a Generator of maps, such as JSON request bodies,
+// with a value from the corresponding Generator of fields for each
+// key.
+func GenObject(fields map[string]Generator) Generator {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return func(r *rand.Rand, size int) interface{} {
+		obj := make(map[string]interface{}, len(keys))
+		for _, key := range keys {
+			obj[key] = fields[key](r, size)
+		}
+		return obj
+	}
+}
+
+// GenQuick returns a Generator of values of the same type as example,
+// generated by testing/quick, so types implementing quick.Generator
+// generate themselves. Size is ignored.
+func GenQuick(example interface{}) Generator {
+	t := reflect.TypeOf(example)
+	return func(r *rand.Rand, size int) interface{} {
+		if value, ok := quick.Value(t, r); !ok {
+			panic(fmt.Sprintf("argot: GenQuick: Cannot generate values of %v", t))
+		} else {
+			return value.Interface()
+		}
+	}
+}
+
+// The defaults of the fields of Property.
+const (
+	defaultPropertyRuns    = 100
+	defaultPropertyMaxSize = 100
+	defaultPropertyShrinks = 100
+)
+
+// Property is a Step for property-based testing: it generates values
+// with Generate, and runs the Steps returned by Build with each in
+// turn, for example substituting the value into a request and checking
+// that the response is not a server error. Should any run fail, the
+// failing case is shrunk by searching for a failing value of a smaller
+// size, and the error (a *PropertyError) reports the smallest found,
+// and the seed and size with which to replay it.
+type Property struct {
+	Name     string
+	Generate Generator
+	Build    func(value interface{}) Steps
+	// The number of values to try. If zero, 100.
+	Runs int
+	// Sizes grow from zero to MaxSize over the runs. If zero, 100.
+	MaxSize int
+	// The maximum number of runs whilst shrinking. If zero, 100.
+	MaxShrinks int
+	// The seed from which the values are generated. If zero, a seed is
+	// drawn from argot's random number generator (see Seed), and
+	// reported should the property fail.
+	Seed int64
+}
+
+func (p *Property) String() string {
+	return fmt.Sprintf("Property(%s)", p.Name)
+}
+
+func (p *Property) maxSize() int {
+	if p.MaxSize <= 0 {
+		return defaultPropertyMaxSize
+	} else {
+		return p.MaxSize
+	}
+}
+
+// try runs Build with the value generated from seed and size.
+func (p *Property) try(seed int64, size int) (interface{}, error) {
+	value := p.Generate(rand.New(rand.NewSource(seed)), size)
+	return value, p.Build(value).Go()
+}
+
+// Go runs the property.
+func (p *Property) Go() error {
+	runs, maxShrinks := p.Runs, p.MaxShrinks
+	if runs <= 0 {
+		runs = defaultPropertyRuns
+	}
+	if maxShrinks <= 0 {
+		maxShrinks = defaultPropertyShrinks
+	}
+	seed := p.Seed
+	if seed == 0 {
+		seed = randInt63n(1<<62) + 1
+	}
+	seeds := rand.New(rand.NewSource(seed))
+	for run := 0; run < runs; run++ {
+		caseSeed, size := seeds.Int63(), run*p.maxSize()/runs
+		if value, err := p.try(caseSeed, size); err != nil {
+			pErr := &PropertyError{Name: p.Name, Seed: seed, Run: run + 1, CaseSeed: caseSeed, Size: size, Value: value, Err: err}
+			p.shrink(pErr, maxShrinks)
+			return pErr
+		}
+	}
+	return nil
+}
+
+// shrink searches for a failing case of a smaller size than that of
+// pErr, trying sizes from zero upwards, each with the failing case's
+// seed and then others, and updates pErr with the first found.
+func (p *Property) shrink(pErr *PropertyError, maxShrinks int) {
+	seeds := rand.New(rand.NewSource(pErr.CaseSeed))
+	perSize := maxShrinks / (pErr.Size + 1)
+	if perSize < 1 {
+		perSize = 1
+	}
+	for size := 0; size < pErr.Size && pErr.Shrinks < maxShrinks; size++ {
+		for attempt := 0; attempt < perSize && pErr.Shrinks < maxShrinks; attempt++ {
+			caseSeed := pErr.CaseSeed
+			if attempt > 0 {
+				caseSeed = seeds.Int63()
+			}
+			pErr.Shrinks++
+			if value, err := p.try(caseSeed, size); err != nil {
+				pErr.CaseSeed, pErr.Size, pErr.Value, pErr.Err = caseSeed, size, value, err
+				return
+			}
+		}
+	}
+}
+
+// Replay is a Step that when executed runs Build with the value
+// generated from the given case seed and size, as reported by a
+// PropertyError, so that a failure can be reproduced exactly.
+func (p *Property) Replay(caseSeed int64, size int) Step {
+	return NewNamedStep(fmt.Sprintf("%v.Replay(%d, %d)", p, caseSeed, size), func() error {
+		if value, err := p.try(caseSeed, size); err != nil {
+			return fmt.Errorf("Property %s: Value %s: %v", p.Name, propertyValue(value), err)
+		} else {
+			return nil
+		}
+	})
+}
+
+// PropertyError is the error returned by a Property which fails. Seed
+// is the seed of the Property, from which the whole run can be
+// repeated; CaseSeed and Size regenerate Value, the smallest failing
+// value found (see Property.Replay).
+type PropertyError struct {
+	Name     string
+	Seed     int64
+	Run      int
+	CaseSeed int64
+	Size     int
+	Value    interface{}
+	Shrinks  int
+	Err      error
+}
+
+func (e *PropertyError) Error() string {
+	return fmt.Sprintf("Property %s: Failed on run %d (seed %d) with value %s, shrunk in %d runs; replay with Replay(%d, %d): %s",
+		e.Name, e.Run, e.Seed, propertyValue(e.Value), e.Shrinks, e.CaseSeed, e.Size, strings.Replace(e.Err.Error(), "\n", "\n\t", -1))
+}
+
+// Unwrap returns the error of the smallest failing value.
+func (e *PropertyError) Unwrap() error {
+	return e.Err
+}
+
+// propertyValue formats value for error messages.
+func propertyValue(value interface{}) string {
+	if str, ok := value.(string); ok {
+		return fuzzValueName(str)
+	} else if formatted := jsonString(value); len(formatted) > 64 {
+		return fmt.Sprintf("%s... (%d bytes)", formatted[:64], len(formatted))
+	} else {
+		return formatted
+	}
+}
@@ -0,0 +1,86 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestProperty(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		} else if limit > 40 && strings.Contains(r.URL.Query().Get("q"), "%") {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	query := GenObject(map[string]Generator{
+		"limit": GenInt(0, 1000),
+		"q":     GenString("ab%"),
+	})
+	runs := 0
+	property := &Property{
+		Name:     "search",
+		Generate: query,
+		Build: func(value interface{}) Steps {
+			runs++
+			params := value.(map[string]interface{})
+			return Steps{
+				hc.NewRequest("GET", fmt.Sprintf("%s?limit=%d&q=%s", server.URL, params["limit"], url.QueryEscape(params["q"].(string))), nil),
+				hc.ResponseNoServerError(),
+			}
+		},
+		Seed: 42,
+	}
+	err := property.Go()
+	var pErr *PropertyError
+	if !errors.As(err, &pErr) {
+		t.Fatalf("Expected a PropertyError; found %v", err)
+	} else if pErr.Seed != 42 || !strings.Contains(err.Error(), fmt.Sprintf("replay with Replay(%d, %d)", pErr.CaseSeed, pErr.Size)) {
+		t.Fatalf("Expected the seed and replay to be reported; found %v", err)
+	} else if pErr.Size < 41 || pErr.Size > pErr.Run-1 || pErr.Shrinks == 0 {
+		t.Fatalf("Expected the failure to be shrunk below the size of run %d; found %d after %d runs.", pErr.Run, pErr.Size, pErr.Shrinks)
+	} else if limit := pErr.Value.(map[string]interface{})["limit"].(int); limit <= 40 {
+		t.Fatalf("Expected the shrunk value to fail; found limit %d.", limit)
+	}
+	if err := property.Replay(pErr.CaseSeed, pErr.Size).Go(); err == nil {
+		t.Fatal("Expected the replayed case to fail.")
+	}
+	runs = 0
+	if err := property.Go(); err == nil || err.Error() != pErr.Error() {
+		t.Fatalf("Expected the same seed to reproduce the failure; found %v", err)
+	}
+
+	property.Generate, property.Runs = GenObject(map[string]Generator{"limit": GenInt(0, 40), "q": GenOneOf("a", "%")}), 20
+	runs = 0
+	Steps{property}.Test(t)
+	if runs != 20 {
+		t.Fatalf("Expected 20 runs; found %d.", runs)
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	values := map[string]bool{}
+	for seed := int64(1); seed < 200; seed++ {
+		property := &Property{Generate: GenSlice(GenQuick(uint8(0))), Build: func(value interface{}) Steps {
+			values[fmt.Sprint(len(value.([]interface{})))] = true
+			return nil
+		}, Runs: 1, MaxSize: 3, Seed: seed}
+		Steps{property}.Test(t)
+	}
+	if len(values) != 1 || !values["0"] {
+		t.Fatalf("Expected the first run to have size zero; found lengths %v.", values)
+	}
+	if value, err := (&Property{Generate: GenString("x"), Build: func(value interface{}) Steps { return nil }}).try(7, 5); err != nil || len(value.(string)) > 5 || strings.Trim(value.(string), "x") != "" {
+		t.Fatalf("Unexpected string %q (%v).", value, err)
+	}
+}
@@ -0,0 +1,201 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// hopHeaders are the hop-by-hop headers, which a proxy must not
+// forward.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// CaptureProxy is a forward HTTP proxy which records the requests made
+// through it, so that the traffic of a real client application (a
+// browser, or a mobile app in a simulator) can be captured during
+// exploratory testing and converted into Steps or a HAR document.
+// Only plain HTTP is captured: HTTPS requests, which a client sends
+// through the proxy with CONNECT, are tunnelled without being
+// recorded. Sensitive headers, such as Authorization, are recorded as
+// they were sent, so that they are replayed, so take care in sharing a
+// saved capture. A CaptureProxy is an http.Handler, and is safe for
+// concurrent use.
+type CaptureProxy struct {
+	// The transport through which requests are forwarded. If nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	lock     sync.Mutex
+	entries  []HAREntry
+	listener net.Listener
+}
+
+// NewCaptureProxy creates a new CaptureProxy.
+func NewCaptureProxy() *CaptureProxy {
+	return &CaptureProxy{}
+}
+
+// Start listens on address (for example "127.0.0.1:8080") and serves
+// the proxy in a new go-routine, returning its URL, with which to
+// configure the client (for example as $HTTP_PROXY).
+func (cp *CaptureProxy) Start(address string) (string, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return "", err
+	}
+	cp.lock.Lock()
+	cp.listener = listener
+	cp.lock.Unlock()
+	go http.Serve(listener, cp)
+	return "http://" + listener.Addr().String(), nil
+}
+
+// Close stops the proxy started by Start.
+func (cp *CaptureProxy) Close() error {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	if cp.listener == nil {
+		return nil
+	}
+	err := cp.listener.Close()
+	cp.listener = nil
+	return err
+}
+
+func (cp *CaptureProxy) transport() http.RoundTripper {
+	if cp.Transport == nil {
+		return http.DefaultTransport
+	} else {
+		return cp.Transport
+	}
+}
+
+// ServeHTTP forwards the request and records it.
+func (cp *CaptureProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		cp.tunnel(w, r)
+		return
+	} else if !r.URL.IsAbs() {
+		http.Error(w, "argot: CaptureProxy: Expected a proxy request with an absolute URL.", http.StatusBadRequest)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	out, err := http.NewRequestWithContext(r.Context(), r.Method, r.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	out.Header = r.Header.Clone()
+	for _, key := range hopHeaders {
+		out.Header.Del(key)
+	}
+	if len(body) == 0 {
+		out.Body = nil
+	}
+
+	entry := HAREntry{Request: HARRequest{Method: r.Method, URL: r.URL.String()}}
+	for _, key := range sortedHeaderKeys(out.Header) {
+		for _, value := range out.Header[key] {
+			entry.Request.Headers = append(entry.Request.Headers, HARNameValue{Name: key, Value: value})
+		}
+	}
+	if len(body) > 0 {
+		entry.Request.PostData = &HARPostData{MimeType: r.Header.Get("Content-Type"), Text: string(body)}
+	}
+
+	response, err := cp.transport().RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer response.Body.Close()
+	entry.Response.Status = response.StatusCode
+	cp.lock.Lock()
+	cp.entries = append(cp.entries, entry)
+	cp.lock.Unlock()
+
+	for key, values := range response.Header {
+		w.Header()[key] = values
+	}
+	for _, key := range hopHeaders {
+		w.Header().Del(key)
+	}
+	w.WriteHeader(response.StatusCode)
+	io.Copy(w, response.Body)
+}
+
+// tunnel relays a CONNECT request's connection, unrecorded.
+func (cp *CaptureProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "argot: CaptureProxy: Cannot tunnel.", http.StatusInternalServerError)
+		return
+	}
+	upstream, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+	go func() {
+		io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+	io.Copy(conn, upstream)
+	conn.Close()
+}
+
+func sortedHeaderKeys(header http.Header) []string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// HAR returns a HAR document of the requests captured so far, in the
+// order in which they were made.
+func (cp *CaptureProxy) HAR() *HAR {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	har := new(HAR)
+	har.Log.Entries = append([]HAREntry{}, cp.entries...)
+	return har
+}
+
+// SaveHAR writes the HAR document of the requests captured so far to
+// path, for LoadHAR.
+func (cp *CaptureProxy) SaveHAR(path string) error {
+	if bites, err := json.MarshalIndent(cp.HAR(), "", "  "); err != nil {
+		return err
+	} else if err := ioutil.WriteFile(path, bites, 0644); err != nil {
+		return fmt.Errorf("HAR %s: %v", path, err)
+	} else {
+		return nil
+	}
+}
+
+// Steps converts the requests captured so far into Steps using hc, as
+// HAR.Steps does.
+func (cp *CaptureProxy) Steps(hc *HttpCall, assertStatus bool) Steps {
+	return cp.HAR().Steps(hc, assertStatus)
+}
@@ -0,0 +1,69 @@
+package argot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCaptureProxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			body, _ := ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			w.Write(body)
+		} else {
+			w.Write([]byte("tea"))
+		}
+	}))
+	defer server.Close()
+
+	proxy := NewCaptureProxy()
+	proxyURL, err := proxy.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	parsed, _ := url.Parse(proxyURL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(parsed)}}
+
+	// The client application under exploration.
+	if response, err := client.Get(server.URL + "/teas?type=green"); err != nil {
+		t.Fatal(err)
+	} else if body, _ := ioutil.ReadAll(response.Body); string(body) != "tea" {
+		t.Fatalf("Expected the response to be proxied; found %q.", body)
+	}
+	if _, err := client.Post(server.URL+"/orders", "application/json", strings.NewReader(`{"tea": "sencha"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	har := proxy.HAR()
+	if len(har.Log.Entries) != 2 {
+		t.Fatalf("Expected 2 entries; found %d.", len(har.Log.Entries))
+	} else if entry := har.Log.Entries[1]; entry.Request.Method != "POST" || entry.Request.PostData.Text != `{"tea": "sencha"}` || entry.Response.Status != http.StatusCreated {
+		t.Fatalf("Unexpected entry: %+v", entry)
+	}
+
+	dir, err := ioutil.TempDir("", "argot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "capture.har")
+	if err := proxy.SaveHAR(path); err != nil {
+		t.Fatal(err)
+	} else if loaded, err := LoadHAR(path); err != nil {
+		t.Fatal(err)
+	} else if len(loaded.Log.Entries) != 2 {
+		t.Fatalf("Expected 2 entries; found %d.", len(loaded.Log.Entries))
+	}
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	append(proxy.Steps(hc, true), hc.ResponseBodyEquals(`{"tea": "sencha"}`)).Test(t)
+}
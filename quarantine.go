@@ -0,0 +1,193 @@
+package argot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// Quarantine lists the scenarios which are known to be flaky, by name
+// or by pattern (as for path.Match, for example "checkout *"), so that
+// their failures are tolerated, without code changes, whilst they are
+// fixed. It also counts how many consecutive runs each quarantined
+// scenario has passed (see Record), so that scenarios which have
+// started passing consistently can be reported (see Stable) and
+// released from quarantine. It is persisted, with the counts, as JSON,
+// for example:
+//
+//	{
+//	  "patterns": ["checkout *", "search by tag"],
+//	  "releaseAfter": 10
+//	}
+//
+// A Quarantine is safe for concurrent use.
+type Quarantine struct {
+	// The number of consecutive runs a quarantined scenario must pass
+	// to be reported as stable. If 0, 5 is used.
+	ReleaseAfter int
+
+	lock     sync.Mutex
+	patterns []string
+	passes   map[string]int
+}
+
+// quarantineFile is the format in which a Quarantine is persisted.
+type quarantineFile struct {
+	Patterns     []string       `json:"patterns"`
+	ReleaseAfter int            `json:"releaseAfter,omitempty"`
+	Passes       map[string]int `json:"passes,omitempty"`
+}
+
+// NewQuarantine creates a new Quarantine of the scenarios matching
+// patterns.
+func NewQuarantine(patterns ...string) *Quarantine {
+	return &Quarantine{
+		patterns: append([]string{}, patterns...),
+		passes:   make(map[string]int),
+	}
+}
+
+// LoadQuarantine loads the Quarantine saved to path by Save, or
+// written by hand. If path does not exist, an empty Quarantine is
+// returned. It errors if any pattern is malformed.
+func LoadQuarantine(path string) (*Quarantine, error) {
+	q := NewQuarantine()
+	bites, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return q, nil
+	} else if err != nil {
+		return nil, err
+	}
+	file := quarantineFile{}
+	if err := json.Unmarshal(bites, &file); err != nil {
+		return nil, fmt.Errorf("Quarantine %s: %v", path, err)
+	} else if err := q.Add(file.Patterns...); err != nil {
+		return nil, fmt.Errorf("Quarantine %s: %v", path, err)
+	}
+	q.ReleaseAfter = file.ReleaseAfter
+	for name, passes := range file.Passes {
+		q.passes[name] = passes
+	}
+	return q, nil
+}
+
+// Save writes the patterns, and the consecutive passes of the
+// quarantined scenarios, to path.
+func (q *Quarantine) Save(path string) error {
+	q.lock.Lock()
+	file := quarantineFile{Patterns: append([]string{}, q.patterns...), ReleaseAfter: q.ReleaseAfter, Passes: make(map[string]int)}
+	for name, passes := range q.passes {
+		file.Passes[name] = passes
+	}
+	q.lock.Unlock()
+	if bites, err := json.MarshalIndent(file, "", "  "); err != nil {
+		return err
+	} else if err := ioutil.WriteFile(path, append(bites, '\n'), 0644); err != nil {
+		return fmt.Errorf("Quarantine %s: %v", path, err)
+	} else {
+		return nil
+	}
+}
+
+// Add quarantines the scenarios matching patterns. It errors if any
+// pattern is malformed, in which case none are added.
+func (q *Quarantine) Add(patterns ...string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Pattern '%s': %v", pattern, err)
+		}
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.patterns = append(q.patterns, patterns...)
+	return nil
+}
+
+// Quarantined returns true iff the scenario called name is
+// quarantined.
+func (q *Quarantine) Quarantined(name string) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.quarantined(name)
+}
+
+func (q *Quarantine) quarantined(name string) bool {
+	for _, pattern := range q.patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Record counts the consecutive passes of each of results which is
+// quarantined: a failure resets its count. The counts of scenarios
+// which are no longer quarantined are dropped.
+func (q *Quarantine) Record(results ...*ScenarioResult) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for _, result := range results {
+		if !q.quarantined(result.Name) {
+			continue
+		} else if result.Passed() {
+			q.passes[result.Name]++
+		} else {
+			q.passes[result.Name] = 0
+		}
+	}
+	for name := range q.passes {
+		if !q.quarantined(name) {
+			delete(q.passes, name)
+		}
+	}
+}
+
+// Passes returns the number of consecutive runs that the quarantined
+// scenario called name has passed.
+func (q *Quarantine) Passes(name string) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.passes[name]
+}
+
+// Stable returns the names, sorted, of the quarantined scenarios which
+// have passed at least ReleaseAfter consecutive runs, and so may be
+// released from quarantine.
+func (q *Quarantine) Stable() []string {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	releaseAfter := q.ReleaseAfter
+	if releaseAfter == 0 {
+		releaseAfter = 5
+	}
+	stable := []string{}
+	for name, passes := range q.passes {
+		if passes >= releaseAfter {
+			stable = append(stable, name)
+		}
+	}
+	sort.Strings(stable)
+	return stable
+}
+
+// Test runs steps as the scenario called name, and records its outcome
+// (see Record). If a step fails, t.Fatal is called, as by Steps.Test,
+// unless the scenario is quarantined, in which case the failure is
+// logged and the test skipped.
+func (q *Quarantine) Test(t *testing.T, name string, steps Steps) {
+	results, err := steps.run()
+	q.Record(&ScenarioResult{Name: name, Err: err})
+	if err == nil {
+		return
+	} else if q.Quarantined(name) {
+		t.Log(formatFatalSteps(results, err))
+		t.Skipf("Scenario %s is quarantined.", name)
+	} else {
+		t.Fatal(formatFatalSteps(results, err))
+	}
+}
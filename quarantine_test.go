@@ -0,0 +1,68 @@
+package argot
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQuarantine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "argot-quarantine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "quarantine.json")
+	if q, err := LoadQuarantine(path); err != nil || q.Quarantined("anything") {
+		t.Fatalf("Expected an empty quarantine; found %v", err)
+	}
+	ioutil.WriteFile(path, []byte(`{"patterns": ["checkout *", "search"], "releaseAfter": 2}`), 0644)
+	q, err := LoadQuarantine(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if !q.Quarantined("checkout with voucher") || !q.Quarantined("search") || q.Quarantined("search by tag") {
+		t.Fatal("Expected scenarios to be quarantined by name and pattern")
+	}
+
+	failing := &ScenarioResult{Name: "search", Err: errors.New("boom")}
+	passing := &ScenarioResult{Name: "search"}
+	q.Record(passing, &ScenarioResult{Name: "login"})
+	q.Record(failing)
+	q.Record(passing)
+	if stable := q.Stable(); len(stable) != 0 || q.Passes("search") != 1 || q.Passes("login") != 0 {
+		t.Fatalf("Expected a failure to reset the passes; found %v", stable)
+	}
+	q.Record(passing)
+	if err := q.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	q, err = LoadQuarantine(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if stable := q.Stable(); len(stable) != 1 || stable[0] != "search" || q.Passes("search") != 2 {
+		t.Fatalf("Expected search to be stable after 2 passes; found %v", stable)
+	}
+
+	ioutil.WriteFile(path, []byte(`{"patterns": ["[unclosed"]}`), 0644)
+	if _, err := LoadQuarantine(path); err == nil || !strings.Contains(err.Error(), "Pattern '[unclosed'") {
+		t.Fatalf("Expected an error for a malformed pattern; found %v", err)
+	}
+}
+
+func TestQuarantineTest(t *testing.T) {
+	q := NewQuarantine("flaky *")
+	t.Run("flaky checkout", func(t *testing.T) {
+		q.Test(t, "flaky checkout", Steps{NewNamedStep("fail", func() error { return errors.New("boom") })})
+		t.Fatal("Expected a quarantined failure to skip the test")
+	})
+	if q.Passes("flaky checkout") != 0 {
+		t.Fatal("Expected the failure to be recorded")
+	}
+	q.Test(t, "flaky search", Steps{NewNamedStep("pass", func() error { return nil })})
+	if q.Passes("flaky search") != 1 {
+		t.Fatal("Expected the pass to be recorded")
+	}
+}
@@ -0,0 +1,92 @@
+package argot
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SeedEnv is the environment variable from which the seed of argot's
+// random number generator is read, to reproduce a run.
+const SeedEnv = "ARGOT_SEED"
+
+var (
+	randLock sync.Mutex
+	randSeed int64
+	randGen  *rand.Rand
+	// Whether any random number has been generated since the generator
+	// was seeded, in which case the seed is reported on failure.
+	randUsed bool
+)
+
+// Seed returns the seed of argot's random number generator, which
+// every randomised feature (such as the jitter of RetryPolicy, the
+// values of a Property without a Seed, and NewRandomFaker) draws from,
+// so that a run can be reproduced exactly by setting the same seed.
+// The seed is read from $ARGOT_SEED, if set, or otherwise chosen from
+// the time, and is reported by Steps.Test when a run which used it
+// fails. It panics if $ARGOT_SEED is not an integer.
+func Seed() int64 {
+	randLock.Lock()
+	defer randLock.Unlock()
+	initRand()
+	return randSeed
+}
+
+// SetSeed reseeds argot's random number generator (see Seed), for
+// example from a command line flag.
+func SetSeed(seed int64) {
+	randLock.Lock()
+	defer randLock.Unlock()
+	randSeed, randGen, randUsed = seed, rand.New(rand.NewSource(seed)), false
+}
+
+// initRand seeds the generator if it has not been. randLock must be
+// held.
+func initRand() {
+	if randGen != nil {
+		return
+	}
+	randSeed = time.Now().UnixNano()
+	if env := os.Getenv(SeedEnv); env != "" {
+		seed, err := strconv.ParseInt(env, 10, 64)
+		if err != nil {
+			panic(fmt.Sprintf("argot: $%s must be an integer; found %q", SeedEnv, env))
+		}
+		randSeed = seed
+	}
+	randGen = rand.New(rand.NewSource(randSeed))
+}
+
+// randInt63n returns a random number in [0, n) from argot's random
+// number generator.
+func randInt63n(n int64) int64 {
+	randLock.Lock()
+	defer randLock.Unlock()
+	initRand()
+	randUsed = true
+	return randGen.Int63n(n)
+}
+
+// NewRand returns a new random number generator seeded from argot's
+// random number generator (see Seed), for randomised tests of your own
+// which should be reproducible alongside argot's.
+func NewRand() *rand.Rand {
+	return rand.New(rand.NewSource(randInt63n(1<<62) + 1))
+}
+
+// seedMessage returns a line reporting the seed, preceded by a
+// newline, for failure messages, if any random number has been
+// generated, and otherwise the empty string.
+func seedMessage() string {
+	randLock.Lock()
+	defer randLock.Unlock()
+	if !randUsed {
+		return ""
+	} else {
+		return fmt.Sprintf("\nSeed: %d (set $%s to reproduce)", randSeed, SeedEnv)
+	}
+}
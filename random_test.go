@@ -0,0 +1,36 @@
+package argot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSeedReproducible(t *testing.T) {
+	defer SetSeed(Seed())
+	draw := func() []int64 {
+		SetSeed(42)
+		return []int64{randInt63n(1000), NewRand().Int63(), NewRandomFaker().rand.Int63()}
+	}
+	first, second := draw(), draw()
+	for idx := range first {
+		if first[idx] != second[idx] {
+			t.Fatalf("Draw %d: Expected %d; found %d.", idx, first[idx], second[idx])
+		}
+	}
+	if Seed() != 42 {
+		t.Fatalf("Expected seed 42; found %d.", Seed())
+	}
+}
+
+func TestSeedMessage(t *testing.T) {
+	defer SetSeed(Seed())
+	SetSeed(7)
+	if msg := seedMessage(); msg != "" {
+		t.Fatalf("Expected no seed message before use; found %q.", msg)
+	}
+	randInt63n(10)
+	if msg := formatFatalSteps(nil, errors.New("failed")); !strings.HasSuffix(msg, "\nSeed: 7 (set $ARGOT_SEED to reproduce)") {
+		t.Fatalf("Expected the seed to be reported; found %q.", msg)
+	}
+}
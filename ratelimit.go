@@ -0,0 +1,274 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitedTransport is an http.RoundTripper which limits the rate
+// at which requests are sent using a token bucket: up to Burst
+// requests may be sent at once, after which requests are delayed so
+// that on average no more than Rate are sent per second. This keeps
+// large data-driven suites within the target's rate limits without
+// ad-hoc sleeps. It records when each request was sent so that steps
+// can assert on the observed pacing. A RateLimitedTransport is safe
+// for concurrent use, and may be shared by several HttpCalls to limit
+// their combined rate.
+type RateLimitedTransport struct {
+	// Transport performs the requests. If nil, http.DefaultTransport
+	// is used.
+	Transport http.RoundTripper
+	// Rate is the sustained number of requests per second. If zero,
+	// requests are not limited.
+	Rate float64
+	// Burst is the number of requests which may be sent at once. If
+	// less than one, one is used.
+	Burst int
+
+	lock   sync.Mutex
+	tokens float64
+	last   time.Time
+	sent   []time.Time
+}
+
+// NewRateLimitedTransport creates a new RateLimitedTransport wrapping
+// transport.
+func NewRateLimitedTransport(transport http.RoundTripper, rate float64, burst int) *RateLimitedTransport {
+	return &RateLimitedTransport{Transport: transport, Rate: rate, Burst: burst}
+}
+
+// RateLimit replaces hc.Client with a copy whose Transport is wrapped
+// in a new RateLimitedTransport, so that requests made by hc are sent
+// at no more than rate per second (after an initial burst). The
+// original client is not modified. The RateLimitedTransport is
+// returned for use in assertions.
+func (hc *HttpCall) RateLimit(rate float64, burst int) *RateLimitedTransport {
+	client := *hc.Client
+	rl := NewRateLimitedTransport(client.Transport, rate, burst)
+	client.Transport = rl
+	hc.Client = &client
+	return rl
+}
+
+func (rl *RateLimitedTransport) transport() http.RoundTripper {
+	if rl.Transport == nil {
+		return http.DefaultTransport
+	} else {
+		return rl.Transport
+	}
+}
+
+func (rl *RateLimitedTransport) burst() int {
+	if rl.Burst < 1 {
+		return 1
+	} else {
+		return rl.Burst
+	}
+}
+
+// reserve takes a token from the bucket, returning how long the caller
+// must wait before the token is valid.
+func (rl *RateLimitedTransport) reserve() time.Duration {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	now := time.Now()
+	burst := float64(rl.burst())
+	if rl.last.IsZero() {
+		rl.tokens = burst
+	} else if rl.tokens += now.Sub(rl.last).Seconds() * rl.Rate; rl.tokens > burst {
+		rl.tokens = burst
+	}
+	rl.last = now
+	rl.tokens--
+	if rl.tokens >= 0 {
+		return 0
+	} else {
+		return time.Duration(-rl.tokens / rl.Rate * float64(time.Second))
+	}
+}
+
+// RoundTrip implements http.RoundTripper. If the request's context is
+// cancelled whilst it is waiting to be sent, the context's error is
+// returned.
+func (rl *RateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rl.Rate > 0 {
+		if delay := rl.reserve(); delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				rl.lock.Lock()
+				rl.tokens++
+				rl.lock.Unlock()
+				return nil, req.Context().Err()
+			}
+		}
+	}
+	rl.lock.Lock()
+	rl.sent = append(rl.sent, time.Now())
+	rl.lock.Unlock()
+	return rl.transport().RoundTrip(req)
+}
+
+// Sent returns when each request was sent, in order.
+func (rl *RateLimitedTransport) Sent() []time.Time {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	return append([]time.Time(nil), rl.sent...)
+}
+
+// pacingJitter is the scheduling delay allowed for by ExpectPacing.
+const pacingJitter = 10 * time.Millisecond
+
+// ExpectPacing is a Step that when executed errors unless every
+// window of the requests sent so far respects the given rate and
+// burst: in any interval of length d, no more than burst + rate*d
+// requests may have been sent. Up to 10ms of scheduling jitter is
+// allowed for. The rate and burst need not be those of rl: for
+// example, the target API's published limit can be checked.
+func (rl *RateLimitedTransport) ExpectPacing(rate float64, burst int) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectPacing(%v/s, burst %d)", rate, burst), func() error {
+		sent := rl.Sent()
+		if len(sent) == 0 {
+			return errors.New("Pacing: no requests sent.")
+		}
+		for i := range sent {
+			for j := i; j < len(sent); j++ {
+				elapsed := sent[j].Sub(sent[i]) + pacingJitter
+				if allowed := float64(burst) + rate*elapsed.Seconds(); float64(j-i+1) > allowed {
+					return fmt.Errorf("Pacing: Expected at most %.1f requests in %v; found %d (requests %d to %d).",
+						allowed, sent[j].Sub(sent[i]), j-i+1, i+1, j+1)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// ExpectSentRequests is a Step that when executed errors unless
+// exactly n requests have been sent.
+func (rl *RateLimitedTransport) ExpectSentRequests(n int) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectSentRequests(%d)", n), func() error {
+		if found := len(rl.Sent()); found != n {
+			return fmt.Errorf("Pacing: Expected %d requests sent; found %d.", n, found)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectRateLimited is a Step that when executed sends copies of
+// hc.Request, up to max times, until a response with status 429 (Too
+// Many Requests) is received, and errors if none is. The 429 response
+// becomes hc.Response, so that its headers can then be checked with
+// ExpectRateLimitHeaders and the limiter's recovery with
+// ExpectRetryAfterSucceeds. The request body, if any, must be
+// replayable (see LatencyPercentiles).
+func (hc *HttpCall) ExpectRateLimited(max int) Step {
+	return hc.step(fmt.Sprintf("ExpectRateLimited(%d)", max), func() error {
+		if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
+			return err
+		}
+		for idx := 0; idx < max; idx++ {
+			response, err := hc.replayRequest()
+			if err != nil {
+				return fmt.Errorf("Rate limit: request %d: %v", idx+1, err)
+			} else if response.StatusCode == http.StatusTooManyRequests {
+				hc.Response = response
+				return nil
+			}
+			io.Copy(ioutil.Discard, response.Body)
+			response.Body.Close()
+		}
+		return fmt.Errorf("Rate limit: Expected status %d within %d requests; found none.", http.StatusTooManyRequests, max)
+	})
+}
+
+// retryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func retryAfter(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, nil
+	} else if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date), nil
+	} else {
+		return 0, fmt.Errorf("Retry-After: Expected seconds or an HTTP date; found %q.", value)
+	}
+}
+
+// rateLimitHeader returns the value of the named rate limit header,
+// trying both the X-RateLimit- and the RateLimit- prefixes.
+func rateLimitHeader(header http.Header, name string) (string, bool) {
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		if values, found := header[http.CanonicalHeaderKey(prefix+name)]; found && len(values) != 0 {
+			return values[0], true
+		}
+	}
+	return "", false
+}
+
+// ExpectRateLimitHeaders is a Step that when executed ensures there is
+// a non-nil hc.Response and errors unless it carries a valid
+// Retry-After header, and numeric Limit, Remaining and Reset headers
+// (with either the X-RateLimit- or RateLimit- prefix). If the status
+// is 429, Remaining must be 0.
+func (hc *HttpCall) ExpectRateLimitHeaders() Step {
+	return hc.step("ExpectRateLimitHeaders", func() error {
+		if err := hc.EnsureResponse(); err != nil {
+			return err
+		} else if _, err := retryAfter(hc.Response.Header.Get("Retry-After")); err != nil {
+			return err
+		}
+		for _, name := range []string{"Limit", "Remaining", "Reset"} {
+			if value, found := rateLimitHeader(hc.Response.Header, name); !found {
+				return fmt.Errorf("Rate limit: Expected header X-RateLimit-%s or RateLimit-%s; found neither.", name, name)
+			} else if n, err := strconv.ParseInt(value, 10, 64); err != nil {
+				return fmt.Errorf("Rate limit %s: Expected a number; found %q.", name, value)
+			} else if name == "Remaining" && hc.Response.StatusCode == http.StatusTooManyRequests && n != 0 {
+				return fmt.Errorf("Rate limit Remaining: Expected 0 when rate limited; found %d.", n)
+			}
+		}
+		return nil
+	})
+}
+
+// ExpectRetryAfterSucceeds is a Step that when executed ensures there
+// is a non-nil hc.Response, waits for the time given by its
+// Retry-After header, and then sends a copy of hc.Request, erroring
+// unless it is not rate limited. It errors without waiting if
+// Retry-After exceeds maxWait. The new response becomes hc.Response.
+func (hc *HttpCall) ExpectRetryAfterSucceeds(maxWait time.Duration) Step {
+	return hc.step(fmt.Sprintf("ExpectRetryAfterSucceeds(%v)", maxWait), func() error {
+		if err := hc.EnsureResponse(); err != nil {
+			return err
+		}
+		wait, err := retryAfter(hc.Response.Header.Get("Retry-After"))
+		if err != nil {
+			return err
+		} else if wait > maxWait {
+			return fmt.Errorf("Retry-After: Expected at most %v; found %v.", maxWait, wait)
+		}
+		time.Sleep(wait)
+		response, err := hc.replayRequest()
+		if err != nil {
+			return fmt.Errorf("Rate limit: retry: %v", err)
+		}
+		if hc.ResponseBody == nil {
+			io.Copy(ioutil.Discard, hc.Response.Body)
+			hc.Response.Body.Close()
+		}
+		hc.Response, hc.ResponseBody = response, nil
+		if response.StatusCode == http.StatusTooManyRequests {
+			return fmt.Errorf("Rate limit: Expected retry after %v to succeed; found status %d.", wait, response.StatusCode)
+		} else {
+			return nil
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	rl := hc.RateLimit(50, 2)
+	steps := Steps{}
+	for idx := 0; idx < 8; idx++ {
+		steps = append(steps, hc.NewRequest("GET", server.URL, nil), hc.ResponseStatusEquals(200))
+	}
+	start := time.Now()
+	steps.Test(t)
+	// Two requests are sent at once; the remaining six at 50/s.
+	if elapsed := time.Since(start); elapsed < 110*time.Millisecond {
+		t.Fatalf("Expected rate limiting to take at least 120ms; took %v", elapsed)
+	}
+	Steps{
+		rl.ExpectSentRequests(8),
+		rl.ExpectPacing(50, 2),
+		ExpectError(rl.ExpectPacing(10, 1)),
+	}.Test(t)
+	if hc.Client.Transport != rl {
+		t.Fatal("Expected RateLimit to install the transport.")
+	}
+}
+
+func TestRateLimitContract(t *testing.T) {
+	var lock sync.Mutex
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		count++
+		w.Header().Set("X-RateLimit-Limit", "3")
+		w.Header().Set("X-RateLimit-Reset", "0")
+		if count > 3 {
+			// The window resets immediately, so Retry-After is 0.
+			count = 0
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		} else {
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(3-count))
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("POST", server.URL, strings.NewReader("body")),
+		hc.ExpectRateLimited(10),
+		hc.ResponseStatusEquals(http.StatusTooManyRequests),
+		hc.ExpectRateLimitHeaders(),
+		hc.ExpectRetryAfterSucceeds(time.Second),
+		hc.ResponseStatusEquals(http.StatusOK),
+		ExpectError(hc.ExpectRateLimitHeaders()),
+		hc.NewRequest("GET", server.URL, nil),
+		ExpectError(hc.ExpectRateLimited(2)),
+	}.Test(t)
+}
@@ -0,0 +1,280 @@
+package argot
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RawHttpCall sends byte-exact HTTP/1.1 requests over a single
+// connection, bypassing http.Client, so that deliberately malformed
+// requests, such as those with invalid headers or with conflicting
+// Content-Length and Transfer-Encoding headers (as used in request
+// smuggling), can be sent, and the server's rejection of them
+// asserted. Responses are read from the connection in order, and can
+// be loaded into an HttpCall so that its steps can assert on them. A
+// RawHttpCall can only be used by a single go-routine at a time.
+type RawHttpCall struct {
+	// The Dialer used to connect. If nil, a zero net.Dialer is used.
+	Dialer *net.Dialer
+	// Used for https URLs.
+	TLSConfig *tls.Config
+	// The connection, once connected.
+	Conn net.Conn
+
+	url     *url.URL
+	reader  *bufio.Reader
+	pending []string
+	closed  bool
+}
+
+// NewRawHttpCall creates a new RawHttpCall.
+func NewRawHttpCall() *RawHttpCall {
+	return &RawHttpCall{}
+}
+
+// RawRequest joins lines with CRLF, as HTTP/1.1 requires, and ends
+// the request head with an empty line. body, if any, follows verbatim.
+// For example RawRequest("", "GET / HTTP/1.1", "Host: example.com").
+func RawRequest(body string, lines ...string) string {
+	return strings.Join(lines, "\r\n") + "\r\n\r\n" + body
+}
+
+// Reset is idempotent. You should ensure this is called at the end of
+// life for each RawHttpCall. It closes any connection.
+func (rc *RawHttpCall) Reset() error {
+	if rc.Conn != nil {
+		rc.Conn.Close()
+	}
+	rc.Conn = nil
+	rc.url = nil
+	rc.reader = nil
+	rc.pending = nil
+	rc.closed = false
+	return nil
+}
+
+func (rc *RawHttpCall) assertConnected() error {
+	if rc.Conn == nil {
+		return errors.New("Not connected.")
+	} else {
+		return nil
+	}
+}
+
+// Connect is a Step that when executed connects to the host of the
+// http or https URL. The step will automatically call rc.Reset first.
+func (rc *RawHttpCall) Connect(urlStr string) Step {
+	return NewNamedStep(fmt.Sprintf("Connect(%s)", DefaultRedactor.String(urlStr)), func() error {
+		if err := rc.Reset(); err != nil {
+			return err
+		}
+		u, err := url.Parse(urlStr)
+		if err != nil {
+			return err
+		}
+		host := u.Host
+		switch u.Scheme {
+		case "http":
+			if u.Port() == "" {
+				host = net.JoinHostPort(u.Hostname(), "80")
+			}
+		case "https":
+			if u.Port() == "" {
+				host = net.JoinHostPort(u.Hostname(), "443")
+			}
+		default:
+			return fmt.Errorf("Raw HTTP: unsupported scheme '%s'.", u.Scheme)
+		}
+		dialer := rc.Dialer
+		if dialer == nil {
+			dialer = new(net.Dialer)
+		}
+		conn, err := dialer.Dial("tcp", host)
+		if err != nil {
+			return fmt.Errorf("Raw HTTP: error when dialing %s: %v", host, err)
+		}
+		if u.Scheme == "https" {
+			config := rc.TLSConfig
+			if config == nil {
+				config = new(tls.Config)
+			}
+			if config.ServerName == "" {
+				config = config.Clone()
+				config.ServerName = u.Hostname()
+			}
+			tlsConn := tls.Client(conn, config)
+			if err := tlsConn.Handshake(); err != nil {
+				conn.Close()
+				return err
+			}
+			conn = tlsConn
+		}
+		rc.Conn = conn
+		rc.url = u
+		rc.reader = bufio.NewReader(conn)
+		return nil
+	})
+}
+
+// Send is a Step that when executed writes raw to the connection
+// exactly as given. If raw starts with a request line, it is used to
+// describe the request to which the next unread response is the
+// answer (see ReceiveResponse): any further requests within raw, such
+// as one smuggled in the body of the first, are not.
+func (rc *RawHttpCall) Send(raw string) Step {
+	firstLine := raw
+	if idx := strings.Index(raw, "\r\n"); idx >= 0 {
+		firstLine = raw[:idx]
+	}
+	return NewNamedStep(fmt.Sprintf("Send(%q: %d bytes)", DefaultRedactor.String(firstLine), len(raw)), func() error {
+		if err := rc.assertConnected(); err != nil {
+			return err
+		} else if _, err := io.WriteString(rc.Conn, raw); err != nil {
+			return err
+		}
+		if fields := strings.Fields(firstLine); len(fields) == 3 && strings.HasPrefix(fields[2], "HTTP/") {
+			rc.pending = append(rc.pending, firstLine)
+		}
+		return nil
+	})
+}
+
+// request returns a request describing the one to which the next
+// response is the answer: a GET of the connection's URL if unknown.
+func (rc *RawHttpCall) request() (*http.Request, error) {
+	method, target := http.MethodGet, rc.url.RequestURI()
+	if len(rc.pending) > 0 {
+		fields := strings.Fields(rc.pending[0])
+		rc.pending = rc.pending[1:]
+		method, target = fields[0], fields[1]
+	}
+	if ref, err := url.Parse(target); err == nil {
+		return http.NewRequest(method, rc.url.ResolveReference(ref).String(), nil)
+	}
+	return http.NewRequest(method, rc.url.String(), nil)
+}
+
+// isTimeout returns whether err is a network timeout.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// readResponse reads the next response within timeout, returning the
+// network error if it times out (see isTimeout). If the connection is
+// closed (or reset) before a response is received, both the response
+// and the error are nil.
+func (rc *RawHttpCall) readResponse(timeout time.Duration) (*http.Response, error) {
+	if err := rc.assertConnected(); err != nil {
+		return nil, err
+	} else if rc.closed {
+		return nil, nil
+	}
+	req, err := rc.request()
+	if err != nil {
+		return nil, err
+	}
+	rc.Conn.SetReadDeadline(time.Now().Add(timeout))
+	defer rc.Conn.SetReadDeadline(time.Time{})
+	response, err := http.ReadResponse(rc.reader, req)
+	if isTimeout(err) {
+		return nil, err
+	} else if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+		rc.closed = true
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Raw HTTP: Malformed response: %v", err)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("Raw HTTP: Response body: %v", err)
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if response.Close {
+		rc.closed = true
+	}
+	return response, nil
+}
+
+// ReceiveResponse is a Step that when executed reads the next response
+// from the connection within timeout, and loads it into hc, which is
+// first reset, along with a request describing the one it answers (see
+// Send), so that any of hc's steps can assert on it, for example
+// hc.ResponseStatusEquals. It errors if the connection is closed
+// without a response.
+func (rc *RawHttpCall) ReceiveResponse(hc *HttpCall, timeout time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("ReceiveResponse(%v)", timeout), func() error {
+		response, err := rc.readResponse(timeout)
+		if isTimeout(err) {
+			return fmt.Errorf("Raw HTTP: Expected a response; none within %v.", timeout)
+		} else if err != nil {
+			return err
+		} else if response == nil {
+			return errors.New("Raw HTTP: Expected a response; the connection was closed.")
+		} else if err := hc.Reset(); err != nil {
+			return err
+		}
+		hc.Request = response.Request
+		hc.Response = response
+		return nil
+	})
+}
+
+// ExpectRejected is a Step that when executed errors unless, within
+// timeout, the server either responds to the next request with a 4xx
+// or 5xx status, or closes the connection without responding.
+func (rc *RawHttpCall) ExpectRejected(timeout time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectRejected(%v)", timeout), func() error {
+		response, err := rc.readResponse(timeout)
+		if isTimeout(err) {
+			return fmt.Errorf("Expected the request to be rejected; no response within %v.", timeout)
+		} else if err != nil {
+			return fmt.Errorf("Expected the request to be rejected: %v", err)
+		} else if response != nil && response.StatusCode < 400 {
+			return fmt.Errorf("Expected the request to be rejected; found status %d.", response.StatusCode)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectNoResponse is a Step that when executed errors if a further
+// response is received within timeout: for example, the response to a
+// request smuggled within the body of one already answered. The
+// connection being closed is not an error.
+func (rc *RawHttpCall) ExpectNoResponse(timeout time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectNoResponse(%v)", timeout), func() error {
+		response, err := rc.readResponse(timeout)
+		if response != nil {
+			return fmt.Errorf("Expected no response; found status %d to %s %s.", response.StatusCode, response.Request.Method, response.Request.URL.RequestURI())
+		} else if err != nil && !isTimeout(err) {
+			return err
+		} else {
+			return nil
+		}
+	})
+}
+
+// Close is a Step that when executed closes the connection.
+func (rc *RawHttpCall) Close() Step {
+	return NewNamedStep("Close", func() error {
+		if err := rc.assertConnected(); err != nil {
+			return err
+		}
+		err := rc.Conn.Close()
+		rc.Conn = nil
+		return err
+	})
+}
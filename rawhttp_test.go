@@ -0,0 +1,84 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRawHttpCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"path": "` + r.URL.Path + `"}`))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	rc := NewRawHttpCall()
+	defer rc.Reset()
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+
+	// Pipelined requests are answered in order, and can be asserted on
+	// with an HttpCall.
+	Steps{
+		rc.Connect(server.URL),
+		rc.Send(RawRequest("", "GET /teas/1 HTTP/1.1", "Host: "+host)),
+		rc.Send(RawRequest("", "HEAD /teas/2 HTTP/1.1", "Host: "+host)),
+		rc.ReceiveResponse(hc, time.Second),
+		hc.ResponseStatusEquals(http.StatusOK),
+		hc.ResponseBodyJSONPathEquals("path", "/teas/1"),
+		rc.ReceiveResponse(hc, time.Second),
+		hc.ResponseStatusEquals(http.StatusOK),
+		rc.ExpectNoResponse(50 * time.Millisecond),
+	}.Test(t)
+	if hc.Request.Method != "HEAD" || hc.Request.URL.String() != server.URL+"/teas/2" {
+		t.Fatalf("Expected the request to be described as HEAD %s/teas/2; found %s %s", server.URL, hc.Request.Method, hc.Request.URL)
+	}
+
+	// Malformed requests are rejected.
+	Steps{
+		rc.Connect(server.URL),
+		rc.Send(RawRequest("", "GET / HTTP/1.1", "Host: "+host, "Bad Header")),
+		rc.ExpectRejected(time.Second),
+		rc.Connect(server.URL),
+		rc.Send(RawRequest("abcde", "POST / HTTP/1.1", "Host: "+host, "Content-Length: 3", "Content-Length: 5")),
+		rc.ExpectRejected(time.Second),
+		rc.ExpectNoResponse(50 * time.Millisecond),
+	}.Test(t)
+
+	// A well-formed request is not rejected.
+	err := Steps{
+		rc.Connect(server.URL),
+		rc.Send(RawRequest("", "GET / HTTP/1.1", "Host: "+host)),
+		rc.ExpectRejected(time.Second),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "Expected the request to be rejected; found status 200.") {
+		t.Fatalf("Expected an error for an accepted request; found %v", err)
+	}
+
+	// A request smuggled within the body of another is caught if answered.
+	smuggled := RawRequest("0\r\n\r\n"+RawRequest("", "GET /smuggled HTTP/1.1", "Host: "+host),
+		"POST / HTTP/1.1", "Host: "+host, "Transfer-Encoding: chunked")
+	err = Steps{
+		rc.Connect(server.URL),
+		rc.Send(smuggled),
+		rc.ReceiveResponse(hc, time.Second),
+		rc.ExpectNoResponse(time.Second),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "Expected no response; found status 200 to GET /.") {
+		t.Fatalf("Expected an error for an answered smuggled request; found %v", err)
+	}
+
+	// Without a response, the step times out.
+	err = Steps{
+		rc.Connect(server.URL),
+		rc.Send("GET / HTTP/1.1\r\n"),
+		rc.ReceiveResponse(hc, 50*time.Millisecond),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "Expected a response; none within 50ms.") {
+		t.Fatalf("Expected a timeout; found %v", err)
+	}
+}
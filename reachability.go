@@ -0,0 +1,71 @@
+package argot
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// NotReadyError is the error of WaitForReachable when the environment
+// under test is not ready, distinguishing a service which is down, or
+// a host which is wrongly configured, from a failure of the service's
+// behaviour.
+type NotReadyError struct {
+	// The host:port which could not be reached.
+	Addr    string
+	Timeout time.Duration
+	// The error of the last attempt to connect.
+	Err error
+}
+
+func (e *NotReadyError) Error() string {
+	return fmt.Sprintf("Environment not ready: %s unreachable after %v (%v): %v", e.Addr, e.Timeout, ClassifyTransportError(e.Err), e.Err)
+}
+
+// Unwrap returns the error of the last attempt to connect.
+func (e *NotReadyError) Unwrap() error {
+	return e.Err
+}
+
+// WaitForReachable is a Step that when executed waits up to timeout,
+// backing off exponentially, for a TCP connection to port of host to
+// succeed, and errors with a NotReadyError if none does. Used as the
+// first step of a scenario, it gates the scenario on the environment
+// being up, so that an environment which is not ready fails clearly
+// rather than with confusing errors from later steps. (ICMP is not
+// used, as it requires privileges, and says nothing of whether the
+// port is open.)
+func WaitForReachable(host string, port int, timeout time.Duration) Step {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	return NewNamedStep(fmt.Sprintf("WaitForReachable(%s, %v)", addr, timeout), func() error {
+		var lastErr error
+		if err := waitFor(timeout, func() error {
+			conn, err := net.DialTimeout("tcp", addr, time.Second)
+			if err != nil {
+				lastErr = err
+				return err
+			}
+			return conn.Close()
+		}); err != nil {
+			return &NotReadyError{Addr: addr, Timeout: timeout, Err: lastErr}
+		}
+		return nil
+	})
+}
+
+// ExpectUnreachable is a Step that when executed attempts a TCP
+// connection to port of host, waiting up to timeout, and errors if it
+// succeeds, for example to check that a firewall blocks an internal
+// port.
+func ExpectUnreachable(host string, port int, timeout time.Duration) Step {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	return NewNamedStep(fmt.Sprintf("ExpectUnreachable(%s, %v)", addr, timeout), func() error {
+		if conn, err := net.DialTimeout("tcp", addr, timeout); err != nil {
+			return nil
+		} else {
+			conn.Close()
+			return fmt.Errorf("Expected %s to be unreachable; connected.", addr)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package argot
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReachability(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	if err := WaitForReachable("127.0.0.1", port, time.Second).Go(); err != nil {
+		t.Fatal(err)
+	} else if err := ExpectUnreachable("127.0.0.1", port, time.Second).Go(); err == nil || !strings.Contains(err.Error(), "to be unreachable; connected.") {
+		t.Fatalf("Expected the open port to be reachable; found %v.", err)
+	}
+
+	listener.Close()
+	var notReady *NotReadyError
+	if err := ExpectUnreachable("127.0.0.1", port, time.Second).Go(); err != nil {
+		t.Fatal(err)
+	} else if err := WaitForReachable("127.0.0.1", port, 50*time.Millisecond).Go(); !errors.As(err, &notReady) {
+		t.Fatalf("Expected a NotReadyError; found %v.", err)
+	} else if !strings.HasPrefix(err.Error(), "Environment not ready: 127.0.0.1:") || ClassifyTransportError(err) != ConnectionRefused {
+		t.Fatalf("Expected the port to be refused; found %v.", err)
+	}
+}
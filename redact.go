@@ -0,0 +1,157 @@
+package argot
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// DefaultRedactedHeaders lists the headers whose values are replaced
+// before being written to a cassette or shown in failure output.
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+const redactedValue = "REDACTED"
+
+// Redactor removes sensitive data from step names and failure
+// output. Values of the listed headers are replaced wherever headers
+// are shown, and any text matching one of the patterns is
+// replaced. If a pattern contains capture groups, only the text
+// matched by the last group is replaced, which allows the context
+// (for example the name of a field) to be kept. Values added with
+// AddValue, such as secrets, are replaced wherever they appear.
+type Redactor struct {
+	Headers  []string
+	Patterns []*regexp.Regexp
+
+	lock   sync.RWMutex
+	values []string
+}
+
+// DefaultRedactor is used by HttpCalls that have no Redactor of their
+// own, and for all failure output produced by Steps.Test.
+var DefaultRedactor = &Redactor{
+	Headers: DefaultRedactedHeaders,
+	Patterns: []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\b(?:bearer|basic)\s+([A-Za-z0-9\-._~+/]+=*)`),
+		regexp.MustCompile(`(?i)"(?:password|passwd|secret|token|access_token|refresh_token|api_?key|client_secret)"\s*:\s*"((?:[^"\\]|\\.)*)"`),
+		regexp.MustCompile(`(?i)\b(?:password|passwd|secret|token|access_token|refresh_token|api_?key|client_secret)=([^&\s"']+)`),
+		regexp.MustCompile(`://[^/:@\s]*:([^/@\s]+)@`),
+	},
+}
+
+func (hc *HttpCall) redactor() *Redactor {
+	if hc.Redactor == nil {
+		return DefaultRedactor
+	} else {
+		return hc.Redactor
+	}
+}
+
+// ownRedactor returns hc.Redactor, first setting it to a copy of
+// DefaultRedactor if it is nil, so that values added to it, such as
+// hc's secrets, are not redacted from the output of every other
+// HttpCall.
+func (hc *HttpCall) ownRedactor() *Redactor {
+	if hc.Redactor == nil {
+		hc.Redactor = DefaultRedactor.Copy()
+	}
+	return hc.Redactor
+}
+
+// RedactsHeader returns true iff the values of the header key are
+// redacted.
+func (r *Redactor) RedactsHeader(key string) bool {
+	key = http.CanonicalHeaderKey(key)
+	for _, header := range r.Headers {
+		if http.CanonicalHeaderKey(header) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// HeaderValue returns the value, redacted if values of the header key
+// are redacted or if it matches any of the patterns.
+func (r *Redactor) HeaderValue(key, value string) string {
+	if r.RedactsHeader(key) {
+		return redactedValue
+	} else {
+		return r.String(value)
+	}
+}
+
+// Header returns a copy of header with values redacted as per
+// HeaderValue.
+func (r *Redactor) Header(header http.Header) http.Header {
+	clone := make(http.Header, len(header))
+	for key, values := range header {
+		for _, value := range values {
+			clone[key] = append(clone[key], r.HeaderValue(key, value))
+		}
+	}
+	return clone
+}
+
+// AddValue adds value to the values which are always redacted. It is
+// safe to call concurrently with the other methods of r. Empty values
+// are ignored.
+func (r *Redactor) AddValue(value string) {
+	if value == "" {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, existing := range r.values {
+		if existing == value {
+			return
+		}
+	}
+	r.values = append(r.values, value)
+}
+
+// Copy returns a new Redactor with the same headers, patterns and
+// values as r. Values added to either later are not added to the
+// other.
+func (r *Redactor) Copy() *Redactor {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return &Redactor{
+		Headers:  append([]string(nil), r.Headers...),
+		Patterns: append([]*regexp.Regexp(nil), r.Patterns...),
+		values:   append([]string(nil), r.values...),
+	}
+}
+
+// String returns s with all values added with AddValue, and all
+// matches of the patterns, redacted.
+func (r *Redactor) String(s string) string {
+	r.lock.RLock()
+	for _, value := range r.values {
+		s = strings.Replace(s, value, redactedValue, -1)
+	}
+	r.lock.RUnlock()
+	for _, pattern := range r.Patterns {
+		s = pattern.ReplaceAllStringFunc(s, func(match string) string {
+			groups := pattern.FindStringSubmatchIndex(match)
+			if n := len(groups); n <= 2 || groups[n-2] < 0 {
+				return redactedValue
+			} else {
+				return match[:groups[n-2]] + redactedValue + match[groups[n-1]:]
+			}
+		})
+	}
+	return s
+}
+
+// redactHeader returns a copy of header in which the values of the
+// given keys are replaced.
+func redactHeader(header http.Header, keys []string) http.Header {
+	header = cloneHeader(header)
+	for _, key := range keys {
+		if _, found := header[http.CanonicalHeaderKey(key)]; found {
+			header.Set(key, redactedValue)
+		}
+	}
+	return header
+}
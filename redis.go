@@ -0,0 +1,267 @@
+package argot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// RedisError is an error reply from a Redis server.
+type RedisError string
+
+func (e RedisError) Error() string {
+	return string(e)
+}
+
+// RedisCall is a minimal Redis client (speaking RESP directly) so
+// that scenarios can seed and verify cache state around HTTP calls. The
+// connection is made lazily and kept open until Reset. A RedisCall
+// can only be used by a single go-routine at a time.
+type RedisCall struct {
+	// The host:port of the Redis server.
+	Addr string
+	// If non-empty, AUTH is sent on connecting.
+	Password string
+	// The database selected on connecting.
+	DB int
+	// Timeout bounds connecting and each command. If zero, 5 seconds
+	// is used.
+	Timeout time.Duration
+
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisCall creates a new RedisCall for the server at addr.
+func NewRedisCall(addr string) *RedisCall {
+	return &RedisCall{Addr: addr}
+}
+
+// Reset is idempotent. You should ensure this is called at the end of
+// life for each RedisCall. It closes any connection.
+func (rc *RedisCall) Reset() error {
+	if rc.conn != nil {
+		rc.conn.Close()
+	}
+	rc.conn = nil
+	rc.reader = nil
+	return nil
+}
+
+func (rc *RedisCall) timeout() time.Duration {
+	if rc.Timeout == 0 {
+		return 5 * time.Second
+	} else {
+		return rc.Timeout
+	}
+}
+
+func (rc *RedisCall) ensureConn() error {
+	if rc.conn != nil {
+		return nil
+	} else if conn, err := net.DialTimeout("tcp", rc.Addr, rc.timeout()); err != nil {
+		return err
+	} else {
+		rc.conn = conn
+		rc.reader = bufio.NewReader(conn)
+	}
+	if rc.Password != "" {
+		if _, err := rc.do("AUTH", rc.Password); err != nil {
+			rc.Reset()
+			return err
+		}
+	}
+	if rc.DB != 0 {
+		if _, err := rc.do("SELECT", strconv.Itoa(rc.DB)); err != nil {
+			rc.Reset()
+			return err
+		}
+	}
+	return nil
+}
+
+// Do sends a command and returns its reply: a string for simple and
+// bulk strings, an int64 for integers, nil for null replies, and an
+// []interface{} for arrays. Error replies are returned as RedisError.
+func (rc *RedisCall) Do(args ...string) (interface{}, error) {
+	if err := rc.ensureConn(); err != nil {
+		return nil, err
+	}
+	return rc.do(args...)
+}
+
+func (rc *RedisCall) do(args ...string) (interface{}, error) {
+	rc.conn.SetDeadline(time.Now().Add(rc.timeout()))
+	writer := bufio.NewWriter(rc.conn)
+	fmt.Fprintf(writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := writer.Flush(); err != nil {
+		rc.Reset()
+		return nil, err
+	}
+	reply, err := readRESP(rc.reader)
+	if _, isRedisErr := err.(RedisError); err != nil && !isRedisErr {
+		// The connection is in an unknown state.
+		rc.Reset()
+	}
+	return reply, err
+}
+
+func readRESP(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	} else if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("Redis: malformed reply %q.", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, RedisError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		if size, err := strconv.Atoi(payload); err != nil {
+			return nil, err
+		} else if size < 0 {
+			return nil, nil
+		} else {
+			bites := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, bites); err != nil {
+				return nil, err
+			}
+			return string(bites[:size]), nil
+		}
+	case '*':
+		if size, err := strconv.Atoi(payload); err != nil {
+			return nil, err
+		} else if size < 0 {
+			return nil, nil
+		} else {
+			elems := make([]interface{}, size)
+			for idx := range elems {
+				if elems[idx], err = readRESP(reader); err != nil {
+					if _, isRedisErr := err.(RedisError); !isRedisErr {
+						return nil, err
+					}
+				}
+			}
+			return elems, nil
+		}
+	default:
+		return nil, fmt.Errorf("Redis: unknown reply type %q.", kind)
+	}
+}
+
+// Command is a Step that when executed sends the command and errors
+// if the reply is an error.
+func (rc *RedisCall) Command(args ...string) Step {
+	return NewNamedStep(fmt.Sprintf("RedisCommand(%v)", args), func() error {
+		_, err := rc.Do(args...)
+		return err
+	})
+}
+
+// Set is a Step that when executed sets key to value. If ttl is
+// non-zero, the key expires after ttl, rounded up to a whole
+// millisecond.
+func (rc *RedisCall) Set(key, value string, ttl time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("RedisSet(%s)", key), func() error {
+		args := []string{"SET", key, value}
+		if ttl > 0 {
+			ms := (ttl + time.Millisecond - 1) / time.Millisecond
+			args = append(args, "PX", strconv.FormatInt(int64(ms), 10))
+		}
+		_, err := rc.Do(args...)
+		return err
+	})
+}
+
+// Del is a Step that when executed deletes keys.
+func (rc *RedisCall) Del(keys ...string) Step {
+	return NewNamedStep(fmt.Sprintf("RedisDel(%v)", keys), func() error {
+		_, err := rc.Do(append([]string{"DEL"}, keys...)...)
+		return err
+	})
+}
+
+// ExpectGet is a Step that when executed errors unless key exists and
+// its value equals value.
+func (rc *RedisCall) ExpectGet(key, value string) Step {
+	return NewNamedStep(fmt.Sprintf("RedisExpectGet(%s)", key), func() error {
+		if reply, err := rc.Do("GET", key); err != nil {
+			return err
+		} else if reply == nil {
+			return fmt.Errorf("Redis: Expected key '%s' to exist; not found.", key)
+		} else if found, _ := reply.(string); found != value {
+			return diffError(key, value, found, "Redis: '%s': Diff: '%s'.", key, diff(value, found))
+		} else {
+			return nil
+		}
+	})
+}
+
+func (rc *RedisCall) exists(key string) (bool, error) {
+	if reply, err := rc.Do("EXISTS", key); err != nil {
+		return false, err
+	} else if count, ok := reply.(int64); !ok {
+		return false, fmt.Errorf("Redis: unexpected EXISTS reply %v.", reply)
+	} else {
+		return count > 0, nil
+	}
+}
+
+// ExpectExists is a Step that when executed errors unless key exists.
+func (rc *RedisCall) ExpectExists(key string) Step {
+	return NewNamedStep(fmt.Sprintf("RedisExpectExists(%s)", key), func() error {
+		if exists, err := rc.exists(key); err != nil {
+			return err
+		} else if !exists {
+			return fmt.Errorf("Redis: Expected key '%s' to exist; not found.", key)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectNotExists is a Step that when executed errors unless key does
+// not exist. This is useful for checking cache invalidation.
+func (rc *RedisCall) ExpectNotExists(key string) Step {
+	return NewNamedStep(fmt.Sprintf("RedisExpectNotExists(%s)", key), func() error {
+		if exists, err := rc.exists(key); err != nil {
+			return err
+		} else if exists {
+			return fmt.Errorf("Redis: Expected key '%s' not to exist; found.", key)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectTTL is a Step that when executed errors unless key exists,
+// has an expiry, and its remaining time to live is between min and max
+// inclusive.
+func (rc *RedisCall) ExpectTTL(key string, min, max time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("RedisExpectTTL(%s: %v-%v)", key, min, max), func() error {
+		if reply, err := rc.Do("PTTL", key); err != nil {
+			return err
+		} else if ms, ok := reply.(int64); !ok {
+			return fmt.Errorf("Redis: unexpected PTTL reply %v.", reply)
+		} else if ms == -2 {
+			return fmt.Errorf("Redis: Expected key '%s' to exist; not found.", key)
+		} else if ms == -1 {
+			return fmt.Errorf("Redis: Expected key '%s' to expire; it has no TTL.", key)
+		} else if ttl := time.Duration(ms) * time.Millisecond; ttl < min || ttl > max {
+			return fmt.Errorf("Redis: TTL of '%s': Expected between %v and %v; found %v.", key, min, max, ttl)
+		} else {
+			return nil
+		}
+	})
+}
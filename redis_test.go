@@ -0,0 +1,108 @@
+package argot
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis implements just enough of Redis to exercise RedisCall.
+func fakeRedis(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lock sync.Mutex
+	values := make(map[string]string)
+	expiries := make(map[string]time.Time)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					reply, err := readRESP(reader)
+					if err != nil {
+						return
+					}
+					args := reply.([]interface{})
+					lock.Lock()
+					switch args[0] {
+					case "SET":
+						ms := 0
+						if len(args) == 5 {
+							ms, _ = strconv.Atoi(args[4].(string))
+						}
+						if len(args) == 5 && ms <= 0 {
+							fmt.Fprint(conn, "-ERR invalid expire time in 'set' command\r\n")
+							break
+						}
+						values[args[1].(string)] = args[2].(string)
+						if len(args) == 5 {
+							expiries[args[1].(string)] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+						}
+						fmt.Fprint(conn, "+OK\r\n")
+					case "GET":
+						if value, found := values[args[1].(string)]; found {
+							fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+						} else {
+							fmt.Fprint(conn, "$-1\r\n")
+						}
+					case "DEL":
+						delete(values, args[1].(string))
+						fmt.Fprint(conn, ":1\r\n")
+					case "EXISTS":
+						if _, found := values[args[1].(string)]; found {
+							fmt.Fprint(conn, ":1\r\n")
+						} else {
+							fmt.Fprint(conn, ":0\r\n")
+						}
+					case "PTTL":
+						if _, found := values[args[1].(string)]; !found {
+							fmt.Fprint(conn, ":-2\r\n")
+						} else if expiry, found := expiries[args[1].(string)]; !found {
+							fmt.Fprint(conn, ":-1\r\n")
+						} else {
+							fmt.Fprintf(conn, ":%d\r\n", time.Until(expiry)/time.Millisecond)
+						}
+					default:
+						fmt.Fprintf(conn, "-ERR unknown command '%v'\r\n", args[0])
+					}
+					lock.Unlock()
+				}
+			}()
+		}
+	}()
+	return listener
+}
+
+func TestRedisCall(t *testing.T) {
+	listener := fakeRedis(t)
+	defer listener.Close()
+	rc := NewRedisCall(listener.Addr().String())
+	defer rc.Reset()
+
+	Steps{
+		rc.Set("user:1", "alice", time.Minute),
+		rc.ExpectGet("user:1", "alice"),
+		rc.ExpectExists("user:1"),
+		rc.ExpectTTL("user:1", 50*time.Second, time.Minute),
+		ExpectError(rc.ExpectGet("user:1", "bob")),
+		rc.Set("plain", "x", 0),
+		rc.Set("brief", "x", time.Microsecond),
+		rc.ExpectExists("brief"),
+		ExpectError(rc.ExpectTTL("plain", 0, time.Minute)),
+		rc.Del("user:1"),
+		rc.ExpectNotExists("user:1"),
+		ExpectError(rc.Command("FLUSHALL")),
+		rc.ExpectGet("plain", "x"),
+	}.Test(t)
+}
@@ -0,0 +1,171 @@
+package argot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// RemoteFiles is a remote file store, such as an FTP or SFTP server,
+// on which RemoteFileCall asserts. FTPClient implements it. argot does
+// not include an SSH implementation, so for SFTP wrap a client such as
+// that of github.com/pkg/sftp, whose Stat, Open, Create and Remove
+// methods each implement one of these almost directly. Size and Remove
+// must error with an error satisfying errors.Is(err, os.ErrNotExist)
+// if the file does not exist.
+type RemoteFiles interface {
+	Size(path string) (int64, error)
+	ReadFile(path string) ([]byte, error)
+	WriteFile(path string, data []byte) error
+	Remove(path string) error
+	Close() error
+}
+
+// RemoteFileCall provides steps which verify the files on a
+// RemoteFiles, for pipelines which drop files onto an FTP or SFTP
+// server as a side effect of API calls, and which upload fixtures,
+// remembering them so that they can be removed by Cleanup. A
+// RemoteFileCall can only be used by a single go-routine at a time.
+type RemoteFileCall struct {
+	Files RemoteFiles
+	// How often ExpectFile checks for the file. If zero, 100
+	// milliseconds is used.
+	PollInterval time.Duration
+
+	uploaded []string
+}
+
+// NewRemoteFileCall creates a new RemoteFileCall for files.
+func NewRemoteFileCall(files RemoteFiles) *RemoteFileCall {
+	return &RemoteFileCall{Files: files}
+}
+
+// Reset is idempotent. You should ensure this is called at the end of
+// life for each RemoteFileCall. It closes rfc.Files, without removing
+// any uploaded files (see Cleanup).
+func (rfc *RemoteFileCall) Reset() error {
+	rfc.uploaded = nil
+	return rfc.Files.Close()
+}
+
+func (rfc *RemoteFileCall) pollInterval() time.Duration {
+	if rfc.PollInterval == 0 {
+		return 100 * time.Millisecond
+	} else {
+		return rfc.PollInterval
+	}
+}
+
+// ExpectFile is a Step that when executed waits up to timeout for a
+// file to exist at path, and errors if none does.
+func (rfc *RemoteFileCall) ExpectFile(path string, timeout time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectFile(%s, %v)", path, timeout), func() error {
+		deadline := time.Now().Add(timeout)
+		for {
+			if _, err := rfc.Files.Size(path); err == nil {
+				return nil
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return err
+			} else if !time.Now().Before(deadline) {
+				return fmt.Errorf("Files: Expected %s to exist within %v; not found.", path, timeout)
+			}
+			time.Sleep(rfc.pollInterval())
+		}
+	})
+}
+
+// ExpectNoFile is a Step that when executed errors if a file exists at
+// path.
+func (rfc *RemoteFileCall) ExpectNoFile(path string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectNoFile(%s)", path), func() error {
+		if size, err := rfc.Files.Size(path); err == nil {
+			return fmt.Errorf("Files: Expected %s not to exist; found %d bytes.", path, size)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectFileSize is a Step that when executed errors unless the file
+// at path is size bytes long.
+func (rfc *RemoteFileCall) ExpectFileSize(path string, size int64) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectFileSize(%s, %d)", path, size), func() error {
+		if found, err := rfc.Files.Size(path); err != nil {
+			return err
+		} else if found != size {
+			return fmt.Errorf("Files: %s: Expected %d bytes; found %d.", path, size, found)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectFileSHA256 is a Step that when executed errors unless the
+// SHA-256 digest of the file at path equals digest, given in hex.
+func (rfc *RemoteFileCall) ExpectFileSHA256(path, digest string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectFileSHA256(%s, %s)", path, digest), func() error {
+		if data, err := rfc.Files.ReadFile(path); err != nil {
+			return err
+		} else if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != strings.ToLower(digest) {
+			return fmt.Errorf("Files: %s: Expected SHA-256 %s; found %s.", path, digest, hex.EncodeToString(sum[:]))
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectFileContains is a Step that when executed errors unless the
+// file at path contains substr.
+func (rfc *RemoteFileCall) ExpectFileContains(path, substr string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectFileContains(%s, %s)", path, substr), func() error {
+		if data, err := rfc.Files.ReadFile(path); err != nil {
+			return err
+		} else if !strings.Contains(string(data), substr) {
+			return fmt.Errorf("Files: %s: Expected to contain '%s'; not found in %d bytes.", path, substr, len(data))
+		} else {
+			return nil
+		}
+	})
+}
+
+// Upload is a Step that when executed writes data to path, as a
+// fixture, to be removed by Cleanup.
+func (rfc *RemoteFileCall) Upload(path string, data []byte) Step {
+	return NewNamedStep(fmt.Sprintf("Upload(%s)", path), func() error {
+		if err := rfc.Files.WriteFile(path, data); err != nil {
+			return err
+		}
+		rfc.uploaded = append(rfc.uploaded, path)
+		return nil
+	})
+}
+
+// Remove is a Step that when executed removes the file at path, for
+// example one dropped by the system under test. It errors if there is
+// no such file.
+func (rfc *RemoteFileCall) Remove(path string) Step {
+	return NewNamedStep(fmt.Sprintf("Remove(%s)", path), func() error {
+		return rfc.Files.Remove(path)
+	})
+}
+
+// Cleanup is a Step that when executed removes every file uploaded by
+// Upload, in reverse order, ignoring those which no longer exist.
+func (rfc *RemoteFileCall) Cleanup() Step {
+	return NewNamedStep("Cleanup", func() error {
+		for len(rfc.uploaded) > 0 {
+			path := rfc.uploaded[len(rfc.uploaded)-1]
+			if err := rfc.Files.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			rfc.uploaded = rfc.uploaded[:len(rfc.uploaded)-1]
+		}
+		return nil
+	})
+}
@@ -0,0 +1,386 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type jsonStepReport struct {
+	Name          string  `json:"name"`
+	Duration      float64 `json:"durationSeconds"`
+	BytesSent     int64   `json:"bytesSent"`
+	BytesReceived int64   `json:"bytesReceived"`
+	Error         string  `json:"error,omitempty"`
+	// Only set for retried steps (see Flaky).
+	Attempts      int          `json:"attempts,omitempty"`
+	RetriedErrors []string     `json:"retriedErrors,omitempty"`
+	Artifacts     []Artifact   `json:"artifacts,omitempty"`
+	Differences   []Difference `json:"differences,omitempty"`
+}
+
+type jsonScenarioReport struct {
+	Name          string           `json:"name"`
+	Passed        bool             `json:"passed"`
+	Started       time.Time        `json:"started"`
+	Duration      float64          `json:"durationSeconds"`
+	BytesSent     int64            `json:"bytesSent"`
+	BytesReceived int64            `json:"bytesReceived"`
+	Error         string           `json:"error,omitempty"`
+	Flaky         int              `json:"flakySteps,omitempty"`
+	Params        Case             `json:"parameters,omitempty"`
+	Steps         []jsonStepReport `json:"steps"`
+}
+
+type jsonReport struct {
+	Passed    int                  `json:"passed"`
+	Failed    int                  `json:"failed"`
+	Duration  float64              `json:"durationSeconds"`
+	Scenarios []jsonScenarioReport `json:"scenarios"`
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	} else {
+		return DefaultRedactor.String(err.Error())
+	}
+}
+
+// redactedDifferences returns the Differences carried by err (see
+// StepError), with the strings within their values redacted by the
+// Redactor of the HttpCall whose step failed, if any, and by
+// DefaultRedactor. Values which cannot be encoded as JSON are
+// formatted with fmt.Sprint.
+func redactedDifferences(err error) []Difference {
+	diffs := Differences(err)
+	if len(diffs) == 0 {
+		return nil
+	}
+	redactors := []*Redactor{DefaultRedactor}
+	var hcErr *HttpCallError
+	if errors.As(err, &hcErr) && hcErr.redactor != nil && hcErr.redactor != DefaultRedactor {
+		redactors = append([]*Redactor{hcErr.redactor}, redactors...)
+	}
+	redact := func(str string) string {
+		for _, redactor := range redactors {
+			str = redactor.String(str)
+		}
+		return str
+	}
+	redacted := make([]Difference, len(diffs))
+	for idx, diff := range diffs {
+		diff.Expected = redactDifferenceValue(diff.Expected, redact)
+		diff.Actual = redactDifferenceValue(diff.Actual, redact)
+		redacted[idx] = diff
+	}
+	return redacted
+}
+
+// redactDifferenceValue returns value with every string within it,
+// including the keys of maps, passed through redact. Composite values
+// are returned in their JSON form, and values which cannot be encoded
+// as JSON are formatted with fmt.Sprint.
+func redactDifferenceValue(value interface{}, redact func(string) string) interface{} {
+	if value == nil {
+		return nil
+	} else if str, ok := value.(string); ok {
+		return redact(str)
+	}
+	bites, err := json.Marshal(value)
+	if err != nil {
+		return redact(fmt.Sprint(value))
+	}
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(bites))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return redact(fmt.Sprint(value))
+	}
+	return redactJSON(doc, redact)
+}
+
+// redactJSON returns doc, decoded from JSON, with every string within
+// it passed through redact.
+func redactJSON(doc interface{}, redact func(string) string) interface{} {
+	switch value := doc.(type) {
+	case string:
+		return redact(value)
+	case []interface{}:
+		for idx, elem := range value {
+			value[idx] = redactJSON(elem, redact)
+		}
+		return value
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(value))
+		for key, elem := range value {
+			redacted[redact(key)] = redactJSON(elem, redact)
+		}
+		return redacted
+	default:
+		return doc
+	}
+}
+
+// newJSONScenarioReport summarises result for a JSON report.
+func newJSONScenarioReport(result *ScenarioResult) jsonScenarioReport {
+	scenario := jsonScenarioReport{
+		Name:          result.Name,
+		Passed:        result.Passed(),
+		Started:       result.Started,
+		Duration:      result.Duration.Seconds(),
+		BytesSent:     result.Transfer.Sent,
+		BytesReceived: result.Transfer.Received,
+		Error:         errorString(result.Err),
+		Flaky:         len(result.FlakySteps()),
+		Params:        result.Params.redacted(),
+		Steps:         []jsonStepReport{},
+	}
+	for _, step := range result.Steps {
+		stepReport := jsonStepReport{
+			Name:          step.Name,
+			Duration:      step.Duration.Seconds(),
+			BytesSent:     step.Transfer.Sent,
+			BytesReceived: step.Transfer.Received,
+			Error:         errorString(step.Err),
+			Artifacts:     step.Artifacts,
+			Differences:   redactedDifferences(step.Err),
+		}
+		if step.Attempts > 1 {
+			stepReport.Attempts = step.Attempts
+			for _, err := range step.RetriedErrors {
+				stepReport.RetriedErrors = append(stepReport.RetriedErrors, errorString(err))
+			}
+		}
+		scenario.Steps = append(scenario.Steps, stepReport)
+	}
+	return scenario
+}
+
+// WriteJSONReport writes the results as an indented JSON document
+// summarising the number of scenarios passed and failed, and the
+// outcome and duration of every scenario and step. Failed steps whose
+// errors carry Differences (see StepError) include them.
+func WriteJSONReport(w io.Writer, results []*ScenarioResult) error {
+	report := jsonReport{Scenarios: []jsonScenarioReport{}}
+	for _, result := range results {
+		scenario := newJSONScenarioReport(result)
+		if scenario.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Duration += scenario.Duration
+		report.Scenarios = append(report.Scenarios, scenario)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Time       string           `xml:"time,attr"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnitReport writes the results as a JUnit XML testsuite named
+// suite, with one testcase per scenario. The failure of a failed
+// scenario carries the error and the steps that were achieved.
+func WriteJUnitReport(w io.Writer, suite string, results []*ScenarioResult) error {
+	report := junitTestSuite{Name: suite, Tests: len(results)}
+	var total time.Duration
+	for idx, result := range results {
+		if idx == 0 {
+			report.Timestamp = result.Started.UTC().Format("2006-01-02T15:04:05")
+		}
+		total += result.Duration
+		testCase := junitTestCase{Name: result.Name, ClassName: suite, Time: junitTime(result.Duration)}
+		if !result.Passed() {
+			report.Failures++
+			testCase.Failure = &junitFailure{
+				Message: errorString(result.Err),
+				Type:    "StepFailure",
+				Text:    junitFailureText(result),
+			}
+		}
+		report.TestCases = append(report.TestCases, testCase)
+	}
+	report.Time = junitTime(total)
+	return writeJUnit(w, report)
+}
+
+// WriteJUnitStepReport writes the results as JUnit XML testsuites
+// named suite, with one testsuite per scenario and one testcase per
+// step, so that CI dashboards can track individual steps. Steps after
+// the one that failed were not run and so do not appear.
+func WriteJUnitStepReport(w io.Writer, suite string, results []*ScenarioResult) error {
+	report := junitTestSuites{Name: suite}
+	var total time.Duration
+	for _, result := range results {
+		total += result.Duration
+		scenario := junitTestSuite{
+			Name:      result.Name,
+			Tests:     len(result.Steps),
+			Time:      junitTime(result.Duration),
+			Timestamp: result.Started.UTC().Format("2006-01-02T15:04:05"),
+		}
+		for _, step := range result.Steps {
+			testCase := junitTestCase{Name: step.Name, ClassName: suite + "." + result.Name, Time: junitTime(step.Duration)}
+			if step.Err != nil {
+				scenario.Failures++
+				testCase.Failure = &junitFailure{
+					Message: errorString(step.Err),
+					Type:    "StepFailure",
+					Text:    errorString(step.Err),
+				}
+			}
+			scenario.TestCases = append(scenario.TestCases, testCase)
+		}
+		report.Tests += scenario.Tests
+		report.Failures += scenario.Failures
+		report.TestSuites = append(report.TestSuites, scenario)
+	}
+	report.Time = junitTime(total)
+	return writeJUnit(w, report)
+}
+
+func writeJUnit(w io.Writer, report interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitFailureText(result *ScenarioResult) string {
+	text := ""
+	for _, step := range result.Steps {
+		if step.Err == nil {
+			text += fmt.Sprintf("ok   %s (%s)\n", step.Name, step.Duration)
+		} else {
+			text += fmt.Sprintf("FAIL %s (%s)\n", step.Name, step.Duration)
+		}
+	}
+	return text + "Error: " + errorString(result.Err)
+}
+
+// markdownFailures is the maximum number of failures detailed by
+// WriteMarkdownReport.
+const markdownFailures = 10
+
+func markdownCell(s string) string {
+	return strings.Replace(strings.Replace(s, "|", `\|`, -1), "\n", " ", -1)
+}
+
+// markdownCode formats text as a fenced code block, using a fence
+// longer than any run of backticks within text.
+func markdownCode(text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + "\n" + text + "\n" + fence + "\n"
+}
+
+// WriteMarkdownReport writes the results as a Markdown summary headed
+// title, suitable for posting as a pull request comment: a table of
+// the scenarios with their outcome and duration, followed by any
+// flaky steps (see Flaky), and the failed step and error (including
+// any diff) of up to the first ten failed scenarios.
+func WriteMarkdownReport(w io.Writer, title string, results []*ScenarioResult) error {
+	buf := new(strings.Builder)
+	failures := []*ScenarioResult{}
+	var total time.Duration
+	for _, result := range results {
+		total += result.Duration
+		if !result.Passed() {
+			failures = append(failures, result)
+		}
+	}
+	fmt.Fprintf(buf, "## %s\n\n", title)
+	fmt.Fprintf(buf, "**%d passed, %d failed** in %v.\n", len(results)-len(failures), len(failures), total.Round(time.Millisecond))
+	if len(results) > 0 {
+		buf.WriteString("\n| Scenario | Result | Duration | Steps |\n| --- | --- | ---: | ---: |\n")
+		for _, result := range results {
+			outcome := "PASS"
+			if !result.Passed() {
+				outcome = "**FAIL**"
+			}
+			fmt.Fprintf(buf, "| %s | %s | %v | %d |\n", markdownCell(result.Name), outcome, result.Duration.Round(time.Millisecond), len(result.Steps))
+		}
+	}
+	flakyHeader := false
+	for _, result := range results {
+		for _, step := range result.FlakySteps() {
+			if !flakyHeader {
+				buf.WriteString("\n### Flaky steps\n\n")
+				flakyHeader = true
+			}
+			fmt.Fprintf(buf, "- %s: `%s` passed after %d attempts\n", markdownCell(result.Name), strings.Replace(step.Name, "`", "'", -1), step.Attempts)
+		}
+	}
+	if len(failures) > 0 {
+		buf.WriteString("\n### Failures\n")
+		for idx, result := range failures {
+			if idx == markdownFailures {
+				fmt.Fprintf(buf, "\n... and %d more.\n", len(failures)-markdownFailures)
+				break
+			}
+			fmt.Fprintf(buf, "\n#### %s\n\n", result.Name)
+			if step := result.FailedStep(); step != nil {
+				fmt.Fprintf(buf, "Failed step: `%s`\n\n", strings.Replace(step.Name, "`", "'", -1))
+				if len(step.Artifacts) > 0 {
+					names := make([]string, len(step.Artifacts))
+					for idx, artifact := range step.Artifacts {
+						names[idx] = "`" + strings.Replace(artifact.Name, "`", "'", -1) + "`"
+					}
+					fmt.Fprintf(buf, "Artifacts: %s\n\n", strings.Join(names, ", "))
+				}
+			}
+			buf.WriteString(markdownCode(errorString(result.Err)))
+		}
+	}
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
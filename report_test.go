@@ -0,0 +1,63 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteJUnitStepReport(t *testing.T) {
+	ok := NewNamedStep("ok", func() error { return nil })
+	fail := NewNamedStep("fail", func() error { return errors.New("Expected 200; found 500.") })
+	results := []*ScenarioResult{
+		RunScenario("passes", Steps{ok, ok}),
+		RunScenario("fails", Steps{ok, fail, ok}),
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteJUnitStepReport(buf, "suite", results); err != nil {
+		t.Fatal(err)
+	}
+	var report junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Tests != 4 || report.Failures != 1 || len(report.TestSuites) != 2 {
+		t.Fatalf("Unexpected report: %s", buf)
+	}
+	failed := report.TestSuites[1]
+	if failed.Name != "fails" || failed.Failures != 1 || len(failed.TestCases) != 2 {
+		t.Fatalf("Unexpected scenario: %+v", failed)
+	} else if testCase := failed.TestCases[1]; testCase.Name != "fail" || testCase.ClassName != "suite.fails" || testCase.Failure == nil || testCase.Failure.Message != "Expected 200; found 500." {
+		t.Fatalf("Unexpected testcase: %+v", testCase)
+	} else if failed.TestCases[0].Failure != nil {
+		t.Fatalf("Unexpected failure: %+v", failed.TestCases[0])
+	}
+}
+
+func TestWriteMarkdownReport(t *testing.T) {
+	ok := NewNamedStep("ok", func() error { return nil })
+	fail := NewNamedStep("fail", func() error { return errors.New("Diff:\n```\n-a\n+b\n```") })
+	results := []*ScenarioResult{
+		RunScenario("a | b", Steps{ok}),
+		RunScenario("fails", Steps{ok, fail}),
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteMarkdownReport(buf, "Smoke tests", results); err != nil {
+		t.Fatal(err)
+	}
+	report := buf.String()
+	for _, expected := range []string{
+		"## Smoke tests\n\n**1 passed, 1 failed** in ",
+		"| a \\| b | PASS | ",
+		"| fails | **FAIL** | ",
+		"#### fails\n\nFailed step: `fail`\n\n````\nDiff:\n```\n-a\n+b\n```\n````\n",
+	} {
+		if !strings.Contains(report, expected) {
+			t.Fatalf("Expected report to contain:\n%s\nfound:\n%s", expected, report)
+		}
+	}
+}
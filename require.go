@@ -0,0 +1,159 @@
+package argot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// fixture is a registered fixture. It is run at most once, with its
+// own Store.
+type fixture struct {
+	name      string
+	dependsOn []string
+	build     func(store *Store) Steps
+	once      sync.Once
+	store     *Store
+	err       error
+}
+
+var (
+	fixturesLock sync.RWMutex
+	fixtures     = make(map[string]*fixture)
+)
+
+// RegisterFixture registers shared setup, such as creating a tenant,
+// under name, so that scenarios which need it can Require it rather
+// than each repeating it, or relying on the order in which scenarios
+// run. The fixture is run at most once per process, when first
+// required, after the fixtures it depends on: build is called with a
+// new Store holding the values of those fixtures' stores, and the
+// fixture's steps keep their results (such as the tenant's id) in it,
+// to be copied into the store of each scenario which requires it. For
+// example:
+//
+//	argot.RegisterFixture("tenant", nil, func(store *argot.Store) argot.Steps {
+//		hc := argot.NewHttpCall(nil)
+//		return argot.Steps{
+//			hc.NewRequest("POST", baseURL+"/tenants", nil),
+//			hc.ResponseStatusEquals(201),
+//			hc.CaptureJSON(store, "tenantID", "id"),
+//		}
+//	})
+//
+// RegisterFixture panics if name is already registered, so it is
+// typically called from an init function.
+func RegisterFixture(name string, dependsOn []string, build func(store *Store) Steps) {
+	fixturesLock.Lock()
+	defer fixturesLock.Unlock()
+	if _, found := fixtures[name]; found {
+		panic(fmt.Sprintf("argot: fixture %s is already registered", name))
+	}
+	fixtures[name] = &fixture{name: name, dependsOn: append([]string(nil), dependsOn...), build: build}
+}
+
+// unregisterFixture removes the fixture registered under name, so
+// that tests can register theirs afresh each run.
+func unregisterFixture(name string) {
+	fixturesLock.Lock()
+	defer fixturesLock.Unlock()
+	delete(fixtures, name)
+}
+
+// fixtureOrder returns the fixtures names, and those they depend on
+// transitively, in an order in which each follows its dependencies.
+func fixtureOrder(names []string) ([]*fixture, error) {
+	fixturesLock.RLock()
+	defer fixturesLock.RUnlock()
+	order := []*fixture{}
+	visited := make(map[string]bool)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		for idx, ancestor := range path {
+			if ancestor == name {
+				return fmt.Errorf("Fixture dependency cycle: %s.", strings.Join(append(path[idx:], name), " -> "))
+			}
+		}
+		if visited[name] {
+			return nil
+		}
+		f, found := fixtures[name]
+		if !found {
+			if len(path) == 0 {
+				return fmt.Errorf("Fixture %s is not registered.", name)
+			}
+			return fmt.Errorf("Fixture %s is not registered (a dependency of %s).", name, path[len(path)-1])
+		}
+		for _, dependency := range f.dependsOn {
+			if err := visit(dependency, append(path[:len(path):len(path)], name)); err != nil {
+				return err
+			}
+		}
+		visited[name] = true
+		order = append(order, f)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// run runs the fixture, once, its dependencies having been run.
+func (f *fixture) run() error {
+	f.once.Do(func() {
+		f.store = NewStore()
+		fixturesLock.RLock()
+		dependencies := make([]*fixture, len(f.dependsOn))
+		for idx, name := range f.dependsOn {
+			dependencies[idx] = fixtures[name]
+		}
+		fixturesLock.RUnlock()
+		for _, dependency := range dependencies {
+			if dependency.err != nil {
+				f.err = fmt.Errorf("Fixture %s: Dependency %s failed.", f.name, dependency.name)
+				return
+			}
+			dependency.store.copyTo(f.store)
+		}
+		if err := f.build(f.store).Go(); err != nil {
+			f.err = fmt.Errorf("Fixture %s: %v", f.name, err)
+		}
+	})
+	return f.err
+}
+
+// copyTo sets every key of s in dst.
+func (s *Store) copyTo(dst *Store) {
+	for _, key := range s.Keys() {
+		if value, found := s.Get(key); found {
+			dst.Set(key, value)
+		}
+	}
+}
+
+// Require is a Step that when executed runs the named fixtures (see
+// RegisterFixture), and those they depend on, unless they have already
+// run, in an order in which each follows its dependencies, and then
+// copies the values of their stores into store. It errors if any of
+// the fixtures is not registered, they depend on each other in a
+// cycle, or any of them failed, now or when first run.
+func Require(store *Store, names ...string) Step {
+	return NewNamedStep(fmt.Sprintf("Require(%s)", strings.Join(names, ", ")), func() error {
+		order, err := fixtureOrder(names)
+		if err != nil {
+			return err
+		}
+		for _, f := range order {
+			if err := f.run(); err != nil {
+				return err
+			}
+		}
+		for _, f := range order {
+			f.store.copyTo(store)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,82 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRequireFixtures(t *testing.T) {
+	var tenants, users int32
+	t.Cleanup(func() {
+		for _, name := range []string{"test-tenant", "test-user", "test-broken", "test-cycle-a", "test-cycle-b"} {
+			unregisterFixture(name)
+		}
+	})
+	RegisterFixture("test-tenant", nil, func(store *Store) Steps {
+		return Steps{NewNamedStep("CreateTenant", func() error {
+			store.Set("tenantID", fmt.Sprint(100+atomic.AddInt32(&tenants, 1)))
+			return nil
+		})}
+	})
+	RegisterFixture("test-user", []string{"test-tenant"}, func(store *Store) Steps {
+		return Steps{NewNamedStep("CreateUser", func() error {
+			atomic.AddInt32(&users, 1)
+			store.Set("userID", store.GetString("tenantID")+"-alice")
+			return nil
+		})}
+	})
+	RegisterFixture("test-broken", []string{"test-tenant"}, func(store *Store) Steps {
+		return Steps{NewNamedStep("Break", func() error { return errors.New("Broken.") })}
+	})
+	RegisterFixture("test-cycle-a", []string{"test-cycle-b"}, func(store *Store) Steps { return nil })
+	RegisterFixture("test-cycle-b", []string{"test-cycle-a"}, func(store *Store) Steps { return nil })
+
+	for idx := 0; idx < 3; idx++ {
+		store := NewStore()
+		Steps{
+			Require(store, "test-user"),
+			store.ExpectEquals("tenantID", "101"),
+			store.ExpectEquals("userID", "101-alice"),
+		}.Test(t)
+	}
+	if tenants != 1 || users != 1 {
+		t.Fatalf("Expected each fixture to run once; found %d and %d runs.", tenants, users)
+	}
+
+	for names, expected := range map[string]string{
+		"test-broken":       "Fixture test-broken: Broken.",
+		"test-user,missing": "Fixture missing is not registered.",
+		"test-cycle-a":      "Fixture dependency cycle: test-cycle-a -> test-cycle-b -> test-cycle-a.",
+	} {
+		for attempt := 0; attempt < 2; attempt++ {
+			if err := Require(NewStore(), strings.Split(names, ",")...).Go(); err == nil || err.Error() != expected {
+				t.Fatalf("%s: Expected %q; found %v.", names, expected, err)
+			}
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+	scenario, err := ParseScenario([]byte(`
+name: user profile
+requires: [test-user]
+steps:
+  - request: {method: GET, url: "/users/${userID}"}
+    expect: {status: 200, body: "/users/101-alice"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore()
+	store.Set("baseURL", server.URL)
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	scenario.Build(hc, store).Test(t)
+}
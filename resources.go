@@ -0,0 +1,154 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type trackedResource struct {
+	name     string
+	teardown Step
+}
+
+// ResourceTracker manages the lifecycle of the test data a scenario
+// creates in the system under test, so that none is left behind in
+// shared environments. Each step which creates a resource is followed
+// by one which registers the step that deletes it (see Track and
+// TrackDelete), and the teardowns are run, most recent first, by
+// Teardown, or, whether or not the scenario succeeds, by WithTeardown.
+// A failed teardown does not prevent the others from running, and each
+// failure is reported against the resource concerned. A
+// ResourceTracker is safe for concurrent use.
+type ResourceTracker struct {
+	lock      sync.Mutex
+	resources []trackedResource
+}
+
+// NewResourceTracker creates a new ResourceTracker with no resources.
+func NewResourceTracker() *ResourceTracker {
+	return new(ResourceTracker)
+}
+
+// Track is a Step that when executed registers teardown as the step
+// which deletes the resource called name. It should directly follow
+// the step which creates the resource, so that a resource is only
+// torn down if it was created.
+func (rt *ResourceTracker) Track(name string, teardown Step) Step {
+	return NewNamedStep(fmt.Sprintf("Track(%s)", name), func() error {
+		rt.lock.Lock()
+		defer rt.lock.Unlock()
+		rt.resources = append(rt.resources, trackedResource{name: name, teardown: teardown})
+		return nil
+	})
+}
+
+// TrackDelete is a Step that when executed registers, as the teardown
+// of the resource hc has just created, a DELETE of urlStr or, if
+// urlStr is empty, of the Location of hc.Response. The DELETE is sent
+// by a clone of hc (see Clone), with the headers, other than
+// Content-*, of hc.Request, and succeeds if the response is a 2xx, or
+// a 404 or 410 as the resource is already gone.
+func (rt *ResourceTracker) TrackDelete(hc *HttpCall, urlStr string) Step {
+	return hc.step("TrackDelete", func() error {
+		if err := hc.EnsureResponse(); err != nil {
+			return err
+		}
+		target := urlStr
+		if target == "" {
+			if location, err := hc.Response.Location(); err != nil {
+				return fmt.Errorf("TrackDelete: No resource URL: %v", err)
+			} else {
+				target = location.String()
+			}
+		}
+		teardown := new(HttpCall)
+		if err := hc.cloneInto(teardown); err != nil {
+			return err
+		}
+		header := http.Header{}
+		for key, values := range hc.Request.Header {
+			if !strings.HasPrefix(key, "Content-") {
+				header[key] = values
+			}
+		}
+		name := fmt.Sprintf("DELETE %s", hc.redactor().String(target))
+		return rt.Track(name, NewNamedStep(name, func() error {
+			if err := teardown.NewRequest(http.MethodDelete, target, nil).Go(); err != nil {
+				return err
+			}
+			for key, values := range header {
+				teardown.Request.Header[key] = values
+			}
+			if err := teardown.EnsureResponse(); err != nil {
+				return err
+			}
+			defer teardown.Reset()
+			if status := teardown.Response.StatusCode; status/100 != 2 && status != http.StatusNotFound && status != http.StatusGone {
+				return fmt.Errorf("Status: Expected 2xx, 404 or 410; found %d.", status)
+			}
+			return nil
+		})).Go()
+	})
+}
+
+// Pending returns the names of the resources registered and not yet
+// torn down, in the order they were registered.
+func (rt *ResourceTracker) Pending() []string {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	names := make([]string, len(rt.resources))
+	for idx, resource := range rt.resources {
+		names[idx] = resource.name
+	}
+	return names
+}
+
+// Teardown is a Step that when executed runs the teardown of every
+// resource registered, in the reverse order of registration, and
+// forgets them. Every teardown is run, even if some fail, in which
+// case it errors with a *MultiError giving the failure of each
+// resource concerned.
+func (rt *ResourceTracker) Teardown() Step {
+	return NewNamedStep("Teardown", func() error {
+		rt.lock.Lock()
+		resources := rt.resources
+		rt.resources = nil
+		rt.lock.Unlock()
+
+		var failures []StepFailure
+		for idx := len(resources) - 1; idx >= 0; idx-- {
+			resource := resources[idx]
+			err := resource.teardown.Go()
+			if hcErr, ok := err.(*HttpCallError); ok {
+				err = hcErr.Err
+			}
+			if err != nil {
+				failures = append(failures, StepFailure{Index: idx, Step: NewNamedStep(resource.name, resource.teardown.Go), Err: err})
+			}
+		}
+		if len(failures) != 0 {
+			return &MultiError{
+				Message:  fmt.Sprintf("%d of %d resources failed to tear down", len(failures), len(resources)),
+				Failures: failures,
+			}
+		}
+		return nil
+	})
+}
+
+// WithTeardown is a Step that when executed runs steps and then,
+// whether or not they succeed, Teardown. It errors if any of steps,
+// or the teardown of any resource, fails.
+func (rt *ResourceTracker) WithTeardown(steps Steps) Step {
+	return NewNamedStep("WithTeardown", func() error {
+		err := steps.Go()
+		if teardownErr := rt.Teardown().Go(); err == nil {
+			err = teardownErr
+		} else if teardownErr != nil {
+			err = fmt.Errorf("%v\n%v", err, teardownErr)
+		}
+		return err
+	})
+}
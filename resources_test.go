@@ -0,0 +1,138 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestResourceTracker(t *testing.T) {
+	var lock sync.Mutex
+	teas := map[string]bool{}
+	deleted := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+		} else if r.Method == http.MethodPost {
+			id := "/teas/" + r.URL.Query().Get("name")
+			teas[id] = true
+			w.Header().Set("Location", id)
+			w.WriteHeader(http.StatusCreated)
+		} else if r.Method == http.MethodDelete && r.URL.Path == "/teas/stuck" {
+			w.WriteHeader(http.StatusInternalServerError)
+		} else if r.Method == http.MethodDelete {
+			deleted = append(deleted, r.URL.Path)
+			if !teas[r.URL.Path] {
+				w.WriteHeader(http.StatusNotFound)
+			} else {
+				delete(teas, r.URL.Path)
+				w.WriteHeader(http.StatusNoContent)
+			}
+		} else {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	create := func(hc *HttpCall, name string) Steps {
+		return Steps{
+			hc.NewRequest(http.MethodPost, server.URL+"/teas?name="+name, nil),
+			hc.RequestHeader("Authorization", "Bearer secret"),
+			hc.ResponseStatusEquals(http.StatusCreated),
+		}
+	}
+
+	hc := NewHttpCall(nil)
+	rt := NewResourceTracker()
+	order := []string{}
+	steps := append(create(hc, "green"), rt.TrackDelete(hc, ""))
+	steps = append(steps, create(hc, "black")...)
+	steps = append(steps,
+		rt.TrackDelete(hc, ""),
+		rt.Track("note", NewNamedStep("forget", func() error {
+			order = append(order, "note")
+			return nil
+		})),
+		NewNamedStep("Fail", func() error { return errors.New("Boom") }),
+		create(hc, "oolong")[0],
+	)
+	err := rt.WithTeardown(steps).Go()
+	if err == nil || !strings.HasPrefix(err.Error(), "Boom") {
+		t.Fatalf("Expected the scenario's error; found %v", err)
+	}
+	if len(order) != 1 || strings.Join(deleted, ",") != "/teas/black,/teas/green" {
+		t.Fatalf("Expected teardown in reverse order; found %v %v", order, deleted)
+	}
+	if len(teas) != 0 || len(rt.Pending()) != 0 {
+		t.Fatalf("Expected every resource to be torn down; found %v %v", teas, rt.Pending())
+	}
+
+	// A failed teardown does not stop the others, and resources which
+	// are already gone are tolerated.
+	steps = append(create(hc, "white"), rt.TrackDelete(hc, ""), rt.TrackDelete(hc, server.URL+"/teas/stuck"), rt.TrackDelete(hc, server.URL+"/teas/gone"))
+	if err := steps.Go(); err != nil {
+		t.Fatal(err)
+	} else if pending := rt.Pending(); len(pending) != 3 || !strings.HasSuffix(pending[1], "/teas/stuck") {
+		t.Fatalf("Unexpected pending resources: %v", pending)
+	}
+	err = rt.Teardown().Go()
+	multiErr, ok := err.(*MultiError)
+	if !ok || len(multiErr.Failures) != 1 || multiErr.Failures[0].Index != 1 ||
+		!strings.Contains(multiErr.Error(), "1 of 3 resources failed to tear down") ||
+		!strings.Contains(multiErr.Error(), "/teas/stuck: Status: Expected 2xx, 404 or 410; found 500.") {
+		t.Fatalf("Expected the stuck resource to be reported; found %v", err)
+	}
+	if len(teas) != 0 {
+		t.Fatalf("Expected every resource to be torn down; found %v", teas)
+	}
+
+	// A created resource must have a Location if no URL is given.
+	hc.NewRequest(http.MethodGet, server.URL+"/teas", nil).Go()
+	if err := rt.TrackDelete(hc, "").Go(); err == nil || !strings.Contains(err.Error(), "TrackDelete: No resource URL") {
+		t.Fatalf("Expected a missing Location to be reported; found %v", err)
+	}
+}
+
+func TestResourceTrackerRerun(t *testing.T) {
+	var lock sync.Mutex
+	created, deleted := 0, []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if r.Method == http.MethodPost {
+			created++
+			w.Header().Set("Location", fmt.Sprintf("/teas/%d", created))
+			w.WriteHeader(http.StatusCreated)
+		} else {
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	// The same steps, run twice, must track each run's own resource.
+	hc := NewHttpCall(nil)
+	rt := NewResourceTracker()
+	steps := Steps{
+		hc.NewRequest(http.MethodPost, server.URL+"/teas", nil),
+		hc.ResponseStatusEquals(http.StatusCreated),
+		rt.TrackDelete(hc, ""),
+	}
+	for idx := 0; idx < 2; idx++ {
+		if err := steps.Go(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rt.Teardown().Go(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(deleted, ",") != "/teas/2,/teas/1" {
+		t.Fatalf("Expected both resources to be torn down; found %v", deleted)
+	}
+}
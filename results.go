@@ -0,0 +1,136 @@
+package argot
+
+import (
+	"fmt"
+	"time"
+)
+
+// StepResult records the outcome of running a single step.
+type StepResult struct {
+	Name     string
+	Duration time.Duration
+	// Transfer counts the bytes transferred by HttpCalls whilst the
+	// step ran (see TotalTransfer).
+	Transfer Transfer
+	// Err is nil iff the step succeeded.
+	Err error
+	// Attempts is the number of times the step was attempted, which is
+	// more than one only for a FlakyStep which was retried.
+	Attempts int
+	// RetriedErrors holds the errors of the failed attempts of a
+	// FlakyStep.
+	RetriedErrors []error
+	// Artifacts attached by the step or its error (see
+	// ArtifactSource).
+	Artifacts []Artifact
+}
+
+// Flaky returns true iff the step passed, but only after failing at
+// least once.
+func (sr *StepResult) Flaky() bool {
+	return sr.Err == nil && len(sr.RetriedErrors) > 0
+}
+
+// ScenarioResult records the outcome of running a scenario: a named
+// sequence of steps. As with Steps.Test, Steps holds the steps that
+// succeeded followed by the step that failed, if any.
+type ScenarioResult struct {
+	Name     string
+	Started  time.Time
+	Duration time.Duration
+	Steps    []StepResult
+	// Transfer counts the bytes transferred by HttpCalls whilst the
+	// scenario ran (see TotalTransfer).
+	Transfer Transfer
+	// Err is nil iff every step succeeded.
+	Err error
+	// Params are the parameters of the case, if the scenario is a case
+	// of a data-driven table (see RunTable).
+	Params Case
+}
+
+// Passed returns true iff the scenario succeeded.
+func (sr *ScenarioResult) Passed() bool {
+	return sr.Err == nil
+}
+
+// FailedStep returns the result of the step that failed, or nil if
+// the scenario passed.
+func (sr *ScenarioResult) FailedStep() *StepResult {
+	if sr.Err == nil || len(sr.Steps) == 0 {
+		return nil
+	} else {
+		return &sr.Steps[len(sr.Steps)-1]
+	}
+}
+
+// FlakySteps returns the results of the steps which were flaky (see
+// StepResult.Flaky).
+func (sr *ScenarioResult) FlakySteps() []StepResult {
+	flaky := []StepResult{}
+	for _, step := range sr.Steps {
+		if step.Flaky() {
+			flaky = append(flaky, step)
+		}
+	}
+	return flaky
+}
+
+// RunScenario runs steps in order, stopping at the first error, and
+// records the outcome and duration of each. Unlike Steps.Test, the
+// results are structured so that reporters (see WriteJSONReport and
+// WriteJUnitReport) can present them. WarmupSteps are run, but are
+// excluded from the results. Should a step fail, diagnostics of the
+// environment (see AddDiagnosticCollector) are attached to its result.
+func RunScenario(name string, steps Steps) *ScenarioResult {
+	return runScenario(name, nil, steps)
+}
+
+func runScenario(name string, params Case, steps Steps) *ScenarioResult {
+	result := &ScenarioResult{Name: name, Started: time.Now(), Params: params}
+	emit(Event{Type: ScenarioStarted, Scenario: name, Params: params})
+	scenarioTransfer := TotalTransfer()
+	var warmup time.Duration
+	for _, step := range steps {
+		transfer := TotalTransfer()
+		if ws, ok := step.(*WarmupStep); ok {
+			start := time.Now()
+			ws.Go()
+			warmup += time.Since(start)
+			warmupTransfer := TotalTransfer().since(transfer)
+			scenarioTransfer.Sent += warmupTransfer.Sent
+			scenarioTransfer.Received += warmupTransfer.Received
+			continue
+		}
+		duration, err := runStep(step)
+		stepResult := StepResult{
+			Name:      DefaultRedactor.String(fmt.Sprint(step)),
+			Duration:  duration,
+			Transfer:  TotalTransfer().since(transfer),
+			Err:       err,
+			Attempts:  1,
+			Artifacts: stepArtifacts(step, err),
+		}
+		if flaky, ok := step.(*FlakyStep); ok {
+			stepResult.Attempts = flaky.Attempts
+			if err == nil {
+				stepResult.RetriedErrors = flaky.Errors
+			} else {
+				stepResult.RetriedErrors = flaky.Errors[:len(flaky.Errors)-1]
+			}
+		}
+		result.Steps = append(result.Steps, stepResult)
+		if err != nil {
+			result.Err = err
+			break
+		}
+	}
+	result.Duration = time.Since(result.Started) - warmup
+	result.Transfer = TotalTransfer().since(scenarioTransfer)
+	if result.Err != nil {
+		failed := &result.Steps[len(result.Steps)-1]
+		failed.Artifacts = append(failed.Artifacts, collectDiagnostics()...)
+	}
+	emit(Event{Type: ScenarioFinished, Scenario: name, Params: params, Duration: result.Duration, Err: result.Err})
+	return result
+}
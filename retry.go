@@ -0,0 +1,183 @@
+package argot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy configures RetryCall. The zero value is usable.
+type RetryPolicy struct {
+	// The maximum number of times the request is sent. If less than
+	// one, three is used.
+	Attempts int
+	// The delay before the first retry, which doubles for each
+	// subsequent retry up to MaxBackoff. Each delay is jittered: a
+	// random duration between half and all of it is used. If zero,
+	// 100ms and 2s are used.
+	Backoff, MaxBackoff time.Duration
+	// The response statuses which are retried. Connection errors are
+	// always retried. If empty, 502 and 503 are retried.
+	Statuses []int
+	// The header which carries the idempotency key of requests with
+	// non-idempotent methods. If empty, Idempotency-Key is used.
+	IdempotencyHeader string
+	// If non-nil, run after a non-idempotent request has succeeded,
+	// to check, for example, that only one resource was created.
+	Check Step
+}
+
+func (rp RetryPolicy) attempts() int {
+	if rp.Attempts < 1 {
+		return 3
+	}
+	return rp.Attempts
+}
+
+func (rp RetryPolicy) retryable(status int) bool {
+	statuses := rp.Statuses
+	if len(statuses) == 0 {
+		statuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable}
+	}
+	for _, retryable := range statuses {
+		if status == retryable {
+			return true
+		}
+	}
+	return false
+}
+
+func (rp RetryPolicy) idempotencyHeader() string {
+	if rp.IdempotencyHeader == "" {
+		return "Idempotency-Key"
+	}
+	return rp.IdempotencyHeader
+}
+
+// backoff returns the jittered delay before the given retry, counting
+// from one.
+func (rp RetryPolicy) backoff(retry int) time.Duration {
+	backoff, max := rp.Backoff, rp.MaxBackoff
+	if backoff <= 0 {
+		backoff = 100 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 2 * time.Second
+	}
+	for idx := 1; idx < retry && backoff < max; idx++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		backoff = max
+	}
+	return backoff/2 + time.Duration(randInt63n(int64(backoff/2)+1))
+}
+
+// idempotentMethod returns true iff requests with method may safely be
+// repeated.
+func idempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// Attempts returns the number of times the current request was sent by
+// RetryCall.
+func (hc *HttpCall) Attempts() int {
+	return hc.attempts
+}
+
+// RetryCall is a Step that when executed performs the HTTP Request,
+// retrying it with jittered exponential backoff (see RetryPolicy)
+// whilst it fails with a connection error or a retryable status. The
+// final response becomes hc.Response; if every attempt fails, the
+// step errors. Like Call, it must be used after the request has been
+// built, and before any step which needs the response.
+//
+// Retrying a request with a non-idempotent method, such as POST, is
+// only safe if the server honours an idempotency key, so for those
+// the step sets the policy's IdempotencyHeader (to a random UUID,
+// unless already set) and, once the request has succeeded, asserts
+// that it was honoured: it sends the request once more with the same
+// key, and errors unless the response has the same status and body.
+// It then runs the policy's Check step, if any.
+func (hc *HttpCall) RetryCall(policy RetryPolicy) Step {
+	return hc.step(fmt.Sprintf("RetryCall(%d attempts)", policy.attempts()), func() error {
+		if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
+			return err
+		}
+		idempotent := idempotentMethod(hc.Request.Method)
+		if header := policy.idempotencyHeader(); !idempotent && hc.Request.Header.Get(header) == "" {
+			if key, err := newUUID(); err != nil {
+				return err
+			} else {
+				hc.Request.Header.Set(header, key)
+			}
+		}
+		if _, err := RequestBodyBytes(hc.Request); err != nil {
+			return fmt.Errorf("Retry: Request body cannot be replayed: %v", err)
+		}
+		var err error
+		for hc.attempts = 1; ; hc.attempts++ {
+			if hc.attempts > 1 {
+				time.Sleep(policy.backoff(hc.attempts - 1))
+				if hc.Request.GetBody != nil {
+					if hc.Request.Body, err = hc.Request.GetBody(); err != nil {
+						return err
+					}
+				}
+			}
+			if err = hc.EnsureResponse(); err == nil && !policy.retryable(hc.Response.StatusCode) {
+				break
+			} else if err == nil {
+				err = fmt.Errorf("Retry: status %d", hc.Response.StatusCode)
+			}
+			if hc.attempts == policy.attempts() {
+				return fmt.Errorf("Retry: Expected success within %d attempts; last error: %v", hc.attempts, err)
+			}
+			if hc.Response != nil {
+				io.Copy(ioutil.Discard, hc.Response.Body)
+				hc.Response.Body.Close()
+				hc.Response = nil
+			}
+		}
+		if idempotent {
+			return nil
+		} else if err := hc.expectIdempotentReplay(policy.idempotencyHeader()); err != nil {
+			return err
+		} else if policy.Check != nil {
+			return policy.Check.Go()
+		} else {
+			return nil
+		}
+	})
+}
+
+// expectIdempotentReplay sends a copy of hc.Request, and errors unless
+// the response has the same status and body as hc.Response.
+func (hc *HttpCall) expectIdempotentReplay(header string) error {
+	if err := hc.ReceiveBody(); err != nil {
+		return err
+	}
+	response, err := hc.replayRequest()
+	if err != nil {
+		return fmt.Errorf("Idempotency: replay: %v", err)
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("Idempotency: replay: %v", err)
+	} else if response.StatusCode != hc.Response.StatusCode {
+		return fmt.Errorf("Idempotency: Expected replaying the request with the same %s to give status %d; found %d.", header, hc.Response.StatusCode, response.StatusCode)
+	} else if !bytes.Equal(body, hc.ResponseBody) {
+		return fmt.Errorf("Idempotency: Expected replaying the request with the same %s to give the same body; found %s", header, hc.redactor().String(string(body)))
+	} else {
+		return nil
+	}
+}
@@ -0,0 +1,113 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryCall(t *testing.T) {
+	var lock sync.Mutex
+	failures := 0
+	honour := true
+	created := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method == "GET" {
+			fmt.Fprintf(w, "%d", len(created))
+			return
+		}
+		key := r.Header.Get("Idempotency-Key")
+		if !honour {
+			key += fmt.Sprint(len(created))
+		}
+		if _, found := created[key]; !found {
+			created[key] = fmt.Sprintf(`{"id": %d}`, len(created)+1)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(created[key]))
+	}))
+	defer server.Close()
+
+	policy := RetryPolicy{Backoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond}
+	count := NewHttpCall(nil)
+	defer count.Reset()
+	policy.Check = Steps{
+		count.NewRequest("GET", server.URL, nil),
+		count.ResponseBodyEquals("1"),
+	}
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	failures = 2
+	Steps{
+		hc.NewRequest("POST", server.URL, strings.NewReader(`{"name": "widget"}`)),
+		hc.RetryCall(policy),
+		hc.ResponseStatusEquals(201),
+		hc.ResponseBodyEquals(`{"id": 1}`),
+	}.Test(t)
+	if hc.Attempts() != 3 || hc.Request.Header.Get("Idempotency-Key") == "" {
+		t.Fatalf("Expected 3 attempts with an idempotency key; found %d.", hc.Attempts())
+	}
+
+	// Idempotent methods are simply retried.
+	failures = 1
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.RetryCall(RetryPolicy{Backoff: time.Millisecond}),
+		hc.ResponseBodyEquals("1"),
+	}.Test(t)
+
+	failures = 5
+	err := Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.RetryCall(RetryPolicy{Attempts: 2, Backoff: time.Millisecond}),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "within 2 attempts") {
+		t.Fatalf("Expected the retries to be exhausted; found %v.", err)
+	}
+	failures = 0
+
+	lock.Lock()
+	honour = false
+	lock.Unlock()
+	err = Steps{
+		hc.NewRequest("POST", server.URL, strings.NewReader(`{"name": "gadget"}`)),
+		hc.RetryCall(policy),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "same body") {
+		t.Fatalf("Expected the idempotency key not to be honoured; found %v.", err)
+	}
+
+	// Connection errors are retried.
+	flaky := NewHttpCall(nil)
+	defer flaky.Reset()
+	dropped := false
+	flaky.Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if !dropped {
+				dropped = true
+				return nil, errors.New("connection reset")
+			}
+			return next.RoundTrip(req)
+		})
+	})
+	Steps{
+		flaky.NewRequest("GET", server.URL, nil),
+		flaky.RetryCall(RetryPolicy{Backoff: time.Millisecond}),
+		flaky.ResponseStatusEquals(200),
+	}.Test(t)
+	if flaky.Attempts() != 2 {
+		t.Fatalf("Expected 2 attempts; found %d.", flaky.Attempts())
+	}
+}
@@ -0,0 +1,428 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a declarative scenario: a sequence of HTTP requests,
+// each with expectations and captures, typically loaded from a YAML
+// or JSON file with LoadScenario. A scenario is turned into Steps
+// with Scenario.Build, so it runs on the same engine as scenarios
+// written in Go. For example:
+//
+//	name: create and fetch a user
+//	vars:
+//	  userName: alice
+//	steps:
+//	  - name: create
+//	    request:
+//	      method: POST
+//	      url: /users
+//	      json: {name: "${userName}"}
+//	    expect:
+//	      status: 201
+//	      json: {name: "${userName}"}
+//	    capture:
+//	      userID: json:id
+//	  - request:
+//	      method: GET
+//	      url: /users/${userID}
+//	    expect:
+//	      status: 200
+//
+// Strings in requests and expectations may refer to values in the
+// Store with ${key} (see Store.Interpolate): vars are added to the
+// store when the scenario starts, and captures when their step runs.
+// Each run of the scenario also sets ${namespace} to a new, unique,
+// prefix (see Namespace) with which to name the data it creates, so
+// that concurrent runs do not collide, unless a var overrides it.
+// URLs beginning with "/" are relative to the store's baseURL value,
+// if any.
+type Scenario struct {
+	Name string `yaml:"name,omitempty"`
+	// Priority is the priority of the scenario, such as P0 for a smoke
+	// scenario (see Scenarios.ByPriority and Scenarios.UpTo).
+	Priority Priority `yaml:"priority,omitempty"`
+	// Requires names the fixtures (see RegisterFixture) which the
+	// scenario needs, whose values are added to the store before the
+	// vars.
+	Requires []string          `yaml:"requires,omitempty"`
+	Vars     map[string]string `yaml:"vars,omitempty"`
+	Steps    []*ScenarioStep   `yaml:"steps,omitempty"`
+}
+
+// ScenarioStep is a single request of a Scenario.
+type ScenarioStep struct {
+	Name    string           `yaml:"name,omitempty"`
+	Request *ScenarioRequest `yaml:"request,omitempty"`
+	Expect  *ScenarioExpect  `yaml:"expect,omitempty"`
+	// Capture maps store keys to the part of the response to
+	// capture: "status", "body", "header:<name>" or "json:<path>"
+	// (see JSONPath).
+	Capture map[string]string `yaml:"capture,omitempty"`
+}
+
+// ScenarioRequest describes the request of a ScenarioStep. At most
+// one of Body and JSON may be set; if JSON is set the request has a
+// JSON encoded body and a Content-Type of application/json.
+type ScenarioRequest struct {
+	Method  string            `yaml:"method,omitempty"`
+	URL     string            `yaml:"url,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Body    string            `yaml:"body,omitempty"`
+	JSON    interface{}       `yaml:"json,omitempty"`
+}
+
+// ScenarioExpect describes the expectations of a ScenarioStep. Unset
+// fields are not checked.
+type ScenarioExpect struct {
+	Status int `yaml:"status,omitempty"`
+	// Headers which must equal the given values.
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Headers which must contain the given values.
+	HeadersContain map[string]string `yaml:"headersContain,omitempty"`
+	// HeadersAbsent lists headers which must not be present.
+	HeadersAbsent []string `yaml:"headersAbsent,omitempty"`
+	// Body which the response body must equal exactly.
+	Body *string `yaml:"body,omitempty"`
+	// BodyContains lists strings the response body must contain.
+	BodyContains []string `yaml:"bodyContains,omitempty"`
+	// BodyMatches is a regular expression the response body must
+	// match.
+	BodyMatches string `yaml:"bodyMatches,omitempty"`
+	// JSON maps JSON paths (see JSONPath) to the values expected
+	// there.
+	JSON map[string]interface{} `yaml:"json,omitempty"`
+	// JSONSchema is a JSON schema the response body must satisfy.
+	JSONSchema string `yaml:"jsonSchema,omitempty"`
+}
+
+// LoadScenario loads a Scenario from the YAML or JSON file at path.
+func LoadScenario(path string) (*Scenario, error) {
+	if data, err := ioutil.ReadFile(path); err != nil {
+		return nil, err
+	} else if scenario, err := ParseScenario(data); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	} else {
+		if scenario.Name == "" {
+			scenario.Name = path
+		}
+		return scenario, nil
+	}
+}
+
+// Save writes the scenario to path as YAML, for LoadScenario.
+func (sc *Scenario) Save(path string) error {
+	buf := new(bytes.Buffer)
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(sc); err != nil {
+		return err
+	} else if err := encoder.Close(); err != nil {
+		return err
+	} else if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("Scenario %s: %v", path, err)
+	} else {
+		return nil
+	}
+}
+
+// ParseScenario parses a Scenario from YAML or JSON (which is a
+// subset of YAML). Unknown fields are errors, so that typos are not
+// silently ignored.
+func ParseScenario(data []byte) (*Scenario, error) {
+	scenario := new(Scenario)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(scenario); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return scenario, scenario.validate()
+}
+
+func (sc *Scenario) validate() error {
+	for idx, step := range sc.Steps {
+		if step.Request == nil {
+			return fmt.Errorf("Step %d: no request.", idx+1)
+		} else if step.Request.Method == "" || step.Request.URL == "" {
+			return fmt.Errorf("Step %d: request requires a method and url.", idx+1)
+		} else if step.Request.Body != "" && step.Request.JSON != nil {
+			return fmt.Errorf("Step %d: request cannot have both body and json.", idx+1)
+		} else if step.Expect != nil && step.Expect.BodyMatches != "" {
+			if _, err := regexp.Compile(step.Expect.BodyMatches); err != nil {
+				return fmt.Errorf("Step %d: bodyMatches: %v", idx+1, err)
+			}
+		}
+		for key, source := range step.Capture {
+			if source != "status" && source != "body" && !strings.HasPrefix(source, "header:") && !strings.HasPrefix(source, "json:") {
+				return fmt.Errorf("Step %d: capture '%s': unknown source '%s'.", idx+1, key, source)
+			}
+		}
+	}
+	return nil
+}
+
+// interpolateValue interpolates every string within value, which is
+// a decoded YAML or JSON value.
+func interpolateValue(store *Store, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return store.Interpolate(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for idx, elem := range v {
+			var err error
+			if result[idx], err = interpolateValue(store, elem); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			var err error
+			if result[key], err = interpolateValue(store, elem); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+// interpolated creates a NamedStep which, when executed, interpolates
+// its arguments and then builds and runs the step.
+func interpolated(name string, store *Store, args []string, build func(args []string) Step) Step {
+	return NewNamedStep(name, func() error {
+		interpolatedArgs := make([]string, len(args))
+		for idx, arg := range args {
+			var err error
+			if interpolatedArgs[idx], err = store.Interpolate(arg); err != nil {
+				return err
+			}
+		}
+		return build(interpolatedArgs).Go()
+	})
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Build converts the scenario into Steps which make the requests with
+// hc, and keep vars and captures in store.
+func (sc *Scenario) Build(hc *HttpCall, store *Store) Steps {
+	steps := Steps{}
+	if len(sc.Requires) > 0 {
+		steps = append(steps, Require(store, sc.Requires...))
+	}
+	steps = append(steps, NewNamedStep(fmt.Sprintf("Scenario(%s)", sc.Name), func() error {
+		store.Set(NamespaceKey, NewNamespace("").Prefix())
+		for _, key := range sortedKeys(sc.Vars) {
+			if value, err := store.Interpolate(sc.Vars[key]); err != nil {
+				return err
+			} else {
+				store.Set(key, value)
+			}
+		}
+		return nil
+	}))
+	for _, step := range sc.Steps {
+		steps = append(steps, step.steps(hc, store)...)
+	}
+	return steps
+}
+
+// Scenarios is a suite of independent scenarios.
+type Scenarios []*Scenario
+
+// TestParallel runs each scenario as a parallel subtest of t (see
+// testing.T.Run and testing.T.Parallel), named after the scenario.
+// Each scenario is built with its own HttpCall and Store, created by
+// newHttpCall and newStore, so that scenarios share no state; the
+// HttpCall is Reset when its scenario finishes. If newHttpCall is nil,
+// NewHttpCall(nil) is used, and if newStore is nil, NewStore is used.
+// As with any parallel subtests, the scenarios finish before t does,
+// but after TestParallel returns.
+func (scs Scenarios) TestParallel(t *testing.T, newHttpCall func() *HttpCall, newStore func() *Store) {
+	if newHttpCall == nil {
+		newHttpCall = func() *HttpCall { return NewHttpCall(nil) }
+	}
+	if newStore == nil {
+		newStore = NewStore
+	}
+	for idx, sc := range scs {
+		sc := sc
+		name := sc.Name
+		if name == "" {
+			name = fmt.Sprintf("scenario-%d", idx+1)
+		}
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			hc := newHttpCall()
+			defer hc.Reset()
+			sc.Build(hc, newStore()).Test(t)
+		})
+	}
+}
+
+func (ss *ScenarioStep) steps(hc *HttpCall, store *Store) Steps {
+	req := ss.Request
+	name := ss.Name
+	if name == "" {
+		name = fmt.Sprintf("%s %s", req.Method, req.URL)
+	}
+	steps := Steps{NewNamedStep(fmt.Sprintf("Request(%s)", name), func() error {
+		urlStr, err := store.Interpolate(req.URL)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(urlStr, "/") {
+			urlStr = strings.TrimSuffix(store.GetString("baseURL"), "/") + urlStr
+		}
+		var body io.Reader
+		if req.JSON != nil {
+			if value, err := interpolateValue(store, req.JSON); err != nil {
+				return err
+			} else if bites, err := json.Marshal(value); err != nil {
+				return err
+			} else {
+				body = bytes.NewReader(bites)
+			}
+		} else if req.Body != "" {
+			if str, err := store.Interpolate(req.Body); err != nil {
+				return err
+			} else {
+				body = strings.NewReader(str)
+			}
+		}
+		requestSteps := Steps{hc.NewRequest(req.Method, urlStr, body)}
+		if req.JSON != nil {
+			requestSteps = append(requestSteps, hc.RequestHeader("Content-Type", "application/json"))
+		}
+		for _, key := range sortedKeys(req.Headers) {
+			if value, err := store.Interpolate(req.Headers[key]); err != nil {
+				return err
+			} else {
+				requestSteps = append(requestSteps, hc.RequestHeader(key, value))
+			}
+		}
+		return requestSteps.Go()
+	})}
+	if ss.Expect != nil {
+		steps = append(steps, ss.Expect.steps(hc, store)...)
+	}
+	keys := make([]string, 0, len(ss.Capture))
+	for key := range ss.Capture {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		steps = append(steps, captureStep(hc, store, key, ss.Capture[key]))
+	}
+	return steps
+}
+
+func (se *ScenarioExpect) steps(hc *HttpCall, store *Store) Steps {
+	steps := Steps{}
+	if se.Status != 0 {
+		steps = append(steps, hc.ResponseStatusEquals(se.Status))
+	}
+	for _, key := range sortedKeys(se.Headers) {
+		key := key
+		steps = append(steps, interpolated(fmt.Sprintf("ResponseHeaderEquals(%s)", key), store, []string{se.Headers[key]},
+			func(args []string) Step { return hc.ResponseHeaderEquals(key, args[0]) }))
+	}
+	for _, key := range sortedKeys(se.HeadersContain) {
+		key := key
+		steps = append(steps, interpolated(fmt.Sprintf("ResponseHeaderContains(%s)", key), store, []string{se.HeadersContain[key]},
+			func(args []string) Step { return hc.ResponseHeaderContains(key, args[0]) }))
+	}
+	for _, key := range se.HeadersAbsent {
+		steps = append(steps, hc.ResponseHeaderNotExists(key))
+	}
+	if se.Body != nil {
+		steps = append(steps, interpolated("ResponseBodyEquals", store, []string{*se.Body},
+			func(args []string) Step { return hc.ResponseBodyEquals(args[0]) }))
+	}
+	for _, value := range se.BodyContains {
+		steps = append(steps, interpolated("ResponseBodyContains", store, []string{value},
+			func(args []string) Step { return hc.ResponseBodyContains(args[0]) }))
+	}
+	if se.BodyMatches != "" {
+		bodyMatches := se.BodyMatches
+		steps = append(steps, NewNamedStep(fmt.Sprintf("ResponseBodyMatches(%s)", bodyMatches), func() error {
+			if pattern, err := regexp.Compile(bodyMatches); err != nil {
+				return err
+			} else {
+				return hc.ResponseBodyMatches(pattern).Go()
+			}
+		}))
+	}
+	paths := make([]string, 0, len(se.JSON))
+	for path := range se.JSON {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		path, expected := path, se.JSON[path]
+		steps = append(steps, NewNamedStep(fmt.Sprintf("ResponseBodyJSONPathEquals(%s)", path), func() error {
+			if value, err := interpolateValue(store, expected); err != nil {
+				return err
+			} else {
+				return hc.ResponseBodyJSONPathEquals(path, value).Go()
+			}
+		}))
+	}
+	if se.JSONSchema != "" {
+		steps = append(steps, hc.ResponseBodyJSONSchema(se.JSONSchema))
+	}
+	return steps
+}
+
+func captureStep(hc *HttpCall, store *Store, key, source string) Step {
+	switch {
+	case strings.HasPrefix(source, "json:"):
+		return hc.CaptureJSON(store, key, strings.TrimPrefix(source, "json:"))
+	case strings.HasPrefix(source, "header:"):
+		return hc.CaptureHeader(store, key, strings.TrimPrefix(source, "header:"))
+	case source == "status":
+		return hc.step(fmt.Sprintf("CaptureStatus(%s)", key), func() error {
+			if err := hc.EnsureResponse(); err != nil {
+				return err
+			}
+			store.Set(key, strconv.Itoa(hc.Response.StatusCode))
+			return nil
+		})
+	case source == "body":
+		return hc.step(fmt.Sprintf("CaptureBody(%s)", key), func() error {
+			if err := hc.ReceiveBody(); err != nil {
+				return err
+			}
+			store.Set(key, string(hc.ResponseBody))
+			return nil
+		})
+	default:
+		return NewNamedStep(fmt.Sprintf("Capture(%s)", key), func() error {
+			return errors.New("Unknown capture source: " + source)
+		})
+	}
+}
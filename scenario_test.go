@@ -0,0 +1,184 @@
+package argot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const userScenario = `
+name: users
+vars:
+  userName: alice
+steps:
+  - name: create
+    request:
+      method: POST
+      url: /users
+      headers: {X-Request-Id: "req-${userName}"}
+      json: {name: "${userName}", tags: [a, b]}
+    expect:
+      status: 201
+      headers: {Content-Type: application/json}
+      json:
+        name: "${userName}"
+        tags[1]: b
+    capture:
+      userID: json:id
+      location: header:Location
+  - request:
+      method: GET
+      url: "${location}"
+    expect:
+      status: 200
+      bodyContains: ['"id":${userID}']
+      bodyMatches: '"name":"alice"'
+`
+
+func userServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "POST" && r.Header.Get("X-Request-Id") == "req-alice" {
+			var user map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&user)
+			user["id"] = 12345678
+			w.Header().Set("Location", "/users/12345678")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(user)
+		} else if r.Method == "GET" && r.URL.Path == "/users/12345678" {
+			w.Write([]byte(`{"id":12345678,"name":"alice"}`))
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestScenario(t *testing.T) {
+	server := userServer()
+	defer server.Close()
+	scenario, err := ParseScenario([]byte(userScenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	store := NewStore()
+	store.Set("baseURL", server.URL)
+
+	scenario.Build(hc, store).Test(t)
+	Steps{store.ExpectEquals("userID", "12345678")}.Test(t)
+}
+
+func TestScenarioJSON(t *testing.T) {
+	server := userServer()
+	defer server.Close()
+	scenario, err := ParseScenario([]byte(`{"steps": [{"request": {"method": "GET", "url": "/missing"}, "expect": {"status": 200}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	store := NewStore()
+	store.Set("baseURL", server.URL)
+	if _, err := scenario.Build(hc, store).Test(nil); err == nil || !strings.Contains(err.Error(), "Expected 200; found 404") {
+		t.Fatalf("Expected status failure; found %v", err)
+	}
+}
+
+func TestScenarioBadBodyMatches(t *testing.T) {
+	server := userServer()
+	defer server.Close()
+	scenario := &Scenario{Steps: []*ScenarioStep{{
+		Request: &ScenarioRequest{Method: "GET", URL: server.URL + "/missing"},
+		Expect:  &ScenarioExpect{BodyMatches: "("},
+	}}}
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	if _, err := scenario.Build(hc, NewStore()).Test(nil); err == nil || !strings.Contains(err.Error(), "missing closing )") {
+		t.Fatalf("Expected the bad pattern to be reported; found %v", err)
+	}
+}
+
+func TestParseScenarioErrors(t *testing.T) {
+	for _, doc := range []string{
+		`steps: [{request: {method: GET}}]`,
+		`steps: [{request: {method: GET, url: /}, expect: {stauts: 200}}]`,
+		`steps: [{request: {method: GET, url: /}, capture: {x: cookie}}]`,
+	} {
+		if _, err := ParseScenario([]byte(doc)); err == nil {
+			t.Errorf("Expected an error parsing %s", doc)
+		}
+	}
+}
+
+func TestStoreInterpolate(t *testing.T) {
+	store := NewStore()
+	store.Set("a", 1)
+	store.Set("b", "two")
+	if str, err := store.Interpolate("${a}-${b}-$${a}"); err != nil || str != "1-two-${a}" {
+		t.Fatalf("Unexpected interpolation: %q %v", str, err)
+	}
+	if _, err := store.Interpolate("${missing}"); err == nil {
+		t.Fatal("Expected an error for a missing key")
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"a": {"b": [1, {"c": "x"}]}}`), &doc)
+	if value, err := JSONPath(doc, "$.a.b[1].c"); err != nil || value != "x" {
+		t.Fatalf("Unexpected value: %v %v", value, err)
+	}
+	for _, path := range []string{"a.x", "a.b[2]", "a.b.c", "a[0]"} {
+		if _, err := JSONPath(doc, path); err == nil {
+			t.Errorf("Expected an error for %s", path)
+		}
+	}
+}
+
+func TestScenariosTestParallel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	scenarios := Scenarios{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		scenario, err := ParseScenario([]byte(`
+name: ` + name + `
+vars:
+  me: ` + name + `
+steps:
+  - request: {method: GET, url: "/${me}"}
+    expect: {status: 200, body: "/${me}"}
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scenarios = append(scenarios, scenario)
+	}
+	stores := make(chan *Store, len(scenarios))
+	t.Run("suite", func(t *testing.T) {
+		scenarios.TestParallel(t, nil, func() *Store {
+			store := NewStore()
+			store.Set("baseURL", server.URL)
+			stores <- store
+			return store
+		})
+		// Parallel subtests only start once their parent's function
+		// returns.
+		if len(stores) != 0 {
+			t.Fatal("Expected the scenarios to be parallel subtests.")
+		}
+	})
+	close(stores)
+	seen := map[string]bool{}
+	for store := range stores {
+		seen[store.GetString("me")] = true
+	}
+	if len(seen) != len(scenarios) {
+		t.Fatalf("Expected each scenario to have its own store; found %v.", seen)
+	}
+}
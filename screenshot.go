@@ -0,0 +1,37 @@
+package argot
+
+import (
+	"mime"
+	"strings"
+)
+
+// ScreenshotFunc renders an HTML page to a PNG image, typically with a
+// headless browser such as Chrome driven by chromedp or Playwright,
+// which argot does not depend upon. html is the body of the response
+// to the request for urlStr, against which relative links (to
+// stylesheets, images and so on) should be resolved. Set as
+// HttpCall.Screenshot, it is called when a step fails whilst the
+// HttpCall holds an HTML response, so that a failure of, for example,
+// a server-rendered error page can be seen as a user would see it.
+// Note the screenshot cannot be redacted, so take care in sharing
+// artifacts of pages which show sensitive data.
+type ScreenshotFunc func(urlStr string, html []byte) ([]byte, error)
+
+// isHTML returns true iff contentType is that of an HTML document.
+func isHTML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// screenshot returns the screenshot, by hc.Screenshot, of hc's
+// response, or nil if there is no HTML response whose body can be
+// received.
+func (hc *HttpCall) screenshot() ([]byte, error) {
+	if hc.Request == nil || hc.Response == nil || !isHTML(hc.Response.Header.Get("Content-Type")) {
+		return nil, nil
+	} else if hc.ReceiveBody() != nil || len(strings.TrimSpace(string(hc.ResponseBody))) == 0 {
+		return nil, nil
+	} else {
+		return hc.Screenshot(hc.Request.URL.String(), hc.ResponseBody)
+	}
+}
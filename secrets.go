@@ -0,0 +1,247 @@
+package argot
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SecretProvider looks up secrets, such as API tokens and passwords, by
+// name, so that tests can refer to them without embedding their values
+// in code or in step names. Set one as HttpCall.Secrets to use the
+// steps such as RequestBearerTokenFromSecret. Secret values obtained by
+// those steps are added to the redactors (see Redactor.AddValue) so
+// that they never appear in failure output.
+type SecretProvider interface {
+	// Secret returns the value of the named secret, or an error if it
+	// cannot be found.
+	Secret(name string) (string, error)
+}
+
+// EnvSecrets is a SecretProvider which reads secrets from environment
+// variables. A secret's name is converted to the variable's name by
+// upper-casing it and replacing every character other than a letter
+// or digit with an underscore, and adding Prefix: with the prefix
+// "ARGOT_SECRET_", the secret api-token is read from
+// ARGOT_SECRET_API_TOKEN.
+type EnvSecrets struct {
+	Prefix string
+}
+
+// Secret implements SecretProvider.
+func (es EnvSecrets) Secret(name string) (string, error) {
+	variable := es.Prefix + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		} else if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		} else {
+			return '_'
+		}
+	}, name)
+	if value, found := os.LookupEnv(variable); !found {
+		return "", fmt.Errorf("Secret '%s' not found: %s is not set.", name, variable)
+	} else {
+		return value, nil
+	}
+}
+
+// FileSecrets is a SecretProvider which reads each secret from the file
+// of the same name in Dir, as Docker and Kubernetes mount secrets.
+// Trailing newlines are removed.
+type FileSecrets struct {
+	Dir string
+}
+
+// Secret implements SecretProvider.
+func (fs FileSecrets) Secret(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("Secret '%s': Invalid name.", name)
+	} else if bites, err := ioutil.ReadFile(filepath.Join(fs.Dir, name)); err != nil {
+		return "", fmt.Errorf("Secret '%s' not found: %v", name, err)
+	} else {
+		return strings.TrimRight(string(bites), "\r\n"), nil
+	}
+}
+
+// VaultSecrets is a SecretProvider which reads secrets from HashiCorp
+// Vault. A secret's name is a field of the secret at Path, or, if it
+// contains a '#', a path and a field: with the Path "secret/data/argot",
+// the name api-token reads the field api-token of secret/data/argot,
+// and the name "secret/data/other#token" reads the field token of
+// secret/data/other. Both version 1 and version 2 (whose paths include
+// "/data/") key/value engines are supported. Each path is read once.
+type VaultSecrets struct {
+	// The address of the Vault server. If empty, $VAULT_ADDR is used.
+	Address string
+	// The token with which to authenticate. If empty, $VAULT_TOKEN is
+	// used.
+	Token string
+	// The default path of secrets.
+	Path string
+	// The client used to make requests. If nil, http.DefaultClient is
+	// used.
+	Client *http.Client
+
+	lock  sync.Mutex
+	cache map[string]map[string]interface{}
+}
+
+// Secret implements SecretProvider.
+func (vs *VaultSecrets) Secret(name string) (string, error) {
+	path, field := vs.Path, name
+	if idx := strings.LastIndexByte(name, '#'); idx >= 0 {
+		path, field = name[:idx], name[idx+1:]
+	}
+	if fields, err := vs.read(path); err != nil {
+		return "", fmt.Errorf("Secret '%s': %v", name, err)
+	} else if value, found := fields[field]; !found {
+		return "", fmt.Errorf("Secret '%s' not found: No field '%s' at %s.", name, field, path)
+	} else if str, ok := value.(string); ok {
+		return str, nil
+	} else {
+		return fmt.Sprint(value), nil
+	}
+}
+
+// read returns the fields of the secret at path.
+func (vs *VaultSecrets) read(path string) (map[string]interface{}, error) {
+	vs.lock.Lock()
+	defer vs.lock.Unlock()
+	if fields, found := vs.cache[path]; found {
+		return fields, nil
+	}
+	address, token, client := vs.Address, vs.Token, vs.Client
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if address == "" {
+		return nil, errors.New("Vault: No address: set Address or $VAULT_ADDR.")
+	}
+	req, err := http.NewRequest("GET", strings.TrimSuffix(address, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Vault: Reading %s: Expected status 200; found %d.", path, response.StatusCode)
+	} else if err := json.NewDecoder(response.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("Vault: Reading %s: %v", path, err)
+	}
+	fields := secret.Data
+	if nested, ok := fields["data"].(map[string]interface{}); ok && strings.Contains(path, "/data/") {
+		fields = nested
+	}
+	if vs.cache == nil {
+		vs.cache = make(map[string]map[string]interface{})
+	}
+	vs.cache[path] = fields
+	return fields, nil
+}
+
+// SecretProviders is a SecretProvider which tries each of its
+// providers in turn, returning the first secret found.
+type SecretProviders []SecretProvider
+
+// Secret implements SecretProvider.
+func (sps SecretProviders) Secret(name string) (string, error) {
+	errs := make([]string, 0, len(sps))
+	for _, provider := range sps {
+		if value, err := provider.Secret(name); err == nil {
+			return value, nil
+		} else {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) == 0 {
+		return "", fmt.Errorf("Secret '%s' not found: No providers.", name)
+	}
+	return "", fmt.Errorf("Secret '%s' not found: %s", name, strings.Join(errs, "; "))
+}
+
+// Secret implements SecretProvider, returning the environment's
+// credentials.
+func (env *Environment) Secret(name string) (string, error) {
+	return env.Credential(name)
+}
+
+// secret returns the value of the named secret from hc.Secrets,
+// having added it to hc's redactor.
+func (hc *HttpCall) secret(name string) (string, error) {
+	if hc.Secrets == nil {
+		return "", fmt.Errorf("Secret '%s': No SecretProvider: set HttpCall.Secrets.", name)
+	} else if value, err := hc.Secrets.Secret(name); err != nil {
+		return "", err
+	} else {
+		hc.ownRedactor().AddValue(value)
+		return value, nil
+	}
+}
+
+// RequestHeaderFromSecret is a Step that when executed sets the header
+// key of the HTTP Request to the value of the named secret from
+// hc.Secrets. The step's name contains only the secret's name. As with
+// RequestHeader, this can only be done after hc.Request has been
+// created, and before hc.Response has been created.
+func (hc *HttpCall) RequestHeaderFromSecret(key, name string) Step {
+	return hc.step(fmt.Sprintf("RequestHeaderFromSecret(%s: %s)", key, name), func() error {
+		return hc.setHeaderFromSecret(key, name, func(value string) string { return value })
+	})
+}
+
+// RequestBearerTokenFromSecret is a Step that when executed sets the
+// Authorization header of the HTTP Request to a bearer token, the
+// value of the named secret from hc.Secrets. As with
+// RequestHeaderFromSecret, the value never appears in step names or
+// failure output.
+func (hc *HttpCall) RequestBearerTokenFromSecret(name string) Step {
+	return hc.step(fmt.Sprintf("RequestBearerTokenFromSecret(%s)", name), func() error {
+		return hc.setHeaderFromSecret("Authorization", name, func(value string) string { return "Bearer " + value })
+	})
+}
+
+// RequestBasicAuthFromSecret is a Step that when executed sets the
+// Authorization header of the HTTP Request to basic authentication
+// with the given username, and the named secret from hc.Secrets as
+// the password.
+func (hc *HttpCall) RequestBasicAuthFromSecret(username, name string) Step {
+	return hc.step(fmt.Sprintf("RequestBasicAuthFromSecret(%s, %s)", username, name), func() error {
+		return hc.setHeaderFromSecret("Authorization", name, func(value string) string {
+			credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + value))
+			hc.ownRedactor().AddValue(credentials)
+			return "Basic " + credentials
+		})
+	})
+}
+
+func (hc *HttpCall) setHeaderFromSecret(key, name string, format func(string) string) error {
+	if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
+		return err
+	} else if value, err := hc.secret(name); err != nil {
+		return err
+	} else {
+		hc.Request.Header.Set(key, format(value))
+		return nil
+	}
+}
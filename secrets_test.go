@@ -0,0 +1,107 @@
+package argot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretProviders(t *testing.T) {
+	os.Setenv("ARGOT_TEST_SECRET_API_TOKEN", "from-env")
+	defer os.Unsetenv("ARGOT_TEST_SECRET_API_TOKEN")
+	dir, err := ioutil.TempDir("", "argot-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "db-password"), []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	requests := 0
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+		} else if r.URL.Path == "/v1/secret/data/argot" {
+			w.Write([]byte(`{"data": {"data": {"signing-key": "from-vault"}, "metadata": {"version": 3}}}`))
+		} else if r.URL.Path == "/v1/kv/argot" {
+			w.Write([]byte(`{"data": {"token": "from-vault-v1"}}`))
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+
+	providers := SecretProviders{
+		EnvSecrets{Prefix: "ARGOT_TEST_SECRET_"},
+		FileSecrets{Dir: dir},
+		&VaultSecrets{Address: vault.URL, Token: "root", Path: "secret/data/argot"},
+	}
+	for name, expected := range map[string]string{
+		"api-token":      "from-env",
+		"db-password":    "from-file",
+		"signing-key":    "from-vault",
+		"kv/argot#token": "from-vault-v1",
+	} {
+		if value, err := providers.Secret(name); err != nil || value != expected {
+			t.Fatalf("Secret %s: Expected %s; found %s (%v).", name, expected, value, err)
+		}
+	}
+	if _, err := providers.Secret("signing-key"); err != nil || requests != 2 {
+		t.Fatalf("Expected Vault to be read once per path: Expected 2 requests; found %d (%v).", requests, err)
+	}
+	if _, err := providers.Secret("missing"); err == nil || !strings.Contains(err.Error(), "ARGOT_TEST_SECRET_MISSING") {
+		t.Fatalf("Expected a missing secret to be an error from every provider; found %v.", err)
+	}
+	if _, err := (FileSecrets{Dir: dir}).Secret("../db-password"); err == nil {
+		t.Fatal("Expected a name outside Dir to be an error.")
+	}
+	if _, err := (&VaultSecrets{Address: vault.URL, Token: "wrong", Path: "secret/data/argot"}).Secret("signing-key"); err == nil {
+		t.Fatal("Expected a forbidden Vault read to be an error.")
+	}
+}
+
+func TestRequestFromSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok-9c1f" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		w.Write([]byte("key=" + r.Header.Get("X-Api-Key")))
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		ExpectError(hc.RequestBearerTokenFromSecret("api-token")),
+	}.Test(t)
+
+	hc.Secrets = &Environment{Name: "test", Credentials: map[string]string{"api-token": "tok-9c1f", "api-key": "key-71ad"}}
+	step := hc.RequestBearerTokenFromSecret("api-token")
+	if name := step.(*NamedStep).name; name != "RequestBearerTokenFromSecret(api-token)" {
+		t.Fatalf("Expected the step name to contain only the secret's name; found %s.", name)
+	}
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		step,
+		hc.ResponseStatusEquals(200),
+	}.Test(t)
+	hc.Reset()
+
+	err := Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.RequestBearerTokenFromSecret("api-token"),
+		hc.RequestHeaderFromSecret("X-Api-Key", "api-key"),
+		hc.ResponseBodyEquals("nope"),
+	}.Go()
+	if err == nil {
+		t.Fatal("Expected ResponseBodyEquals to fail.")
+	} else if msg := err.Error(); strings.Contains(msg, "key-71ad") || strings.Contains(msg, "tok-9c1f") {
+		t.Fatalf("Expected the secrets to be redacted; found %s.", msg)
+	}
+}
@@ -0,0 +1,128 @@
+package argot
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// decodeSignature decodes a signature given in hex or, failing that,
+// standard base64.
+func decodeSignature(signature string) ([]byte, error) {
+	if decoded, err := hex.DecodeString(signature); err == nil {
+		return decoded, nil
+	} else if decoded, err := base64.StdEncoding.DecodeString(signature); err == nil {
+		return decoded, nil
+	} else {
+		return nil, fmt.Errorf("Signature: Expected hex or base64; found '%s'.", signature)
+	}
+}
+
+func hmacSHA256(secret, message []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+func webhookHeader(request *WebhookRequest, header string) (string, error) {
+	if value := request.Header.Get(header); value == "" {
+		return "", fmt.Errorf("Header '%s' not found.", header)
+	} else {
+		return value, nil
+	}
+}
+
+// WebhookHMACSignature matches requests whose header carries the
+// HMAC-SHA256 of the body keyed with secret, in hex or base64, after
+// prefix. For example, GitHub signs with
+//
+//	WebhookHMACSignature("X-Hub-Signature-256", "sha256=", secret)
+func WebhookHMACSignature(header, prefix string, secret []byte) WebhookMatcher {
+	return func(request *WebhookRequest) error {
+		value, err := webhookHeader(request, header)
+		if err != nil {
+			return err
+		} else if !strings.HasPrefix(value, prefix) {
+			return fmt.Errorf("Header '%s': Expected prefix '%s'; found '%s'.", header, prefix, value)
+		} else if signature, err := decodeSignature(strings.TrimPrefix(value, prefix)); err != nil {
+			return err
+		} else if !hmac.Equal(signature, hmacSHA256(secret, request.Body)) {
+			return fmt.Errorf("Header '%s': HMAC signature does not match the body.", header)
+		}
+		return nil
+	}
+}
+
+// WebhookStripeSignature matches requests whose header carries a
+// Stripe style signature: t=<unix time>,v1=<hex HMAC-SHA256>, where
+// the HMAC is of the timestamp, a '.' and the body, keyed with secret.
+// Any of several v1 signatures may match. If tolerance is non-zero,
+// the timestamp must also be within tolerance of the time the request
+// was received, guarding against replays.
+func WebhookStripeSignature(header string, secret []byte, tolerance time.Duration) WebhookMatcher {
+	return func(request *WebhookRequest) error {
+		value, err := webhookHeader(request, header)
+		if err != nil {
+			return err
+		}
+		timestamp, signatures := "", []string{}
+		for _, part := range strings.Split(value, ",") {
+			if kv := strings.SplitN(strings.TrimSpace(part), "=", 2); len(kv) != 2 {
+				continue
+			} else if kv[0] == "t" {
+				timestamp = kv[1]
+			} else if kv[0] == "v1" {
+				signatures = append(signatures, kv[1])
+			}
+		}
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil || len(signatures) == 0 {
+			return fmt.Errorf("Header '%s': Expected t=<timestamp>,v1=<signature>; found '%s'.", header, value)
+		}
+		if skew := request.Received.Sub(time.Unix(seconds, 0)); tolerance > 0 && (skew > tolerance || skew < -tolerance) {
+			return fmt.Errorf("Header '%s': Expected timestamp within %v; found %v away.", header, tolerance, skew)
+		}
+		expected := hmacSHA256(secret, append([]byte(timestamp+"."), request.Body...))
+		for _, signature := range signatures {
+			if decoded, err := hex.DecodeString(signature); err == nil && hmac.Equal(decoded, expected) {
+				return nil
+			}
+		}
+		return fmt.Errorf("Header '%s': No v1 signature matches the body.", header)
+	}
+}
+
+// WebhookEd25519Signature matches requests whose header carries an
+// Ed25519 signature, in hex or base64, of the body, made with the
+// private key corresponding to publicKey. If timestampHeader is not
+// empty, the signed message is the value of that header followed by
+// the body (as used by Discord, for example).
+func WebhookEd25519Signature(header, timestampHeader string, publicKey ed25519.PublicKey) WebhookMatcher {
+	return func(request *WebhookRequest) error {
+		message := request.Body
+		if timestampHeader != "" {
+			if timestamp, err := webhookHeader(request, timestampHeader); err != nil {
+				return err
+			} else {
+				message = append([]byte(timestamp), request.Body...)
+			}
+		}
+		if value, err := webhookHeader(request, header); err != nil {
+			return err
+		} else if signature, err := decodeSignature(value); err != nil {
+			return err
+		} else if len(publicKey) != ed25519.PublicKeySize {
+			return errors.New("Ed25519: Invalid public key.")
+		} else if !ed25519.Verify(publicKey, message, signature) {
+			return fmt.Errorf("Header '%s': Ed25519 signature does not match the body.", header)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,107 @@
+package argot
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// SlowHeaders is a Step that when executed connects to address over
+// TCP and sends the start of an HTTP request for host, then one
+// further header line every interval, never completing the headers,
+// as a slowloris attack does. It errors unless the server, enforcing
+// its read timeouts, closes the connection within timeout. Anything
+// the server sends first, such as a 408 Request Timeout response, is
+// available from Received. The step will automatically call nc.Reset
+// first.
+func (nc *NetCall) SlowHeaders(address, host string, interval, timeout time.Duration) Step {
+	name := fmt.Sprintf("SlowHeaders(%s: every %v within %v)", address, interval, timeout)
+	return NewNamedStep(name, func() error {
+		head := fmt.Sprintf("GET / HTTP/1.1\r\nHost: %s\r\n", host)
+		return nc.trickle(address, []byte(head), func(idx int) []byte {
+			return []byte(fmt.Sprintf("X-Slow-%d: %d\r\n", idx, idx))
+		}, interval, timeout)
+	})
+}
+
+// SlowBody is a Step that when executed connects to address over TCP
+// and sends the headers of an HTTP POST to path on host, declaring a
+// body of size bytes, then sends one byte of the body every interval.
+// It errors unless the server closes the connection within timeout
+// (see SlowHeaders), so size should be large enough that the body is
+// not completed within timeout.
+func (nc *NetCall) SlowBody(address, host, path string, size int, interval, timeout time.Duration) Step {
+	name := fmt.Sprintf("SlowBody(%s%s: every %v within %v)", address, path, interval, timeout)
+	return NewNamedStep(name, func() error {
+		head := fmt.Sprintf("POST %s HTTP/1.1\r\nHost: %s\r\nContent-Type: application/octet-stream\r\nContent-Length: %d\r\n\r\n", path, host, size)
+		return nc.trickle(address, []byte(head), func(idx int) []byte {
+			if idx < size {
+				return []byte{'a'}
+			} else {
+				return nil
+			}
+		}, interval, timeout)
+	})
+}
+
+// trickle connects to address and sends head, then the data returned
+// by next every interval, erroring unless the server closes the
+// connection within timeout. Data received is left in nc.received.
+func (nc *NetCall) trickle(address string, head []byte, next func(idx int) []byte, interval, timeout time.Duration) error {
+	if err := nc.Connect("tcp", address).Go(); err != nil {
+		return err
+	}
+	received := []byte{}
+	var readErr error
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		buf := make([]byte, 4096)
+		for {
+			n, err := nc.Conn.Read(buf)
+			received = append(received, buf[:n]...)
+			if err != nil {
+				readErr = err
+				return
+			}
+		}
+	}()
+	start := time.Now()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	sent, idx := 0, 0
+	data := head
+	for {
+		if len(data) > 0 {
+			if n, err := nc.Conn.Write(data); err != nil {
+				// The server has closed the connection; the reader
+				// will notice.
+				data = nil
+			} else {
+				sent += n
+			}
+		}
+		select {
+		case <-closed:
+			nc.received, nc.closed = received, true
+			return nil
+		case <-deadline.C:
+			// Stop the reader, which may yet find the connection
+			// closed.
+			nc.Conn.SetReadDeadline(time.Now())
+			<-closed
+			nc.Conn.SetReadDeadline(time.Time{})
+			nc.received = received
+			if netErr, ok := readErr.(net.Error); ok && netErr.Timeout() {
+				return fmt.Errorf("Expected the server to close the connection within %v; still open after %v and %d bytes sent; received %q.", timeout, time.Since(start).Round(time.Millisecond), sent, received)
+			}
+			nc.closed = true
+			return nil
+		case <-ticker.C:
+			data = next(idx)
+			idx++
+		}
+	}
+}
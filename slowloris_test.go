@@ -0,0 +1,43 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowloris(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	protected := httptest.NewUnstartedServer(handler)
+	protected.Config.ReadHeaderTimeout = 200 * time.Millisecond
+	protected.Config.ReadTimeout = 300 * time.Millisecond
+	protected.Start()
+	defer protected.Close()
+	unprotected := httptest.NewServer(handler)
+	defer unprotected.Close()
+
+	nc := NewNetCall()
+	defer nc.Reset()
+	address := protected.Listener.Addr().String()
+	Steps{
+		nc.SlowHeaders(address, "example.com", 50*time.Millisecond, 2*time.Second),
+		nc.SlowBody(address, "example.com", "/upload", 1000, 50*time.Millisecond, 2*time.Second),
+	}.Test(t)
+
+	address = unprotected.Listener.Addr().String()
+	if _, err := (Steps{nc.SlowHeaders(address, "example.com", 50*time.Millisecond, 300*time.Millisecond)}).Test(nil); err == nil {
+		t.Fatal("Expected the unprotected server to keep the connection open.")
+	} else if !strings.Contains(err.Error(), "Expected the server to close the connection within 300ms") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// A server which answers the completed request is not protected
+	// against a slow body that it never reads.
+	if _, err := (Steps{
+		nc.SlowBody(address, "example.com", "/upload", 1000, 50*time.Millisecond, 300*time.Millisecond),
+	}).Test(nil); err == nil {
+		t.Fatal("Expected the unprotected server to keep the connection open.")
+	}
+}
@@ -0,0 +1,289 @@
+package argot
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Email is a message received by an SMTPSink.
+type Email struct {
+	// The envelope sender (MAIL FROM).
+	From string
+	// The envelope recipients (RCPT TO).
+	To []string
+	// The parsed message headers.
+	Header mail.Header
+	// The decoded Subject header.
+	Subject string
+	// The message body.
+	Body string
+	// The message exactly as received.
+	Raw []byte
+}
+
+// SMTPSink is a minimal SMTP server which accepts all mail and keeps
+// it so that steps can assert on what was sent. Point the system under
+// test at Addr, trigger an action (for example through an HttpCall),
+// and then use ExpectEmail. It supports neither TLS nor
+// authentication.
+type SMTPSink struct {
+	// The address the sink is listening on.
+	Addr string
+
+	listener net.Listener
+	lock     sync.Mutex
+	cond     *sync.Cond
+	emails   []*Email
+	closed   bool
+}
+
+// NewSMTPSink creates a new SMTPSink listening on addr. If addr is
+// empty, a random port on the loopback interface is used. Close must
+// be called to stop the sink.
+func NewSMTPSink(addr string) (*SMTPSink, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	sink := &SMTPSink{
+		Addr:     listener.Addr().String(),
+		listener: listener,
+	}
+	sink.cond = sync.NewCond(&sink.lock)
+	go sink.serve()
+	return sink, nil
+}
+
+// Close stops the sink.
+func (sink *SMTPSink) Close() error {
+	sink.lock.Lock()
+	sink.closed = true
+	sink.cond.Broadcast()
+	sink.lock.Unlock()
+	return sink.listener.Close()
+}
+
+// Emails returns all the emails received which have not been consumed
+// by ExpectEmail.
+func (sink *SMTPSink) Emails() []*Email {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	return append([]*Email{}, sink.emails...)
+}
+
+// Clear discards all emails received.
+func (sink *SMTPSink) Clear() {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	sink.emails = nil
+}
+
+func (sink *SMTPSink) serve() {
+	for {
+		conn, err := sink.listener.Accept()
+		if err != nil {
+			return
+		}
+		go sink.session(conn)
+	}
+}
+
+func (sink *SMTPSink) session(conn net.Conn) {
+	text := textproto.NewConn(conn)
+	defer text.Close()
+	text.PrintfLine("220 argot SMTP sink ready")
+	email := new(Email)
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg := line, ""
+		if idx := strings.IndexByte(line, ' '); idx >= 0 {
+			verb, arg = line[:idx], strings.TrimSpace(line[idx+1:])
+		}
+		switch strings.ToUpper(verb) {
+		case "HELO", "EHLO":
+			text.PrintfLine("250 Hello %s", arg)
+		case "MAIL":
+			email = &Email{From: smtpPath(arg)}
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			email.To = append(email.To, smtpPath(arg))
+			text.PrintfLine("250 OK")
+		case "DATA":
+			if len(email.To) == 0 {
+				text.PrintfLine("503 No recipients")
+				continue
+			}
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			raw, err := ioutil.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			email.Raw = raw
+			if msg, err := mail.ReadMessage(bytes.NewReader(raw)); err == nil {
+				email.Header = msg.Header
+				body, _ := ioutil.ReadAll(msg.Body)
+				email.Body = string(body)
+				subject := msg.Header.Get("Subject")
+				if decoded, err := new(mime.WordDecoder).DecodeHeader(subject); err == nil {
+					subject = decoded
+				}
+				email.Subject = subject
+			} else {
+				email.Body = string(raw)
+			}
+			sink.lock.Lock()
+			sink.emails = append(sink.emails, email)
+			sink.cond.Broadcast()
+			sink.lock.Unlock()
+			email = new(Email)
+			text.PrintfLine("250 OK: queued")
+		case "RSET":
+			email = new(Email)
+			text.PrintfLine("250 OK")
+		case "NOOP":
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// smtpPath extracts the address from a MAIL FROM or RCPT TO argument.
+func smtpPath(arg string) string {
+	if start := strings.IndexByte(arg, '<'); start >= 0 {
+		if end := strings.IndexByte(arg[start:], '>'); end >= 0 {
+			return arg[start+1 : start+end]
+		}
+	}
+	if idx := strings.IndexByte(arg, ':'); idx >= 0 {
+		return strings.TrimSpace(arg[idx+1:])
+	}
+	return arg
+}
+
+// EmailMatcher returns nil iff the email matches.
+type EmailMatcher func(email *Email) error
+
+// EmailTo matches emails with addr among their recipients.
+func EmailTo(addr string) EmailMatcher {
+	return func(email *Email) error {
+		for _, to := range email.To {
+			if strings.EqualFold(to, addr) {
+				return nil
+			}
+		}
+		return fmt.Errorf("To: Expected %s; found %v.", addr, email.To)
+	}
+}
+
+// EmailFrom matches emails whose envelope sender is addr.
+func EmailFrom(addr string) EmailMatcher {
+	return func(email *Email) error {
+		if !strings.EqualFold(email.From, addr) {
+			return fmt.Errorf("From: Expected %s; found %s.", addr, email.From)
+		}
+		return nil
+	}
+}
+
+// EmailSubject matches emails whose subject equals subject.
+func EmailSubject(subject string) EmailMatcher {
+	return func(email *Email) error {
+		if email.Subject != subject {
+			return fmt.Errorf("Subject: Expected '%s'; found '%s'.", subject, email.Subject)
+		}
+		return nil
+	}
+}
+
+// EmailBodyContains matches emails whose body contains value using
+// strings.Contains.
+func EmailBodyContains(value string) EmailMatcher {
+	return func(email *Email) error {
+		if !strings.Contains(email.Body, value) {
+			return fmt.Errorf("Body: Expected '%s'; found '%s'.", value, email.Body)
+		}
+		return nil
+	}
+}
+
+// EmailBodyMatches matches emails whose body matches pattern.
+func EmailBodyMatches(pattern *regexp.Regexp) EmailMatcher {
+	return func(email *Email) error {
+		if !pattern.MatchString(email.Body) {
+			return fmt.Errorf("Body: Expected to match the pattern '%v'; found '%s'.", pattern, email.Body)
+		}
+		return nil
+	}
+}
+
+// ExpectEmail is a Step that when executed waits up to timeout for an
+// email which satisfies every matcher, and errors if none arrives.
+// The matching email is consumed so that it cannot satisfy a later
+// ExpectEmail.
+func (sink *SMTPSink) ExpectEmail(timeout time.Duration, matchers ...EmailMatcher) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectEmail(%v)", timeout), func() error {
+		sink.lock.Lock()
+		defer sink.lock.Unlock()
+		var lastErr error
+		found := false
+		waitForCond(sink.cond, timeout, func() bool {
+			for idx, email := range sink.emails {
+				if lastErr = matchEmail(email, matchers); lastErr == nil {
+					sink.emails = append(sink.emails[:idx], sink.emails[idx+1:]...)
+					found = true
+					break
+				}
+			}
+			return found || sink.closed
+		})
+		if found {
+			return nil
+		} else if lastErr == nil {
+			return fmt.Errorf("No email received within %v.", timeout)
+		} else {
+			return fmt.Errorf("No matching email received within %v (%d received). Last mismatch: %v", timeout, len(sink.emails), lastErr)
+		}
+	})
+}
+
+// ExpectNoEmail is a Step that when executed waits for wait and
+// errors if any email satisfying every matcher has been received.
+func (sink *SMTPSink) ExpectNoEmail(wait time.Duration, matchers ...EmailMatcher) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectNoEmail(%v)", wait), func() error {
+		time.Sleep(wait)
+		for _, email := range sink.Emails() {
+			if matchEmail(email, matchers) == nil {
+				return fmt.Errorf("Expected no email; found one to %v with subject '%s'.", email.To, email.Subject)
+			}
+		}
+		return nil
+	})
+}
+
+func matchEmail(email *Email, matchers []EmailMatcher) error {
+	for _, matcher := range matchers {
+		if err := matcher(email); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package argot
+
+import (
+	"net/smtp"
+	"testing"
+	"time"
+)
+
+func TestSMTPSink(t *testing.T) {
+	sink, err := NewSMTPSink("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	send := NewNamedStep("Send", func() error {
+		msg := "To: bob@example.com\r\nSubject: =?utf-8?q?Welcome_=E2=9C=93?=\r\n\r\nYour code is 1234.\r\n"
+		return smtp.SendMail(sink.Addr, nil, "app@example.com", []string{"bob@example.com"}, []byte(msg))
+	})
+
+	Steps{
+		send,
+		sink.ExpectEmail(time.Second,
+			EmailFrom("app@example.com"),
+			EmailTo("bob@example.com"),
+			EmailSubject("Welcome ✓"),
+			EmailBodyContains("code is 1234"),
+		),
+		ExpectError(sink.ExpectEmail(50*time.Millisecond, EmailTo("bob@example.com"))),
+		send,
+		ExpectError(sink.ExpectNoEmail(0)),
+	}.Test(t)
+}
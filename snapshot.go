@@ -0,0 +1,161 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// UpdateSnapshotsEnv is the name of the environment variable which,
+// when set to a non-empty value, causes ResponseMatchesSnapshot to
+// overwrite existing snapshots rather than compare against them.
+const UpdateSnapshotsEnv = "ARGOT_UPDATE_SNAPSHOTS"
+
+// SnapshotOptions controls what ResponseMatchesSnapshot records.
+type SnapshotOptions struct {
+	// Dir is the directory in which snapshots are stored. If empty,
+	// testdata/snapshots is used.
+	Dir string
+	// Headers lists the response headers to include in the
+	// snapshot. All other headers are ignored.
+	Headers []string
+	// Scrub lists JSON object keys whose values are volatile (ids,
+	// timestamps and so on). Wherever these keys appear in a JSON
+	// body, their values are replaced before comparison.
+	Scrub []string
+}
+
+const scrubbedValue = "<scrubbed>"
+
+type snapshot struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body"`
+}
+
+func (so *SnapshotOptions) path(name string) string {
+	dir := so.Dir
+	if dir == "" {
+		dir = filepath.Join("testdata", "snapshots")
+	}
+	return filepath.Join(dir, name+".json")
+}
+
+// normaliseBody returns the body as a value suitable for
+// snapshotting. If the body is JSON then it is decoded (so that on
+// re-encoding object keys are in a stable order) and scrubbed;
+// otherwise the body is used verbatim as a string. JSON bodies are then
+// normalised by normalisers.
+func (so *SnapshotOptions) normaliseBody(body []byte, normalisers []JSONNormaliser) interface{} {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return string(body)
+	}
+	scrub := make(map[string]bool, len(so.Scrub))
+	for _, key := range so.Scrub {
+		scrub[key] = true
+	}
+	return applyNormalisers(scrubJSON(value, scrub), normalisers)
+}
+
+func scrubJSON(value interface{}, scrub map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, elem := range v {
+			if scrub[key] {
+				v[key] = scrubbedValue
+			} else {
+				v[key] = scrubJSON(elem, scrub)
+			}
+		}
+	case []interface{}:
+		for idx, elem := range v {
+			v[idx] = scrubJSON(elem, scrub)
+		}
+	}
+	return value
+}
+
+func (hc *HttpCall) snapshot() ([]byte, error) {
+	snap := snapshot{
+		Status: hc.Response.StatusCode,
+		Body:   hc.Snapshot.normaliseBody(hc.ResponseBody, hc.JSONNormalisers),
+	}
+	if len(hc.Snapshot.Headers) > 0 {
+		snap.Headers = make(map[string]string, len(hc.Snapshot.Headers))
+		for _, key := range hc.Snapshot.Headers {
+			snap.Headers[key] = hc.Response.Header.Get(key)
+		}
+	}
+	return encodeSnapshot(&snap)
+}
+
+func encodeSnapshot(snap *snapshot) ([]byte, error) {
+	if bites, err := json.MarshalIndent(snap, "", "  "); err != nil {
+		return nil, err
+	} else {
+		return append(bites, '\n'), nil
+	}
+}
+
+// renormalise applies hc.JSONNormalisers to the body of an existing
+// snapshot, so that a snapshot recorded before a normaliser was added
+// still matches. If there are no normalisers, or the snapshot cannot
+// be decoded, it is returned unchanged.
+func (hc *HttpCall) renormalise(existing []byte) []byte {
+	var snap snapshot
+	decoder := json.NewDecoder(bytes.NewReader(existing))
+	decoder.UseNumber()
+	if len(hc.JSONNormalisers) == 0 {
+		return existing
+	} else if err := decoder.Decode(&snap); err != nil {
+		return existing
+	} else if _, isString := snap.Body.(string); isString {
+		return existing
+	}
+	snap.Body = applyNormalisers(snap.Body, hc.JSONNormalisers)
+	if bites, err := encodeSnapshot(&snap); err != nil {
+		return existing
+	} else {
+		return bites
+	}
+}
+
+// ResponseMatchesSnapshot is a Step that when executed ensures there
+// is a non-nil hc.ResponseBody, and compares the status, the headers
+// listed in hc.Snapshot.Headers and the normalised body against the
+// snapshot stored under the given name. A JSON body is scrubbed (see
+// SnapshotOptions) and normalised by hc.JSONNormalisers, as is the
+// body of the existing snapshot. If no such snapshot exists,
+// or the UpdateSnapshotsEnv environment variable is set, the snapshot
+// is written and the step succeeds.
+func (hc *HttpCall) ResponseMatchesSnapshot(name string) Step {
+	return hc.step(fmt.Sprintf("ResponseMatchesSnapshot(%s)", name), func() error {
+		path := hc.Snapshot.path(name)
+		hc.cover("status", "body:")
+		for _, key := range hc.Snapshot.Headers {
+			hc.coverHeader(key)
+		}
+		if err := hc.ReceiveBody(); err != nil {
+			return err
+		} else if current, err := hc.snapshot(); err != nil {
+			return err
+		} else if existing, err := ioutil.ReadFile(path); os.IsNotExist(err) || (err == nil && os.Getenv(UpdateSnapshotsEnv) != "") {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			return ioutil.WriteFile(path, current, 0644)
+		} else if err != nil {
+			return err
+		} else if existing = hc.renormalise(existing); !bytes.Equal(existing, current) {
+			return diffError(name, string(existing), string(current), "Snapshot '%s': Diff: '%s'.", name, diff(string(existing), string(current)))
+		} else {
+			return nil
+		}
+	})
+}
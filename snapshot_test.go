@@ -0,0 +1,42 @@
+package argot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestResponseMatchesSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "argot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	id := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"name": "%s", "id": %d}`, r.URL.Query().Get("name"), id)
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	hc.Snapshot = SnapshotOptions{Dir: dir, Headers: []string{"Content-Type"}, Scrub: []string{"id"}}
+
+	for _, name := range []string{"a", "a", "b"} {
+		_, err := Steps{
+			hc.NewRequest("GET", server.URL+"?name="+name, nil),
+			hc.ResponseMatchesSnapshot("snap"),
+		}.Test(nil)
+		if name == "b" && err == nil {
+			t.Fatal("Expected snapshot mismatch.")
+		} else if name != "b" && err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// SOAPVersion identifies the version of SOAP envelope to use.
+type SOAPVersion int
+
+const (
+	// SOAP11 envelopes are sent as text/xml with a SOAPAction header.
+	SOAP11 SOAPVersion = iota
+	// SOAP12 envelopes are sent as application/soap+xml with the
+	// action as a parameter of the Content-Type.
+	SOAP12
+)
+
+const (
+	soap11Namespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	soap12Namespace = "http://www.w3.org/2003/05/soap-envelope"
+)
+
+func (v SOAPVersion) String() string {
+	if v == SOAP12 {
+		return "SOAP 1.2"
+	} else {
+		return "SOAP 1.1"
+	}
+}
+
+// SOAPFault is a SOAP Fault, normalised across SOAP versions. For
+// SOAP 1.1 Code is the faultcode and Reason the faultstring; for SOAP
+// 1.2 Code is the Code/Value and Reason the first Reason/Text.
+type SOAPFault struct {
+	Code   string
+	Reason string
+	// Detail is the raw XML content of the fault's detail element.
+	Detail string
+}
+
+func (f *SOAPFault) Error() string {
+	return fmt.Sprintf("SOAP Fault %s: %s", f.Code, f.Reason)
+}
+
+type soapFaultXML struct {
+	// SOAP 1.1
+	FaultCode   string `xml:"faultcode"`
+	FaultString string `xml:"faultstring"`
+	Detail11    struct {
+		Inner string `xml:",innerxml"`
+	} `xml:"detail"`
+	// SOAP 1.2
+	Code struct {
+		Value string `xml:"Value"`
+	} `xml:"Code"`
+	Reason struct {
+		Text []string `xml:"Text"`
+	} `xml:"Reason"`
+	Detail12 struct {
+		Inner string `xml:",innerxml"`
+	} `xml:"Detail"`
+}
+
+type soapEnvelopeXML struct {
+	XMLName xml.Name
+	Body    struct {
+		Inner []byte `xml:",innerxml"`
+	} `xml:"Body"`
+}
+
+// soapEnvelope wraps payload in an envelope of the given version. If
+// payload is a string or []byte it is used verbatim, otherwise it is
+// marshalled with encoding/xml.
+func soapEnvelope(version SOAPVersion, payload interface{}) ([]byte, error) {
+	var inner []byte
+	switch p := payload.(type) {
+	case string:
+		inner = []byte(p)
+	case []byte:
+		inner = p
+	default:
+		if bites, err := xml.Marshal(payload); err != nil {
+			return nil, err
+		} else {
+			inner = bites
+		}
+	}
+	namespace := soap11Namespace
+	if version == SOAP12 {
+		namespace = soap12Namespace
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString(xml.Header)
+	fmt.Fprintf(buf, `<soap:Envelope xmlns:soap="%s"><soap:Body>`, namespace)
+	buf.Write(inner)
+	buf.WriteString(`</soap:Body></soap:Envelope>`)
+	return buf.Bytes(), nil
+}
+
+// NewSOAPRequest is a Step that when executed will create a new POST
+// request to urlStr whose body is payload wrapped in a SOAP envelope
+// of the given version, with the appropriate Content-Type and action
+// headers. If payload is a string or []byte it is used verbatim as the
+// content of the Body element; otherwise it is marshalled with
+// encoding/xml. As with NewRequest, hc.Reset is called first.
+func (hc *HttpCall) NewSOAPRequest(version SOAPVersion, urlStr, action string, payload interface{}) Step {
+	name := fmt.Sprintf("NewSOAPRequest(%v: %s: %s)", version, hc.redactor().String(urlStr), action)
+	return hc.step(name, func() error {
+		if err := hc.Reset(); err != nil {
+			return err
+		} else if body, err := soapEnvelope(version, payload); err != nil {
+			return err
+		} else if req, err := http.NewRequest("POST", urlStr, bytes.NewReader(body)); err != nil {
+			return err
+		} else {
+			if version == SOAP12 {
+				contentType := "application/soap+xml; charset=utf-8"
+				if action != "" {
+					contentType += fmt.Sprintf(`; action="%s"`, action)
+				}
+				req.Header.Set("Content-Type", contentType)
+			} else {
+				req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+				req.Header.Set("SOAPAction", fmt.Sprintf(`"%s"`, action))
+			}
+			hc.Request = req
+			hc.requestName = name
+			return nil
+		}
+	})
+}
+
+// SOAPBody ensures there is a non-nil hc.ResponseBody, parses it as a
+// SOAP envelope (of either version) and returns the XML content of
+// its Body element. If the Body contains a Fault, the fault is
+// returned too.
+func (hc *HttpCall) SOAPBody() ([]byte, *SOAPFault, error) {
+	if err := hc.ReceiveBody(); err != nil {
+		return nil, nil, err
+	}
+	envelope := new(soapEnvelopeXML)
+	if err := xml.Unmarshal(hc.ResponseBody, envelope); err != nil {
+		return nil, nil, fmt.Errorf("SOAP: Unable to parse envelope: %v", err)
+	} else if envelope.XMLName.Local != "Envelope" {
+		return nil, nil, fmt.Errorf("SOAP: Expected an Envelope; found %s.", envelope.XMLName.Local)
+	}
+	inner := bytes.TrimSpace(envelope.Body.Inner)
+	decoder := xml.NewDecoder(bytes.NewReader(inner))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			// An empty Body, or only character data.
+			return inner, nil, nil
+		} else if start, ok := token.(xml.StartElement); !ok {
+			continue
+		} else if start.Name.Local != "Fault" {
+			return inner, nil, nil
+		} else {
+			faultXML := new(soapFaultXML)
+			if err := decoder.DecodeElement(faultXML, &start); err != nil {
+				return nil, nil, fmt.Errorf("SOAP: Unable to parse Fault: %v", err)
+			}
+			fault := &SOAPFault{
+				Code:   faultXML.FaultCode,
+				Reason: faultXML.FaultString,
+				Detail: strings.TrimSpace(faultXML.Detail11.Inner),
+			}
+			if fault.Code == "" {
+				fault.Code = faultXML.Code.Value
+			}
+			if fault.Reason == "" && len(faultXML.Reason.Text) > 0 {
+				fault.Reason = faultXML.Reason.Text[0]
+			}
+			if fault.Detail == "" {
+				fault.Detail = strings.TrimSpace(faultXML.Detail12.Inner)
+			}
+			return inner, fault, nil
+		}
+	}
+}
+
+// soapBody is as SOAPBody but treats a Fault as an error.
+func (hc *HttpCall) soapBody() ([]byte, error) {
+	if body, fault, err := hc.SOAPBody(); err != nil {
+		return nil, err
+	} else if fault != nil {
+		return nil, fmt.Errorf("SOAP: Expected no Fault; found %v.", fault)
+	} else {
+		return body, nil
+	}
+}
+
+// ExpectNoSOAPFault is a Step that when executed ensures there is a
+// non-nil hc.ResponseBody and errors unless it is a SOAP envelope
+// whose Body does not contain a Fault.
+func (hc *HttpCall) ExpectNoSOAPFault() Step {
+	return hc.step("ExpectNoSOAPFault", func() error {
+		_, err := hc.soapBody()
+		return err
+	})
+}
+
+// ExpectSOAPFault is a Step that when executed ensures there is a
+// non-nil hc.ResponseBody and errors unless it is a SOAP envelope
+// whose Body contains a Fault with the given code. The code is
+// matched ignoring any namespace prefix, so "Client" matches
+// "soap:Client". If code is empty, any Fault is accepted.
+func (hc *HttpCall) ExpectSOAPFault(code string) Step {
+	return hc.step(fmt.Sprintf("ExpectSOAPFault(%s)", code), func() error {
+		if _, fault, err := hc.SOAPBody(); err != nil {
+			return err
+		} else if fault == nil {
+			return errors.New("SOAP: Expected a Fault; found none.")
+		} else if found := fault.Code; code != "" && found != code && !strings.HasSuffix(found, ":"+code) {
+			return fmt.Errorf("SOAP: Expected Fault code %s; found %v.", code, fault)
+		} else {
+			return nil
+		}
+	})
+}
+
+// SOAPBodyContains is a Step that when executed ensures there is a
+// non-nil hc.ResponseBody and errors unless it is a SOAP envelope
+// without a Fault whose Body content contains the value parameter
+// using strings.Contains.
+func (hc *HttpCall) SOAPBodyContains(value string) Step {
+	return hc.step("SOAPBodyContains", func() error {
+		if body, err := hc.soapBody(); err != nil {
+			return err
+		} else if !strings.Contains(string(body), value) {
+			return fmt.Errorf("SOAP Body: Expected '%s'; found '%s'.", value, string(body))
+		} else {
+			return nil
+		}
+	})
+}
+
+// SOAPBodyXMLMatchesStruct is a Step that when executed ensures there
+// is a non-nil hc.ResponseBody, unwraps the SOAP envelope, parses the
+// content of the Body as XML (via encoding/xml) based on the type of
+// the expected structure and errors unless it is equal to the
+// expected value, as validated by the pretty package. A Fault is
+// treated as an error.
+func (hc *HttpCall) SOAPBodyXMLMatchesStruct(expected interface{}) Step {
+	return hc.step("SOAPBodyXMLMatchesStruct", func() error {
+		parseAs := reflect.New(reflect.TypeOf(expected)).Interface()
+		if body, err := hc.soapBody(); err != nil {
+			return err
+		} else if err := xml.Unmarshal(body, parseAs); err != nil {
+			return err
+		} else if err := compareError(parseAs, expected); err != nil {
+			return err
+		} else {
+			return nil
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package argot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type soapAddResponse struct {
+	Result int `xml:"Result"`
+}
+
+func TestSOAP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/xml")
+		if r.Header.Get("SOAPAction") == `"urn:Add"` && strings.Contains(string(body), "<soap:Body><Add>") {
+			w.Write([]byte(`<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/">
+  <s:Body><AddResponse><Result>3</Result></AddResponse></s:Body>
+</s:Envelope>`))
+		} else if strings.HasPrefix(r.Header.Get("Content-Type"), "application/soap+xml") {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope">
+  <env:Body><env:Fault>
+    <env:Code><env:Value>env:Sender</env:Value></env:Code>
+    <env:Reason><env:Text xml:lang="en">Bad request</env:Text></env:Reason>
+  </env:Fault></env:Body>
+</env:Envelope>`))
+		}
+	}))
+	defer server.Close()
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+
+	Steps{
+		hc.NewSOAPRequest(SOAP11, server.URL, "urn:Add", "<Add><A>1</A><B>2</B></Add>"),
+		hc.ResponseStatusEquals(http.StatusOK),
+		hc.ExpectNoSOAPFault(),
+		hc.SOAPBodyContains("<Result>3</Result>"),
+		hc.SOAPBodyXMLMatchesStruct(soapAddResponse{Result: 3}),
+		ExpectError(hc.ExpectSOAPFault("")),
+
+		hc.NewSOAPRequest(SOAP12, server.URL, "urn:Add", "<Add/>"),
+		hc.ExpectSOAPFault("Sender"),
+		ExpectError(hc.ExpectSOAPFault("Receiver")),
+		ExpectError(hc.ExpectNoSOAPFault()),
+	}.Test(t)
+}
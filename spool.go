@@ -0,0 +1,111 @@
+package argot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// spoolBody writes head, followed by the rest of body, to a temporary
+// file which Reset removes.
+func (hc *HttpCall) spoolBody(head []byte, body io.Reader) error {
+	file, err := ioutil.TempFile("", "argot-body-")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	written, err := io.Copy(file, io.MultiReader(bytes.NewReader(head), body))
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	hc.spool = file.Name()
+	hc.spoolSize = written
+	return nil
+}
+
+// ResponseBodyReader ensures the body has been received and returns a
+// reader over it, whether it is held in hc.ResponseBody or was spooled
+// to disk (see SpoolThreshold). The reader must be closed.
+func (hc *HttpCall) ResponseBodyReader() (io.ReadCloser, error) {
+	if err := hc.receiveBody(); err != nil {
+		return nil, err
+	} else if hc.spool == "" {
+		return ioutil.NopCloser(bytes.NewReader(hc.ResponseBody)), nil
+	} else {
+		return os.Open(hc.spool)
+	}
+}
+
+// ResponseBodySize ensures the body has been received and returns its
+// size in bytes, whether or not it was spooled to disk.
+func (hc *HttpCall) ResponseBodySize() (int64, error) {
+	if err := hc.receiveBody(); err != nil {
+		return 0, err
+	} else if hc.spool == "" {
+		return int64(len(hc.ResponseBody)), nil
+	} else {
+		return hc.spoolSize, nil
+	}
+}
+
+// streamBody calls fn with a reader over the body.
+func (hc *HttpCall) streamBody(fn func(io.Reader) (bool, error)) (bool, error) {
+	if reader, err := hc.ResponseBodyReader(); err != nil {
+		return false, err
+	} else {
+		defer reader.Close()
+		return fn(reader)
+	}
+}
+
+// readerContains reports whether value occurs in r, reading r in
+// chunks and keeping enough of each chunk to find a value which spans
+// two.
+func readerContains(r io.Reader, value []byte) (bool, error) {
+	if len(value) == 0 {
+		return true, nil
+	}
+	chunk := make([]byte, 64*1024)
+	window := make([]byte, 0, len(chunk)+len(value))
+	for {
+		n, err := r.Read(chunk)
+		window = append(window, chunk[:n]...)
+		if bytes.Contains(window, value) {
+			return true, nil
+		} else if keep := len(value) - 1; len(window) > keep {
+			window = append(window[:0], window[len(window)-keep:]...)
+		}
+		if err == io.EOF {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+	}
+}
+
+// ResponseBodySHA256 is a Step that when executed ensures the body has
+// been received and errors unless its SHA-256 digest equals digest,
+// given in hex. The body is hashed as a stream, so this works whether
+// or not the body was spooled to disk (see SpoolThreshold).
+func (hc *HttpCall) ResponseBodySHA256(digest string) Step {
+	return hc.step(fmt.Sprintf("ResponseBodySHA256(%s)", digest), func() error {
+		hc.cover("body:")
+		hash := sha256.New()
+		if _, err := hc.streamBody(func(r io.Reader) (bool, error) {
+			_, err := io.Copy(hash, r)
+			return err == nil, err
+		}); err != nil {
+			return err
+		} else if found := hex.EncodeToString(hash.Sum(nil)); found != strings.ToLower(digest) {
+			return fmt.Errorf("Body: Expected SHA-256 %s; found %s.", digest, found)
+		} else {
+			return nil
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package argot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSpoolBody(t *testing.T) {
+	// The needle spans the boundary between two chunks of the search.
+	body := strings.Repeat("a", 64*1024-3) + "needle" + strings.Repeat("b", 100*1024)
+	digest := sha256.Sum256([]byte(body))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/small" {
+			w.Write([]byte("small needle"))
+		} else {
+			w.Write([]byte(body))
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	hc.SpoolThreshold = 1024
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseBodyContains("needle"),
+		ExpectError(hc.ResponseBodyContains("haystack")),
+		hc.ResponseBodyMatches(regexp.MustCompile(`a+needleb+$`)),
+		ExpectError(hc.ResponseBodyMatches(regexp.MustCompile(`^b`))),
+		hc.ResponseBodySHA256(hex.EncodeToString(digest[:])),
+		ExpectError(hc.ResponseBodySHA256("00")),
+		hc.ResponseSizeUnder(int64(len(body) + 1)),
+		ExpectError(hc.ResponseBodyEquals(body)),
+	}.Test(t)
+	if hc.ResponseBody != nil {
+		t.Fatal("Expected the body to be spooled; found it in memory.")
+	}
+	spool := hc.spool
+	if _, err := os.Stat(spool); err != nil {
+		t.Fatal(err)
+	}
+	hc.Reset()
+	if _, err := os.Stat(spool); !os.IsNotExist(err) {
+		t.Fatalf("Expected the spool file to be removed; found %v.", err)
+	}
+
+	hc.SpoolThreshold = 1024
+	Steps{
+		hc.NewRequest("GET", server.URL+"/small", nil),
+		hc.ResponseBodyContains("needle"),
+		hc.ResponseBodyEquals("small needle"),
+	}.Test(t)
+	if hc.spool != "" {
+		t.Fatal("Expected a small body to be held in memory.")
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 4096)))
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	hc.MaxBodySize = 4096
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseSizeUnder(4097),
+	}.Test(t)
+	hc.Reset()
+
+	hc.MaxBodySize = 4095
+	err := Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseBodyContains("x"),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "Expected at most 4095 bytes") {
+		t.Fatalf("Expected the body to exceed MaxBodySize; found %v.", err)
+	}
+	hc.Reset()
+
+	hc.SpoolThreshold = 1024
+	err = Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseBodyContains("x"),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "Expected at most 4095 bytes") || hc.spool != "" {
+		t.Fatalf("Expected the spooled body to exceed MaxBodySize; found %v.", err)
+	}
+}
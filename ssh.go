@@ -0,0 +1,111 @@
+package argot
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSHTarget is a remote host on which CommandCall.RunSSH runs
+// commands. Commands are run with the ssh client (OpenSSH), so that
+// authentication works as it does for ssh at the command line: with
+// IdentityFile, or otherwise with the keys of the ssh-agent at
+// $SSH_AUTH_SOCK and the default identities, and with the settings of
+// ~/.ssh/config. Password authentication is not possible, as ssh is run
+// in batch mode.
+type SSHTarget struct {
+	Host string
+	// If zero, the default port (normally 22) is used.
+	Port int
+	// If empty, the default user is used.
+	User string
+	// If not empty, the private key with which to authenticate; only
+	// it is offered.
+	IdentityFile string
+	// If not empty, the known hosts file against which the host's key
+	// is checked, instead of ~/.ssh/known_hosts.
+	KnownHostsFile string
+	// If true, the host's key is not checked. Use only against
+	// throwaway test machines.
+	InsecureIgnoreHostKey bool
+	// If non-zero, the timeout for connecting.
+	ConnectTimeout time.Duration
+	// Further ssh options, each as for -o, for example
+	// "ProxyJump=bastion".
+	Options []string
+	// The ssh client to run. If empty, "ssh" is used.
+	Binary string
+}
+
+func (target SSHTarget) String() string {
+	host := target.Host
+	if target.User != "" {
+		host = target.User + "@" + host
+	}
+	if target.Port != 0 {
+		host += ":" + strconv.Itoa(target.Port)
+	}
+	return host
+}
+
+// args returns the arguments with which to run command with ssh.
+func (target SSHTarget) args(command string) []string {
+	args := []string{"-o", "BatchMode=yes"}
+	if target.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(target.Port))
+	}
+	if target.User != "" {
+		args = append(args, "-l", target.User)
+	}
+	if target.IdentityFile != "" {
+		args = append(args, "-i", target.IdentityFile, "-o", "IdentitiesOnly=yes")
+	}
+	if target.InsecureIgnoreHostKey {
+		args = append(args, "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile="+os.DevNull)
+	} else if target.KnownHostsFile != "" {
+		args = append(args, "-o", "StrictHostKeyChecking=yes", "-o", "UserKnownHostsFile="+target.KnownHostsFile)
+	}
+	if target.ConnectTimeout > 0 {
+		seconds := int((target.ConnectTimeout + time.Second - 1) / time.Second)
+		args = append(args, "-o", "ConnectTimeout="+strconv.Itoa(seconds))
+	}
+	for _, option := range target.Options {
+		args = append(args, "-o", option)
+	}
+	return append(args, "--", target.Host, command)
+}
+
+// RunSSH is a Step that when executed creates a new command which runs
+// command, with the remote user's shell, on target over SSH, for
+// example to verify the state of a server after API calls. As with
+// RunCommand, the command is run when a step needs its result, and
+// steps such as ExitCodeEquals and StdoutContains assert on the
+// remote command's exit code and output. Failing to connect or
+// authenticate, which ssh reports with exit code 255, is an error.
+func (cc *CommandCall) RunSSH(target SSHTarget, command string) Step {
+	return NewNamedStep(fmt.Sprintf("RunSSH(%v: %s)", target, DefaultRedactor.String(command)), func() error {
+		if err := cc.Reset(); err != nil {
+			return err
+		}
+		binary := target.Binary
+		if binary == "" {
+			binary = "ssh"
+		}
+		cc.Cmd = exec.Command(binary, target.args(command)...)
+		cc.Cmd.Env = os.Environ()
+		cc.ssh = target.String()
+		return nil
+	})
+}
+
+// sshError returns the error of an ssh command which failed to
+// connect or authenticate, if it did.
+func (cc *CommandCall) sshError() error {
+	if cc.ssh == "" || cc.ExitCode != 255 {
+		return nil
+	}
+	return fmt.Errorf("SSH %s: %s", cc.ssh, strings.TrimSpace(string(cc.Stderr)))
+}
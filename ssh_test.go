@@ -0,0 +1,47 @@
+package argot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunSSH(t *testing.T) {
+	dir, err := ioutil.TempDir("", "argot-ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The fake ssh client prints its arguments, and fails to connect
+	// to unreachable.example.
+	binary := filepath.Join(dir, "ssh")
+	script := `#!/bin/sh
+for arg in "$@"; do
+	if [ "$arg" = unreachable.example ]; then
+		echo "ssh: connect to host unreachable.example port 22: Connection refused" >&2
+		exit 255
+	fi
+done
+echo "$@"
+exit 3
+`
+	if err := ioutil.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	target := SSHTarget{Host: "box.example", Port: 2222, User: "deploy", IdentityFile: "/keys/id_ed25519", InsecureIgnoreHostKey: true, ConnectTimeout: 1500 * time.Millisecond, Binary: binary}
+	cc := NewCommandCall()
+	Steps{
+		cc.RunSSH(target, "crontab -l"),
+		cc.ExitCodeEquals(3),
+		cc.StdoutEquals("-o BatchMode=yes -p 2222 -l deploy -i /keys/id_ed25519 -o IdentitiesOnly=yes -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null -o ConnectTimeout=2 -- box.example crontab -l\n"),
+	}.Test(t)
+
+	target.Host = "unreachable.example"
+	if err := (Steps{cc.RunSSH(target, "true"), cc.ExitCodeEquals(255)}).Go(); err == nil || !strings.Contains(err.Error(), "SSH deploy@unreachable.example:2222: ssh: connect to host unreachable.example port 22: Connection refused") {
+		t.Fatalf("Expected a connection failure; found %v.", err)
+	}
+}
@@ -0,0 +1,151 @@
+package argot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Transition is a transition of a StateMachine from the state From to
+// the state To, made by running Steps.
+type Transition struct {
+	Name  string
+	From  string
+	To    string
+	Steps Steps
+}
+
+// StateMachine is a model of a system, such as the lifecycle of a
+// resource (draft, published, archived), as states and the
+// transitions between them, for model-based testing: rather than
+// enumerating scenarios by hand, Explore runs every path of
+// transitions from the initial state up to a given depth. For
+// example:
+//
+//	sm := &argot.StateMachine{
+//		Initial: "draft",
+//		Reset:   createDraft,
+//		Transitions: []argot.Transition{
+//			{Name: "publish", From: "draft", To: "published", Steps: publish},
+//			{Name: "archive", From: "published", To: "archived", Steps: archive},
+//			{Name: "restore", From: "archived", To: "draft", Steps: restore},
+//		},
+//		Invariants: map[string]argot.Step{"published": expectPublic},
+//	}
+//	argot.Steps{sm.Explore(4)}.Test(t)
+//
+// The number of paths grows exponentially with depth.
+type StateMachine struct {
+	// The state in which every path starts.
+	Initial     string
+	Transitions []Transition
+	// If non-nil, run before each path to put the system into the
+	// Initial state, for example by creating a new resource.
+	Reset Step
+	// Steps run whenever the keyed state is entered, to check that the
+	// system is in that state.
+	Invariants map[string]Step
+}
+
+// Paths returns every path of transitions from the Initial state of
+// exactly depth transitions, or fewer if it reaches a state from which
+// there are no transitions. Shorter paths are not returned, as they
+// are prefixes of those that are.
+func (sm *StateMachine) Paths(depth int) [][]*Transition {
+	paths := [][]*Transition{}
+	var extend func(state string, path []*Transition)
+	extend = func(state string, path []*Transition) {
+		extended := false
+		if len(path) < depth {
+			for idx := range sm.Transitions {
+				if transition := &sm.Transitions[idx]; transition.From == state {
+					extended = true
+					extend(transition.To, append(path[:len(path):len(path)], transition))
+				}
+			}
+		}
+		if !extended && len(path) > 0 {
+			paths = append(paths, path)
+		}
+	}
+	extend(sm.Initial, nil)
+	return paths
+}
+
+// Explore is a Step that when executed runs every path of up to depth
+// transitions (see Paths), each after running Reset, checking the
+// invariant of every state entered, and errors at the first path
+// which fails. The error is a *StateMachineError which reports the
+// sequence of transitions.
+func (sm *StateMachine) Explore(depth int) Step {
+	return NewNamedStep(fmt.Sprintf("Explore(%s, %d)", sm.Initial, depth), func() error {
+		for _, path := range sm.Paths(depth) {
+			if err := sm.run(path); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// run runs path, returning a *StateMachineError should it fail.
+func (sm *StateMachine) run(path []*Transition) error {
+	fail := func(idx int, err error) error {
+		return &StateMachineError{Initial: sm.Initial, Path: path, Failed: idx, Err: err}
+	}
+	if sm.Reset != nil {
+		if err := sm.Reset.Go(); err != nil {
+			return fail(-1, err)
+		}
+	}
+	if invariant, found := sm.Invariants[sm.Initial]; found {
+		if err := invariant.Go(); err != nil {
+			return fail(-1, err)
+		}
+	}
+	for idx, transition := range path {
+		if err := transition.Steps.Go(); err != nil {
+			return fail(idx, err)
+		} else if invariant, found := sm.Invariants[transition.To]; found {
+			if err := invariant.Go(); err != nil {
+				return fail(idx, err)
+			}
+		}
+	}
+	return nil
+}
+
+// StateMachineError is the error returned by StateMachine.Explore when
+// a path fails. Failed is the index within Path of the transition
+// which failed (or into whose state the invariant failed), or -1 if
+// Reset or the invariant of the Initial state failed.
+type StateMachineError struct {
+	Initial string
+	Path    []*Transition
+	Failed  int
+	Err     error
+}
+
+func (e *StateMachineError) Error() string {
+	var failed string
+	if e.Failed < 0 {
+		failed = "Initial state " + e.Initial
+	} else {
+		failed = fmt.Sprintf("Transition %d (%s)", e.Failed+1, e.Path[e.Failed].Name)
+	}
+	return fmt.Sprintf("State machine: Path %s: %s: %s", e.PathString(), failed, strings.Replace(e.Err.Error(), "\n", "\n\t", -1))
+}
+
+// PathString returns the sequence of states and transitions of the
+// path, for example "draft -publish-> published -archive-> archived".
+func (e *StateMachineError) PathString() string {
+	parts := []string{e.Initial}
+	for _, transition := range e.Path {
+		parts = append(parts, fmt.Sprintf("-%s-> %s", transition.Name, transition.To))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Unwrap returns the error of the failed step.
+func (e *StateMachineError) Unwrap() error {
+	return e.Err
+}
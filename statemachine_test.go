@@ -0,0 +1,64 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStateMachineExplore(t *testing.T) {
+	visible, resets := false, 0
+	set := func(name string, public bool) Steps {
+		return Steps{NewNamedStep(name, func() error {
+			visible = public
+			return nil
+		})}
+	}
+	expectVisible := func(public bool) Step {
+		return NewNamedStep(fmt.Sprintf("expectVisible(%v)", public), func() error {
+			if visible != public {
+				return fmt.Errorf("Expected visible %v; found %v.", public, visible)
+			}
+			return nil
+		})
+	}
+	sm := &StateMachine{
+		Initial: "draft",
+		Reset: NewNamedStep("create", func() error {
+			resets++
+			visible = false
+			return nil
+		}),
+		Transitions: []Transition{
+			{Name: "publish", From: "draft", To: "published", Steps: set("publish", true)},
+			{Name: "archive", From: "published", To: "archived", Steps: set("archive", false)},
+			{Name: "restore", From: "archived", To: "draft", Steps: set("restore", false)},
+			{Name: "delete", From: "draft", To: "deleted", Steps: set("delete", false)},
+		},
+		Invariants: map[string]Step{
+			"draft":     expectVisible(false),
+			"published": expectVisible(true),
+			"archived":  expectVisible(false),
+		},
+	}
+
+	paths := sm.Paths(4)
+	if len(paths) != 3 {
+		t.Fatalf("Expected 3 paths; found %d.", len(paths))
+	}
+	Steps{sm.Explore(4)}.Test(t)
+	if resets != 3 {
+		t.Fatalf("Expected Reset to run before each path; found %d resets.", resets)
+	}
+
+	sm.Transitions[2].Steps = set("restore", true)
+	err := sm.Explore(4).Go()
+	var smErr *StateMachineError
+	if !errors.As(err, &smErr) {
+		t.Fatalf("Expected a StateMachineError; found %v", err)
+	} else if path := smErr.PathString(); path != "draft -publish-> published -archive-> archived -restore-> draft -publish-> published" || smErr.Failed != 2 {
+		t.Fatalf("Expected the restore transition of the path to fail; found %s (%d).", path, smErr.Failed)
+	} else if msg := err.Error(); msg != "State machine: Path "+path+": Transition 3 (restore): Expected visible false; found true." {
+		t.Fatalf("Unexpected error: %s", msg)
+	}
+}
@@ -0,0 +1,156 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Store is a set of named values shared between steps. Because steps
+// are constructed before any of them run, values produced by one step
+// (such as an id captured from a response) cannot be passed to later
+// steps as arguments; instead they are put in a Store and looked up,
+// or interpolated into strings (see Interpolate), when the later
+// steps run. A Store is safe for concurrent use.
+type Store struct {
+	lock   sync.RWMutex
+	values map[string]interface{}
+}
+
+// NewStore creates a new, empty, Store.
+func NewStore() *Store {
+	return &Store{values: make(map[string]interface{})}
+}
+
+// Set sets key to value.
+func (s *Store) Set(key string, value interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.values[key] = value
+}
+
+// Get returns the value of key, and whether it was found.
+func (s *Store) Get(key string) (interface{}, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	value, found := s.values[key]
+	return value, found
+}
+
+// GetString returns the value of key formatted with fmt.Sprint, or
+// the empty string if key is not found.
+func (s *Store) GetString(key string) string {
+	if value, found := s.Get(key); found {
+		return fmt.Sprint(value)
+	} else {
+		return ""
+	}
+}
+
+// Delete removes key.
+func (s *Store) Delete(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.values, key)
+}
+
+// Keys returns the keys of the store, sorted.
+func (s *Store) Keys() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Interpolate replaces every ${key} in str with the value of key
+// (formatted with fmt.Sprint). It errors if any key is not found. Use
+// $${ for a literal ${.
+func (s *Store) Interpolate(str string) (string, error) {
+	if !strings.Contains(str, "${") {
+		return str, nil
+	}
+	result := new(strings.Builder)
+	for {
+		start := strings.Index(str, "${")
+		if start < 0 {
+			result.WriteString(str)
+			return result.String(), nil
+		} else if start > 0 && str[start-1] == '$' {
+			result.WriteString(str[:start-1])
+			result.WriteString("${")
+			str = str[start+2:]
+			continue
+		}
+		end := strings.IndexByte(str[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("Interpolate: Unterminated ${ in '%s'.", str)
+		}
+		key := str[start+2 : start+end]
+		value, found := s.Get(key)
+		if !found {
+			return "", fmt.Errorf("Interpolate: '%s' not found in store.", key)
+		}
+		result.WriteString(str[:start])
+		result.WriteString(fmt.Sprint(value))
+		str = str[start+end+1:]
+	}
+}
+
+// SetStep is a Step that when executed sets key to value.
+func (s *Store) SetStep(key string, value interface{}) Step {
+	return NewNamedStep(fmt.Sprintf("StoreSet(%s)", key), func() error {
+		s.Set(key, value)
+		return nil
+	})
+}
+
+// ExpectEquals is a Step that when executed errors unless key is
+// found and its value, formatted with fmt.Sprint, equals expected.
+func (s *Store) ExpectEquals(key, expected string) Step {
+	return NewNamedStep(fmt.Sprintf("StoreExpectEquals(%s: %s)", key, expected), func() error {
+		if value, found := s.Get(key); !found {
+			return fmt.Errorf("Store: '%s' not found.", key)
+		} else if str := fmt.Sprint(value); str != expected {
+			return fmt.Errorf("Store: '%s': Expected '%s'; found '%s'.", key, expected, str)
+		} else {
+			return nil
+		}
+	})
+}
+
+// CaptureJSON is a Step that when executed ensures there is a non-nil
+// hc.ResponseBody, parses it as JSON and stores the value at path
+// (see JSONPath) as key in store.
+func (hc *HttpCall) CaptureJSON(store *Store, key, path string) Step {
+	return hc.step(fmt.Sprintf("CaptureJSON(%s: %s)", key, path), func() error {
+		if value, err := hc.responseJSONPath(path); err != nil {
+			return err
+		} else {
+			store.Set(key, value)
+			return nil
+		}
+	})
+}
+
+// CaptureHeader is a Step that when executed ensures there is a
+// non-nil hc.Response and stores the value of the response header
+// (which must be present) as key in store.
+func (hc *HttpCall) CaptureHeader(store *Store, key, header string) Step {
+	return hc.step(fmt.Sprintf("CaptureHeader(%s: %s)", key, header), func() error {
+		hc.coverHeader(header)
+		if err := hc.EnsureResponse(); err != nil {
+			return err
+		} else if values, found := hc.Response.Header[http.CanonicalHeaderKey(header)]; !found || len(values) == 0 {
+			return fmt.Errorf("Header '%s' not found.", header)
+		} else {
+			store.Set(key, values[0])
+			return nil
+		}
+	})
+}
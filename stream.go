@@ -0,0 +1,214 @@
+package argot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// StreamChunk is a piece of a response body as it was received by a
+// streaming step such as ResponseStream: the data returned by one read
+// of the body, which for a chunked or streamed response is typically
+// what the server flushed at once.
+type StreamChunk struct {
+	// The data received. It is only set whilst the chunk is being
+	// passed to a ResponseStream function, so that streams need not be
+	// held in memory.
+	Data []byte
+	// The size of the data.
+	Size int
+	// When the chunk was received, since the request was sent.
+	At time.Duration
+}
+
+// ErrStopStream may be returned by a ResponseStream function to stop
+// reading the stream without error. The rest of the body is discarded.
+var ErrStopStream = errors.New("stop stream")
+
+// Chunks returns the chunks received by streaming steps, without their
+// data.
+func (hc *HttpCall) Chunks() []StreamChunk {
+	return append([]StreamChunk(nil), hc.chunks...)
+}
+
+type streamRead struct {
+	data []byte
+	err  error
+	at   time.Time
+}
+
+// receiveStream ensures there is a response and reads its body
+// incrementally, calling fn (if non-nil) with each chunk, and recording
+// the chunks in hc.chunks. It errors if firstWithin is positive and
+// the first chunk is not received within firstWithin of the request
+// being sent. The body is not held: once streamed, steps which need
+// the body error. If the body has already been streamed, it succeeds
+// only if fn is nil.
+func (hc *HttpCall) receiveStream(firstWithin time.Duration, fn func(StreamChunk) error) error {
+	if err := hc.EnsureResponse(); err != nil {
+		return err
+	} else if hc.streamed && fn == nil {
+		return nil
+	} else if hc.streamed {
+		return errors.New("Stream: The body has already been streamed.")
+	} else if hc.ResponseBody != nil || hc.spool != "" {
+		return errors.New("Stream: The body has already been received; streaming steps must come before other body steps.")
+	}
+	hc.streamed = true
+	start := time.Now()
+	if hc.trace != nil {
+		start = hc.trace.start
+	}
+	var body io.Reader = hc.Response.Body
+	if hc.MaxBodySize > 0 {
+		body = &bodyLimitReader{Reader: body, limit: hc.MaxBodySize}
+	}
+	reads := make(chan streamRead)
+	stop := make(chan struct{})
+	go func() {
+		for {
+			buf := make([]byte, 32*1024)
+			n, err := body.Read(buf)
+			select {
+			case reads <- streamRead{data: buf[:n], err: err, at: time.Now()}:
+			case <-stop:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		hc.Response.Body.Close()
+	}()
+
+	var timeout <-chan time.Time
+	if firstWithin > 0 {
+		timer := time.NewTimer(firstWithin - time.Since(start))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		var read streamRead
+		select {
+		case read = <-reads:
+		case <-timeout:
+			return fmt.Errorf("Stream: Expected the first chunk within %v; found none.", firstWithin)
+		}
+		if len(read.data) > 0 {
+			chunk := StreamChunk{Data: read.data, Size: len(read.data), At: read.at.Sub(start)}
+			if len(hc.chunks) == 0 && firstWithin > 0 && chunk.At > firstWithin {
+				return fmt.Errorf("Stream: Expected the first chunk within %v; found it after %v.", firstWithin, chunk.At)
+			}
+			timeout = nil
+			if fn != nil {
+				if err := fn(chunk); err == ErrStopStream {
+					chunk.Data = nil
+					hc.chunks = append(hc.chunks, chunk)
+					return nil
+				} else if err != nil {
+					return fmt.Errorf("Stream: Chunk %d: %v", len(hc.chunks)+1, err)
+				}
+			}
+			chunk.Data = nil
+			hc.chunks = append(hc.chunks, chunk)
+		}
+		if read.err == io.EOF {
+			if hc.trace != nil {
+				hc.trace.mark(&hc.trace.bodyDone)()
+			}
+			return nil
+		} else if read.err != nil {
+			return read.err
+		}
+	}
+}
+
+// ResponseStream is a Step that when executed ensures there is a
+// non-nil hc.Response and reads its body incrementally, calling fn
+// with each chunk as it arrives, and errors if fn does. If firstWithin
+// is positive, it errors unless the first chunk arrives within
+// firstWithin of the request being sent. The body is not held in
+// memory, so this must come before, and cannot be combined with,
+// steps which need the whole body; the chunks' sizes and times remain
+// available for ResponseChunkCount and Chunks.
+func (hc *HttpCall) ResponseStream(firstWithin time.Duration, fn func(StreamChunk) error) Step {
+	return hc.step(fmt.Sprintf("ResponseStream(%v)", firstWithin), func() error {
+		return hc.receiveStream(firstWithin, fn)
+	})
+}
+
+// ResponseFirstChunkWithin is a Step that when executed streams the
+// body (as for ResponseStream, if it has not already been streamed)
+// and errors unless its first chunk arrives within d of the request
+// being sent.
+func (hc *HttpCall) ResponseFirstChunkWithin(d time.Duration) Step {
+	return hc.step(fmt.Sprintf("ResponseFirstChunkWithin(%v)", d), func() error {
+		if !hc.streamed {
+			return hc.receiveStream(d, nil)
+		} else if len(hc.chunks) == 0 {
+			return fmt.Errorf("Stream: Expected the first chunk within %v; found no chunks.", d)
+		} else if at := hc.chunks[0].At; at > d {
+			return fmt.Errorf("Stream: Expected the first chunk within %v; found it after %v.", d, at)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ResponseChunkCount is a Step that when executed streams the body (as
+// for ResponseStream, if it has not already been streamed) and errors
+// unless it was received in at least min and at most max chunks. If
+// max is less than min, there is no maximum.
+func (hc *HttpCall) ResponseChunkCount(min, max int) Step {
+	return hc.step(fmt.Sprintf("ResponseChunkCount(%d, %d)", min, max), func() error {
+		if err := hc.receiveStream(0, nil); err != nil {
+			return err
+		} else if found := len(hc.chunks); found < min || max >= min && found > max {
+			return fmt.Errorf("Stream: Expected between %d and %d chunks; found %d.", min, max, found)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ResponseStreamNDJSON is a Step that when executed streams the body
+// (as for ResponseStream) as newline delimited JSON, validating each
+// line against schema (as for ResponseBodyJSONSchema) as it arrives,
+// and errors at the first line which does not validate, giving its
+// line number. Blank lines are ignored.
+func (hc *HttpCall) ResponseStreamNDJSON(schema string) Step {
+	return hc.step("ResponseStreamNDJSON", func() error {
+		compiled, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema))
+		if err != nil {
+			return err
+		}
+		lineNo := 0
+		partial := []byte{}
+		validate := func(line []byte) error {
+			lineNo++
+			return validateNDJSONLine(compiled, lineNo, line)
+		}
+		if err := hc.receiveStream(0, func(chunk StreamChunk) error {
+			partial = append(partial, chunk.Data...)
+			for {
+				idx := bytes.IndexByte(partial, '\n')
+				if idx < 0 {
+					return nil
+				} else if err := validate(partial[:idx]); err != nil {
+					return err
+				}
+				partial = partial[idx+1:]
+			}
+		}); err != nil {
+			return err
+		}
+		return validate(partial)
+	})
+}
@@ -0,0 +1,73 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponseStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for idx := 1; idx <= 3; idx++ {
+			if r.URL.Path == "/bad" && idx == 2 {
+				fmt.Fprint(w, `{"id": "two"}`+"\n")
+			} else {
+				fmt.Fprintf(w, `{"id": %d}`+"\n", idx)
+			}
+			w.(http.Flusher).Flush()
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+	schema := `{"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}}}`
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	lines := []string{}
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseStream(time.Second, func(chunk StreamChunk) error {
+			lines = append(lines, strings.TrimSpace(string(chunk.Data)))
+			return nil
+		}),
+		hc.ResponseChunkCount(3, 3),
+		ExpectError(hc.ResponseChunkCount(4, -1)),
+		hc.ResponseFirstChunkWithin(time.Second),
+		ExpectError(hc.ResponseBodyContains("id")),
+		hc.ResponseStatusEquals(200),
+	}.Test(t)
+	if len(lines) != 3 || lines[2] != `{"id": 3}` {
+		t.Fatalf("Expected 3 chunks, one per line; found %q.", lines)
+	}
+	if chunks := hc.Chunks(); chunks[2].At < chunks[0].At+30*time.Millisecond || chunks[0].Data != nil {
+		t.Fatalf("Expected the chunks to be timed as received, without data; found %+v.", chunks)
+	}
+
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseStreamNDJSON(schema),
+		hc.NewRequest("GET", server.URL+"/slow", nil),
+		ExpectError(hc.ResponseFirstChunkWithin(50 * time.Millisecond)),
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseStream(0, func(chunk StreamChunk) error { return ErrStopStream }),
+		hc.ResponseChunkCount(1, 1),
+		hc.NewRequest("GET", server.URL, nil),
+		ExpectError(hc.ResponseStream(0, func(chunk StreamChunk) error { return errors.New("nope") })),
+	}.Test(t)
+
+	err := Steps{
+		hc.NewRequest("GET", server.URL+"/bad", nil),
+		hc.ResponseStreamNDJSON(schema),
+	}.Go()
+	if err == nil || !strings.Contains(err.Error(), "NDJSON: Line 2") {
+		t.Fatalf("Expected line 2 to fail validation; found %v.", err)
+	}
+}
@@ -0,0 +1,117 @@
+package argot
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// unknownJSONFields returns a Difference for every object member within
+// doc, a decoded JSON document, which would be ignored when decoding
+// doc into a value of type t: members of objects decoded into structs
+// which match none of the struct's fields. Values decoded into
+// interface{}, or by an UnmarshalJSON or UnmarshalText method, may
+// contain anything. Members are matched to fields as by encoding/json,
+// so case-insensitively. The values of unknown members are not
+// reported, as they may be data which should not have been exposed.
+func unknownJSONFields(doc interface{}, t reflect.Type, path string) []Difference {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface || reflect.PtrTo(t).Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return nil
+	}
+	diffs := []Difference{}
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if t.Kind() == reflect.Map {
+			for _, key := range keys {
+				diffs = append(diffs, unknownJSONFields(v[key], t.Elem(), joinPath(path, key))...)
+			}
+		} else if t.Kind() == reflect.Struct {
+			fields := structJSONFields(t)
+			for _, key := range keys {
+				if field, found := fields[strings.ToLower(key)]; !found {
+					diffs = append(diffs, Difference{Path: joinPath(path, key), Kind: DifferenceUnexpected})
+				} else {
+					diffs = append(diffs, unknownJSONFields(v[key], field, joinPath(path, key))...)
+				}
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for idx, elem := range v {
+				diffs = append(diffs, unknownJSONFields(elem, t.Elem(), fmt.Sprintf("%s[%d]", path, idx))...)
+			}
+		}
+	}
+	return diffs
+}
+
+// structJSONFields returns the types of the fields of the struct type
+// t, including those promoted from embedded structs, keyed by their
+// lower-cased JSON names.
+func structJSONFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		tag := field.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if tag == "-" {
+			continue
+		} else if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for key, fieldType := range structJSONFields(embedded) {
+					if _, found := fields[key]; !found {
+						fields[key] = fieldType
+					}
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+		fields[strings.ToLower(name)] = field.Type
+	}
+	return fields
+}
+
+// unknownFieldsError returns nil if body, once normalised, contains no
+// members unknown to t (see unknownJSONFields), and otherwise a
+// StepError listing them.
+func (hc *HttpCall) unknownFieldsError(body []byte, t reflect.Type) error {
+	if doc, err := hc.normalise(json.RawMessage(body)); err != nil {
+		return err
+	} else if diffs := unknownJSONFields(doc, t, ""); len(diffs) == 0 {
+		return nil
+	} else {
+		paths := make([]string, len(diffs))
+		for idx, diff := range diffs {
+			paths[idx] = "'" + diff.Path + "'"
+		}
+		return &StepError{
+			Message:     fmt.Sprintf("Unexpected fields not in %v: %s.", t, strings.Join(paths, ", ")),
+			Differences: diffs,
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type strictAudit struct {
+	Created time.Time `json:"created"`
+}
+
+type strictUser struct {
+	strictAudit
+	ID       int               `json:"id"`
+	Name     string            `json:"name"`
+	Roles    []strictRole      `json:"roles"`
+	Labels   map[string]string `json:"labels"`
+	Extra    interface{}       `json:"extra"`
+	Internal string            `json:"-"`
+}
+
+type strictRole struct {
+	Name string
+}
+
+func TestJSONDisallowUnknownFields(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ID": 1, "name": "ann", "created": "2020-01-02T03:04:05Z", "roles": [{"name": "admin"}, {"name": "ops", "grantedBy": 7}], "labels": {"team": "core"}, "extra": {"anything": true}, "passwordHash": "x", "Internal": "y"}`))
+	}))
+	defer server.Close()
+
+	expected := strictUser{
+		strictAudit: strictAudit{Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		ID:          1,
+		Name:        "ann",
+		Roles:       []strictRole{{"admin"}, {"ops"}},
+		Labels:      map[string]string{"team": "core"},
+		Extra:       map[string]interface{}{"anything": true},
+	}
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseBodyJSONMatchesStruct(expected),
+	}.Test(t)
+
+	hc.JSONDisallowUnknownFields = true
+	err := hc.ResponseBodyJSONMatchesStruct(expected).Go()
+	if err == nil || !strings.Contains(err.Error(), "'Internal', 'passwordHash', 'roles[1].grantedBy'") || strings.Contains(err.Error(), `"x"`) {
+		t.Fatalf("Expected the unknown fields to be listed; found %v", err)
+	} else if diffs := Differences(err); len(diffs) != 3 || diffs[1] != (Difference{Path: "passwordHash", Kind: DifferenceUnexpected}) {
+		t.Fatalf("Expected the unknown fields as Differences; found %+v", diffs)
+	}
+
+	hc.JSONNormalisers = []JSONNormaliser{DropPaths("passwordHash", "Internal", "roles[*].grantedBy")}
+	Steps{
+		hc.ResponseBodyJSONMatchesStruct(expected),
+	}.Test(t)
+}
@@ -0,0 +1,115 @@
+package argot
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+)
+
+// The names of the scenarios recorded by Bootstrap and Teardown.
+const (
+	BootstrapScenario = "bootstrap"
+	TeardownScenario  = "teardown"
+)
+
+var (
+	suiteLock       sync.Mutex
+	bootstrapResult *ScenarioResult
+	teardownResult  *ScenarioResult
+)
+
+// Bootstrap runs steps, as the scenario "bootstrap" (see RunScenario),
+// to set up the global fixtures, such as a tenant or the schema of a
+// database, on which every scenario in the test binary relies. It is
+// idempotent: only the first call runs steps, and every call returns
+// the result of the first, so it is safe to call it both from TestMain
+// (see RunMain) and from the tests which need the fixtures. Its result
+// is included in SuiteResults, so that it can be reported alongside
+// those of the scenarios.
+func Bootstrap(steps Steps) *ScenarioResult {
+	suiteLock.Lock()
+	defer suiteLock.Unlock()
+	if bootstrapResult == nil {
+		bootstrapResult = RunScenario(BootstrapScenario, steps)
+	}
+	return bootstrapResult
+}
+
+// Teardown runs steps, as the scenario "teardown", to remove the
+// global fixtures created by Bootstrap. As with Bootstrap, only the
+// first call runs steps, and every call returns the result of the
+// first. The steps are run whether or not Bootstrap succeeded, so they
+// should tolerate fixtures which were never created.
+func Teardown(steps Steps) *ScenarioResult {
+	suiteLock.Lock()
+	defer suiteLock.Unlock()
+	if teardownResult == nil {
+		teardownResult = RunScenario(TeardownScenario, steps)
+	}
+	return teardownResult
+}
+
+// SuiteResults returns the results of Bootstrap and Teardown, of those
+// which have run, so that they can be passed to reporters such as
+// WriteJSONReport with the results of the scenarios.
+func SuiteResults() []*ScenarioResult {
+	suiteLock.Lock()
+	defer suiteLock.Unlock()
+	results := []*ScenarioResult{}
+	if bootstrapResult != nil {
+		results = append(results, bootstrapResult)
+	}
+	if teardownResult != nil {
+		results = append(results, teardownResult)
+	}
+	return results
+}
+
+// RequireBootstrap calls t.Fatal unless Bootstrap has run and
+// succeeded.
+func RequireBootstrap(t *testing.T) {
+	t.Helper()
+	suiteLock.Lock()
+	result := bootstrapResult
+	suiteLock.Unlock()
+	if result == nil {
+		t.Fatal("Bootstrap has not run.")
+	} else if !result.Passed() {
+		t.Fatalf("Bootstrap failed: %v", result.Err)
+	}
+}
+
+// RunMain runs the tests of m between Bootstrap of bootstrap and
+// Teardown of teardown, and returns the exit code for os.Exit, for
+// use in TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(argot.RunMain(m, bootstrap, teardown))
+//	}
+//
+// If Bootstrap fails, the tests are not run. Teardown is run
+// regardless. The failure of either is written to stderr, and makes
+// the exit code non-zero.
+func RunMain(m *testing.M, bootstrap, teardown Steps) int {
+	return runMain(os.Stderr, m.Run, bootstrap, teardown)
+}
+
+func runMain(w io.Writer, run func() int, bootstrap, teardown Steps) int {
+	code := 1
+	if result := Bootstrap(bootstrap); result.Passed() {
+		code = run()
+	} else {
+		writeSuiteFailure(w, result)
+	}
+	if result := Teardown(teardown); !result.Passed() {
+		writeSuiteFailure(w, result)
+		code = 1
+	}
+	return code
+}
+
+func writeSuiteFailure(w io.Writer, result *ScenarioResult) {
+	fmt.Fprintf(w, "FAIL %s (%v) at %s: %v\n", result.Name, result.Duration, result.FailedStep().Name, result.Err)
+}
@@ -0,0 +1,62 @@
+package argot
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func resetSuite() {
+	suiteLock.Lock()
+	defer suiteLock.Unlock()
+	bootstrapResult = nil
+	teardownResult = nil
+}
+
+func TestSuiteHooks(t *testing.T) {
+	defer resetSuite()
+	resetSuite()
+	calls := []string{}
+	record := func(name string, err error) Step {
+		return NewNamedStep(name, func() error {
+			calls = append(calls, name)
+			return err
+		})
+	}
+
+	buf := new(bytes.Buffer)
+	code := runMain(buf, func() int {
+		calls = append(calls, "tests")
+		Bootstrap(Steps{record("again", nil)})
+		return 0
+	}, Steps{record("createTenant", nil), record("migrate", nil)}, Steps{record("deleteTenant", nil)})
+	if code != 0 || buf.Len() != 0 {
+		t.Fatalf("Expected success; found %d: %s", code, buf)
+	} else if strings.Join(calls, ",") != "createTenant,migrate,tests,deleteTenant" {
+		t.Fatalf("Unexpected calls: %v", calls)
+	}
+	results := SuiteResults()
+	if len(results) != 2 || results[0].Name != BootstrapScenario || len(results[0].Steps) != 2 || results[1].Name != TeardownScenario {
+		t.Fatalf("Unexpected results: %v", results)
+	}
+	RequireBootstrap(t)
+
+	resetSuite()
+	calls = nil
+	code = runMain(buf, func() int {
+		calls = append(calls, "tests")
+		return 0
+	}, Steps{record("createTenant", nil), record("migrate", errors.New("Boom"))}, Steps{record("deleteTenant", errors.New("Gone"))})
+	if code != 1 {
+		t.Fatalf("Expected failure; found %d", code)
+	} else if strings.Join(calls, ",") != "createTenant,migrate,deleteTenant" {
+		t.Fatalf("Expected the tests to be skipped and teardown run; found %v", calls)
+	} else if out := buf.String(); !strings.Contains(out, "FAIL bootstrap (") || !strings.Contains(out, ") at migrate: Boom\n") ||
+		!strings.Contains(out, "FAIL teardown (") || !strings.Contains(out, ") at deleteTenant: Gone\n") {
+		t.Fatalf("Unexpected output: %s", out)
+	}
+	if results := SuiteResults(); len(results) != 2 || results[0].Passed() || results[1].Passed() {
+		t.Fatalf("Unexpected results: %v", results)
+	}
+}
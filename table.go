@@ -0,0 +1,78 @@
+package argot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Case is one case of a data-driven table of scenarios (see RunTable
+// and TestTable): its parameters, by name. The parameters are recorded
+// as structured fields of the scenario's result, events and failure
+// output, so that failures can be grouped by them.
+type Case map[string]string
+
+// String returns the parameters as "key=value" pairs in key order.
+func (c Case) String() string {
+	pairs := make([]string, 0, len(c))
+	for _, key := range sortedKeys(c) {
+		pairs = append(pairs, key+"="+c[key])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// redacted returns the parameters with their values redacted by
+// DefaultRedactor.
+func (c Case) redacted() Case {
+	if c == nil {
+		return nil
+	}
+	redacted := make(Case, len(c))
+	for key, value := range c {
+		redacted[key] = DefaultRedactor.String(value)
+	}
+	return redacted
+}
+
+// format renders the parameters one per line, for failure output.
+func (c Case) format() string {
+	msg := "Parameters:\n"
+	for _, key := range sortedKeys(c) {
+		msg += fmt.Sprintf("\t%s: %s\n", key, c[key])
+	}
+	return DefaultRedactor.String(msg)
+}
+
+// caseName returns the name of the scenario of a case: name followed
+// by the redacted parameters in brackets.
+func caseName(name string, c Case) string {
+	return fmt.Sprintf("%s[%v]", name, c.redacted())
+}
+
+// RunTable runs a scenario for each case, as RunScenario does, with
+// the steps returned by build for the case. Each scenario is named
+// after name and the case's redacted parameters, and its result
+// records the parameters (see ScenarioResult.Params), as do its
+// events.
+func RunTable(name string, cases []Case, build func(c Case) Steps) []*ScenarioResult {
+	results := make([]*ScenarioResult, 0, len(cases))
+	for _, c := range cases {
+		results = append(results, runScenario(caseName(name, c), c, build(c)))
+	}
+	return results
+}
+
+// TestTable runs the steps returned by build for each case as a
+// subtest of t (see testing.T.Run), named after the case's redacted
+// parameters in key order. Should a case fail, its parameters are listed in the
+// failure output, ahead of the steps.
+func TestTable(t *testing.T, cases []Case, build func(c Case) Steps) {
+	for _, c := range cases {
+		c := c
+		t.Run(c.redacted().String(), func(t *testing.T) {
+			if results, err := build(c).Test(nil); err != nil {
+				t.Fatal(c.format() + formatFatalSteps(results, err))
+			}
+		})
+	}
+}
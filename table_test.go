@@ -0,0 +1,61 @@
+package argot
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRunTable(t *testing.T) {
+	cases := []Case{
+		{"method": "GET", "role": "admin"},
+		{"method": "DELETE", "role": "guest"},
+	}
+	build := func(c Case) Steps {
+		return Steps{NewNamedStep("authorise", func() error {
+			if c["role"] == "guest" && c["method"] != "GET" {
+				return errors.New("Expected 200; found 403.")
+			}
+			return nil
+		})}
+	}
+
+	events := []Event{}
+	remove := AddEventListener(func(event Event) {
+		if event.Type == ScenarioFinished {
+			events = append(events, event)
+		}
+	})
+	results := RunTable("authorisation", cases, build)
+	remove()
+
+	if len(results) != 2 || results[0].Name != "authorisation[method=GET, role=admin]" || !results[0].Passed() {
+		t.Fatalf("Unexpected results: %+v", results)
+	} else if results[1].Passed() || results[1].Params["role"] != "guest" {
+		t.Fatalf("Unexpected result: %+v", results[1])
+	} else if len(events) != 2 || events[1].Params["method"] != "DELETE" {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteJSONReport(buf, results); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(buf.String(), `"parameters": {
+        "method": "DELETE",
+        "role": "guest"
+      }`) {
+		t.Fatalf("Unexpected report:\n%s", buf)
+	}
+
+	if msg := cases[1].format(); msg != "Parameters:\n\tmethod: DELETE\n\trole: guest\n" {
+		t.Fatalf("Unexpected failure output: %q", msg)
+	}
+
+	secret := Case{"auth": "Bearer hunter2"}
+	if name := caseName("login", secret); name != "login[auth=Bearer REDACTED]" {
+		t.Fatalf("Expected the parameters in the name to be redacted; found %q", name)
+	}
+
+	TestTable(t, cases[:1], build)
+}
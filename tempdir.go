@@ -0,0 +1,161 @@
+package argot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// TempDir is a temporary directory for on-disk fixtures, such as
+// files to upload or a destination for downloads. The directory is
+// created by NewTempDir, so its paths (see Path) can be passed to
+// steps when they are constructed; files within it are created by
+// steps. Call Cleanup (typically deferred) to remove it.
+type TempDir struct {
+	// The absolute path of the directory.
+	Dir string
+}
+
+// NewTempDir creates a new temporary directory whose name begins with
+// prefix.
+func NewTempDir(prefix string) (*TempDir, error) {
+	if dir, err := ioutil.TempDir("", prefix); err != nil {
+		return nil, err
+	} else {
+		return &TempDir{Dir: dir}, nil
+	}
+}
+
+// Cleanup removes the directory and everything within it.
+func (td *TempDir) Cleanup() error {
+	return os.RemoveAll(td.Dir)
+}
+
+// Path returns the path of rel within the directory. rel uses forward
+// slashes.
+func (td *TempDir) Path(rel string) string {
+	return filepath.Join(td.Dir, filepath.FromSlash(rel))
+}
+
+// WriteFile is a Step that when executed writes contents to the file
+// rel, creating any parent directories.
+func (td *TempDir) WriteFile(rel string, contents []byte) Step {
+	return NewNamedStep(fmt.Sprintf("WriteFile(%s)", rel), func() error {
+		path := td.Path(rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, contents, 0644)
+	})
+}
+
+// CopyFile is a Step that when executed copies the file src (for
+// example from testdata) to rel, creating any parent directories.
+func (td *TempDir) CopyFile(src, rel string) Step {
+	return NewNamedStep(fmt.Sprintf("CopyFile(%s: %s)", src, rel), func() error {
+		if contents, err := ioutil.ReadFile(src); err != nil {
+			return err
+		} else {
+			return td.WriteFile(rel, contents).Go()
+		}
+	})
+}
+
+// Mkdir is a Step that when executed creates the directory rel and
+// any parents.
+func (td *TempDir) Mkdir(rel string) Step {
+	return NewNamedStep(fmt.Sprintf("Mkdir(%s)", rel), func() error {
+		return os.MkdirAll(td.Path(rel), 0755)
+	})
+}
+
+// Remove is a Step that when executed removes rel and anything
+// within it.
+func (td *TempDir) Remove(rel string) Step {
+	return NewNamedStep(fmt.Sprintf("Remove(%s)", rel), func() error {
+		return os.RemoveAll(td.Path(rel))
+	})
+}
+
+// ExpectFileExists is a Step that when executed errors unless rel
+// exists.
+func (td *TempDir) ExpectFileExists(rel string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectFileExists(%s)", rel), func() error {
+		_, err := os.Stat(td.Path(rel))
+		return err
+	})
+}
+
+// ExpectFileNotExists is a Step that when executed errors unless rel
+// does not exist.
+func (td *TempDir) ExpectFileNotExists(rel string) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectFileNotExists(%s)", rel), func() error {
+		if _, err := os.Stat(td.Path(rel)); err == nil {
+			return fmt.Errorf("Expected '%s' not to exist; found.", rel)
+		} else if !os.IsNotExist(err) {
+			return err
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectFileContents is a Step that when executed errors unless the
+// contents of rel equal contents.
+func (td *TempDir) ExpectFileContents(rel string, contents []byte) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectFileContents(%s)", rel), func() error {
+		if found, err := ioutil.ReadFile(td.Path(rel)); err != nil {
+			return err
+		} else if !bytes.Equal(found, contents) {
+			return diffError(rel, string(contents), string(found), "File '%s': Diff: '%s'.", rel, diff(string(contents), string(found)))
+		} else {
+			return nil
+		}
+	})
+}
+
+// SaveResponseBody is a Step that when executed ensures there is a
+// non-nil hc.ResponseBody and writes it to the file rel, creating any
+// parent directories.
+func (td *TempDir) SaveResponseBody(hc *HttpCall, rel string) Step {
+	return hc.step(fmt.Sprintf("SaveResponseBody(%s)", rel), func() error {
+		if err := hc.ReceiveBody(); err != nil {
+			return err
+		} else {
+			return td.WriteFile(rel, hc.ResponseBody).Go()
+		}
+	})
+}
+
+// Reader returns an io.Reader of the file rel which opens the file
+// when first read and closes it at EOF. As the file need not exist
+// until the reader is used, it can be given to steps (such as
+// HttpCall.NewRequest) constructed before the file is written.
+func (td *TempDir) Reader(rel string) io.Reader {
+	return &lazyFileReader{path: td.Path(rel)}
+}
+
+type lazyFileReader struct {
+	path string
+	file *os.File
+	err  error
+}
+
+func (lfr *lazyFileReader) Read(p []byte) (int, error) {
+	if lfr.err != nil {
+		return 0, lfr.err
+	} else if lfr.file == nil {
+		if lfr.file, lfr.err = os.Open(lfr.path); lfr.err != nil {
+			return 0, lfr.err
+		}
+	}
+	n, err := lfr.file.Read(p)
+	if err != nil {
+		lfr.file.Close()
+		lfr.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,69 @@
+package argot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTempDir(t *testing.T) {
+	if _, err := NewTempDir("bad/prefix"); err == nil {
+		t.Fatal("Expected a prefix containing a path separator to be rejected.")
+	}
+	td, err := NewTempDir("argot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer td.Cleanup()
+	if !filepath.IsAbs(td.Dir) || filepath.Base(td.Dir)[:5] != "argot" {
+		t.Fatalf("Unexpected directory: %s", td.Dir)
+	} else if path := td.Path("a/b.txt"); path != filepath.Join(td.Dir, "a", "b.txt") {
+		t.Fatalf("Unexpected path: %s", path)
+	}
+
+	src := filepath.Join(td.Dir, "src.txt")
+	if err := ioutil.WriteFile(src, []byte("fixture"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("downloaded"))
+	}))
+	defer server.Close()
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+
+	Steps{
+		td.WriteFile("nested/dir/file.txt", []byte("contents")),
+		td.ExpectFileExists("nested/dir/file.txt"),
+		td.ExpectFileContents("nested/dir/file.txt", []byte("contents")),
+		ExpectError(td.ExpectFileContents("nested/dir/file.txt", []byte("other"))),
+		ExpectError(td.ExpectFileContents("missing.txt", []byte("contents"))),
+		// A file cannot be the parent of another.
+		ExpectError(td.WriteFile("nested/dir/file.txt/child", []byte("contents"))),
+
+		td.CopyFile(src, "copies/copy.txt"),
+		td.ExpectFileContents("copies/copy.txt", []byte("fixture")),
+		ExpectError(td.CopyFile(filepath.Join(td.Dir, "missing.txt"), "copies/missing.txt")),
+		td.ExpectFileNotExists("copies/missing.txt"),
+
+		hc.NewRequest("GET", server.URL, nil),
+		td.SaveResponseBody(hc, "downloads/body.txt"),
+		td.ExpectFileContents("downloads/body.txt", []byte("downloaded")),
+		ExpectError(td.SaveResponseBody(hc, "nested/dir/file.txt/body.txt")),
+
+		td.Remove("nested"),
+		td.ExpectFileNotExists("nested/dir/file.txt"),
+		ExpectError(td.ExpectFileExists("nested")),
+	}.Test(t)
+
+	if err := td.Cleanup(); err != nil {
+		t.Fatal(err)
+	} else if _, err := os.Stat(td.Dir); !os.IsNotExist(err) {
+		t.Fatalf("Expected the directory to be removed; found %v", err)
+	} else if err := td.Cleanup(); err != nil {
+		t.Fatalf("Expected Cleanup to be idempotent; found %v", err)
+	}
+}
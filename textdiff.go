@@ -0,0 +1,183 @@
+package argot
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// DiffMode selects the units in which strings are compared by the
+// steps which report a diff, such as ResponseBodyEquals.
+type DiffMode int
+
+const (
+	// DiffCharacters compares strings character by character, where a
+	// character is a grapheme cluster: a base character together with
+	// any combining marks, variation selectors, emoji modifiers and
+	// zero width joined characters which follow it. Multibyte and
+	// combined characters are therefore never split.
+	DiffCharacters DiffMode = iota
+	// DiffLines compares strings line by line, which is clearer for
+	// large multi-line bodies.
+	DiffLines
+)
+
+// StringDiffMode is the DiffMode used to diff strings in failure
+// messages.
+var StringDiffMode = DiffCharacters
+
+// diff diffs two strings, using StringDiffMode, as a coloured string:
+// the expected parts will be removed/red if they're missing, the
+// found/inserted parts will be green if present, if the parts are the
+// same, no colour is applied. Control characters within the strings,
+// other than newlines and tabs, are escaped, as are invalid UTF-8
+// bytes.
+func diff(expected string, got string) string {
+	return diffIn(StringDiffMode, expected, got)
+}
+
+func diffIn(mode DiffMode, expected string, got string) string {
+	split := splitGraphemes
+	if mode == DiffLines {
+		split = splitLines
+	}
+	units := []string{}
+	ids := make(map[string]rune)
+	// encode maps each unit of text to a distinct rune, so that the
+	// runes can be diffed as a whole.
+	encode := func(text string) []rune {
+		runes := []rune{}
+		for _, unit := range split(text) {
+			id, found := ids[unit]
+			if !found {
+				id = diffRune(len(units))
+				ids[unit] = id
+				units = append(units, unit)
+			}
+			runes = append(runes, id)
+		}
+		return runes
+	}
+	dmp := diffmatchpatch.New()
+	expectedRunes, gotRunes := encode(expected), encode(got)
+	var diffs []diffmatchpatch.Diff
+	decode := len(units) <= diffRunes
+	if decode {
+		diffs = dmp.DiffMainRunes(expectedRunes, gotRunes, false)
+	} else {
+		// Too many distinct units to encode: fall back to runes.
+		diffs = dmp.DiffMain(expected, got, false)
+	}
+	buf := new(strings.Builder)
+	for _, d := range diffs {
+		text := new(strings.Builder)
+		if decode {
+			for _, id := range d.Text {
+				text.WriteString(escapeControl(units[diffUnit(id)]))
+			}
+		} else {
+			text.WriteString(escapeControl(d.Text))
+		}
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			fmt.Fprintf(buf, "\x1b[32m%s\x1b[0m", text)
+		case diffmatchpatch.DiffDelete:
+			fmt.Fprintf(buf, "\x1b[31m%s\x1b[0m", text)
+		default:
+			buf.WriteString(text.String())
+		}
+	}
+	return buf.String()
+}
+
+// diffRunes is the number of distinct units which can be diffed: the
+// number of valid runes other than surrogates, which do not survive
+// conversion to and from strings.
+const diffRunes = unicode.MaxRune + 1 - 0x800
+
+func diffRune(unit int) rune {
+	if unit < 0xD800 {
+		return rune(unit)
+	} else {
+		return rune(unit + 0x800)
+	}
+}
+
+func diffUnit(id rune) int {
+	if id < 0xD800 {
+		return int(id)
+	} else {
+		return int(id) - 0x800
+	}
+}
+
+// splitLines splits text into lines, each retaining its newline.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// splitGraphemes splits text into approximate grapheme clusters. Each
+// invalid UTF-8 byte is a cluster of its own.
+func splitGraphemes(text string) []string {
+	clusters := []string{}
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		end, joined := size, false
+		if r == '\r' && strings.HasPrefix(text, "\r\n") {
+			end++
+		} else if isRegionalIndicator(r) {
+			if next, nextSize := utf8.DecodeRuneInString(text[end:]); isRegionalIndicator(next) {
+				end += nextSize
+			}
+		} else if r != utf8.RuneError || size > 1 {
+			for end < len(text) {
+				next, nextSize := utf8.DecodeRuneInString(text[end:])
+				if next == utf8.RuneError && nextSize <= 1 {
+					break
+				} else if joined || next == '\u200d' || unicode.In(next, unicode.Mn, unicode.Me, unicode.Mc) || next >= 0x1F3FB && next <= 0x1F3FF {
+					joined = next == '\u200d'
+					end += nextSize
+				} else {
+					break
+				}
+			}
+		}
+		clusters = append(clusters, text[:end])
+		text = text[end:]
+	}
+	return clusters
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// escapeControl escapes the control characters in text, other than
+// newlines and tabs, and invalid UTF-8 bytes, so that they cannot
+// corrupt the output in which the text appears.
+func escapeControl(text string) string {
+	buf := new(strings.Builder)
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		if r == utf8.RuneError && size == 1 {
+			fmt.Fprintf(buf, `\x%02x`, text[0])
+		} else if r == '\n' || r == '\t' || !unicode.IsControl(r) {
+			buf.WriteString(text[:size])
+		} else if r == '\r' {
+			buf.WriteString(`\r`)
+		} else if r < utf8.RuneSelf {
+			fmt.Fprintf(buf, `\x%02x`, r)
+		} else {
+			fmt.Fprintf(buf, `\u%04x`, r)
+		}
+		text = text[size:]
+	}
+	return buf.String()
+}
@@ -0,0 +1,30 @@
+package argot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffUnicode(t *testing.T) {
+	for _, test := range []struct {
+		mode                  DiffMode
+		expected, got, result string
+	}{
+		{DiffCharacters, "thumbs 👍🏽", "thumbs 👎🏽", "thumbs \x1b[31m👍🏽\x1b[0m\x1b[32m👎🏽\x1b[0m"},
+		{DiffCharacters, "café", "cafe", "caf\x1b[31mé\x1b[0m\x1b[32me\x1b[0m"},
+		{DiffCharacters, "🇬🇧🇫🇷", "🇬🇧🇩🇪", "🇬🇧\x1b[31m🇫🇷\x1b[0m\x1b[32m🇩🇪\x1b[0m"},
+		{DiffCharacters, `{"name": "Zoë"}`, "{\"name\": \"Zo\xeb\"}", "{\"name\": \"Zo\x1b[31më\x1b[0m\x1b[32m\\xeb\x1b[0m\"}"},
+		{DiffCharacters, "ok", "ok\x1b[2J\r\n", "ok\x1b[32m\\x1b[2J\\r\n\x1b[0m"},
+		{DiffLines, "a\nb\nc\n", "a\nbb\nc\n", "a\n\x1b[31mb\n\x1b[0m\x1b[32mbb\n\x1b[0mc\n"},
+	} {
+		if result := diffIn(test.mode, test.expected, test.got); result != test.result {
+			t.Fatalf("Diff of %q and %q: Expected %q; found %q.", test.expected, test.got, test.result, result)
+		}
+	}
+
+	StringDiffMode = DiffLines
+	defer func() { StringDiffMode = DiffCharacters }()
+	if result := diff("one\ntwo", "one\nthree"); !strings.Contains(result, "\x1b[32mthree\x1b[0m") {
+		t.Fatalf("Expected StringDiffMode to select line diffs; found %q.", result)
+	}
+}
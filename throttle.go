@@ -0,0 +1,198 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ThrottledTransport is an http.RoundTripper which simulates a slow
+// network: it delays every request, and limits the rate at which
+// request and response bodies are transferred. It records how each
+// response body was transferred so that steps can assert on it.
+type ThrottledTransport struct {
+	// Transport performs the requests. If nil, http.DefaultTransport
+	// is used.
+	Transport http.RoundTripper
+	// Latency is added before every request is sent.
+	Latency time.Duration
+	// BytesPerSecond limits the rate at which bodies are transferred
+	// in each direction. If zero, bodies are not throttled.
+	BytesPerSecond int
+
+	lock sync.Mutex
+	last *TransferStats
+}
+
+// TransferStats describes the transfer of a response body.
+type TransferStats struct {
+	// Started is when the request was sent.
+	Started time.Time
+	// Headers is when the response headers were received.
+	Headers time.Time
+	// FirstByte is when the first byte of the body was received.
+	FirstByte time.Time
+	// Finished is when the body was read to its end (or failed).
+	Finished time.Time
+	// Bytes is the number of body bytes received.
+	Bytes int64
+	// Complete is true iff the body was read to its end.
+	Complete bool
+	// Err is any error, other than io.EOF, encountered whilst
+	// reading the body.
+	Err error
+}
+
+func (tt *ThrottledTransport) transport() http.RoundTripper {
+	if tt.Transport == nil {
+		return http.DefaultTransport
+	} else {
+		return tt.Transport
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (tt *ThrottledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	stats := &TransferStats{Started: time.Now()}
+	if tt.Latency > 0 {
+		select {
+		case <-time.After(tt.Latency):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	if req.Body != nil && tt.BytesPerSecond > 0 {
+		throttled := *req
+		throttled.Body = &throttledBody{ReadCloser: req.Body, bytesPerSecond: tt.BytesPerSecond}
+		req = &throttled
+	}
+	response, err := tt.transport().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	stats.Headers = time.Now()
+	response.Body = &throttledBody{ReadCloser: response.Body, bytesPerSecond: tt.BytesPerSecond, stats: stats, lock: &tt.lock}
+	tt.lock.Lock()
+	tt.last = stats
+	tt.lock.Unlock()
+	return response, nil
+}
+
+// LastTransfer returns a copy of the statistics of the most recent
+// response, or nil if there has been no response.
+func (tt *ThrottledTransport) LastTransfer() *TransferStats {
+	tt.lock.Lock()
+	defer tt.lock.Unlock()
+	if tt.last == nil {
+		return nil
+	}
+	stats := *tt.last
+	return &stats
+}
+
+type throttledBody struct {
+	io.ReadCloser
+	bytesPerSecond int
+	started        time.Time
+	total          int64
+	stats          *TransferStats
+	lock           *sync.Mutex
+}
+
+func (tb *throttledBody) Read(p []byte) (int, error) {
+	if tb.bytesPerSecond > 0 {
+		if tb.started.IsZero() {
+			tb.started = time.Now()
+		}
+		// Read in small chunks so that the transfer is smooth.
+		if chunk := tb.bytesPerSecond/10 + 1; len(p) > chunk {
+			p = p[:chunk]
+		}
+	}
+	n, err := tb.ReadCloser.Read(p)
+	tb.total += int64(n)
+	if n > 0 && tb.stats != nil {
+		tb.lock.Lock()
+		if tb.stats.FirstByte.IsZero() {
+			tb.stats.FirstByte = time.Now()
+		}
+		tb.lock.Unlock()
+	}
+	if tb.bytesPerSecond > 0 {
+		due := tb.started.Add(time.Duration(tb.total) * time.Second / time.Duration(tb.bytesPerSecond))
+		time.Sleep(time.Until(due))
+	}
+	if tb.stats != nil {
+		tb.lock.Lock()
+		now := time.Now()
+		tb.stats.Bytes = tb.total
+		if err == io.EOF {
+			tb.stats.Complete = true
+			tb.stats.Finished = now
+		} else if err != nil {
+			tb.stats.Err = err
+			tb.stats.Finished = now
+		}
+		tb.lock.Unlock()
+	}
+	return n, err
+}
+
+func (tt *ThrottledTransport) lastFinished() (*TransferStats, error) {
+	if stats := tt.LastTransfer(); stats == nil {
+		return nil, errors.New("Transfer: no response received.")
+	} else if stats.Err != nil {
+		return nil, fmt.Errorf("Transfer: failed after %d bytes: %v", stats.Bytes, stats.Err)
+	} else if !stats.Complete {
+		return nil, fmt.Errorf("Transfer: incomplete after %d bytes: the body has not been read to its end.", stats.Bytes)
+	} else {
+		return stats, nil
+	}
+}
+
+// ExpectTransferComplete is a Step that when executed errors unless
+// the most recent response body was read to its end without error. It
+// should be used after the body has been received (for example after
+// a ResponseBody step, or after HttpCall.ReceiveBody).
+func (tt *ThrottledTransport) ExpectTransferComplete() Step {
+	return NewNamedStep("ExpectTransferComplete", func() error {
+		_, err := tt.lastFinished()
+		return err
+	})
+}
+
+// ExpectFirstByteWithin is a Step that when executed errors unless the
+// first byte of the most recent response body arrived within limit of
+// the request being sent. This can be used to check that a handler
+// starts streaming promptly even to a slow client.
+func (tt *ThrottledTransport) ExpectFirstByteWithin(limit time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectFirstByteWithin(%v)", limit), func() error {
+		if stats, err := tt.lastFinished(); err != nil {
+			return err
+		} else if stats.FirstByte.IsZero() {
+			return errors.New("Transfer: empty body.")
+		} else if elapsed := stats.FirstByte.Sub(stats.Started); elapsed > limit {
+			return fmt.Errorf("Transfer: Expected first byte within %v; took %v.", limit, elapsed)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectTransferWithin is a Step that when executed errors unless the
+// most recent response body was completely received within limit of
+// the request being sent.
+func (tt *ThrottledTransport) ExpectTransferWithin(limit time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectTransferWithin(%v)", limit), func() error {
+		if stats, err := tt.lastFinished(); err != nil {
+			return err
+		} else if elapsed := stats.Finished.Sub(stats.Started); elapsed > limit {
+			return fmt.Errorf("Transfer: Expected completion within %v; took %v.", limit, elapsed)
+		} else {
+			return nil
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package argot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThrottledTransport(t *testing.T) {
+	body := strings.Repeat("x", 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/empty" {
+			return
+		}
+		received, _ := ioutil.ReadAll(r.Body)
+		w.Write(append(received, body...))
+	}))
+	defer server.Close()
+
+	tt := &ThrottledTransport{Latency: 50 * time.Millisecond, BytesPerSecond: 5000}
+	if tt.LastTransfer() != nil {
+		t.Fatal("Expected no transfer before the first response.")
+	}
+	hc := NewHttpCall(&http.Client{Transport: tt})
+	defer hc.Reset()
+	Steps{
+		ExpectError(tt.ExpectTransferComplete()),
+		hc.NewRequest("GET", server.URL, nil),
+		hc.Call(),
+		// The body has not yet been read.
+		ExpectError(tt.ExpectTransferComplete()),
+		hc.ResponseBodyEquals(body),
+		tt.ExpectTransferComplete(),
+		tt.ExpectFirstByteWithin(5 * time.Second),
+		ExpectError(tt.ExpectFirstByteWithin(10 * time.Millisecond)),
+		tt.ExpectTransferWithin(5 * time.Second),
+		ExpectError(tt.ExpectTransferWithin(100 * time.Millisecond)),
+	}.Test(t)
+
+	stats := tt.LastTransfer()
+	if stats.Bytes != 1000 || !stats.Complete || stats.Err != nil {
+		t.Fatalf("Unexpected transfer: %+v", stats)
+	} else if latency := stats.Headers.Sub(stats.Started); latency < 50*time.Millisecond {
+		t.Fatalf("Expected the latency to be added; response headers after %v.", latency)
+	} else if stats.FirstByte.Before(stats.Headers) || stats.Finished.Before(stats.FirstByte) {
+		t.Fatalf("Unexpected order of events: %+v", stats)
+	} else if elapsed := stats.Finished.Sub(stats.Headers); elapsed < 190*time.Millisecond {
+		// 1000 bytes at 5000 bytes per second.
+		t.Fatalf("Expected the body to take at least 200ms; took %v.", elapsed)
+	}
+
+	Steps{
+		hc.NewRequest("GET", server.URL+"/empty", nil),
+		hc.ResponseBodyEquals(""),
+		tt.ExpectTransferComplete(),
+		ExpectError(tt.ExpectFirstByteWithin(5 * time.Second)),
+	}.Test(t)
+}
+
+func TestThrottledBody(t *testing.T) {
+	tb := &throttledBody{ReadCloser: ioutil.NopCloser(strings.NewReader(strings.Repeat("x", 500))), bytesPerSecond: 5000}
+	started := time.Now()
+	buf := make([]byte, 1024)
+	total := 0
+	for {
+		n, err := tb.Read(buf)
+		if n > 5000/10+1 {
+			t.Fatalf("Expected reads of at most %d bytes; read %d.", 5000/10+1, n)
+		}
+		total += n
+		if err != nil {
+			break
+		}
+	}
+	if elapsed := time.Since(started); total != 500 || elapsed < 90*time.Millisecond {
+		// 500 bytes at 5000 bytes per second.
+		t.Fatalf("Expected 500 bytes in at least 100ms; read %d in %v.", total, elapsed)
+	}
+
+	// Unthrottled bodies are passed through.
+	tb = &throttledBody{ReadCloser: ioutil.NopCloser(strings.NewReader(strings.Repeat("x", 500)))}
+	if n, _ := tb.Read(buf); n != 500 {
+		t.Fatalf("Expected an unthrottled read of 500 bytes; read %d.", n)
+	}
+}
+
+func TestThrottledTransportRequestBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		ioutil.ReadAll(r.Body)
+		w.Write([]byte(time.Since(started).String()))
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(&http.Client{Transport: &ThrottledTransport{BytesPerSecond: 5000}})
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("POST", server.URL, strings.NewReader(strings.Repeat("x", 1000))),
+		hc.ResponseStatusEquals(http.StatusOK),
+	}.Test(t)
+	if err := hc.ReceiveBody(); err != nil {
+		t.Fatal(err)
+	} else if elapsed, err := time.ParseDuration(string(hc.ResponseBody)); err != nil {
+		t.Fatal(err)
+	} else if elapsed < 100*time.Millisecond {
+		// 1000 bytes at 5000 bytes per second, less the first chunk,
+		// which may have been sent with the headers.
+		t.Fatalf("Expected the request body to be throttled; received in %v.", elapsed)
+	}
+}
@@ -0,0 +1,190 @@
+package argot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TimeVariant is a set of inputs which may affect how a request's
+// times are interpreted or rendered, such as a time zone header, or a
+// date near a daylight saving transition. See
+// HttpCall.TimeFormatSweep.
+type TimeVariant struct {
+	Name string
+	// Headers set on the request.
+	Header http.Header
+	// Query parameters set on the request.
+	Query url.Values
+}
+
+// timeVariant returns a TimeVariant called name which sets value at
+// place: "header:<name>" or "query:<name>".
+func timeVariant(name, place, value string) (TimeVariant, error) {
+	variant := TimeVariant{Name: name, Header: http.Header{}, Query: url.Values{}}
+	kind, key, err := parsePlace(place)
+	if err != nil {
+		return variant, err
+	} else if kind == "header" {
+		variant.Header.Set(key, value)
+	} else {
+		variant.Query.Set(key, value)
+	}
+	return variant, nil
+}
+
+// TimeZoneVariants returns a TimeVariant for each of zones, such as
+// "America/New_York", which sends the zone at place: "header:<name>"
+// or "query:<name>". It errors if place is neither.
+func TimeZoneVariants(place string, zones ...string) ([]TimeVariant, error) {
+	variants := make([]TimeVariant, len(zones))
+	for idx, zone := range zones {
+		variant, err := timeVariant(zone, place, zone)
+		if err != nil {
+			return nil, err
+		}
+		variants[idx] = variant
+	}
+	return variants, nil
+}
+
+// DSTTransitions returns the instants during year at which the UTC
+// offset of loc changes, such as the starts and ends of daylight
+// saving time.
+func DSTTransitions(loc *time.Location, year int) []time.Time {
+	transitions := []time.Time{}
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	end := start.AddDate(1, 0, 0)
+	_, offset := start.Zone()
+	for t := start; t.Before(end); t = t.Add(15 * time.Minute) {
+		if _, next := t.Zone(); next != offset {
+			transitions = append(transitions, t)
+			offset = next
+		}
+	}
+	return transitions
+}
+
+// DSTVariants returns TimeVariants which send, at place ("header:<name>"
+// or "query:<name>"), the times 30 minutes before and after each
+// daylight saving transition of loc in year (see DSTTransitions),
+// formatted as RFC 3339 in loc. It errors if place is neither.
+func DSTVariants(place string, loc *time.Location, year int) ([]TimeVariant, error) {
+	if _, _, err := parsePlace(place); err != nil {
+		return nil, err
+	}
+	variants := []TimeVariant{}
+	for _, transition := range DSTTransitions(loc, year) {
+		for _, t := range []time.Time{transition.Add(-30 * time.Minute), transition.Add(30 * time.Minute)} {
+			value := t.In(loc).Format(time.RFC3339)
+			variant, err := timeVariant(fmt.Sprintf("%s %s", loc, value), place, value)
+			if err != nil {
+				return nil, err
+			}
+			variants = append(variants, variant)
+		}
+	}
+	return variants, nil
+}
+
+var timestampPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[Tt ]\d{2}:\d{2}`)
+
+// timestampViolation returns why str, which looks like a timestamp, is
+// not an RFC 3339 UTC timestamp, or the empty string if it is one.
+func timestampViolation(str string) string {
+	if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+		return "not RFC 3339"
+	} else if !strings.HasSuffix(str, "Z") {
+		return "not UTC"
+	} else {
+		return ""
+	}
+}
+
+// TimeFormatSweep is a Step that when executed replays the request
+// built by earlier steps once for each variant, with the variant's
+// headers and query parameters, and errors unless every string in
+// every JSON response which looks like a timestamp (a date and a time)
+// is an RFC 3339 timestamp in UTC, such as "2024-03-31T01:30:00Z",
+// whatever the time zone of the inputs. The violations are aggregated
+// by field (see JSONField), giving the values of each variant which
+// violated it. For example:
+//
+//	london, _ := time.LoadLocation("Europe/London")
+//	zones, _ := argot.TimeZoneVariants("header:Time-Zone", "UTC", "America/New_York", "Asia/Kolkata")
+//	dst, _ := argot.DSTVariants("query:from", london, 2024)
+//	Steps{
+//		hc.NewRequest("GET", url, nil),
+//		hc.TimeFormatSweep(append(zones, dst...)...),
+//	}
+//
+// Like Call, it must be used after the request has been built, and
+// before any step which needs the response. hc is left holding the
+// response to the last variant.
+func (hc *HttpCall) TimeFormatSweep(variants ...TimeVariant) Step {
+	return hc.step(fmt.Sprintf("TimeFormatSweep(%d variants)", len(variants)), func() error {
+		if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
+			return err
+		}
+		template := hc.Request
+		body, err := RequestBodyBytes(template)
+		if err != nil {
+			return err
+		}
+		failures := []string{}
+		violations := make(map[string][]string)
+		for _, variant := range variants {
+			hc.replay(template, body)
+			for key, values := range variant.Header {
+				hc.Request.Header[key] = values
+			}
+			if len(variant.Query) != 0 {
+				query := hc.Request.URL.Query()
+				for key, values := range variant.Query {
+					query[key] = values
+				}
+				hc.Request.URL.RawQuery = query.Encode()
+			}
+			var doc interface{}
+			if err := hc.ReceiveBody(); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", variant.Name, err))
+				continue
+			}
+			decoder := json.NewDecoder(bytes.NewReader(hc.ResponseBody))
+			decoder.UseNumber()
+			if err := decoder.Decode(&doc); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: Body: Expected JSON: %v", variant.Name, err))
+				continue
+			}
+			WalkJSON(doc, func(path string, value interface{}) error {
+				if str, ok := value.(string); ok && timestampPattern.MatchString(str) {
+					hc.coverJSONPath(path)
+					if violation := timestampViolation(str); violation != "" {
+						field := JSONField(path)
+						violations[field] = append(violations[field], fmt.Sprintf("%s: '%s' is %s.", variant.Name, str, violation))
+					}
+				}
+				return nil
+			})
+		}
+
+		fields := make([]string, 0, len(violations))
+		for field := range violations {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			failures = append(failures, fmt.Sprintf("%s:\n\t\t%s", field, strings.Join(violations[field], "\n\t\t")))
+		}
+		if len(failures) != 0 {
+			return fmt.Errorf("Time formats: Expected RFC 3339 UTC timestamps:\n\t%s", strings.Join(failures, "\n\t"))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,86 @@
+package argot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWalkJSON(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"items":[{"id":1},{"id":2}],"name":"tea"}`), &doc)
+	paths := []string{}
+	WalkJSON(doc, func(path string, value interface{}) error {
+		if _, err := JSONPath(doc, path); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path+"="+JSONField(path))
+		return nil
+	})
+	if found := strings.Join(paths, " "); found != "= items=items items[0]=items[] items[0].id=items[].id items[1]=items[] items[1].id=items[].id name=name" {
+		t.Fatalf("Unexpected paths: %s", found)
+	}
+}
+
+func TestDSTTransitions(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip(err)
+	}
+	transitions := DSTTransitions(london, 2024)
+	if len(transitions) != 2 || !transitions[0].Equal(time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC)) ||
+		!transitions[1].Equal(time.Date(2024, 10, 27, 1, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Unexpected transitions: %v", transitions)
+	}
+	variants, err := DSTVariants("query:from", london, 2024)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(variants) != 4 || variants[0].Query.Get("from") != "2024-03-31T00:30:00Z" || variants[1].Query.Get("from") != "2024-03-31T02:30:00+01:00" {
+		t.Fatalf("Unexpected variants: %v", variants)
+	}
+	if _, err := DSTVariants("querry:from", london, 2024); err == nil || err.Error() != "Unknown placement 'querry:from'." {
+		t.Fatalf("Expected the placement to be rejected; found %v", err)
+	}
+	if _, err := TimeZoneVariants("querry:tz", "UTC"); err == nil || err.Error() != "Unknown placement 'querry:tz'." {
+		t.Fatalf("Expected the placement to be rejected; found %v", err)
+	}
+}
+
+func TestTimeFormatSweep(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		created := "2024-03-31T01:30:00Z"
+		if zone := r.Header.Get("Time-Zone"); zone != "" && zone != "UTC" {
+			// Renders times in the caller's zone.
+			loc, _ := time.LoadLocation(zone)
+			created = time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC).In(loc).Format(time.RFC3339)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[{"created":"` + created + `","day":"2024-03-31"},{"created":"2024-03-31T01:30:00.5Z"}],` +
+			`"updated":"2024-03-31 01:30","query":"` + r.URL.Query().Get("from") + `"}`))
+	}))
+	defer server.Close()
+
+	variants, err := TimeZoneVariants("header:Time-Zone", "UTC", "America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc := NewHttpCall(nil)
+	err = Steps{
+		hc.NewRequest(http.MethodGet, server.URL+"/teas", nil),
+		hc.TimeFormatSweep(variants...),
+	}.Go()
+	expected := "Time formats: Expected RFC 3339 UTC timestamps:\n" +
+		"\titems[].created:\n" +
+		"\t\tAmerica/New_York: '2024-03-30T21:30:00-04:00' is not UTC.\n" +
+		"\tupdated:\n" +
+		"\t\tUTC: '2024-03-31 01:30' is not RFC 3339.\n" +
+		"\t\tAmerica/New_York: '2024-03-31 01:30' is not RFC 3339."
+	if hcErr, ok := err.(*HttpCallError); !ok || hcErr.Err.Error() != expected {
+		t.Fatalf("Expected violations aggregated by field; found %v", err)
+	} else if zone := hc.Request.Header.Get("Time-Zone"); zone != "America/New_York" {
+		t.Fatalf("Expected hc to hold the last variant; found %s", zone)
+	}
+}
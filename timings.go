@@ -0,0 +1,203 @@
+package argot
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings records how long the phases of an HTTP call took, as
+// observed through net/http/httptrace. Phases which did not occur (for
+// example DNS and Connect when a connection is reused) are zero.
+type Timings struct {
+	// DNS is the duration of the DNS lookup.
+	DNS time.Duration
+	// Connect is the duration of establishing the TCP connection.
+	Connect time.Duration
+	// TLSHandshake is the duration of the TLS handshake.
+	TLSHandshake time.Duration
+	// TTFB (time to first byte) is the duration from the request
+	// starting to the first byte of the response being received.
+	TTFB time.Duration
+	// Total is the duration from the request starting to the response
+	// body being completely received. It is zero until the body has
+	// been received.
+	Total time.Duration
+}
+
+// callTrace collects httptrace events for a single call. Events may
+// arrive on several go-routines.
+type callTrace struct {
+	lock                sync.Mutex
+	start               time.Time
+	dnsStart, dnsDone   time.Time
+	connStart, connDone time.Time
+	tlsStart, tlsDone   time.Time
+	firstByte, bodyDone time.Time
+	conn                *httptrace.GotConnInfo
+}
+
+func newCallTrace() *callTrace {
+	return &callTrace{start: time.Now()}
+}
+
+func (ct *callTrace) mark(point *time.Time) func() {
+	return func() {
+		ct.lock.Lock()
+		defer ct.lock.Unlock()
+		if point.IsZero() {
+			*point = time.Now()
+		}
+	}
+}
+
+// traced returns a copy of req which reports events to ct.
+func (ct *callTrace) traced(req *http.Request) *http.Request {
+	trace := &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { ct.mark(&ct.dnsStart)() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { ct.mark(&ct.dnsDone)() },
+		ConnectStart:         func(string, string) { ct.mark(&ct.connStart)() },
+		ConnectDone:          func(string, string, error) { ct.mark(&ct.connDone)() },
+		TLSHandshakeStart:    ct.mark(&ct.tlsStart),
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { ct.mark(&ct.tlsDone)() },
+		GotFirstResponseByte: ct.mark(&ct.firstByte),
+		GotConn: func(info httptrace.GotConnInfo) {
+			ct.lock.Lock()
+			defer ct.lock.Unlock()
+			ct.conn = &info
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+func between(from, to time.Time) time.Duration {
+	if from.IsZero() || to.IsZero() {
+		return 0
+	} else {
+		return to.Sub(from)
+	}
+}
+
+func (ct *callTrace) timings() *Timings {
+	ct.lock.Lock()
+	defer ct.lock.Unlock()
+	return &Timings{
+		DNS:          between(ct.dnsStart, ct.dnsDone),
+		Connect:      between(ct.connStart, ct.connDone),
+		TLSHandshake: between(ct.tlsStart, ct.tlsDone),
+		TTFB:         between(ct.start, ct.firstByte),
+		Total:        between(ct.start, ct.bodyDone),
+	}
+}
+
+// Timings returns the timings of the current call, or nil if no
+// request has yet been made.
+func (hc *HttpCall) Timings() *Timings {
+	if hc.trace == nil {
+		return nil
+	} else {
+		return hc.trace.timings()
+	}
+}
+
+// timingUnder creates a Step which errors unless the duration picked
+// from the call's Timings is under limit.
+func (hc *HttpCall) timingUnder(phase string, limit time.Duration, needBody bool, pick func(*Timings) time.Duration) Step {
+	return hc.step(fmt.Sprintf("%sUnder(%v)", phase, limit), func() error {
+		if err := hc.EnsureResponse(); err != nil {
+			return err
+		} else if needBody {
+			if err := hc.receiveBody(); err != nil {
+				return err
+			}
+		}
+		if timings := hc.Timings(); timings == nil {
+			return errors.New("No timings recorded.")
+		} else if took := pick(timings); took >= limit {
+			return fmt.Errorf("%s: Expected under %v; took %v.", phase, limit, took)
+		} else {
+			return nil
+		}
+	})
+}
+
+// DNSUnder is a Step that when executed ensures there is a non-nil
+// hc.Response and errors unless the DNS lookup took less than limit.
+func (hc *HttpCall) DNSUnder(limit time.Duration) Step {
+	return hc.timingUnder("DNS", limit, false, func(t *Timings) time.Duration { return t.DNS })
+}
+
+// ConnectUnder is a Step that when executed ensures there is a
+// non-nil hc.Response and errors unless establishing the TCP
+// connection took less than limit.
+func (hc *HttpCall) ConnectUnder(limit time.Duration) Step {
+	return hc.timingUnder("Connect", limit, false, func(t *Timings) time.Duration { return t.Connect })
+}
+
+// TLSHandshakeUnder is a Step that when executed ensures there is a
+// non-nil hc.Response and errors unless the TLS handshake took less
+// than limit.
+func (hc *HttpCall) TLSHandshakeUnder(limit time.Duration) Step {
+	return hc.timingUnder("TLSHandshake", limit, false, func(t *Timings) time.Duration { return t.TLSHandshake })
+}
+
+// TTFBUnder is a Step that when executed ensures there is a non-nil
+// hc.Response and errors unless the first byte of the response
+// arrived less than limit after the request started.
+func (hc *HttpCall) TTFBUnder(limit time.Duration) Step {
+	return hc.timingUnder("TTFB", limit, false, func(t *Timings) time.Duration { return t.TTFB })
+}
+
+// TotalUnder is a Step that when executed ensures there is a non-nil
+// hc.ResponseBody and errors unless the whole call, up to the body
+// being received, took less than limit.
+func (hc *HttpCall) TotalUnder(limit time.Duration) Step {
+	return hc.timingUnder("Total", limit, true, func(t *Timings) time.Duration { return t.Total })
+}
+
+// ConnectionInfo returns information about the connection used by the
+// current call, or nil if no connection has been obtained (for
+// example because no request has yet been made, or because the
+// Transport does not use connections).
+func (hc *HttpCall) ConnectionInfo() *httptrace.GotConnInfo {
+	if hc.trace == nil {
+		return nil
+	}
+	hc.trace.lock.Lock()
+	defer hc.trace.lock.Unlock()
+	return hc.trace.conn
+}
+
+func (hc *HttpCall) connectionReused(expected bool, name string) Step {
+	return hc.step(name, func() error {
+		if err := hc.EnsureResponse(); err != nil {
+			return err
+		} else if info := hc.ConnectionInfo(); info == nil {
+			return errors.New("Connection: no connection information recorded.")
+		} else if info.Reused != expected && expected {
+			return errors.New("Connection: Expected a reused connection; found a new connection.")
+		} else if info.Reused != expected {
+			return fmt.Errorf("Connection: Expected a new connection; found a reused connection (idle for %v).", info.IdleTime)
+		} else {
+			return nil
+		}
+	})
+}
+
+// AssertConnectionReused is a Step that when executed ensures there
+// is a non-nil hc.Response and errors unless the request was sent
+// over a previously used (keep-alive) connection.
+func (hc *HttpCall) AssertConnectionReused() Step {
+	return hc.connectionReused(true, "AssertConnectionReused")
+}
+
+// AssertNewConnection is a Step that when executed ensures there is a
+// non-nil hc.Response and errors unless the request was sent over a
+// newly established connection.
+func (hc *HttpCall) AssertNewConnection() Step {
+	return hc.connectionReused(false, "AssertNewConnection")
+}
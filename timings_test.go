@@ -0,0 +1,85 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTimingsAndConnectionReuse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.AssertNewConnection(),
+		hc.TTFBUnder(time.Minute),
+		hc.TotalUnder(time.Minute),
+		hc.NewRequest("GET", server.URL, nil),
+		hc.AssertConnectionReused(),
+	}.Test(t)
+
+	if timings := hc.Timings(); timings == nil || timings.TTFB == 0 {
+		t.Fatalf("Expected TTFB to be recorded; found %v", timings)
+	} else if err := hc.TTFBUnder(0).Go(); err == nil {
+		t.Fatal("Expected TTFBUnder(0) to fail.")
+	}
+}
+
+func TestLatencyPercentiles(t *testing.T) {
+	var count int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// One slow request in twenty.
+		if atomic.AddInt64(&count, 1)%20 == 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("POST", server.URL, strings.NewReader("body")),
+		hc.LatencyPercentiles(40, 5, LatencyBudget{P50: 40 * time.Millisecond, P95: time.Second}),
+		ExpectError(hc.LatencyPercentiles(40, 0, LatencyBudget{Max: 40 * time.Millisecond})),
+	}.Test(t)
+	if count := atomic.LoadInt64(&count); count != 85 {
+		t.Fatalf("Expected 85 requests; found %d", count)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 1000)))
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	meter := NewTransferMeter()
+	result := RunScenario("transfer", Steps{
+		meter.Start(),
+		hc.NewRequest("POST", server.URL, strings.NewReader("hello")),
+		hc.ResponseSizeUnder(1001),
+		ExpectError(hc.ResponseSizeUnder(1000)),
+		hc.TransferUnder(2000),
+		meter.TotalTransferUnder(2000),
+		ExpectError(meter.TotalTransferUnder(1000)),
+	})
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	} else if transfer := hc.Transfer(); transfer.Sent <= 5 || transfer.Received <= 1000 {
+		t.Fatalf("Expected request and response to be counted; found %v", transfer)
+	} else if result.Transfer != meter.Transfer() || result.Transfer != transfer {
+		t.Fatalf("Expected scenario transfer %v to match call transfer %v", result.Transfer, transfer)
+	} else if step := result.Steps[2]; step.Transfer.Received <= 1000 {
+		t.Fatalf("Expected the body to be attributed to %s; found %v", step.Name, step.Transfer)
+	}
+}
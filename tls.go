@@ -0,0 +1,141 @@
+package argot
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// TLSState ensures there is a non-nil hc.Response and returns the
+// state of the TLS connection it was received on. It errors if the
+// response was not received over TLS.
+func (hc *HttpCall) TLSState() (*tls.ConnectionState, error) {
+	if err := hc.EnsureResponse(); err != nil {
+		return nil, err
+	} else if hc.Response.TLS == nil {
+		return nil, errors.New("TLS: Expected a TLS connection; found plain text.")
+	} else {
+		return hc.Response.TLS, nil
+	}
+}
+
+// certificate returns the server's leaf certificate.
+func (hc *HttpCall) certificate() (*x509.Certificate, error) {
+	if state, err := hc.TLSState(); err != nil {
+		return nil, err
+	} else if len(state.PeerCertificates) == 0 {
+		return nil, errors.New("TLS: No peer certificates.")
+	} else {
+		return state.PeerCertificates[0], nil
+	}
+}
+
+// TLSVersionAtLeast is a Step that when executed ensures there is a
+// non-nil hc.Response and errors unless it was received over TLS of
+// at least version (for example tls.VersionTLS12).
+func (hc *HttpCall) TLSVersionAtLeast(version uint16) Step {
+	return hc.step(fmt.Sprintf("TLSVersionAtLeast(%s)", tls.VersionName(version)), func() error {
+		if state, err := hc.TLSState(); err != nil {
+			return err
+		} else if state.Version < version {
+			return fmt.Errorf("TLS version: Expected at least %s; found %s.", tls.VersionName(version), tls.VersionName(state.Version))
+		} else {
+			return nil
+		}
+	})
+}
+
+// TLSCipherSuiteIn is a Step that when executed ensures there is a
+// non-nil hc.Response and errors unless the negotiated cipher suite
+// is one of suites (for example tls.TLS_AES_128_GCM_SHA256).
+func (hc *HttpCall) TLSCipherSuiteIn(suites ...uint16) Step {
+	names := make([]string, len(suites))
+	for idx, suite := range suites {
+		names[idx] = tls.CipherSuiteName(suite)
+	}
+	return hc.step(fmt.Sprintf("TLSCipherSuiteIn(%s)", strings.Join(names, ", ")), func() error {
+		state, err := hc.TLSState()
+		if err != nil {
+			return err
+		}
+		for _, suite := range suites {
+			if state.CipherSuite == suite {
+				return nil
+			}
+		}
+		return fmt.Errorf("TLS cipher suite: Expected one of %s; found %s.", strings.Join(names, ", "), tls.CipherSuiteName(state.CipherSuite))
+	})
+}
+
+// CertificateValidFor is a Step that when executed ensures there is a
+// non-nil hc.Response and errors unless the server's certificate is
+// currently valid and will not expire within d. This catches
+// certificates which are about to expire before they do.
+func (hc *HttpCall) CertificateValidFor(d time.Duration) Step {
+	return hc.step(fmt.Sprintf("CertificateValidFor(%v)", d), func() error {
+		now := time.Now()
+		if cert, err := hc.certificate(); err != nil {
+			return err
+		} else if now.Before(cert.NotBefore) {
+			return fmt.Errorf("Certificate: Not valid until %v.", cert.NotBefore)
+		} else if deadline := now.Add(d); deadline.After(cert.NotAfter) {
+			return fmt.Errorf("Certificate expiry: Expected after %v; found %v.", deadline.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))
+		} else {
+			return nil
+		}
+	})
+}
+
+// CertificateIssuerContains is a Step that when executed ensures there
+// is a non-nil hc.Response and errors unless the distinguished name of
+// the issuer of the server's certificate (for example
+// "CN=R3,O=Let's Encrypt,C=US") contains substr.
+func (hc *HttpCall) CertificateIssuerContains(substr string) Step {
+	return hc.step(fmt.Sprintf("CertificateIssuerContains(%s)", substr), func() error {
+		if cert, err := hc.certificate(); err != nil {
+			return err
+		} else if issuer := cert.Issuer.String(); !strings.Contains(issuer, substr) {
+			return fmt.Errorf("Certificate issuer: Expected to contain %q; found %q.", substr, issuer)
+		} else {
+			return nil
+		}
+	})
+}
+
+// CertificateSANsInclude is a Step that when executed ensures there is
+// a non-nil hc.Response and errors unless the server's certificate
+// lists every one of names as a subject alternative name. Names may
+// be DNS names (matched exactly, so wildcards must be given as such)
+// or IP addresses.
+func (hc *HttpCall) CertificateSANsInclude(names ...string) Step {
+	return hc.step(fmt.Sprintf("CertificateSANsInclude(%s)", strings.Join(names, ", ")), func() error {
+		cert, err := hc.certificate()
+		if err != nil {
+			return err
+		}
+		sans := make(map[string]bool)
+		for _, name := range cert.DNSNames {
+			sans[strings.ToLower(name)] = true
+		}
+		for _, ip := range cert.IPAddresses {
+			sans[ip.String()] = true
+		}
+		missing := []string{}
+		for _, name := range names {
+			if ip := net.ParseIP(name); ip != nil {
+				name = ip.String()
+			}
+			if !sans[strings.ToLower(name)] {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) != 0 {
+			return fmt.Errorf("Certificate SANs: Expected to include %s; found DNS %v, IP %v.", strings.Join(missing, ", "), cert.DNSNames, cert.IPAddresses)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,34 @@
+package argot
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTLSAssertions(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+
+	hc := NewHttpCall(server.Client())
+	defer hc.Reset()
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.TLSVersionAtLeast(tls.VersionTLS12),
+		hc.TLSCipherSuiteIn(tls.TLS_AES_128_GCM_SHA256, tls.TLS_AES_256_GCM_SHA384, tls.TLS_CHACHA20_POLY1305_SHA256),
+		ExpectError(hc.TLSCipherSuiteIn(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)),
+		hc.CertificateValidFor(365 * 24 * time.Hour),
+		ExpectError(hc.CertificateValidFor(100 * 365 * 24 * time.Hour)),
+		hc.CertificateIssuerContains("O=Acme Co"),
+		ExpectError(hc.CertificateIssuerContains("Let's Encrypt")),
+		hc.CertificateSANsInclude("example.com", "127.0.0.1"),
+		ExpectError(hc.CertificateSANsInclude("example.org")),
+
+		hc.NewRequest("GET", plain.URL, nil),
+		ExpectError(hc.TLSVersionAtLeast(tls.VersionTLS12)),
+	}.Test(t)
+}
@@ -0,0 +1,146 @@
+package argot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// TokenRefreshTransport is an http.RoundTripper which handles expired
+// bearer tokens the way a well-behaved client must: when a response
+// has status 401 (Unauthorized), it runs Refresh to obtain a new
+// token, and retries the original request once with the new token.
+// Expiry is detected by the server rather than predicted from the
+// token's expiry time, so skew between the test's clock and the
+// server's does not matter. Once a token has been refreshed, every
+// request sent through the transport carries it.
+//
+// Concurrent requests which are refused with the same stale token
+// cause only one refresh. Requests whose bodies cannot be replayed
+// (see http.Request.GetBody) are not retried. A TokenRefreshTransport
+// is safe for concurrent use.
+type TokenRefreshTransport struct {
+	// Transport performs the requests. If nil, http.DefaultTransport
+	// is used.
+	Transport http.RoundTripper
+	// Refresh obtains a new token and sets it in Store under Key. It
+	// must not use the HttpCall whose requests are being refreshed:
+	// it runs whilst that HttpCall is waiting for its response.
+	Refresh Step
+	Store   *Store
+	Key     string
+	// If non-nil, each refreshed token is added to Redactor (see
+	// Redactor.AddValue).
+	Redactor *Redactor
+
+	lock      sync.Mutex
+	token     string
+	refreshes int
+}
+
+// RefreshTokenOnUnauthorized replaces hc.Client with a copy whose
+// Transport is wrapped in a new TokenRefreshTransport which runs
+// refresh, and then uses the token it sets in store under key, when a
+// request is refused with status 401. The original client is not
+// modified. The TokenRefreshTransport is returned for use in
+// assertions. Refreshed tokens are redacted by hc's Redactor.
+func (hc *HttpCall) RefreshTokenOnUnauthorized(refresh Step, store *Store, key string) *TokenRefreshTransport {
+	client := *hc.Client
+	tr := &TokenRefreshTransport{Transport: client.Transport, Refresh: refresh, Store: store, Key: key, Redactor: hc.ownRedactor()}
+	client.Transport = tr
+	hc.Client = &client
+	return tr
+}
+
+func (tr *TokenRefreshTransport) transport() http.RoundTripper {
+	if tr.Transport == nil {
+		return http.DefaultTransport
+	} else {
+		return tr.Transport
+	}
+}
+
+// authorize returns req, or a clone of it carrying the current token
+// if one has been refreshed, and the Authorization header sent.
+func (tr *TokenRefreshTransport) authorize(req *http.Request) (*http.Request, string) {
+	tr.lock.Lock()
+	token := tr.token
+	tr.lock.Unlock()
+	if token != "" && req.Header.Get("Authorization") != "Bearer "+token {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, req.Header.Get("Authorization")
+}
+
+// refresh runs Refresh unless the token has changed since sent was
+// sent, in which case the request need only be retried.
+func (tr *TokenRefreshTransport) refresh(sent string) error {
+	tr.lock.Lock()
+	defer tr.lock.Unlock()
+	if tr.token != "" && sent != "Bearer "+tr.token {
+		return nil
+	} else if tr.Refresh == nil || tr.Store == nil {
+		return errors.New("Token refresh: Refresh and Store must be set.")
+	}
+	tr.refreshes++
+	if err := tr.Refresh.Go(); err != nil {
+		return fmt.Errorf("Token refresh: %v", err)
+	}
+	token := tr.Store.GetString(tr.Key)
+	if token == "" {
+		return fmt.Errorf("Token refresh: Expected a token in '%s'; found none.", tr.Key)
+	}
+	if tr.Redactor != nil {
+		tr.Redactor.AddValue(token)
+	}
+	tr.token = token
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (tr *TokenRefreshTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sending, sent := tr.authorize(req)
+	response, err := tr.transport().RoundTrip(sending)
+	if err != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
+	} else if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return response, nil
+	}
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+	if err := tr.refresh(sent); err != nil {
+		return nil, err
+	}
+	retry, _ := tr.authorize(req.Clone(req.Context()))
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err != nil {
+			return nil, err
+		} else {
+			retry.Body = body
+		}
+	}
+	return tr.transport().RoundTrip(retry)
+}
+
+// Refreshes returns the number of times Refresh has been run.
+func (tr *TokenRefreshTransport) Refreshes() int {
+	tr.lock.Lock()
+	defer tr.lock.Unlock()
+	return tr.refreshes
+}
+
+// ExpectRefreshes is a Step that when executed errors unless Refresh
+// has been run exactly n times.
+func (tr *TokenRefreshTransport) ExpectRefreshes(n int) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectRefreshes(%d)", n), func() error {
+		if found := tr.Refreshes(); found != n {
+			return fmt.Errorf("Token refresh: Expected %d refreshes; found %d.", n, found)
+		} else {
+			return nil
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package argot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTokenRefresh(t *testing.T) {
+	var lock sync.Mutex
+	current, issued := "argot-token-1", 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if r.URL.Path == "/token" {
+			issued++
+			current = fmt.Sprintf("argot-token-%d", issued)
+			w.Write([]byte(`{"access_token": "` + current + `"}`))
+		} else if r.URL.Path == "/expire" {
+			current = "argot-expired-token"
+		} else if r.Header.Get("Authorization") != "Bearer "+current {
+			w.WriteHeader(http.StatusUnauthorized)
+		} else {
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write(append([]byte("ok "), body...))
+		}
+	}))
+	defer server.Close()
+
+	store := NewStore()
+	auth := NewHttpCall(nil)
+	defer auth.Reset()
+	refresh := Steps{
+		auth.NewRequest("POST", server.URL+"/token", nil),
+		auth.ResponseStatusEquals(200),
+		auth.CaptureJSON(store, "token", "access_token"),
+	}
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	tr := hc.RefreshTokenOnUnauthorized(refresh, store, "token")
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.RequestHeader("Authorization", "Bearer argot-token-1"),
+		hc.ResponseStatusEquals(200),
+		tr.ExpectRefreshes(0),
+		hc.NewRequest("GET", server.URL+"/expire", nil),
+		hc.ResponseStatusEquals(200),
+		hc.NewRequest("POST", server.URL, strings.NewReader("replayed")),
+		hc.RequestHeader("Authorization", "Bearer argot-token-1"),
+		hc.ResponseStatusEquals(200),
+		hc.ResponseBodyEquals("ok replayed"),
+		tr.ExpectRefreshes(1),
+		// The refreshed token is used from now on.
+		hc.NewRequest("GET", server.URL, nil),
+		hc.RequestHeader("Authorization", "Bearer argot-token-1"),
+		hc.ResponseStatusEquals(200),
+		tr.ExpectRefreshes(1),
+		ExpectError(tr.ExpectRefreshes(2)),
+	}.Test(t)
+
+	// A token which is refused even once refreshed is only retried
+	// once.
+	hc = NewHttpCall(nil)
+	defer hc.Reset()
+	tr = hc.RefreshTokenOnUnauthorized(NewNamedStep("stale", func() error {
+		store.Set("token", "argot-stale-token")
+		return nil
+	}), store, "token")
+	Steps{
+		hc.NewRequest("GET", server.URL, nil),
+		hc.ResponseStatusEquals(401),
+		tr.ExpectRefreshes(1),
+	}.Test(t)
+	if redacted := hc.redactor().String("argot-stale-token"); redacted != "REDACTED" {
+		t.Fatalf("Expected the refreshed token to be redacted by the HttpCall; found %s", redacted)
+	} else if DefaultRedactor.String("argot-stale-token") != "argot-stale-token" {
+		t.Fatal("Expected the refreshed token not to be added to DefaultRedactor.")
+	}
+}
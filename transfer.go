@@ -0,0 +1,175 @@
+package argot
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// Transfer counts the bytes sent and received by HTTP calls. Header
+// sizes are estimated from their HTTP/1.1 wire format, and body sizes
+// are as seen by the client: after any transparent decompression by
+// the Transport.
+type Transfer struct {
+	Sent     int64
+	Received int64
+}
+
+// Total returns the sum of the bytes sent and received.
+func (t Transfer) Total() int64 {
+	return t.Sent + t.Received
+}
+
+func (t Transfer) since(earlier Transfer) Transfer {
+	return Transfer{Sent: t.Sent - earlier.Sent, Received: t.Received - earlier.Received}
+}
+
+func (t Transfer) String() string {
+	return fmt.Sprintf("%d bytes sent, %d bytes received", t.Sent, t.Received)
+}
+
+// totalSent and totalReceived count the bytes transferred by every
+// HttpCall in the process.
+var totalSent, totalReceived int64
+
+// TotalTransfer returns the bytes transferred by every HttpCall in
+// the process so far. RunScenario uses this to attribute transfer to
+// steps, so the attribution is only accurate when scenarios are run
+// one at a time.
+func TotalTransfer() Transfer {
+	return Transfer{Sent: atomic.LoadInt64(&totalSent), Received: atomic.LoadInt64(&totalReceived)}
+}
+
+// callTransfer counts the bytes transferred by a single call.
+type callTransfer struct {
+	sent, received int64
+}
+
+func (ct *callTransfer) addSent(n int64) {
+	atomic.AddInt64(&ct.sent, n)
+	atomic.AddInt64(&totalSent, n)
+}
+
+func (ct *callTransfer) addReceived(n int64) {
+	atomic.AddInt64(&ct.received, n)
+	atomic.AddInt64(&totalReceived, n)
+}
+
+type byteCounter int64
+
+func (bc *byteCounter) Write(p []byte) (int, error) {
+	*bc += byteCounter(len(p))
+	return len(p), nil
+}
+
+func headerSize(header http.Header) int64 {
+	counter := new(byteCounter)
+	header.Write(counter)
+	return int64(*counter)
+}
+
+// countRequest counts the request line and headers of req, and wraps
+// its body so that the body is counted as it is sent.
+func (ct *callTransfer) countRequest(req *http.Request) {
+	ct.addSent(int64(len(req.Method)+len(req.URL.RequestURI())+len(" HTTP/1.1\r\n")+len("Host: \r\n\r\n")+len(req.Host)) + headerSize(req.Header))
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = &countingBody{ReadCloser: req.Body, count: ct.addSent}
+	}
+}
+
+// countResponse counts the status line and headers of response, and
+// wraps its body so that the body is counted as it is received.
+func (ct *callTransfer) countResponse(response *http.Response) {
+	ct.addReceived(int64(len(response.Proto)+len(response.Status)+len(" \r\n\r\n")) + headerSize(response.Header))
+	response.Body = &countingBody{ReadCloser: response.Body, count: ct.addReceived}
+}
+
+type countingBody struct {
+	io.ReadCloser
+	count func(int64)
+}
+
+func (cb *countingBody) Read(p []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(p)
+	cb.count(int64(n))
+	return n, err
+}
+
+// Transfer returns the bytes transferred so far by the current call.
+// Response body bytes are counted as the body is received.
+func (hc *HttpCall) Transfer() Transfer {
+	if hc.transfer == nil {
+		return Transfer{}
+	} else {
+		return Transfer{Sent: atomic.LoadInt64(&hc.transfer.sent), Received: atomic.LoadInt64(&hc.transfer.received)}
+	}
+}
+
+// ResponseSizeUnder is a Step that when executed ensures the body has
+// been received and errors unless it is smaller than limit bytes,
+// whether or not it was spooled to disk (see SpoolThreshold).
+func (hc *HttpCall) ResponseSizeUnder(limit int64) Step {
+	return hc.step(fmt.Sprintf("ResponseSizeUnder(%d)", limit), func() error {
+		if size, err := hc.ResponseBodySize(); err != nil {
+			return err
+		} else if size >= limit {
+			return fmt.Errorf("Body size: Expected under %d bytes; found %d.", limit, size)
+		} else {
+			return nil
+		}
+	})
+}
+
+// TransferUnder is a Step that when executed ensures the body has been
+// received and errors unless the total bytes sent and
+// received by the call are fewer than limit.
+func (hc *HttpCall) TransferUnder(limit int64) Step {
+	return hc.step(fmt.Sprintf("TransferUnder(%d)", limit), func() error {
+		if err := hc.receiveBody(); err != nil {
+			return err
+		} else if transfer := hc.Transfer(); transfer.Total() >= limit {
+			return fmt.Errorf("Transfer: Expected under %d bytes; found %v.", limit, transfer)
+		} else {
+			return nil
+		}
+	})
+}
+
+// TransferMeter measures the bytes transferred by every HttpCall
+// between its Start step and its assertion steps, for enforcing a
+// payload budget over a sequence of calls.
+type TransferMeter struct {
+	start Transfer
+}
+
+// NewTransferMeter creates a new TransferMeter.
+func NewTransferMeter() *TransferMeter {
+	return &TransferMeter{}
+}
+
+// Start is a Step that when executed starts measuring.
+func (tm *TransferMeter) Start() Step {
+	return NewNamedStep("TransferMeterStart", func() error {
+		tm.start = TotalTransfer()
+		return nil
+	})
+}
+
+// Transfer returns the bytes transferred since Start.
+func (tm *TransferMeter) Transfer() Transfer {
+	return TotalTransfer().since(tm.start)
+}
+
+// TotalTransferUnder is a Step that when executed errors unless the
+// bytes sent and received since Start are fewer than limit. Response
+// bodies must have been received to be counted.
+func (tm *TransferMeter) TotalTransferUnder(limit int64) Step {
+	return NewNamedStep(fmt.Sprintf("TotalTransferUnder(%d)", limit), func() error {
+		if transfer := tm.Transfer(); transfer.Total() >= limit {
+			return fmt.Errorf("Transfer: Expected under %d bytes in total; found %v.", limit, transfer)
+		} else {
+			return nil
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package argot
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// TransportErrorKind classifies why a request could not be made.
+type TransportErrorKind int
+
+const (
+	// TransportErrorOther is any failure not classified below.
+	TransportErrorOther TransportErrorKind = iota
+	// DNSFailure is a failure to resolve the host's name.
+	DNSFailure
+	// ConnectionRefused is a refusal of the connection, typically as
+	// nothing is listening on the port.
+	ConnectionRefused
+	// TLSFailure is a failure of the TLS handshake, such as an
+	// untrusted certificate or a certificate for another host.
+	TLSFailure
+	// Timeout is a timeout, whether of the client, the request's
+	// context or the connection.
+	Timeout
+	// DependencyDown is a request which was not sent as its host is
+	// down (see CircuitBreaker).
+	DependencyDown
+)
+
+func (k TransportErrorKind) String() string {
+	switch k {
+	case DNSFailure:
+		return "DNS failure"
+	case ConnectionRefused:
+		return "connection refused"
+	case TLSFailure:
+		return "TLS failure"
+	case Timeout:
+		return "timeout"
+	case DependencyDown:
+		return "dependency down"
+	default:
+		return "transport error"
+	}
+}
+
+// TransportError is the error returned by EnsureResponse (and so by
+// any step which sends a request) when the request could not be made
+// or no response was received.
+type TransportError struct {
+	Kind TransportErrorKind
+	// The URL of the request, without any user info.
+	URL string
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("Error when making call of %s: %v", e.URL, e.Err)
+}
+
+// Unwrap returns the error of the http.Client.
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyTransportError returns the kind of the transport error err.
+func ClassifyTransportError(err error) TransportErrorKind {
+	var dnsErr *net.DNSError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var netErr net.Error
+	var downErr *DependencyDownError
+	if errors.As(err, &downErr) {
+		return DependencyDown
+	} else if errors.As(err, &dnsErr) {
+		return DNSFailure
+	} else if errors.Is(err, syscall.ECONNREFUSED) {
+		return ConnectionRefused
+	} else if errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return TLSFailure
+	} else if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return Timeout
+	} else {
+		return TransportErrorOther
+	}
+}
+
+// ExpectTransportError is a Step that when executed sends the request
+// and errors unless it fails with a transport error of kind, for
+// negative tests such as that a firewalled port refuses connections.
+// Like Call, it must be used after the request has been built.
+func (hc *HttpCall) ExpectTransportError(kind TransportErrorKind) Step {
+	return hc.step(fmt.Sprintf("ExpectTransportError(%v)", kind), func() error {
+		if err := AnyError(hc.AssertRequest(), hc.AssertNoResponse()); err != nil {
+			return err
+		}
+		var transportErr *TransportError
+		if err := hc.EnsureResponse(); err == nil {
+			return fmt.Errorf("Expected %v; found status %d.", kind, hc.Response.StatusCode)
+		} else if !errors.As(err, &transportErr) {
+			return err
+		} else if transportErr.Kind != kind {
+			return fmt.Errorf("Expected %v; found %v: %v", kind, transportErr.Kind, transportErr.Err)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectConnectionRefused is a Step that when executed sends the
+// request and errors unless the connection is refused (see
+// ExpectTransportError).
+func (hc *HttpCall) ExpectConnectionRefused() Step {
+	return hc.ExpectTransportError(ConnectionRefused)
+}
+
+// ExpectDNSFailure is a Step that when executed sends the request and
+// errors unless the host's name cannot be resolved (see
+// ExpectTransportError).
+func (hc *HttpCall) ExpectDNSFailure() Step {
+	return hc.ExpectTransportError(DNSFailure)
+}
+
+// ExpectTLSFailure is a Step that when executed sends the request and
+// errors unless the TLS handshake fails (see ExpectTransportError).
+func (hc *HttpCall) ExpectTLSFailure() Step {
+	return hc.ExpectTransportError(TLSFailure)
+}
+
+// ExpectTimeout is a Step that when executed sends the request and
+// errors unless it times out (see ExpectTransportError), for example
+// as a firewall silently drops it. A timeout must be set, on
+// hc.Client or the request's context.
+func (hc *HttpCall) ExpectTimeout() Step {
+	return hc.ExpectTransportError(Timeout)
+}
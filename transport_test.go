@@ -0,0 +1,64 @@
+package argot
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransportErrors(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedURL := "http://" + listener.Addr().String()
+	listener.Close()
+
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+	release := make(chan struct{})
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-release }))
+	defer slowServer.Close()
+	defer close(release)
+
+	// Names are resolved by a stub which fails every lookup, so that
+	// the test does not depend on the network.
+	dialer := &net.Dialer{Resolver: &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, errors.New("no DNS in tests")
+		},
+	}}
+	hc := NewHttpCall(&http.Client{Transport: &http.Transport{DialContext: dialer.DialContext}})
+	defer hc.Reset()
+	timed := NewHttpCall(&http.Client{Timeout: 100 * time.Millisecond})
+	defer timed.Reset()
+	Steps{
+		hc.NewRequest("GET", closedURL, nil),
+		hc.ExpectConnectionRefused(),
+		hc.NewRequest("GET", "http://argot.invalid/", nil),
+		hc.ExpectDNSFailure(),
+		hc.NewRequest("GET", tlsServer.URL, nil),
+		hc.ExpectTLSFailure(),
+		timed.NewRequest("GET", slowServer.URL, nil),
+		timed.ExpectTimeout(),
+	}.Test(t)
+
+	var transportErr *TransportError
+	if err := (Steps{hc.NewRequest("GET", closedURL, nil), hc.Call()}).Go(); !errors.As(err, &transportErr) {
+		t.Fatalf("Expected a TransportError; found %v.", err)
+	} else if transportErr.Kind != ConnectionRefused || !strings.HasPrefix(transportErr.Error(), "Error when making call of "+closedURL+": ") {
+		t.Fatalf("Unexpected error: %v (%v)", transportErr, transportErr.Kind)
+	}
+
+	if _, err := (Steps{hc.NewRequest("GET", tlsServer.URL, nil), hc.ExpectConnectionRefused()}).Test(nil); err == nil {
+		t.Fatal("Expected a TLS failure not to be a refused connection.")
+	} else if !strings.Contains(err.Error(), "Expected connection refused; found TLS failure: ") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package argot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sampler returns the current value of something which changes over
+// time, such as the progress of an asynchronous job or a metric.
+type Sampler func() (float64, error)
+
+// TrendSample is a value sampled by PollTrend, with the time elapsed
+// from the first sample until it was taken.
+type TrendSample struct {
+	Elapsed time.Duration
+	Value   float64
+}
+
+// TrendAssertion checks the samples taken by PollTrend, in order,
+// returning an error describing how they are not as expected.
+type TrendAssertion func(samples []TrendSample) error
+
+// jsonNumber converts a value decoded from JSON into a float64.
+func jsonNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case float64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("Expected a number; found %v.", value)
+	}
+}
+
+// JSONPathSampler returns a Sampler which GETs urlStr with hc, errors
+// unless the response status is 200, and returns the number at path
+// (see JSONPath) of the JSON body. hc is left holding the last
+// response.
+func (hc *HttpCall) JSONPathSampler(urlStr, path string) Sampler {
+	return func() (float64, error) {
+		if err := (Steps{hc.NewRequest(http.MethodGet, urlStr, nil), hc.ResponseStatusEquals(http.StatusOK)}).Go(); err != nil {
+			if hcErr, ok := err.(*HttpCallError); ok {
+				err = hcErr.Err
+			}
+			return 0, err
+		} else if value, err := hc.responseJSONPath(path); err != nil {
+			return 0, err
+		} else if f, err := jsonNumber(value); err != nil {
+			return 0, fmt.Errorf("JSON path '%s': %v", path, err)
+		} else {
+			return f, nil
+		}
+	}
+}
+
+// PrometheusSampler returns a Sampler which GETs urlStr with hc,
+// errors unless the response status is 200, and returns the value of
+// metric from the body, in the Prometheus text exposition format.
+// metric is the name of the sample with its labels, if any, exactly as
+// exposed, for example `jobs_completed_total{queue="emails"}`. hc is
+// left holding the last response.
+func (hc *HttpCall) PrometheusSampler(urlStr, metric string) Sampler {
+	return func() (float64, error) {
+		if err := (Steps{hc.NewRequest(http.MethodGet, urlStr, nil), hc.ResponseStatusEquals(http.StatusOK)}).Go(); err != nil {
+			if hcErr, ok := err.(*HttpCallError); ok {
+				err = hcErr.Err
+			}
+			return 0, err
+		} else if err := hc.ReceiveBody(); err != nil {
+			return 0, err
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(hc.ResponseBody))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, metric+" ") {
+				fields := strings.Fields(line[len(metric):])
+				return strconv.ParseFloat(fields[0], 64)
+			}
+		}
+		return 0, fmt.Errorf("Metric %s not found.", metric)
+	}
+}
+
+// Increasing is a TrendAssertion that errors if any sample is less
+// than the one before it.
+func Increasing() TrendAssertion {
+	return func(samples []TrendSample) error {
+		for idx := 1; idx < len(samples); idx++ {
+			if samples[idx].Value < samples[idx-1].Value {
+				return fmt.Errorf("Expected an increasing value; found %v after %v at %v.", samples[idx].Value, samples[idx-1].Value, samples[idx].Elapsed)
+			}
+		}
+		return nil
+	}
+}
+
+// Decreasing is a TrendAssertion that errors if any sample is greater
+// than the one before it.
+func Decreasing() TrendAssertion {
+	return func(samples []TrendSample) error {
+		for idx := 1; idx < len(samples); idx++ {
+			if samples[idx].Value > samples[idx-1].Value {
+				return fmt.Errorf("Expected a decreasing value; found %v after %v at %v.", samples[idx].Value, samples[idx-1].Value, samples[idx].Elapsed)
+			}
+		}
+		return nil
+	}
+}
+
+// NeverExceeds is a TrendAssertion that errors if any sample is
+// greater than max.
+func NeverExceeds(max float64) TrendAssertion {
+	return func(samples []TrendSample) error {
+		for _, sample := range samples {
+			if sample.Value > max {
+				return fmt.Errorf("Expected never to exceed %v; found %v at %v.", max, sample.Value, sample.Elapsed)
+			}
+		}
+		return nil
+	}
+}
+
+// NeverBelow is a TrendAssertion that errors if any sample is less
+// than min.
+func NeverBelow(min float64) TrendAssertion {
+	return func(samples []TrendSample) error {
+		for _, sample := range samples {
+			if sample.Value < min {
+				return fmt.Errorf("Expected never to fall below %v; found %v at %v.", min, sample.Value, sample.Elapsed)
+			}
+		}
+		return nil
+	}
+}
+
+// StabilisesWithin is a TrendAssertion that errors unless, from some
+// sample taken within d onwards, every sample is within tolerance of
+// the last sample: that is, the value settles within d and then stays
+// settled.
+func StabilisesWithin(d time.Duration, tolerance float64) TrendAssertion {
+	return func(samples []TrendSample) error {
+		if len(samples) == 0 {
+			return errors.New("Expected samples; found none.")
+		}
+		last := samples[len(samples)-1].Value
+		stable := len(samples) - 1
+		for stable > 0 && math.Abs(samples[stable-1].Value-last) <= tolerance {
+			stable--
+		}
+		if samples[stable].Elapsed > d {
+			return fmt.Errorf("Expected to stabilise within %v; found %v settled at %v.", d, last, samples[stable].Elapsed)
+		}
+		return nil
+	}
+}
+
+// PollTrend is a Step that when executed takes a sample with sampler
+// every interval until window has elapsed, and then errors unless the
+// samples satisfy every one of assertions. It errors immediately if
+// sampling fails. The error lists the samples taken.
+func PollTrend(sampler Sampler, interval, window time.Duration, assertions ...TrendAssertion) Step {
+	return NewNamedStep(fmt.Sprintf("PollTrend(%v every %v)", window, interval), func() error {
+		samples := []TrendSample{}
+		start := time.Now()
+		for {
+			elapsed := time.Since(start)
+			value, err := sampler()
+			if err != nil {
+				return fmt.Errorf("Trend: Sample at %v: %v", elapsed, err)
+			}
+			samples = append(samples, TrendSample{Elapsed: elapsed, Value: value})
+			if elapsed+interval > window {
+				break
+			}
+			time.Sleep(time.Until(start.Add(time.Duration(len(samples)) * interval)))
+		}
+		failures := []string{}
+		for _, assertion := range assertions {
+			if err := assertion(samples); err != nil {
+				failures = append(failures, err.Error())
+			}
+		}
+		if len(failures) == 0 {
+			return nil
+		}
+		values := make([]string, len(samples))
+		for idx, sample := range samples {
+			values[idx] = fmt.Sprintf("%v=%v", sample.Elapsed.Round(time.Millisecond), sample.Value)
+		}
+		return fmt.Errorf("Trend over %d samples:\n\t%s\nSamples: %s", len(samples), strings.Join(failures, "\n\t"), strings.Join(values, ", "))
+	})
+}
@@ -0,0 +1,50 @@
+package argot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPollTrend(t *testing.T) {
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		progress := atomic.AddInt32(&polls, 1) * 25
+		if progress > 100 {
+			progress = 100
+		}
+		if r.URL.Path == "/metrics" {
+			fmt.Fprintf(w, "# TYPE jobs_progress gauge\njobs_progress{job=\"other\"} 7\njobs_progress{job=\"export\"} %d\n", progress)
+		} else {
+			fmt.Fprintf(w, `{"job": {"progress": %d}}`, progress)
+		}
+	}))
+	defer server.Close()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		PollTrend(hc.JSONPathSampler(server.URL+"/jobs/1", "job.progress"), 10*time.Millisecond, 100*time.Millisecond,
+			Increasing(), NeverExceeds(100), NeverBelow(25), StabilisesWithin(80*time.Millisecond, 0)),
+	}.Test(t)
+
+	atomic.StoreInt32(&polls, 0)
+	err := PollTrend(hc.PrometheusSampler(server.URL+"/metrics", `jobs_progress{job="export"}`), 10*time.Millisecond, 100*time.Millisecond,
+		Decreasing(), NeverExceeds(50), StabilisesWithin(10*time.Millisecond, 0)).Go()
+	if err == nil {
+		t.Fatal("Expected the trend to fail.")
+	}
+	for _, expected := range []string{"Expected a decreasing value; found 50 after 25", "Expected never to exceed 50; found 75", "Expected to stabilise within 10ms; found 100 settled at", "Samples: 0s=25, "} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected %q in error: %v", expected, err)
+		}
+	}
+
+	if err := PollTrend(hc.PrometheusSampler(server.URL+"/metrics", "missing"), time.Millisecond, time.Millisecond).Go(); err == nil || !strings.Contains(err.Error(), "Metric missing not found.") {
+		t.Fatalf("Expected a sampling error; found %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package argot
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// varyFingerprint is the part of a response which a cache must not
+// serve to a request it was not made for.
+type varyFingerprint struct {
+	status int
+	header []string
+	body   []byte
+}
+
+// varyHeaders are the response headers which, with the status and
+// body, distinguish one representation from another.
+var varyHeaders = []string{"Content-Type", "Content-Encoding", "Content-Language"}
+
+func (hc *HttpCall) varyFingerprint() varyFingerprint {
+	fp := varyFingerprint{status: hc.Response.StatusCode, body: hc.ResponseBody}
+	for _, key := range varyHeaders {
+		fp.header = append(fp.header, hc.Response.Header.Get(key))
+	}
+	return fp
+}
+
+func (fp varyFingerprint) equals(other varyFingerprint) bool {
+	if fp.status != other.status || !bytes.Equal(fp.body, other.body) {
+		return false
+	}
+	for idx := range fp.header {
+		if fp.header[idx] != other.header[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+// VaryCorrect is a Step that when executed checks that the Vary header
+// of the response to a GET of urlStr is correct, as an incorrect Vary
+// poisons caches. It GETs urlStr, and then GETs it again with each of
+// the alternative values of each of the request headers given. The
+// response (its status, body, Content-Type, Content-Encoding and
+// Content-Language) must be the same whenever the header is not
+// listed in Vary, else a cache would serve one representation in
+// place of another; and must differ for at least one alternative when
+// it is listed, else the cache is needlessly fragmented. Headers
+// listed neither in Vary nor in alternatives are not checked; the
+// response must not be dynamic, such as including the time. hc is
+// left holding the last response.
+func (hc *HttpCall) VaryCorrect(urlStr string, alternatives map[string][]string) Step {
+	return hc.step(fmt.Sprintf("VaryCorrect(%s)", hc.redactor().String(urlStr)), func() error {
+		get := func(key, value string) (varyFingerprint, error) {
+			steps := Steps{hc.NewRequest(http.MethodGet, urlStr, nil)}
+			if key != "" {
+				steps = append(steps, hc.RequestHeader(key, value))
+			}
+			if err := steps.Go(); err != nil {
+				return varyFingerprint{}, err
+			} else if err := hc.ReceiveBody(); err != nil {
+				return varyFingerprint{}, err
+			}
+			hc.coverHeader("Vary")
+			return hc.varyFingerprint(), nil
+		}
+		base, err := get("", "")
+		if err != nil {
+			return err
+		}
+		vary := varyValues(hc.Request, hc.Response.Header)
+		listed := strings.Join(hc.Response.Header.Values("Vary"), ", ")
+		if listed == "*" {
+			return nil
+		}
+		for _, key := range sortedHeaderKeys(alternatives) {
+			_, varies := vary[http.CanonicalHeaderKey(key)]
+			differed := false
+			for _, value := range alternatives[key] {
+				fp, err := get(key, value)
+				if err != nil {
+					return err
+				} else if fp.equals(base) {
+					continue
+				} else if !varies {
+					return fmt.Errorf("Vary: The response differs with %s: %s, but Vary (%q) does not list %s.", key, value, listed, http.CanonicalHeaderKey(key))
+				}
+				differed = true
+			}
+			if varies && !differed {
+				return fmt.Errorf("Vary: Lists %s, but the response is the same with every value of it given.", http.CanonicalHeaderKey(key))
+			}
+		}
+		return nil
+	})
+}
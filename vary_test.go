@@ -0,0 +1,45 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVaryCorrect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/correct":
+			w.Header().Set("Vary", "Accept-Language")
+		case "/missing":
+		case "/excessive":
+			w.Header().Set("Vary", "Accept-Language, User-Agent")
+		}
+		if strings.HasPrefix(r.Header.Get("Accept-Language"), "fr") {
+			w.Header().Set("Content-Language", "fr")
+			w.Write([]byte("bonjour"))
+		} else {
+			w.Header().Set("Content-Language", "en")
+			w.Write([]byte("hello"))
+		}
+	}))
+	defer server.Close()
+
+	alternatives := map[string][]string{
+		"Accept-Language": {"fr-FR", "de"},
+		"User-Agent":      {"curl/8.0"},
+	}
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	Steps{
+		hc.VaryCorrect(server.URL+"/correct", alternatives),
+	}.Test(t)
+
+	if err := hc.VaryCorrect(server.URL+"/missing", alternatives).Go(); err == nil || !strings.Contains(err.Error(), `The response differs with Accept-Language: fr-FR, but Vary ("") does not list Accept-Language.`) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := hc.VaryCorrect(server.URL+"/excessive", alternatives).Go(); err == nil || !strings.Contains(err.Error(), "Lists User-Agent, but the response is the same") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package argot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ResponseTranslator translates the response held by hc, whose body
+// has been received, from the form of one version of an API into the
+// canonical form against which assertions are written, by modifying
+// hc.ResponseBody, hc.Response.Header or hc.Response.StatusCode in
+// place.
+type ResponseTranslator func(hc *HttpCall) error
+
+// JSONTranslator returns a ResponseTranslator which decodes the body as
+// JSON, passes it to translate, and replaces the body with the JSON
+// encoding of the result.
+func JSONTranslator(translate func(doc interface{}) (interface{}, error)) ResponseTranslator {
+	return func(hc *HttpCall) error {
+		var doc interface{}
+		if err := json.Unmarshal(hc.ResponseBody, &doc); err != nil {
+			return err
+		} else if doc, err = translate(doc); err != nil {
+			return err
+		} else if bites, err := json.Marshal(doc); err != nil {
+			return err
+		} else {
+			hc.ResponseBody = bites
+			return nil
+		}
+	}
+}
+
+// APIVersion is one version of an endpoint served side by side with
+// others during a migration (see CompareVersions).
+type APIVersion struct {
+	// The name of the version, for example "v1".
+	Name string
+	// Request builds the request of this version, such as one to
+	// "/v1/teas/1", on hc.
+	Request func(hc *HttpCall) Steps
+	// Translate translates the response of this version into the
+	// canonical form. If nil, the response is used as received.
+	Translate ResponseTranslator
+}
+
+// CompareVersions is a Step that when executed, for each of versions,
+// builds and sends its request with a clone of hc (see Clone),
+// translates the response, and runs every one of the steps built by
+// assertions against it, whether or not the earlier ones fail. It
+// errors, listing them, if any assertion fails for any version,
+// distinguishing those which hold for only some of the versions, which
+// are the differences between the versions that a migration must
+// resolve, from those which hold for none. The assertions are matched
+// between versions by their position, so assertions must build the
+// same steps whichever HttpCall it is given.
+func (hc *HttpCall) CompareVersions(versions []APIVersion, assertions func(hc *HttpCall) Steps) Step {
+	names := make([]string, len(versions))
+	for idx, version := range versions {
+		names[idx] = version.Name
+	}
+	return hc.step(fmt.Sprintf("CompareVersions(%s)", strings.Join(names, ", ")), func() error {
+		var steps Steps
+		errs := make([][]error, len(versions))
+		for idx, version := range versions {
+			call, err := hc.Clone()
+			if err != nil {
+				return err
+			}
+			err = version.Request(call).Go()
+			if err == nil {
+				err = call.ReceiveBody()
+			}
+			if err == nil && version.Translate != nil {
+				if err = version.Translate(call); err != nil {
+					err = fmt.Errorf("Translate: %v", err)
+				}
+			}
+			if hcErr, ok := err.(*HttpCallError); ok {
+				err = hcErr.Err
+			}
+			if err != nil {
+				call.Reset()
+				return fmt.Errorf("Version %s: %v", version.Name, err)
+			}
+			steps = assertions(call)
+			errs[idx] = make([]error, len(steps))
+			for stepIdx, step := range steps {
+				err := step.Go()
+				if hcErr, ok := err.(*HttpCallError); ok {
+					err = hcErr.Err
+				}
+				errs[idx][stepIdx] = err
+			}
+			call.Reset()
+		}
+
+		for idx, version := range versions {
+			if len(errs[idx]) != len(steps) {
+				return fmt.Errorf("Version %s: Expected %d assertions; found %d.", version.Name, len(steps), len(errs[idx]))
+			}
+		}
+		differences, failures := []string{}, []string{}
+		for stepIdx, step := range steps {
+			held, failed := []string{}, []string{}
+			for idx, version := range versions {
+				if err := errs[idx][stepIdx]; err == nil {
+					held = append(held, version.Name)
+				} else {
+					failed = append(failed, fmt.Sprintf("%s: %s", version.Name, strings.Replace(err.Error(), "\n", "\n\t\t", -1)))
+				}
+			}
+			if len(failed) == 0 {
+				continue
+			} else if len(held) == 0 {
+				failures = append(failures, fmt.Sprintf("[%d] %v: holds for no version:\n\t\t%s", stepIdx, step, strings.Join(failed, "\n\t\t")))
+			} else {
+				differences = append(differences, fmt.Sprintf("[%d] %v: holds only for %s:\n\t\t%s", stepIdx, step, strings.Join(held, ", "), strings.Join(failed, "\n\t\t")))
+			}
+		}
+		if len(differences)+len(failures) != 0 {
+			return fmt.Errorf("Versions: %d of %d assertions differ between versions and %d hold for none:\n\t%s",
+				len(differences), len(steps), len(failures), strings.Join(append(differences, failures...), "\n\t"))
+		}
+		return nil
+	})
+}
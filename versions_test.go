@@ -0,0 +1,72 @@
+package argot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/v1/teas/1" {
+			w.Write([]byte(`{"tea_name":"green","price":3}`))
+		} else {
+			w.Write([]byte(`{"name":"green","price":{"amount":4}}`))
+		}
+	}))
+	defer server.Close()
+
+	versions := []APIVersion{
+		{
+			Name: "v1",
+			Request: func(hc *HttpCall) Steps {
+				return Steps{hc.NewRequest(http.MethodGet, server.URL+"/v1/teas/1", nil)}
+			},
+			Translate: JSONTranslator(func(doc interface{}) (interface{}, error) {
+				obj := doc.(map[string]interface{})
+				return map[string]interface{}{"name": obj["tea_name"], "price": obj["price"]}, nil
+			}),
+		},
+		{
+			Name: "v2",
+			Request: func(hc *HttpCall) Steps {
+				return Steps{hc.NewRequest(http.MethodGet, server.URL+"/v2/teas/1", nil)}
+			},
+			Translate: JSONTranslator(func(doc interface{}) (interface{}, error) {
+				obj := doc.(map[string]interface{})
+				obj["price"] = obj["price"].(map[string]interface{})["amount"]
+				return obj, nil
+			}),
+		},
+	}
+	hc := NewHttpCall(nil)
+	err := hc.CompareVersions(versions, func(hc *HttpCall) Steps {
+		return Steps{
+			hc.ResponseStatusEquals(http.StatusOK),
+			hc.ResponseBodyJSONPathEquals("name", "green"),
+			hc.ResponseBodyJSONPathEquals("price", 3),
+		}
+	}).Go()
+	if err == nil || !strings.Contains(err.Error(), "Versions: 1 of 3 assertions differ between versions and 0 hold for none:") ||
+		!strings.Contains(err.Error(), "[2] ResponseBodyJSONPathEquals(price): holds only for v1:\n\t\tv2: ") {
+		t.Fatalf("Expected the price to differ; found %v", err)
+	}
+
+	err = hc.CompareVersions(versions, func(hc *HttpCall) Steps {
+		return Steps{hc.ResponseBodyJSONPathEquals("name", "green")}
+	}).Go()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions[1].Translate = nil
+	err = hc.CompareVersions(versions, func(hc *HttpCall) Steps {
+		return Steps{hc.ResponseBodyJSONPathEquals("name", "black")}
+	}).Go()
+	if err == nil || !strings.Contains(err.Error(), "0 of 1 assertions differ between versions and 1 hold for none:") ||
+		!strings.Contains(err.Error(), "holds for no version:\n\t\tv1: ") {
+		t.Fatalf("Expected the name to hold for no version; found %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package argot
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// WarmupStep is a Step which runs steps to warm up the system under
+// test (its JIT, caches and connection pools) before the measured
+// portion of a scenario, so that first-request timings do not skew
+// latency assertions. A WarmupStep never fails: the error of its
+// steps, if any, is kept in Err. RunScenario excludes a WarmupStep
+// from its results, along with its duration and the bytes it
+// transferred. Whilst it runs, the requests of its Calls, and of any
+// HttpCalls cloned from them (see Clone), are marked as Warmup in
+// their events, so that TimingBaseline ignores them, whichever
+// go-routine makes them: steps such as Concurrently and Load which
+// warm up with several go-routines should use clones of the Calls.
+// Requests of other HttpCalls, such as those of scenarios running
+// concurrently, are measured as usual.
+type WarmupStep struct {
+	Steps Steps
+	// The HttpCalls used by Steps.
+	Calls []*HttpCall
+	// The error of the steps when they last ran, if any.
+	Err error
+}
+
+// Warmup creates a new WarmupStep which runs steps, which use calls.
+func Warmup(steps Steps, calls ...*HttpCall) *WarmupStep {
+	return &WarmupStep{Steps: steps, Calls: calls}
+}
+
+// warmingUp returns true iff a WarmupStep using hc, or the HttpCall
+// hc was cloned from, is running.
+func (hc *HttpCall) warmingUp() bool {
+	return hc.warmup != nil && atomic.LoadInt32(hc.warmup) > 0
+}
+
+func (ws *WarmupStep) String() string {
+	return fmt.Sprintf("Warmup(%d steps)", len(ws.Steps))
+}
+
+// Go runs the steps in order, stopping at the first error, which is
+// kept in ws.Err rather than returned.
+func (ws *WarmupStep) Go() error {
+	for _, hc := range ws.Calls {
+		if hc.warmup == nil {
+			hc.warmup = new(int32)
+		}
+		atomic.AddInt32(hc.warmup, 1)
+	}
+	defer func() {
+		for _, hc := range ws.Calls {
+			atomic.AddInt32(hc.warmup, -1)
+		}
+	}()
+	ws.Err = ws.Steps.Go()
+	return nil
+}
@@ -0,0 +1,151 @@
+package argot
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWarmup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("warm"))
+	}))
+	defer server.Close()
+
+	tb := NewTimingBaseline()
+	stop := tb.ObserveCalls()
+	defer stop()
+	warmupEvents := 0
+	defer AddEventListener(func(event Event) {
+		if event.Warmup {
+			warmupEvents++
+		}
+	})()
+
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	warmup := Warmup(Steps{
+		hc.NewRequest("GET", server.URL+"/warmup", nil),
+		hc.ResponseStatusEquals(http.StatusOK),
+		NewNamedStep("Slow", func() error {
+			time.Sleep(100 * time.Millisecond)
+			return errors.New("cold")
+		}),
+	}, hc)
+	measured := Steps{
+		NewNamedStep("Reset", func() error { hc.Reset(); return nil }),
+		hc.NewRequest("GET", server.URL+"/measured", nil),
+		hc.ResponseStatusEquals(http.StatusOK),
+	}
+	result := RunScenario("warmed", append(Steps{warmup}, measured...))
+	if !result.Passed() {
+		t.Fatalf("Expected the scenario to pass despite the warm-up failing; found %v", result.Err)
+	} else if warmup.Err == nil || warmup.Err.Error() != "cold" {
+		t.Fatalf("Expected the warm-up's error to be kept; found %v", warmup.Err)
+	} else if len(result.Steps) != 3 || result.Steps[0].Name != "Reset" {
+		t.Fatalf("Expected the warm-up to be excluded from the results; found %+v", result.Steps)
+	} else if result.Duration >= 100*time.Millisecond {
+		t.Fatalf("Expected the warm-up to be excluded from the duration; found %v", result.Duration)
+	} else if cold := RunScenario("cold", measured); result.Transfer != cold.Transfer {
+		t.Fatalf("Expected only the measured request to be counted; found %v rather than %v", result.Transfer, cold.Transfer)
+	} else if warmupEvents == 0 {
+		t.Fatal("Expected events marked as warm-up.")
+	}
+	tb.RebaselineCurrent()
+	if _, found := tb.baseline["GET "+server.URL+"/warmup"]; found {
+		t.Fatalf("Expected the warm-up call not to be observed; found %v", tb.baseline)
+	} else if _, found := tb.baseline["GET "+server.URL+"/measured"]; !found {
+		t.Fatalf("Expected the measured call to be observed; found %v", tb.baseline)
+	}
+}
+
+func TestWarmupConcurrentScenarios(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tb := NewTimingBaseline()
+	stop := tb.ObserveCalls()
+	defer stop()
+
+	// Another scenario's requests, made whilst one warms up, are
+	// still measured.
+	warming, measured := make(chan struct{}), make(chan struct{})
+	warm := NewHttpCall(nil)
+	defer warm.Reset()
+	warmup := Warmup(Steps{
+		warm.NewRequest("GET", server.URL+"/warmup", nil),
+		warm.ResponseStatusEquals(http.StatusOK),
+		NewNamedStep("Wait", func() error {
+			close(warming)
+			<-measured
+			return nil
+		}),
+	}, warm)
+	done := make(chan *ScenarioResult)
+	go func() { done <- RunScenario("warming", Steps{warmup}) }()
+	<-warming
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	result := RunScenario("measured", Steps{
+		hc.NewRequest("GET", server.URL+"/measured", nil),
+		hc.ResponseStatusEquals(http.StatusOK),
+	})
+	close(measured)
+	if !result.Passed() {
+		t.Fatal(result.Err)
+	} else if warmed := <-done; !warmed.Passed() || warmup.Err != nil {
+		t.Fatalf("Expected the warm-up to pass; found %v %v", warmed.Err, warmup.Err)
+	}
+	tb.RebaselineCurrent()
+	if _, found := tb.baseline["GET "+server.URL+"/measured"]; !found {
+		t.Fatalf("Expected the concurrent call to be observed; found %v", tb.baseline)
+	} else if _, found := tb.baseline["GET "+server.URL+"/warmup"]; found {
+		t.Fatalf("Expected the warm-up call not to be observed; found %v", tb.baseline)
+	}
+}
+
+func TestWarmupConcurrently(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var lock sync.Mutex
+	requests, warmups := 0, 0
+	defer AddEventListener(func(event Event) {
+		if event.Type == RequestFinished {
+			lock.Lock()
+			defer lock.Unlock()
+			requests++
+			if event.Warmup {
+				warmups++
+			}
+		}
+	})()
+
+	// Clones of the warm-up's calls are marked, whichever go-routine
+	// uses them.
+	hc := NewHttpCall(nil)
+	defer hc.Reset()
+	warmup := Warmup(Steps{Concurrently(4, func(i int) Step {
+		clone := new(HttpCall)
+		return Steps{
+			hc.CloneTo(clone),
+			clone.NewRequest("GET", server.URL, nil),
+			clone.ResponseStatusEquals(http.StatusOK),
+		}
+	})}, hc)
+	RunScenario("warming", Steps{warmup})
+	if warmup.Err != nil {
+		t.Fatal(warmup.Err)
+	} else if requests != 4 || warmups != 4 {
+		t.Fatalf("Expected 4 requests marked as warm-up; found %d of %d.", warmups, requests)
+	}
+
+	// Once warmed up, the calls are measured.
+	Steps{hc.NewRequest("GET", server.URL, nil), hc.ResponseStatusEquals(http.StatusOK)}.Test(t)
+	if requests != 5 || warmups != 4 {
+		t.Fatalf("Expected the request after the warm-up to be measured; found %d of %d marked.", warmups, requests)
+	}
+}
@@ -0,0 +1,255 @@
+package argot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebhookRequest is a request received by a WebhookReceiver.
+type WebhookRequest struct {
+	Method string
+	// The path and query of the request.
+	Path   string
+	Header http.Header
+	Body   []byte
+	// When the request was received.
+	Received time.Time
+}
+
+// WebhookReceiver is a local HTTP server which records the callback
+// requests it receives, so that steps can verify that the system
+// under test calls back with the right payload. Configure the system
+// under test with URL (plus any path), trigger an action, and then
+// use ExpectReceived.
+type WebhookReceiver struct {
+	// The base URL of the receiver, for example
+	// http://127.0.0.1:34567.
+	URL string
+
+	listener net.Listener
+	server   *http.Server
+	lock     sync.Mutex
+	cond     *sync.Cond
+	requests []*WebhookRequest
+	status   int
+	closed   bool
+}
+
+// NewWebhookReceiver creates a new WebhookReceiver listening on addr.
+// If addr is empty, a random port on the loopback interface is used.
+// Close must be called to stop the receiver.
+func NewWebhookReceiver(addr string) (*WebhookReceiver, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	receiver := &WebhookReceiver{
+		URL:      "http://" + listener.Addr().String(),
+		listener: listener,
+		status:   http.StatusOK,
+	}
+	receiver.cond = sync.NewCond(&receiver.lock)
+	receiver.server = &http.Server{Handler: http.HandlerFunc(receiver.serveHTTP)}
+	go receiver.server.Serve(listener)
+	return receiver, nil
+}
+
+func (receiver *WebhookReceiver) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	request := &WebhookRequest{
+		Method:   r.Method,
+		Path:     r.URL.RequestURI(),
+		Header:   r.Header,
+		Body:     body,
+		Received: time.Now(),
+	}
+	receiver.lock.Lock()
+	receiver.requests = append(receiver.requests, request)
+	status := receiver.status
+	receiver.cond.Broadcast()
+	receiver.lock.Unlock()
+	w.WriteHeader(status)
+}
+
+// Close stops the receiver.
+func (receiver *WebhookReceiver) Close() error {
+	receiver.lock.Lock()
+	receiver.closed = true
+	receiver.cond.Broadcast()
+	receiver.lock.Unlock()
+	return receiver.server.Close()
+}
+
+// Requests returns all the requests received which have not been
+// consumed by ExpectReceived.
+func (receiver *WebhookReceiver) Requests() []*WebhookRequest {
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	return append([]*WebhookRequest{}, receiver.requests...)
+}
+
+// Clear discards all requests received.
+func (receiver *WebhookReceiver) Clear() {
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	receiver.requests = nil
+}
+
+// RespondWith is a Step that when executed sets the status code with
+// which the receiver responds to subsequent requests. The default is
+// 200. This can be used to check that the system under test retries
+// failed deliveries.
+func (receiver *WebhookReceiver) RespondWith(status int) Step {
+	return NewNamedStep(fmt.Sprintf("RespondWith(%d)", status), func() error {
+		receiver.lock.Lock()
+		defer receiver.lock.Unlock()
+		receiver.status = status
+		return nil
+	})
+}
+
+// WebhookMatcher returns nil iff the request matches.
+type WebhookMatcher func(request *WebhookRequest) error
+
+// WebhookMethod matches requests with the given method.
+func WebhookMethod(method string) WebhookMatcher {
+	return func(request *WebhookRequest) error {
+		if request.Method != method {
+			return fmt.Errorf("Method: Expected %s; found %s.", method, request.Method)
+		}
+		return nil
+	}
+}
+
+// WebhookPath matches requests whose path (and query) equals path.
+func WebhookPath(path string) WebhookMatcher {
+	return func(request *WebhookRequest) error {
+		if request.Path != path {
+			return fmt.Errorf("Path: Expected %s; found %s.", path, request.Path)
+		}
+		return nil
+	}
+}
+
+// WebhookHeaderEquals matches requests whose header key equals value.
+func WebhookHeaderEquals(key, value string) WebhookMatcher {
+	return func(request *WebhookRequest) error {
+		if found, ok := request.Header[http.CanonicalHeaderKey(key)]; !ok {
+			return fmt.Errorf("Header '%s' not found.", key)
+		} else if found[0] != value {
+			return fmt.Errorf("Header '%s': Expected '%s'; found '%s'.", key, value, found[0])
+		}
+		return nil
+	}
+}
+
+// WebhookBodyContains matches requests whose body contains value using
+// strings.Contains.
+func WebhookBodyContains(value string) WebhookMatcher {
+	return func(request *WebhookRequest) error {
+		if body := string(request.Body); !strings.Contains(body, value) {
+			return fmt.Errorf("Body: Expected '%s'; found '%s'.", value, body)
+		}
+		return nil
+	}
+}
+
+// WebhookBodyJSONMatchesStruct matches requests whose body, parsed as
+// JSON into a value of the same type as expected, equals expected.
+func WebhookBodyJSONMatchesStruct(expected interface{}) WebhookMatcher {
+	return func(request *WebhookRequest) error {
+		parseAs := reflect.New(reflect.TypeOf(expected)).Interface()
+		if err := json.Unmarshal(request.Body, parseAs); err != nil {
+			return err
+		} else if err := compareError(parseAs, expected); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// WebhookJSONPathEquals matches requests whose body, parsed as JSON,
+// has a value at path (see JSONPath) equal to expected. Values are
+// compared by their JSON encoding.
+func WebhookJSONPathEquals(path string, expected interface{}) WebhookMatcher {
+	return func(request *WebhookRequest) error {
+		var doc interface{}
+		if err := json.Unmarshal(request.Body, &doc); err != nil {
+			return err
+		} else if value, err := JSONPath(doc, path); err != nil {
+			return err
+		} else if want, err := normaliseJSON(expected); err != nil {
+			return err
+		} else if !reflect.DeepEqual(value, want) {
+			return fmt.Errorf("JSON path '%s': Expected %v; found %v.", path, want, value)
+		}
+		return nil
+	}
+}
+
+// ExpectReceived is a Step that when executed waits up to timeout for
+// a request which satisfies every matcher, and errors if none
+// arrives. The matching request is consumed so that it cannot satisfy
+// a later ExpectReceived.
+func (receiver *WebhookReceiver) ExpectReceived(timeout time.Duration, matchers ...WebhookMatcher) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectReceived(%v)", timeout), func() error {
+		receiver.lock.Lock()
+		defer receiver.lock.Unlock()
+		var lastErr error
+		found := false
+		waitForCond(receiver.cond, timeout, func() bool {
+			for idx, request := range receiver.requests {
+				if lastErr = matchWebhook(request, matchers); lastErr == nil {
+					receiver.requests = append(receiver.requests[:idx], receiver.requests[idx+1:]...)
+					found = true
+					break
+				}
+			}
+			return found || receiver.closed
+		})
+		if found {
+			return nil
+		} else if lastErr == nil {
+			return fmt.Errorf("No webhook request received within %v.", timeout)
+		} else {
+			return fmt.Errorf("No matching webhook request received within %v (%d received). Last mismatch: %v", timeout, len(receiver.requests), lastErr)
+		}
+	})
+}
+
+// ExpectNotReceived is a Step that when executed waits for wait and
+// errors if any request satisfying every matcher has been received.
+func (receiver *WebhookReceiver) ExpectNotReceived(wait time.Duration, matchers ...WebhookMatcher) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectNotReceived(%v)", wait), func() error {
+		time.Sleep(wait)
+		for _, request := range receiver.Requests() {
+			if matchWebhook(request, matchers) == nil {
+				return fmt.Errorf("Expected no webhook request; found %s %s.", request.Method, request.Path)
+			}
+		}
+		return nil
+	})
+}
+
+func matchWebhook(request *WebhookRequest, matchers []WebhookMatcher) error {
+	for _, matcher := range matchers {
+		if err := matcher(request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
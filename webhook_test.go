@@ -0,0 +1,110 @@
+package argot
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookReceiver(t *testing.T) {
+	receiver, err := NewWebhookReceiver("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+
+	// The system under test calls back asynchronously.
+	callback := func() error {
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			req, _ := http.NewRequest("POST", receiver.URL+"/hooks/order?attempt=1", strings.NewReader(`{"event":"order.created","order":{"id":7}}`))
+			req.Header.Set("Content-Type", "application/json")
+			if response, err := http.DefaultClient.Do(req); err == nil {
+				response.Body.Close()
+			}
+		}()
+		return nil
+	}
+
+	type event struct {
+		Event string
+		Order struct{ ID int }
+	}
+	expected := event{Event: "order.created"}
+	expected.Order.ID = 7
+
+	Steps{
+		StepFunc(callback),
+		receiver.ExpectReceived(time.Second,
+			WebhookMethod("POST"),
+			WebhookPath("/hooks/order?attempt=1"),
+			WebhookHeaderEquals("content-type", "application/json"),
+			WebhookBodyContains("order.created"),
+			WebhookBodyJSONMatchesStruct(expected),
+			WebhookJSONPathEquals("order.id", 7),
+		),
+		ExpectError(receiver.ExpectReceived(50 * time.Millisecond)),
+		receiver.RespondWith(http.StatusServiceUnavailable),
+		StepFunc(callback),
+		ExpectError(receiver.ExpectReceived(200*time.Millisecond, WebhookJSONPathEquals("order.id", 8))),
+		receiver.ExpectNotReceived(0, WebhookMethod("PUT")),
+		ExpectError(receiver.ExpectNotReceived(0, WebhookMethod("POST"))),
+	}.Test(t)
+	if requests := receiver.Requests(); len(requests) != 1 {
+		t.Fatalf("Expected one unconsumed request; found %d", len(requests))
+	}
+}
+
+func TestWebhookSignatures(t *testing.T) {
+	receiver, err := NewWebhookReceiver("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+
+	secret := []byte("shh")
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"event":"ping"}`
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	send := func(headers map[string]string) Step {
+		return StepFunc(func() error {
+			req, _ := http.NewRequest("POST", receiver.URL, strings.NewReader(body))
+			for key, value := range headers {
+				req.Header.Set(key, value)
+			}
+			response, err := http.DefaultClient.Do(req)
+			if err == nil {
+				response.Body.Close()
+			}
+			return err
+		})
+	}
+
+	Steps{
+		send(map[string]string{"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(hmacSHA256(secret, []byte(body)))}),
+		ExpectError(receiver.ExpectReceived(0, WebhookHMACSignature("X-Hub-Signature-256", "sha256=", []byte("wrong")))),
+		receiver.ExpectReceived(0, WebhookHMACSignature("X-Hub-Signature-256", "sha256=", secret)),
+
+		send(map[string]string{"Stripe-Signature": "t=" + timestamp + ",v1=00,v1=" + hex.EncodeToString(hmacSHA256(secret, []byte(timestamp+"."+body)))}),
+		ExpectError(receiver.ExpectReceived(0, WebhookStripeSignature("Stripe-Signature", []byte("wrong"), time.Minute))),
+		receiver.ExpectReceived(0, WebhookStripeSignature("Stripe-Signature", secret, time.Minute)),
+
+		send(map[string]string{"Stripe-Signature": "t=1000,v1=" + hex.EncodeToString(hmacSHA256(secret, []byte("1000."+body)))}),
+		ExpectError(receiver.ExpectReceived(0, WebhookStripeSignature("Stripe-Signature", secret, time.Minute))),
+		receiver.ExpectReceived(0, WebhookStripeSignature("Stripe-Signature", secret, 0)),
+
+		send(map[string]string{
+			"X-Signature-Ed25519":   hex.EncodeToString(ed25519.Sign(privateKey, []byte(timestamp+body))),
+			"X-Signature-Timestamp": timestamp,
+		}),
+		ExpectError(receiver.ExpectReceived(0, WebhookEd25519Signature("X-Signature-Ed25519", "", publicKey))),
+		receiver.ExpectReceived(0, WebhookEd25519Signature("X-Signature-Ed25519", "X-Signature-Timestamp", publicKey)),
+	}.Test(t)
+}
@@ -0,0 +1,620 @@
+package argot
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// WebSocket message types, as used in WsMessage.Type.
+const (
+	WsText   = 1
+	WsBinary = 2
+)
+
+const (
+	wsContinuation = 0
+	wsClose        = 8
+	wsPing         = 9
+	wsPong         = 10
+	wsGUID         = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+// DefaultWsMaxFrameSize is the largest frame payload, in bytes, that a
+// WsCall whose MaxFrameSize is zero will accept.
+const DefaultWsMaxFrameSize = 16 << 20
+
+// WsMessage is a complete (possibly reassembled) message received
+// over a WebSocket.
+type WsMessage struct {
+	// Type is either WsText or WsBinary.
+	Type int
+	Data []byte
+}
+
+func (m WsMessage) String() string {
+	if m.Type == WsText {
+		return fmt.Sprintf("text '%s'", m.Data)
+	} else {
+		return fmt.Sprintf("binary %x", m.Data)
+	}
+}
+
+// WsCall captures all the state relating to a single WebSocket
+// connection, in the same way that HttpCall does for HTTP. Pings from
+// the server are answered automatically. A WsCall can only be used by
+// a single go-routine at a time.
+type WsCall struct {
+	// Dialer is used to establish connections. If nil, a zero
+	// net.Dialer is used.
+	Dialer *net.Dialer
+	// TLSConfig is used for wss URLs.
+	TLSConfig *tls.Config
+	// HandshakeTimeout bounds dialing and the opening handshake. If
+	// zero, ten seconds is used.
+	HandshakeTimeout time.Duration
+	// MaxFrameSize is the largest frame payload, and the largest
+	// message assembled from continuation frames, in bytes, which is
+	// accepted from the server. A larger frame is a read error, after
+	// which no more messages are received, as is a larger message,
+	// which also closes the connection with code 1009 (Message Too
+	// Big). If zero, DefaultWsMaxFrameSize is used.
+	MaxFrameSize int64
+	// Response is the response to the opening handshake.
+	Response *http.Response
+
+	conn      net.Conn
+	writeLock sync.Mutex
+	messages  chan WsMessage
+	pongs     chan []byte
+	stop      chan struct{}
+	done      chan struct{}
+
+	lock        sync.Mutex
+	closeCode   int
+	closeReason string
+	closeSent   bool
+	readErr     error
+}
+
+// NewWsCall creates a new WsCall.
+func NewWsCall() *WsCall {
+	return &WsCall{}
+}
+
+// Reset is idempotent. It closes any open connection. You should
+// ensure this is called at the end of life for each WsCall.
+func (ws *WsCall) Reset() error {
+	if ws.conn != nil {
+		close(ws.stop)
+		ws.conn.Close()
+		<-ws.done
+	}
+	ws.conn = nil
+	ws.Response = nil
+	ws.closeCode = 0
+	ws.closeReason = ""
+	ws.closeSent = false
+	ws.readErr = nil
+	return nil
+}
+
+// AssertConnected returns nil iff there is an open connection.
+func (ws *WsCall) AssertConnected() error {
+	if ws.conn == nil {
+		return errors.New("WebSocket: not connected.")
+	} else {
+		return nil
+	}
+}
+
+func wsAccept(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Dial is a Step that when executed resets ws, connects to the ws or
+// wss URL, and performs the opening handshake with the given
+// additional headers (which may be nil). The step errors unless the
+// server switches protocols.
+func (ws *WsCall) Dial(urlStr string, header http.Header) Step {
+	return NewNamedStep(fmt.Sprintf("Dial(%s)", DefaultRedactor.String(urlStr)), func() error {
+		if err := ws.Reset(); err != nil {
+			return err
+		}
+		u, err := url.Parse(urlStr)
+		if err != nil {
+			return err
+		}
+		host := u.Host
+		switch u.Scheme {
+		case "ws":
+			u.Scheme = "http"
+			if u.Port() == "" {
+				host = net.JoinHostPort(u.Hostname(), "80")
+			}
+		case "wss":
+			u.Scheme = "https"
+			if u.Port() == "" {
+				host = net.JoinHostPort(u.Hostname(), "443")
+			}
+		default:
+			return fmt.Errorf("WebSocket: unsupported scheme '%s'.", u.Scheme)
+		}
+
+		timeout := ws.HandshakeTimeout
+		if timeout == 0 {
+			timeout = 10 * time.Second
+		}
+		dialer := ws.Dialer
+		if dialer == nil {
+			dialer = new(net.Dialer)
+		}
+		deadline := time.Now().Add(timeout)
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		conn, err := dialer.DialContext(ctx, "tcp", host)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("WebSocket: error when dialing %s: %v", host, err)
+		}
+		conn.SetDeadline(deadline)
+		if u.Scheme == "https" {
+			config := ws.TLSConfig
+			if config == nil {
+				config = new(tls.Config)
+			}
+			if config.ServerName == "" {
+				config = config.Clone()
+				config.ServerName = u.Hostname()
+			}
+			tlsConn := tls.Client(conn, config)
+			if err := tlsConn.Handshake(); err != nil {
+				conn.Close()
+				return err
+			}
+			conn = tlsConn
+		}
+
+		nonce := make([]byte, 16)
+		rand.Read(nonce)
+		key := base64.StdEncoding.EncodeToString(nonce)
+		req, err := http.NewRequest("GET", u.String(), nil)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		for k, values := range header {
+			req.Header[k] = append([]string(nil), values...)
+		}
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Sec-WebSocket-Key", key)
+		req.Header.Set("Sec-WebSocket-Version", "13")
+
+		reader := bufio.NewReader(conn)
+		if err := req.Write(conn); err != nil {
+			conn.Close()
+			return err
+		}
+		response, err := http.ReadResponse(reader, req)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		ws.Response = response
+		if response.StatusCode != http.StatusSwitchingProtocols {
+			conn.Close()
+			return fmt.Errorf("WebSocket: Expected status %d; found %d.", http.StatusSwitchingProtocols, response.StatusCode)
+		} else if accept := response.Header.Get("Sec-WebSocket-Accept"); accept != wsAccept(key) {
+			conn.Close()
+			return fmt.Errorf("WebSocket: Sec-WebSocket-Accept: Expected '%s'; found '%s'.", wsAccept(key), accept)
+		}
+		conn.SetDeadline(time.Time{})
+
+		ws.conn = conn
+		ws.messages = make(chan WsMessage, 256)
+		ws.pongs = make(chan []byte, 16)
+		ws.stop = make(chan struct{})
+		ws.done = make(chan struct{})
+		go ws.readLoop(reader, ws.messages, ws.pongs, ws.stop, ws.done)
+		return nil
+	})
+}
+
+// writeWsFrame writes a single final frame. Frames sent by clients
+// must be masked.
+func writeWsFrame(w io.Writer, opcode int, payload []byte, masked bool) error {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(0x80 | byte(opcode))
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch l := len(payload); {
+	case l < 126:
+		buf.WriteByte(maskBit | byte(l))
+	case l <= 0xffff:
+		buf.WriteByte(maskBit | 126)
+		binary.Write(buf, binary.BigEndian, uint16(l))
+	default:
+		buf.WriteByte(maskBit | 127)
+		binary.Write(buf, binary.BigEndian, uint64(l))
+	}
+	if masked {
+		mask := make([]byte, 4)
+		rand.Read(mask)
+		buf.Write(mask)
+		for idx, b := range payload {
+			buf.WriteByte(b ^ mask[idx%4])
+		}
+	} else {
+		buf.Write(payload)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// readWsFrame reads a single frame, unmasking it if necessary. It
+// errors, without reading the payload, if the payload is longer than
+// maxSize bytes.
+func readWsFrame(r io.Reader, maxSize int64) (fin bool, opcode int, payload []byte, err error) {
+	header := make([]byte, 2)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = int(header[0] & 0x0f)
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var l uint16
+		err = binary.Read(r, binary.BigEndian, &l)
+		length = uint64(l)
+	case 127:
+		err = binary.Read(r, binary.BigEndian, &length)
+	}
+	if err != nil {
+		return
+	} else if length > uint64(maxSize) {
+		err = fmt.Errorf("WebSocket: Expected a frame of at most %d bytes (see MaxFrameSize); found %d.", maxSize, length)
+		return
+	}
+	var mask []byte
+	if header[1]&0x80 != 0 {
+		mask = make([]byte, 4)
+		if _, err = io.ReadFull(r, mask); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	for idx := range mask {
+		for i := idx; i < len(payload); i += 4 {
+			payload[i] ^= mask[idx]
+		}
+	}
+	return
+}
+
+func (ws *WsCall) writeFrame(opcode int, payload []byte) error {
+	ws.writeLock.Lock()
+	defer ws.writeLock.Unlock()
+	return writeWsFrame(ws.conn, opcode, payload, true)
+}
+
+func (ws *WsCall) readLoop(reader *bufio.Reader, messages chan WsMessage, pongs chan []byte, stop, done chan struct{}) {
+	defer close(done)
+	maxSize := ws.MaxFrameSize
+	if maxSize <= 0 {
+		maxSize = DefaultWsMaxFrameSize
+	}
+	var message WsMessage
+	for {
+		fin, opcode, payload, err := readWsFrame(reader, maxSize)
+		if err != nil {
+			ws.lock.Lock()
+			ws.readErr = err
+			ws.lock.Unlock()
+			return
+		}
+		switch opcode {
+		case wsPing:
+			ws.writeFrame(wsPong, payload)
+		case wsPong:
+			select {
+			case pongs <- payload:
+			default:
+			}
+		case wsClose:
+			ws.lock.Lock()
+			ws.closeCode = 1005 // No status received.
+			if len(payload) >= 2 {
+				ws.closeCode = int(binary.BigEndian.Uint16(payload))
+				ws.closeReason = string(payload[2:])
+			}
+			reply := !ws.closeSent
+			ws.closeSent = true
+			ws.lock.Unlock()
+			if reply && len(payload) >= 2 {
+				ws.writeFrame(wsClose, payload[:2])
+			} else if reply {
+				ws.writeFrame(wsClose, nil)
+			}
+			ws.conn.Close()
+			return
+		case wsContinuation:
+			if message.Type == wsContinuation {
+				ws.lock.Lock()
+				ws.readErr = errors.New("WebSocket: Expected a continuation frame only within a fragmented message; found one with no message in progress.")
+				ws.lock.Unlock()
+				return
+			} else if size := int64(len(message.Data) + len(payload)); size > maxSize {
+				ws.lock.Lock()
+				ws.readErr = fmt.Errorf("WebSocket: Expected a message of at most %d bytes (see MaxFrameSize); found at least %d.", maxSize, size)
+				reply := !ws.closeSent
+				ws.closeSent = true
+				ws.lock.Unlock()
+				if reply {
+					ws.writeFrame(wsClose, []byte{0x03, 0xf1}) // 1009: Message Too Big.
+				}
+				ws.conn.Close()
+				return
+			}
+			message.Data = append(message.Data, payload...)
+		default:
+			message = WsMessage{Type: opcode, Data: payload}
+		}
+		if fin && (opcode == wsContinuation || opcode == WsText || opcode == WsBinary) {
+			select {
+			case messages <- message:
+			case <-stop:
+				return
+			}
+			message = WsMessage{}
+		}
+	}
+}
+
+func (ws *WsCall) send(opcode int, payload []byte) error {
+	if err := ws.AssertConnected(); err != nil {
+		return err
+	} else {
+		return ws.writeFrame(opcode, payload)
+	}
+}
+
+// SendText is a Step that when executed sends a text message.
+func (ws *WsCall) SendText(text string) Step {
+	return NewNamedStep(fmt.Sprintf("SendText(%s)", text), func() error {
+		return ws.send(WsText, []byte(text))
+	})
+}
+
+// SendBinary is a Step that when executed sends a binary message.
+func (ws *WsCall) SendBinary(data []byte) Step {
+	return NewNamedStep("SendBinary", func() error {
+		return ws.send(WsBinary, data)
+	})
+}
+
+// SendJSON is a Step that when executed encodes value as JSON and
+// sends it as a text message.
+func (ws *WsCall) SendJSON(value interface{}) Step {
+	return NewNamedStep("SendJSON", func() error {
+		if bites, err := json.Marshal(value); err != nil {
+			return err
+		} else {
+			return ws.send(WsText, bites)
+		}
+	})
+}
+
+// receive waits up to timeout for the next message.
+func (ws *WsCall) receive(timeout time.Duration) (WsMessage, error) {
+	if err := ws.AssertConnected(); err != nil {
+		return WsMessage{}, err
+	}
+	select {
+	case message := <-ws.messages:
+		return message, nil
+	case <-ws.done:
+		select {
+		case message := <-ws.messages:
+			return message, nil
+		default:
+			return WsMessage{}, fmt.Errorf("WebSocket: Expected a message; connection closed (%s).", ws.closeStatus())
+		}
+	case <-time.After(timeout):
+		return WsMessage{}, fmt.Errorf("WebSocket: Expected a message within %v; none received.", timeout)
+	}
+}
+
+func (ws *WsCall) closeStatus() string {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	if ws.closeCode != 0 {
+		return fmt.Sprintf("code %d: '%s'", ws.closeCode, ws.closeReason)
+	} else if ws.readErr != nil {
+		return ws.readErr.Error()
+	} else {
+		return "no close frame"
+	}
+}
+
+// ExpectMessage is a Step that when executed waits up to timeout for
+// the next message, and errors unless check returns nil for it.
+func (ws *WsCall) ExpectMessage(timeout time.Duration, check func(WsMessage) error) Step {
+	return NewNamedStep("ExpectMessage", func() error {
+		if message, err := ws.receive(timeout); err != nil {
+			return err
+		} else {
+			return check(message)
+		}
+	})
+}
+
+func expectWsText(message WsMessage) error {
+	if message.Type != WsText {
+		return fmt.Errorf("WebSocket: Expected a text message; found %v.", message)
+	} else {
+		return nil
+	}
+}
+
+// ExpectText is a Step that when executed waits up to timeout for the
+// next message, and errors unless it is a text message equal to text.
+func (ws *WsCall) ExpectText(text string, timeout time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectText(%s)", text), func() error {
+		if message, err := ws.receive(timeout); err != nil {
+			return err
+		} else if err := expectWsText(message); err != nil {
+			return err
+		} else if found := string(message.Data); found != text {
+			return diffError("message", text, found, "WebSocket: Diff: '%s'.", diff(text, found))
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectTextMatches is a Step that when executed waits up to timeout
+// for the next message, and errors unless it is a text message
+// matching the regular expression.
+func (ws *WsCall) ExpectTextMatches(pattern *regexp.Regexp, timeout time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectTextMatches(%v)", pattern), func() error {
+		if message, err := ws.receive(timeout); err != nil {
+			return err
+		} else if err := expectWsText(message); err != nil {
+			return err
+		} else if !pattern.Match(message.Data) {
+			return fmt.Errorf("WebSocket: Expected to match the pattern '%v'; found '%s'.", pattern, message.Data)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectBinary is a Step that when executed waits up to timeout for
+// the next message, and errors unless it is a binary message equal to
+// data.
+func (ws *WsCall) ExpectBinary(data []byte, timeout time.Duration) Step {
+	return NewNamedStep("ExpectBinary", func() error {
+		if message, err := ws.receive(timeout); err != nil {
+			return err
+		} else if message.Type != WsBinary || !bytes.Equal(message.Data, data) {
+			return fmt.Errorf("WebSocket: Expected binary %x; found %v.", data, message)
+		} else {
+			return nil
+		}
+	})
+}
+
+// ExpectJSONMatchesStruct is a Step that when executed waits up to
+// timeout for the next message, parses it as JSON based on the type
+// of expected, and errors unless it equals expected (see
+// HttpCall.ResponseBodyJSONMatchesStruct).
+func (ws *WsCall) ExpectJSONMatchesStruct(expected interface{}, timeout time.Duration) Step {
+	return NewNamedStep("ExpectJSONMatchesStruct", func() error {
+		parseAs := reflect.New(reflect.TypeOf(expected)).Interface()
+		if message, err := ws.receive(timeout); err != nil {
+			return err
+		} else if err := json.Unmarshal(message.Data, parseAs); err != nil {
+			return err
+		} else if err := compareError(parseAs, expected); err != nil {
+			return err
+		} else {
+			return nil
+		}
+	})
+}
+
+// Ping is a Step that when executed sends a ping with the given
+// payload. Use ExpectPong to wait for the reply.
+func (ws *WsCall) Ping(payload string) Step {
+	return NewNamedStep(fmt.Sprintf("Ping(%s)", payload), func() error {
+		return ws.send(wsPing, []byte(payload))
+	})
+}
+
+// ExpectPong is a Step that when executed waits up to timeout for a
+// pong, and errors unless one arrives with the given payload.
+func (ws *WsCall) ExpectPong(payload string, timeout time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectPong(%s)", payload), func() error {
+		if err := ws.AssertConnected(); err != nil {
+			return err
+		}
+		select {
+		case found := <-ws.pongs:
+			if string(found) != payload {
+				return fmt.Errorf("WebSocket: Pong: Expected '%s'; found '%s'.", payload, found)
+			}
+			return nil
+		case <-ws.done:
+			return fmt.Errorf("WebSocket: Expected a pong; connection closed (%s).", ws.closeStatus())
+		case <-time.After(timeout):
+			return fmt.Errorf("WebSocket: Expected a pong within %v; none received.", timeout)
+		}
+	})
+}
+
+// Close is a Step that when executed starts the closing handshake by
+// sending a close frame with the given code and reason. Use
+// ExpectClose to wait for the server to complete the handshake.
+func (ws *WsCall) Close(code int, reason string) Step {
+	return NewNamedStep(fmt.Sprintf("Close(%d)", code), func() error {
+		payload := make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, reason...)
+		ws.lock.Lock()
+		ws.closeSent = true
+		ws.lock.Unlock()
+		return ws.send(wsClose, payload)
+	})
+}
+
+// ExpectClose is a Step that when executed waits up to timeout for the
+// server to close the connection, and errors unless it does so with a
+// close frame carrying the given code. Any messages received in the
+// meantime are discarded.
+func (ws *WsCall) ExpectClose(code int, timeout time.Duration) Step {
+	return NewNamedStep(fmt.Sprintf("ExpectClose(%d)", code), func() error {
+		if err := ws.AssertConnected(); err != nil {
+			return err
+		}
+		expired := time.After(timeout)
+		for {
+			select {
+			case <-ws.messages:
+			case <-ws.done:
+				ws.lock.Lock()
+				defer ws.lock.Unlock()
+				if ws.closeCode == 0 {
+					return fmt.Errorf("WebSocket: Expected close code %d; connection ended without a close frame: %v", code, ws.readErr)
+				} else if ws.closeCode != code {
+					return fmt.Errorf("WebSocket: Close code: Expected %d; found %d ('%s').", code, ws.closeCode, ws.closeReason)
+				}
+				return nil
+			case <-expired:
+				return fmt.Errorf("WebSocket: Expected close within %v; connection still open.", timeout)
+			}
+		}
+	})
+}
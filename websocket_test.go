@@ -0,0 +1,114 @@
+package argot
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsEchoServer is a minimal WebSocket server which echoes messages,
+// and closes the connection with code 4000 on receiving "bye".
+func wsEchoServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + wsAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		rw.Flush()
+		reader := bufio.NewReader(rw)
+		for {
+			_, opcode, payload, err := readWsFrame(reader, DefaultWsMaxFrameSize)
+			if err != nil {
+				return
+			}
+			switch {
+			case opcode == wsPing:
+				writeWsFrame(conn, wsPong, payload, false)
+			case opcode == wsClose:
+				writeWsFrame(conn, wsClose, payload, false)
+				return
+			case string(payload) == "bye":
+				writeWsFrame(conn, wsClose, []byte{0x0f, 0xa0}, false)
+			case string(payload) == "fragments":
+				// "abcdef" as a text frame and two continuation frames.
+				conn.Write([]byte{WsText, 2, 'a', 'b', wsContinuation, 2, 'c', 'd', 0x80 | wsContinuation, 2, 'e', 'f'})
+			case string(payload) == "orphan":
+				conn.Write([]byte{0x80 | wsContinuation, 1, 'x'})
+			default:
+				writeWsFrame(conn, opcode, payload, false)
+			}
+		}
+	}))
+}
+
+func TestWsCall(t *testing.T) {
+	server := wsEchoServer(t)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	ws := NewWsCall()
+	defer ws.Reset()
+	Steps{
+		ws.Dial(url, http.Header{"Authorization": {"token"}}),
+		ws.SendText("hello"),
+		ws.ExpectText("hello", time.Second),
+		ws.SendJSON(map[string]int{"a": 1}),
+		ws.ExpectJSONMatchesStruct(map[string]int{"a": 1}, time.Second),
+		ws.SendBinary([]byte{1, 2, 3}),
+		ws.ExpectBinary([]byte{1, 2, 3}, time.Second),
+		ws.Ping("p"),
+		ws.ExpectPong("p", time.Second),
+		ws.SendText("bye"),
+		ws.ExpectClose(4000, time.Second),
+	}.Test(t)
+
+	Steps{
+		ws.Dial(url, nil),
+		ws.Close(1000, "done"),
+		ws.ExpectClose(1000, time.Second),
+	}.Test(t)
+
+	ws.MaxFrameSize = 4
+	Steps{
+		ws.Dial(url, nil),
+		ws.SendText("hi"),
+		ws.ExpectText("hi", time.Second),
+		ws.SendText("hello"),
+	}.Test(t)
+	if err := ws.ExpectText("hello", time.Second).Go(); err == nil || !strings.Contains(err.Error(), "Expected a frame of at most 4 bytes (see MaxFrameSize); found 5.") {
+		t.Fatalf("Expected the oversized frame to be rejected; found %v", err)
+	}
+
+	// Messages assembled from continuation frames are limited too.
+	ws.MaxFrameSize = 0
+	Steps{
+		ws.Dial(url, nil),
+		ws.SendText("fragments"),
+		ws.ExpectText("abcdef", time.Second),
+	}.Test(t)
+	ws.MaxFrameSize = 4
+	Steps{
+		ws.Dial(url, nil),
+		ws.SendText("fragments"),
+	}.Test(t)
+	if err := ws.ExpectText("abcdef", time.Second).Go(); err == nil || !strings.Contains(err.Error(), "Expected a message of at most 4 bytes (see MaxFrameSize); found at least 6.") {
+		t.Fatalf("Expected the oversized message to be rejected; found %v", err)
+	}
+
+	// A continuation frame must continue a message.
+	ws.MaxFrameSize = 0
+	Steps{
+		ws.Dial(url, nil),
+		ws.SendText("orphan"),
+	}.Test(t)
+	if err := ws.ExpectText("x", time.Second).Go(); err == nil || !strings.Contains(err.Error(), "no message in progress") {
+		t.Fatalf("Expected the continuation frame to be rejected; found %v", err)
+	}
+}